@@ -0,0 +1,400 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// An AnchorNamer returns the anchor name for a value the encoder writes more
+// than once. key is the mapping key or struct field name the value first
+// appears under (or the key of the enclosing collection for sequence items),
+// and v is the value itself. Returning "" selects a generated name.
+// Characters that cannot appear in an anchor are replaced by '_', and a
+// numeric suffix is added when the name is already taken.
+type AnchorNamer func(key string, v interface{}) string
+
+// AnchorPointers makes the encoder write a pointer that is reachable more
+// than once from the encoded value in full only the first time, with an
+// anchor, and as an alias everywhere else. This also allows encoding
+// cyclic structures.
+//
+// Independently of this setting, a struct field tagged with `,anchor=name`
+// writes its value with the anchor &name, and a field tagged with
+// `,alias=name` is written as the alias *name, whatever its value, e.g. to
+// merge defaults with `yaml:"<<,alias=defaults"`. The names are written as
+// given. When a field with an anchor holds a shared pointer, its aliases
+// use that name.
+func (e *Encoder) AnchorPointers(enable bool) {
+	e.anchorPointers = enable
+}
+
+// AnchorNames sets the function naming the anchors written by the encoder.
+// By default anchors are named id001, id002 and so on.
+func (e *Encoder) AnchorNames(namer AnchorNamer) {
+	e.anchorNamer = namer
+}
+
+// An AnchorPolicy controls how the anchors of pointers shared by several
+// documents of a stream are written.
+type AnchorPolicy int
+
+const (
+	// DocumentAnchors writes each document on its own: a pointer is
+	// written in full in every document holding it, and anchor names are
+	// chosen anew for each document.
+	DocumentAnchors AnchorPolicy = iota
+	// ReuseAnchors writes a pointer that was anchored in an earlier
+	// document as an alias to that anchor. YAML scopes anchors to their
+	// document, so only decoders that keep anchors across the documents
+	// of a stream, as Decoder does, can read such streams.
+	ReuseAnchors
+	// RedefineAnchors writes a pointer that was anchored in an earlier
+	// document in full again, with the same anchor, so that every
+	// document stands alone and the names stay the same across them.
+	RedefineAnchors
+)
+
+// StreamAnchors sets how AnchorPointers treats pointers that the documents
+// written by Encode share: whether later documents alias them, define them
+// again with the same anchors, or ignore the earlier documents, which is
+// the default. Anchor names are unique across the stream unless the policy
+// is DocumentAnchors. Reset forgets the anchors of earlier documents.
+func (e *Encoder) StreamAnchors(policy AnchorPolicy) {
+	e.anchorPolicy = policy
+}
+
+// Deduplicate makes the encoder look for identical sequences and mappings
+// made of at least minNodes nodes (counting the collection itself and every
+// value, key and item inside it). Each repeated subtree is written in full
+// once, with an anchor, and as an alias everywhere else. Anchors are named
+// with the function set by AnchorNames, which receives a nil value. A
+// minNodes of zero disables deduplication.
+func (e *Encoder) Deduplicate(minNodes int) {
+	e.dedupMinNodes = minNodes
+}
+
+type pointerKey struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+type pointerAnchor struct {
+	key   string
+	count int
+	name  string
+	ptr   reflect.Value
+}
+
+// A streamAnchor is the anchor of a pointer in an earlier document. It
+// holds on to the pointer so that its address is not reused by another
+// value while the stream is written.
+type streamAnchor struct {
+	name string
+	ptr  reflect.Value
+}
+
+// countPointers records how often each pointer is reachable from v.
+func (e *Encoder) countPointers(key string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || v.Type().Elem() == nodeType {
+			return
+		}
+		k := pointerKey{v.Type(), v.Pointer()}
+		if p, ok := e.pointers[k]; ok {
+			p.count++
+			return
+		}
+		e.pointers[k] = &pointerAnchor{key: key, count: 1, ptr: v}
+		if e.anchorPolicy == ReuseAnchors && e.streamAnchors[k].name != "" {
+			// written as an alias, so its content is not written
+			return
+		}
+		e.countPointers(key, v.Elem())
+	case reflect.Interface:
+		if !v.IsNil() {
+			e.countPointers(key, v.Elem())
+		}
+	case reflect.Map:
+		// visit the keys in the order they are written, so that the key
+		// passed to the AnchorNamer is the first one in the output
+		for _, k := range e.mapKeys(v) {
+			e.countPointers(fmt.Sprint(k.value.Interface()), v.MapIndex(k.key))
+		}
+	case reflect.Struct:
+		if v.Type() == timeTimeType || v.Type() == nodeType {
+			return
+		}
+		for _, f := range cachedTypeFields(v.Type()) {
+			if fv := fieldByIndex(v, f.index); fv.IsValid() {
+				e.countPointers(f.name, fv)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e.countPointers(key, v.Index(i))
+		}
+	}
+}
+
+// emitAlias writes an alias when the pointer v was already written, and
+// returns true. Otherwise it names the anchor of a shared pointer for the
+// next node and returns false.
+func (e *Encoder) emitAlias(v reflect.Value) bool {
+	k := pointerKey{v.Type(), v.Pointer()}
+	p := e.pointers[k]
+	if p == nil {
+		return false
+	}
+
+	// anchored in an earlier document
+	if earlier := e.streamAnchors[k].name; earlier != "" && p.name == "" {
+		switch e.anchorPolicy {
+		case ReuseAnchors:
+			yaml_alias_event_initialize(&e.event, []byte(earlier))
+			e.emit()
+			return true
+		case RedefineAnchors:
+			if e.anchor == "" {
+				p.name = earlier
+				e.anchor = earlier
+				return false
+			}
+		}
+	}
+
+	if p.count < 2 {
+		return false
+	}
+
+	if p.name != "" {
+		yaml_alias_event_initialize(&e.event, []byte(p.name))
+		e.emit()
+		return true
+	}
+
+	// a pointer held by a field with an anchor option takes its name
+	if e.anchor != "" {
+		p.name = e.anchor
+		return false
+	}
+
+	name := ""
+	if e.anchorNamer != nil && !e.deterministic {
+		name = e.anchorNamer(p.key, v.Interface())
+	}
+	p.name = e.anchorName(name)
+	e.anchor = p.name
+	return false
+}
+
+// startAnchors prepares the anchor names of a new document written by
+// Encode, reserving those of earlier documents unless each document is
+// written on its own.
+func (e *Encoder) startAnchors() {
+	e.anchorNames = nil
+	if e.anchorPolicy == DocumentAnchors || len(e.streamAnchors) == 0 {
+		return
+	}
+	e.anchorNames = make(map[string]bool, len(e.streamAnchors))
+	for _, a := range e.streamAnchors {
+		e.anchorNames[a.name] = true
+	}
+}
+
+// endAnchors records the anchors of the pointers of the document written
+// by Encode for the documents after it.
+func (e *Encoder) endAnchors() {
+	if e.anchorPolicy == DocumentAnchors {
+		return
+	}
+	for k, p := range e.pointers {
+		if p.name == "" {
+			continue
+		}
+		if e.streamAnchors == nil {
+			e.streamAnchors = make(map[pointerKey]streamAnchor)
+		}
+		if _, ok := e.streamAnchors[k]; !ok {
+			e.streamAnchors[k] = streamAnchor{p.name, p.ptr}
+		}
+	}
+}
+
+// anchorName turns name into a valid anchor not used in the current
+// document, generating one when name is empty.
+func (e *Encoder) anchorName(name string) string {
+	if e.anchorNames == nil {
+		e.anchorNames = make(map[string]bool)
+	}
+
+	if name == "" {
+		for i := len(e.anchorNames) + 1; ; i++ {
+			name = fmt.Sprintf("id%03d", i)
+			if !e.anchorNames[name] {
+				break
+			}
+		}
+	} else {
+		b := []byte(name)
+		for i := range b {
+			if !is_alpha(b[i]) {
+				b[i] = '_'
+			}
+		}
+		name = string(b)
+		for i, base := 2, name; e.anchorNames[name]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+	}
+
+	e.anchorNames[name] = true
+	return name
+}
+
+// defineAnchor sets the anchor of the next node to name, which is kept as
+// given, so that aliases written with the alias option can refer to it.
+func (e *Encoder) defineAnchor(name string) {
+	if e.anchorNames == nil {
+		e.anchorNames = make(map[string]bool)
+	}
+	e.anchorNames[name] = true
+	e.anchor = name
+}
+
+// takeAnchor returns the anchor for the next node, if any, and clears it.
+func (e *Encoder) takeAnchor() []byte {
+	if e.anchor == "" {
+		return nil
+	}
+	anchor := []byte(e.anchor)
+	e.anchor = ""
+	return anchor
+}
+
+// emitDeduplicated writes the recorded events, replacing repeated subtrees
+// with aliases to their first occurrence.
+func (e *Encoder) emitDeduplicated() {
+	events := e.events
+	e.events = nil
+
+	// end[i] is the index following the node starting at event i, size[i]
+	// the number of nodes in it, and anchored[i] whether it defines an
+	// anchor and so cannot be repeated.
+	end := make([]int, len(events))
+	size := make([]int, len(events))
+	anchored := make([]bool, len(events))
+	var open []int
+	for i := len(events) - 1; i >= 0; i-- {
+		switch events[i].event_type {
+		case yaml_SEQUENCE_END_EVENT, yaml_MAPPING_END_EVENT:
+			open = append(open, i)
+			continue
+		case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+			end[i] = open[len(open)-1] + 1
+			open = open[:len(open)-1]
+		default:
+			end[i] = i + 1
+		}
+
+		size[i] = 1
+		anchored[i] = len(events[i].anchor) > 0 && events[i].event_type != yaml_ALIAS_EVENT
+		for j := i + 1; j < end[i]-1; j = end[j] {
+			size[i] += size[j]
+			anchored[i] = anchored[i] || anchored[j]
+		}
+	}
+
+	// find the subtrees repeating an earlier one, outermost first
+	seen := make(map[string]int)
+	keys := make(map[int]string)
+	aliasOf := make(map[int]int)
+	var visit func(i int, key string)
+	visit = func(i int, key string) {
+		t := events[i].event_type
+		if (t == yaml_SEQUENCE_START_EVENT || t == yaml_MAPPING_START_EVENT) &&
+			size[i] >= e.dedupMinNodes && !anchored[i] {
+			k := subtreeKey(events[i:end[i]])
+			if first, ok := seen[k]; ok {
+				aliasOf[i] = first
+				return
+			}
+			seen[k] = i
+			keys[i] = key
+		}
+
+		for j, n := i+1, 0; j < end[i]-1; j, n = end[j], n+1 {
+			if t == yaml_MAPPING_START_EVENT && n%2 == 0 {
+				key = ""
+				if events[j].event_type == yaml_SCALAR_EVENT {
+					key = string(events[j].value)
+				}
+			}
+			visit(j, key)
+		}
+	}
+	visit(0, "")
+
+	targets := make(map[int]string)
+	for _, first := range aliasOf {
+		targets[first] = ""
+	}
+
+	for i := 0; i < len(events); {
+		if first, ok := aliasOf[i]; ok {
+			yaml_alias_event_initialize(&e.event, []byte(targets[first]))
+			e.emit()
+			i = end[i]
+			continue
+		}
+
+		e.event = events[i]
+		if _, ok := targets[i]; ok {
+			name := ""
+			if e.anchorNamer != nil && !e.deterministic {
+				name = e.anchorNamer(keys[i], nil)
+			}
+			targets[i] = e.anchorName(name)
+			e.event.anchor = []byte(targets[i])
+		}
+		e.emit()
+		i++
+	}
+}
+
+// subtreeKey returns a string identifying the content of events.
+func subtreeKey(events []yaml_event_t) string {
+	var b bytes.Buffer
+	for _, ev := range events {
+		b.WriteString(strconv.Itoa(int(ev.event_type)))
+		b.WriteByte(' ')
+		b.WriteString(strconv.Itoa(int(ev.style)))
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatBool(ev.implicit))
+		b.WriteString(strconv.FormatBool(ev.quoted_implicit))
+		for _, s := range [][]byte{ev.anchor, ev.tag, ev.value} {
+			b.WriteByte(' ')
+			b.WriteString(strconv.Itoa(len(s)))
+			b.WriteByte(':')
+			b.Write(s)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,51 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+// Annotate sets the annotation key of n to value, or removes it when value
+// is nil. Annotations are metadata of tools, such as where a node came
+// from, which are never encoded.
+func (n *Node) Annotate(key string, value interface{}) {
+	if value == nil {
+		delete(n.Annotations, key)
+		return
+	}
+	if n.Annotations == nil {
+		n.Annotations = make(map[string]interface{})
+	}
+	n.Annotations[key] = value
+}
+
+// Annotation returns the annotation key of n, nil if it has none.
+func (n *Node) Annotation(key string) interface{} {
+	return n.Annotations[key]
+}
+
+// mergeAnnotations returns a copy of the annotations of base with those of
+// over set on top, so that the nodes copied or replaced by a transformation
+// do not share them.
+func mergeAnnotations(base, over map[string]interface{}) map[string]interface{} {
+	if len(base) == 0 && len(over) == 0 {
+		return nil
+	}
+	merged := make(map[string]interface{}, len(base)+len(over))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range over {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,68 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Annotations", func() {
+	var root Node
+
+	BeforeEach(func() {
+		root = Node{}
+		Expect(Unmarshal([]byte("a: &a {x: 1}\nb: *a\nc: !var port\n"), &root)).To(Succeed())
+	})
+
+	It("sets and removes annotations", func() {
+		n := root.Content[1]
+		Expect(n.Annotation("source")).To(BeNil())
+		n.Annotate("source", "base.yml:1")
+		Expect(n.Annotation("source")).To(Equal("base.yml:1"))
+		n.Annotate("source", nil)
+		Expect(n.Annotations).To(BeEmpty())
+	})
+
+	It("does not encode annotations", func() {
+		root.Annotate("source", "base.yml")
+		root.Content[1].Annotate("overlay", "prod")
+		out, err := Marshal(&root)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("a: &a {x: 1}\nb: *a\nc: !var port\n"))
+	})
+
+	It("keeps annotations through inlining, on copies of their own", func() {
+		root.Content[1].Annotate("source", "base.yml")
+		root.Content[1].Content[1].Annotate("source", "base.yml:1")
+		root.Content[3].Annotate("overlay", "prod")
+		Expect(root.Content[3].Inline()).To(Succeed())
+
+		b := root.Content[3]
+		Expect(b.Annotations).To(Equal(map[string]interface{}{"source": "base.yml", "overlay": "prod"}))
+		Expect(b.Content[1].Annotation("source")).To(Equal("base.yml:1"))
+
+		b.Content[1].Annotate("source", "changed")
+		Expect(root.Content[1].Content[1].Annotation("source")).To(Equal("base.yml:1"))
+		Expect(root.Content[1].Annotation("overlay")).To(BeNil())
+	})
+
+	It("keeps the annotations of substituted placeholders", func() {
+		root.Content[5].Annotate("source", "base.yml:3")
+		Expect(root.Substitute(map[string]interface{}{"port": 8080})).To(Succeed())
+		Expect(root.Content[5].Value).To(Equal("8080"))
+		Expect(root.Content[5].Annotation("source")).To(Equal("base.yml:3"))
+	})
+})
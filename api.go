@@ -31,6 +31,29 @@ func yaml_parser_initialize(parser *yaml_parser_t) bool {
 	return true
 }
 
+/*
+ * Reset a parser object for a new input, keeping its buffers and options.
+ */
+
+func yaml_parser_reset(parser *yaml_parser_t) {
+	*parser = yaml_parser_t{
+		yaml_parser_options_t: parser.yaml_parser_options_t,
+
+		raw_buffer:         parser.raw_buffer[:0],
+		buffer:             parser.buffer[:0],
+		comments:           parser.comments[:0],
+		tokens:             parser.tokens[:0],
+		indents:            parser.indents[:0],
+		simple_keys:        parser.simple_keys[:0],
+		states:             parser.states[:0],
+		marks:              parser.marks[:0],
+		tag_directives:     parser.tag_directives[:0],
+		indentation_issues: parser.indentation_issues[:0],
+		unknown_directives: parser.unknown_directives[:0],
+		source:             parser.source[:0],
+	}
+}
+
 /*
  * Destroy a parser object.
  */
@@ -121,6 +144,37 @@ func yaml_emitter_initialize(emitter *yaml_emitter_t) {
 		raw_buffer: make([]byte, 0, OUTPUT_RAW_BUFFER_SIZE),
 		states:     make([]yaml_emitter_state_t, 0, INITIAL_STACK_SIZE),
 		events:     make([]yaml_event_t, 0, INITIAL_QUEUE_SIZE),
+		flush_size: OUTPUT_FLUSH_SIZE,
+	}
+}
+
+/*
+ * Reset an emitter object for a new output, keeping its buffers and options.
+ */
+
+func yaml_emitter_reset(emitter *yaml_emitter_t) {
+	*emitter = yaml_emitter_t{
+		buffer:         emitter.buffer,
+		raw_buffer:     emitter.raw_buffer[:0],
+		states:         emitter.states[:0],
+		events:         emitter.events[:0],
+		indents:        emitter.indents[:0],
+		tag_directives: emitter.tag_directives[:0],
+		canonical:      emitter.canonical,
+		compact:        emitter.compact,
+		best_indent:    emitter.best_indent,
+		best_width:     emitter.best_width,
+		unicode:        emitter.unicode,
+		display_width:  emitter.display_width,
+		line_break:     emitter.line_break,
+
+		max_simple_key_length: emitter.max_simple_key_length,
+		no_plain_breaks:       emitter.no_plain_breaks,
+		indent_sequences:      emitter.indent_sequences,
+		no_compact_mappings:   emitter.no_compact_mappings,
+		defer_flush:           emitter.defer_flush,
+		flush_size:            emitter.flush_size,
+		hold_final_break:      emitter.hold_final_break,
 	}
 }
 
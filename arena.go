@@ -0,0 +1,125 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+const (
+	arenaNodes   = 1024
+	arenaContent = 4096
+)
+
+// A NodeArena holds the Nodes that Decoders build, allocated in large
+// blocks rather than one by one, so that tools decoding many documents
+// into Nodes spend less time in the garbage collector. Release frees all
+// of them at once, after which the arena reuses their memory, and the
+// Nodes allocated before are no longer valid: they are zeroed, and will
+// be overwritten by the Nodes decoded next.
+//
+// The zero NodeArena is ready to use. A NodeArena is not safe for
+// concurrent use, and the decoders of DecodeParallel do not use it.
+type NodeArena struct {
+	// the blocks of Nodes and of the Content of Nodes, and how far the
+	// current one of each is used
+	nodes        [][]Node
+	node, used   int
+	content      [][]*Node
+	contentBlock int
+	contentUsed  int
+}
+
+// UseArena makes the Decoder allocate the Nodes it decodes, and their
+// Content, in the arena a. A nil arena allocates them one by one, which is
+// the default.
+func (d *Decoder) UseArena(a *NodeArena) {
+	d.arena = a
+}
+
+// Release frees every Node allocated in a, which makes them invalid, and
+// keeps the memory of the arena to allocate the Nodes decoded next.
+func (a *NodeArena) Release() {
+	for i := 0; i <= a.node && i < len(a.nodes); i++ {
+		b := a.nodes[i]
+		for j := range b {
+			b[j] = Node{}
+		}
+	}
+	for i := 0; i <= a.contentBlock && i < len(a.content); i++ {
+		b := a.content[i]
+		for j := range b {
+			b[j] = nil
+		}
+	}
+	a.node, a.used = 0, 0
+	a.contentBlock, a.contentUsed = 0, 0
+}
+
+// newNode returns a zero Node allocated in the arena.
+func (a *NodeArena) newNode() *Node {
+	if a.node == len(a.nodes) {
+		a.nodes = append(a.nodes, make([]Node, arenaNodes))
+	}
+	n := &a.nodes[a.node][a.used]
+	a.used++
+	if a.used == arenaNodes {
+		a.node, a.used = a.node+1, 0
+	}
+	return n
+}
+
+// newContent returns a copy of nodes allocated in the arena. Appending to
+// the copy reallocates it rather than overwriting the Nodes after it.
+func (a *NodeArena) newContent(nodes []*Node) []*Node {
+	if len(nodes) > arenaContent/4 {
+		return append([]*Node(nil), nodes...)
+	}
+	if a.contentBlock < len(a.content) && a.contentUsed+len(nodes) > arenaContent {
+		a.contentBlock, a.contentUsed = a.contentBlock+1, 0
+	}
+	if a.contentBlock == len(a.content) {
+		a.content = append(a.content, make([]*Node, arenaContent))
+	}
+	start, end := a.contentUsed, a.contentUsed+len(nodes)
+	c := a.content[a.contentBlock][start:end:end]
+	copy(c, nodes)
+	a.contentUsed = end
+	return c
+}
+
+// newNode returns a zero Node for the Decoder to fill in.
+func (d *Decoder) newNode() *Node {
+	if d.arena != nil {
+		return d.arena.newNode()
+	}
+	return &Node{}
+}
+
+// takeContent returns the Nodes pushed on the stack of the Decoder since
+// start as the Content of a Node, and pops them.
+func (d *Decoder) takeContent(start int) []*Node {
+	nodes := d.nodeStack[start:]
+	var content []*Node
+	switch {
+	case len(nodes) == 0:
+	case d.arena != nil:
+		content = d.arena.newContent(nodes)
+	default:
+		content = append([]*Node(nil), nodes...)
+	}
+
+	for i := range nodes {
+		nodes[i] = nil
+	}
+	d.nodeStack = d.nodeStack[:start]
+	return content
+}
@@ -0,0 +1,73 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Node arenas", func() {
+	var input string
+
+	BeforeEach(func() {
+		var b strings.Builder
+		for i := 0; i < 1500; i++ {
+			fmt.Fprintf(&b, "- {name: item%d, tags: [a, b], ref: &r%d x}\n", i, i)
+		}
+		b.WriteString("- *r7\n")
+		input = b.String()
+	})
+
+	decode := func(arena *NodeArena) *Node {
+		d := NewDecoder(strings.NewReader(input))
+		d.UseArena(arena)
+		var n Node
+		Expect(d.Decode(&n)).To(Succeed())
+		return &n
+	}
+
+	It("decodes the same Nodes as without an arena", func() {
+		var arena NodeArena
+		Expect(decode(&arena)).To(Equal(decode(nil)))
+	})
+
+	It("keeps the content of Nodes apart", func() {
+		var arena NodeArena
+		n := decode(&arena)
+		first := n.Content[0]
+		first.Content = append(first.Content, &Node{Kind: ScalarNode, Value: "added"}, &Node{Kind: ScalarNode})
+		Expect(n.Content[1].Content[0].Value).To(Equal("name"))
+		Expect(n.Content[1].Content[1].Value).To(Equal("item1"))
+	})
+
+	It("reuses its memory once released", func() {
+		var arena NodeArena
+		n := decode(&arena)
+		item := n.Content[3]
+		Expect(item.Content[1].Value).To(Equal("item3"))
+		blocks := len(arena.nodes)
+
+		arena.Release()
+		Expect(item.Kind).To(BeZero())
+		Expect(item.Content).To(BeNil())
+
+		Expect(decode(&arena)).To(Equal(decode(nil)))
+		Expect(arena.nodes).To(HaveLen(blocks))
+	})
+})
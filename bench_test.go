@@ -0,0 +1,135 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchmarkInput returns a document of n items of typical configuration.
+func benchmarkInput(n int) []byte {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `# service %d
+- name: service-%d
+  description: a plain scalar of several words, as descriptions are
+  ports: [80, 443, 8080]
+  enabled: true
+  script: |
+    make deps
+    make test
+`, i, i)
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkScan(b *testing.B) {
+	input := benchmarkInput(1000)
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		parser := yaml_parser_t{}
+		yaml_parser_initialize(&parser)
+		yaml_parser_set_input_string(&parser, input)
+
+		token := yaml_token_t{}
+		for token.token_type != yaml_STREAM_END_TOKEN {
+			if !yaml_parser_scan(&parser, &token) {
+				b.Fatal(parser.problem)
+			}
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	input := benchmarkInput(1000)
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		var v interface{}
+		if err := Unmarshal(input, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeStruct(b *testing.B) {
+	type service struct {
+		Name        string
+		Description string
+		Ports       []int
+		Enabled     bool
+		Script      string
+	}
+	input := benchmarkInput(1000)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v []service
+		if err := Unmarshal(input, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeZeroCopy(b *testing.B) {
+	input := benchmarkInput(1000)
+	for _, zeroCopy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("ZeroCopy=%t", zeroCopy), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				d := NewDecoder(bytes.NewReader(input))
+				d.ZeroCopy(zeroCopy)
+				var v interface{}
+				if err := d.Decode(&v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	var v interface{}
+	if err := Unmarshal(benchmarkInput(1000), &v); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAnchors(b *testing.B) {
+	var input strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "a%d: &a%d [x, y]\nb%d: *a%d\n", i, i, i, i)
+	}
+	d := NewDecoder(strings.NewReader(input.String()))
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if len(d.Anchors()) != 1000 {
+			b.Fatal("missing anchors")
+		}
+	}
+}
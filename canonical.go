@@ -0,0 +1,84 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"sort"
+)
+
+// CanonicalBytes returns the documents of doc in a canonical form, which
+// is the same for documents holding the same data whatever their
+// formatting, e.g. for hashing and signing them. Documents EqualDocuments
+// reports equal under DefaultSchema have the same canonical form.
+//
+// The canonical form is written in the canonical style of YAML, with the
+// resolved tag of every node, flow collections and double-quoted scalars.
+// Scalars are written in a normal form of their values, such as 1 for 0x1
+// and true for yes, mapping keys are sorted by their canonical forms, and
+// aliases are replaced by the values they refer to. Comments are left out.
+func CanonicalBytes(doc []byte) (out []byte, err error) {
+	defer recovery(&err)
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	yaml_emitter_set_canonical(&e.emitter, true)
+	e.LineWidth(-1)
+	c := &comparison{schema: DefaultSchema}
+	for _, n := range composeNodes(doc) {
+		if err := e.Encode(c.canonicalNode(n)); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// canonicalNode returns a copy of the tree n roots in canonical form.
+func (c *comparison) canonicalNode(n *Node) *Node {
+	switch n = target(n); n.Kind {
+	case ScalarNode:
+		tag, value := canonicalScalar(n)
+		return &Node{Kind: ScalarNode, Tag: tag, Value: value}
+	case SequenceNode:
+		seq := &Node{Kind: SequenceNode, Tag: collectionTag(n), Content: make([]*Node, len(n.Content))}
+		if seq.Tag == "" {
+			seq.Tag = yaml_SEQ_TAG
+		}
+		for i, item := range n.Content {
+			seq.Content[i] = c.canonicalNode(item)
+		}
+		return seq
+	case MappingNode:
+		m := &Node{Kind: MappingNode, Tag: collectionTag(n)}
+		if m.Tag == "" {
+			m.Tag = yaml_MAP_TAG
+		}
+		keys := c.keys(n)
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			i := keys[key]
+			m.Content = append(m.Content, c.canonicalNode(n.Content[i]), c.canonicalNode(n.Content[i+1]))
+		}
+		return m
+	}
+	return &Node{Kind: ScalarNode, Tag: yaml_NULL_TAG}
+}
@@ -0,0 +1,60 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CanonicalBytes", func() {
+	canonical := func(src string) string {
+		out, err := CanonicalBytes([]byte(src))
+		Expect(err).NotTo(HaveOccurred())
+		return string(out)
+	}
+
+	It("writes documents in the canonical style, with sorted keys", func() {
+		Expect(canonical("# settings\nname: app\nports: [0x50, 443]\n")).To(Equal(`---
+!!map {
+  ? !!str "name"
+  : !!str "app",
+  ? !!str "ports"
+  : !!seq [
+    !!int "80",
+    !!int "443",
+  ],
+}
+`))
+	})
+
+	It("is the same for documents holding the same data", func() {
+		a := canonical("b: &x [1, yes, ~, 'str', 1.50]\na:\n  k: *x\n  t: 2001-12-14 21:59:43 -5\n")
+		b := canonical("a: {k: [0o1, true, null, str, 15e-1], t: !!timestamp \"2001-12-15T02:59:43Z\"}\nb: [1, True, Null, \"str\", 1.5]\n")
+		Expect(a).To(Equal(b))
+		Expect(a).NotTo(ContainSubstring("*"))
+		Expect(canonical("a: 1\n")).NotTo(Equal(canonical("a: '1'\n")))
+	})
+
+	It("keeps the last value of a duplicate key and every document", func() {
+		Expect(canonical("a: 1\na: 2\n---\nplain\n")).To(Equal(
+			"---\n!!map {\n  ? !!str \"a\"\n  : !!int \"2\",\n}\n---\n!!str \"plain\"\n"))
+	})
+
+	It("fails on invalid documents", func() {
+		_, err := CanonicalBytes([]byte("a: [1\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,108 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command candiedyaml-gen generates reflection-free YAML codecs for the
+// structs of Go source files whose doc comments contain a line reading
+// "candiedyaml:generate".
+//
+// Usage:
+//
+//	candiedyaml-gen file.go...
+//
+// The codecs of the structs of file.go are written to file_yaml.go. It is
+// meant to be run by go generate:
+//
+//	//go:generate candiedyaml-gen $GOFILE
+//
+// With -infer, it instead writes to the standard output the declaration of
+// a struct type of the given name, and of the types it needs, inferred
+// from sample YAML files:
+//
+//	candiedyaml-gen -infer Config [-package config] sample.yml...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/candiedyaml/gen"
+)
+
+func main() {
+	infer := flag.String("infer", "", "the name of a type to infer from sample YAML files")
+	pkg := flag.String("package", "main", "the package of the inferred type")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: candiedyaml-gen file.go...")
+		fmt.Fprintln(os.Stderr, "       candiedyaml-gen -infer Name [-package pkg] sample.yml...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *infer != "" {
+		if err := inferType(*pkg, *infer, flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	failed := false
+	for _, filename := range flag.Args() {
+		if err := generate(filename); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func generate(filename string) error {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	out, err := gen.Generate(filename, src)
+	if err != nil || out == nil {
+		return err
+	}
+	return ioutil.WriteFile(strings.TrimSuffix(filename, ".go")+"_yaml.go", out, 0644)
+}
+
+func inferType(pkg, name string, filenames []string) error {
+	var samples []io.Reader
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		samples = append(samples, f)
+	}
+	out, err := gen.Infer(pkg, name, samples...)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
@@ -0,0 +1,168 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "strings"
+
+// Comments are attached to Nodes by position while they are decoded:
+//
+//   - a comment on a line of its own is a head comment of the node that
+//     follows it, or a foot comment of the block collection it closes when
+//     it is indented at least as deep as the collection's items;
+//   - a comment following a token on the same line is a line comment of
+//     the last node started before it;
+//   - comments inside a flow collection are line comments of the outermost
+//     flow collection.
+//
+// Blank lines are kept as empty lines of head and foot comments, where a
+// run of them stands for a single one. Blank lines inside flow collections,
+// before the root of a document or at the end of a collection are dropped,
+// unless a foot comment follows them.
+//
+// Where a node starts at the position of its first child, the comments go
+// to the outer node.
+
+// takeComments removes the recorded comments found before mark and returns
+// them.
+func (d *Decoder) takeComments(mark YAML_mark_t) []yaml_comment_t {
+	comments := d.parser.comments
+	i := 0
+	for i < len(comments) && comments[i].start_mark.index < mark.index {
+		i++
+	}
+	d.parser.comments = comments[i:]
+	return comments[:i]
+}
+
+// nodeComments attaches the comments found before n, which has just been
+// started.
+func (d *Decoder) nodeComments(n *Node) {
+	if !d.parser.keep_comments {
+		return
+	}
+
+	if d.nodeDepth == 0 {
+		d.lastNode = nil
+	}
+
+	for _, c := range d.takeComments(d.event.start_mark) {
+		switch {
+		case d.flowNode != nil:
+			if len(c.value) > 0 {
+				addComment(&d.flowNode.LineComment, c, " ")
+			}
+		case c.trailing && d.lastNode != nil:
+			addComment(&d.lastNode.LineComment, c, " ")
+		default:
+			addComment(&n.HeadComment, c, "\n")
+		}
+	}
+	if d.nodeDepth == 0 {
+		n.HeadComment = strings.TrimLeft(n.HeadComment, "\n")
+	}
+
+	if d.flowNode == nil {
+		d.lastNode = n
+	}
+}
+
+// enterFlow records n as the outermost flow collection, if it is one.
+func (d *Decoder) enterFlow(n *Node) {
+	if n.Flow && d.flowNode == nil && d.parser.keep_comments {
+		d.flowNode = n
+	}
+}
+
+// endComments attaches the comments found before the end of the
+// collection n.
+func (d *Decoder) endComments(n *Node) {
+	if !d.parser.keep_comments {
+		return
+	}
+
+	comments := d.takeComments(d.event.start_mark)
+	if d.flowNode != nil {
+		for _, c := range comments {
+			if len(c.value) > 0 {
+				addComment(&d.flowNode.LineComment, c, " ")
+			}
+		}
+		if d.flowNode == n {
+			d.flowNode = nil
+		}
+		return
+	}
+
+	for i, c := range comments {
+		// a blank line goes with the comment following it
+		next := c
+		for j := i + 1; len(next.value) == 0 && j < len(comments); j++ {
+			next = comments[j]
+		}
+
+		switch {
+		case c.trailing && d.lastNode != nil:
+			addComment(&d.lastNode.LineComment, c, " ")
+		case len(next.value) > 0 && next.start_mark.column >= n.Column-1:
+			addComment(&n.FootComment, c, "\n")
+		default:
+			// the rest belongs to the enclosing collections
+			rest := append([]yaml_comment_t{}, comments[i:]...)
+			d.parser.comments = append(rest, d.parser.comments...)
+			return
+		}
+	}
+}
+
+// documentComments attaches the comments found between n, the root of a
+// document, and the end of the document.
+func (d *Decoder) documentComments(n *Node) {
+	if !d.parser.keep_comments || d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		return
+	}
+
+	for _, c := range d.takeComments(d.event.start_mark) {
+		if c.trailing && d.lastNode != nil {
+			addComment(&d.lastNode.LineComment, c, " ")
+		} else {
+			addComment(&n.FootComment, c, "\n")
+		}
+	}
+	n.FootComment = strings.TrimRight(n.FootComment, "\n")
+}
+
+func addComment(comment *string, c yaml_comment_t, sep string) {
+	text := strings.TrimRight(string(c.value), " \t")
+	if *comment != "" || text == "" {
+		text = *comment + sep + text
+	}
+	*comment = text
+}
+
+// emitComments emits the current event of n with the comments of n that
+// belong to it: the head comment on the first event of n, the foot comment
+// on the last one and, when line is set, the line comment.
+func (e *Encoder) emitComments(n *Node, first, last, line bool) {
+	if first {
+		e.event.head_comment = []byte(n.HeadComment)
+	}
+	if last {
+		e.event.foot_comment = []byte(n.FootComment)
+	}
+	if line {
+		e.event.line_comment = []byte(n.LineComment)
+	}
+	e.emit()
+}
@@ -0,0 +1,386 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A ComposedDocument is a document held as a table of nodes, as by the
+// document API of libyaml. Nodes refer to each other by id, the position
+// of a node in Nodes plus one, and an alias is composed as a second
+// reference to the node of its anchor, so that a node can be shared and
+// structures can be cyclic. The root is the node with id 1.
+type ComposedDocument struct {
+	DocumentInfo
+
+	Nodes []ComposedNode
+
+	// Start and End are the positions of the start and end of the
+	// document.
+	Start, End Position
+}
+
+// A ComposedNode is a node of a ComposedDocument.
+type ComposedNode struct {
+	// Kind is ScalarNode, SequenceNode or MappingNode.
+	Kind NodeKind
+
+	// Tag is the tag of the node in full. A scalar without an explicit
+	// tag has the tag it resolves to, and a collection has the tag of a
+	// sequence or a mapping.
+	Tag string
+
+	// Anchor is the anchor defined on the node in the source, if any.
+	Anchor string
+
+	// Value and Style are the content and style of a scalar.
+	Value string
+	Style ScalarStyle
+
+	// Flow is true when a sequence or mapping uses the flow style.
+	Flow bool
+
+	// Items are the ids of the items of a sequence, and Pairs those of
+	// the keys and values of a mapping.
+	Items []int
+	Pairs []NodePair
+
+	Start, End Position
+}
+
+// A NodePair is the ids of a key and its value in a mapping.
+type NodePair struct {
+	Key, Value int
+}
+
+// Node returns the node with the given id, or nil if there is none.
+func (doc *ComposedDocument) Node(id int) *ComposedNode {
+	if id < 1 || id > len(doc.Nodes) {
+		return nil
+	}
+	return &doc.Nodes[id-1]
+}
+
+// Root returns the root node, or nil if the document has no nodes.
+func (doc *ComposedDocument) Root() *ComposedNode {
+	return doc.Node(1)
+}
+
+// AddScalar adds a scalar node and returns its id. An empty tag stands for
+// the tag the value resolves to.
+func (doc *ComposedDocument) AddScalar(tag, value string, style ScalarStyle) int {
+	if tag == "" {
+		tag = DetectScalarType(value)
+	}
+	return doc.add(ComposedNode{Kind: ScalarNode, Tag: longTag(tag), Value: value, Style: style})
+}
+
+// AddSequence adds an empty sequence node and returns its id. An empty tag
+// stands for the tag of a sequence.
+func (doc *ComposedDocument) AddSequence(tag string, flow bool) int {
+	if tag == "" {
+		tag = yaml_SEQ_TAG
+	}
+	return doc.add(ComposedNode{Kind: SequenceNode, Tag: longTag(tag), Flow: flow})
+}
+
+// AddMapping adds an empty mapping node and returns its id. An empty tag
+// stands for the tag of a mapping.
+func (doc *ComposedDocument) AddMapping(tag string, flow bool) int {
+	if tag == "" {
+		tag = yaml_MAP_TAG
+	}
+	return doc.add(ComposedNode{Kind: MappingNode, Tag: longTag(tag), Flow: flow})
+}
+
+func (doc *ComposedDocument) add(n ComposedNode) int {
+	doc.Nodes = append(doc.Nodes, n)
+	return len(doc.Nodes)
+}
+
+// AppendSequenceItem appends the node item to the sequence seq. The ids
+// are checked when the document is serialized.
+func (doc *ComposedDocument) AppendSequenceItem(seq, item int) {
+	if n := doc.Node(seq); n != nil {
+		n.Items = append(n.Items, item)
+	}
+}
+
+// AppendMappingPair appends the pair of nodes key and value to the mapping
+// m. The ids are checked when the document is serialized.
+func (doc *ComposedDocument) AppendMappingPair(m, key, value int) {
+	if n := doc.Node(m); n != nil {
+		n.Pairs = append(n.Pairs, NodePair{key, value})
+	}
+}
+
+// ComposeDocument reads the next document of the stream as a
+// ComposedDocument. At the end of the stream it returns a document without
+// nodes, as libyaml does.
+func (d *Decoder) ComposeDocument() (doc *ComposedDocument, err error) {
+	defer recovery(&err)
+
+	d.start()
+	doc = &ComposedDocument{}
+	if d.event.event_type == yaml_STREAM_END_EVENT {
+		return doc, nil
+	}
+	if d.event.event_type != yaml_DOCUMENT_START_EVENT {
+		return nil, composingError(d.event.start_mark, "Expected document start")
+	}
+	doc.Start = markPosition(d.event.start_mark)
+	d.nextEvent()
+
+	c := composer{d: d, doc: doc, anchors: make(map[string]int)}
+	c.node()
+
+	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		return nil, composingError(d.event.start_mark, "Expected document end")
+	}
+	doc.End = markPosition(d.event.end_mark)
+	doc.DocumentInfo = d.documentInfo
+	d.nextEvent()
+	return doc, nil
+}
+
+// a composer builds the nodes of a document from the events of a Decoder
+type composer struct {
+	d       *Decoder
+	doc     *ComposedDocument
+	anchors map[string]int
+}
+
+// node composes the current value and returns its id.
+func (c *composer) node() int {
+	event := &c.d.event
+	if event.event_type == yaml_ALIAS_EVENT {
+		id, ok := c.anchors[string(event.anchor)]
+		if !ok {
+			c.d.error(composingError(event.start_mark, "missing anchor: '%s'", event.anchor))
+		}
+		c.d.nextEvent()
+		return id
+	}
+
+	n := ComposedNode{
+		Tag:    string(event.tag),
+		Anchor: string(event.anchor),
+		Start:  markPosition(event.start_mark),
+		End:    markPosition(event.end_mark),
+	}
+	switch event.event_type {
+	case yaml_SCALAR_EVENT:
+		n.Kind = ScalarNode
+		n.Value = string(event.value)
+		n.Style = ScalarStyle(event.style)
+		if n.Tag == "" || n.Tag == "!" {
+			n.Tag = yaml_STR_TAG
+			if event.implicit {
+				n.Tag = DetectScalarType(n.Value)
+			}
+		}
+	case yaml_SEQUENCE_START_EVENT:
+		n.Kind = SequenceNode
+		n.Flow = yaml_sequence_style_t(event.style) == yaml_FLOW_SEQUENCE_STYLE
+		if n.Tag == "" || n.Tag == "!" {
+			n.Tag = yaml_SEQ_TAG
+		}
+	case yaml_MAPPING_START_EVENT:
+		n.Kind = MappingNode
+		n.Flow = yaml_mapping_style_t(event.style) == yaml_FLOW_MAPPING_STYLE
+		if n.Tag == "" || n.Tag == "!" {
+			n.Tag = yaml_MAP_TAG
+		}
+	default:
+		c.d.error(&UnexpectedEventError{
+			Value:     string(event.value),
+			EventType: EventKind(event.event_type),
+			At:        markOf(event.start_mark),
+		})
+	}
+
+	// the anchor is registered before the content, which may alias it
+	id := c.doc.add(n)
+	if n.Anchor != "" {
+		c.anchors[n.Anchor] = id
+	}
+	c.d.nextEvent()
+
+	switch n.Kind {
+	case SequenceNode:
+		for c.d.event.event_type != yaml_SEQUENCE_END_EVENT {
+			item := c.node()
+			c.doc.AppendSequenceItem(id, item)
+		}
+	case MappingNode:
+		for c.d.event.event_type != yaml_MAPPING_END_EVENT {
+			c.d.checkKey()
+			key := c.node()
+			value := c.node()
+			c.doc.AppendMappingPair(id, key, value)
+		}
+	default:
+		return id
+	}
+	c.doc.Nodes[id-1].End = markPosition(c.d.event.end_mark)
+	c.d.nextEvent()
+	return id
+}
+
+func markPosition(m YAML_mark_t) Position {
+	return Position{m.line + 1, m.column + 1}
+}
+
+// SerializeDocument writes doc to the stream as a new document, with its
+// directives and markers. A node referred to more than once is written in
+// full the first time, with an anchor named after its Anchor or generated,
+// and as an alias everywhere else.
+func (e *Encoder) SerializeDocument(doc *ComposedDocument) (err error) {
+	if e.err != nil {
+		return e.err
+	}
+	defer func() { e.err = err }()
+	defer recovery(&err)
+
+	if len(doc.Nodes) == 0 {
+		return errors.New("The document has no nodes")
+	}
+	s := serializer{e: e, doc: doc, refs: make([]int, len(doc.Nodes)), anchors: make([]string, len(doc.Nodes))}
+	s.count(1)
+
+	version, tags := documentDirectives(doc.DocumentInfo)
+	if !e.started {
+		e.start()
+	}
+	yaml_document_start_event_initialize(&e.event, version, tags, doc.ImplicitStart)
+	e.emit()
+
+	e.anchorNames = nil
+	s.node(1)
+
+	yaml_document_end_event_initialize(&e.event, doc.ImplicitEnd)
+	e.emit()
+	return nil
+}
+
+// documentDirectives returns the directives of a document start event.
+func documentDirectives(info DocumentInfo) (*yaml_version_directive_t, []yaml_tag_directive_t) {
+	var version *yaml_version_directive_t
+	if info.Version != "" {
+		parts := strings.SplitN(info.Version, ".", 2)
+		v := yaml_version_directive_t{}
+		var err error
+		if v.major, err = strconv.Atoi(parts[0]); err == nil && len(parts) == 2 {
+			v.minor, err = strconv.Atoi(parts[1])
+		}
+		if err != nil || len(parts) != 2 {
+			panic(fmt.Errorf("Invalid version directive '%s'", info.Version))
+		}
+		version = &v
+	}
+
+	var tags []yaml_tag_directive_t
+	for _, t := range info.TagDirectives {
+		tags = append(tags, yaml_tag_directive_t{handle: []byte(t.Handle), prefix: []byte(t.Prefix)})
+	}
+	return version, tags
+}
+
+// a serializer writes the nodes of a document, counting the references to
+// each node first to know which ones need an anchor
+type serializer struct {
+	e       *Encoder
+	doc     *ComposedDocument
+	refs    []int
+	anchors []string
+}
+
+// count counts a reference to the node id, and the references made by
+// its content the first time.
+func (s *serializer) count(id int) {
+	n := s.doc.Node(id)
+	if n == nil {
+		panic(fmt.Errorf("Invalid node id %d", id))
+	}
+	s.refs[id-1]++
+	if s.refs[id-1] > 1 {
+		return
+	}
+	for _, item := range n.Items {
+		s.count(item)
+	}
+	for _, p := range n.Pairs {
+		s.count(p.Key)
+		s.count(p.Value)
+	}
+}
+
+// node writes the node id, or an alias to it when it was already written.
+func (s *serializer) node(id int) {
+	e, n := s.e, s.doc.Node(id)
+	if s.anchors[id-1] != "" {
+		yaml_alias_event_initialize(&e.event, []byte(s.anchors[id-1]))
+		e.emit()
+		return
+	}
+
+	var anchor []byte
+	if s.refs[id-1] > 1 {
+		s.anchors[id-1] = e.anchorName(n.Anchor)
+		anchor = []byte(s.anchors[id-1])
+	}
+
+	switch n.Kind {
+	case ScalarNode:
+		plain := n.Tag == DetectScalarType(n.Value)
+		if tag, ok := e.implicitTag(n.Value); ok {
+			plain = n.Tag == tag
+		}
+		yaml_scalar_event_initialize(&e.event, anchor, []byte(n.Tag), []byte(n.Value),
+			plain, n.Tag == yaml_STR_TAG, yaml_scalar_style_t(n.Style))
+		e.emit()
+	case SequenceNode:
+		style := yaml_BLOCK_SEQUENCE_STYLE
+		if n.Flow {
+			style = yaml_FLOW_SEQUENCE_STYLE
+		}
+		yaml_sequence_start_event_initialize(&e.event, anchor, []byte(n.Tag), n.Tag == yaml_SEQ_TAG, style)
+		e.emit()
+		for _, item := range n.Items {
+			s.node(item)
+		}
+		yaml_sequence_end_event_initialize(&e.event)
+		e.emit()
+	case MappingNode:
+		style := yaml_BLOCK_MAPPING_STYLE
+		if n.Flow {
+			style = yaml_FLOW_MAPPING_STYLE
+		}
+		yaml_mapping_start_event_initialize(&e.event, anchor, []byte(n.Tag), n.Tag == yaml_MAP_TAG, style)
+		e.emit()
+		for _, p := range n.Pairs {
+			s.node(p.Key)
+			s.node(p.Value)
+		}
+		yaml_mapping_end_event_initialize(&e.event)
+		e.emit()
+	default:
+		panic(fmt.Errorf("Invalid kind of node %d", id))
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ComposedDocument", func() {
+	It("composes aliases as references to the node of their anchor", func() {
+		d := NewDecoder(strings.NewReader("base: &b {x: 1}\ncopy: *b\nname: 'x'\n"))
+		doc, err := d.ComposeDocument()
+		Expect(err).NotTo(HaveOccurred())
+
+		root := doc.Root()
+		Expect(root.Kind).To(Equal(MappingNode))
+		Expect(root.Tag).To(Equal("tag:yaml.org,2002:map"))
+		Expect(root.Pairs).To(HaveLen(3))
+		Expect(root.Pairs[0].Value).To(Equal(root.Pairs[1].Value))
+
+		base := doc.Node(root.Pairs[0].Value)
+		Expect(base.Anchor).To(Equal("b"))
+		Expect(base.Flow).To(BeTrue())
+		Expect(base.Start).To(Equal(Position{1, 7}))
+		Expect(base.End).To(Equal(Position{1, 16}))
+		Expect(doc.Node(base.Pairs[0].Value).Tag).To(Equal(IntTag))
+		Expect(doc.Node(root.Pairs[2].Value).Tag).To(Equal(StrTag))
+		Expect(doc.Node(0)).To(BeNil())
+	})
+
+	It("returns a document without nodes at the end of the stream", func() {
+		d := NewDecoder(strings.NewReader("%YAML 1.1\n--- a\n"))
+		doc, err := d.ComposeDocument()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(doc.Version).To(Equal("1.1"))
+		Expect(doc.Root().Value).To(Equal("a"))
+
+		doc, err = d.ComposeDocument()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(doc.Root()).To(BeNil())
+	})
+
+	It("fails on undefined aliases", func() {
+		d := NewDecoder(strings.NewReader("- *a\n"))
+		_, err := d.ComposeDocument()
+		Expect(err).To(MatchError(ContainSubstring("missing anchor: 'a'")))
+	})
+
+	It("serializes shared nodes with anchors", func() {
+		doc := &ComposedDocument{DocumentInfo: DocumentInfo{ImplicitStart: true, ImplicitEnd: true}}
+		root := doc.AddMapping("", false)
+		shared := doc.AddSequence("", true)
+		doc.AppendSequenceItem(shared, doc.AddScalar("", "1", PlainStyle))
+		doc.AppendSequenceItem(shared, doc.AddScalar("!!str", "2", PlainStyle))
+		doc.AppendMappingPair(root, doc.AddScalar("", "a", PlainStyle), shared)
+		doc.AppendMappingPair(root, doc.AddScalar("", "b", PlainStyle), shared)
+
+		var buf bytes.Buffer
+		Expect(NewEncoder(&buf).SerializeDocument(doc)).To(Succeed())
+		Expect(buf.String()).To(Equal("a: &id001 [1, '2']\nb: *id001\n"))
+	})
+
+	It("serializes cyclic documents", func() {
+		doc := &ComposedDocument{}
+		seq := doc.AddSequence("", false)
+		doc.AppendSequenceItem(seq, seq)
+
+		var buf bytes.Buffer
+		Expect(NewEncoder(&buf).SerializeDocument(doc)).To(Succeed())
+		Expect(buf.String()).To(Equal("--- &id001\n- *id001\n...\n"))
+	})
+
+	It("writes back what it composed", func() {
+		src := "%YAML 1.1\n---\nbase: &b\n  x: 1\ncopy: *b\nlist: ['q', \"2\", ~]\n...\n"
+		doc, err := NewDecoder(strings.NewReader(src)).ComposeDocument()
+		Expect(err).NotTo(HaveOccurred())
+
+		var buf bytes.Buffer
+		Expect(NewEncoder(&buf).SerializeDocument(doc)).To(Succeed())
+		Expect(buf.String()).To(Equal(src))
+	})
+
+	It("rejects invalid node ids", func() {
+		doc := &ComposedDocument{}
+		doc.AppendSequenceItem(doc.AddSequence("", false), 5)
+		Expect(NewEncoder(&bytes.Buffer{}).SerializeDocument(doc)).To(MatchError("Invalid node id 5"))
+		Expect(NewEncoder(&bytes.Buffer{}).SerializeDocument(&ComposedDocument{})).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,138 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Concat joins YAML streams into one, holding the documents of all of
+// them in order. It inserts a "---" before a document that would otherwise
+// continue the one before it, and a "..." before directives, which can
+// only follow an ended document; other "..." markers ending the streams
+// are removed. The text of the streams, comments included, is otherwise
+// kept. Concat fails when a stream is not valid YAML.
+func Concat(streams ...[]byte) ([]byte, error) {
+	var out bytes.Buffer
+	documents := false
+	for i, src := range streams {
+		src = bytes.TrimPrefix(src, []byte("\xEF\xBB\xBF"))
+
+		info, err := scanStream(src)
+		if err != nil {
+			return nil, fmt.Errorf("stream %d: %s", i, err)
+		}
+
+		if info.explicitEnd {
+			src = trimDocumentEnd(src)
+		}
+		if documents && info.documents {
+			if info.directives {
+				out.WriteString("...\n")
+			} else if !info.explicitStart {
+				out.WriteString("---\n")
+			}
+		}
+		out.Write(src)
+		if len(src) > 0 && src[len(src)-1] != '\n' && src[len(src)-1] != '\r' {
+			out.WriteByte('\n')
+		}
+		documents = documents || info.documents
+	}
+	return out.Bytes(), nil
+}
+
+// ConcatReaders is Concat for streams read from readers, writing the
+// stream joined to w.
+func ConcatReaders(w io.Writer, readers ...io.Reader) error {
+	streams := make([][]byte, len(readers))
+	for i, r := range readers {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		streams[i] = b
+	}
+	b, err := Concat(streams...)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// A streamShape is what Concat needs to know of a stream.
+type streamShape struct {
+	documents     bool // it holds documents
+	directives    bool // the first document has directives
+	explicitStart bool // the first document starts with "---"
+	explicitEnd   bool // the last document ends with "..."
+}
+
+func scanStream(src []byte) (streamShape, error) {
+	var parser yaml_parser_t
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_string(&parser, src)
+
+	var shape streamShape
+	var event yaml_event_t
+	for {
+		if !yaml_parser_parse(&parser, &event) {
+			return shape, newParserError(&parser)
+		}
+		switch event.event_type {
+		case yaml_DOCUMENT_START_EVENT:
+			if !shape.documents {
+				shape.documents = true
+				shape.directives = event.version_directive != nil || len(event.tag_directives) > 0
+				shape.explicitStart = !event.implicit
+			}
+		case yaml_DOCUMENT_END_EVENT:
+			shape.explicitEnd = !event.implicit
+		case yaml_STREAM_END_EVENT:
+			return shape, nil
+		}
+	}
+}
+
+// trimDocumentEnd removes the "..." line ending the last document of src,
+// which is only followed by blank lines and comments.
+func trimDocumentEnd(src []byte) []byte {
+	end := len(src)
+	for {
+		start := bytes.LastIndexAny(src[:end], "\r\n") + 1
+		line := bytes.TrimSpace(src[start:end])
+		if bytes.HasPrefix(line, []byte("...")) {
+			rest := bytes.TrimSpace(line[3:])
+			if len(rest) > 0 && rest[0] != '#' {
+				return src
+			}
+			after := src[end:]
+			if bytes.HasPrefix(after, []byte("\r\n")) {
+				after = after[2:]
+			} else if len(after) > 0 {
+				after = after[1:]
+			}
+			return append(src[:start:start], after...)
+		}
+		if len(line) > 0 && line[0] != '#' || start == 0 {
+			return src
+		}
+		end = start - 1
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Concat", func() {
+	concat := func(streams ...string) string {
+		bs := make([][]byte, len(streams))
+		for i, s := range streams {
+			bs[i] = []byte(s)
+		}
+		out, err := Concat(bs...)
+		Expect(err).NotTo(HaveOccurred())
+		return string(out)
+	}
+
+	It("separates the documents", func() {
+		Expect(concat("a: 1", "# b\nb: 2\n", "--- c\n", "")).To(Equal("a: 1\n---\n# b\nb: 2\n--- c\n"))
+	})
+
+	It("removes the document end markers", func() {
+		Expect(concat("a: 1\n...\n# end\n", "b: 2\n... # done\n")).To(Equal("a: 1\n# end\n---\nb: 2\n"))
+	})
+
+	It("ends the documents before directives", func() {
+		Expect(concat("a: 1\n", "%YAML 1.1\n---\nb: 2\n")).To(Equal("a: 1\n...\n%YAML 1.1\n---\nb: 2\n"))
+	})
+
+	It("keeps streams without documents", func() {
+		out := concat("# header\n", "a: 1\n", "# only a comment\n", "b: 2\n")
+		Expect(out).To(Equal("# header\na: 1\n# only a comment\n---\nb: 2\n"))
+
+		events, err := ParseAllEvents([]byte(out))
+		Expect(err).NotTo(HaveOccurred())
+		documents := 0
+		for _, ev := range events {
+			if ev.Kind == DocumentStartEvent {
+				documents++
+			}
+		}
+		Expect(documents).To(Equal(2))
+	})
+
+	It("fails on invalid streams", func() {
+		_, err := Concat([]byte("a: 1\n"), []byte("b: [\n"))
+		Expect(err).To(MatchError(HavePrefix("stream 1: ")))
+	})
+
+	It("reads the streams from readers", func() {
+		var buf bytes.Buffer
+		Expect(ConcatReaders(&buf, strings.NewReader("a: 1\n"), strings.NewReader("b: 2\n"))).To(Succeed())
+		Expect(buf.String()).To(Equal("a: 1\n---\nb: 2\n"))
+	})
+})
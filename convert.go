@@ -0,0 +1,91 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "io/ioutil"
+
+// Decode stores the value n holds in the value v points to, as Unmarshal
+// would store that of its text, without writing the text. Errors are
+// located at the positions of the nodes. A nil Node leaves v unchanged.
+func (n *Node) Decode(v interface{}) error {
+	if n == nil {
+		return nil
+	}
+	rec := &Recording{events: nodeStream(n)}
+	return rec.Replay().Decode(v)
+}
+
+// NodeFrom returns the Node the encoder would write for v, as
+// Unmarshal would decode it from the text of Marshal, without writing the
+// text, so that it can be changed or spliced into another tree. The Nodes
+// of v are copied, and their comments left out.
+func NodeFrom(v interface{}) (n *Node, err error) {
+	defer recovery(&err)
+
+	e := NewEncoder(ioutil.Discard)
+	e.recording = true
+	e.root(v)
+
+	events := []yaml_event_t{
+		{event_type: yaml_STREAM_START_EVENT, encoding: yaml_UTF8_ENCODING},
+		{event_type: yaml_DOCUMENT_START_EVENT, implicit: true},
+	}
+	for _, event := range e.events {
+		if event.event_type == yaml_SCALAR_EVENT && yaml_scalar_style_t(event.style) == yaml_RAW_SCALAR_STYLE {
+			events = append(events, rawEvents(event)...)
+			continue
+		}
+		if len(event.tag) > 0 {
+			event.tag = []byte(longTag(string(event.tag)))
+		}
+		events = append(events, event)
+	}
+	events = append(events,
+		yaml_event_t{event_type: yaml_DOCUMENT_END_EVENT, implicit: true},
+		yaml_event_t{event_type: yaml_STREAM_END_EVENT})
+
+	n = &Node{}
+	if err := (&Recording{events: events}).Replay().Decode(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// rawEvents returns the events of the text of a raw scalar, such as that
+// of a RawYAML value, with the anchor of the scalar on its root.
+func rawEvents(raw yaml_event_t) []yaml_event_t {
+	var parser yaml_parser_t
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_string(&parser, raw.value)
+
+	var events []yaml_event_t
+	for {
+		var event yaml_event_t
+		if !yaml_parser_parse(&parser, &event) {
+			panic(newParserError(&parser))
+		}
+		switch event.event_type {
+		case yaml_STREAM_START_EVENT, yaml_DOCUMENT_START_EVENT, yaml_DOCUMENT_END_EVENT:
+			continue
+		case yaml_STREAM_END_EVENT:
+			return events
+		}
+		if len(events) == 0 && len(raw.anchor) > 0 {
+			event.anchor = raw.anchor
+		}
+		event.start_mark, event.end_mark = YAML_mark_t{}, YAML_mark_t{}
+		events = append(events, event)
+	}
+}
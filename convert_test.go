@@ -0,0 +1,120 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Node conversion", func() {
+	type server struct {
+		Host string
+		Port int
+		Tags []string `yaml:",omitempty"`
+	}
+
+	It("decodes a Node into a value", func() {
+		root := &Node{}
+		Expect(Unmarshal([]byte("servers:\n- host: a\n  port: 80\n- {host: b, port: 81}\n"), root)).To(Succeed())
+
+		var s server
+		Expect(root.Content[1].Content[1].Decode(&s)).To(Succeed())
+		Expect(s).To(Equal(server{Host: "b", Port: 81}))
+
+		var v interface{}
+		Expect(root.Decode(&v)).To(Succeed())
+		Expect(v).To(HaveKey("servers"))
+	})
+
+	It("locates the errors of decoding a Node", func() {
+		root := &Node{}
+		Expect(Unmarshal([]byte("host: a\nport: eighty\n"), root)).To(Succeed())
+
+		var s server
+		err := root.Decode(&s)
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(Equal(Unmarshal([]byte("host: a\nport: eighty\n"), &s)))
+	})
+
+	It("follows aliases", func() {
+		root := &Node{}
+		Expect(Unmarshal([]byte("base: &b {host: a, port: 1}\nnext: *b\n"), root)).To(Succeed())
+
+		var s server
+		Expect(root.Content[3].Decode(&s)).To(Succeed())
+		Expect(s).To(Equal(server{Host: "a", Port: 1}))
+	})
+
+	It("builds the Node of a value", func() {
+		n, err := NodeFrom(server{Host: "a", Port: 80, Tags: []string{"x", "true"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		src, err := Marshal(server{Host: "a", Port: 80, Tags: []string{"x", "true"}})
+		Expect(err).NotTo(HaveOccurred())
+		decoded := &Node{}
+		Expect(Unmarshal(src, decoded)).To(Succeed())
+
+		Expect(Marshal(n)).To(Equal(src))
+		Expect(n.Kind).To(Equal(MappingNode))
+		Expect(n.Content[0].Value).To(Equal("Host"))
+		Expect(n.Content[5].Content[1].Value).To(Equal(decoded.Content[5].Content[1].Value))
+		Expect(n.Content[5].Content[1].Style).To(Equal(decoded.Content[5].Content[1].Style))
+	})
+
+	It("writes tags in full", func() {
+		n, err := NodeFrom(map[string]interface{}{"at": time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "data": []byte{0xff}})
+		Expect(err).NotTo(HaveOccurred())
+
+		decoded := &Node{}
+		src, err := Marshal(map[string]interface{}{"at": time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "data": []byte{0xff}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(Unmarshal(src, decoded)).To(Succeed())
+		Expect(n.Content[3].Tag).To(Equal(decoded.Content[3].Tag))
+		Expect(n.Content[3].Tag).To(Equal("tag:yaml.org,2002:binary"))
+	})
+
+	It("splices raw text and Nodes", func() {
+		child := &Node{Kind: ScalarNode, Value: "kept", Style: SingleQuotedStyle}
+		n, err := NodeFrom(map[string]interface{}{
+			"raw":  RawYAML("[1, {a: b}]"),
+			"node": child,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Content[1]).NotTo(BeIdenticalTo(child))
+		Expect(n.Content[1].Value).To(Equal("kept"))
+		Expect(n.Content[1].Style).To(Equal(SingleQuotedStyle))
+		Expect(n.Content[3].Kind).To(Equal(SequenceNode))
+		Expect(n.Content[3].Flow).To(BeTrue())
+		Expect(n.Content[3].Content[1].Content[1].Value).To(Equal("b"))
+	})
+
+	It("round-trips a value through a Node", func() {
+		in := []server{{Host: "a", Port: 1}, {Host: "b", Port: 2, Tags: []string{"c"}}}
+		n, err := NodeFrom(in)
+		Expect(err).NotTo(HaveOccurred())
+
+		var out []server
+		Expect(n.Decode(&out)).To(Succeed())
+		Expect(out).To(Equal(in))
+	})
+
+	It("returns the errors of encoding", func() {
+		_, err := NodeFrom(map[string]interface{}{"f": func() {}})
+		Expect(err).To(HaveOccurred())
+	})
+})
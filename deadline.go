@@ -0,0 +1,40 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrDeadlineExceeded is returned by a Decoder whose deadline has passed.
+var ErrDeadlineExceeded = errors.New("The decoding deadline was exceeded")
+
+// SetDeadline sets the time after which the Decoder fails with
+// ErrDeadlineExceeded, so that a parse of untrusted input can be bounded
+// without a context. The deadline is checked between events: an event
+// being read when it passes, such as a long scalar, is read in full
+// first. It applies to all the documents decoded until it is changed,
+// and a zero time, the default, means no deadline.
+func (d *Decoder) SetDeadline(t time.Time) {
+	d.deadline = t
+}
+
+// checkDeadline fails if the deadline of the Decoder has passed.
+func (d *Decoder) checkDeadline() {
+	if time.Now().After(d.deadline) {
+		panic(ErrDeadlineExceeded)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// slowReader returns its input a line at a time, sleeping before each.
+type slowReader struct {
+	lines []string
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.lines) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	n := copy(p, r.lines[0])
+	r.lines[0] = r.lines[0][n:]
+	if r.lines[0] == "" {
+		r.lines = r.lines[1:]
+	}
+	return n, nil
+}
+
+var _ = Describe("Deadlines", func() {
+	It("stops decoding once the deadline has passed", func() {
+		r := &slowReader{lines: strings.SplitAfter(strings.Repeat("- a\n", 100), "\n"), delay: 5 * time.Millisecond}
+		d := NewDecoder(r)
+		d.SetDeadline(time.Now().Add(50 * time.Millisecond))
+
+		var v interface{}
+		Expect(d.Decode(&v)).To(Equal(ErrDeadlineExceeded))
+		Expect(len(r.lines)).To(BeNumerically(">", 50))
+	})
+
+	It("fails at once when the deadline has already passed", func() {
+		d := NewDecoder(strings.NewReader("a: 1\n"))
+		d.SetDeadline(time.Now().Add(-time.Second))
+
+		var v interface{}
+		Expect(d.Decode(&v)).To(Equal(ErrDeadlineExceeded))
+	})
+
+	It("decodes in time", func() {
+		d := NewDecoder(strings.NewReader("a: [1, 2]\n"))
+		d.SetDeadline(time.Now().Add(time.Minute))
+
+		var v map[string][]int
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(v).To(Equal(map[string][]int{"a": {1, 2}}))
+	})
+
+	It("can be lifted", func() {
+		d := NewDecoder(strings.NewReader("a: 1\n"))
+		d.SetDeadline(time.Now().Add(-time.Second))
+		d.SetDeadline(time.Time{})
+
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+	})
+})
@@ -23,6 +23,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Unmarshaler interface {
@@ -45,42 +46,321 @@ func (n Number) Int64() (int64, error) {
 	return strconv.ParseInt(string(n), 10, 64)
 }
 
-type Decoder struct {
-	parser        yaml_parser_t
-	event         yaml_event_t
-	replay_events []yaml_event_t
-	useNumber     bool
-	mapType       reflect.Type
+// An IntString is an integer literal that keeps the text it was written
+// with, so that hexadecimal (0xFF), octal (0o755) and binary (0b101) values
+// are encoded back in their original base.
+type IntString string
+
+// FormatIntString returns the literal for i in the given base (2, 8, 10 or
+// 16). Other bases are written in decimal.
+func FormatIntString(i int64, base int) IntString {
+	sign := ""
+	u := uint64(i)
+	if i < 0 {
+		sign = "-"
+		u = uint64(-i)
+	}
+
+	prefix := ""
+	switch base {
+	case 2:
+		prefix = "0b"
+	case 8:
+		prefix = "0o"
+	case 16:
+		prefix = "0x"
+	default:
+		base = 10
+	}
+
+	return IntString(sign + prefix + strconv.FormatUint(u, base))
+}
+
+// String returns the literal text of the integer.
+func (i IntString) String() string { return string(i) }
+
+// Base returns the base the integer is written in.
+func (i IntString) Base() int {
+	s := strings.TrimLeft(string(i), "+-")
+	switch {
+	case strings.HasPrefix(s, "0x"):
+		return 16
+	case strings.HasPrefix(s, "0b"):
+		return 2
+	case strings.HasPrefix(s, "0o"), len(s) > 1 && s[0] == '0':
+		return 8
+	}
+	return 10
+}
+
+// Int64 returns the integer as an int64.
+func (i IntString) Int64() (int64, error) {
+	var n int64
+	_, err := resolve_int(string(i), reflect.ValueOf(&n).Elem(), false, yaml_event_t{})
+	return n, err
+}
+
+// Uint64 returns the integer as a uint64.
+func (i IntString) Uint64() (uint64, error) {
+	var n uint64
+	_, err := resolve_uint(string(i), reflect.ValueOf(&n).Elem(), false, yaml_event_t{})
+	return n, err
+}
+
+// decoderOptions are the options of a Decoder, which Reset keeps and the
+// decoders of DecodeParallel copy.
+type decoderOptions struct {
+	useNumber bool
+	bigInts   BigIntPolicy
+	mapType   reflect.Type
 	// `strictMode` determines how the decoder should act when a field is encountered
 	// which cannot be mapped to a field on the struct being decode into.
 	// When `strictMode` is true, then the decoder errors when such a field is encountered.
 	// When false, the decoder ignores the field.
 	strictMode bool
+	stringKeys bool
+
+	// the levels of collections built in interface{} values
+	depthLimit int
+
+	emptyPolicy EmptyPolicy
+	nullPolicy  NullPolicy
+
+	timestampStrings bool
+
+	pointerNulls   PointerNullPolicy
+	singleElements SingleElementPolicy
+	documentStarts DocumentStartPolicy
+
+	// whether document markers may end block scalars
+	strictMarkers bool
+
+	unknownTags UnknownTagPolicy
+	tagPolicy   *tagPolicy
+
+	recordPositions bool
+	tolerant        bool
+	onWarning       func(Warning)
+
+	maxValueBytes     int
+	maxAliasExpansion int
+	deadline          time.Time
+
+	normalizeText bool
+	zeroCopy      bool
+
+	onProgress    func(Mark)
+	progressEvery int
+
+	implicitRules []ImplicitRule
+	noSeparators  bool
+	nulls         map[string]bool
+	failsafe      bool
+	schema        Schema
+	weaklyTyped   bool
+}
+
+type Decoder struct {
+	decoderOptions
+
+	parser        yaml_parser_t
+	event         yaml_event_t
+	replay_events []yaml_event_t
+	recorded      []yaml_event_t
+
+	// the level of the collection being decoded
+	depth int
+
+	// the start and the last line of the last scalar read when it is a
+	// block scalar
+	blockScalar     bool
+	blockScalarMark YAML_mark_t
+	blockScalarEnd  int
 
 	anchors          map[string][]yaml_event_t
 	tracking_anchors [][]yaml_event_t
+	nodeAnchors      map[string]*Node
+
+	// the anchors defined and the positions of the aliases used in the
+	// last document read, and in the document being read
+	documentAnchors, nextAnchors []string
+	aliases, nextAliases         map[string][]Position
+
+	// the arena Nodes are allocated in, and the Nodes decoded for the
+	// Content of the collections being decoded
+	arena     *NodeArena
+	nodeStack []*Node
+
+	// the state of attaching comments to the Nodes being decoded
+	nodeDepth int
+	lastNode  *Node
+	flowNode  *Node
+
+	tagChecked bool
+
+	// the positions of the values of the last document, by path
+	positions map[string]Position
+	path      []decodeStep
+
+	// the syntax errors skipped when tolerating them, and the lines
+	// skipped that are still to be added to the Nodes being decoded
+	diagnostics []Diagnostic
+	skipped     []skippedLine
+
+	valueBytes int
+
+	// the events parsed in the document being read, and those its aliases
+	// expanded to, for MaxAliasExpansion
+	parsedEvents, expandedEvents int
+
+	nextProgress int
+
+	// the directives and markers of the last document read, and of the
+	// document being read
+	documentInfo, nextDocumentInfo DocumentInfo
 }
 
 type ParserError struct {
 	ErrorType   YAML_error_type_t
 	Context     string
-	ContextMark YAML_mark_t
+	ContextMark Mark
 	Problem     string
-	ProblemMark YAML_mark_t
+	ProblemMark Mark
+
+	// Offset is the byte offset in the input of the problem of an error
+	// reading it, such as an invalid UTF-8 sequence, and Value the octet
+	// or character at fault, or -1.
+	Offset int
+	Value  int
+}
+
+// newParserError returns the error that stopped a parser.
+func newParserError(parser *yaml_parser_t) error {
+	if parser.error == yaml_LIMIT_ERROR {
+		return &LimitError{
+			Limit: parser.context,
+			Max:   parser.problem_value,
+			At:    markOf(parser.context_mark),
+		}
+	}
+	return &ParserError{
+		ErrorType:   parser.error,
+		Context:     parser.context,
+		ContextMark: markOf(parser.context_mark),
+		Problem:     parser.problem,
+		ProblemMark: markOf(parser.problem_mark),
+		Offset:      parser.problem_offset,
+		Value:       parser.problem_value,
+	}
 }
 
 func (e *ParserError) Error() string {
-	return fmt.Sprintf("yaml: [%s] %s at line %d, column %d", e.Context, e.Problem, e.ProblemMark.line+1, e.ProblemMark.column+1)
+	if e.ErrorType == yaml_READER_ERROR {
+		// the reader runs ahead of the scanner, and only knows the offset
+		if e.Value >= 0 {
+			return fmt.Sprintf("yaml: %s #x%02X at byte offset %d", e.Problem, e.Value, e.Offset)
+		}
+		return fmt.Sprintf("yaml: %s at byte offset %d", e.Problem, e.Offset)
+	}
+	return fmt.Sprintf("yaml: [%s] %s at line %d, column %d", e.Context, e.Problem, e.ProblemMark.Line, e.ProblemMark.Column)
+}
+
+// Kind returns the stage of parsing at which the error occurred.
+func (e *ParserError) Kind() ErrorKind {
+	switch e.ErrorType {
+	case yaml_READER_ERROR:
+		return ReadingError
+	case yaml_SCANNER_ERROR:
+		return ScanningError
+	}
+	return ParsingError
+}
+
+func (e *ParserError) Location() Mark {
+	if e.ErrorType == yaml_READER_ERROR {
+		return Mark{Offset: e.Offset}
+	}
+	return e.ProblemMark
+}
+
+func (e *ParserError) Description() string {
+	return e.Problem
+}
+
+// An ErrorKind is the stage of decoding at which an Error occurred.
+type ErrorKind int
+
+const (
+	// ReadingError is input that cannot be read or decoded, such as
+	// invalid UTF-8.
+	ReadingError ErrorKind = iota + 1
+	// ScanningError is input that cannot be split into tokens.
+	ScanningError
+	// ParsingError is tokens that do not form a document.
+	ParsingError
+	// ComposingError is events that do not form the nodes of a document,
+	// such as an alias to an unknown anchor.
+	ComposingError
+	// DecodingError is nodes that cannot be decoded into the values they
+	// are decoded into, such as an invalid integer.
+	DecodingError
+	// LimitExceededError is input exceeding the Limits of a Decoder.
+	LimitExceededError
+)
+
+var errorKindNames = []string{
+	ReadingError:       "reading error",
+	ScanningError:      "scanning error",
+	ParsingError:       "parsing error",
+	ComposingError:     "composing error",
+	DecodingError:      "decoding error",
+	LimitExceededError: "limit exceeded error",
+}
+
+func (k ErrorKind) String() string {
+	if k > 0 && int(k) < len(errorKindNames) {
+		return errorKindNames[k]
+	}
+	return "error kind " + strconv.Itoa(int(k))
 }
 
 type UnexpectedEventError struct {
 	Value     string
-	EventType yaml_event_type_t
-	At        YAML_mark_t
+	EventType EventKind
+	At        Mark
 }
 
 func (e *UnexpectedEventError) Error() string {
-	return fmt.Sprintf("yaml: Unexpect event [%d]: '%s' at line %d, column %d", e.EventType, e.Value, e.At.line+1, e.At.column+1)
+	return fmt.Sprintf("yaml: Unexpect event [%d]: '%s' at line %d, column %d", e.EventType, e.Value, e.At.Line, e.At.Column)
+}
+
+// Kind returns ComposingError.
+func (e *UnexpectedEventError) Kind() ErrorKind {
+	return ComposingError
+}
+
+func (e *UnexpectedEventError) Location() Mark {
+	return e.At
+}
+
+func (e *UnexpectedEventError) Description() string {
+	return fmt.Sprintf("Unexpected event: %s", e.EventType)
+}
+
+// A FieldError is an error decoding the value at Path, which names mapping
+// keys as written and sequence indexes from the root of the document as
+// Positions does, such as "servers[0].port".
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
 }
 
 func recovery(err *error) {
@@ -89,36 +369,210 @@ func recovery(err *error) {
 			panic(r)
 		}
 
-		var tmpError error
-		switch r := r.(type) {
-		case error:
-			tmpError = r
-		case string:
-			tmpError = errors.New(r)
-		default:
-			tmpError = errors.New("Unknown panic: " + reflect.ValueOf(r).String())
-		}
+		*err = panicError(r)
+	}
+}
 
-		*err = tmpError
+// panicError returns the error for the value of a panic.
+func panicError(r interface{}) error {
+	switch r := r.(type) {
+	case error:
+		return r
+	case string:
+		return errors.New(r)
+	default:
+		return errors.New("Unknown panic: " + reflect.ValueOf(r).String())
 	}
 }
 
 func Unmarshal(data []byte, v interface{}) error {
-	d := NewDecoder(bytes.NewBuffer(data))
-	return d.Decode(v)
+	d := getDecoder(bytes.NewReader(data))
+	err := d.Decode(v)
+	putDecoder(d)
+	return err
 }
 
-func NewDecoder(r io.Reader) *Decoder {
+// UnmarshalOne is like Unmarshal, but data must hold exactly one document:
+// where Unmarshal ignores what follows the first document, another
+// document after it, even an empty one, is an error. Comments and a "..."
+// marker may follow the document.
+func UnmarshalOne(data []byte, v interface{}) error {
+	d := getDecoder(bytes.NewReader(data))
+	defer putDecoder(d)
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if d.event.event_type != yaml_STREAM_END_EVENT {
+		return composingError(d.event.start_mark, "Expected a single document but found another")
+	}
+	return nil
+}
+
+// NewDecoder returns a new decoder that reads from r, configured by opts.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
 	d := &Decoder{
 		anchors:          make(map[string][]yaml_event_t),
 		tracking_anchors: make([][]yaml_event_t, 0),
 	}
 	yaml_parser_initialize(&d.parser)
 	yaml_parser_set_input_reader(&d.parser, r)
+
+	for _, opt := range opts {
+		if opt.decoder != nil {
+			opt.decoder(d)
+		}
+	}
 	return d
 }
 
+// Reset discards the state of d and makes it read from r. Its options
+// are kept and its buffers reused, so that a Decoder can decode many
+// small inputs without allocating a new one for each.
+func (d *Decoder) Reset(r io.Reader) {
+	yaml_parser_reset(&d.parser)
+	yaml_parser_set_input_reader(&d.parser, r)
+
+	d.event = yaml_event_t{}
+	d.replay_events = nil
+	d.recorded = nil
+	for name := range d.anchors {
+		delete(d.anchors, name)
+	}
+	d.tracking_anchors = d.tracking_anchors[:0]
+	d.documentAnchors, d.nextAnchors = nil, nil
+	d.aliases, d.nextAliases = nil, nil
+	d.nodeAnchors = nil
+	d.nodeStack = d.nodeStack[:0]
+	d.nodeDepth = 0
+	d.lastNode, d.flowNode = nil, nil
+	d.tagChecked = false
+	d.positions = nil
+	d.diagnostics, d.skipped = nil, nil
+	d.documentInfo, d.nextDocumentInfo = DocumentInfo{}, DocumentInfo{}
+	d.nextProgress = d.progressEvery
+	d.valueBytes = 0
+	d.parsedEvents, d.expandedEvents = 0, 0
+	d.blockScalar = false
+}
+
+// Decode reads the next document from the input and stores it in the
+// value v points to.
+//
+// An alias is decoded again from the events of its anchor wherever it is
+// used, so the values of an anchor and of its aliases share no slices,
+// maps or pointers: changing one leaves the others as they were. Nodes
+// are the exception, as an AliasNode refers to the Node of its anchor.
 func (d *Decoder) Decode(v interface{}) (err error) {
+	return d.DecodeValue(reflect.ValueOf(v))
+}
+
+// DecodeValue decodes the next document into v like Decode, for callers
+// that build the values to decode into with reflect: v is either a value
+// that can be set, such as the field of a struct reached through a
+// pointer, or a pointer to the value to decode into. The type of v
+// directs the decoding as it does for Decode.
+func (d *Decoder) DecodeValue(v reflect.Value) (err error) {
+	defer recovery(&err)
+
+	rv := v
+	if rv.CanSet() {
+		rv = rv.Addr()
+	}
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Expected a pointer or nil but was a %s at %s", rv.String(), d.event.start_mark)
+	}
+
+	d.start()
+	if done, err := d.noDocument(rv); done {
+		return err
+	}
+	d.document(rv)
+	return nil
+}
+
+// Skip discards the next document of the stream. It reads the document
+// event by event, without building any values, which makes it cheaper
+// than decoding documents that are not needed.
+func (d *Decoder) Skip() (err error) {
+	defer recovery(&err)
+
+	d.start()
+	if d.event.event_type != yaml_DOCUMENT_START_EVENT {
+		return composingError(d.event.start_mark, "Expected document start")
+	}
+	d.nextEvent()
+	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		d.skip()
+	}
+	d.dropSkipped()
+	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		return composingError(d.event.start_mark, "Expected document end")
+	}
+	d.nextEvent()
+	return nil
+}
+
+// DecodeFields decodes the next document, which must be a mapping, binding
+// only the keys present in fields. Each value of fields is a pointer that
+// its key's value is decoded into, as by Decode; the values of other keys
+// are skipped without being decoded. Keys merged in with "<<" are not
+// looked up.
+func (d *Decoder) DecodeFields(fields map[string]interface{}) (err error) {
+	defer recovery(&err)
+
+	for k, v := range fields {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return fmt.Errorf("Expected a pointer for field %q but was a %s", k, rv.String())
+		}
+	}
+
+	d.start()
+	if d.event.event_type != yaml_DOCUMENT_START_EVENT {
+		return composingError(d.event.start_mark, "Expected document start")
+	}
+	d.nextEvent()
+
+	switch d.event.event_type {
+	case yaml_DOCUMENT_END_EVENT:
+	case yaml_MAPPING_START_EVENT:
+		anchor := string(d.event.anchor)
+		d.begin_anchor(anchor)
+		d.nextEvent()
+		for d.event.event_type != yaml_MAPPING_END_EVENT {
+			d.checkKey()
+			if d.event.event_type != yaml_SCALAR_EVENT && d.event.event_type != yaml_ALIAS_EVENT {
+				d.skip()
+				d.skip()
+				continue
+			}
+
+			key := ""
+			d.parse(reflect.ValueOf(&key))
+			if v, ok := fields[key]; ok {
+				d.parse(reflect.ValueOf(v))
+			} else {
+				d.skip()
+			}
+		}
+		d.nextEvent()
+		d.end_anchor(anchor)
+	default:
+		return decodingError(d.event.start_mark, "Expected a mapping")
+	}
+
+	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		return composingError(d.event.start_mark, "Expected document end")
+	}
+	d.nextEvent()
+	return nil
+}
+
+// Peek decodes the next document into v like Decode, but leaves it to be
+// read again by the next call: a document can first be decoded into a
+// small header struct, and then into the type that the header selects.
+// The events of the document are kept in memory until it is read again.
+func (d *Decoder) Peek(v interface{}) (err error) {
 	defer recovery(&err)
 
 	rv := reflect.ValueOf(v)
@@ -126,22 +580,104 @@ func (d *Decoder) Decode(v interface{}) (err error) {
 		return fmt.Errorf("Expected a pointer or nil but was a %s at %s", rv.String(), d.event.start_mark)
 	}
 
+	d.start()
+	if done, err := d.noDocument(rv); done {
+		return err
+	}
+	if d.event.event_type != yaml_DOCUMENT_START_EVENT {
+		return composingError(d.event.start_mark, "Expected document start")
+	}
+
+	// the anchors and aliases are those of the document decoded last,
+	// and are recorded again when the document is read again
+	anchors, aliases := d.documentAnchors, d.aliases
+	defer func() { d.documentAnchors, d.aliases = anchors, aliases }()
+
+	// read up to the event following the document, where decoding it
+	// stops, so that decoding it never reads from the parser; its values
+	// count toward MaxValueBytes when they are decoded
+	valueBytes := d.valueBytes
+	events := []yaml_event_t{d.event}
+	for d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		d.nextEvent()
+		events = append(events, d.event)
+	}
+	// the events parsed are those of the document, not of the next one
+	parsedEvents, expandedEvents := d.parsedEvents, d.expandedEvents
+	d.nextEvent()
+	events = append(events, d.event)
+	events = append(events, d.replay_events...)
+	d.valueBytes = valueBytes
+	d.parsedEvents, d.expandedEvents = parsedEvents, expandedEvents
+
+	rewind := func() {
+		d.event = events[0]
+		d.replay_events = events[1:]
+	}
+	rewind()
+	defer rewind()
+
+	d.document(rv)
+	return nil
+}
+
+// start reads the start of the stream before the first document.
+func (d *Decoder) start() {
 	if d.event.event_type == yaml_NO_EVENT {
+		if d.tolerant {
+			d.skipErrors()
+		}
 		d.nextEvent()
 
 		if d.event.event_type != yaml_STREAM_START_EVENT {
-			return errors.New("Invalid stream")
+			d.error(newDecodeError(ComposingError, d.event.start_mark, "Invalid stream", "Invalid stream"))
 		}
 
 		d.nextEvent()
 	}
+}
 
-	d.document(rv)
-	return nil
+// skip consumes the current value. Its anchors are still recorded, as
+// they may be aliased by the values that follow.
+func (d *Decoder) skip() {
+	anchor := string(d.event.anchor)
+	switch d.event.event_type {
+	case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+		d.begin_anchor(anchor)
+		d.nextEvent()
+		for d.event.event_type != yaml_SEQUENCE_END_EVENT &&
+			d.event.event_type != yaml_MAPPING_END_EVENT {
+			d.skip()
+		}
+	case yaml_SCALAR_EVENT:
+		d.begin_anchor(anchor)
+	case yaml_ALIAS_EVENT:
+		// enclosing anchors still record the aliased value in full
+		for i, e := range d.tracking_anchors {
+			d.tracking_anchors[i] = append(e, d.anchors[anchor]...)
+		}
+		d.nextEvent()
+		return
+	default:
+		d.error(&UnexpectedEventError{
+			Value:     string(d.event.value),
+			EventType: EventKind(d.event.event_type),
+			At:        markOf(d.event.start_mark),
+		})
+	}
+	d.nextEvent()
+	d.end_anchor(anchor)
 }
 
 func (d *Decoder) UseNumber() { d.useNumber = true }
 
+// LineBreak returns the style of the first line break read from the input,
+// or AnyBreak if none has been read yet. Passing it to Encoder.SetLineBreak
+// preserves the break style of a document that is decoded and re-encoded.
+func (d *Decoder) LineBreak() LineBreak {
+	return LineBreak(d.parser.line_break)
+}
+
 // StrictMode is used to set the strict mode flag on the decoder.
 // When the strict mode is set to true, the decoder should
 // error when an unexpected field is encountered.
@@ -149,7 +685,272 @@ func (d *Decoder) StrictMode(strict bool) {
 	d.strictMode = strict
 }
 
+// StringKeys makes the decoder reject mapping keys that are not strings,
+// such as numbers, booleans, nulls and collections. Documents that are
+// forwarded as JSON then fail while decoding, with the position of the
+// offending key.
+func (d *Decoder) StringKeys(only bool) {
+	d.stringKeys = only
+}
+
+// An EmptyPolicy controls what Decode does when the stream holds no more
+// documents, as when the input is empty or only has comments.
+type EmptyPolicy int
+
+const (
+	// EmptyIsError makes Decode fail.
+	EmptyIsError EmptyPolicy = iota
+	// EmptyIsEOF makes Decode return io.EOF.
+	EmptyIsEOF
+	// EmptyIsZero makes Decode set the value to its zero value. Documents
+	// without content, such as "---" followed by comments, are decoded
+	// the same way.
+	EmptyIsZero
+)
+
+// EmptyDocuments sets what Decode and Peek do when there is no document
+// left to read. The default is EmptyIsError. Documents without content
+// are otherwise decoded as null.
+func (d *Decoder) EmptyDocuments(policy EmptyPolicy) {
+	d.emptyPolicy = policy
+}
+
+// noDocument applies the EmptyDocuments policy at the end of the stream,
+// reporting whether decoding into rv is done and with which error.
+func (d *Decoder) noDocument(rv reflect.Value) (bool, error) {
+	if d.event.event_type != yaml_STREAM_END_EVENT {
+		return false, nil
+	}
+	switch d.emptyPolicy {
+	case EmptyIsEOF:
+		return true, io.EOF
+	case EmptyIsZero:
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+		return true, nil
+	}
+	return false, nil
+}
+
+// emptyContent reports whether the current event is the empty scalar that
+// stands for the content of a document without any.
+func (d *Decoder) emptyContent() bool {
+	return d.event.event_type == yaml_SCALAR_EVENT && len(d.event.value) == 0 &&
+		len(d.event.tag) == 0 && len(d.event.anchor) == 0 &&
+		yaml_scalar_style_t(d.event.style) == yaml_PLAIN_SCALAR_STYLE
+}
+
+// A NullPolicy controls how a Decoder binds nulls to values that cannot
+// be nil.
+type NullPolicy int
+
+const (
+	// NullIsZero sets such values to their zero values.
+	NullIsZero NullPolicy = iota
+	// NullIsError makes decoding fail with the path and position of the
+	// null.
+	NullIsError
+)
+
+// NullValues sets how nulls, including empty values, are bound to values
+// other than pointers, interfaces, maps and slices. By default they are
+// set to their zero values; strict schemas can reject them instead, so
+// that only nullable fields can be left out with an explicit null.
+func (d *Decoder) NullValues(policy NullPolicy) {
+	d.nullPolicy = policy
+}
+
+// nullable reports whether a null can be stored in v as nil.
+func nullable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr:
+		return v.CanSet() || !v.IsNil() && nullable(v.Elem())
+	case reflect.Interface, reflect.Map, reflect.Slice:
+		return true
+	}
+	return false
+}
+
+// checkNull enforces NullValues on the scalar at the current event, to be
+// stored in v, which holds the value pv.
+func (d *Decoder) checkNull(v, pv reflect.Value) {
+	if d.nullPolicy != NullIsError || !isNull(d.event) || nullable(v) {
+		return
+	}
+	d.error(decodingError(d.event.start_mark, "Cannot decode a null into the %s", pv.Type()))
+}
+
+// A PointerNullPolicy controls what a null sets a pointer to.
+type PointerNullPolicy int
+
+const (
+	// NilOnNull sets the pointer to nil.
+	NilOnNull PointerNullPolicy = iota
+	// ZeroOnNull sets the pointer to a new zero value.
+	ZeroOnNull
+)
+
+// PointerNulls sets what an explicit null, such as `key: null`, `key: ~` or
+// an empty `key:`, sets a pointer to. A key that is absent always leaves
+// its field untouched, so a nil pointer stays nil.
+//
+// By default a null sets the pointer to nil, which cannot be told apart
+// from an absent key in a nil pointer; for a PATCH-style API, decode into
+// pointers to pointers, such as **int, which stay nil for absent keys and
+// point to a nil pointer for nulls, or use ZeroOnNull, which makes a null
+// allocate a zero value that an absent key never does.
+func (d *Decoder) PointerNulls(policy PointerNullPolicy) {
+	d.pointerNulls = policy
+}
+
+// A SingleElementPolicy controls whether a Decoder converts between
+// scalars and sequences of one element.
+type SingleElementPolicy int
+
+const (
+	// KeepShape decodes sequences only into slices, arrays and interfaces.
+	KeepShape SingleElementPolicy = iota
+	// WrapScalars decodes a scalar into a slice or array as its only
+	// element.
+	WrapScalars
+	// WrapAndCollapse also decodes a sequence of one element into a value
+	// that is not a slice or array as that element.
+	WrapAndCollapse
+)
+
+// SingleElements sets whether `key: value` can be decoded into a slice
+// field, and `key: [value]` into a field that is not a slice, for input
+// written by producers that use either form. Nulls and byte slices are
+// decoded as they are by default.
+func (d *Decoder) SingleElements(policy SingleElementPolicy) {
+	d.singleElements = policy
+}
+
+// wrapScalar decodes the scalar at the current event into v as its only
+// element when v is a slice or array and SingleElements allows it.
+func (d *Decoder) wrapScalar(v reflect.Value) bool {
+	if d.singleElements == KeepShape || isNull(d.event) {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+	case reflect.Array:
+		if v.Len() == 0 || v.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		v.Set(reflect.Zero(v.Type()))
+	default:
+		return false
+	}
+
+	// the anchor of the scalar is already being tracked
+	d.event.anchor = nil
+	n := d.pushIndex(0)
+	d.parse(v.Index(0))
+	d.popPath(n)
+	return true
+}
+
+// collapseSequence decodes the sequence at the current event into v as
+// its only element when SingleElements allows it.
+func (d *Decoder) collapseSequence(v reflect.Value) bool {
+	if d.singleElements != WrapAndCollapse {
+		return false
+	}
+
+	start := d.event.start_mark
+	d.nextEvent()
+	if d.event.event_type == yaml_SEQUENCE_END_EVENT {
+		d.error(decodingError(start, "Cannot decode an empty sequence into the %s", v.Type()))
+	}
+
+	n := d.pushIndex(0)
+	d.parse(v)
+	d.popPath(n)
+
+	if d.event.event_type != yaml_SEQUENCE_END_EVENT {
+		d.error(decodingError(start, "Cannot decode a sequence of more than one element into the %s", v.Type()))
+	}
+	d.nextEvent()
+	return true
+}
+
+// DepthLimit makes the decoder build only levels levels of nested
+// collections in interface{} values: the collections nested deeper are
+// decoded as *Node, which keeps their content without building maps and
+// slices for it. With a limit of 1, decoding a document into an
+// interface{} gives a map whose collection values are all Nodes. Typed
+// values are decoded in full. A limit of zero, the default, builds every
+// level.
+func (d *Decoder) DepthLimit(levels int) {
+	d.depthLimit = levels
+}
+
+// deferNode decodes the collection at the current event as a *Node into
+// the interface{} v when it is nested deeper than the DepthLimit.
+func (d *Decoder) deferNode(v reflect.Value) bool {
+	if d.depthLimit <= 0 || d.depth < d.depthLimit || !v.CanSet() ||
+		v.Kind() != reflect.Interface || v.NumMethod() != 0 {
+		return false
+	}
+	switch d.event.event_type {
+	case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+		v.Set(reflect.ValueOf(d.node()))
+		return true
+	}
+	return false
+}
+
+// checkKey enforces StringKeys on the mapping key at the current event.
+func (d *Decoder) checkKey() {
+	if !d.stringKeys {
+		return
+	}
+
+	event := d.event
+	if event.event_type == yaml_ALIAS_EVENT {
+		if events := d.anchors[string(event.anchor)]; len(events) > 0 {
+			event = events[0]
+		}
+	}
+
+	switch event.event_type {
+	case yaml_SEQUENCE_START_EVENT:
+		d.error(decodingError(d.event.start_mark, "Expected a string key but was a sequence"))
+	case yaml_MAPPING_START_EVENT:
+		d.error(decodingError(d.event.start_mark, "Expected a string key but was a mapping"))
+	case yaml_SCALAR_EVENT:
+		if !isStringScalar(event) {
+			d.error(decodingError(d.event.start_mark, "Expected a string key but was '%s'", event.value))
+		}
+	}
+}
+
+// isStringScalar reports whether a scalar event resolves to a string.
+func isStringScalar(event yaml_event_t) bool {
+	switch tag := string(event.tag); tag {
+	case yaml_STR_TAG:
+		return true
+	case "", "!":
+	default:
+		return false
+	}
+	_, v := resolveInterface(event, false)
+	_, ok := v.(string)
+	return ok
+}
+
 func (d *Decoder) error(err error) {
+	switch err.(type) {
+	case *ParserError, *LimitError, *ResourceLimitError, *FieldError:
+	default:
+		if len(d.path) > 0 {
+			err = &FieldError{Path: d.pathString(), Err: err}
+		}
+	}
 	panic(err)
 }
 
@@ -157,6 +958,9 @@ func (d *Decoder) nextEvent() {
 	if d.event.event_type == yaml_STREAM_END_EVENT {
 		d.error(errors.New("The stream is closed"))
 	}
+	if !d.deadline.IsZero() {
+		d.checkDeadline()
+	}
 
 	if d.replay_events != nil {
 		d.event = d.replay_events[0]
@@ -166,19 +970,45 @@ func (d *Decoder) nextEvent() {
 			d.replay_events = d.replay_events[1:]
 		}
 	} else {
-		if !yaml_parser_parse(&d.parser, &d.event) {
+		if d.recorded != nil {
+			d.event = d.recorded[0]
+			d.recorded = d.recorded[1:]
+		} else if !yaml_parser_parse(&d.parser, &d.event) {
 			yaml_event_delete(&d.event)
 
-			d.error(&ParserError{
-				ErrorType:   d.parser.error,
-				Context:     d.parser.context,
-				ContextMark: d.parser.context_mark,
-				Problem:     d.parser.problem,
-				ProblemMark: d.parser.problem_mark,
-			})
+			d.error(newParserError(&d.parser))
+		}
+		if d.maxAliasExpansion > 0 {
+			d.countParsedEvent()
+		}
+		if len(d.parser.indentation_issues) > 0 {
+			d.warnIndentation()
+		}
+		if len(d.parser.unknown_directives) > 0 {
+			d.warnDirectives()
+		}
+		if d.onProgress != nil {
+			d.reportProgress()
+		}
+		if d.tagPolicy != nil {
+			d.checkTag()
+		}
+		if d.strictMarkers {
+			d.checkMarker()
+		}
+		if d.implicitRules != nil || d.noSeparators || d.nulls != nil || d.failsafe || d.schema != DefaultSchema {
+			d.applyImplicitRules()
 		}
 	}
 
+	if d.event.event_type == yaml_DOCUMENT_START_EVENT || d.event.event_type == yaml_DOCUMENT_END_EVENT {
+		d.recordDocumentInfo()
+	}
+
+	if d.maxValueBytes > 0 {
+		d.countValueBytes()
+	}
+
 	last := len(d.tracking_anchors)
 	// skip aliases when tracking an anchor
 	if last > 0 && d.event.event_type != yaml_ALIAS_EVENT {
@@ -188,14 +1018,28 @@ func (d *Decoder) nextEvent() {
 
 func (d *Decoder) document(rv reflect.Value) {
 	if d.event.event_type != yaml_DOCUMENT_START_EVENT {
-		d.error(fmt.Errorf("Expected document start at %s", d.event.start_mark))
+		d.error(composingError(d.event.start_mark, "Expected document start"))
 	}
 
 	d.nextEvent()
-	d.parse(rv)
+	d.documentContent(rv)
+}
+
+// documentContent decodes the content of a document, whose start event was
+// read, into rv, and reads the end of the document.
+func (d *Decoder) documentContent(rv reflect.Value) {
+	d.startPositions()
+	d.depth = 0
+	if d.emptyPolicy == EmptyIsZero && d.emptyContent() {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+		d.nextEvent()
+	} else {
+		d.parse(rv)
+	}
+	d.dropSkipped()
 
 	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
-		d.error(fmt.Errorf("Expected document end at %s", d.event.start_mark))
+		d.error(composingError(d.event.start_mark, "Expected document end"))
 	}
 
 	d.nextEvent()
@@ -208,15 +1052,63 @@ func (d *Decoder) parse(rv reflect.Value) {
 		return
 	}
 
+	if n := nodeTarget(rv); n != nil && d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		*n = *d.node()
+		return
+	}
+
+	if r := rawTarget(rv); r != nil && d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		*r = d.raw()
+		return
+	}
+
+	if u := d.nodeUnmarshaler(rv); u != nil && d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		if err := u.UnmarshalYAMLNode(d.node()); err != nil {
+			d.error(err)
+		}
+		return
+	}
+
+	if u := d.eventUnmarshaler(rv); u != nil && d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		d.unmarshalEvents(u)
+		return
+	}
+
+	iv := rv
+	for iv.Kind() == reflect.Ptr && !iv.IsNil() {
+		iv = iv.Elem()
+	}
+	if nv := d.registeredValue(iv); nv.IsValid() && iv.CanSet() {
+		d.parse(nv)
+		if nv.Elem().Type().Implements(iv.Type()) {
+			iv.Set(nv.Elem())
+		} else {
+			iv.Set(nv)
+		}
+		return
+	}
+
+	if iv.CanSet() && d.taggedValue(iv) {
+		return
+	}
+
+	if d.deferNode(iv) {
+		return
+	}
+
 	anchor := string(d.event.anchor)
 	switch d.event.event_type {
 	case yaml_SEQUENCE_START_EVENT:
 		d.begin_anchor(anchor)
+		d.depth++
 		d.sequence(rv)
+		d.depth--
 		d.end_anchor(anchor)
 	case yaml_MAPPING_START_EVENT:
 		d.begin_anchor(anchor)
+		d.depth++
 		d.mapping(rv)
+		d.depth--
 		d.end_anchor(anchor)
 	case yaml_SCALAR_EVENT:
 		d.begin_anchor(anchor)
@@ -228,8 +1120,8 @@ func (d *Decoder) parse(rv reflect.Value) {
 	default:
 		d.error(&UnexpectedEventError{
 			Value:     string(d.event.value),
-			EventType: d.event.event_type,
-			At:        d.event.start_mark,
+			EventType: EventKind(d.event.event_type),
+			At:        markOf(d.event.start_mark),
 		})
 	}
 }
@@ -247,13 +1139,17 @@ func (d *Decoder) end_anchor(anchor string) {
 		d.tracking_anchors = d.tracking_anchors[0 : len(d.tracking_anchors)-1]
 		// remove the anchor, replaying events shouldn't have anchors
 		events[0].anchor = nil
-		// we went one too many, remove the extra event
-		events = events[:len(events)-1]
+		// we went one too many, remove the extra event unless it was
+		// an alias, which is never tracked
+		if d.event.event_type != yaml_ALIAS_EVENT {
+			events = events[:len(events)-1]
+		}
 		// if nested, append to all the other anchors
 		for i, e := range d.tracking_anchors {
 			d.tracking_anchors[i] = append(e, events...)
 		}
 		d.anchors[anchor] = events
+		d.nextAnchors = append(d.nextAnchors, anchor)
 	}
 }
 
@@ -302,7 +1198,7 @@ func (d *Decoder) indirect(v reflect.Value, decodingNull bool) (Unmarshaler, ref
 
 func (d *Decoder) sequence(v reflect.Value) {
 	if d.event.event_type != yaml_SEQUENCE_START_EVENT {
-		d.error(fmt.Errorf("Expected sequence start at %s", d.event.start_mark))
+		d.error(decodingError(d.event.start_mark, "Expected sequence start"))
 	}
 
 	u, pv := d.indirect(v, false)
@@ -317,6 +1213,12 @@ func (d *Decoder) sequence(v reflect.Value) {
 
 	v = pv
 
+	if tag := string(d.event.tag); tag == yaml_OMAP_TAG || tag == yaml_PAIRS_TAG {
+		if d.pairs(v) {
+			return
+		}
+	}
+
 	// Check type of target.
 	switch v.Kind() {
 	case reflect.Interface:
@@ -328,7 +1230,10 @@ func (d *Decoder) sequence(v reflect.Value) {
 		// Otherwise it's invalid.
 		fallthrough
 	default:
-		d.error(fmt.Errorf("Expected an array, slice or interface{} but was a %s at %s", v, d.event.start_mark))
+		if d.collapseSequence(v) {
+			return
+		}
+		d.error(decodingError(d.event.start_mark, "Expected an array, slice or interface{} but was a %s", v))
 	case reflect.Array:
 	case reflect.Slice:
 		break
@@ -361,6 +1266,7 @@ done:
 			}
 		}
 
+		n := d.pushIndex(i)
 		if i < v.Len() {
 			// Decode into element.
 			d.parse(v.Index(i))
@@ -368,6 +1274,7 @@ done:
 			// Ran out of fixed array: skip.
 			d.parse(reflect.Value{})
 		}
+		d.popPath(n)
 		i++
 	}
 
@@ -403,9 +1310,13 @@ func (d *Decoder) mapping(v reflect.Value) {
 	}
 	v = pv
 
+	set := string(d.event.tag) == yaml_SET_TAG
+
 	// Decoding into nil interface?  Switch to non-reflect code.
 	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
-		if d.mapType != nil {
+		if set {
+			v.Set(reflect.ValueOf(d.setInterface()))
+		} else if d.mapType != nil {
 			subv := reflect.New(d.mapType).Elem()
 			d.mappingSlice(subv)
 			v.Set(subv)
@@ -428,7 +1339,7 @@ func (d *Decoder) mapping(v reflect.Value) {
 		return
 	case reflect.Map:
 	default:
-		d.error(fmt.Errorf("Expected a struct or map but was a %s at %s ", v, d.event.start_mark))
+		d.error(decodingError(d.event.start_mark, "Expected a struct or map but was a %s", v))
 	}
 
 	mapt := v.Type()
@@ -451,8 +1362,16 @@ done:
 			return
 		}
 
+		d.checkKey()
+		at := d.event.start_mark
 		key := reflect.New(keyt)
 		d.parse(key.Elem())
+		if keyt.Kind() == reflect.Interface && !key.Elem().IsNil() {
+			key.Elem().Set(reflect.ValueOf(hashableKey(key.Elem().Interface())))
+		}
+		if !set && d.onWarning != nil && v.MapIndex(key.Elem()).IsValid() {
+			d.warn(DuplicateKey, at, "duplicate key '%v', of which the last value is kept", key.Elem().Interface())
+		}
 
 		if !mapElem.IsValid() {
 			mapElem = reflect.New(mapElemt).Elem()
@@ -460,7 +1379,17 @@ done:
 			mapElem.Set(reflect.Zero(mapElemt))
 		}
 
-		d.parse(mapElem)
+		if set {
+			// members have no value, only their presence matters
+			d.parse(reflect.Value{})
+			if mapElemt.Kind() == reflect.Bool {
+				mapElem.SetBool(true)
+			}
+		} else {
+			n := d.pushValue(key.Elem())
+			d.parse(mapElem)
+			d.popPath(n)
+		}
 
 		v.SetMapIndex(key.Elem(), mapElem)
 	}
@@ -472,25 +1401,12 @@ func (d *Decoder) mappingSlice(v reflect.Value) {
 
 	structt := v.Type().Elem()
 
-	fields := cachedTypeFields(structt)
-
-	var nameField *field
-	var valueField *field
-	for _, f := range fields {
-		switch f.name {
-		case "Key":
-			tempf := f
-			nameField = &tempf
-		case "Value":
-			tempf := f
-			valueField = &tempf
-		}
-	}
+	nameField, valueField := pairFields(structt)
 
 	// in this instance, we require that a struct
 	// with names Key and Value
 	if nameField == nil || valueField == nil {
-		d.error(fmt.Errorf("Expected a slice of a struct with fields called 'Key' and 'Value': %v, %v ", v, d.event.start_mark))
+		d.error(decodingError(d.event.start_mark, "Expected a slice of a struct with fields called 'Key' and 'Value': %v", v))
 	}
 
 	d.nextEvent()
@@ -520,8 +1436,11 @@ done:
 
 		subv := reflect.New(structt).Elem()
 
+		d.checkKey()
 		d.parse(subv.FieldByName(nameField.name))
+		n := d.pushValue(subv.FieldByName(nameField.name))
 		d.parse(subv.FieldByName(valueField.name))
+		d.popPath(n)
 
 		v.Index(i).Set(subv)
 		i++
@@ -537,8 +1456,12 @@ done:
 
 func (d *Decoder) mappingStruct(v reflect.Value) {
 
-	structt := v.Type()
-	fields := cachedTypeFields(structt)
+	st := cachedStructType(v.Type())
+
+	var seen map[*field]bool
+	if d.onWarning != nil {
+		seen = make(map[*field]bool)
+	}
 
 	d.nextEvent()
 
@@ -551,26 +1474,23 @@ done:
 			return
 		}
 
+		d.checkKey()
+		at := d.event.start_mark
 		key := ""
 		d.parse(reflect.ValueOf(&key))
 
 		// Figure out field corresponding to key.
 		var subv reflect.Value
+		n := len(d.path)
 
-		var f *field
-		for i := range fields {
-			ff := &fields[i]
-			if ff.name == key {
-				f = ff
-				break
-			}
-
-			if f == nil && strings.EqualFold(ff.name, key) {
-				f = ff
+		if f := st.fieldByName(key); f != nil {
+			if seen != nil {
+				if seen[f] {
+					d.warn(DuplicateKey, at, "duplicate key '%s', of which the last value is kept", key)
+				}
+				seen[f] = true
 			}
-		}
-
-		if f != nil {
+			n = d.pushKey(key)
 			subv = v
 			for _, i := range f.index {
 				if subv.Kind() == reflect.Ptr {
@@ -582,19 +1502,25 @@ done:
 				subv = subv.Field(i)
 			}
 		} else if d.strictMode {
-			d.error(fmt.Errorf("unable to map key %q to a struct field at %v", key, d.event.start_mark))
+			d.error(decodingError(d.event.start_mark, "unable to map key %q to a struct field", key))
+		} else if d.onWarning != nil {
+			d.warn(UnknownField, at, "key '%s' matches no field of %s", key, v.Type())
 		}
 		d.parse(subv)
+		d.popPath(n)
 	}
 
 	d.nextEvent()
 }
 
 func (d *Decoder) scalar(v reflect.Value) {
-	val := string(d.event.value)
-	wantptr := null_values[val]
+	// a null sets a pointer to nil, or to a new zero value
+	wantptr := isNull(d.event) && d.pointerNulls == NilOnNull
 
 	u, pv := d.indirect(v, wantptr)
+	if u == nil {
+		d.checkNull(v, pv)
+	}
 
 	var tag string
 	if u != nil {
@@ -608,43 +1534,111 @@ func (d *Decoder) scalar(v reflect.Value) {
 	}
 	v = pv
 
+	if d.wrapScalar(v) {
+		return
+	}
+
+	if enum := registeredEnum(v.Type()); enum != nil && !isNull(d.event) {
+		d.enum(enum, v)
+		d.nextEvent()
+		return
+	}
+
+	if d.weaklyTyped {
+		var ok bool
+		if tag, ok = d.weakScalar(v); ok {
+			d.nextEvent()
+			return
+		}
+	}
+
 	var err error
-	tag, err = resolve(d.event, v, d.useNumber)
+	tag, err = resolveText(d.event, d.scalarValue(), v, d.useNumber)
 	if err != nil {
 		d.error(err)
 	}
+	d.checkScalar(tag, v)
 
 	d.nextEvent()
 }
 
 func (d *Decoder) alias(rv reflect.Value) {
+	d.replayAlias()
+	d.parse(rv)
+}
+
+// replayAlias moves to the first event of the value the current alias
+// refers to, recording where the alias was used.
+func (d *Decoder) replayAlias() {
 	val, ok := d.anchors[string(d.event.anchor)]
 	if !ok {
-		d.error(fmt.Errorf("missing anchor: '%s' at %s", d.event.anchor, d.event.start_mark))
+		d.error(composingError(d.event.start_mark, "missing anchor: '%s'", d.event.anchor))
 	}
 
+	d.recordAlias()
+	if d.maxAliasExpansion > 0 {
+		d.countExpansion(val)
+	}
+	if d.replay_events != nil {
+		// replaying a peeked document
+		val = append(append([]yaml_event_t(nil), val...), d.replay_events...)
+	}
 	d.replay_events = val
 	d.nextEvent()
-	d.parse(rv)
+}
+
+// recordAlias records the position of the current alias event.
+func (d *Decoder) recordAlias() {
+	anchor := string(d.event.anchor)
+	if d.nextAliases == nil {
+		d.nextAliases = make(map[string][]Position)
+	}
+	d.nextAliases[anchor] = append(d.nextAliases[anchor], Position{
+		Line:   d.event.start_mark.line + 1,
+		Column: d.event.start_mark.column + 1,
+	})
 }
 
 func (d *Decoder) valueInterface() interface{} {
 	var v interface{}
 
+	if nv := d.registeredValue(reflect.ValueOf(&v).Elem()); nv.IsValid() {
+		d.parse(nv)
+		return nv.Elem().Interface()
+	}
+
+	if d.taggedValue(reflect.ValueOf(&v).Elem()) {
+		return v
+	}
+
+	if d.deferNode(reflect.ValueOf(&v).Elem()) {
+		return v
+	}
+
 	anchor := string(d.event.anchor)
 	switch d.event.event_type {
 	case yaml_SEQUENCE_START_EVENT:
 		d.begin_anchor(anchor)
-		v = d.sequenceInterface()
+		d.depth++
+		if tag := string(d.event.tag); tag == yaml_OMAP_TAG || tag == yaml_PAIRS_TAG {
+			d.pairs(reflect.ValueOf(&v).Elem())
+		} else {
+			v = d.sequenceInterface()
+		}
+		d.depth--
 	case yaml_MAPPING_START_EVENT:
 		d.begin_anchor(anchor)
-		if d.mapType != nil {
+		d.depth++
+		if string(d.event.tag) == yaml_SET_TAG {
+			v = d.setInterface()
+		} else if d.mapType != nil {
 			subv := reflect.New(d.mapType).Elem()
 			d.mappingSlice(subv)
 			v = subv.Interface()
 		} else {
 			v = d.mappingInterface()
 		}
+		d.depth--
 	case yaml_SCALAR_EVENT:
 		d.begin_anchor(anchor)
 		v = d.scalarInterface()
@@ -655,8 +1649,8 @@ func (d *Decoder) valueInterface() interface{} {
 	case yaml_DOCUMENT_END_EVENT:
 		d.error(&UnexpectedEventError{
 			Value:     string(d.event.value),
-			EventType: d.event.event_type,
-			At:        d.event.start_mark,
+			EventType: EventKind(d.event.event_type),
+			At:        markOf(d.event.start_mark),
 		})
 
 	}
@@ -666,7 +1660,11 @@ func (d *Decoder) valueInterface() interface{} {
 }
 
 func (d *Decoder) scalarInterface() interface{} {
-	_, v := resolveInterface(d.event, d.useNumber)
+	tag, v := resolveValue(d.event, d.scalarValue(), d.useNumber)
+	if v != nil {
+		d.checkScalar(tag, reflect.ValueOf(v))
+		v = d.interfaceValue(tag, v)
+	}
 
 	d.nextEvent()
 	return v
@@ -685,7 +1683,9 @@ done:
 			break done
 		}
 
+		n := d.pushIndex(len(v))
 		v = append(v, d.valueInterface())
+		d.popPath(n)
 	}
 
 	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
@@ -708,10 +1708,17 @@ done:
 			break done
 		}
 
-		key := d.valueInterface()
+		d.checkKey()
+		at := d.event.start_mark
+		key := hashableKey(d.valueInterface())
+		if _, ok := m[key]; ok && d.onWarning != nil {
+			d.warn(DuplicateKey, at, "duplicate key '%v', of which the last value is kept", key)
+		}
 
 		// Read value.
+		n := d.pushValue(reflect.ValueOf(key))
 		m[key] = d.valueInterface()
+		d.popPath(n)
 	}
 
 	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
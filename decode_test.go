@@ -15,9 +15,11 @@ limitations under the License.
 package candiedyaml
 
 import (
-	"fmt"
+	"errors"
+	"io"
 	"math"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -183,8 +185,10 @@ default:
 
 					err := d.Decode(&v)
 					Expect(err).To(HaveOccurred())
-					expectedErrorString := fmt.Errorf("unable to map key \"avg\" to a struct field at line 3, column 8")
-					Expect(err).To(Equal(expectedErrorString))
+					Expect(err).To(MatchError(`[0]: unable to map key "avg" to a struct field at line 3, column 8`))
+					var decodeErr *DecodeError
+					Expect(errors.As(err, &decodeErr)).To(BeTrue())
+					Expect(decodeErr.Problem).To(Equal(`unable to map key "avg" to a struct field`))
 				})
 			})
 
@@ -317,6 +321,60 @@ default:
 		})
 	})
 
+	Context("Complex keys", func() {
+		It("Decodes sequence keys to arrays", func() {
+			f, _ := os.Open("fixtures/specification/example2_11.yaml")
+			d := NewDecoder(f)
+			var v map[[2]string][]string
+
+			err := d.Decode(&v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[[2]string][]string{
+				{"Detroit Tigers", "Chicago cubs"}:     {"2001-07-23"},
+				{"New York Yankees", "Atlanta Braves"}: {"2001-07-02", "2001-08-12", "2001-08-14"},
+			}))
+		})
+
+		It("Decodes mapping keys to structs", func() {
+			type point struct {
+				X, Y int
+			}
+			var v map[point]string
+
+			err := Unmarshal([]byte("? {X: 1, Y: 2}\n: a\n? {Y: 4, X: 3}\n: b\n"), &v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[point]string{{1, 2}: "a", {3, 4}: "b"}))
+		})
+
+		It("Decodes to ComplexKeys in interface{}s", func() {
+			var v interface{}
+
+			err := Unmarshal([]byte("? [a, b]\n: 1\n? {b: 2, a: [1, {c: 3}]}\n: 2\nd: 3\n"), &v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[interface{}]interface{}{
+				ComplexKey("[a, b]"):                 int64(1),
+				ComplexKey("{a: [1, {c: 3}], b: 2}"): int64(2),
+				"d":                                  int64(3),
+			}))
+		})
+
+		It("Decodes to ComplexKeys in maps with interface{} keys", func() {
+			var v map[interface{}]string
+
+			err := Unmarshal([]byte("? [x, 'y z']\n: a\n"), &v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[interface{}]string{ComplexKey("[x, y z]"): "a"}))
+		})
+
+		It("Unmarshals a ComplexKey to its value", func() {
+			var v []interface{}
+
+			err := Unmarshal([]byte(ComplexKey("[a, {b: 1}]")), &v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal([]interface{}{"a", map[interface{}]interface{}{"b": int64(1)}}))
+		})
+	})
+
 	Context("Sequence of Maps", func() {
 		It("Decodes to interface{}s", func() {
 			f, _ := os.Open("fixtures/specification/example2_4.yaml")
@@ -819,6 +877,37 @@ z: *b
 
 		})
 
+		It("decodes each alias into values of its own", func() {
+			d := NewDecoder(strings.NewReader(`
+---
+a: &a {b: [1, 2], c: {d: 3}}
+x: *a
+y: [*a]
+`))
+			var v map[string]interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+
+			a := v["a"].(map[interface{}]interface{})
+			a["b"].([]interface{})[0] = 9
+			a["c"].(map[interface{}]interface{})["d"] = 9
+			alias := map[interface{}]interface{}{
+				"b": []interface{}{int64(1), int64(2)},
+				"c": map[interface{}]interface{}{"d": int64(3)},
+			}
+			Expect(v["x"]).To(Equal(alias))
+			Expect(v["y"]).To(Equal([]interface{}{alias}))
+
+			var s struct {
+				A *struct{ B []int }
+				X *struct{ B []int }
+			}
+			d = NewDecoder(strings.NewReader("a: &a {b: [1, 2]}\nx: *a\n"))
+			Expect(d.Decode(&s)).To(Succeed())
+			Expect(s.X).NotTo(BeIdenticalTo(s.A))
+			s.A.B[0] = 9
+			Expect(s.X.B).To(Equal([]int{1, 2}))
+		})
+
 		It("redefinition while composing aliases", func() {
 			d := NewDecoder(strings.NewReader(`
 ---
@@ -838,6 +927,15 @@ y: *a
 
 		})
 
+		It("keeps the value of an anchor followed directly by an alias", func() {
+			var v interface{}
+			Expect(Unmarshal([]byte("[&a x, *a]"), &v)).To(Succeed())
+			Expect(v).To(Equal([]interface{}{"x", "x"}))
+
+			Expect(Unmarshal([]byte("- &a x\n- &b [*a]\n- *b\n"), &v)).To(Succeed())
+			Expect(v).To(Equal([]interface{}{"x", []interface{}{"x"}, []interface{}{"x"}}))
+		})
+
 		It("can parse nested anchors", func() {
 			d := NewDecoder(strings.NewReader(`
 ---
@@ -858,6 +956,557 @@ b:
 		})
 	})
 
+	Context("Anchors", func() {
+		It("reports anchors with their definitions and references", func() {
+			d := NewDecoder(strings.NewReader(`base: &base
+  a: 1
+name: &name bob
+x: *base
+y:
+  - *name
+  - *base
+`))
+			var v interface{}
+			err := d.Decode(&v)
+			Expect(err).NotTo(HaveOccurred())
+
+			anchors := d.Anchors()
+			Expect(anchors).To(HaveLen(2))
+
+			base := anchors["base"]
+			Expect(base.Name).To(Equal("base"))
+			Expect(base.Node.Kind).To(Equal(MappingNode))
+			Expect(base.Node.Anchor).To(Equal("base"))
+			Expect(base.Node.Line).To(Equal(1))
+			Expect(base.Node.Column).To(Equal(7))
+			Expect(base.Node.Content[1].Value).To(Equal("1"))
+			Expect(base.References).To(Equal([]Position{{Line: 4, Column: 4}, {Line: 7, Column: 5}}))
+
+			name := anchors["name"]
+			Expect(name.Node.Value).To(Equal("bob"))
+			Expect(name.Node.Line).To(Equal(3))
+			Expect(name.Node.Column).To(Equal(7))
+			Expect(name.References).To(Equal([]Position{{Line: 6, Column: 5}}))
+		})
+
+		It("reports the anchors of the last document only", func() {
+			d := NewDecoder(strings.NewReader("a: &a 1\nb: *a\nc: &c 3\n---\nx: &a 2\ny: [*a, *a]\n---\nz: 1\n"))
+			var v interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(d.Anchors()).To(HaveLen(2))
+			Expect(d.Anchors()["a"].References).To(Equal([]Position{{Line: 2, Column: 4}}))
+
+			Expect(d.Decode(&v)).To(Succeed())
+			anchors := d.Anchors()
+			Expect(anchors).To(HaveLen(1))
+			Expect(anchors["a"].Node.Value).To(Equal("2"))
+			Expect(anchors["a"].References).To(Equal([]Position{{Line: 6, Column: 5}, {Line: 6, Column: 9}}))
+
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(d.Anchors()).To(BeEmpty())
+		})
+
+		It("is empty when there are no anchors", func() {
+			d := NewDecoder(strings.NewReader("a: 1\n"))
+			var v interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(d.Anchors()).To(BeEmpty())
+		})
+
+		It("reports the usage of anchors without decoding", func() {
+			usages, err := AnchorUsages([]byte(`base: &base
+  a: 1
+unused: &unused 2
+x: *base
+base2: &base 3
+y: [*base, *base]
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(usages).To(Equal([]AnchorUsage{
+				{Name: "base", Definition: Position{1, 7}, References: []Position{{4, 4}}},
+				{Name: "unused", Definition: Position{3, 9}},
+				{Name: "base", Definition: Position{5, 8}, References: []Position{{6, 5}, {6, 12}}},
+			}))
+			Expect(usages[0].Unused()).To(BeFalse())
+			Expect(usages[1].Unused()).To(BeTrue())
+		})
+
+		It("reports aliases to missing anchors", func() {
+			_, err := AnchorUsages([]byte("a: *b\n"))
+			Expect(err).To(MatchError(ContainSubstring("missing anchor: 'b'")))
+		})
+
+		It("scopes anchors to their document", func() {
+			_, err := AnchorUsages([]byte("a: &x 1\n---\nb: *x\n"))
+			Expect(err).To(MatchError(ContainSubstring("missing anchor: 'x'")))
+
+			usages, err := AnchorUsages([]byte("a: &x 1\n---\nb: &x 2\nc: *x\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(usages).To(Equal([]AnchorUsage{
+				{Name: "x", Definition: Position{1, 4}},
+				{Name: "x", Definition: Position{3, 4}, References: []Position{{4, 4}}},
+			}))
+		})
+	})
+
+	Context("Empty documents", func() {
+		It("fails on a stream without documents by default", func() {
+			var v interface{}
+			err := NewDecoder(strings.NewReader("# nothing\n")).Decode(&v)
+			Expect(err).To(MatchError(ContainSubstring("Expected document start")))
+		})
+
+		It("returns io.EOF when asked to", func() {
+			d := NewDecoder(strings.NewReader("# nothing\n"))
+			d.EmptyDocuments(EmptyIsEOF)
+			v := 5
+			Expect(d.Decode(&v)).To(Equal(io.EOF))
+			Expect(d.Peek(&v)).To(Equal(io.EOF))
+			Expect(v).To(Equal(5))
+		})
+
+		It("stops at the end of the stream with io.EOF", func() {
+			d := NewDecoder(strings.NewReader("a\n---\nb\n"))
+			d.EmptyDocuments(EmptyIsEOF)
+			var docs []string
+			for {
+				var s string
+				err := d.Decode(&s)
+				if err == io.EOF {
+					break
+				}
+				Expect(err).NotTo(HaveOccurred())
+				docs = append(docs, s)
+			}
+			Expect(docs).To(Equal([]string{"a", "b"}))
+		})
+
+		It("decodes the zero value when asked to", func() {
+			type config struct{ Port int }
+			for _, input := range []string{"", "# nothing\n", "--- # nothing\n", "---\n...\n"} {
+				d := NewDecoder(strings.NewReader(input))
+				d.EmptyDocuments(EmptyIsZero)
+				v := config{Port: 80}
+				Expect(d.Decode(&v)).To(Succeed())
+				Expect(v).To(Equal(config{}))
+
+				n := 5
+				Expect(d.Decode(&n)).To(Succeed())
+				Expect(n).To(BeZero())
+			}
+		})
+	})
+
+	Context("Null values", func() {
+		type server struct {
+			Host  string
+			Port  int
+			Proxy *string
+			Tags  []string
+		}
+		type config struct {
+			Servers []server
+		}
+		doc := `servers:
+- host: a
+  port: ~
+  proxy: null
+  tags:
+`
+
+		It("sets non-nullable values to zero by default", func() {
+			var c config
+			Expect(Unmarshal([]byte(doc), &c)).To(Succeed())
+			Expect(c.Servers).To(Equal([]server{{Host: "a"}}))
+		})
+
+		It("rejects nulls for non-nullable values", func() {
+			d := NewDecoder(strings.NewReader(doc))
+			d.NullValues(NullIsError)
+			var c config
+			err := d.Decode(&c)
+			Expect(err).To(MatchError(`servers[0].port: Cannot decode a null into the int at line 2, column 8`))
+		})
+
+		It("rejects empty values", func() {
+			d := NewDecoder(strings.NewReader("host:\n"))
+			d.NullValues(NullIsError)
+			var s server
+			Expect(d.Decode(&s)).To(MatchError(ContainSubstring(`host: Cannot decode a null into the string`)))
+		})
+
+		It("accepts nulls for nullable values", func() {
+			d := NewDecoder(strings.NewReader("proxy: ~\ntags:\n"))
+			d.NullValues(NullIsError)
+			s := server{Tags: []string{"x"}}
+			Expect(d.Decode(&s)).To(Succeed())
+			Expect(s.Proxy).To(BeNil())
+			Expect(s.Tags).To(BeNil())
+		})
+
+		It("rejects a null document for a non-nullable value", func() {
+			d := NewDecoder(strings.NewReader("~\n"))
+			d.NullValues(NullIsError)
+			var n int
+			Expect(d.Decode(&n)).To(MatchError("Cannot decode a null into the int at line 0, column 0"))
+		})
+	})
+
+	Context("Field paths", func() {
+		type port struct {
+			Port int8
+		}
+		type container struct {
+			Ports []port
+		}
+		type spec struct {
+			Containers map[string][]container
+		}
+
+		It("names the value that failed to decode", func() {
+			var v spec
+			err := Unmarshal([]byte("containers:\n  web:\n  - ports: [{port: 80}, {port: 300}]\n"), &v)
+			Expect(err).To(MatchError("containers.web[0].ports[1].port: Invalid integer: '300' at line 2, column 31"))
+
+			var fieldErr *FieldError
+			Expect(errors.As(err, &fieldErr)).To(BeTrue())
+			Expect(fieldErr.Path).To(Equal("containers.web[0].ports[1].port"))
+		})
+
+		It("names the values by their keys as written", func() {
+			var v struct {
+				Server struct {
+					Port int `yaml:"listen_port"`
+				}
+			}
+			err := Unmarshal([]byte("SERVER:\n  listen_port: notanumber\n"), &v)
+			Expect(err).To(MatchError("SERVER.listen_port: Invalid integer: 'notanumber' at line 1, column 15"))
+		})
+
+		It("leaves out the path of syntax errors", func() {
+			var v spec
+			err := Unmarshal([]byte("containers:\n  web: [\n"), &v)
+			Expect(err).To(BeAssignableToTypeOf(&ParserError{}))
+		})
+	})
+
+	Context("Null pointers", func() {
+		type patch struct {
+			Name  *string
+			Port  *int
+			Proxy **string
+		}
+
+		It("tells absent keys from nulls with pointers to pointers", func() {
+			var p patch
+			Expect(Unmarshal([]byte("port: 1\nproxy: ~\n"), &p)).To(Succeed())
+			Expect(p.Name).To(BeNil())
+			Expect(*p.Port).To(Equal(1))
+			Expect(p.Proxy).NotTo(BeNil())
+			Expect(*p.Proxy).To(BeNil())
+
+			p = patch{}
+			Expect(Unmarshal([]byte("port: 1\n"), &p)).To(Succeed())
+			Expect(p.Proxy).To(BeNil())
+		})
+
+		It("sets pointers to nil for nulls by default", func() {
+			name, port := "a", 1
+			p := patch{Name: &name, Port: &port}
+			Expect(Unmarshal([]byte("name:\nport: null\n"), &p)).To(Succeed())
+			Expect(p.Name).To(BeNil())
+			Expect(p.Port).To(BeNil())
+		})
+
+		It("leaves pointers of absent keys untouched", func() {
+			name := "a"
+			p := patch{Name: &name}
+			Expect(Unmarshal([]byte("port: 1\n"), &p)).To(Succeed())
+			Expect(p.Name).To(Equal(&name))
+		})
+
+		It("sets pointers to zero values for nulls when asked to", func() {
+			d := NewDecoder(strings.NewReader("name: ~\nport:\n"))
+			d.PointerNulls(ZeroOnNull)
+			var p patch
+			Expect(d.Decode(&p)).To(Succeed())
+			Expect(p.Name).To(Equal(new(string)))
+			Expect(p.Port).To(Equal(new(int)))
+			Expect(p.Proxy).To(BeNil())
+		})
+	})
+
+	Context("Depth limit", func() {
+		doc := `kind: route
+spec:
+  rules:
+  - host: a
+tags: [x, z]
+`
+
+		It("decodes deeper collections as Nodes", func() {
+			d := NewDecoder(strings.NewReader(doc))
+			d.DepthLimit(1)
+			var v map[string]interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+
+			Expect(v["kind"]).To(Equal("route"))
+			spec, ok := v["spec"].(*Node)
+			Expect(ok).To(BeTrue())
+			Expect(spec.Kind).To(Equal(MappingNode))
+			Expect(spec.Line).To(Equal(3))
+			Expect(spec.Content[0].Value).To(Equal("rules"))
+			Expect(spec.Content[1].Content[0].Content[1].Value).To(Equal("a"))
+
+			tags, ok := v["tags"].(*Node)
+			Expect(ok).To(BeTrue())
+			Expect(tags.Flow).To(BeTrue())
+			Expect(tags.Content).To(HaveLen(2))
+		})
+
+		It("counts the levels of typed values", func() {
+			var v struct {
+				Kind string
+				Spec map[string]interface{}
+			}
+			d := NewDecoder(strings.NewReader(doc))
+			d.DepthLimit(2)
+			Expect(d.Decode(&v)).To(Succeed())
+			rules, ok := v.Spec["rules"].(*Node)
+			Expect(ok).To(BeTrue())
+			Expect(rules.Kind).To(Equal(SequenceNode))
+		})
+
+		It("builds every level by default", func() {
+			var v interface{}
+			Expect(NewDecoder(strings.NewReader(doc)).Decode(&v)).To(Succeed())
+			Expect(v).To(HaveKeyWithValue("tags", []interface{}{"x", "z"}))
+		})
+	})
+
+	Context("String keys only", func() {
+		decode := func(doc string, v interface{}) error {
+			d := NewDecoder(strings.NewReader(doc))
+			d.StringKeys(true)
+			return d.Decode(v)
+		}
+
+		It("accepts string keys", func() {
+			var v map[string]int
+			err := decode("a: 1\n'1': 2\n!!str true: 3\n", &v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[string]int{"a": 1, "1": 2, "true": 3}))
+		})
+
+		It("rejects scalar keys that are not strings", func() {
+			var v interface{}
+			err := decode("a: 1\nb:\n  1: x\n", &v)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("b: Expected a string key but was '1' at line 2, column 2"))
+
+			Expect(decode("true: x\n", &v)).NotTo(Succeed())
+			Expect(decode("~: x\n", &v)).NotTo(Succeed())
+			Expect(decode("2001-01-01: x\n", &v)).NotTo(Succeed())
+		})
+
+		It("rejects collection keys", func() {
+			var v map[string]interface{}
+			err := decode("? [a, b]\n: x\n", &v)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Expected a string key but was a sequence at line 0, column 2"))
+		})
+
+		It("checks aliased keys", func() {
+			var v interface{}
+			err := decode("a: &k 1\n*k : x\n", &v)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected a string key but was '1'"))
+		})
+
+		It("checks keys decoded into structs", func() {
+			var v struct{ A string }
+			Expect(decode("1: x\n", &v)).NotTo(Succeed())
+		})
+
+		It("allows any keys by default", func() {
+			var v interface{}
+			Expect(Unmarshal([]byte("1: x\n"), &v)).To(Succeed())
+		})
+	})
+
+	Context("Struct field names", func() {
+		It("prefers exact names to names in another case", func() {
+			var v struct {
+				Name  string
+				NAme  string
+				Other string `yaml:"oTHER"`
+			}
+			Expect(Unmarshal([]byte("NAme: a\nname: b\nother: c\n"), &v)).To(Succeed())
+			Expect(v.NAme).To(Equal("a"))
+			Expect(v.Name).To(Equal("b"))
+			Expect(v.Other).To(Equal("c"))
+		})
+
+		It("folds names as Unicode does", func() {
+			var v struct{ Kelvin, Class int }
+			Expect(Unmarshal([]byte("\u212aelvin: 1\ncla\u017f\u017f: 2\n"), &v)).To(Succeed())
+			Expect(v.Kelvin).To(Equal(1))
+			Expect(v.Class).To(Equal(2))
+		})
+	})
+
+	Context("Skipping documents", func() {
+		It("discards documents of any shape", func() {
+			d := NewDecoder(strings.NewReader("a: [1, {b: c}]\n---\n- &x 1\n- *x\n---\n---\nlast\n"))
+			Expect(d.Skip()).To(Succeed())
+			Expect(d.Skip()).To(Succeed())
+			Expect(d.Skip()).To(Succeed())
+
+			var v string
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(v).To(Equal("last"))
+			Expect(d.Skip()).NotTo(Succeed())
+		})
+
+		It("reports syntax errors", func() {
+			d := NewDecoder(strings.NewReader("a: [1\n"))
+			Expect(d.Skip()).NotTo(Succeed())
+		})
+	})
+
+	Context("Decoding selected fields", func() {
+		doc := `apiVersion: v1
+kind: Pod
+spec:
+  containers: &c [{name: a}, {name: b}]
+  labels: {x: y}
+? [complex, key]
+: ignored
+metadata:
+  name: web
+  containers: *c
+---
+kind: Service
+`
+
+		It("binds only the requested keys", func() {
+			d := NewDecoder(strings.NewReader(doc))
+			var kind string
+			var meta struct {
+				Name       string
+				Containers []map[string]string
+			}
+			Expect(d.DecodeFields(map[string]interface{}{"kind": &kind, "metadata": &meta})).To(Succeed())
+			Expect(kind).To(Equal("Pod"))
+			Expect(meta.Name).To(Equal("web"))
+			Expect(meta.Containers).To(Equal([]map[string]string{{"name": "a"}, {"name": "b"}}))
+
+			var missing int
+			Expect(d.DecodeFields(map[string]interface{}{"kind": &kind, "replicas": &missing})).To(Succeed())
+			Expect(kind).To(Equal("Service"))
+			Expect(missing).To(Equal(0))
+		})
+
+		It("requires a mapping", func() {
+			d := NewDecoder(strings.NewReader("- a\n"))
+			err := d.DecodeFields(map[string]interface{}{})
+			Expect(err).To(MatchError("Expected a mapping at line 0, column 0"))
+		})
+
+		It("requires pointers", func() {
+			d := NewDecoder(strings.NewReader(doc))
+			Expect(d.DecodeFields(map[string]interface{}{"kind": ""})).NotTo(Succeed())
+		})
+	})
+
+	Context("Single documents", func() {
+		It("decodes a document alone", func() {
+			var v map[string]int
+			Expect(UnmarshalOne([]byte("---\na: 1\n...\n# the end\n"), &v)).To(Succeed())
+			Expect(v).To(Equal(map[string]int{"a": 1}))
+		})
+
+		It("rejects another document", func() {
+			var v map[string]int
+			err := UnmarshalOne([]byte("a: 1\n---\nb: 2\n"), &v)
+			Expect(err).To(MatchError("Expected a single document but found another at line 1, column 0"))
+
+			Expect(Unmarshal([]byte("a: 1\n---\nb: 2\n"), &v)).To(Succeed())
+		})
+
+		It("rejects an empty document after it", func() {
+			var v map[string]int
+			Expect(UnmarshalOne([]byte("a: 1\n--- # empty\n"), &v)).To(MatchError(ContainSubstring("single document")))
+		})
+
+		It("rejects content after the end of the document", func() {
+			var v map[string]int
+			Expect(UnmarshalOne([]byte("a: 1\n...\nb: 2\n"), &v)).To(MatchError(ContainSubstring("did not find expected <document start>")))
+		})
+	})
+
+	Context("Peeking at documents", func() {
+		type header struct{ Kind string }
+		type pod struct {
+			Kind  string
+			Names []string
+		}
+
+		It("decodes the same document again", func() {
+			d := NewDecoder(strings.NewReader("kind: pod\nnames: [&a x, *a, y]\n---\nkind: other\n"))
+
+			var h header
+			Expect(d.Peek(&h)).To(Succeed())
+			Expect(h.Kind).To(Equal("pod"))
+			Expect(d.Peek(&h)).To(Succeed())
+
+			var p pod
+			Expect(d.Decode(&p)).To(Succeed())
+			Expect(p).To(Equal(pod{Kind: "pod", Names: []string{"x", "x", "y"}}))
+			Expect(d.Anchors()["a"].References).To(HaveLen(1))
+
+			Expect(d.Peek(&h)).To(Succeed())
+			Expect(h.Kind).To(Equal("other"))
+			var rest map[string]string
+			Expect(d.Decode(&rest)).To(Succeed())
+			Expect(rest).To(Equal(map[string]string{"kind": "other"}))
+			Expect(d.Peek(&h)).NotTo(Succeed())
+		})
+
+		It("leaves the document in place when decoding it fails", func() {
+			d := NewDecoder(strings.NewReader("kind: [a]\n"))
+
+			var h header
+			Expect(d.Peek(&h)).NotTo(Succeed())
+
+			var v map[string][]string
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(v).To(Equal(map[string][]string{"kind": {"a"}}))
+		})
+	})
+
+	Context("Reset", func() {
+		It("reads a new input with the same options", func() {
+			d := NewDecoder(strings.NewReader("a: &x 1\n---\nb: 2\n"))
+			d.StrictMode(true)
+			var v map[string]int
+			Expect(d.Decode(&v)).To(Succeed())
+
+			d.Reset(strings.NewReader("a: *x\n"))
+			Expect(d.Decode(&v)).To(MatchError(ContainSubstring("missing anchor")))
+
+			d.Reset(strings.NewReader("a: 3\nc: 4\n"))
+			var s struct{ A int }
+			Expect(d.Decode(&s)).To(MatchError(ContainSubstring("unable to map key \"c\"")))
+			Expect(s.A).To(Equal(3))
+
+			d.Reset(strings.NewReader("[1, 2]\n"))
+			var n []int
+			Expect(d.Decode(&n)).To(Succeed())
+			Expect(n).To(Equal([]int{1, 2}))
+		})
+	})
+
 	Context("When decoding fails", func() {
 		It("returns an error", func() {
 			f, _ := os.Open("fixtures/specification/example_empty.yaml")
@@ -870,6 +1519,49 @@ b:
 		})
 	})
 
+	Context("Input encodings", func() {
+		It("decodes UTF-16LE with a BOM", func() {
+			d := NewDecoder(strings.NewReader("\xff\xfek\x00:\x00 \x00\xe9\x00\xe5\x65\n\x00"))
+			var v map[string]string
+
+			err := d.Decode(&v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[string]string{"k": "é日"}))
+		})
+
+		It("decodes UTF-16BE without a BOM", func() {
+			d := NewDecoder(strings.NewReader("\x00k\x00:\x00 \x00v\x00\n"))
+			var v map[string]string
+
+			err := d.Decode(&v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[string]string{"k": "v"}))
+		})
+
+		It("rejects UTF-32", func() {
+			d := NewDecoder(strings.NewReader("\xff\xfe\x00\x00k\x00\x00\x00"))
+			var v interface{}
+
+			err := d.Decode(&v)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("UTF-32 input is not supported"))
+		})
+
+		It("rejects invalid UTF-8 at its exact offset", func() {
+			for input, message := range map[string]string{
+				"a: b\nc: d\xffe\n":    "yaml: invalid leading UTF-8 octet #xFF at byte offset 9",
+				"a: \xc3\x28\n":        "yaml: invalid trailing UTF-8 octet #x28 at byte offset 4",
+				"# \xed\xa0\x80\na: 1": "yaml: invalid Unicode character #xD800 at byte offset 2",
+				"a: \xe2\x82":          "yaml: incomplete UTF-8 octet sequence at byte offset 3",
+			} {
+				var v interface{}
+				err := Unmarshal([]byte(input), &v)
+				Expect(err).To(MatchError(message))
+				Expect(err.(*ParserError).Offset).To(BeNumerically(">", 0))
+			}
+		})
+	})
+
 	Context("Unmarshaler support", func() {
 		Context("Receiver is a value", func() {
 			It("the Marshaler interface is not used", func() {
@@ -967,6 +1659,158 @@ b:
 			Expect(n.String()).To(Equal("123"))
 		})
 	})
+
+	Context("Decodes into an IntString", func() {
+		It("keeps the original literal", func() {
+			d := NewDecoder(strings.NewReader("mode: 0o755\nmask: 0xFF\ncount: 12\n"))
+			var v map[string]IntString
+
+			err := d.Decode(&v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v["mode"]).To(Equal(IntString("0o755")))
+			Expect(v["mode"].Base()).To(Equal(8))
+			Expect(v["mask"].Base()).To(Equal(16))
+			Expect(v["count"].Base()).To(Equal(10))
+
+			i, err := v["mask"].Int64()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(i).To(Equal(int64(255)))
+		})
+
+		It("fails when the value is not an integer", func() {
+			d := NewDecoder(strings.NewReader("1.5\n"))
+			var v IntString
+
+			err := d.Decode(&v)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(MatchRegexp("Invalid integer: '1.5' at line 0, column 0"))
+		})
+	})
+
+	Context("Single elements", func() {
+		type service struct {
+			Name   string
+			Hosts  []string
+			Ports  [2]int
+			Region string
+			More   []string
+		}
+		doc := `name: [web]
+hosts: &h a.example.com
+ports: 80
+region: [eu]
+`
+
+		It("keeps the shape of values by default", func() {
+			var s service
+			err := Unmarshal([]byte(doc), &s)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("wraps scalars in slices and arrays", func() {
+			d := NewDecoder(strings.NewReader("hosts: a.example.com\nports: 80\nname: ~\n"))
+			d.SingleElements(WrapScalars)
+			var s service
+			Expect(d.Decode(&s)).To(Succeed())
+			Expect(s).To(Equal(service{Hosts: []string{"a.example.com"}, Ports: [2]int{80, 0}}))
+		})
+
+		It("collapses sequences of one element", func() {
+			d := NewDecoder(strings.NewReader(doc + "more: *h\n"))
+			d.SingleElements(WrapAndCollapse)
+			var s service
+			Expect(d.Decode(&s)).To(Succeed())
+			Expect(s).To(Equal(service{
+				Name:   "web",
+				Hosts:  []string{"a.example.com"},
+				Ports:  [2]int{80, 0},
+				Region: "eu",
+				More:   []string{"a.example.com"},
+			}))
+		})
+
+		It("rejects sequences of several elements", func() {
+			d := NewDecoder(strings.NewReader("name: [a, b]\n"))
+			d.SingleElements(WrapAndCollapse)
+			var s service
+			Expect(d.Decode(&s)).To(MatchError("name: Cannot decode a sequence of more than one element into the string at line 0, column 6"))
+		})
+	})
+
+	Context("DecodeValue", func() {
+		type server struct {
+			Host string
+			Port int
+		}
+
+		It("decodes into values that can be set", func() {
+			v := reflect.New(reflect.TypeOf(server{})).Elem()
+			d := NewDecoder(strings.NewReader("host: example.com\nport: '8080'\n"))
+			Expect(d.DecodeValue(v)).To(Succeed())
+			Expect(v.Interface()).To(Equal(server{Host: "example.com", Port: 8080}))
+		})
+
+		It("sets pointers that can be set, and decodes through the others", func() {
+			var config struct{ Primary *server }
+			field := reflect.ValueOf(&config).Elem().Field(0)
+			Expect(NewDecoder(strings.NewReader("port: 80\n")).DecodeValue(field)).To(Succeed())
+			Expect(config.Primary).To(Equal(&server{Port: 80}))
+
+			s := &server{Host: "kept"}
+			Expect(NewDecoder(strings.NewReader("port: 81\n")).DecodeValue(reflect.ValueOf(s))).To(Succeed())
+			Expect(s).To(Equal(&server{Host: "kept", Port: 81}))
+		})
+
+		It("rejects values that cannot be set", func() {
+			err := NewDecoder(strings.NewReader("port: 80\n")).DecodeValue(reflect.ValueOf(server{}))
+			Expect(err).To(MatchError(ContainSubstring("Expected a pointer or nil but was a <candiedyaml.server Value>")))
+		})
+	})
+
+	Context("Raw YAML", func() {
+		type settings struct {
+			Plugins map[string]RawMessage `yaml:"plugins"`
+		}
+
+		It("captures the text of each value for later decoding", func() {
+			var s settings
+			err := Unmarshal([]byte("plugins:\n  auth:\n    realm: ops\n    users: [ann, bob]\n  cache: 'off'\n  none:\n"), &s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.Plugins).To(Equal(map[string]RawMessage{
+				"auth":  RawMessage("realm: ops\nusers: [ann, bob]\n"),
+				"cache": RawMessage("'off'\n"),
+				"none":  RawMessage("null\n"),
+			}))
+
+			var auth struct {
+				Realm string
+				Users []string
+			}
+			Expect(Unmarshal(s.Plugins["auth"], &auth)).To(Succeed())
+			Expect(auth.Users).To(Equal([]string{"ann", "bob"}))
+		})
+
+		It("replaces aliases to anchors outside the value", func() {
+			var v struct {
+				Base  map[string]int
+				Value RawYAML
+			}
+			err := Unmarshal([]byte("base: &b {x: 1}\nvalue: [*b, &c 2, *c]\n"), &v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(v.Value)).To(Equal("[{x: 1}, &c 2, *c]\n"))
+		})
+
+		It("splices the captured values back when encoding", func() {
+			input := "plugins:\n  auth:\n    realm: ops\n  cache: 'off'\n"
+			var s settings
+			Expect(Unmarshal([]byte(input), &s)).To(Succeed())
+
+			out, err := Marshal(s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal(input))
+		})
+	})
+
 	Context("When there are special characters", func() {
 		It("returns an error", func() {
 			d := NewDecoder(strings.NewReader(`
@@ -0,0 +1,157 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "strconv"
+
+// A ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota + 1
+	Removed
+	Modified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "change " + strconv.Itoa(int(k))
+}
+
+func init() {
+	RegisterEnum(Added, Removed, Modified)
+}
+
+// A Change is a difference between two values found by Diff.
+type Change struct {
+	// Path locates the value, in the form of the paths of Positions,
+	// e.g. "servers[0].port".
+	Path string `yaml:"path"`
+
+	Kind ChangeKind `yaml:"change"`
+
+	// Old and New are the value before and after the change; Old is nil
+	// when the value was added and New when it was removed.
+	Old *Node `yaml:"old,omitempty"`
+	New *Node `yaml:"new,omitempty"`
+}
+
+// Diff marshals a and b and returns the changes that turn the YAML of a
+// into that of b, in the order of the keys and elements of a and then of
+// the values only b has. Scalars are compared as EqualDocuments does
+// under DefaultSchema, so that a value written in another way is not a
+// change, and elements of sequences are compared by their index.
+//
+// The changes marshal to a report of a mapping for each change, with the
+// path, the kind and the old and new values, such as
+// {path: servers[0].port, change: modified, old: 80, new: 8080}.
+func Diff(a, b interface{}) (changes []Change, err error) {
+	defer recovery(&err)
+
+	before, err := diffDocument(a)
+	if err != nil {
+		return nil, err
+	}
+	after, err := diffDocument(b)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &differ{}
+	d.diff(before, after)
+	return d.changes, nil
+}
+
+// diffDocument marshals v and decodes the result as a Node.
+func diffDocument(v interface{}) (*Node, error) {
+	src, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return composeNodes(src)[0], nil
+}
+
+// the changes found by Diff, and the path of the values being compared
+type differ struct {
+	comparison
+	changes []Change
+}
+
+func (d *differ) change(kind ChangeKind, before, after *Node) {
+	d.changes = append(d.changes, Change{Path: string(d.path), Kind: kind, Old: before, New: after})
+}
+
+func (d *differ) diff(before, after *Node) {
+	before, after = target(before), target(after)
+	if before.Kind != after.Kind || collectionTag(before) != collectionTag(after) {
+		d.change(Modified, before, after)
+		return
+	}
+
+	n := len(d.path)
+	switch before.Kind {
+	case ScalarNode:
+		if d.scalar(before) != d.scalar(after) {
+			d.change(Modified, before, after)
+		}
+	case SequenceNode:
+		for i := 0; i < len(before.Content) || i < len(after.Content); i++ {
+			d.path = append(d.path, '[')
+			d.path = strconv.AppendInt(d.path, int64(i), 10)
+			d.path = append(d.path, ']')
+			switch {
+			case i >= len(after.Content):
+				d.change(Removed, before.Content[i], nil)
+			case i >= len(before.Content):
+				d.change(Added, nil, after.Content[i])
+			default:
+				d.diff(before.Content[i], after.Content[i])
+			}
+			d.path = d.path[:n]
+		}
+	case MappingNode:
+		keysBefore, keysAfter := d.keys(before), d.keys(after)
+		for i := 0; i < len(before.Content); i += 2 {
+			key := d.canonical(before.Content[i])
+			if keysBefore[key] != i {
+				// the value of a duplicate key is the last one
+				continue
+			}
+			d.pushKey(before.Content[i])
+			if j, ok := keysAfter[key]; ok {
+				d.diff(before.Content[i+1], after.Content[j+1])
+			} else {
+				d.change(Removed, before.Content[i+1], nil)
+			}
+			d.path = d.path[:n]
+		}
+		for i := 0; i < len(after.Content); i += 2 {
+			key := d.canonical(after.Content[i])
+			if _, ok := keysBefore[key]; ok || keysAfter[key] != i {
+				continue
+			}
+			d.pushKey(after.Content[i])
+			d.change(Added, nil, after.Content[i+1])
+			d.path = d.path[:n]
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+	type server struct {
+		Host string
+		Port int
+	}
+	type config struct {
+		Name    string
+		Servers []server
+		Labels  map[string]string
+	}
+
+	before := config{
+		Name:    "api",
+		Servers: []server{{"a", 80}, {"b", 80}},
+		Labels:  map[string]string{"team": "core", "tier": "1"},
+	}
+
+	paths := func(changes []Change) map[string]ChangeKind {
+		m := make(map[string]ChangeKind)
+		for _, c := range changes {
+			m[c.Path] = c.Kind
+		}
+		return m
+	}
+
+	It("finds no changes between equal values", func() {
+		changes, err := Diff(before, before)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+
+	It("finds the paths of added, removed and modified values", func() {
+		after := config{
+			Name:    "api",
+			Servers: []server{{"a", 8080}},
+			Labels:  map[string]string{"team": "core", "zone": "eu"},
+		}
+		changes, err := Diff(before, after)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths(changes)).To(Equal(map[string]ChangeKind{
+			"Servers[0].Port": Modified,
+			"Servers[1]":      Removed,
+			"Labels.tier":     Removed,
+			"Labels.zone":     Added,
+		}))
+	})
+
+	It("compares the values the YAML holds", func() {
+		changes, err := Diff(map[string]interface{}{"n": 1.0}, map[string]interface{}{"n": 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+
+		changes, err = Diff([]interface{}{"1"}, []interface{}{1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths(changes)).To(Equal(map[string]ChangeKind{"[0]": Modified}))
+	})
+
+	It("reports a change of the kind of a value", func() {
+		changes, err := Diff(map[string]interface{}{"a": []int{1}}, map[string]interface{}{"a": 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(HaveLen(1))
+		Expect(changes[0].Old.Kind).To(Equal(SequenceNode))
+		Expect(changes[0].New.Kind).To(Equal(ScalarNode))
+	})
+
+	It("marshals a report of the changes", func() {
+		after := before
+		after.Servers = []server{{"a", 8080}, {"b", 80}, {"c", 80}}
+		changes, err := Diff(before, after)
+		Expect(err).NotTo(HaveOccurred())
+
+		report, err := Marshal(changes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(report)).To(Equal(`- path: Servers[0].Port
+  change: modified
+  old: 80
+  new: 8080
+- path: Servers[2]
+  change: added
+  new:
+    Host: c
+    Port: 80
+`))
+	})
+
+	It("returns the errors of marshaling the values", func() {
+		_, err := Diff(make(chan int), 1)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,172 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// A TagDirective is a %TAG directive, binding a handle such as "!e!" to the
+// prefix of the tags written with it.
+type TagDirective struct {
+	Handle, Prefix string
+}
+
+// DocumentInfo describes the directives and markers of a document.
+type DocumentInfo struct {
+	// Version is the version given by the %YAML directive, such as "1.1",
+	// or "" when there is none.
+	Version string
+
+	// TagDirectives are the %TAG directives of the document, in order.
+	TagDirectives []TagDirective
+
+	// ImplicitStart is true when the "---" marker is omitted, and
+	// ImplicitEnd when the "..." marker is.
+	ImplicitStart, ImplicitEnd bool
+}
+
+// DocumentInfo returns the directives and markers of the last document
+// read by Decode, Skip, DecodeFields or Peek, or the zero DocumentInfo
+// before any was read.
+func (d *Decoder) DocumentInfo() DocumentInfo {
+	return d.documentInfo
+}
+
+// A DocumentSelector returns the value that a document is decoded into,
+// which must be a pointer, given what is known when the document starts:
+// its directives and start marker, and the explicit tag of its root node,
+// in full, or "" when it has none. Returning nil skips the document.
+type DocumentSelector func(info DocumentInfo, tag string) (interface{}, error)
+
+// DecodeSelected decodes the next document into the value that selector
+// returns for it, and returns that value, so that the documents of a
+// stream holding several kinds of them are each decoded into their own
+// type in a single pass. It returns nil when the document was skipped, and
+// io.EOF at the end of the stream. An error returned by selector is
+// returned as it is. The tag of the root is not checked against the
+// UnknownTags policy, as the selector dealt with it.
+func (d *Decoder) DecodeSelected(selector DocumentSelector) (v interface{}, err error) {
+	defer recovery(&err)
+
+	d.start()
+	if d.event.event_type == yaml_STREAM_END_EVENT {
+		return nil, io.EOF
+	}
+	if d.event.event_type != yaml_DOCUMENT_START_EVENT {
+		return nil, composingError(d.event.start_mark, "Expected document start")
+	}
+	d.nextEvent()
+
+	v, err = selector(d.nextDocumentInfo, explicitTag(&d.event))
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		if d.event.event_type != yaml_DOCUMENT_END_EVENT {
+			d.skip()
+		}
+		d.dropSkipped()
+		if d.event.event_type != yaml_DOCUMENT_END_EVENT {
+			return nil, composingError(d.event.start_mark, "Expected document end")
+		}
+		d.nextEvent()
+		return nil, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, fmt.Errorf("Expected a pointer or nil but was a %s at %s", rv.String(), d.event.start_mark)
+	}
+
+	// the selector handled the tag of the root, known or not
+	d.tagChecked = true
+	d.documentContent(rv)
+	d.tagChecked = false
+	return v, nil
+}
+
+// A DocumentStartPolicy selects which documents of a stream may omit the
+// "---" marker that starts a document.
+type DocumentStartPolicy int
+
+const (
+	// ImplicitFirstDocument lets only the first document omit the marker,
+	// as YAML 1.1 does.
+	ImplicitFirstDocument DocumentStartPolicy = iota
+	// ExplicitDocuments requires every document to start with "---".
+	ExplicitDocuments
+	// ImplicitDocuments also lets the documents following a "..." marker
+	// omit it, as YAML 1.2 does.
+	ImplicitDocuments
+)
+
+// DocumentStarts sets which documents may omit the "---" marker, e.g. to
+// reject bare content where a stream has to mark each of its documents.
+// A document without the marker is an error when it is not allowed.
+func (d *Decoder) DocumentStarts(policy DocumentStartPolicy) {
+	d.documentStarts = policy
+	d.parser.implicit_documents = policy == ImplicitDocuments
+}
+
+// An UnknownDirectivePolicy selects how the Decoder handles directives
+// other than %YAML and %TAG, which YAML reserves for future use.
+type UnknownDirectivePolicy int
+
+const (
+	// IgnoreUnknownDirectives skips them.
+	IgnoreUnknownDirectives UnknownDirectivePolicy = iota
+	// WarnOnUnknownDirectives skips them, reporting each as a Warning of
+	// the UnknownDirective kind.
+	WarnOnUnknownDirectives
+	// ErrorOnUnknownDirectives fails the parsing.
+	ErrorOnUnknownDirectives
+)
+
+// UnknownDirectives sets how the Decoder handles unknown directives. The
+// default is IgnoreUnknownDirectives.
+func (d *Decoder) UnknownDirectives(policy UnknownDirectivePolicy) {
+	d.parser.reject_unknown_directives = policy == ErrorOnUnknownDirectives
+	d.parser.keep_unknown_directives = policy == WarnOnUnknownDirectives
+}
+
+// recordDocumentInfo keeps the directives of a document start event, and
+// the anchors and aliases of the document, until its document ends, as the
+// event following a document is read before decoding it returns.
+func (d *Decoder) recordDocumentInfo() {
+	switch d.event.event_type {
+	case yaml_DOCUMENT_START_EVENT:
+		if d.event.implicit && d.documentStarts == ExplicitDocuments {
+			d.error(composingError(d.event.start_mark, "Expected a document start '---'"))
+		}
+		info := DocumentInfo{ImplicitStart: d.event.implicit}
+		if v := d.event.version_directive; v != nil {
+			info.Version = strconv.Itoa(v.major) + "." + strconv.Itoa(v.minor)
+		}
+		for _, t := range d.event.tag_directives {
+			info.TagDirectives = append(info.TagDirectives, TagDirective{string(t.handle), string(t.prefix)})
+		}
+		d.nextDocumentInfo = info
+		d.nextAnchors, d.nextAliases = nil, nil
+	case yaml_DOCUMENT_END_EVENT:
+		d.documentInfo = d.nextDocumentInfo
+		d.documentInfo.ImplicitEnd = d.event.implicit
+		d.documentAnchors, d.aliases = d.nextAnchors, d.nextAliases
+	}
+}
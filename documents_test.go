@@ -0,0 +1,213 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DocumentInfo", func() {
+	input := `%YAML 1.1
+%TAG !e! tag:example.com,2000:
+---
+a: 1
+...
+--- b
+---
+c: 3
+`
+
+	It("describes the directives and markers of each document", func() {
+		d := NewDecoder(strings.NewReader(input))
+		Expect(d.DocumentInfo()).To(Equal(DocumentInfo{}))
+
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(d.DocumentInfo()).To(Equal(DocumentInfo{
+			Version:       "1.1",
+			TagDirectives: []TagDirective{{"!e!", "tag:example.com,2000:"}},
+		}))
+
+		Expect(d.Skip()).To(Succeed())
+		Expect(d.DocumentInfo()).To(Equal(DocumentInfo{ImplicitEnd: true}))
+
+		Expect(d.Peek(&v)).To(Succeed())
+		Expect(d.DocumentInfo()).To(Equal(DocumentInfo{ImplicitEnd: true}))
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(d.DocumentInfo()).To(Equal(DocumentInfo{ImplicitEnd: true}))
+	})
+
+	It("forgets the documents read before a reset", func() {
+		d := NewDecoder(strings.NewReader(input))
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+
+		d.Reset(strings.NewReader("x\n"))
+		Expect(d.DocumentInfo()).To(Equal(DocumentInfo{}))
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(d.DocumentInfo()).To(Equal(DocumentInfo{ImplicitStart: true, ImplicitEnd: true}))
+	})
+})
+
+var _ = Describe("Document starts", func() {
+	decodeAll := func(input string, policy DocumentStartPolicy) ([]interface{}, error) {
+		d := NewDecoder(strings.NewReader(input))
+		d.DocumentStarts(policy)
+		d.EmptyDocuments(EmptyIsEOF)
+		var docs []interface{}
+		for {
+			var v interface{}
+			err := d.Decode(&v)
+			if err == io.EOF {
+				return docs, nil
+			}
+			if err != nil {
+				return docs, err
+			}
+			docs = append(docs, v)
+		}
+	}
+
+	It("lets only the first document omit the marker by default", func() {
+		docs, err := decodeAll("a\n--- b\n", ImplicitFirstDocument)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(docs).To(Equal([]interface{}{"a", "b"}))
+
+		_, err = decodeAll("a\n...\nb\n", ImplicitFirstDocument)
+		Expect(err).To(MatchError(ContainSubstring("did not find expected <document start>")))
+	})
+
+	It("requires every document to start with the marker", func() {
+		docs, err := decodeAll("--- a\n--- b\n", ExplicitDocuments)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(docs).To(Equal([]interface{}{"a", "b"}))
+
+		_, err = decodeAll("a: 1\n", ExplicitDocuments)
+		Expect(err).To(MatchError("Expected a document start '---' at line 0, column 0"))
+	})
+
+	It("lets documents following an end marker omit it", func() {
+		docs, err := decodeAll("a\n...\nb\n...\n--- c\n", ImplicitDocuments)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(docs).To(Equal([]interface{}{"a", "b", "c"}))
+	})
+})
+
+var _ = Describe("Unknown directives", func() {
+	input := "%FOO bar  baz # comment\n%YAML 1.1\n%BAZ\n--- a\n"
+
+	decode := func(policy UnknownDirectivePolicy) (interface{}, []Warning, error) {
+		var warnings []Warning
+		d := NewDecoder(strings.NewReader(input))
+		d.UnknownDirectives(policy)
+		d.OnWarning(func(w Warning) { warnings = append(warnings, w) })
+		var v interface{}
+		err := d.Decode(&v)
+		return v, warnings, err
+	}
+
+	It("skips them by default", func() {
+		v, warnings, err := decode(IgnoreUnknownDirectives)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(Equal("a"))
+		Expect(warnings).To(BeEmpty())
+	})
+
+	It("reports them as warnings", func() {
+		v, warnings, err := decode(WarnOnUnknownDirectives)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(Equal("a"))
+		Expect(warnings).To(Equal([]Warning{
+			{UnknownDirective, "unknown directive '%FOO bar  baz' was ignored", Position{1, 1}},
+			{UnknownDirective, "unknown directive '%BAZ' was ignored", Position{3, 1}},
+		}))
+	})
+
+	It("fails on them", func() {
+		_, _, err := decode(ErrorOnUnknownDirectives)
+		Expect(err).To(MatchError("yaml: [while scanning a directive] found unknown directive name at line 1, column 5"))
+	})
+
+	It("keeps the known directives", func() {
+		d := NewDecoder(strings.NewReader(input))
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(d.DocumentInfo().Version).To(Equal("1.1"))
+	})
+})
+
+var _ = Describe("Selecting the value of each document", func() {
+	type service struct{ Name string }
+	type volume struct{ Size int }
+
+	It("decodes each document into the value selected when it starts", func() {
+		input := `%YAML 1.1
+--- !service
+name: web
+--- !volume
+size: 10
+--- !secret
+key: hidden
+--- !service
+name: db
+`
+		d := NewDecoder(strings.NewReader(input))
+		var versions []string
+		selector := func(info DocumentInfo, tag string) (interface{}, error) {
+			versions = append(versions, info.Version)
+			switch tag {
+			case "!service":
+				return &service{}, nil
+			case "!volume":
+				return &volume{}, nil
+			}
+			return nil, nil
+		}
+
+		var docs []interface{}
+		for {
+			v, err := d.DecodeSelected(selector)
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			docs = append(docs, v)
+		}
+
+		Expect(docs).To(Equal([]interface{}{&service{"web"}, &volume{10}, nil, &service{"db"}}))
+		Expect(versions).To(Equal([]string{"1.1", "", "", ""}))
+	})
+
+	It("returns the errors of the selector", func() {
+		d := NewDecoder(strings.NewReader("a: 1\n"))
+		_, err := d.DecodeSelected(func(DocumentInfo, string) (interface{}, error) {
+			return nil, errors.New("unknown kind")
+		})
+		Expect(err).To(MatchError("unknown kind"))
+	})
+
+	It("rejects selected values that are not pointers", func() {
+		d := NewDecoder(strings.NewReader("a: 1\n"))
+		_, err := d.DecodeSelected(func(DocumentInfo, string) (interface{}, error) {
+			return service{}, nil
+		})
+		Expect(err).To(MatchError(ContainSubstring("Expected a pointer")))
+	})
+})
@@ -16,6 +16,7 @@ package candiedyaml
 
 import (
 	"bytes"
+	"unicode/utf8"
 )
 
 var default_tag_directives = []yaml_tag_directive_t{
@@ -24,14 +25,19 @@ var default_tag_directives = []yaml_tag_directive_t{
 }
 
 /*
- * Flush the buffer if needed.
+ * Grow the buffer, or flush it once it holds flush_size bytes.
  */
-
 func flush(emitter *yaml_emitter_t) bool {
-	if emitter.buffer_pos+5 >= len(emitter.buffer) {
-		return yaml_emitter_flush(emitter)
+	if emitter.buffer_pos+5 < len(emitter.buffer) && emitter.buffer_pos < emitter.flush_size {
+		return true
 	}
-	return true
+	if emitter.buffer_pos < emitter.flush_size {
+		buffer := make([]byte, 2*len(emitter.buffer))
+		copy(buffer, emitter.buffer[:emitter.buffer_pos])
+		emitter.buffer = buffer
+		return true
+	}
+	return yaml_emitter_flush(emitter)
 }
 
 /*
@@ -65,7 +71,7 @@ func put_break(emitter *yaml_emitter_t) bool {
 		emitter.buffer_pos++
 	case yaml_CRLN_BREAK:
 		emitter.buffer[emitter.buffer_pos] = '\r'
-		emitter.buffer[emitter.buffer_pos] = '\n'
+		emitter.buffer[emitter.buffer_pos+1] = '\n'
 		emitter.buffer_pos += 2
 	default:
 		return false
@@ -82,8 +88,12 @@ func write(emitter *yaml_emitter_t, src []byte, src_pos *int) bool {
 	if !flush(emitter) {
 		return false
 	}
+	if emitter.display_width {
+		emitter.column += display_width_at(src, *src_pos)
+	} else {
+		emitter.column++
+	}
 	copy_bytes(emitter.buffer, &emitter.buffer_pos, src, src_pos)
-	emitter.column++
 	return true
 }
 
@@ -135,6 +145,13 @@ func yaml_emitter_emit(emitter *yaml_emitter_t, event *yaml_event_t) bool {
 		yaml_event_delete(event)
 		emitter.events_head++
 	}
+
+	/* The events emitted are dropped, so that the queue does not grow. */
+
+	if emitter.events_head == len(emitter.events) {
+		emitter.events = emitter.events[:0]
+		emitter.events_head = 0
+	}
 	return true
 }
 
@@ -485,6 +502,18 @@ func yaml_emitter_emit_document_start(emitter *yaml_emitter_t,
 func yaml_emitter_emit_document_content(emitter *yaml_emitter_t, event *yaml_event_t) bool {
 	emitter.states = append(emitter.states, yaml_EMIT_DOCUMENT_END_STATE)
 
+	if emitter.base_indent > 0 && !yaml_emitter_write_indent(emitter) {
+		return false
+	}
+	if len(event.head_comment) > 0 {
+		if !yaml_emitter_write_comment(emitter, event.head_comment) {
+			return false
+		}
+		if !yaml_emitter_write_indent(emitter) {
+			return false
+		}
+	}
+
 	return yaml_emitter_emit_node(emitter, event, true, false, false, false)
 }
 
@@ -499,6 +528,8 @@ func yaml_emitter_emit_document_end(emitter *yaml_emitter_t, event *yaml_event_t
 			"expected DOCUMENT-END")
 	}
 
+	/* A fragment ends with a line break, without its indentation. */
+	emitter.base_indent = 0
 	if !yaml_emitter_write_indent(emitter) {
 		return false
 	}
@@ -510,7 +541,7 @@ func yaml_emitter_emit_document_end(emitter *yaml_emitter_t, event *yaml_event_t
 			return false
 		}
 	}
-	if !yaml_emitter_flush(emitter) {
+	if !emitter.defer_flush && !yaml_emitter_flush(emitter) {
 		return false
 	}
 
@@ -550,6 +581,9 @@ func yaml_emitter_emit_flow_sequence_item(emitter *yaml_emitter_t, event *yaml_e
 		if !yaml_emitter_write_indicator(emitter, []byte("]"), false, false, false) {
 			return false
 		}
+		if !yaml_emitter_write_flow_end_comments(emitter, event) {
+			return false
+		}
 		emitter.state = emitter.states[len(emitter.states)-1]
 		emitter.states = emitter.states[:len(emitter.states)-1]
 
@@ -560,6 +594,9 @@ func yaml_emitter_emit_flow_sequence_item(emitter *yaml_emitter_t, event *yaml_e
 		if !yaml_emitter_write_indicator(emitter, []byte(","), false, false, false) {
 			return false
 		}
+		if emitter.compact {
+			emitter.whitespace = true
+		}
 	}
 
 	if emitter.canonical || emitter.column > emitter.best_width {
@@ -606,6 +643,9 @@ func yaml_emitter_emit_flow_mapping_key(emitter *yaml_emitter_t,
 		if !yaml_emitter_write_indicator(emitter, []byte("}"), false, false, false) {
 			return false
 		}
+		if !yaml_emitter_write_flow_end_comments(emitter, event) {
+			return false
+		}
 
 		emitter.state = emitter.states[len(emitter.states)-1]
 		emitter.states = emitter.states[:len(emitter.states)-1]
@@ -617,6 +657,9 @@ func yaml_emitter_emit_flow_mapping_key(emitter *yaml_emitter_t,
 		if !yaml_emitter_write_indicator(emitter, []byte(","), false, false, false) {
 			return false
 		}
+		if emitter.compact {
+			emitter.whitespace = true
+		}
 	}
 	if emitter.canonical || emitter.column > emitter.best_width {
 		if !yaml_emitter_write_indent(emitter) {
@@ -671,12 +714,16 @@ func yaml_emitter_emit_block_sequence_item(emitter *yaml_emitter_t,
 
 	if first {
 		if !yaml_emitter_increase_indent(emitter, false,
-			(emitter.mapping_context && !emitter.indention)) {
+			(emitter.mapping_context && !emitter.indention && !emitter.indent_sequences)) {
 			return false
 		}
 	}
 
 	if event.event_type == yaml_SEQUENCE_END_EVENT {
+		emitter.head_comment = nil
+		if !yaml_emitter_write_comment(emitter, event.foot_comment) {
+			return false
+		}
 
 		emitter.indent = emitter.indents[len(emitter.indents)-1]
 		emitter.indents = emitter.indents[:len(emitter.indents)-1]
@@ -687,12 +734,19 @@ func yaml_emitter_emit_block_sequence_item(emitter *yaml_emitter_t,
 		return true
 	}
 
+	if !yaml_emitter_write_head_comments(emitter, event) {
+		return false
+	}
 	if !yaml_emitter_write_indent(emitter) {
 		return false
 	}
 	if !yaml_emitter_write_indicator(emitter, []byte("-"), true, false, true) {
 		return false
 	}
+	if emitter.no_compact_mappings && event.event_type == yaml_MAPPING_START_EVENT {
+		/* The first key of a block mapping goes on the next line. */
+		emitter.indention = false
+	}
 
 	emitter.states = append(emitter.states, yaml_EMIT_BLOCK_SEQUENCE_ITEM_STATE)
 	return yaml_emitter_emit_node(emitter, event, false, true, false, false)
@@ -712,6 +766,11 @@ func yaml_emitter_emit_block_mapping_key(emitter *yaml_emitter_t,
 	}
 
 	if event.event_type == yaml_MAPPING_END_EVENT {
+		emitter.head_comment = nil
+		if !yaml_emitter_write_comment(emitter, event.foot_comment) {
+			return false
+		}
+
 		emitter.indent = emitter.indents[len(emitter.indents)-1]
 		emitter.indents = emitter.indents[:len(emitter.indents)-1]
 
@@ -721,6 +780,9 @@ func yaml_emitter_emit_block_mapping_key(emitter *yaml_emitter_t,
 		return true
 	}
 
+	if !yaml_emitter_write_head_comments(emitter, event) {
+		return false
+	}
 	if !yaml_emitter_write_indent(emitter) {
 		return false
 	}
@@ -758,6 +820,21 @@ func yaml_emitter_emit_block_mapping_value(emitter *yaml_emitter_t,
 			return false
 		}
 	}
+
+	/* Comments before the value, or after a key followed by a collection. */
+
+	key_comment := emitter.key_line_comment
+	emitter.key_line_comment = nil
+	if yaml_emitter_check_block_collection(emitter, event) {
+		emitter.head_comment = event.head_comment
+		if !yaml_emitter_write_line_comment(emitter, key_comment) {
+			return false
+		}
+	} else {
+		event.line_comment = join_comments(join_comments(key_comment, event.head_comment), event.line_comment)
+	}
+	event.head_comment = nil
+
 	emitter.states = append(emitter.states, yaml_EMIT_BLOCK_MAPPING_KEY_STATE)
 
 	return yaml_emitter_emit_node(emitter, event, false, false, true, false)
@@ -803,6 +880,9 @@ func yaml_emitter_emit_alias(emitter *yaml_emitter_t, event *yaml_event_t) bool
 	if !yaml_emitter_process_anchor(emitter) {
 		return false
 	}
+	if !yaml_emitter_write_node_end_comments(emitter, event, emitter.simple_key_context) {
+		return false
+	}
 
 	emitter.state = emitter.states[len(emitter.states)-1]
 	emitter.states = emitter.states[:len(emitter.states)-1]
@@ -827,12 +907,22 @@ func yaml_emitter_emit_scalar(emitter *yaml_emitter_t, event *yaml_event_t) bool
 	if !yaml_emitter_increase_indent(emitter, true, false) {
 		return false
 	}
+	style := emitter.scalar_data.style
+	if style == yaml_LITERAL_SCALAR_STYLE || style == yaml_FOLDED_SCALAR_STYLE {
+		/* The line comment goes in the header of a block scalar. */
+		emitter.line_comment = event.line_comment
+		event.line_comment = nil
+	}
 	if !yaml_emitter_process_scalar(emitter) {
 		return false
 	}
 	emitter.indent = emitter.indents[len(emitter.indents)-1]
 	emitter.indents = emitter.indents[:len(emitter.indents)-1]
 
+	if !yaml_emitter_write_node_end_comments(emitter, event, emitter.simple_key_context) {
+		return false
+	}
+
 	emitter.state = emitter.states[len(emitter.states)-1]
 	emitter.states = emitter.states[:len(emitter.states)-1]
 
@@ -854,9 +944,15 @@ func yaml_emitter_emit_sequence_start(emitter *yaml_emitter_t, event *yaml_event
 	if emitter.flow_level > 0 || emitter.canonical ||
 		event.style == yaml_style_t(yaml_FLOW_SEQUENCE_STYLE) ||
 		yaml_emitter_check_empty_sequence(emitter) {
+		if emitter.flow_level == 0 {
+			emitter.flow_simple_key = emitter.simple_key_context
+		}
 		emitter.state = yaml_EMIT_FLOW_SEQUENCE_FIRST_ITEM_STATE
 	} else {
 		emitter.state = yaml_EMIT_BLOCK_SEQUENCE_FIRST_ITEM_STATE
+		if !yaml_emitter_write_line_comment(emitter, event.line_comment) {
+			return false
+		}
 	}
 
 	return true
@@ -877,9 +973,15 @@ func yaml_emitter_emit_mapping_start(emitter *yaml_emitter_t, event *yaml_event_
 	if emitter.flow_level > 0 || emitter.canonical ||
 		event.style == yaml_style_t(yaml_FLOW_MAPPING_STYLE) ||
 		yaml_emitter_check_empty_mapping(emitter) {
+		if emitter.flow_level == 0 {
+			emitter.flow_simple_key = emitter.simple_key_context
+		}
 		emitter.state = yaml_EMIT_FLOW_MAPPING_FIRST_KEY_STATE
 	} else {
 		emitter.state = yaml_EMIT_BLOCK_MAPPING_FIRST_KEY_STATE
+		if !yaml_emitter_write_line_comment(emitter, event.line_comment) {
+			return false
+		}
 	}
 
 	return true
@@ -890,7 +992,14 @@ func yaml_emitter_emit_mapping_start(emitter *yaml_emitter_t, event *yaml_event_
  */
 
 func yaml_emitter_check_empty_document(emitter *yaml_emitter_t) bool {
-	return false
+	if len(emitter.events)-emitter.events_head < 2 {
+		return false
+	}
+
+	event := &emitter.events[emitter.events_head+1]
+	return event.event_type == yaml_SCALAR_EVENT && len(event.value) == 0 &&
+		len(event.anchor) == 0 && len(event.tag) == 0 &&
+		event.style == yaml_style_t(yaml_PLAIN_SCALAR_STYLE)
 }
 
 /*
@@ -961,7 +1070,11 @@ func yaml_emitter_check_simple_key(emitter *yaml_emitter_t) bool {
 		return false
 	}
 
-	if length > 128 {
+	max := emitter.max_simple_key_length
+	if max <= 0 {
+		max = 128
+	}
+	if length > max {
 		return false
 	}
 
@@ -982,6 +1095,11 @@ func yaml_emitter_select_scalar_style(emitter *yaml_emitter_t, event *yaml_event
 
 	style := yaml_scalar_style_t(event.style)
 
+	if style == yaml_RAW_SCALAR_STYLE {
+		emitter.scalar_data.style = style
+		return true
+	}
+
 	if style == yaml_ANY_SCALAR_STYLE {
 		style = yaml_PLAIN_SCALAR_STYLE
 	}
@@ -994,6 +1112,15 @@ func yaml_emitter_select_scalar_style(emitter *yaml_emitter_t, event *yaml_event
 		style = yaml_DOUBLE_QUOTED_SCALAR_STYLE
 	}
 
+	if style == yaml_PLAIN_SCALAR_STYLE && len(emitter.scalar_data.value) == 0 &&
+		no_tag && event.implicit && !event.quoted_implicit &&
+		(emitter.flow_level > 0 || emitter.simple_key_context || !emitter.scalar_data.block_plain_allowed) {
+		// a null, which would be read as a string if it were quoted
+		emitter.scalar_data.value = []byte("~")
+		emitter.scalar_data.flow_plain_allowed = true
+		emitter.scalar_data.block_plain_allowed = true
+	}
+
 	if style == yaml_PLAIN_SCALAR_STYLE {
 		if (emitter.flow_level > 0 && !emitter.scalar_data.flow_plain_allowed) ||
 			(emitter.flow_level == 0 && !emitter.scalar_data.block_plain_allowed) {
@@ -1098,7 +1225,7 @@ func yaml_emitter_process_scalar(emitter *yaml_emitter_t) bool {
 	case yaml_PLAIN_SCALAR_STYLE:
 		return yaml_emitter_write_plain_scalar(emitter,
 			emitter.scalar_data.value,
-			!emitter.simple_key_context)
+			!emitter.simple_key_context && !emitter.no_plain_breaks)
 
 	case yaml_SINGLE_QUOTED_SCALAR_STYLE:
 		return yaml_emitter_write_single_quoted_scalar(emitter,
@@ -1118,6 +1245,10 @@ func yaml_emitter_process_scalar(emitter *yaml_emitter_t) bool {
 		return yaml_emitter_write_folded_scalar(emitter,
 			emitter.scalar_data.value)
 
+	case yaml_RAW_SCALAR_STYLE:
+		return yaml_emitter_write_raw_scalar(emitter,
+			emitter.scalar_data.value)
+
 	default:
 		panic("unknown scalar")
 	}
@@ -1163,7 +1294,7 @@ func yaml_emitter_analyze_tag_directive(emitter *yaml_emitter_t,
 			"tag handle must end with '!'")
 	}
 
-	for i := 1; i < len(handle)-1; width(handle[i]) {
+	for i := 1; i < len(handle)-1; i += width(handle[i]) {
 		if !is_alpha(handle[i]) {
 			return yaml_emitter_set_emitter_error(emitter,
 				"tag handle must contain alphanumerical characters only")
@@ -1256,6 +1387,10 @@ func yaml_emitter_analyze_scalar(emitter *yaml_emitter_t, value []byte) bool {
 
 	emitter.scalar_data.value = value
 
+	if !utf8.Valid(value) {
+		return yaml_emitter_set_emitter_error(emitter, "scalar value is not valid UTF-8")
+	}
+
 	if len(value) == 0 {
 		emitter.scalar_data.multiline = false
 		emitter.scalar_data.flow_plain_allowed = false
@@ -1485,6 +1620,7 @@ func yaml_emitter_write_indent(emitter *yaml_emitter_t) bool {
 	if indent < 0 {
 		indent = 0
 	}
+	indent += emitter.base_indent
 
 	if !emitter.indention || emitter.column > indent ||
 		(emitter.column == indent && !emitter.whitespace) {
@@ -1505,6 +1641,187 @@ func yaml_emitter_write_indent(emitter *yaml_emitter_t) bool {
 	return true
 }
 
+/*
+ * Write comment lines, each at the current indentation.
+ */
+
+func yaml_emitter_write_comment(emitter *yaml_emitter_t, comment []byte) bool {
+	if len(comment) == 0 || emitter.flow_level > 0 {
+		return true
+	}
+
+	for _, line := range bytes.Split(comment, []byte("\n")) {
+		if len(line) == 0 {
+			if !yaml_emitter_write_blank_line(emitter) {
+				return false
+			}
+			continue
+		}
+		if !yaml_emitter_write_indent(emitter) {
+			return false
+		}
+		if !yaml_emitter_write_comment_text(emitter, line) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+ * Write a blank line, unless the last line written is one.
+ */
+
+func yaml_emitter_write_blank_line(emitter *yaml_emitter_t) bool {
+	/* Drop the indentation written to the current line. */
+	if emitter.indention && emitter.column > 0 && emitter.buffer_pos >= emitter.column &&
+		len(bytes.Trim(emitter.buffer[emitter.buffer_pos-emitter.column:emitter.buffer_pos], " ")) == 0 {
+		emitter.buffer_pos -= emitter.column
+		emitter.column = 0
+	}
+
+	if emitter.column > 0 {
+		if !put_break(emitter) {
+			return false
+		}
+	}
+	if emitter.line != emitter.blank_line {
+		if !put_break(emitter) {
+			return false
+		}
+		emitter.blank_line = emitter.line
+	}
+
+	emitter.whitespace = true
+	emitter.indention = true
+
+	return true
+}
+
+/*
+ * Write a comment at the end of the current line.
+ */
+
+func yaml_emitter_write_line_comment(emitter *yaml_emitter_t, comment []byte) bool {
+	if len(comment) == 0 || emitter.flow_level > 0 {
+		return true
+	}
+
+	/* The lines are joined, leaving out blank lines. */
+	var lines [][]byte
+	for _, line := range bytes.Split(comment, []byte("\n")) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return true
+	}
+
+	if !put(emitter, ' ') {
+		return false
+	}
+	return yaml_emitter_write_comment_text(emitter, bytes.Join(lines, []byte(" ")))
+}
+
+func yaml_emitter_write_comment_text(emitter *yaml_emitter_t, text []byte) bool {
+	if len(text) == 0 || text[0] != '#' {
+		if !put(emitter, '#') {
+			return false
+		}
+		if len(text) > 0 && !put(emitter, ' ') {
+			return false
+		}
+	}
+
+	for i := 0; i < len(text); {
+		if !write(emitter, text, &i) {
+			return false
+		}
+	}
+
+	emitter.whitespace = false
+	emitter.indention = false
+
+	return true
+}
+
+/*
+ * Write the head comments of a block collection item or key.
+ */
+
+func yaml_emitter_write_head_comments(emitter *yaml_emitter_t, event *yaml_event_t) bool {
+	head := emitter.head_comment
+	emitter.head_comment = nil
+	if !yaml_emitter_write_comment(emitter, head) {
+		return false
+	}
+	return yaml_emitter_write_comment(emitter, event.head_comment)
+}
+
+/*
+ * Write the comments following a scalar or an alias.
+ */
+
+func yaml_emitter_write_node_end_comments(emitter *yaml_emitter_t, event *yaml_event_t, simple_key bool) bool {
+	if emitter.flow_level > 0 {
+		return true
+	}
+
+	if simple_key {
+		/* Written after the ':' indicator. */
+		emitter.key_line_comment = event.line_comment
+		return true
+	}
+
+	if !yaml_emitter_write_line_comment(emitter, event.line_comment) {
+		return false
+	}
+	return yaml_emitter_write_comment(emitter, event.foot_comment)
+}
+
+/*
+ * Write the comments following a flow collection.
+ */
+
+func yaml_emitter_write_flow_end_comments(emitter *yaml_emitter_t, event *yaml_event_t) bool {
+	return yaml_emitter_write_node_end_comments(emitter, event, emitter.flow_simple_key)
+}
+
+/*
+ * Check if the event starts a non-empty block collection.
+ */
+
+func yaml_emitter_check_block_collection(emitter *yaml_emitter_t, event *yaml_event_t) bool {
+	if emitter.flow_level > 0 || emitter.canonical {
+		return false
+	}
+
+	switch event.event_type {
+	case yaml_SEQUENCE_START_EVENT:
+		return event.style != yaml_style_t(yaml_FLOW_SEQUENCE_STYLE) &&
+			!yaml_emitter_check_empty_sequence(emitter)
+	case yaml_MAPPING_START_EVENT:
+		return event.style != yaml_style_t(yaml_FLOW_MAPPING_STYLE) &&
+			!yaml_emitter_check_empty_mapping(emitter)
+	}
+	return false
+}
+
+/*
+ * Join two comments with a line break.
+ */
+
+func join_comments(a, b []byte) []byte {
+	switch {
+	case len(a) == 0:
+		return b
+	case len(b) == 0:
+		return a
+	}
+	return append(append(append([]byte{}, a...), '\n'), b...)
+}
+
 func yaml_emitter_write_indicator(emitter *yaml_emitter_t,
 	indicator []byte, need_whitespace bool,
 	is_whitespace bool, is_indention bool) bool {
@@ -1627,7 +1944,7 @@ func yaml_emitter_write_plain_scalar(emitter *yaml_emitter_t, value []byte,
 	spaces := false
 	breaks := false
 
-	if !emitter.whitespace {
+	if len(value) > 0 && !emitter.whitespace {
 		if !put(emitter, ' ') {
 			return false
 		}
@@ -1950,6 +2267,7 @@ func yaml_emitter_write_block_scalar_hints(emitter *yaml_emitter_t, value []byte
 			chomp_hint[0] = '+'
 			emitter.open_ended = true
 		} else {
+			i--
 			for value[i]&0xC0 == 0x80 {
 				i--
 			}
@@ -1962,9 +2280,12 @@ func yaml_emitter_write_block_scalar_hints(emitter *yaml_emitter_t, value []byte
 	}
 
 	if chomp_hint[0] != 0 {
+		/* Writing the '+' hint must not clear open_ended, which it sets. */
+		open_ended := emitter.open_ended
 		if !yaml_emitter_write_indicator(emitter, chomp_hint[:], false, false, false) {
 			return false
 		}
+		emitter.open_ended = open_ended
 	}
 
 	return true
@@ -1981,6 +2302,10 @@ func yaml_emitter_write_literal_scalar(emitter *yaml_emitter_t, value []byte) bo
 	if !yaml_emitter_write_block_scalar_hints(emitter, value) {
 		return false
 	}
+	if !yaml_emitter_write_line_comment(emitter, emitter.line_comment) {
+		return false
+	}
+	emitter.line_comment = nil
 
 	if !put_break(emitter) {
 		return false
@@ -2013,6 +2338,60 @@ func yaml_emitter_write_literal_scalar(emitter *yaml_emitter_t, value []byte) bo
 	return true
 }
 
+/*
+ * Write raw text, each line after the first at the current indentation.
+ */
+
+func yaml_emitter_write_raw_scalar(emitter *yaml_emitter_t, value []byte) bool {
+	block := len(value) > 0 && value[0] == '\n'
+	if block {
+		value = value[1:]
+	}
+	multiline := block || bytes.IndexByte(value, '\n') >= 0
+	if multiline && emitter.flow_level > 0 {
+		return yaml_emitter_set_emitter_error(emitter,
+			"raw text in a flow collection must be a single line")
+	}
+
+	indent := emitter.indent
+	if emitter.root_context {
+		indent = 0
+	}
+	indent += emitter.base_indent
+	if block && emitter.column > 0 {
+		if !put_break(emitter) {
+			return false
+		}
+	} else if !block && !emitter.whitespace {
+		if !put(emitter, ' ') {
+			return false
+		}
+	}
+
+	for n, line := range bytes.Split(value, []byte{'\n'}) {
+		if n > 0 && !put_break(emitter) {
+			return false
+		}
+		if len(line) == 0 {
+			continue
+		}
+		for (n > 0 || block) && emitter.column < indent {
+			if !put(emitter, ' ') {
+				return false
+			}
+		}
+		for i := 0; i < len(line); {
+			if !write(emitter, line, &i) {
+				return false
+			}
+		}
+	}
+
+	emitter.whitespace = false
+	emitter.indention = false
+	return true
+}
+
 func yaml_emitter_write_folded_scalar(emitter *yaml_emitter_t, value []byte) bool {
 	breaks := true
 	leading_spaces := true
@@ -2023,6 +2402,10 @@ func yaml_emitter_write_folded_scalar(emitter *yaml_emitter_t, value []byte) boo
 	if !yaml_emitter_write_block_scalar_hints(emitter, value) {
 		return false
 	}
+	if !yaml_emitter_write_line_comment(emitter, emitter.line_comment) {
+		return false
+	}
+	emitter.line_comment = nil
 	if !put_break(emitter) {
 		return false
 	}
@@ -2033,10 +2416,10 @@ func yaml_emitter_write_folded_scalar(emitter *yaml_emitter_t, value []byte) boo
 		if is_break_at(value, i) {
 			if !breaks && !leading_spaces && value[i] == '\n' {
 				k := i
-				for is_break_at(value, k) {
+				for k < len(value) && is_break_at(value, k) {
 					k += width(value[k])
 				}
-				if !is_blankz_at(value, k) {
+				if k < len(value) && !is_blankz_at(value, k) {
 					if !put_break(emitter) {
 						return false
 					}
@@ -2054,7 +2437,7 @@ func yaml_emitter_write_folded_scalar(emitter *yaml_emitter_t, value []byte) boo
 				}
 				leading_spaces = is_blank(value[i])
 			}
-			if !breaks && is_space(value[i]) && !is_space(value[i+1]) &&
+			if !breaks && is_space(value[i]) && i+1 < len(value) && !is_space(value[i+1]) &&
 				emitter.column > emitter.best_width {
 				if !yaml_emitter_write_indent(emitter) {
 					return false
@@ -17,20 +17,25 @@ package candiedyaml
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
 	"math"
 	"reflect"
 	"regexp"
-	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 var (
 	timeTimeType  = reflect.TypeOf(time.Time{})
 	marshalerType = reflect.TypeOf(new(Marshaler)).Elem()
+	isZeroerType  = reflect.TypeOf(new(IsZeroer)).Elem()
 	numberType    = reflect.TypeOf(Number(""))
-	nonPrintable  = regexp.MustCompile("[^\t\n\r\u0020-\u007E\u0085\u00A0-\uD7FF\uE000-\uFFFD]")
+	intStringType = reflect.TypeOf(IntString(""))
+	nonPrintable  = regexp.MustCompile("[^\t\n\r\u0020-\u007E\u0085\u00A0-\uD7FF\uE000-\uFFFD\U00010000-\U0010FFFF]")
 	multiline     = regexp.MustCompile("\n|\u0085|\u2028|\u2029")
 
 	shortTags = map[string]string{
@@ -43,69 +48,894 @@ var (
 		yaml_SEQ_TAG:       "!!seq",
 		yaml_MAP_TAG:       "!!map",
 		yaml_BINARY_TAG:    "!!binary",
+		yaml_SET_TAG:       "!!set",
+		yaml_OMAP_TAG:      "!!omap",
+		yaml_PAIRS_TAG:     "!!pairs",
 	}
 )
 
+// A ScalarStyle selects how a scalar value is written.
+type ScalarStyle int
+
+const (
+	// AnyStyle lets the encoder choose the style.
+	AnyStyle ScalarStyle = iota
+	PlainStyle
+	SingleQuotedStyle
+	DoubleQuotedStyle
+	LiteralStyle
+	FoldedStyle
+)
+
+var scalarStyleNames = []string{
+	AnyStyle:          "any",
+	PlainStyle:        "plain",
+	SingleQuotedStyle: "single-quoted",
+	DoubleQuotedStyle: "double-quoted",
+	LiteralStyle:      "literal",
+	FoldedStyle:       "folded",
+}
+
+func (s ScalarStyle) String() string {
+	if s >= 0 && int(s) < len(scalarStyleNames) {
+		return scalarStyleNames[s]
+	}
+	return fmt.Sprintf("style %d", int(s))
+}
+
+// A Marshaler returns the value to encode in its place, with the tag to
+// write, if any. The value can be a Node or *Node, which controls the
+// styles, tags and comments of the output; the tag returned applies to a
+// node without one.
 type Marshaler interface {
 	MarshalYAML() (tag string, value interface{}, err error)
 }
 
+// IsZeroer is implemented by types that decide for themselves whether they
+// are zero. Fields tagged omitzero are omitted when IsZero returns true, and
+// other fields tagged omitzero when they hold the zero value of their type.
+// Fields tagged omitempty do not consult it, so that structs, which are
+// never empty, and times are still written.
+type IsZeroer interface {
+	IsZero() bool
+}
+
 // An Encoder writes JSON objects to an output stream.
 type Encoder struct {
-	w       io.Writer
-	emitter yaml_emitter_t
-	event   yaml_event_t
-	flow    bool
-	err     error
+	w        io.Writer
+	emitter  yaml_emitter_t
+	fragment yaml_emitter_t
+	event    yaml_event_t
+	flow     bool
+	err      error
+	started  bool
+
+	encoding yaml_encoding_t
+
+	autoFlowItems  int
+	autoFlowWidth  int
+	multilineStyle yaml_scalar_style_t
+	longLength     int
+	longStyle      yaml_scalar_style_t
+	fieldStyle     yaml_scalar_style_t
+	typeStyles     map[reflect.Type]yaml_scalar_style_t
+	pathKeyOrders  map[string]map[string]int
+	typeKeyOrders  map[reflect.Type]map[string]int
+	typeStyle      yaml_scalar_style_t
+	quoteStrings   bool
+	singleQuotes   bool
+	quoteMarkers   bool
+	key            bool
+	nullValue      string
+	fieldNull      *string
+	fieldTagged    bool
+	redaction      Redaction
+	controls       ControlPolicy
+	unsupported    UnsupportedPolicy
+	endMarker      EndMarkerPolicy
+	lineBreak      yaml_break_t
+	deterministic  bool
+	timeLayout     string
+	timeUTC        bool
+
+	floatFormat       byte
+	floatPrec         int
+	floatDecimalPoint bool
+	floatNaN          string
+	floatPosInf       string
+	floatNegInf       string
+
+	implicitRules []ImplicitRule
+	noSeparators  bool
+	nulls         []string
+	schema        Schema
+
+	anchorPointers bool
+	anchorNamer    AnchorNamer
+	anchor         string
+	anchorNames    map[string]bool
+	pointers       map[pointerKey]*pointerAnchor
+	anchorPolicy   AnchorPolicy
+	streamAnchors  map[pointerKey]streamAnchor
+	dedupMinNodes  int
+	selfCheck      bool
+	checkOutput    io.Writer
+	checkBuffer    bytes.Buffer
+	checkEvents    []checkedEvent
+	recording      bool
+	events         []yaml_event_t
+
+	// the head comment of the next event
+	comment string
+
+	// the path of the value being encoded
+	path []byte
 }
 
 func Marshal(v interface{}) ([]byte, error) {
 	b := bytes.Buffer{}
-	e := NewEncoder(&b)
+	e := getEncoder(&b)
 	err := e.Encode(v)
+	putEncoder(e)
 	return b.Bytes(), err
 }
 
-// NewEncoder returns a new encoder that writes to w.
-func NewEncoder(w io.Writer) *Encoder {
-	e := &Encoder{w: w}
+// NewEncoder returns a new encoder that writes to w, configured by opts.
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	e := &Encoder{
+		w:           w,
+		nullValue:   "null",
+		floatFormat: 'g',
+		floatPrec:   -1,
+		floatNaN:    ".nan",
+		floatPosInf: "+.inf",
+		floatNegInf: "-.inf",
+	}
 	yaml_emitter_initialize(&e.emitter)
 	yaml_emitter_set_output_writer(&e.emitter, e.w)
-	yaml_stream_start_event_initialize(&e.event, yaml_UTF8_ENCODING)
+
+	for _, opt := range opts {
+		if opt.encoder != nil {
+			opt.encoder(e)
+		}
+	}
+	return e
+}
+
+// Reset discards the state of e and makes it write to w, starting a new
+// stream. Its options are kept and its buffers reused, so that an Encoder
+// can encode many small outputs without allocating a new one for each.
+func (e *Encoder) Reset(w io.Writer) {
+	yaml_emitter_reset(&e.emitter)
+	yaml_emitter_set_output_writer(&e.emitter, w)
+	yaml_emitter_set_break(&e.emitter, e.lineBreak)
+
+	e.w = w
+	e.event = yaml_event_t{}
+	e.flow = false
+	e.err = nil
+	e.started = false
+	e.key = false
+	e.fieldStyle = yaml_ANY_SCALAR_STYLE
+	e.typeStyle = yaml_ANY_SCALAR_STYLE
+	e.fieldNull = nil
+	e.fieldTagged = false
+	e.anchor = ""
+	e.anchorNames = nil
+	e.comment = ""
+	e.pointers = nil
+	e.streamAnchors = nil
+	e.recording = false
+	e.events = e.events[:0]
+}
+
+// AutoFlush sets whether Encode writes each document to the writer as soon
+// as it ends, which is the default. Without it, documents collect in the
+// Encoder's buffer, which is written once it is full or when Flush is
+// called, so that many small documents sent to a network connection do not
+// each cost a write.
+func (e *Encoder) AutoFlush(enable bool) {
+	e.emitter.defer_flush = !enable
+}
+
+// SetBufferSize sets the number of bytes of output the Encoder collects
+// before writing them to its writer, 64 KiB by default; a size below 1
+// selects the default. The output of a document is written as it is
+// produced rather than when the document ends, so the memory the Encoder
+// uses does not grow with the size of the output, but with that of the
+// buffer, of the longest scalar and of the nesting of the values encoded.
+// Deduplicate and SelfCheck are the exceptions, as they hold a whole
+// document before writing it.
+func (e *Encoder) SetBufferSize(size int) {
+	if size < 1 {
+		size = OUTPUT_FLUSH_SIZE
+	}
+	e.emitter.flush_size = size
+}
+
+// Flush writes any output buffered by e to its writer.
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.emitter.buffer_pos > 0 && !yaml_emitter_flush(&e.emitter) {
+		e.err = errors.New(e.emitter.problem)
+		return e.err
+	}
+	e.err = e.flushTee()
+	return e.err
+}
+
+// An EndMarkerPolicy selects whether Close ends the stream with a "..."
+// marker.
+type EndMarkerPolicy int
+
+const (
+	// EndMarkerWhenNeeded writes it after a last document that would
+	// otherwise be read on past its end, such as one ending in a block
+	// scalar keeping its trailing line breaks.
+	EndMarkerWhenNeeded EndMarkerPolicy = iota
+	// AlwaysEndMarker writes it after every stream with documents.
+	AlwaysEndMarker
+	// NoEndMarker never writes it.
+	NoEndMarker
+)
+
+// EndMarker sets whether Close writes a "..." marker at the end of the
+// stream. The default is EndMarkerWhenNeeded.
+func (e *Encoder) EndMarker(policy EndMarkerPolicy) {
+	e.endMarker = policy
+}
+
+// FinalNewline sets whether the output ends with a line break, which is
+// the default. When it is off, the line break that ends the output so far
+// is held back until more output follows it, and dropped by Close; until
+// then, it is not written even by Flush. A document ending in a block
+// scalar then reads back with a line break less, unless it is followed by
+// a "..." marker.
+func (e *Encoder) FinalNewline(on bool) {
+	e.emitter.hold_final_break = !on
+}
+
+// Close ends the stream written by e: it writes the "..." marker selected
+// by EndMarker, then the output still buffered, leaving out the line break
+// ending it when FinalNewline is off. An Encoder cannot encode more once it
+// is closed, until it is Reset.
+func (e *Encoder) Close() (err error) {
+	if e.err != nil {
+		return e.err
+	}
+	defer func() {
+		e.err = err
+		if err == nil {
+			e.err = errClosed
+		}
+	}()
+	defer recovery(&err)
+
+	if !e.started {
+		return nil
+	}
+	switch e.endMarker {
+	case AlwaysEndMarker:
+		e.emitter.open_ended = true
+	case NoEndMarker:
+		e.emitter.open_ended = false
+	}
+	yaml_stream_end_event_initialize(&e.event)
 	e.emit()
-	yaml_document_start_event_initialize(&e.event, nil, nil, true)
+
+	if hold := e.emitter.hold_final_break; hold {
+		e.emitter.buffer_pos -= yaml_emitter_final_break(&e.emitter)
+		e.emitter.hold_final_break = false
+		defer func() { e.emitter.hold_final_break = hold }()
+	}
+	if !yaml_emitter_flush(&e.emitter) {
+		return errors.New(e.emitter.problem)
+	}
+	return e.flushTee()
+}
+
+// errClosed is the error of the calls to an Encoder once it is closed.
+var errClosed = errors.New("The encoder is closed")
+
+// An Encoding is a character encoding of the encoded output.
+type Encoding int
+
+const (
+	UTF8 Encoding = iota + 1
+	UTF16LE
+	UTF16BE
+)
+
+// SetEncoding sets the character encoding of the output. UTF-16 output
+// starts with a byte order mark. It must be called before Encode.
+func (e *Encoder) SetEncoding(enc Encoding) {
+	switch enc {
+	case UTF8, UTF16LE, UTF16BE:
+		e.encoding = yaml_encoding_t(enc)
+	}
+}
+
+// A LineBreak is the line break style of a document.
+type LineBreak int
+
+const (
+	AnyBreak  LineBreak = iota
+	CRBreak             // \r
+	LFBreak             // \n
+	CRLFBreak           // \r\n
+)
+
+// SetLineBreak sets the line breaks written by the encoder. The default,
+// AnyBreak, writes \n.
+func (e *Encoder) SetLineBreak(brk LineBreak) {
+	switch brk {
+	case AnyBreak, CRBreak, LFBreak, CRLFBreak:
+		e.lineBreak = yaml_break_t(brk)
+		yaml_emitter_set_break(&e.emitter, e.lineBreak)
+	}
+}
+
+// start emits the event opening the stream.
+func (e *Encoder) start() {
+	encoding := e.encoding
+	if encoding == yaml_ANY_ENCODING {
+		encoding = yaml_UTF8_ENCODING
+	}
+	yaml_stream_start_event_initialize(&e.event, encoding)
 	e.emit()
+	e.started = true
+}
 
-	return e
+// AutoFlow enables emitting short leaf collections in flow style.
+// A sequence or mapping whose elements are all scalars is written as
+// `[a, b]` or `{a: 1}` when it holds at most maxItems elements and its
+// flow rendering fits within maxWidth columns. Larger collections keep
+// the block style. A maxItems of zero disables the heuristic.
+func (e *Encoder) AutoFlow(maxItems, maxWidth int) {
+	e.autoFlowItems = maxItems
+	e.autoFlowWidth = maxWidth
+}
+
+// MultilineStyle sets the style used for strings containing line breaks.
+// LiteralStyle (the default) writes them as `|` blocks, FoldedStyle as `>`
+// blocks and DoubleQuotedStyle as escaped, quoted scalars. Struct fields
+// tagged with `,literal` or `,folded` override this setting.
+func (e *Encoder) MultilineStyle(style ScalarStyle) {
+	switch style {
+	case LiteralStyle, FoldedStyle, DoubleQuotedStyle:
+		e.multilineStyle = yaml_scalar_style_t(style)
+	default:
+		e.multilineStyle = yaml_ANY_SCALAR_STYLE
+	}
+}
+
+// LongStrings makes the encoder write the strings without line breaks
+// that are longer than length characters in style, which is FoldedStyle
+// or LiteralStyle, instead of as plain or quoted scalars folded at the
+// line width. A length of zero, the default, disables this. Mapping keys
+// and struct fields tagged with a style are not affected.
+func (e *Encoder) LongStrings(length int, style ScalarStyle) {
+	switch style {
+	case FoldedStyle, LiteralStyle:
+		e.longLength = length
+		e.longStyle = yaml_scalar_style_t(style)
+	default:
+		e.longLength = 0
+	}
 }
 
+// Indent sets the number of spaces, from 2 to 9, that each level of block
+// collections is indented by. Other numbers select 2, the default.
+func (e *Encoder) Indent(spaces int) {
+	yaml_emitter_set_indent(&e.emitter, spaces)
+}
+
+// LineWidth sets the preferred width of the output lines, at which long
+// scalars are folded. Zero selects 80, the default, and a negative width
+// disables folding.
+func (e *Encoder) LineWidth(width int) {
+	yaml_emitter_set_width(&e.emitter, width)
+}
+
+// PlainLineBreaks sets whether plain scalars longer than the line width
+// can be folded onto several lines, as they are by default. Quoted
+// scalars are still folded.
+func (e *Encoder) PlainLineBreaks(allow bool) {
+	e.emitter.no_plain_breaks = !allow
+}
+
+// IndentlessSequences sets whether the block sequences that are values of
+// block mappings are written at the indentation of their keys, as in
+// "key:\n- a\n- b", which is the default and the style of Kubernetes
+// manifests. Without it they are indented under their keys, as in
+// "key:\n  - a\n  - b".
+func (e *Encoder) IndentlessSequences(on bool) {
+	e.emitter.indent_sequences = !on
+}
+
+// CompactSequenceMappings sets whether a mapping that is an item of a
+// block sequence starts on the line of its dash, as in "- key: value",
+// which is the default. Without it, the mapping starts on the next line,
+// indented under the dash.
+func (e *Encoder) CompactSequenceMappings(on bool) {
+	e.emitter.no_compact_mappings = !on
+}
+
+// MaxSimpleKeyLength sets the length of the longest mapping key written as
+// `key: value`; longer keys are written as `? key` followed by `: value`
+// on a line of their own. Zero selects 128, the default; YAML does not
+// allow simple keys longer than 1024 characters.
+func (e *Encoder) MaxSimpleKeyLength(length int) {
+	if length > 1024 {
+		length = 1024
+	}
+	e.emitter.max_simple_key_length = length
+}
+
+// QuoteStrings forces string values to be written as double-quoted
+// scalars, so they can never be reinterpreted as another type by a YAML 1.1
+// parser (e.g. `1.20` or `NO`). Mapping keys and multiline strings are not
+// affected. Struct fields tagged with `,singlequoted` or `,doublequoted`
+// select their quoting individually.
+func (e *Encoder) QuoteStrings(quote bool) {
+	e.quoteStrings = quote
+}
+
+// SingleQuotes makes the encoder quote strings with single quotes rather
+// than double quotes, where it chooses to quote them: strings that would
+// not be read back as strings, and every string with QuoteStrings. Strings
+// that single quotes cannot hold, such as those with characters that must
+// be escaped, are still double-quoted. Struct fields tagged with
+// `,doublequoted` and the styles of Nodes are kept.
+func (e *Encoder) SingleQuotes(prefer bool) {
+	e.singleQuotes = prefer
+}
+
+// TypeStyle sets the style of the strings of type t, such as a Secret type
+// that is always double-quoted or a Script type always written as a
+// literal block. Struct fields tagged with a style override it, mapping
+// keys are not affected, and PlainStyle is only used for strings that
+// would be read back as strings. AnyStyle removes the setting.
+func (e *Encoder) TypeStyle(t reflect.Type, style ScalarStyle) {
+	if style == AnyStyle {
+		delete(e.typeStyles, t)
+		return
+	}
+	if e.typeStyles == nil {
+		e.typeStyles = make(map[reflect.Type]yaml_scalar_style_t)
+	}
+	e.typeStyles[t] = yaml_scalar_style_t(style)
+}
+
+// quotedStyle returns the style of the strings the encoder quotes.
+func (e *Encoder) quotedStyle() yaml_scalar_style_t {
+	if e.singleQuotes {
+		return yaml_SINGLE_QUOTED_SCALAR_STYLE
+	}
+	return yaml_DOUBLE_QUOTED_SCALAR_STYLE
+}
+
+// AllowUnicode controls how non-ASCII characters are written. By default
+// they are escaped (`\xE9`, `\u65E5`, `\U0001F600`) inside double-quoted
+// scalars so that the output is plain ASCII. When allowed, they are written
+// as UTF-8 and the scalars keep their usual style.
+func (e *Encoder) AllowUnicode(allow bool) {
+	yaml_emitter_set_unicode(&e.emitter, allow)
+}
+
+// DisplayWidth makes line folding measure unescaped text the way terminals
+// display it: wide East Asian characters and emoji take two columns, and
+// combining marks none. By default every character takes one column.
+func (e *Encoder) DisplayWidth(on bool) {
+	e.emitter.display_width = on
+}
+
+// NullValue sets how nil values are written: "null" (the default), "~",
+// "Null", "NULL" or "" for an empty value, or another null of the set
+// given to Nulls. Other representations are ignored.
+// Struct fields tagged with `,null=~` (or any other null of the set)
+// override this setting.
+func (e *Encoder) NullValue(repr string) {
+	if e.isNull(repr) {
+		e.nullValue = repr
+	}
+}
+
+// TimeFormat sets how times are written: with layout, in the form of the
+// time package, such as time.RFC3339 or "2006-01-02" for dates only, and
+// converted to UTC first when utc is true. By default times are written
+// with time.RFC3339Nano in their own locations. The format also applies to
+// the scalars of Nodes that resolve to timestamps, which are otherwise
+// written as they are.
+func (e *Encoder) TimeFormat(layout string, utc bool) {
+	e.timeLayout = layout
+	e.timeUTC = utc
+}
+
+// Deterministic makes the output depend only on the encoded values, so
+// that semantically identical input is always written byte for byte the
+// same, e.g. for hashing. Mapping keys are always written in sorted order;
+// in addition, a deterministic encoder
+//
+//   - names anchors id001, id002 and so on, ignoring AnchorNames,
+//   - ignores the scalar styles and flow flags of Nodes,
+//   - writes floats in their shortest form, ignoring FloatFormat,
+//     FloatDecimalPoint and FloatSpecials, and
+//   - writes times in UTC.
+func (e *Encoder) Deterministic(on bool) {
+	e.deterministic = on
+}
+
+// A Redaction controls how the encoder writes fields tagged `,secret`.
+type Redaction int
+
+const (
+	// RevealSecrets writes secret fields like any other field.
+	RevealSecrets Redaction = iota
+	// RedactSecrets writes "[REDACTED]" in place of secret values.
+	RedactSecrets
+	// OmitSecrets leaves secret fields out altogether.
+	OmitSecrets
+)
+
+const redacted = "[REDACTED]"
+
+// Redact sets how struct fields tagged `,secret` are written. Secrets are
+// revealed by default, so enable redaction on encoders whose output ends up
+// in logs.
+func (e *Encoder) Redact(mode Redaction) {
+	e.redaction = mode
+}
+
+// An UnsupportedPolicy controls how the encoder handles values that have
+// no representation in YAML: channels, functions, complex numbers and
+// unsafe pointers, which do not implement Marshaler.
+type UnsupportedPolicy int
+
+const (
+	// FailOnUnsupported makes encoding such values fail, with an error
+	// naming the path of the value, such as "servers[0].handler".
+	FailOnUnsupported UnsupportedPolicy = iota
+	// SkipUnsupported leaves out the struct fields, map entries and
+	// sequence elements holding such values.
+	SkipUnsupported
+)
+
+// UnsupportedValues sets how values that cannot be written are handled.
+// By default encoding them fails.
+func (e *Encoder) UnsupportedValues(policy UnsupportedPolicy) {
+	e.unsupported = policy
+}
+
+// skipped reports whether v is left out under SkipUnsupported.
+func (e *Encoder) skipped(v reflect.Value) bool {
+	if e.unsupported != SkipUnsupported {
+		return false
+	}
+	for {
+		vt := v.Type()
+		if vt.Implements(marshalerType) || vt.Implements(eventMarshalerType) || registeredTag(vt) != "" {
+			return false
+		}
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if v.IsNil() {
+				return false
+			}
+			v = v.Elem()
+		case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// pushKey extends the path with a mapping key, returning the length to
+// cut it back to.
+func (e *Encoder) pushKey(key interface{}) int {
+	n := len(e.path)
+	if n > 0 {
+		e.path = append(e.path, '.')
+	}
+	if s, ok := key.(string); ok {
+		e.path = append(e.path, s...)
+	} else {
+		e.path = append(e.path, fmt.Sprint(key)...)
+	}
+	return n
+}
+
+// pushIndex is like pushKey for the index of an element of a sequence.
+func (e *Encoder) pushIndex(i int) int {
+	n := len(e.path)
+	e.path = append(e.path, '[')
+	e.path = strconv.AppendInt(e.path, int64(i), 10)
+	e.path = append(e.path, ']')
+	return n
+}
+
+// A ControlPolicy controls how the encoder writes strings holding control
+// characters, or other characters that cannot appear in a YAML document.
+type ControlPolicy int
+
+const (
+	// EscapeControls writes such strings double-quoted, with the
+	// characters escaped.
+	EscapeControls ControlPolicy = iota
+	// RejectControls makes encoding such strings fail.
+	RejectControls
+	// BinaryControls writes such strings as !!binary scalars holding their
+	// bytes in base64.
+	BinaryControls
+)
+
+// ControlCharacters sets how strings holding control characters are
+// written. They are escaped by default. Strings that are not valid UTF-8
+// cannot be escaped, and are written as !!binary scalars unless rejected.
+func (e *Encoder) ControlCharacters(policy ControlPolicy) {
+	e.controls = policy
+}
+
+// binaryText reports whether s, which holds characters that cannot appear
+// in a document, is written as a !!binary scalar rather than escaped.
+func (e *Encoder) binaryText(s string) bool {
+	switch {
+	case e.controls == RejectControls:
+		panic(fmt.Errorf("Cannot encode the string %q holding control characters", s))
+	case e.controls == BinaryControls, !utf8.ValidString(s):
+		return true
+	}
+	return false
+}
+
+// FloatFormat sets the strconv format ('g', 'e' or 'f') and precision used
+// for floats. The default, 'g' with a precision of -1, writes the shortest
+// representation that parses back to the same value. Other formats are
+// ignored.
+func (e *Encoder) FloatFormat(format byte, prec int) {
+	switch format {
+	case 'g', 'e', 'f':
+		e.floatFormat = format
+		e.floatPrec = prec
+	}
+}
+
+// FloatDecimalPoint makes every finite float include a decimal point, so
+// that values such as 1 or 1e+06 are written as 1.0 and 1.0e+06 and keep
+// their type when read back.
+func (e *Encoder) FloatDecimalPoint(always bool) {
+	e.floatDecimalPoint = always
+}
+
+// FloatSpecials sets how NaN, positive and negative infinity are written.
+// The defaults are ".nan", "+.inf" and "-.inf"; any capitalisation
+// accepted by the YAML float type (e.g. ".NaN", ".Inf" or "-.INF") may be
+// used. Representations that would not read back as the same value are
+// ignored.
+func (e *Encoder) FloatSpecials(nan, posInf, negInf string) {
+	if strings.ToLower(nan) == ".nan" {
+		e.floatNaN = nan
+	}
+	if l := strings.ToLower(posInf); l == ".inf" || l == "+.inf" {
+		e.floatPosInf = posInf
+	}
+	if strings.ToLower(negInf) == "-.inf" {
+		e.floatNegInf = negInf
+	}
+}
+
+// Encode writes the YAML encoding of v to the stream as a new document.
+// Documents after the first start with a "---" marker. Once Encode or
+// EncodeEvents fails, every later call returns the same error.
+//
+// A struct field with a `comment:"..."` tag is written with the text of the
+// tag as a comment on the lines before its key, one line for each line of
+// the text. Nodes are written with their comments.
 func (e *Encoder) Encode(v interface{}) (err error) {
+	if e.err != nil {
+		return e.err
+	}
+	defer func() { e.err = err }()
 	defer recovery(&err)
 
+	if !e.started {
+		e.startText(v)
+	}
+	yaml_document_start_event_initialize(&e.event, nil, nodeDirectives(v), true)
+	e.emit()
+	e.root(v)
+	yaml_document_end_event_initialize(&e.event, true)
+	e.emit()
+
+	return nil
+}
+
+// root writes v as the root node of a document.
+func (e *Encoder) root(v interface{}) {
+	e.startAnchors()
+	if e.anchorPointers {
+		e.pointers = make(map[pointerKey]*pointerAnchor)
+		e.countPointers("", reflect.ValueOf(v))
+	}
+
+	if e.dedupMinNodes > 0 {
+		e.recording = true
+		e.marshal("", reflect.ValueOf(v), true)
+		e.recording = false
+		e.emitDeduplicated()
+	} else {
+		e.marshal("", reflect.ValueOf(v), true)
+	}
+	if e.anchorPointers {
+		e.endAnchors()
+	}
+}
+
+// EncodeFragment writes v as a bare node to be spliced into a larger,
+// hand-written document: without directives or document markers, and
+// with each of its lines indented by indent spaces. The options of e
+// apply, but its anchors are the fragment's own, and the stream written
+// by Encode is left as it was, so that fragments can be written between
+// its documents.
+func (e *Encoder) EncodeFragment(v interface{}, indent int) (err error) {
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	stream, policy := e.emitter, e.anchorPolicy
+	defer func() {
+		e.fragment, e.emitter = e.emitter, stream
+		e.anchorPolicy = policy
+	}()
+	defer recovery(&err)
+
+	// the fragment is the first document of a stream of its own, written
+	// by an emitter with the options of that of e and buffers of its own
+	f := &e.emitter
+	f.buffer, f.raw_buffer, f.states, f.events, f.indents, f.tag_directives =
+		e.fragment.buffer, e.fragment.raw_buffer, e.fragment.states,
+		e.fragment.events, e.fragment.indents, e.fragment.tag_directives
+	if f.buffer == nil {
+		f.buffer = make([]byte, OUTPUT_BUFFER_SIZE)
+	}
+	yaml_emitter_reset(f)
+	yaml_emitter_set_output_writer(f, e.w)
+
+	yaml_stream_start_event_initialize(&e.event, yaml_UTF8_ENCODING)
+	e.emit()
+	if stream.encoding != yaml_ANY_ENCODING {
+		f.encoding = stream.encoding
+	} else if e.encoding != yaml_ANY_ENCODING {
+		f.encoding = e.encoding
+	}
+	f.base_indent = indent
+
+	e.anchorPolicy = DocumentAnchors
+	yaml_document_start_event_initialize(&e.event, nil, nil, true)
+	e.emit()
+	e.root(v)
+	yaml_document_end_event_initialize(&e.event, true)
+	e.emit()
+
+	if !yaml_emitter_flush(f) {
+		panic(errors.New(f.problem))
+	}
+	return nil
+}
+
+// EncodeSeq writes a document whose root is a sequence of the values that
+// items passes to yield, so that huge sequences stream to the writer
+// without being collected into a slice first. An error returned by yield
+// should be returned by items, which stops the encoding with that error.
+//
+// AnchorPointers applies to each element on its own, and Deduplicate does
+// not apply.
+func (e *Encoder) EncodeSeq(items func(yield func(v interface{}) error) error) (err error) {
 	if e.err != nil {
 		return e.err
 	}
+	defer func() { e.err = err }()
+	defer recovery(&err)
 
-	e.marshal("", reflect.ValueOf(v), true)
+	if !e.started {
+		e.start()
+	}
+	yaml_document_start_event_initialize(&e.event, nil, nil, true)
+	e.emit()
+	yaml_sequence_start_event_initialize(&e.event, nil, nil, true, yaml_BLOCK_SEQUENCE_STYLE)
+	e.emit()
 
-	yaml_document_end_event_initialize(&e.event, true)
+	e.anchorNames = nil
+	yield := func(v interface{}) (err error) {
+		defer recovery(&err)
+		if e.anchorPointers {
+			e.pointers = make(map[pointerKey]*pointerAnchor)
+			e.countPointers("", reflect.ValueOf(v))
+		}
+		e.marshal("", reflect.ValueOf(v), true)
+		return nil
+	}
+	if err := items(yield); err != nil {
+		return err
+	}
+
+	yaml_sequence_end_event_initialize(&e.event)
 	e.emit()
-	e.emitter.open_ended = false
-	yaml_stream_end_event_initialize(&e.event)
+	yaml_document_end_event_initialize(&e.event, true)
 	e.emit()
 
 	return nil
 }
 
 func (e *Encoder) emit() {
+	if e.comment != "" {
+		e.event.head_comment = []byte(e.comment)
+		e.comment = ""
+	}
+	if e.recording {
+		e.events = append(e.events, e.event)
+		return
+	}
+	if e.selfCheck {
+		e.checkEvent()
+	}
+	documentEnd := e.event.event_type == yaml_DOCUMENT_END_EVENT
 	if !yaml_emitter_emit(&e.emitter, &e.event) {
+		if e.emitter.problem != "" {
+			panic(errors.New(e.emitter.problem))
+		}
 		panic("bad emit")
 	}
+	if e.selfCheck && documentEnd {
+		e.endCheck()
+	}
 }
 
 func (e *Encoder) marshal(tag string, v reflect.Value, allowAddr bool) {
 	vt := v.Type()
 
+	if vt == nodeType {
+		n := v.Interface().(Node)
+		if e.anchor != "" && n.Anchor == "" && n.Kind != AliasNode {
+			n.Anchor = string(e.takeAnchor())
+		}
+		if tag != "" && n.Tag == "" && n.Kind != AliasNode {
+			n.Tag = tag
+		}
+		e.emitNode(&n)
+		return
+	}
+
+	if vt == rawYAMLType && !v.IsNil() {
+		e.emitRaw(v.Bytes())
+		return
+	}
+
+	if vt == taggedValueType {
+		tv := v.Interface().(TaggedValue)
+		e.marshal(tv.Tag, reflect.ValueOf(&tv.Value).Elem(), true)
+		return
+	}
+
+	if vt == complexKeyType {
+		e.emitComplexKey(v.String())
+		return
+	}
+
+	if vt.Kind() != reflect.Ptr && tag == "" {
+		if vt.Implements(eventMarshalerType) {
+			e.emitEventMarshaler(v)
+			return
+		}
+		if allowAddr && v.CanAddr() && reflect.PtrTo(vt).Implements(eventMarshalerType) {
+			e.emitEventMarshaler(v.Addr())
+			return
+		}
+	}
+
 	if vt.Implements(marshalerType) {
 		e.emitMarshaler(tag, v)
 		return
@@ -118,6 +948,10 @@ func (e *Encoder) marshal(tag string, v reflect.Value, allowAddr bool) {
 		}
 	}
 
+	if tag == "" {
+		tag = registeredTag(vt)
+	}
+
 	switch v.Kind() {
 	case reflect.Interface:
 		if v.IsNil() {
@@ -130,15 +964,17 @@ func (e *Encoder) marshal(tag string, v reflect.Value, allowAddr bool) {
 	case reflect.Ptr:
 		if v.IsNil() {
 			e.emitNil()
-		} else {
+		} else if !e.emitAlias(v) {
 			e.marshal(tag, v.Elem(), true)
 		}
 	case reflect.Struct:
 		e.emitStruct(tag, v)
-	case reflect.Slice:
+	case reflect.Slice, reflect.Array:
 		e.emitSlice(tag, v)
 	case reflect.String:
+		e.typeStyle = e.typeStyles[vt]
 		e.emitString(tag, v)
+		e.typeStyle = yaml_ANY_SCALAR_STYLE
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		e.emitInt(tag, v)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
@@ -148,17 +984,51 @@ func (e *Encoder) marshal(tag string, v reflect.Value, allowAddr bool) {
 	case reflect.Bool:
 		e.emitBool(tag, v)
 	default:
-		panic("Can't marshal type yet: " + v.Type().String())
+		panic(fmt.Errorf("Cannot encode the %s of %s", v.Type(), e.where()))
+	}
+}
+
+// where names the value at the path being encoded in errors.
+func (e *Encoder) where() string {
+	if len(e.path) > 0 {
+		return strconv.Quote(string(e.path))
 	}
+	return "the document"
+}
+
+func (e *Encoder) marshalKey(k reflect.Value) {
+	e.key = true
+	e.marshal("", k, true)
+	e.key = false
 }
 
 func (e *Encoder) emitMap(tag string, v reflect.Value) {
+	if e.useAutoFlow(v) {
+		e.flow = true
+	}
+
+	set := isSetType(v.Type())
+	if set && tag == "" {
+		tag = shortTags[yaml_SET_TAG]
+	}
+
 	e.mapping(tag, func() {
-		var keys stringValues = v.MapKeys()
-		sort.Sort(keys)
+		keys := e.mapKeys(v)
+		if ranks := e.keyOrder(v.Type()); ranks != nil {
+			orderMapKeys(keys, ranks)
+		}
 		for _, k := range keys {
-			e.marshal("", k, true)
-			e.marshal("", v.MapIndex(k), true)
+			if !set && e.skipped(v.MapIndex(k.key)) {
+				continue
+			}
+			e.marshalKey(k.value)
+			n := e.pushKey(k.value.Interface())
+			if set {
+				e.emitNil()
+			} else {
+				e.marshal("", v.MapIndex(k.key), true)
+			}
+			e.path = e.path[:n]
 		}
 	})
 }
@@ -170,25 +1040,65 @@ func (e *Encoder) emitStruct(tag string, v reflect.Value) {
 	}
 
 	fields := cachedTypeFields(v.Type())
+	if ranks := e.keyOrder(v.Type()); ranks != nil {
+		fields = orderFields(fields, ranks)
+	}
 
 	e.mapping(tag, func() {
+		oldStyle, oldNull, oldTagged := e.fieldStyle, e.fieldNull, e.fieldTagged
+		defer func() { e.fieldStyle, e.fieldNull, e.fieldTagged = oldStyle, oldNull, oldTagged }()
+
 		for _, f := range fields {
 			fv := fieldByIndex(v, f.index)
-			if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) {
+			if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) || f.omitZero && isZeroValue(fv) ||
+				f.omitNil && isNilValue(fv) {
+				continue
+			}
+			if f.secret && e.redaction == OmitSecrets || e.skipped(fv) {
 				continue
 			}
+			if f.secret && e.redaction == RedactSecrets {
+				fv = reflect.ValueOf(redacted)
+			}
 
-			e.marshal("", reflect.ValueOf(f.name), true)
+			e.fieldStyle = yaml_ANY_SCALAR_STYLE
+			e.fieldTagged = false
+			e.comment = f.comment
+			e.marshalKey(reflect.ValueOf(f.name))
+			if f.alias != "" {
+				yaml_alias_event_initialize(&e.event, []byte(f.alias))
+				e.emit()
+				continue
+			}
+			if f.anchor != "" {
+				e.defineAnchor(f.anchor)
+			}
 			e.flow = f.flow
+			e.fieldStyle = f.style
+			e.fieldNull = f.null
+			e.fieldTagged = f.explicit
+			n := e.pushKey(f.name)
 			e.marshal("", fv, true)
+			e.path = e.path[:n]
+			e.anchor = ""
 		}
 	})
 }
 
 func (e *Encoder) emitTime(tag string, v reflect.Value) {
-	t := v.Interface().(time.Time)
-	bytes, _ := t.MarshalText()
-	e.emitScalar(string(bytes), "", tag, yaml_PLAIN_SCALAR_STYLE)
+	e.emitScalar(e.formatTime(v.Interface().(time.Time)), "", tag, yaml_PLAIN_SCALAR_STYLE)
+}
+
+// formatTime formats t following TimeFormat.
+func (e *Encoder) formatTime(t time.Time) string {
+	if e.timeUTC || e.deterministic {
+		t = t.UTC()
+	}
+	if e.timeLayout != "" {
+		return t.Format(e.timeLayout)
+	}
+	b, _ := t.MarshalText()
+	return string(b)
 }
 
 func isEmptyValue(v reflect.Value) bool {
@@ -209,6 +1119,32 @@ func isEmptyValue(v reflect.Value) bool {
 	return false
 }
 
+// isZeroValue reports whether v is the zero value of its type, or an
+// IsZeroer whose IsZero returns true, for the omitzero option.
+func isZeroValue(v reflect.Value) bool {
+	if isNilValue(v) {
+		return true
+	}
+	if v.Type().Implements(isZeroerType) {
+		return v.Interface().(IsZeroer).IsZero()
+	}
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(isZeroerType) {
+		return v.Addr().Interface().(IsZeroer).IsZero()
+	}
+	return v.IsZero()
+}
+
+// isNilValue reports whether v is a nil pointer, interface, map or slice.
+// Unlike isEmptyValue, zero scalars and empty (but non-nil) collections are
+// not considered nil.
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
 func (e *Encoder) mapping(tag string, f func()) {
 	implicit := tag == ""
 	style := yaml_BLOCK_MAPPING_STYLE
@@ -216,7 +1152,7 @@ func (e *Encoder) mapping(tag string, f func()) {
 		e.flow = false
 		style = yaml_FLOW_MAPPING_STYLE
 	}
-	yaml_mapping_start_event_initialize(&e.event, nil, []byte(tag), implicit, style)
+	yaml_mapping_start_event_initialize(&e.event, e.takeAnchor(), []byte(tag), implicit, style)
 	e.emit()
 
 	f()
@@ -231,32 +1167,128 @@ func (e *Encoder) emitSlice(tag string, v reflect.Value) {
 		return
 	}
 
+	if v.Type() == orderedMapType || v.Type() == pairsType {
+		e.emitPairs(tag, v)
+		return
+	}
+
+	if e.useAutoFlow(v) {
+		e.flow = true
+	}
+
 	implicit := tag == ""
 	style := yaml_BLOCK_SEQUENCE_STYLE
 	if e.flow {
 		e.flow = false
 		style = yaml_FLOW_SEQUENCE_STYLE
 	}
-	yaml_sequence_start_event_initialize(&e.event, nil, []byte(tag), implicit, style)
+	yaml_sequence_start_event_initialize(&e.event, e.takeAnchor(), []byte(tag), implicit, style)
 	e.emit()
 
-	n := v.Len()
-	for i := 0; i < n; i++ {
+	for i := 0; i < v.Len(); i++ {
+		if e.skipped(v.Index(i)) {
+			continue
+		}
+		n := e.pushIndex(i)
 		e.marshal("", v.Index(i), true)
+		e.path = e.path[:n]
 	}
 
 	yaml_sequence_end_event_initialize(&e.event)
 	e.emit()
 }
 
+// textWidth returns the number of columns s takes when written unescaped.
+func (e *Encoder) textWidth(s string) int {
+	if !e.emitter.display_width {
+		return utf8.RuneCountInString(s)
+	}
+	n := 0
+	for _, r := range s {
+		n += display_width(r)
+	}
+	return n
+}
+
+// useAutoFlow reports whether the slice or map v is small enough to be
+// emitted in flow style under the AutoFlow settings.
+func (e *Encoder) useAutoFlow(v reflect.Value) bool {
+	if e.autoFlowItems <= 0 || v.Len() == 0 || v.Len() > e.autoFlowItems {
+		return false
+	}
+
+	// brackets plus ", " between each element
+	width := 2 + 2*(v.Len()-1)
+	if v.Kind() == reflect.Map {
+		for _, k := range v.MapKeys() {
+			kw, ok := e.flowScalarWidth(k)
+			if !ok {
+				return false
+			}
+			vw, ok := e.flowScalarWidth(v.MapIndex(k))
+			if !ok {
+				return false
+			}
+			width += kw + vw + 2
+		}
+	} else {
+		for i := 0; i < v.Len(); i++ {
+			w, ok := e.flowScalarWidth(v.Index(i))
+			if !ok {
+				return false
+			}
+			width += w
+		}
+	}
+
+	return e.autoFlowWidth <= 0 || width <= e.autoFlowWidth
+}
+
+// flowScalarWidth estimates the rendered width of v in a flow collection.
+// It returns false when v is not a scalar.
+func (e *Encoder) flowScalarWidth(v reflect.Value) (int, bool) {
+	v, k := getElem(v)
+	switch k {
+	case reflect.Invalid, reflect.Interface, reflect.Ptr:
+		return len("null"), true
+	case reflect.String:
+		s := v.String()
+		if v.Type() == numberType || v.Type() == intStringType {
+			return len(s), true
+		}
+		if s == "" || multiline.MatchString(s) || nonPrintable.MatchString(s) ||
+			strings.ContainsAny(s, ",[]{}:#'\"") {
+			return e.textWidth(s) + 2, true
+		}
+		return e.textWidth(s), true
+	case reflect.Bool:
+		return len(strconv.FormatBool(v.Bool())), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return len(strconv.FormatInt(v.Int(), 10)), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return len(strconv.FormatUint(v.Uint(), 10)), true
+	case reflect.Float32, reflect.Float64:
+		return len(strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())), true
+	case reflect.Struct:
+		if v.Type() == timeTimeType {
+			return len(e.formatTime(v.Interface().(time.Time))), true
+		}
+	}
+
+	return 0, false
+}
+
 func (e *Encoder) emitBase64(tag string, v reflect.Value) {
-	if v.IsNil() {
+	var s []byte
+	if v.Kind() == reflect.String {
+		s = []byte(v.String())
+	} else if v.IsNil() {
 		e.emitNil()
 		return
+	} else {
+		s = v.Bytes()
 	}
 
-	s := v.Bytes()
-
 	dst := make([]byte, base64.StdEncoding.EncodedLen(len(s)))
 
 	base64.StdEncoding.Encode(dst, s)
@@ -264,30 +1296,70 @@ func (e *Encoder) emitBase64(tag string, v reflect.Value) {
 }
 
 func (e *Encoder) emitString(tag string, v reflect.Value) {
-	var style yaml_scalar_style_t
 	s := v.String()
+	if v.Type() == numberType || v.Type() == intStringType {
+		if !nonPrintable.MatchString(s) && utf8.ValidString(s) {
+			e.emitScalar(s, "", tag, yaml_PLAIN_SCALAR_STYLE)
+			return
+		}
+	}
+	e.emitText(tag, s)
+}
 
-	if nonPrintable.MatchString(s) {
-		e.emitBase64(tag, v)
-		return
+// emitText writes a string, in a style that keeps it from resolving to
+// another type.
+func (e *Encoder) emitText(tag string, s string) {
+	if tag == "" && e.fieldTagged && !e.key {
+		tag = yaml_STR_TAG
 	}
 
-	if v.Type() == numberType {
-		style = yaml_PLAIN_SCALAR_STYLE
-	} else {
-		event := yaml_event_t{
-			implicit: true,
-			value:    []byte(s),
+	escape := false
+	if nonPrintable.MatchString(s) || !utf8.ValidString(s) {
+		if e.binaryText(s) {
+			e.emitBase64(tag, reflect.ValueOf(s))
+			return
 		}
+		escape = true
+	}
+
+	var style yaml_scalar_style_t
+	event := yaml_event_t{
+		implicit: true,
+		value:    []byte(s),
+	}
 
-		rtag, _ := resolveInterface(event, false)
-		if tag == "" && rtag != yaml_STR_TAG {
-			style = yaml_DOUBLE_QUOTED_SCALAR_STYLE
-		} else if multiline.MatchString(s) {
+	rtag, ok := e.implicitTag(s)
+	if !ok {
+		rtag, _ = resolveInterface(event, false)
+	}
+	if tag == "" && rtag != yaml_STR_TAG {
+		style = e.quotedStyle()
+	} else if multiline.MatchString(s) {
+		style = e.multilineStyle
+		if e.fieldStyle == yaml_LITERAL_SCALAR_STYLE || e.fieldStyle == yaml_FOLDED_SCALAR_STYLE {
+			style = e.fieldStyle
+		}
+		if style == yaml_ANY_SCALAR_STYLE {
 			style = yaml_LITERAL_SCALAR_STYLE
-		} else {
-			style = yaml_PLAIN_SCALAR_STYLE
 		}
+	} else if e.longLength > 0 && !e.key && e.fieldStyle == yaml_ANY_SCALAR_STYLE &&
+		utf8.RuneCountInString(s) > e.longLength {
+		style = e.longStyle
+	} else if e.quoteStrings && !e.key {
+		style = e.quotedStyle()
+	} else {
+		style = yaml_PLAIN_SCALAR_STYLE
+	}
+
+	if (e.fieldStyle == yaml_SINGLE_QUOTED_SCALAR_STYLE || e.fieldStyle == yaml_DOUBLE_QUOTED_SCALAR_STYLE) && !e.key {
+		style = e.fieldStyle
+	} else if e.fieldStyle == yaml_ANY_SCALAR_STYLE && e.typeStyle != yaml_ANY_SCALAR_STYLE && !e.key {
+		if e.typeStyle != yaml_PLAIN_SCALAR_STYLE || tag != "" || rtag == yaml_STR_TAG {
+			style = e.typeStyle
+		}
+	}
+	if escape {
+		style = yaml_DOUBLE_QUOTED_SCALAR_STYLE
 	}
 
 	e.emitScalar(s, "", tag, style)
@@ -299,52 +1371,105 @@ func (e *Encoder) emitBool(tag string, v reflect.Value) {
 }
 
 func (e *Encoder) emitInt(tag string, v reflect.Value) {
+	if name, ok := enumName(v); ok {
+		e.emitText(tag, name)
+		return
+	}
 	s := strconv.FormatInt(v.Int(), 10)
 	e.emitScalar(s, "", tag, yaml_PLAIN_SCALAR_STYLE)
 }
 
 func (e *Encoder) emitUint(tag string, v reflect.Value) {
+	if name, ok := enumName(v); ok {
+		e.emitText(tag, name)
+		return
+	}
 	s := strconv.FormatUint(v.Uint(), 10)
 	e.emitScalar(s, "", tag, yaml_PLAIN_SCALAR_STYLE)
 }
 
 func (e *Encoder) emitFloat(tag string, v reflect.Value) {
-	f := v.Float()
+	e.emitFloatBits(tag, v.Float(), v.Type().Bits())
+}
+
+// emitFloatBits writes a float of the given size in bits.
+func (e *Encoder) emitFloatBits(tag string, f float64, bits int) {
+	nan, posInf, negInf := e.floatNaN, e.floatPosInf, e.floatNegInf
+	if e.deterministic {
+		nan, posInf, negInf = ".nan", "+.inf", "-.inf"
+	}
 
 	var s string
 	switch {
 	case math.IsNaN(f):
-		s = ".nan"
+		s = nan
 	case math.IsInf(f, 1):
-		s = "+.inf"
+		s = posInf
 	case math.IsInf(f, -1):
-		s = "-.inf"
+		s = negInf
+	case e.deterministic:
+		s = strconv.FormatFloat(f, 'g', -1, bits)
 	default:
-		s = strconv.FormatFloat(f, 'g', -1, v.Type().Bits())
+		s = strconv.FormatFloat(f, e.floatFormat, e.floatPrec, bits)
+		if e.floatDecimalPoint && !strings.Contains(s, ".") {
+			if i := strings.IndexByte(s, 'e'); i >= 0 {
+				s = s[:i] + ".0" + s[i:]
+			} else {
+				s += ".0"
+			}
+		}
 	}
 
 	e.emitScalar(s, "", tag, yaml_PLAIN_SCALAR_STYLE)
 }
 
 func (e *Encoder) emitNil() {
-	e.emitScalar("null", "", "", yaml_PLAIN_SCALAR_STYLE)
+	null := e.nullValue
+	if e.fieldNull != nil && e.isNull(*e.fieldNull) {
+		null = *e.fieldNull
+	}
+	if !e.isNull(null) {
+		e.emitScalar("", "", yaml_NULL_TAG, yaml_PLAIN_SCALAR_STYLE)
+		return
+	}
+	e.emitScalar(null, "", "", yaml_PLAIN_SCALAR_STYLE)
 }
 
 func (e *Encoder) emitScalar(value, anchor, tag string, style yaml_scalar_style_t) {
+	tagged := e.fieldTagged && !e.key
+	if tag == "" && tagged {
+		tag = e.resolvedTag(value)
+	}
+
 	implicit := tag == ""
-	if !implicit {
+	if !implicit && !tagged {
 		style = yaml_PLAIN_SCALAR_STYLE
 	}
+	style = e.quotedMarkers(value, style)
 
 	stag := shortTags[tag]
 	if stag == "" {
 		stag = tag
 	}
 
+	if anchor == "" {
+		anchor = string(e.takeAnchor())
+	}
+
 	yaml_scalar_event_initialize(&e.event, []byte(anchor), []byte(stag), []byte(value), implicit, implicit, style)
 	e.emit()
 }
 
+// resolvedTag returns the tag a plain scalar with the given value
+// resolves to, for fields tagged `,tagged`.
+func (e *Encoder) resolvedTag(value string) string {
+	if tag, ok := e.implicitTag(value); ok {
+		return tag
+	}
+	tag, _ := resolveInterface(yaml_event_t{implicit: true, value: []byte(value)}, false)
+	return tag
+}
+
 func (e *Encoder) emitMarshaler(tag string, v reflect.Value) {
 	if v.Kind() == reflect.Ptr && v.IsNil() {
 		e.emitNil()
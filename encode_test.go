@@ -17,7 +17,11 @@ package candiedyaml
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"math"
+	"os"
+	"reflect"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -60,6 +64,119 @@ var _ = Describe("Encode", func() {
 
 		})
 
+		Context("with a multiline style", func() {
+			It("folds multiline strings", func() {
+				enc.MultilineStyle(FoldedStyle)
+				err := enc.Encode("a\nc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`>-
+  a
+
+  c
+`))
+
+			})
+
+			It("quotes multiline strings", func() {
+				enc.MultilineStyle(DoubleQuotedStyle)
+				err := enc.Encode("a\nc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`"a\nc"
+`))
+
+			})
+
+			It("uses the style from the field tag", func() {
+				type script struct {
+					Run  string `yaml:"run,literal"`
+					Note string `yaml:"note,folded"`
+					Name string `yaml:"name,literal"`
+				}
+
+				enc.MultilineStyle(DoubleQuotedStyle)
+				err := enc.Encode(script{Run: "make\nmake test\n", Note: "a\nb", Name: "build"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`run: |
+  make
+  make test
+note: >-
+  a
+
+  b
+name: build
+`))
+
+			})
+		})
+
+		Context("quoting", func() {
+			type release struct {
+				Version string `yaml:"version,singlequoted"`
+				Country string `yaml:"country,doublequoted"`
+				Name    string `yaml:"name"`
+				Count   int    `yaml:"count"`
+			}
+
+			It("quotes fields tagged for quoting", func() {
+				err := enc.Encode(release{Version: "v1", Country: "SE", Name: "go", Count: 1})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`version: 'v1'
+country: "SE"
+name: go
+count: 1
+`))
+
+			})
+
+			It("quotes the values but not the keys of maps tagged for quoting", func() {
+				type labels struct {
+					Single map[string]string `yaml:"single,singlequoted"`
+					Double map[string]string `yaml:"double,doublequoted"`
+				}
+
+				err := enc.Encode(labels{Single: map[string]string{"k": "v"}, Double: map[string]string{"k": "v"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`single:
+  k: 'v'
+double:
+  k: "v"
+`))
+
+			})
+
+			It("quotes all string values", func() {
+				enc.QuoteStrings(true)
+				err := enc.Encode(map[string]interface{}{"version": "1.20", "country": "NO", "count": 1})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`count: 1
+country: "NO"
+version: "1.20"
+`))
+
+			})
+
+			It("prefers single quotes when asked to", func() {
+				enc.SingleQuotes(true)
+				err := enc.Encode(map[string]string{"a": "true", "b": "it's 1", "c": "x: y", "d": "tab\there"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`a: 'true'
+b: it's 1
+c: 'x: y'
+d: "tab\there"
+`))
+			})
+
+			It("quotes all string values with single quotes", func() {
+				enc.SingleQuotes(true)
+				enc.QuoteStrings(true)
+				err := enc.Encode(map[string]string{"a": "1.20", "b": "it's"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`a: '1.20'
+b: 'it''s'
+`))
+			})
+		})
+
 		It("handles strings that match known scalars", func() {
 			err := enc.Encode("true")
 			Expect(err).NotTo(HaveOccurred())
@@ -83,6 +200,19 @@ var _ = Describe("Encode", func() {
 				Expect(buf.String()).To(Equal("13\n"))
 			})
 
+			It("handles IntStrings", func() {
+				err := enc.Encode(map[string]IntString{
+					"mode": FormatIntString(0755, 8),
+					"mask": "0xFF",
+					"neg":  FormatIntString(-10, 16),
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`mask: 0xFF
+mode: 0o755
+neg: -0xa
+`))
+			})
+
 			It("handles uints", func() {
 				err := enc.Encode(uint64(1))
 				Expect(err).NotTo(HaveOccurred())
@@ -90,6 +220,47 @@ var _ = Describe("Encode", func() {
 			})
 		})
 
+		Context("non-ASCII characters", func() {
+			It("escapes them by default", func() {
+				err := enc.Encode("héllo 日本 😀")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`"h\xE9llo \u65E5\u672C \U0001F600"
+`))
+			})
+
+			It("writes UTF-8 when allowed", func() {
+				enc.AllowUnicode(true)
+				err := enc.Encode(map[string]string{"é": "héllo 日本 😀"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`é: héllo 日本 😀
+`))
+			})
+
+			It("still escapes line breaks", func() {
+				enc.AllowUnicode(true)
+				err := enc.Encode("a\u0085b")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`"a\Nb"
+`))
+			})
+
+			It("counts characters when folding lines", func() {
+				enc.AllowUnicode(true)
+				text := strings.TrimSpace(strings.Repeat("日本語 ", 15))
+				Expect(enc.Encode(map[string]string{"a": text})).To(Succeed())
+				Expect(buf.String()).To(Equal("a: " + text + "\n"))
+			})
+
+			It("counts display columns when folding lines", func() {
+				enc.AllowUnicode(true)
+				enc.DisplayWidth(true)
+				text := strings.TrimSpace(strings.Repeat("日本語 ", 15))
+				Expect(enc.Encode(map[string]string{"a": text})).To(Succeed())
+				Expect(buf.String()).To(Equal("a: " + strings.Repeat("日本語 ", 11) + "日本語\n  " +
+					strings.TrimSpace(strings.Repeat("日本語 ", 3)) + "\n"))
+			})
+		})
+
 		Context("handles floats", func() {
 			It("handles float32", func() {
 				err := enc.Encode(float32(1.234))
@@ -115,6 +286,47 @@ var _ = Describe("Encode", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(buf.String()).To(Equal("-.inf\n"))
 			})
+
+			It("uses the configured format and precision", func() {
+				enc.FloatFormat('f', 2)
+				err := enc.Encode([]float64{1, 2.345, 1e6})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`- 1.00
+- 2.35
+- 1000000.00
+`))
+			})
+
+			It("always includes a decimal point", func() {
+				enc.FloatDecimalPoint(true)
+				err := enc.Encode([]float64{1, 2.5, 1e21, -3})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`- 1.0
+- 2.5
+- 1.0e+21
+- -3.0
+`))
+			})
+
+			It("uses the configured special values", func() {
+				enc.FloatSpecials(".NaN", ".Inf", "-.INF")
+				err := enc.Encode([]float64{math.NaN(), math.Inf(1), math.Inf(-1)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`- .NaN
+- .Inf
+- -.INF
+`))
+			})
+
+			It("ignores special values that do not round-trip", func() {
+				enc.FloatSpecials("NaN", "inf", "-Infinity")
+				err := enc.Encode([]float64{math.NaN(), math.Inf(1), math.Inf(-1)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`- .nan
+- +.inf
+- -.inf
+`))
+			})
 		})
 
 		It("handles bools", func() {
@@ -343,6 +555,33 @@ name: Mark McGwire
 `))
 		})
 
+		It("sorts numeric keys by value", func() {
+			err := enc.Encode(map[int]string{10: "ten", 2: "two", -1: "minus one"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buf.String()).To(Equal("-1: minus one\n2: two\n10: ten\n"))
+		})
+
+		It("writes TextMarshaler and Stringer keys as strings, sorted by them", func() {
+			err := enc.Encode(map[textKey]int{{"b", 1}: 1, {"a", 2}: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal("a-2: 2\nb-1: 1\n"))
+
+			out, err := Marshal(map[*stringerKey]int{{"y"}: 1, {"x"}: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal("key x: 2\nkey y: 1\n"))
+		})
+
+		It("fails on keys written the same way", func() {
+			err := enc.Encode(map[string]map[interface{}]int{"m": {textKey{"a", 1}: 1, "a-1": 2}})
+			Expect(err).To(MatchError(`Cannot encode the map of "m", whose keys {a 1} and "a-1" are both written as "a-1"`))
+		})
+
+		It("fails on keys that cannot be written", func() {
+			err := enc.Encode(map[string]map[complex128]int{"m": {1i: 1}})
+			Expect(err).To(MatchError(`Cannot encode the key (0+1i) of "m": a complex128 is not a mapping key`))
+		})
+
 		It("encodes mix types", func() {
 			err := enc.Encode(&map[string]interface{}{
 				"name": "Mark McGwire",
@@ -358,6 +597,65 @@ name: Mark McGwire
 		})
 	})
 
+	Context("Complex keys", func() {
+		It("encodes array keys in the explicit form", func() {
+			err := enc.Encode(map[[2]string]int{{"a", "b"}: 1})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buf.String()).To(Equal(`? - a
+  - b
+: 1
+`))
+		})
+
+		It("encodes struct keys in the explicit form", func() {
+			type point struct {
+				X, Z int
+			}
+			err := enc.Encode(map[point]string{{1, 2}: "a"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buf.String()).To(Equal(`? X: 1
+  Z: 2
+: a
+`))
+		})
+
+		It("encodes long keys in the explicit form", func() {
+			key := strings.Repeat("k", 200)
+			err := enc.Encode(map[string]int{key: 1})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buf.String()).To(Equal("? " + key + "\n: 1\n"))
+		})
+
+		It("encodes ComplexKeys as their value", func() {
+			err := enc.Encode(map[interface{}]int{
+				ComplexKey("[a, b]"):   1,
+				ComplexKey("{x: [1]}"): 2,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buf.String()).To(Equal(`? [a, b]
+: 1
+? {x: [1]}
+: 2
+`))
+		})
+
+		It("round trips complex keys", func() {
+			f, _ := os.Open("fixtures/specification/example2_11.yaml")
+			var v interface{}
+			Expect(NewDecoder(f).Decode(&v)).To(Succeed())
+
+			Expect(enc.Encode(v)).To(Succeed())
+
+			var r interface{}
+			Expect(Unmarshal(buf.Bytes(), &r)).To(Succeed())
+			Expect(r).To(Equal(v))
+		})
+	})
+
 	Context("Sequence of Maps", func() {
 		It("encodes", func() {
 			err := enc.Encode([]map[string]interface{}{
@@ -442,6 +740,54 @@ name:
 		})
 	})
 
+	Context("Auto flow", func() {
+		BeforeEach(func() {
+			enc.AutoFlow(3, 40)
+		})
+
+		It("flows small leaf sequences and maps", func() {
+			err := enc.Encode(map[string]interface{}{
+				"tags":   []string{"a", "b"},
+				"limits": map[string]int{"cpu": 2},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`limits: {cpu: 2}
+tags: [a, b]
+`))
+
+		})
+
+		It("keeps collections over the item threshold in block style", func() {
+			err := enc.Encode(map[string][]int{"ports": {80, 443, 8080, 8443}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`ports:
+- 80
+- 443
+- 8080
+- 8443
+`))
+
+		})
+
+		It("keeps collections over the width threshold in block style", func() {
+			err := enc.Encode([]string{"a-rather-long-value", "another-long-value"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`- a-rather-long-value
+- another-long-value
+`))
+
+		})
+
+		It("keeps collections with nested collections in block style", func() {
+			err := enc.Encode([][]string{{"a"}, {"b"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`- [a]
+- [b]
+`))
+
+		})
+	})
+
 	Context("Omit empty", func() {
 		It("omits nil ptrs", func() {
 			type i struct {
@@ -460,101 +806,1219 @@ name:
 
 		})
 
+		It("keeps structs and times, without consulting IsZero", func() {
+			type inner struct{ Count int }
+			type o struct {
+				A optional  `yaml:"a,omitempty"`
+				B inner     `yaml:"b,omitempty"`
+				C time.Time `yaml:"c,omitempty"`
+			}
+
+			err := enc.Encode(&o{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`a:
+  Set: false
+  Value: 0
+b:
+  Count: 0
+c: 0001-01-01T00:00:00Z
+`))
+		})
 	})
 
-	Context("Skip field", func() {
-		It("does not include the field", func() {
-			type a struct {
-				B string `yaml:"-"`
-				C string
+	Context("Omit zero", func() {
+		It("consults IsZero", func() {
+			type o struct {
+				A optional    `yaml:"a,omitzero"`
+				B optional    `yaml:"b,omitzero"`
+				C *optional   `yaml:"c,omitzero"`
+				D ptrOptional `yaml:"d,omitzero"`
+				E ptrOptional `yaml:"e,omitzero"`
+				F time.Time   `yaml:"f,omitzero"`
 			}
 
-			err := enc.Encode(a{B: "b", C: "c"})
+			err := enc.Encode(&o{
+				A: optional{Set: true},
+				C: &optional{},
+				E: ptrOptional{Set: true, Value: 1},
+			})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(buf.String()).To(Equal(`C: c
+			Expect(buf.String()).To(Equal(`a:
+  Set: true
+  Value: 0
+e:
+  Set: true
+  Value: 1
 `))
+		})
 
+		It("omits the zero values of other types", func() {
+			type inner struct{ Count int }
+			type o struct {
+				A int               `yaml:"a,omitzero"`
+				B inner             `yaml:"b,omitzero"`
+				C []string          `yaml:"c,omitzero"`
+				D []string          `yaml:"d,omitzero"`
+				E map[string]string `yaml:"e,omitzero"`
+				F inner             `yaml:"f,omitzero"`
+			}
+
+			err := enc.Encode(&o{D: []string{}, F: inner{Count: 1}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`d: []
+f:
+  Count: 1
+`))
 		})
 	})
 
-	Context("Marshaler support", func() {
-		Context("Receiver is a value", func() {
-			It("uses the Marshaler interface when a value", func() {
-				err := enc.Encode(hasMarshaler{Value: 123})
-				Expect(err).NotTo(HaveOccurred())
-				Expect(buf.String()).To(Equal("123\n"))
-			})
-
-			It("uses the Marshaler interface when a pointer", func() {
-				err := enc.Encode(&hasMarshaler{Value: "abc"})
-				Expect(err).NotTo(HaveOccurred())
-				Expect(buf.String()).To(Equal(`abc
+	Context("Omit nil", func() {
+		type section struct {
+			Enabled bool
+		}
+
+		type config struct {
+			Name    string            `yaml:"name,omitnil"`
+			Count   int               `yaml:"count,omitnil"`
+			Section *section          `yaml:"section,omitnil"`
+			Tags    []string          `yaml:"tags,omitnil"`
+			Labels  map[string]string `yaml:"labels,omitnil"`
+		}
+
+		It("omits nil ptrs, slices and maps", func() {
+			err := enc.Encode(config{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`name: ""
+count: 0
 `))
-			})
 
-			Context("when it fails", func() {
-				It("returns an error", func() {
-					err := enc.Encode(&hasMarshaler{Value: "abc", Error: errors.New("fail")})
-					Expect(err).To(MatchError("fail"))
-				})
-			})
 		})
 
-		Context("Receiver is a pointer", func() {
-			It("uses the Marshaler interface when a pointer", func() {
-				err := enc.Encode(&hasPtrMarshaler{Value: map[string]string{"a": "b"}})
-				Expect(err).NotTo(HaveOccurred())
-				Expect(buf.String()).To(Equal(`a: b
+		It("keeps empty but non-nil values", func() {
+			err := enc.Encode(config{
+				Section: &section{},
+				Tags:    []string{},
+				Labels:  map[string]string{},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`name: ""
+count: 0
+section:
+  Enabled: false
+tags: []
+labels: {}
 `))
 
-			})
+		})
+	})
 
-			It("skips the Marshaler when its a value", func() {
-				err := enc.Encode(hasPtrMarshaler{Value: map[string]string{"a": "b"}})
-				Expect(err).NotTo(HaveOccurred())
-				Expect(buf.String()).To(Equal(`Tag: ""
-Value:
-  a: b
-Error: null
-`))
+	Context("Output encoding", func() {
+		It("writes UTF-16LE with a BOM", func() {
+			enc.SetEncoding(UTF16LE)
+			err := enc.Encode(map[string]string{"k": "v"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal("\xff\xfek\x00:\x00 \x00v\x00\n\x00"))
+		})
 
-			})
+		It("writes UTF-16BE with a BOM", func() {
+			enc.SetEncoding(UTF16BE)
+			err := enc.Encode("v")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal("\xfe\xff\x00v\x00\n"))
+		})
 
-			Context("the receiver is nil", func() {
-				var ptr *hasPtrMarshaler
+		It("encodes surrogate pairs", func() {
+			enc.SetEncoding(UTF16BE)
+			enc.AllowUnicode(true)
+			err := enc.Encode("😀")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal("\xfe\xff\xd8\x3d\xde\x00\x00\n"))
+		})
 
-				Context("when it fails", func() {
-					It("returns an error", func() {
-						err := enc.Encode(&hasPtrMarshaler{Value: "abc", Error: errors.New("fail")})
-						Expect(err).To(MatchError("fail"))
-					})
-				})
+		It("round-trips through the decoder", func() {
+			enc.SetEncoding(UTF16LE)
+			enc.AllowUnicode(true)
+			err := enc.Encode(map[string]string{"k": "é日😀"})
+			Expect(err).NotTo(HaveOccurred())
 
-				It("returns a null", func() {
-					err := enc.Encode(ptr)
-					Expect(err).NotTo(HaveOccurred())
-					Expect(buf.String()).To(Equal(`null
-`))
+			var v map[string]string
+			err = NewDecoder(buf).Decode(&v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[string]string{"k": "é日😀"}))
+		})
+	})
 
-				})
+	Context("Line breaks", func() {
+		It("writes CRLF line breaks", func() {
+			enc.SetLineBreak(CRLFBreak)
+			err := enc.Encode(map[string]interface{}{
+				"a": []string{"b", "c"},
+				"d": "line one\nline two\n",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal("a:\r\n- b\r\n- c\r\nd: |\r\n  line one\r\n  line two\r\n"))
+		})
+	})
 
-				It("returns a null value for ptr types", func() {
-					err := enc.Encode(map[string]*hasPtrMarshaler{"a": ptr})
-					Expect(err).NotTo(HaveOccurred())
-					Expect(buf.String()).To(Equal(`a: null
-`))
+	Context("Time format", func() {
+		t := time.Date(2001, 2, 3, 4, 5, 6, 700, time.FixedZone("X", 3600))
 
-				})
+		It("writes times in RFC 3339 with nanoseconds by default", func() {
+			Expect(enc.Encode(t)).To(Succeed())
+			Expect(buf.String()).To(Equal("2001-02-03T04:05:06.0000007+01:00\n"))
+		})
 
-				It("panics when used as a nil interface", func() {
-					Expect(func() { enc.Encode(map[string]Marshaler{"a": ptr}) }).To(Panic())
-				})
-			})
+		It("uses the configured layout", func() {
+			enc.TimeFormat(time.RFC3339, false)
+			Expect(enc.Encode(map[string]time.Time{"at": t})).To(Succeed())
+			Expect(buf.String()).To(Equal("at: 2001-02-03T04:05:06+01:00\n"))
+		})
 
-			Context("the receiver has a nil value", func() {
-				ptr := &hasPtrMarshaler{Value: nil}
+		It("converts times to UTC", func() {
+			enc.TimeFormat("2006-01-02", true)
+			Expect(enc.Encode(time.Date(2001, 2, 3, 0, 30, 0, 0, time.FixedZone("X", 3600)))).To(Succeed())
+			Expect(buf.String()).To(Equal("2001-02-02\n"))
+		})
 
-				It("returns null", func() {
+		It("formats the timestamps of Nodes", func() {
+			var n Node
+			Expect(Unmarshal([]byte("- 2001-02-03T04:05:06+01:00\n- '2001-02-03'\n- !!timestamp 2001-02-03\n- abc\n"), &n)).To(Succeed())
+			enc.TimeFormat(time.RFC3339, true)
+			Expect(enc.Encode(n)).To(Succeed())
+			Expect(buf.String()).To(Equal("- 2001-02-03T03:05:06Z\n- '2001-02-03'\n- !!timestamp 2001-02-03T00:00:00Z\n- abc\n"))
+		})
+	})
+
+	Context("Streaming sequences", func() {
+		It("writes the values yielded as a sequence", func() {
+			err := enc.EncodeSeq(func(yield func(interface{}) error) error {
+				for i := 1; i <= 3; i++ {
+					if err := yield(map[string]int{"id": i}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal("- id: 1\n- id: 2\n- id: 3\n"))
+		})
+
+		It("streams the values to the writer", func() {
+			err := enc.EncodeSeq(func(yield func(interface{}) error) error {
+				for i := 0; i < 10000; i++ {
+					if err := yield(i); err != nil {
+						return err
+					}
+				}
+				Expect(buf.Len()).To(BeNumerically(">", 0))
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Count(buf.String(), "\n")).To(Equal(10000))
+		})
+
+		It("stops with the errors of the items", func() {
+			err := enc.EncodeSeq(func(yield func(interface{}) error) error {
+				return yield(make(chan int))
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Anchors", func() {
+		type step struct {
+			Name string `yaml:"name"`
+			Run  string `yaml:"run"`
+		}
+
+		type pipeline struct {
+			Setup *step   `yaml:"setup"`
+			Steps []*step `yaml:"steps"`
+		}
+
+		var p pipeline
+
+		BeforeEach(func() {
+			setup := &step{Name: "setup", Run: "make deps"}
+			p = pipeline{Setup: setup, Steps: []*step{setup, {Name: "test", Run: "make test"}, setup}}
+		})
+
+		It("expands shared pointers by default", func() {
+			err := enc.Encode(map[string]*step{"a": p.Setup, "b": p.Setup})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`a:
+  name: setup
+  run: make deps
+b:
+  name: setup
+  run: make deps
+`))
+		})
+
+		It("writes shared pointers as aliases", func() {
+			enc.AnchorPointers(true)
+			err := enc.Encode(p)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`setup: &id001
+  name: setup
+  run: make deps
+steps:
+- *id001
+- name: test
+  run: make test
+- *id001
+`))
+		})
+
+		It("names anchors with the configured function", func() {
+			enc.AnchorPointers(true)
+			enc.AnchorNames(func(key string, v interface{}) string {
+				return key + " " + v.(*step).Name
+			})
+			err := enc.Encode(p)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`setup: &setup_setup
+  name: setup
+  run: make deps
+steps:
+- *setup_setup
+- name: test
+  run: make test
+- *setup_setup
+`))
+		})
+
+		It("keeps anchor names unique", func() {
+			a, b := &step{Name: "a"}, &step{Name: "b"}
+			enc.AnchorPointers(true)
+			enc.AnchorNames(func(string, interface{}) string { return "step" })
+			err := enc.Encode([]*step{a, b, a, b})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`- &step
+  name: a
+  run: ""
+- &step_2
+  name: b
+  run: ""
+- *step
+- *step_2
+`))
+		})
+
+		It("encodes cyclic structures", func() {
+			type node struct {
+				Name string `yaml:"name"`
+				Next *node  `yaml:"next"`
+			}
+			n := &node{Name: "loop"}
+			n.Next = n
+
+			enc.AnchorPointers(true)
+			err := enc.Encode(n)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`&id001
+name: loop
+next: *id001
+`))
+		})
+
+		It("writes the anchors and aliases of tagged fields", func() {
+			type service struct {
+				Image   string `yaml:"image,omitempty"`
+				Restart string `yaml:"restart,omitempty"`
+			}
+			type web struct {
+				Defaults *service `yaml:"<<,alias=defaults"`
+				Image    string   `yaml:"image"`
+			}
+			type compose struct {
+				Defaults service `yaml:"x-defaults,anchor=defaults"`
+				Web      web     `yaml:"web"`
+			}
+
+			err := enc.Encode(compose{Defaults: service{Restart: "always"}, Web: web{Image: "nginx"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`x-defaults: &defaults
+  restart: always
+web:
+  <<: *defaults
+  image: nginx
+`))
+
+			var v map[string]interface{}
+			Expect(Unmarshal(buf.Bytes(), &v)).To(Succeed())
+			Expect(v["web"]).To(HaveKeyWithValue("<<", v["x-defaults"]))
+		})
+
+		It("names the anchor of a shared pointer after its tagged field", func() {
+			type config struct {
+				Setup *step   `yaml:"setup,anchor=setup"`
+				Steps []*step `yaml:"steps"`
+			}
+			enc.AnchorPointers(true)
+			err := enc.Encode(config{Setup: p.Setup, Steps: p.Steps[:1]})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`setup: &setup
+  name: setup
+  run: make deps
+steps:
+- *setup
+`))
+		})
+
+		Context("across documents", func() {
+			overlay := func() pipeline {
+				return pipeline{Steps: []*step{p.Setup, {Name: "lint", Run: "make lint"}}}
+			}
+
+			It("writes each document on its own by default", func() {
+				enc.AnchorPointers(true)
+				Expect(enc.Encode(p)).To(Succeed())
+				Expect(enc.Encode(overlay())).To(Succeed())
+				Expect(buf.String()).To(HaveSuffix(`---
+setup: null
+steps:
+- name: setup
+  run: make deps
+- name: lint
+  run: make lint
+`))
+			})
+
+			It("aliases the anchors of earlier documents", func() {
+				enc.AnchorPointers(true)
+				enc.StreamAnchors(ReuseAnchors)
+				Expect(enc.Encode(p)).To(Succeed())
+				Expect(enc.Encode(overlay())).To(Succeed())
+				Expect(buf.String()).To(HaveSuffix(`---
+setup: null
+steps:
+- *id001
+- name: lint
+  run: make lint
+`))
+
+				d := NewDecoder(strings.NewReader(buf.String()))
+				var first, second pipeline
+				Expect(d.Decode(&first)).To(Succeed())
+				Expect(d.Decode(&second)).To(Succeed())
+				Expect(*second.Steps[0]).To(Equal(*p.Setup))
+			})
+
+			It("redefines the anchors of earlier documents", func() {
+				other := &step{Name: "other"}
+				enc.AnchorPointers(true)
+				enc.StreamAnchors(RedefineAnchors)
+				Expect(enc.Encode(p)).To(Succeed())
+				Expect(enc.Encode([]*step{other, other, p.Setup})).To(Succeed())
+				Expect(buf.String()).To(HaveSuffix(`---
+- &id002
+  name: other
+  run: ""
+- *id002
+- &id001
+  name: setup
+  run: make deps
+`))
+			})
+		})
+	})
+
+	Context("Deduplication", func() {
+		type step struct {
+			Name string   `yaml:"name"`
+			Run  []string `yaml:"run"`
+		}
+
+		steps := map[string][]step{
+			"build": {{Name: "checkout", Run: []string{"git fetch", "git checkout"}}, {Name: "make", Run: []string{"make"}}},
+			"test":  {{Name: "checkout", Run: []string{"git fetch", "git checkout"}}, {Name: "test", Run: []string{"make test"}}},
+		}
+
+		It("factors repeated subtrees into anchors", func() {
+			enc.Deduplicate(6)
+			err := enc.Encode(steps)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`build:
+- &id001
+  name: checkout
+  run:
+  - git fetch
+  - git checkout
+- name: make
+  run:
+  - make
+test:
+- *id001
+- name: test
+  run:
+  - make test
+`))
+		})
+
+		It("leaves subtrees below the threshold alone", func() {
+			enc.Deduplicate(8)
+			err := enc.Encode(steps)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).NotTo(ContainSubstring("&"))
+		})
+
+		It("names anchors with the configured function", func() {
+			enc.Deduplicate(3)
+			enc.AnchorNames(func(key string, v interface{}) string {
+				Expect(v).To(BeNil())
+				return key
+			})
+			err := enc.Encode(map[string]interface{}{
+				"a": map[string][]int{"ports": {80, 443}},
+				"b": map[string][]int{"ports": {80, 443}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`a: &a
+  ports:
+  - 80
+  - 443
+b: *a
+`))
+		})
+
+		It("round-trips through the decoder", func() {
+			enc.Deduplicate(3)
+			err := enc.Encode(steps)
+			Expect(err).NotTo(HaveOccurred())
+
+			var v map[string][]step
+			Expect(Unmarshal(buf.Bytes(), &v)).To(Succeed())
+			Expect(v).To(Equal(steps))
+		})
+	})
+
+	Context("Deterministic", func() {
+		encode := func(v interface{}) string {
+			var b bytes.Buffer
+			e := NewEncoder(&b)
+			e.Deterministic(true)
+			e.FloatFormat('e', 3)
+			e.FloatSpecials(".NaN", ".Inf", "-.Inf")
+			Expect(e.Encode(v)).To(Succeed())
+			return b.String()
+		}
+
+		It("sorts keys of the same kind by value", func() {
+			Expect(encode(map[interface{}]int{3: 0, 1: 0, 2: 0, 1.5: 0, -0.5: 0, true: 0, false: 0})).To(Equal(`false: 0
+true: 0
+1: 0
+2: 0
+3: 0
+-0.5: 0
+1.5: 0
+`))
+		})
+
+		It("normalizes node styles", func() {
+			var a, b Node
+			Expect(Unmarshal([]byte("a: 'x'\nb: \"1\"\nc: [1, {d: e}]\n"), &a)).To(Succeed())
+			Expect(Unmarshal([]byte("a: x\nb: '1'\nc:\n- 1\n- d: \"e\"\n"), &b)).To(Succeed())
+
+			Expect(encode(a)).To(Equal(`a: x
+b: "1"
+c:
+- 1
+- d: e
+`))
+			Expect(encode(b)).To(Equal(encode(a)))
+		})
+
+		It("writes floats in their shortest form", func() {
+			Expect(encode([]float64{0.25, 1e21, math.NaN(), math.Inf(1)})).To(Equal(`- 0.25
+- 1e+21
+- .nan
+- +.inf
+`))
+		})
+
+		It("writes times in UTC", func() {
+			t := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+			Expect(encode(t.In(time.FixedZone("X", 3600)))).To(Equal(encode(t)))
+			Expect(encode(t)).To(Equal("2001-02-03T04:05:06Z\n"))
+		})
+
+		It("generates anchor names", func() {
+			shared := &struct{ A int }{1}
+			var b bytes.Buffer
+			e := NewEncoder(&b)
+			e.Deterministic(true)
+			e.AnchorPointers(true)
+			e.AnchorNames(func(string, interface{}) string { return "custom" })
+			Expect(e.Encode([]interface{}{shared, shared})).To(Succeed())
+			Expect(b.String()).To(Equal(`- &id001
+  A: 1
+- *id001
+`))
+		})
+	})
+
+	Context("Secrets", func() {
+		type credentials struct {
+			User     string   `yaml:"user"`
+			Password string   `yaml:"password,secret"`
+			Token    *string  `yaml:"token,secret,omitempty"`
+			Keys     []string `yaml:"keys,secret"`
+		}
+		creds := credentials{User: "bob", Password: "hunter2", Keys: []string{"k1"}}
+
+		It("reveals secrets by default", func() {
+			Expect(enc.Encode(creds)).To(Succeed())
+			Expect(buf.String()).To(Equal(`user: bob
+password: hunter2
+keys:
+- k1
+`))
+		})
+
+		It("redacts secrets", func() {
+			enc.Redact(RedactSecrets)
+			Expect(enc.Encode(creds)).To(Succeed())
+			Expect(buf.String()).To(Equal(`user: bob
+password: '[REDACTED]'
+keys: '[REDACTED]'
+`))
+		})
+
+		It("omits secrets", func() {
+			enc.Redact(OmitSecrets)
+			Expect(enc.Encode(creds)).To(Succeed())
+			Expect(buf.String()).To(Equal(`user: bob
+`))
+		})
+	})
+
+	Context("Explicitly tagged fields", func() {
+		type release struct {
+			Name    string      `yaml:"name"`
+			Date    string      `yaml:"date,tagged"`
+			Build   int         `yaml:"build,tagged"`
+			Notes   string      `yaml:"notes,tagged"`
+			Parent  *string     `yaml:"parent,tagged"`
+			Version interface{} `yaml:"version,tagged"`
+		}
+
+		It("writes the resolved tag of tagged fields", func() {
+			r := release{Name: "2020-01-01", Date: "2020-01-01", Build: 7, Notes: "one\ntwo\n", Version: 1.5}
+			Expect(enc.Encode(r)).To(Succeed())
+			Expect(buf.String()).To(Equal(`name: "2020-01-01"
+date: !!str 2020-01-01
+build: !!int 7
+notes: !!str |
+  one
+  two
+parent: !!null null
+version: !!float 1.5
+`))
+		})
+
+		It("reads back the same values", func() {
+			r := release{Name: "x", Date: "true", Build: 1, Notes: "1.0", Version: "yes"}
+			Expect(enc.Encode(r)).To(Succeed())
+
+			var back release
+			Expect(Unmarshal(buf.Bytes(), &back)).To(Succeed())
+			Expect(back).To(Equal(r))
+		})
+	})
+
+	Context("Unsupported values", func() {
+		type server struct {
+			Name    string
+			Handler func()
+			Weight  complex128
+		}
+
+		It("fails with the path of the value", func() {
+			err := enc.Encode(map[string]interface{}{"servers": []server{{Name: "a"}}})
+			Expect(err).To(MatchError(`Cannot encode the func() of "servers[0].Handler"`))
+
+			enc = NewEncoder(buf)
+			Expect(enc.Encode(make(chan int))).To(MatchError("Cannot encode the chan int of the document"))
+		})
+
+		It("skips them", func() {
+			enc.UnsupportedValues(SkipUnsupported)
+			ch := make(chan int)
+			Expect(enc.Encode(map[string]interface{}{
+				"servers": []server{{Name: "a", Handler: func() {}}},
+				"ch":      &ch,
+				"list":    []interface{}{1, complex(1, 2), 2},
+				"nil":     (*chan int)(nil),
+			})).To(Succeed())
+			Expect(buf.String()).To(Equal(`list:
+- 1
+- 2
+nil: null
+servers:
+- Name: a
+`))
+		})
+	})
+
+	Context("Layout heuristics", func() {
+		long := strings.Repeat("word ", 20) + "end"
+
+		It("writes long strings in a block style", func() {
+			enc.LongStrings(40, FoldedStyle)
+			Expect(enc.Encode(map[string]string{"a": long, "b": "short"})).To(Succeed())
+			Expect(buf.String()).To(Equal("a: >-\n  " + strings.Repeat("word ", 15) + "word\n  word word word word end\nb: short\n"))
+		})
+
+		It("sets the line width", func() {
+			enc.LineWidth(40)
+			Expect(enc.Encode([]string{long})).To(Succeed())
+			Expect(buf.String()).To(Equal("- word word word word word word word word\n  word word word word word word word word\n  word word word word end\n"))
+		})
+
+		It("keeps plain scalars on one line", func() {
+			enc.LineWidth(40)
+			enc.PlainLineBreaks(false)
+			Expect(enc.Encode([]string{long})).To(Succeed())
+			Expect(buf.String()).To(Equal("- " + long + "\n"))
+		})
+
+		It("limits the length of simple keys", func() {
+			enc.MaxSimpleKeyLength(10)
+			Expect(enc.Encode(map[string]int{"short": 1, "a longer key": 2})).To(Succeed())
+			Expect(buf.String()).To(Equal("? a longer key\n: 2\nshort: 1\n"))
+		})
+	})
+
+	Context("Type styles", func() {
+		type secret string
+		type script string
+		type job struct {
+			Token  secret
+			Run    script
+			Name   string
+			Backup secret `yaml:",singlequoted"`
+		}
+
+		It("writes the strings of a type in its style", func() {
+			enc.TypeStyle(reflect.TypeOf(secret("")), DoubleQuotedStyle)
+			enc.TypeStyle(reflect.TypeOf(script("")), LiteralStyle)
+			Expect(enc.Encode(job{Token: "abc", Run: "make test", Name: "ci", Backup: "def"})).To(Succeed())
+			Expect(buf.String()).To(Equal(`Token: "abc"
+Run: |-
+  make test
+Name: ci
+Backup: 'def'
+`))
+		})
+
+		It("keeps the strings that need quoting quoted", func() {
+			enc.TypeStyle(reflect.TypeOf(secret("")), PlainStyle)
+			Expect(enc.Encode(map[secret]secret{"a": "yes", "b": "c"})).To(Succeed())
+			Expect(buf.String()).To(Equal(`a: "yes"
+b: c
+`))
+		})
+
+		It("removes the style of a type", func() {
+			enc.TypeStyle(reflect.TypeOf(secret("")), DoubleQuotedStyle)
+			enc.TypeStyle(reflect.TypeOf(secret("")), AnyStyle)
+			Expect(enc.Encode([]secret{"a"})).To(Succeed())
+			Expect(buf.String()).To(Equal("- a\n"))
+		})
+	})
+
+	Context("Raw YAML", func() {
+		It("writes a flow value in place", func() {
+			Expect(enc.Encode(map[string]interface{}{
+				"a": RawYAML("{x: 1, y: [2, 3]}  # kept"),
+				"b": []interface{}{RawYAML("'quoted'")},
+			})).To(Succeed())
+			Expect(buf.String()).To(Equal(`a: {x: 1, y: [2, 3]}  # kept
+b:
+- 'quoted'
+`))
+		})
+
+		It("re-indents a block value", func() {
+			Expect(enc.Encode(map[string]interface{}{
+				"outer": map[string]interface{}{
+					"inner": RawYAML("x: 1\ny:\n- 2 # two\n"),
+					"text":  RawYAML("|\n  line\n"),
+				},
+			})).To(Succeed())
+			Expect(buf.String()).To(Equal(`outer:
+  inner:
+    x: 1
+    y:
+    - 2 # two
+  text: |
+      line
+`))
+		})
+
+		It("writes a block value as the document", func() {
+			Expect(enc.Encode(RawYAML("x: 1\n"))).To(Succeed())
+			Expect(buf.String()).To(Equal("x: 1\n"))
+		})
+
+		It("rejects text that is not a single value", func() {
+			Expect(enc.Encode([]RawYAML{RawYAML("---\nx: 1")})).To(MatchError(`RawYAML is not a single YAML value: "---\nx: 1"`))
+
+			enc = NewEncoder(buf)
+			Expect(enc.Encode([]RawYAML{RawYAML("[1")})).To(HaveOccurred())
+		})
+	})
+
+	Context("Control characters", func() {
+		It("escapes control characters by default", func() {
+			Expect(enc.Encode([]string{"a\x02b", "c\x7f"})).To(Succeed())
+			Expect(buf.String()).To(Equal(`- "a\x02b"
+- "c\x7F"
+`))
+		})
+
+		It("rejects control characters", func() {
+			enc.ControlCharacters(RejectControls)
+			Expect(enc.Encode("a\x02b")).NotTo(Succeed())
+		})
+
+		It("writes strings holding control characters as binary", func() {
+			enc.ControlCharacters(BinaryControls)
+			Expect(enc.Encode("a\x02b")).To(Succeed())
+			Expect(buf.String()).To(Equal("!!binary YQJi\n"))
+		})
+
+		It("writes invalid UTF-8 as binary unless rejected", func() {
+			Expect(enc.Encode("a\xffb")).To(Succeed())
+			Expect(buf.String()).To(Equal("!!binary Yf9i\n"))
+
+			enc.ControlCharacters(RejectControls)
+			Expect(enc.Encode("a\xffb")).NotTo(Succeed())
+		})
+
+		It("fails on node values that are not valid UTF-8", func() {
+			Expect(enc.Encode(&Node{Kind: ScalarNode, Value: "a\xffb"})).NotTo(Succeed())
+		})
+	})
+
+	Context("Null representation", func() {
+		type config struct {
+			A *int
+			B *string `yaml:"b,null=null"`
+			C *bool   `yaml:"c,null=~"`
+			D *int    `yaml:"d,null="`
+		}
+
+		It("writes null by default", func() {
+			err := enc.Encode(map[string]*int{"a": nil})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`a: null
+`))
+		})
+
+		It("uses the configured representation", func() {
+			enc.NullValue("Null")
+			err := enc.Encode([]interface{}{nil, map[string]*int{"a": nil}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`- Null
+- a: Null
+`))
+		})
+
+		It("supports empty values", func() {
+			enc.NullValue("")
+			err := enc.Encode(map[string]*int{"a": nil})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`a:
+`))
+		})
+
+		It("ignores unknown representations", func() {
+			enc.NullValue("nil")
+			err := enc.Encode(map[string]*int{"a": nil})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`a: null
+`))
+		})
+
+		It("honours per-field tags", func() {
+			enc.NullValue("~")
+			err := enc.Encode(config{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`A: ~
+b: null
+c: ~
+d:
+`))
+		})
+	})
+
+	Context("Reset", func() {
+		It("starts a new stream with the same options", func() {
+			buf := &bytes.Buffer{}
+			enc := NewEncoder(buf)
+			enc.SetLineBreak(CRLFBreak)
+			enc.QuoteStrings(true)
+			Expect(enc.Encode("a")).To(Succeed())
+			Expect(enc.Encode(make(chan int))).NotTo(Succeed())
+
+			other := &bytes.Buffer{}
+			enc.Reset(other)
+			Expect(enc.Encode([]string{"b"})).To(Succeed())
+			Expect(other.String()).To(Equal("- \"b\"\r\n"))
+			Expect(buf.String()).To(Equal("\"a\"\r\n"))
+		})
+	})
+
+	Context("Indentless sequences", func() {
+		v := map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"args": []string{"a", "b"}},
+			},
+		}
+
+		It("writes sequences at the indentation of their keys by default", func() {
+			Expect(enc.Encode(v)).To(Succeed())
+			Expect(buf.String()).To(Equal("containers:\n- args:\n  - a\n  - b\n"))
+		})
+
+		It("indents sequences under their keys", func() {
+			enc.IndentlessSequences(false)
+			Expect(enc.Encode(v)).To(Succeed())
+			Expect(buf.String()).To(Equal("containers:\n  - args:\n      - a\n      - b\n"))
+
+			var back interface{}
+			Expect(Unmarshal(buf.Bytes(), &back)).To(Succeed())
+			out, err := Marshal(back)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal("containers:\n- args:\n  - a\n  - b\n"))
+		})
+	})
+
+	Context("Mappings in sequences", func() {
+		v := []interface{}{
+			map[string]interface{}{"a": 1, "b": []int{2}},
+			map[string]interface{}{},
+			"c",
+		}
+
+		It("starts them on the line of the dash by default", func() {
+			Expect(enc.Encode(v)).To(Succeed())
+			Expect(buf.String()).To(Equal("- a: 1\n  b:\n  - 2\n- {}\n- c\n"))
+		})
+
+		It("starts them on the next line", func() {
+			enc.CompactSequenceMappings(false)
+			Expect(enc.Encode(v)).To(Succeed())
+			Expect(buf.String()).To(Equal("-\n  a: 1\n  b:\n  - 2\n- {}\n- c\n"))
+
+			var back interface{}
+			Expect(Unmarshal(buf.Bytes(), &back)).To(Succeed())
+			out, err := Marshal(back)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal("- a: 1\n  b:\n  - 2\n- {}\n- c\n"))
+		})
+	})
+
+	Context("Self-check", func() {
+		BeforeEach(func() {
+			enc.SelfCheck(true)
+		})
+
+		It("writes output that reads back as it was emitted", func() {
+			v := []interface{}{"x", 1, nil, "yes", map[string]int{"k": 1}}
+			Expect(enc.Encode(v)).To(Succeed())
+			Expect(enc.Encode("second")).To(Succeed())
+			Expect(buf.String()).To(Equal("- x\n- 1\n- null\n- \"yes\"\n- k: 1\n--- second\n"))
+		})
+
+		It("reads raw YAML back as the nodes it holds", func() {
+			v := map[string]interface{}{"a": RawYAML("b: c"), "d": RawYAML("[1, 2]")}
+			Expect(enc.Encode(v)).To(Succeed())
+			Expect(buf.String()).To(Equal("a:\n  b: c\nd: [1, 2]\n"))
+		})
+
+		It("fails on output that reads back otherwise", func() {
+			emitted := []checkedEvent{
+				{kind: yaml_DOCUMENT_START_EVENT},
+				{kind: yaml_SCALAR_EVENT, anchor: "a", value: "yes"},
+				{kind: yaml_DOCUMENT_END_EVENT},
+			}
+			err := checkOutput([]byte("&a no\n"), yaml_UTF8_ENCODING, emitted)
+			Expect(err).To(MatchError(`yaml: self-check of the output failed: a scalar &a "yes" reads back at line 1 as a scalar &a "no"`))
+
+			err = checkOutput([]byte("&a [yes\n"), yaml_UTF8_ENCODING, emitted)
+			Expect(err).To(MatchError(HavePrefix("yaml: self-check of the output failed: ")))
+
+			err = checkOutput([]byte("&a \"yes\"\n"), yaml_UTF8_ENCODING, emitted)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("Fragments", func() {
+		It("writes a bare node at a base indentation", func() {
+			buf := &bytes.Buffer{}
+			enc := NewEncoder(buf)
+			v := map[string]interface{}{"a": []int{1, 2}, "b": "x\ny\n"}
+			Expect(enc.EncodeFragment(v, 4)).To(Succeed())
+			Expect(buf.String()).To(Equal(`    a:
+    - 1
+    - 2
+    b: |
+      x
+      y
+`))
+		})
+
+		It("writes scalars and flow collections on one indented line", func() {
+			buf := &bytes.Buffer{}
+			enc := NewEncoder(buf)
+			Expect(enc.EncodeFragment("text", 2)).To(Succeed())
+			Expect(enc.EncodeFragment([]int{}, 2)).To(Succeed())
+			Expect(buf.String()).To(Equal("  text\n  []\n"))
+		})
+
+		It("leaves the stream of documents alone", func() {
+			buf := &bytes.Buffer{}
+			enc := NewEncoder(buf)
+			enc.QuoteStrings(true)
+			Expect(enc.Encode("a")).To(Succeed())
+			Expect(enc.EncodeFragment([]string{"b"}, 2)).To(Succeed())
+			Expect(enc.Encode("c")).To(Succeed())
+			Expect(buf.String()).To(Equal("\"a\"\n  - \"b\"\n--- \"c\"\n"))
+		})
+
+		It("indents the folded lines of long scalars", func() {
+			buf := &bytes.Buffer{}
+			enc := NewEncoder(buf)
+			enc.LineWidth(20)
+			Expect(enc.EncodeFragment(map[string]string{"k": "a b c d e f g h i j k l m"}, 2)).To(Succeed())
+			Expect(buf.String()).To(Equal("  k: a b c d e f g h i\n    j k l m\n"))
+		})
+	})
+
+	Context("Flushing", func() {
+		It("writes each document as it ends by default", func() {
+			w := &countingWriter{}
+			enc := NewEncoder(w)
+			Expect(enc.Encode("a")).To(Succeed())
+			Expect(enc.Encode("b")).To(Succeed())
+			Expect(w.writes).To(Equal(2))
+			Expect(w.String()).To(Equal("a\n--- b\n"))
+		})
+
+		It("writes a large document in few writes", func() {
+			w := &countingWriter{}
+			enc := NewEncoder(w)
+			Expect(enc.Encode(strings.Split(strings.Repeat("item ", 10000), " "))).To(Succeed())
+			Expect(w.writes).To(BeNumerically("<=", 2))
+			Expect(w.Len()).To(BeNumerically(">", 60000))
+		})
+
+		It("keeps documents until Flush without AutoFlush", func() {
+			w := &countingWriter{}
+			enc := NewEncoder(w)
+			enc.AutoFlush(false)
+			for i := 0; i < 100; i++ {
+				Expect(enc.Encode(i)).To(Succeed())
+			}
+			Expect(w.writes).To(Equal(0))
+
+			Expect(enc.Flush()).To(Succeed())
+			Expect(w.writes).To(Equal(1))
+			Expect(w.String()).To(HavePrefix("0\n--- 1\n--- 2\n"))
+			Expect(enc.Flush()).To(Succeed())
+			Expect(w.writes).To(Equal(1))
+		})
+
+		It("writes a document in chunks of the buffer size as it goes", func() {
+			w := &sizeWriter{}
+			enc := NewEncoder(w)
+			enc.SetBufferSize(1000)
+			Expect(enc.Encode(strings.Split(strings.Repeat("item ", 10000), " "))).To(Succeed())
+			Expect(w.writes).To(BeNumerically(">=", 60))
+			Expect(w.largest).To(BeNumerically("<", 1100))
+			Expect(enc.emitter.events).To(BeEmpty())
+			Expect(cap(enc.emitter.events)).To(BeNumerically("<", 100))
+		})
+
+		It("returns write errors from Flush", func() {
+			enc := NewEncoder(errorWriter{})
+			enc.AutoFlush(false)
+			Expect(enc.Encode("a")).To(Succeed())
+			Expect(enc.Flush()).To(MatchError(ContainSubstring("write error")))
+		})
+	})
+
+	Context("Ending the stream", func() {
+		It("writes the end marker when needed", func() {
+			Expect(enc.Encode([]int{1})).To(Succeed())
+			Expect(enc.Close()).To(Succeed())
+			Expect(buf.String()).To(Equal("- 1\n"))
+
+			buf.Reset()
+			enc.Reset(buf)
+			Expect(enc.Encode("a")).To(Succeed())
+			Expect(enc.Encode("b\n\n")).To(Succeed())
+			Expect(enc.Close()).To(Succeed())
+			Expect(buf.String()).To(Equal("a\n--- |+\n  b\n\n...\n"))
+		})
+
+		It("always writes the end marker", func() {
+			enc.EndMarker(AlwaysEndMarker)
+			Expect(enc.Encode(map[string]int{"a": 1})).To(Succeed())
+			Expect(enc.Close()).To(Succeed())
+			Expect(buf.String()).To(Equal("a: 1\n...\n"))
+		})
+
+		It("never writes the end marker", func() {
+			enc.EndMarker(NoEndMarker)
+			Expect(enc.Encode("b\n\n")).To(Succeed())
+			Expect(enc.Close()).To(Succeed())
+			Expect(buf.String()).To(Equal("|+\n  b\n\n"))
+		})
+
+		It("writes nothing for a stream without documents", func() {
+			enc.EndMarker(AlwaysEndMarker)
+			Expect(enc.Close()).To(Succeed())
+			Expect(buf.String()).To(BeEmpty())
+		})
+
+		It("drops the final line break", func() {
+			w := &countingWriter{}
+			enc := NewEncoder(w)
+			enc.FinalNewline(false)
+			Expect(enc.Encode(map[string]int{"a": 1})).To(Succeed())
+			Expect(w.String()).To(Equal("a: 1"))
+			Expect(enc.Encode([]int{2})).To(Succeed())
+			Expect(enc.Flush()).To(Succeed())
+			Expect(w.String()).To(Equal("a: 1\n---\n- 2"))
+			Expect(enc.Close()).To(Succeed())
+			Expect(w.String()).To(Equal("a: 1\n---\n- 2"))
+		})
+
+		It("drops the line break after the end marker", func() {
+			enc.SetLineBreak(CRLFBreak)
+			enc.FinalNewline(false)
+			enc.EndMarker(AlwaysEndMarker)
+			Expect(enc.Encode("a")).To(Succeed())
+			Expect(enc.Close()).To(Succeed())
+			Expect(buf.String()).To(Equal("a\r\n..."))
+		})
+
+		It("drops the final line break of UTF-16 output", func() {
+			enc.SetEncoding(UTF16LE)
+			enc.FinalNewline(false)
+			Expect(enc.Encode([]int{1})).To(Succeed())
+			Expect(enc.Close()).To(Succeed())
+			Expect(buf.Bytes()).To(Equal([]byte{0xFF, 0xFE, '-', 0, ' ', 0, '1', 0}))
+		})
+
+		It("fails to encode once closed", func() {
+			Expect(enc.Encode("a")).To(Succeed())
+			Expect(enc.Close()).To(Succeed())
+			Expect(enc.Encode("b")).To(MatchError("The encoder is closed"))
+			Expect(enc.Close()).To(MatchError("The encoder is closed"))
+
+			enc.Reset(buf)
+			Expect(enc.Encode("b")).To(Succeed())
+		})
+	})
+
+	Context("Skip field", func() {
+		It("does not include the field", func() {
+			type a struct {
+				B string `yaml:"-"`
+				C string
+			}
+
+			err := enc.Encode(a{B: "b", C: "c"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(`C: c
+`))
+
+		})
+	})
+
+	Context("Marshaler support", func() {
+		Context("Receiver is a value", func() {
+			It("uses the Marshaler interface when a value", func() {
+				err := enc.Encode(hasMarshaler{Value: 123})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal("123\n"))
+			})
+
+			It("uses the Marshaler interface when a pointer", func() {
+				err := enc.Encode(&hasMarshaler{Value: "abc"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`abc
+`))
+			})
+
+			Context("when it fails", func() {
+				It("returns an error", func() {
+					err := enc.Encode(&hasMarshaler{Value: "abc", Error: errors.New("fail")})
+					Expect(err).To(MatchError("fail"))
+				})
+			})
+		})
+
+		Context("Receiver is a pointer", func() {
+			It("uses the Marshaler interface when a pointer", func() {
+				err := enc.Encode(&hasPtrMarshaler{Value: map[string]string{"a": "b"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`a: b
+`))
+
+			})
+
+			It("skips the Marshaler when its a value", func() {
+				err := enc.Encode(hasPtrMarshaler{Value: map[string]string{"a": "b"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buf.String()).To(Equal(`Tag: ""
+Value:
+  a: b
+Error: null
+`))
+
+			})
+
+			Context("the receiver is nil", func() {
+				var ptr *hasPtrMarshaler
+
+				Context("when it fails", func() {
+					It("returns an error", func() {
+						err := enc.Encode(&hasPtrMarshaler{Value: "abc", Error: errors.New("fail")})
+						Expect(err).To(MatchError("fail"))
+					})
+				})
+
+				It("returns a null", func() {
+					err := enc.Encode(ptr)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(buf.String()).To(Equal(`null
+`))
+
+				})
+
+				It("returns a null value for ptr types", func() {
+					err := enc.Encode(map[string]*hasPtrMarshaler{"a": ptr})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(buf.String()).To(Equal(`a: null
+`))
+
+				})
+
+				It("panics when used as a nil interface", func() {
+					Expect(func() { enc.Encode(map[string]Marshaler{"a": ptr}) }).To(Panic())
+				})
+			})
+
+			Context("the receiver has a nil value", func() {
+				ptr := &hasPtrMarshaler{Value: nil}
+
+				It("returns null", func() {
 					err := enc.Encode(ptr)
 					Expect(err).NotTo(HaveOccurred())
 					Expect(buf.String()).To(Equal(`null
@@ -611,6 +2075,51 @@ Error: null
 	})
 })
 
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+type sizeWriter struct {
+	writes, largest int
+}
+
+func (w *sizeWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.largest {
+		w.largest = len(p)
+	}
+	return len(p), nil
+}
+
+type textKey struct {
+	name string
+	n    int
+}
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%s-%d", k.name, k.n)), nil
+}
+
+type stringerKey struct {
+	name string
+}
+
+func (k *stringerKey) String() string {
+	return "key " + k.name
+}
+
+type errorWriter struct{}
+
+func (errorWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("closed")
+}
+
 type hasMarshaler struct {
 	Value interface{}
 	Error error
@@ -640,3 +2149,17 @@ func (m *hasPtrMarshaler) UnmarshalYAML(tag string, value interface{}) error {
 	m.Value = value
 	return nil
 }
+
+type optional struct {
+	Set   bool
+	Value int
+}
+
+func (o optional) IsZero() bool { return !o.Set }
+
+type ptrOptional struct {
+	Set   bool
+	Value int
+}
+
+func (o *ptrOptional) IsZero() bool { return !o.Set }
@@ -0,0 +1,112 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var enumRegistry struct {
+	sync.RWMutex
+	enums map[reflect.Type]*enumType
+}
+
+// the names of the values of an enumeration, and the other way around
+type enumType struct {
+	values map[string]reflect.Value
+	names  map[interface{}]string
+}
+
+// RegisterEnum makes the values of an integer type, such as the constants
+// of an enumeration, decode from and encode to the strings their String
+// methods return. The values must all be of the same type.
+//
+// Decoding any other string, or a number, into the type is an error that
+// lists the names allowed. Values that were not registered are encoded as
+// numbers. A later registration of the same type replaces the earlier one.
+func RegisterEnum(values ...fmt.Stringer) {
+	if len(values) == 0 {
+		return
+	}
+
+	t := reflect.TypeOf(values[0])
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		panic(fmt.Sprintf("RegisterEnum of the non-integer type %s", t))
+	}
+
+	enum := &enumType{
+		values: make(map[string]reflect.Value, len(values)),
+		names:  make(map[interface{}]string, len(values)),
+	}
+	for _, v := range values {
+		if reflect.TypeOf(v) != t {
+			panic(fmt.Sprintf("RegisterEnum of values of both %s and %s", t, reflect.TypeOf(v)))
+		}
+		name := v.String()
+		enum.values[name] = reflect.ValueOf(v)
+		enum.names[v] = name
+	}
+
+	enumRegistry.Lock()
+	defer enumRegistry.Unlock()
+
+	if enumRegistry.enums == nil {
+		enumRegistry.enums = make(map[reflect.Type]*enumType)
+	}
+	enumRegistry.enums[t] = enum
+}
+
+// registeredEnum returns the enumeration registered for t, if any.
+func registeredEnum(t reflect.Type) *enumType {
+	enumRegistry.RLock()
+	enum := enumRegistry.enums[t]
+	enumRegistry.RUnlock()
+	return enum
+}
+
+// enumName returns the name of the value of a registered enumeration.
+func enumName(v reflect.Value) (string, bool) {
+	enum := registeredEnum(v.Type())
+	if enum == nil {
+		return "", false
+	}
+	name, ok := enum.names[v.Interface()]
+	return name, ok
+}
+
+// enum decodes the scalar at the current event into v, of the type of
+// enum.
+func (d *Decoder) enum(enum *enumType, v reflect.Value) {
+	value, ok := enum.values[string(d.event.value)]
+	if !ok {
+		names := make([]string, 0, len(enum.values))
+		for name := range enum.values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		expected := strings.Join(names, ", ")
+		d.error(newDecodeError(DecodingError, d.event.start_mark,
+			fmt.Sprintf("Invalid %s '%s', expected one of: %s", v.Type(), d.event.value, expected),
+			fmt.Sprintf("Invalid %s '%s' at %s, expected one of: %s", v.Type(), d.event.value, d.event.start_mark, expected)))
+	}
+	v.Set(value)
+}
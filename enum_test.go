@@ -0,0 +1,72 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type color int
+
+const (
+	red color = iota + 1
+	green
+	blue
+)
+
+func (c color) String() string {
+	switch c {
+	case red:
+		return "red"
+	case green:
+		return "green"
+	case blue:
+		return "blue"
+	}
+	return "unknown"
+}
+
+var _ = Describe("Enums", func() {
+	BeforeEach(func() {
+		RegisterEnum(red, green, blue)
+	})
+
+	type paint struct {
+		Color  color
+		Others []color
+		Spare  *color
+	}
+
+	It("decodes the names of the values", func() {
+		var p paint
+		Expect(Unmarshal([]byte("color: green\nothers: [red, blue]\nspare: ~\n"), &p)).To(Succeed())
+		Expect(p).To(Equal(paint{Color: green, Others: []color{red, blue}}))
+	})
+
+	It("lists the allowed names of an invalid value", func() {
+		var p paint
+		err := Unmarshal([]byte("color: green\nothers: [red, pink]\n"), &p)
+		Expect(err).To(MatchError("others[1]: Invalid candiedyaml.color 'pink' at line 1, column 14, expected one of: blue, green, red"))
+	})
+
+	It("encodes the names of the values", func() {
+		buf := &bytes.Buffer{}
+		Expect(NewEncoder(buf).Encode(map[string]color{"a": blue, "b": color(7)})).To(Succeed())
+		Expect(buf.String()).To(Equal("a: blue\nb: 7\n"))
+	})
+})
@@ -0,0 +1,302 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EqualDocuments reports whether the YAML documents in a and b hold the same data
+// when their scalars are resolved under schema. Key order, scalar styles,
+// flow or block collections, comments and the names of anchors do not
+// matter, and aliases compare as the values they refer to. JSONSchema
+// resolves scalars as CoreSchema does.
+//
+// When the documents differ, path locates the first difference, in the
+// form of the paths of Positions, e.g. "servers[0].port"; when the
+// streams hold several documents, it is preceded by the index of the
+// document and a colon, e.g. "1:servers[0].port".
+func EqualDocuments(a, b []byte, schema Schema) (equal bool, path string, err error) {
+	defer recovery(&err)
+
+	docsA, docsB := composeNodes(a), composeNodes(b)
+	c := &comparison{schema: schema}
+	for i := 0; i < len(docsA) || i < len(docsB); i++ {
+		if len(docsA) > 1 || len(docsB) > 1 {
+			c.path = strconv.AppendInt(c.path[:0], int64(i), 10)
+			c.path = append(c.path, ':')
+		}
+		if i >= len(docsA) || i >= len(docsB) || !c.equal(docsA[i], docsB[i]) {
+			return false, string(c.path), nil
+		}
+	}
+	return true, "", nil
+}
+
+// composeNodes decodes the documents of src as Nodes.
+func composeNodes(src []byte) []*Node {
+	d := NewDecoder(bytes.NewReader(src))
+	d.start()
+
+	var docs []*Node
+	for d.event.event_type != yaml_STREAM_END_EVENT {
+		n := &Node{}
+		d.document(reflect.ValueOf(n))
+		docs = append(docs, n)
+	}
+	return docs
+}
+
+// a comparison of the documents of EqualDocuments, which leaves the path of the
+// first difference it finds
+type comparison struct {
+	schema Schema
+	path   []byte
+}
+
+func (c *comparison) equal(a, b *Node) bool {
+	a, b = target(a), target(b)
+	if a.Kind != b.Kind || collectionTag(a) != collectionTag(b) {
+		return false
+	}
+
+	switch a.Kind {
+	case ScalarNode:
+		return c.scalar(a) == c.scalar(b)
+	case SequenceNode:
+		n := len(c.path)
+		for i := 0; i < len(a.Content) || i < len(b.Content); i++ {
+			c.path = append(c.path, '[')
+			c.path = strconv.AppendInt(c.path, int64(i), 10)
+			c.path = append(c.path, ']')
+			if i >= len(a.Content) || i >= len(b.Content) || !c.equal(a.Content[i], b.Content[i]) {
+				return false
+			}
+			c.path = c.path[:n]
+		}
+	case MappingNode:
+		keysA, keysB := c.keys(a), c.keys(b)
+		n := len(c.path)
+		for i := 0; i < len(a.Content); i += 2 {
+			key := c.canonical(a.Content[i])
+			if keysA[key] != i {
+				// the value of a duplicate key is the last one
+				continue
+			}
+			c.pushKey(a.Content[i])
+			j, ok := keysB[key]
+			if !ok || !c.equal(a.Content[i+1], b.Content[j+1]) {
+				return false
+			}
+			c.path = c.path[:n]
+		}
+		for i := 0; i < len(b.Content); i += 2 {
+			if _, ok := keysA[c.canonical(b.Content[i])]; !ok {
+				c.pushKey(b.Content[i])
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// target returns the node an alias refers to, or n itself.
+func target(n *Node) *Node {
+	for n.Kind == AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+// collectionTag returns the explicit tag of a collection, or "" when it
+// is the tag the collection has anyway.
+func collectionTag(n *Node) string {
+	switch tag := longTag(n.Tag); {
+	case n.Kind == ScalarNode, tag == "!",
+		n.Kind == SequenceNode && tag == yaml_SEQ_TAG,
+		n.Kind == MappingNode && tag == yaml_MAP_TAG:
+		return ""
+	default:
+		return tag
+	}
+}
+
+// keys returns the indexes in the content of a mapping of its keys, by
+// their canonical forms.
+func (c *comparison) keys(n *Node) map[string]int {
+	keys := make(map[string]int, len(n.Content)/2)
+	for i := 0; i < len(n.Content); i += 2 {
+		keys[c.canonical(n.Content[i])] = i
+	}
+	return keys
+}
+
+// pushKey extends the path with a mapping key.
+func (c *comparison) pushKey(key *Node) {
+	if len(c.path) > 0 && c.path[len(c.path)-1] != ':' {
+		c.path = append(c.path, '.')
+	}
+	if key = target(key); key.Kind == ScalarNode {
+		c.path = append(c.path, key.Value...)
+	} else {
+		c.path = append(c.path, c.canonical(key)...)
+	}
+}
+
+// canonical returns a text that is the same for nodes holding the same
+// data, which identifies mapping keys.
+func (c *comparison) canonical(n *Node) string {
+	switch n = target(n); n.Kind {
+	case ScalarNode:
+		return c.scalar(n)
+	case SequenceNode:
+		items := make([]string, len(n.Content))
+		for i, item := range n.Content {
+			items[i] = c.canonical(item)
+		}
+		return collectionTag(n) + "[" + strings.Join(items, ", ") + "]"
+	case MappingNode:
+		pairs := make([]string, 0, len(n.Content)/2)
+		for i := 0; i < len(n.Content); i += 2 {
+			pairs = append(pairs, c.canonical(n.Content[i])+": "+c.canonical(n.Content[i+1]))
+		}
+		sort.Strings(pairs)
+		return collectionTag(n) + "{" + strings.Join(pairs, ", ") + "}"
+	}
+	return ""
+}
+
+// scalar returns the resolved tag and value of a scalar node as text.
+func (c *comparison) scalar(n *Node) string {
+	switch c.schema {
+	case FailsafeSchema:
+		tag := longTag(n.Tag)
+		if tag == "" || tag == "!" {
+			tag = yaml_STR_TAG
+		}
+		return tag + " " + n.Value
+	case CoreSchema, JSONSchema:
+		tag := longTag(n.Tag)
+		if tag == "" && n.Style != AnyStyle && n.Style != PlainStyle {
+			tag = "!"
+		}
+		return coreScalar(tag, n.Value)
+	}
+
+	tag, value := canonicalScalar(n)
+	return tag + " " + value
+}
+
+// canonicalScalar returns the resolved tag of a scalar node and a normal
+// form of its value under DefaultSchema. The values of unknown tags are
+// kept as they are.
+func canonicalScalar(n *Node) (tag, value string) {
+	tag = longTag(n.Tag)
+	if tag == "" && n.Style != AnyStyle && n.Style != PlainStyle {
+		tag = "!"
+	}
+
+	resolved, v, err := Resolve(tag, n.Value)
+	if err != nil {
+		if tag == "" || tag == "!" {
+			tag = yaml_STR_TAG
+		}
+		return tag, n.Value
+	}
+
+	switch v := v.(type) {
+	case nil:
+		value = ""
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			value = ".nan"
+		case math.IsInf(v, 1):
+			value = ".inf"
+		case math.IsInf(v, -1):
+			value = "-.inf"
+		default:
+			value = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+	case time.Time:
+		value = v.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		value = base64.StdEncoding.EncodeToString(v)
+	default:
+		value = fmt.Sprint(v)
+	}
+	return resolved, value
+}
+
+// coreScalar is scalar for the core schema.
+func coreScalar(tag, value string) string {
+	switch tag {
+	case "":
+		switch {
+		case coreNull.MatchString(value):
+			tag = yaml_NULL_TAG
+		case coreBool.MatchString(value):
+			tag = yaml_BOOL_TAG
+		case coreInt.MatchString(value):
+			tag = yaml_INT_TAG
+		case coreFloat.MatchString(value):
+			tag = yaml_FLOAT_TAG
+		default:
+			tag = yaml_STR_TAG
+		}
+	case "!":
+		tag = yaml_STR_TAG
+	}
+
+	switch tag {
+	case yaml_NULL_TAG:
+		if coreNull.MatchString(value) {
+			value = ""
+		}
+	case yaml_BOOL_TAG:
+		if coreBool.MatchString(value) {
+			value = strings.ToLower(value)
+		}
+	case yaml_INT_TAG:
+		base := 10
+		if strings.HasPrefix(value, "0o") || strings.HasPrefix(value, "0x") {
+			base = 0
+		}
+		if i, err := strconv.ParseInt(value, base, 64); err == nil {
+			value = strconv.FormatInt(i, 10)
+		}
+	case yaml_FLOAT_TAG:
+		lower := strings.ToLower(value)
+		switch {
+		case strings.HasSuffix(lower, ".inf"):
+			value = strings.TrimPrefix(strings.TrimSuffix(lower, ".inf"), "+") + "Inf"
+		case lower == ".nan":
+			value = "NaN"
+		default:
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				value = strconv.FormatFloat(f, 'g', -1, 64)
+			}
+		}
+	}
+	return tag + " " + value
+}
@@ -0,0 +1,83 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EqualDocuments", func() {
+	expectEqual := func(a, b string, schema Schema) {
+		equal, path, err := EqualDocuments([]byte(a), []byte(b), schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(BeEmpty())
+		Expect(equal).To(BeTrue())
+	}
+
+	expectDifference := func(a, b string, schema Schema, at string) {
+		equal, path, err := EqualDocuments([]byte(a), []byte(b), schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(equal).To(BeFalse())
+		Expect(path).To(Equal(at))
+	}
+
+	It("ignores key order, styles, comments and anchor names", func() {
+		expectEqual(`# settings
+a: &x {b: 1, c: "two"}
+d: *x
+e: [0x10, yes, 1.50]
+`, `e:
+- 16
+- true
+- 1.5
+d: &other
+  c: two
+  b: 1
+a: *other
+`, DefaultSchema)
+	})
+
+	It("reports the path of the first difference", func() {
+		expectDifference("a: {b: [1, 2]}\n", "a: {b: [1, 3]}\n", DefaultSchema, "a.b[1]")
+		expectDifference("a: 1\n", "a: 1\nb: 2\n", DefaultSchema, "b")
+		expectDifference("a: [1]\n", "a: [1, 2]\n", DefaultSchema, "a[1]")
+		expectDifference("a: 1\n", "- 1\n", DefaultSchema, "")
+	})
+
+	It("compares the last value of duplicate keys", func() {
+		expectEqual("a: 1\na: 2\n", "a: 2\n", DefaultSchema)
+		expectDifference("a: 1\na: 2\n", "a: 1\n", DefaultSchema, "a")
+	})
+
+	It("resolves scalars under the schema", func() {
+		expectDifference("a: 1\n", "a: '1'\n", DefaultSchema, "a")
+		expectDifference("a: yes\n", "a: true\n", CoreSchema, "a")
+		expectEqual("a: 0o17\nb: +.INF\nc: ~\n", "a: 15\nb: .inf\nc: null\n", CoreSchema)
+		expectEqual("a: 1\n", "a: '1'\n", FailsafeSchema)
+		expectDifference("a: !!int 1\n", "a: 1\n", FailsafeSchema, "a")
+	})
+
+	It("compares every document", func() {
+		expectEqual("a: 1\n---\nb: 2\n", "a: 1\n--- {b: 2}\n", DefaultSchema)
+		expectDifference("a: 1\n---\nb: 2\n", "a: 1\n---\nb: 3\n", DefaultSchema, "1:b")
+		expectDifference("a: 1\n---\nb: 2\n", "a: 1\n", DefaultSchema, "1:")
+	})
+
+	It("reports parse errors", func() {
+		_, _, err := EqualDocuments([]byte("a: [\n"), []byte("a: 1\n"), DefaultSchema)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,87 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "fmt"
+
+// An Error is an error in the input of a Decoder, which tells at which
+// stage of decoding it was found, where, and what the problem is, so that
+// tools embedding the package can report it in their own terms. The errors
+// of the input returned by a Decoder are Errors, or wrap one, such as a
+// FieldError does, which errors.As finds. Errors returned by the readers
+// and the Unmarshalers of the caller are returned as they are, as are
+// those of a misuse of the API, such as decoding into a value that is not
+// a pointer.
+type Error interface {
+	error
+
+	// Kind returns the stage of decoding at which the error was found.
+	Kind() ErrorKind
+
+	// Location returns where the problem is in the input. Errors reading
+	// the input only know its byte offset.
+	Location() Mark
+
+	// Description returns the problem, without its location.
+	Description() string
+}
+
+// A DecodeError is an error found while composing the events of a
+// document into its nodes, such as an alias to an unknown anchor, or
+// while decoding them into values, such as an invalid integer.
+type DecodeError struct {
+	// Problem describes the error, and At is the start of the value at
+	// fault.
+	Problem string
+	At      Mark
+
+	kind    ErrorKind
+	message string
+}
+
+// newDecodeError returns the DecodeError of kind described by problem at
+// mark, whose message is the one decoding errors always had.
+func newDecodeError(kind ErrorKind, mark YAML_mark_t, problem, message string) error {
+	return &DecodeError{Problem: problem, At: markOf(mark), kind: kind, message: message}
+}
+
+// decodingError returns the DecodingError at mark described by format.
+func decodingError(mark YAML_mark_t, format string, args ...interface{}) error {
+	problem := fmt.Sprintf(format, args...)
+	return newDecodeError(DecodingError, mark, problem, problem+" at "+mark.String())
+}
+
+// composingError returns the ComposingError at mark described by format.
+func composingError(mark YAML_mark_t, format string, args ...interface{}) error {
+	problem := fmt.Sprintf(format, args...)
+	return newDecodeError(ComposingError, mark, problem, problem+" at "+mark.String())
+}
+
+func (e *DecodeError) Error() string {
+	return e.message
+}
+
+// Kind returns ComposingError or DecodingError.
+func (e *DecodeError) Kind() ErrorKind {
+	return e.kind
+}
+
+func (e *DecodeError) Location() Mark {
+	return e.At
+}
+
+func (e *DecodeError) Description() string {
+	return e.Problem
+}
@@ -0,0 +1,71 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Errors", func() {
+	describe := func(src string, v interface{}, opts ...Option) Error {
+		d := NewDecoder(bytes.NewBufferString(src), opts...)
+		err := d.Decode(v)
+		Expect(err).To(HaveOccurred())
+		var yamlErr Error
+		Expect(errors.As(err, &yamlErr)).To(BeTrue(), err.Error())
+		return yamlErr
+	}
+
+	It("tell the stage, location and problem of errors in the input", func() {
+		var v interface{}
+		err := describe("a: \"b", &v)
+		Expect(err.Kind()).To(Equal(ScanningError))
+		Expect(err.Description()).To(Equal("found unexpected end of stream"))
+		Expect(err.Location().Line).To(Equal(1))
+
+		err = describe("a: [b", &v)
+		Expect(err.Kind()).To(Equal(ParsingError))
+
+		err = describe("a: \xff", &v)
+		Expect(err.Kind()).To(Equal(ReadingError))
+		Expect(err.Location()).To(Equal(Mark{Offset: 3}))
+
+		err = describe("a: 1\nb: *c\n", &v)
+		Expect(err.Kind()).To(Equal(ComposingError))
+		Expect(err.Description()).To(Equal("missing anchor: 'c'"))
+		Expect(err.Location()).To(Equal(Mark{Index: 8, Offset: 8, Line: 2, Column: 4}))
+
+		err = describe("[abc]", &v, WithLimits(Limits{MaxScalarLength: 2}))
+		Expect(err.Kind()).To(Equal(LimitExceededError))
+		Expect(err.Description()).To(Equal("input exceeds MaxScalarLength of 2"))
+	})
+
+	It("tell the problem of values that cannot be decoded", func() {
+		var v struct {
+			Servers []struct{ Port int }
+		}
+		err := describe("servers:\n- port: eighty\n", &v)
+		Expect(err).To(BeAssignableToTypeOf(&DecodeError{}))
+		Expect(err.Kind()).To(Equal(DecodingError))
+		Expect(err.Description()).To(Equal("Invalid integer: 'eighty'"))
+		Expect(err.Location().Position()).To(Equal(Position{Line: 2, Column: 9}))
+		Expect(err.Error()).To(Equal("Invalid integer: 'eighty' at line 1, column 8"))
+		Expect(DecodingError.String()).To(Equal("decoding error"))
+	})
+})
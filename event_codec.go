@@ -0,0 +1,339 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// An EventMarshaler encodes itself by writing events, instead of being
+// encoded through reflection. The methods generated by candiedyaml-gen
+// implement it.
+type EventMarshaler interface {
+	MarshalYAMLEvents(w *EventWriter) error
+}
+
+// An EventUnmarshaler decodes itself by reading the events of one value,
+// instead of being decoded through reflection. The methods generated by
+// candiedyaml-gen implement it.
+type EventUnmarshaler interface {
+	UnmarshalYAMLEvents(r *EventReader) error
+}
+
+var (
+	eventMarshalerType   = reflect.TypeOf(new(EventMarshaler)).Elem()
+	eventUnmarshalerType = reflect.TypeOf(new(EventUnmarshaler)).Elem()
+)
+
+// An EventWriter writes the events of a value for an EventMarshaler.
+// Scalars written with its typed methods are formatted as Encode formats
+// values of those types, following the options of the Encoder.
+type EventWriter struct {
+	e *Encoder
+}
+
+func (w *EventWriter) do(f func()) (err error) {
+	defer recovery(&err)
+	f()
+	return nil
+}
+
+// Write writes an alias, scalar, sequence or mapping event.
+func (w *EventWriter) Write(ev Event) error {
+	return w.do(func() { w.e.emitEvent(ev) })
+}
+
+// WriteKey writes a string mapping key.
+func (w *EventWriter) WriteKey(s string) error {
+	return w.do(func() {
+		w.e.key = true
+		defer func() { w.e.key = false }()
+		w.e.emitText("", s)
+	})
+}
+
+// WriteString writes a string scalar.
+func (w *EventWriter) WriteString(s string) error {
+	return w.do(func() { w.e.emitText("", s) })
+}
+
+// WriteBool writes a boolean scalar.
+func (w *EventWriter) WriteBool(b bool) error {
+	return w.do(func() { w.e.emitScalar(strconv.FormatBool(b), "", "", yaml_PLAIN_SCALAR_STYLE) })
+}
+
+// WriteInt writes an integer scalar.
+func (w *EventWriter) WriteInt(i int64) error {
+	return w.do(func() { w.e.emitScalar(strconv.FormatInt(i, 10), "", "", yaml_PLAIN_SCALAR_STYLE) })
+}
+
+// WriteUint writes an unsigned integer scalar.
+func (w *EventWriter) WriteUint(u uint64) error {
+	return w.do(func() { w.e.emitScalar(strconv.FormatUint(u, 10), "", "", yaml_PLAIN_SCALAR_STYLE) })
+}
+
+// WriteFloat writes a floating point scalar of the given size in bits,
+// 32 or 64.
+func (w *EventWriter) WriteFloat(f float64, bits int) error {
+	return w.do(func() { w.e.emitFloatBits("", f, bits) })
+}
+
+// WriteNull writes a null.
+func (w *EventWriter) WriteNull() error {
+	return w.do(func() { w.e.emitNil() })
+}
+
+// Encode writes v as Encoder.Encode would.
+func (w *EventWriter) Encode(v interface{}) error {
+	return w.do(func() { w.e.marshal("", reflect.ValueOf(&v).Elem(), true) })
+}
+
+// EncodeField writes a mapping key and the value v as Encoder.Encode
+// would, unless the options of a struct field tag, such as "omitempty",
+// leave it out.
+func (w *EventWriter) EncodeField(key string, v interface{}, options string) error {
+	opts := tagOptions(options)
+	rv := reflect.ValueOf(&v).Elem()
+	if opts.Contains("omitempty") && isEmptyValue(rv.Elem()) ||
+		opts.Contains("omitzero") && (v == nil || isZeroValue(rv.Elem())) ||
+		opts.Contains("omitnil") && (v == nil || isNilValue(rv.Elem())) {
+		return nil
+	}
+	if err := w.WriteKey(key); err != nil {
+		return err
+	}
+	return w.Encode(v)
+}
+
+// emitEventMarshaler writes a value through its MarshalYAMLEvents method.
+func (e *Encoder) emitEventMarshaler(v reflect.Value) {
+	m := v.Interface().(EventMarshaler)
+	if err := m.MarshalYAMLEvents(&EventWriter{e: e}); err != nil {
+		panic(err)
+	}
+}
+
+// An EventReader reads the events of a value for an EventUnmarshaler.
+// Aliases are replaced by the events of the values they refer to, so that
+// an EventUnmarshaler never reads an AliasEvent.
+type EventReader struct {
+	d *Decoder
+
+	// the anchors of the open collections, and whether any event was read
+	anchors []string
+	read    bool
+}
+
+func (r *EventReader) do(f func()) (err error) {
+	defer recovery(&err)
+	r.expand()
+	f()
+	return nil
+}
+
+// expand replaces an alias at the current event by the value it refers to.
+func (r *EventReader) expand() {
+	if r.d.event.event_type == yaml_ALIAS_EVENT {
+		r.d.replayAlias()
+	}
+}
+
+// NextKind returns the kind of the next event without reading it.
+func (r *EventReader) NextKind() (kind EventKind, err error) {
+	err = r.do(func() { kind = EventKind(r.d.event.event_type) })
+	return kind, err
+}
+
+// Next reads the next event. It fails at the end of the value, which is
+// after the event ending it, or after its only scalar.
+func (r *EventReader) Next() (ev Event, err error) {
+	err = r.do(func() {
+		d := r.d
+		if r.read && len(r.anchors) == 0 {
+			d.error(decodingError(d.event.start_mark, "Expected the end of the value"))
+		}
+		r.read = true
+
+		ev = eventOf(&d.event)
+		switch d.event.event_type {
+		case yaml_SCALAR_EVENT:
+			d.begin_anchor(ev.Anchor)
+			d.nextEvent()
+			d.end_anchor(ev.Anchor)
+			return
+		case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+			d.begin_anchor(ev.Anchor)
+			r.anchors = append(r.anchors, ev.Anchor)
+		case yaml_SEQUENCE_END_EVENT, yaml_MAPPING_END_EVENT:
+			if len(r.anchors) == 0 {
+				d.error(decodingError(d.event.start_mark, "Expected the end of the value"))
+			}
+			anchor := r.anchors[len(r.anchors)-1]
+			r.anchors = r.anchors[:len(r.anchors)-1]
+			d.nextEvent()
+			d.end_anchor(anchor)
+			return
+		default:
+			d.error(&UnexpectedEventError{
+				Value:     ev.Value,
+				EventType: EventKind(d.event.event_type),
+				At:        markOf(d.event.start_mark),
+			})
+		}
+		d.nextEvent()
+	})
+	return ev, err
+}
+
+// value decodes the next value with f.
+func (r *EventReader) value(f func()) error {
+	return r.do(func() {
+		if r.read && len(r.anchors) == 0 {
+			r.d.error(decodingError(r.d.event.start_mark, "Expected the end of the value"))
+		}
+		r.read = true
+		f()
+	})
+}
+
+// Expect reads the next event, failing unless it is of the given kind.
+func (r *EventReader) Expect(kind EventKind) (Event, error) {
+	mark := r.d.event.start_mark
+	ev, err := r.Next()
+	if err == nil && ev.Kind != kind {
+		err = decodingError(mark, "Expected %s but was %s", kind, ev.Kind)
+	}
+	return ev, err
+}
+
+// Skip reads the next value without decoding it.
+func (r *EventReader) Skip() error {
+	return r.value(func() { r.d.skip() })
+}
+
+// Decode decodes the next value into v as Decoder.Decode would.
+func (r *EventReader) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Expected a pointer or nil but was a %s at %s", rv.String(), r.d.event.start_mark)
+	}
+	return r.value(func() { r.d.parse(rv) })
+}
+
+// ReadString decodes the next value as a string.
+func (r *EventReader) ReadString() (s string, err error) {
+	err = r.value(func() { r.d.parse(reflect.ValueOf(&s)) })
+	return s, err
+}
+
+// ReadBool decodes the next value as a boolean.
+func (r *EventReader) ReadBool() (b bool, err error) {
+	err = r.value(func() { r.d.parse(reflect.ValueOf(&b)) })
+	return b, err
+}
+
+// ReadInt decodes the next value as an integer.
+func (r *EventReader) ReadInt() (i int64, err error) {
+	err = r.value(func() { r.d.parse(reflect.ValueOf(&i)) })
+	return i, err
+}
+
+// ReadUint decodes the next value as an unsigned integer.
+func (r *EventReader) ReadUint() (u uint64, err error) {
+	err = r.value(func() { r.d.parse(reflect.ValueOf(&u)) })
+	return u, err
+}
+
+// ReadFloat decodes the next value as a floating point number.
+func (r *EventReader) ReadFloat() (f float64, err error) {
+	err = r.value(func() { r.d.parse(reflect.ValueOf(&f)) })
+	return f, err
+}
+
+// eventUnmarshaler returns the EventUnmarshaler that v is or points to,
+// allocating a nil pointer to it, or nil if there is none or the current
+// event is a null, which is decoded as usual.
+func (d *Decoder) eventUnmarshaler(v reflect.Value) EventUnmarshaler {
+	if u := d.implementation(v, eventUnmarshalerType); u != nil {
+		return u.(EventUnmarshaler)
+	}
+	return nil
+}
+
+// implementation returns the implementation of the interface t that v is
+// or points to, allocating a nil pointer to it, or nil if there is none
+// or the current event is a null, which is decoded as usual.
+func (d *Decoder) implementation(v reflect.Value, t reflect.Type) interface{} {
+	if d.event.event_type == yaml_SCALAR_EVENT && (len(d.event.tag) == 0 && null_values[string(d.event.value)] ||
+		d.event.implicit && string(d.event.tag) == yaml_NULL_TAG) {
+		return nil
+	}
+
+	if v.Kind() != reflect.Ptr {
+		if !v.CanAddr() {
+			return nil
+		}
+		v = v.Addr()
+	}
+
+	for {
+		if !v.Type().Implements(t) {
+			if v.IsNil() || v.Type().Elem().Kind() != reflect.Ptr {
+				return nil
+			}
+			v = v.Elem()
+			continue
+		}
+		if v.IsNil() {
+			if !v.CanSet() {
+				return nil
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return v.Interface()
+	}
+}
+
+// unmarshalEvents decodes the current value through u.
+func (d *Decoder) unmarshalEvents(u EventUnmarshaler) {
+	r := &EventReader{d: d}
+	if err := u.UnmarshalYAMLEvents(r); err != nil {
+		d.error(err)
+	}
+	if !r.read || len(r.anchors) > 0 {
+		const problem = "UnmarshalYAMLEvents did not read a whole value"
+		d.error(newDecodeError(DecodingError, d.event.start_mark, problem, problem))
+	}
+}
+
+// MatchField returns the name in names that a mapping key selects, as
+// decoding into a struct with fields of those names would: the name that
+// equals key, or else the first that equals it in another case. It
+// returns "" if there is none.
+func MatchField(key string, names []string) string {
+	match := ""
+	for _, name := range names {
+		if name == key {
+			return name
+		}
+		if match == "" && strings.EqualFold(name, key) {
+			match = name
+		}
+	}
+	return match
+}
@@ -0,0 +1,239 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// codecPerson has the methods candiedyaml-gen generates for
+//
+//	type codecPerson struct {
+//		Name string   `yaml:"name"`
+//		Age  int      `yaml:"age,omitempty"`
+//		Tags []string `yaml:"tags,omitempty"`
+//	}
+type codecPerson struct {
+	Name string   `yaml:"name"`
+	Age  int      `yaml:"age,omitempty"`
+	Tags []string `yaml:"tags,omitempty"`
+}
+
+func (v codecPerson) MarshalYAMLEvents(w *EventWriter) error {
+	if err := w.Write(Event{Kind: MappingStartEvent}); err != nil {
+		return err
+	}
+	if err := w.WriteKey("name"); err != nil {
+		return err
+	}
+	if err := w.WriteString(v.Name); err != nil {
+		return err
+	}
+	if v.Age != 0 {
+		if err := w.WriteKey("age"); err != nil {
+			return err
+		}
+		if err := w.WriteInt(int64(v.Age)); err != nil {
+			return err
+		}
+	}
+	if err := w.EncodeField("tags", v.Tags, "omitempty"); err != nil {
+		return err
+	}
+	return w.Write(Event{Kind: MappingEndEvent})
+}
+
+var yamlFieldsOfcodecPerson = []string{"name", "age", "tags"}
+
+func (v *codecPerson) UnmarshalYAMLEvents(r *EventReader) error {
+	if _, err := r.Expect(MappingStartEvent); err != nil {
+		return err
+	}
+	for {
+		kind, err := r.NextKind()
+		if err != nil {
+			return err
+		}
+		if kind == MappingEndEvent {
+			_, err := r.Next()
+			return err
+		}
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch MatchField(key, yamlFieldsOfcodecPerson) {
+		case "name":
+			v.Name, err = r.ReadString()
+		case "age":
+			err = r.Decode(&v.Age)
+		case "tags":
+			err = r.Decode(&v.Tags)
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// codecPoint reads and writes itself as a flow sequence of two integers.
+type codecPoint struct {
+	X, Y int64
+
+	// partial makes UnmarshalYAMLEvents stop before the end of the sequence
+	partial bool
+}
+
+func (p codecPoint) MarshalYAMLEvents(w *EventWriter) error {
+	if err := w.Write(Event{Kind: SequenceStartEvent, Flow: true}); err != nil {
+		return err
+	}
+	if err := w.WriteInt(p.X); err != nil {
+		return err
+	}
+	if err := w.WriteInt(p.Y); err != nil {
+		return err
+	}
+	return w.Write(Event{Kind: SequenceEndEvent})
+}
+
+func (p *codecPoint) UnmarshalYAMLEvents(r *EventReader) (err error) {
+	if _, err = r.Expect(SequenceStartEvent); err != nil {
+		return err
+	}
+	if p.X, err = r.ReadInt(); err != nil {
+		return err
+	}
+	if p.partial {
+		return nil
+	}
+	if p.Y, err = r.ReadInt(); err != nil {
+		return err
+	}
+	_, err = r.Expect(SequenceEndEvent)
+	return err
+}
+
+type failingCodec struct{}
+
+func (failingCodec) MarshalYAMLEvents(w *EventWriter) error {
+	return errors.New("cannot marshal")
+}
+
+var _ = Describe("Event codecs", func() {
+	Context("Marshaling", func() {
+		It("writes the events of the value", func() {
+			out, err := Marshal(map[string]interface{}{
+				"a": codecPerson{Name: "ann", Tags: []string{"x"}},
+				"b": &codecPoint{X: 1, Y: 2},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal("a:\n  name: ann\n  tags:\n  - x\nb: [1, 2]\n"))
+		})
+
+		It("quotes strings that would resolve to other types", func() {
+			out, err := Marshal(codecPerson{Name: "true", Age: 3})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal("name: \"true\"\nage: 3\n"))
+		})
+
+		It("returns the error of MarshalYAMLEvents", func() {
+			_, err := Marshal([]interface{}{failingCodec{}})
+			Expect(err).To(MatchError("cannot marshal"))
+		})
+
+		It("rejects events that do not form a value", func() {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			err := enc.Encode(&struct{ P codecPoint }{})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = (&EventWriter{e: enc}).Write(Event{Kind: DocumentEndEvent})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Unmarshaling", func() {
+		It("reads the value through UnmarshalYAMLEvents", func() {
+			var v struct {
+				People []codecPerson
+				Origin *codecPoint
+			}
+			err := Unmarshal([]byte(`
+people:
+  - name: ann
+    AGE: 30
+    extra: {a: [1, 2]}
+  - {name: bob, tags: [x, y]}
+origin: [3, 4]
+`), &v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v.People).To(Equal([]codecPerson{
+				{Name: "ann", Age: 30},
+				{Name: "bob", Tags: []string{"x", "y"}},
+			}))
+			Expect(v.Origin).To(Equal(&codecPoint{X: 3, Y: 4}))
+		})
+
+		It("expands aliases", func() {
+			var v map[string]codecPoint
+			err := Unmarshal([]byte("a: &p [1, 2]\nb: *p\nc: [&x 5, *x]\n"), &v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[string]codecPoint{
+				"a": {X: 1, Y: 2},
+				"b": {X: 1, Y: 2},
+				"c": {X: 5, Y: 5},
+			}))
+		})
+
+		It("decodes nulls as usual", func() {
+			v := struct{ P *codecPoint }{P: &codecPoint{X: 1}}
+			Expect(Unmarshal([]byte("p: ~\n"), &v)).To(Succeed())
+			Expect(v.P).To(BeNil())
+		})
+
+		It("reports values of the wrong kind", func() {
+			var p codecPoint
+			err := Unmarshal([]byte("{a: 1}"), &p)
+			Expect(err).To(MatchError(ContainSubstring("Expected a sequence but was a mapping")))
+		})
+
+		It("rejects partial reads", func() {
+			p := codecPoint{partial: true}
+			err := Unmarshal([]byte("[1, 2]"), &p)
+			Expect(err).To(MatchError("UnmarshalYAMLEvents did not read a whole value"))
+		})
+
+		It("rejects reads past the end of the value", func() {
+			var v []codecPerson
+			err := Unmarshal([]byte("- name: [1]\n"), &v)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("matches field names as struct decoding does", func() {
+		names := []string{"name", "Name", "age"}
+		Expect(MatchField("Name", names)).To(Equal("Name"))
+		Expect(MatchField("NAME", names)).To(Equal("name"))
+		Expect(MatchField("Age", names)).To(Equal("age"))
+		Expect(MatchField("other", names)).To(Equal(""))
+	})
+})
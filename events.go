@@ -0,0 +1,242 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An EventKind identifies the type of an Event.
+type EventKind int
+
+const (
+	StreamStartEvent EventKind = iota + 1
+	StreamEndEvent
+	DocumentStartEvent
+	DocumentEndEvent
+	AliasEvent
+	ScalarEvent
+	SequenceStartEvent
+	SequenceEndEvent
+	MappingStartEvent
+	MappingEndEvent
+)
+
+var eventKindNames = []string{
+	StreamStartEvent:   "a stream start",
+	StreamEndEvent:     "a stream end",
+	DocumentStartEvent: "a document start",
+	DocumentEndEvent:   "a document end",
+	AliasEvent:         "an alias",
+	ScalarEvent:        "a scalar",
+	SequenceStartEvent: "a sequence",
+	SequenceEndEvent:   "a sequence end",
+	MappingStartEvent:  "a mapping",
+	MappingEndEvent:    "a mapping end",
+}
+
+func (k EventKind) String() string {
+	if k > 0 && int(k) < len(eventKindNames) {
+		return eventKindNames[k]
+	}
+	return fmt.Sprintf("an event of kind %d", int(k))
+}
+
+// An Event is one step of the serialization of a YAML stream, as produced
+// by a parser and consumed by an emitter.
+type Event struct {
+	Kind EventKind
+
+	// Anchor is the anchor defined on a scalar, sequence or mapping, or
+	// the name of the anchor an alias refers to.
+	Anchor string
+
+	// Tag is the explicit tag of a node, empty when the tag is implied.
+	Tag string
+
+	// Value is the content of a scalar.
+	Value string
+
+	// Style is the style of a scalar.
+	Style ScalarStyle
+
+	// Flow is true when a sequence or mapping uses the flow style.
+	Flow bool
+
+	// Implicit is true when the "---" marker of a document start or the
+	// "..." marker of a document end is omitted.
+	Implicit bool
+
+	// Start and End are the marks of the start and end of the event in
+	// the input, when it was read by a parser.
+	Start, End Mark
+}
+
+// eventOf returns the Event for an event read from a parser.
+func eventOf(e *yaml_event_t) Event {
+	ev := Event{
+		Kind:   EventKind(e.event_type),
+		Anchor: string(e.anchor),
+		Tag:    explicitTag(e),
+		Value:  string(e.value),
+		Start:  markOf(e.start_mark),
+		End:    markOf(e.end_mark),
+	}
+	switch e.event_type {
+	case yaml_DOCUMENT_START_EVENT, yaml_DOCUMENT_END_EVENT:
+		ev.Implicit = e.implicit
+	case yaml_SCALAR_EVENT:
+		ev.Style = ScalarStyle(e.style)
+	case yaml_SEQUENCE_START_EVENT:
+		ev.Flow = yaml_sequence_style_t(e.style) == yaml_FLOW_SEQUENCE_STYLE
+	case yaml_MAPPING_START_EVENT:
+		ev.Flow = yaml_mapping_style_t(e.style) == yaml_FLOW_MAPPING_STYLE
+	}
+	return ev
+}
+
+// EncodeEvents writes the events received from events to the stream until
+// the channel is closed, so that large documents can be written without
+// holding them in memory.
+//
+// Stream start and end events are ignored, as the Encoder writes those
+// itself. A node received outside of a document start and end pair is
+// written as a document of its own. Once EncodeEvents fails it drains
+// events, and every later call to Encode or EncodeEvents returns the same
+// error.
+func (e *Encoder) EncodeEvents(events <-chan Event) (err error) {
+	defer func() {
+		if err != nil {
+			for range events {
+			}
+		}
+	}()
+
+	if e.err != nil {
+		return e.err
+	}
+	defer func() { e.err = err }()
+	defer recovery(&err)
+
+	if !e.started {
+		e.start()
+	}
+
+	sink := eventSink{e: e}
+	for ev := range events {
+		sink.write(ev)
+	}
+	return sink.close()
+}
+
+// An eventSink writes a stream of events to an Encoder, as EncodeEvents
+// describes.
+type eventSink struct {
+	e *Encoder
+
+	// depth counts the open collections; document is true inside a
+	// document opened by a DocumentStartEvent, and implicit inside one
+	// opened for a lone node
+	depth              int
+	document, implicit bool
+}
+
+func (s *eventSink) write(ev Event) {
+	e := s.e
+	switch ev.Kind {
+	case StreamStartEvent, StreamEndEvent:
+		return
+	case DocumentStartEvent:
+		s.document = true
+		yaml_document_start_event_initialize(&e.event, nil, nil, ev.Implicit)
+		e.emit()
+		return
+	case DocumentEndEvent:
+		s.document = false
+		yaml_document_end_event_initialize(&e.event, ev.Implicit)
+		e.emit()
+		return
+	}
+
+	if !s.document && !s.implicit {
+		s.implicit = true
+		yaml_document_start_event_initialize(&e.event, nil, nil, true)
+		e.emit()
+	}
+
+	switch ev.Kind {
+	case SequenceStartEvent, MappingStartEvent:
+		s.depth++
+	case SequenceEndEvent, MappingEndEvent:
+		s.depth--
+	}
+	e.emitEvent(ev)
+
+	if s.implicit && s.depth == 0 {
+		s.implicit = false
+		yaml_document_end_event_initialize(&e.event, true)
+		e.emit()
+	}
+}
+
+// close fails if the events written left a document open.
+func (s *eventSink) close() error {
+	if s.document || s.implicit {
+		return errors.New("Unexpected end of events")
+	}
+	return nil
+}
+
+// emitEvent writes an alias, scalar, sequence or mapping event. Nodes
+// without an anchor take the one set for the next node, if any.
+func (e *Encoder) emitEvent(ev Event) {
+	anchor := []byte(ev.Anchor)
+	tag := []byte(ev.Tag)
+	implicit := ev.Tag == ""
+	switch ev.Kind {
+	case ScalarEvent, SequenceStartEvent, MappingStartEvent:
+		if ev.Anchor == "" {
+			anchor = e.takeAnchor()
+		}
+	}
+
+	switch ev.Kind {
+	case AliasEvent:
+		yaml_alias_event_initialize(&e.event, anchor)
+	case ScalarEvent:
+		yaml_scalar_event_initialize(&e.event, anchor, tag, []byte(ev.Value),
+			implicit, implicit && !emptyNull(ev.Style, ev.Value), yaml_scalar_style_t(ev.Style))
+	case SequenceStartEvent:
+		style := yaml_BLOCK_SEQUENCE_STYLE
+		if ev.Flow {
+			style = yaml_FLOW_SEQUENCE_STYLE
+		}
+		yaml_sequence_start_event_initialize(&e.event, anchor, tag, implicit, style)
+	case SequenceEndEvent:
+		yaml_sequence_end_event_initialize(&e.event)
+	case MappingStartEvent:
+		style := yaml_BLOCK_MAPPING_STYLE
+		if ev.Flow {
+			style = yaml_FLOW_MAPPING_STYLE
+		}
+		yaml_mapping_start_event_initialize(&e.event, anchor, tag, implicit, style)
+	case MappingEndEvent:
+		yaml_mapping_end_event_initialize(&e.event)
+	default:
+		panic(errors.New("Unknown event kind"))
+	}
+	e.emit()
+}
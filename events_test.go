@@ -0,0 +1,149 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Streaming encoding", func() {
+	var buf *bytes.Buffer
+	var enc *Encoder
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		enc = NewEncoder(buf)
+	})
+
+	send := func(events ...Event) <-chan Event {
+		ch := make(chan Event)
+		go func() {
+			for _, ev := range events {
+				ch <- ev
+			}
+			close(ch)
+		}()
+		return ch
+	}
+
+	It("appends a document for every call to Encode", func() {
+		Expect(enc.Encode(map[string]int{"a": 1})).To(Succeed())
+		Expect(buf.String()).To(Equal("a: 1\n"))
+		Expect(enc.Encode([]int{2})).To(Succeed())
+		Expect(enc.Encode("c")).To(Succeed())
+		Expect(buf.String()).To(Equal("a: 1\n---\n- 2\n--- c\n"))
+
+		d := NewDecoder(buf)
+		var docs []interface{}
+		for {
+			var v interface{}
+			if err := d.Decode(&v); err != nil {
+				break
+			}
+			docs = append(docs, v)
+		}
+		Expect(docs).To(HaveLen(3))
+	})
+
+	It("names anchors per document", func() {
+		enc.AnchorPointers(true)
+		s := "x"
+		Expect(enc.Encode([]*string{&s, &s})).To(Succeed())
+		Expect(enc.Encode([]*string{&s, &s})).To(Succeed())
+		Expect(buf.String()).To(Equal("- &id001 x\n- *id001\n---\n- &id001 x\n- *id001\n"))
+	})
+
+	It("writes lone nodes as documents", func() {
+		err := enc.EncodeEvents(send(
+			Event{Kind: MappingStartEvent},
+			Event{Kind: ScalarEvent, Value: "a"},
+			Event{Kind: SequenceStartEvent, Flow: true},
+			Event{Kind: ScalarEvent, Value: "1", Anchor: "one"},
+			Event{Kind: AliasEvent, Anchor: "one"},
+			Event{Kind: SequenceEndEvent},
+			Event{Kind: MappingEndEvent},
+			Event{Kind: ScalarEvent, Value: "b", Tag: "!!str", Style: DoubleQuotedStyle},
+		))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(buf.String()).To(Equal("a: [&one 1, *one]\n--- !!str \"b\"\n"))
+	})
+
+	It("writes explicit documents", func() {
+		err := enc.EncodeEvents(send(
+			Event{Kind: StreamStartEvent},
+			Event{Kind: DocumentStartEvent},
+			Event{Kind: ScalarEvent, Value: "a"},
+			Event{Kind: DocumentEndEvent},
+			Event{Kind: StreamEndEvent},
+		))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(enc.Encode("b")).To(Succeed())
+		Expect(buf.String()).To(Equal("--- a\n...\n--- b\n"))
+	})
+
+	It("fails on invalid sequences of events and drains the channel", func() {
+		ch := make(chan Event, 3)
+		ch <- Event{Kind: MappingStartEvent}
+		ch <- Event{Kind: SequenceEndEvent}
+		ch <- Event{Kind: ScalarEvent, Value: "a"}
+		close(ch)
+
+		err := enc.EncodeEvents(ch)
+		Expect(err).To(HaveOccurred())
+		Expect(ch).To(BeEmpty())
+		Expect(enc.Encode("b")).To(Equal(err))
+	})
+
+	It("fails on an unfinished document", func() {
+		err := enc.EncodeEvents(send(Event{Kind: SequenceStartEvent}))
+		Expect(err).To(MatchError("Unexpected end of events"))
+	})
+})
+
+var _ = Describe("Kinds and styles", func() {
+	It("are named", func() {
+		Expect(ScalarEvent.String()).To(Equal("a scalar"))
+		Expect(MappingNode.String()).To(Equal("mapping"))
+		Expect(ErrorNode.String()).To(Equal("error"))
+		Expect(NodeKind(0).String()).To(Equal("node kind 0"))
+		Expect(LiteralStyle.String()).To(Equal("literal"))
+		Expect(ScalarStyle(9).String()).To(Equal("style 9"))
+		Expect(DuplicateKey.String()).To(Equal("duplicate key"))
+		Expect(UnknownDirective.String()).To(Equal("unknown directive"))
+		Expect(ScanningError.String()).To(Equal("scanning error"))
+	})
+
+	It("tell the stage of parser errors", func() {
+		var v interface{}
+		err := Unmarshal([]byte("a: \"b"), &v)
+		Expect(err).To(BeAssignableToTypeOf(&ParserError{}))
+		Expect(err.(*ParserError).Kind()).To(Equal(ScanningError))
+
+		err = Unmarshal([]byte("a: [b"), &v)
+		Expect(err.(*ParserError).Kind()).To(Equal(ParsingError))
+
+		err = Unmarshal([]byte("a: \xff"), &v)
+		Expect(err.(*ParserError).Kind()).To(Equal(ReadingError))
+	})
+
+	It("give the events of unexpected event errors", func() {
+		err := &UnexpectedEventError{EventType: StreamEndEvent, At: Mark{Line: 2, Column: 1}}
+		Expect(err.EventType.String()).To(Equal("a stream end"))
+		Expect(err).To(MatchError("yaml: Unexpect event [2]: '' at line 2, column 1"))
+	})
+})
@@ -0,0 +1,204 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNeedInput is returned by FeedParser.Next when no event can be parsed
+// until more input is written.
+var ErrNeedInput = errors.New("More input is needed")
+
+var errFeedStopped = errors.New("The FeedParser is stopped")
+
+// A FeedParser parses input that is pushed to it as it arrives, instead of
+// reading it from an io.Reader: input is written to it, and the events
+// parsed so far are read with Next, which never blocks waiting for input.
+//
+// The parser runs on a goroutine of its own, which only runs while Next is
+// called. It ends once Next has returned the end of the stream or an
+// error, or when Stop is called: a FeedParser that is dropped before the
+// end of the stream, such as when its connection is lost, has to be
+// stopped for its goroutine to end.
+type FeedParser struct {
+	// input written while the parser is running, and whether it ends
+	buf    []byte
+	closed bool
+
+	// the parser goroutine: data sends it input, or nil at the end of the
+	// input, while it is waiting for some; events receives its results;
+	// closing done makes it return, after which it closes finished
+	data     chan []byte
+	events   chan feedResult
+	done     chan struct{}
+	finished chan struct{}
+	started  bool
+	stopped  bool
+	waiting  bool
+	err      error
+
+	// owned by the parser goroutine
+	parser  yaml_parser_t
+	pending []byte
+	eof     bool
+}
+
+// the result of parsing one event, or a request for input
+type feedResult struct {
+	event Event
+	err   error
+	need  bool
+}
+
+// NewFeedParser returns a FeedParser waiting for input.
+func NewFeedParser() *FeedParser {
+	p := &FeedParser{
+		data:     make(chan []byte),
+		events:   make(chan feedResult),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	yaml_parser_initialize(&p.parser)
+	p.parser.read_handler = p.read
+	return p
+}
+
+// Write adds p to the input. The bytes are copied, so the slice can be
+// reused once Write returns.
+func (p *FeedParser) Write(b []byte) (int, error) {
+	if p.closed {
+		return 0, errors.New("Write after Close")
+	}
+	p.buf = append(p.buf, b...)
+	return len(b), nil
+}
+
+// Close marks the end of the input, after which Next parses the events
+// that remain up to the end of the stream.
+func (p *FeedParser) Close() error {
+	p.closed = true
+	return nil
+}
+
+// Stop ends the parser goroutine, and waits for it to return. Next
+// returns an error once the parser is stopped, unless it had returned the
+// end of the stream or an error before.
+func (p *FeedParser) Stop() {
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+	close(p.done)
+	if p.started {
+		<-p.finished
+	}
+	if p.err == nil {
+		p.err = errFeedStopped
+	}
+}
+
+// Next returns the next event. It returns ErrNeedInput if the input
+// written so far ends before the next event is complete, and io.EOF after
+// the StreamEndEvent. Parse errors are returned by every later call.
+func (p *FeedParser) Next() (Event, error) {
+	if p.err != nil {
+		return Event{}, p.err
+	}
+	if !p.started {
+		p.started = true
+		go p.run()
+	}
+
+	for {
+		if p.waiting {
+			if len(p.buf) == 0 && !p.closed {
+				return Event{}, ErrNeedInput
+			}
+			// nil tells the parser that the input ends
+			data := p.buf
+			if len(data) == 0 {
+				data = nil
+			}
+			p.buf = nil
+			p.waiting = false
+			p.data <- data
+		}
+
+		r := <-p.events
+		switch {
+		case r.need:
+			p.waiting = true
+		case r.err != nil:
+			p.err = r.err
+			return Event{}, r.err
+		default:
+			if r.event.Kind == StreamEndEvent {
+				p.err = io.EOF
+			}
+			return r.event, nil
+		}
+	}
+}
+
+// run parses events until the end of the stream, an error or Stop.
+func (p *FeedParser) run() {
+	defer close(p.finished)
+
+	var event yaml_event_t
+	for {
+		if !yaml_parser_parse(&p.parser, &event) {
+			p.send(feedResult{err: newParserError(&p.parser)})
+			return
+		}
+		if !p.send(feedResult{event: eventOf(&event)}) || event.event_type == yaml_STREAM_END_EVENT {
+			return
+		}
+	}
+}
+
+// send passes a result to Next, and returns false when the parser is
+// stopped instead.
+func (p *FeedParser) send(r feedResult) bool {
+	select {
+	case p.events <- r:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// read is the read handler of the parser, which waits for input to be
+// written whenever it has none left.
+func (p *FeedParser) read(parser *yaml_parser_t, buffer []byte) (int, error) {
+	for len(p.pending) == 0 {
+		if p.eof {
+			return 0, io.EOF
+		}
+		if !p.send(feedResult{need: true}) {
+			return 0, errFeedStopped
+		}
+		select {
+		case p.pending = <-p.data:
+		case <-p.done:
+			return 0, errFeedStopped
+		}
+		p.eof = p.pending == nil
+	}
+	n := copy(buffer, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
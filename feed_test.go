@@ -0,0 +1,171 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"io"
+	"runtime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Feeding input", func() {
+	start := Mark{Line: 1, Column: 1}
+	input := "%YAML 1.1\n--- &a\nkey: [1, 'two', {x: y}]\nblock: |\n  text\n...\n---\n- &x x\n- \"ü\"\n"
+
+	// readAll reads the events available until more input is needed
+	readAll := func(p *FeedParser) ([]Event, error) {
+		var events []Event
+		for {
+			ev, err := p.Next()
+			if err != nil {
+				return events, err
+			}
+			events = append(events, ev)
+		}
+	}
+
+	It("parses the input written at once", func() {
+		p := NewFeedParser()
+		p.Write([]byte(input))
+		Expect(p.Close()).To(Succeed())
+
+		events, err := readAll(p)
+		Expect(err).To(Equal(io.EOF))
+		Expect(events[0]).To(Equal(Event{Kind: StreamStartEvent, Start: start, End: start}))
+		Expect(events[1]).To(Equal(Event{Kind: DocumentStartEvent, Start: start, End: Mark{13, 13, 2, 4}}))
+		Expect(events[2]).To(Equal(Event{Kind: MappingStartEvent, Anchor: "a", Start: Mark{14, 14, 2, 5}, End: Mark{17, 17, 3, 1}}))
+		Expect(events[5]).To(Equal(Event{Kind: ScalarEvent, Value: "1", Style: PlainStyle, Start: Mark{23, 23, 3, 7}, End: Mark{24, 24, 3, 8}}))
+		Expect(events[len(events)-1]).To(Equal(Event{Kind: StreamEndEvent, Start: Mark{78, 79, 10, 1}, End: Mark{78, 79, 10, 1}}))
+
+		_, err = p.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("parses the same events from input written a byte at a time", func() {
+		whole := NewFeedParser()
+		whole.Write([]byte(input))
+		whole.Close()
+		expected, _ := readAll(whole)
+
+		p := NewFeedParser()
+		var events []Event
+		needed := 0
+		for i := 0; i < len(input); i++ {
+			p.Write([]byte{input[i]})
+			got, err := readAll(p)
+			Expect(err).To(Equal(ErrNeedInput))
+			needed++
+			events = append(events, got...)
+		}
+		p.Close()
+		got, err := readAll(p)
+		Expect(err).To(Equal(io.EOF))
+		events = append(events, got...)
+
+		Expect(events).To(Equal(expected))
+		Expect(needed).To(Equal(len(input)))
+	})
+
+	It("returns events as soon as they are complete", func() {
+		p := NewFeedParser()
+		_, err := p.Next()
+		Expect(err).To(Equal(ErrNeedInput))
+
+		p.Write([]byte("- a\n- b"))
+		events, err := readAll(p)
+		Expect(err).To(Equal(ErrNeedInput))
+		Expect(events).To(Equal([]Event{
+			{Kind: StreamStartEvent, Start: start, End: start},
+			{Kind: DocumentStartEvent, Implicit: true, Start: start, End: start},
+			{Kind: SequenceStartEvent, Start: start, End: start},
+			{Kind: ScalarEvent, Value: "a", Style: PlainStyle, Start: Mark{2, 2, 1, 3}, End: Mark{3, 3, 1, 4}},
+		}))
+
+		p.Write([]byte("\n"))
+		p.Close()
+		events, err = readAll(p)
+		Expect(err).To(Equal(io.EOF))
+		Expect(events).To(HaveLen(4))
+		Expect(events[0]).To(Equal(Event{Kind: ScalarEvent, Value: "b", Style: PlainStyle, Start: Mark{6, 6, 2, 3}, End: Mark{7, 7, 2, 4}}))
+	})
+
+	It("keeps returning parse errors", func() {
+		p := NewFeedParser()
+		p.Write([]byte("a: [1\nb: 2\n"))
+		p.Close()
+
+		_, err := readAll(p)
+		Expect(err).To(BeAssignableToTypeOf(&ParserError{}))
+		_, err2 := p.Next()
+		Expect(err2).To(Equal(err))
+	})
+
+	It("ends the goroutines of the parsers stopped", func() {
+		before := runtime.NumGoroutine()
+		parsers := make([]*FeedParser, 20)
+		for i := range parsers {
+			p := NewFeedParser()
+			p.Write([]byte("a: [1, 2"))
+			_, err := readAll(p)
+			Expect(err).To(Equal(ErrNeedInput))
+			if i%2 == 0 {
+				// closed, but abandoned before the end of the stream
+				p.Close()
+				_, err = p.Next()
+				Expect(err).NotTo(HaveOccurred())
+			}
+			parsers[i] = p
+		}
+		// goroutines left by earlier tests may end meanwhile, but none start
+		during := runtime.NumGoroutine()
+		Expect(during).To(BeNumerically(">", before))
+
+		for _, p := range parsers {
+			p.Stop()
+			_, err := p.Next()
+			Expect(err).To(MatchError("The FeedParser is stopped"))
+		}
+		Eventually(runtime.NumGoroutine).Should(BeNumerically("<=", during-len(parsers)))
+		Eventually(runtime.NumGoroutine).Should(BeNumerically("<=", before))
+	})
+
+	It("keeps the end of the stream once stopped", func() {
+		p := NewFeedParser()
+		p.Close()
+		_, err := readAll(p)
+		Expect(err).To(Equal(io.EOF))
+
+		p.Stop()
+		p.Stop()
+		_, err = p.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("rejects writes after Close", func() {
+		p := NewFeedParser()
+		p.Close()
+		_, err := p.Write([]byte("a"))
+		Expect(err).To(HaveOccurred())
+
+		events, err := readAll(p)
+		Expect(err).To(Equal(io.EOF))
+		Expect(events).To(Equal([]Event{
+			{Kind: StreamStartEvent, Start: start, End: start},
+			{Kind: StreamEndEvent, Start: start, End: start},
+		}))
+	})
+})
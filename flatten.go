@@ -0,0 +1,246 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FlattenOptions controls the paths of Flatten and Unflatten.
+type FlattenOptions struct {
+	// Separator joins the keys of nested mappings. Zero selects ".".
+	Separator string
+
+	// Escape is written before a separator, a '[' or an escape that is
+	// part of a key. Zero selects '\'.
+	Escape rune
+}
+
+func (o FlattenOptions) separator() string {
+	if o.Separator == "" {
+		return "."
+	}
+	return o.Separator
+}
+
+func (o FlattenOptions) escape() string {
+	if o.Escape == 0 {
+		return `\`
+	}
+	return string(o.Escape)
+}
+
+// Flatten converts a decoded value into a map from the path of each leaf
+// value to that value, such as "servers[0].port": the keys of mappings
+// are joined by the separator and the elements of sequences are indexed.
+// Keys that are not strings are formatted with fmt. Empty mappings and
+// sequences are kept as leaves, and a value that is not a mapping or a
+// sequence is stored under the path "".
+func Flatten(v interface{}, opts FlattenOptions) map[string]interface{} {
+	flat := make(map[string]interface{})
+	flatten(flat, "", reflect.ValueOf(v), opts)
+	return flat
+}
+
+func flatten(flat map[string]interface{}, path string, v reflect.Value, opts FlattenOptions) {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Kind() == reflect.Map && v.Len() > 0:
+		for _, k := range v.MapKeys() {
+			key := escapeKey(fmt.Sprint(k.Interface()), opts)
+			if path != "" {
+				key = path + opts.separator() + key
+			}
+			flatten(flat, key, v.MapIndex(k), opts)
+		}
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Len() > 0 &&
+		v.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < v.Len(); i++ {
+			flatten(flat, path+"["+strconv.Itoa(i)+"]", v.Index(i), opts)
+		}
+	case !v.IsValid():
+		flat[path] = nil
+	default:
+		flat[path] = v.Interface()
+	}
+}
+
+// escapeKey escapes the separators, brackets and escapes in a key.
+func escapeKey(key string, opts FlattenOptions) string {
+	sep, esc := opts.separator(), opts.escape()
+	var b strings.Builder
+	for i := 0; i < len(key); {
+		switch {
+		case strings.HasPrefix(key[i:], esc):
+			b.WriteString(esc + esc)
+			i += len(esc)
+		case strings.HasPrefix(key[i:], sep):
+			b.WriteString(esc + sep)
+			i += len(sep)
+		case key[i] == '[':
+			b.WriteString(esc + "[")
+			i++
+		default:
+			b.WriteByte(key[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// a step of a path: a mapping key, or the index of a sequence element
+type pathStep struct {
+	key   string
+	index int
+}
+
+// splitPath splits a path of Flatten into its steps. A path is a list of
+// segments joined by separators, each a key followed by indexes; only the
+// first segment can leave out its key, for a root sequence.
+func splitPath(path string, opts FlattenOptions) ([]pathStep, error) {
+	sep, esc := opts.separator(), opts.escape()
+	var steps []pathStep
+	var key strings.Builder
+	first, indexed := true, false
+	pushKey := func() {
+		if !indexed && (key.Len() > 0 || !first) {
+			steps = append(steps, pathStep{key: key.String(), index: -1})
+		}
+		key.Reset()
+	}
+
+	for i := 0; i < len(path); {
+		switch {
+		case strings.HasPrefix(path[i:], sep):
+			if !indexed {
+				steps = append(steps, pathStep{key: key.String(), index: -1})
+			}
+			key.Reset()
+			first, indexed = false, false
+			i += len(sep)
+		case path[i] == '[':
+			pushKey()
+			indexed = true
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("Path %q has an unclosed index at %d", path, i)
+			}
+			n, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("Path %q has an invalid index at %d", path, i)
+			}
+			steps = append(steps, pathStep{index: n})
+			i += end + 1
+		case indexed:
+			return nil, fmt.Errorf("Path %q has a key after an index at %d", path, i)
+		case strings.HasPrefix(path[i:], esc):
+			i += len(esc)
+			if i == len(path) {
+				return nil, fmt.Errorf("Path %q ends with an escape", path)
+			}
+			_, n := utf8.DecodeRuneInString(path[i:])
+			escaped := path[i : i+n]
+			if strings.HasPrefix(path[i:], sep) {
+				escaped = sep
+			}
+			key.WriteString(escaped)
+			i += len(escaped)
+		default:
+			key.WriteByte(path[i])
+			i++
+		}
+	}
+	pushKey()
+	return steps, nil
+}
+
+// Unflatten converts a map from paths to values, as returned by Flatten,
+// back into nested values: mappings are map[interface{}]interface{} with
+// string keys, as the Decoder returns for untyped mappings, and sequences
+// are []interface{}, in which the elements without a path are nil. The
+// paths are applied in sorted order, and a path that goes through a value
+// set by another path, such as "a.b" after "a", is an error.
+func Unflatten(flat map[string]interface{}, opts FlattenOptions) (interface{}, error) {
+	paths := make([]string, 0, len(flat))
+	for path := range flat {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var root interface{}
+	for _, path := range paths {
+		steps, err := splitPath(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		if root, err = setPath(root, steps, flat[path]); err != nil {
+			return nil, fmt.Errorf("Path %q %s", path, err)
+		}
+	}
+	return root, nil
+}
+
+// setPath stores value at the steps from node, and returns node, which is
+// created when it is nil.
+func setPath(node interface{}, steps []pathStep, value interface{}) (interface{}, error) {
+	if len(steps) == 0 {
+		if node != nil {
+			return nil, fmt.Errorf("conflicts with another path")
+		}
+		return value, nil
+	}
+
+	step := steps[0]
+	if step.index < 0 {
+		if node == nil {
+			node = make(map[interface{}]interface{})
+		}
+		m, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("uses a key where there is no mapping")
+		}
+		child, err := setPath(m[step.key], steps[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		m[step.key] = child
+		return m, nil
+	}
+
+	if node == nil {
+		node = []interface{}{}
+	}
+	s, ok := node.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("uses an index where there is no sequence")
+	}
+	for len(s) <= step.index {
+		s = append(s, nil)
+	}
+	child, err := setPath(s[step.index], steps[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	s[step.index] = child
+	return s, nil
+}
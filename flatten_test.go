@@ -0,0 +1,91 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Flatten", func() {
+	var doc interface{}
+
+	BeforeEach(func() {
+		doc = nil
+		Expect(Unmarshal([]byte(`server:
+  host: example.com
+  ports: [80, 443]
+  tls: {}
+users:
+- name: bob
+  roles: []
+"a.b": {"c[0]": 1}
+`), &doc)).To(Succeed())
+	})
+
+	It("maps the path of each leaf to its value", func() {
+		Expect(Flatten(doc, FlattenOptions{})).To(Equal(map[string]interface{}{
+			"server.host":     "example.com",
+			"server.ports[0]": int64(80),
+			"server.ports[1]": int64(443),
+			"server.tls":      map[interface{}]interface{}{},
+			"users[0].name":   "bob",
+			"users[0].roles":  []interface{}{},
+			`a\.b.c\[0]`:      int64(1),
+		}))
+	})
+
+	It("uses the configured separator and escape", func() {
+		flat := Flatten(doc, FlattenOptions{Separator: "/", Escape: '%'})
+		Expect(flat).To(HaveKeyWithValue("server/ports[1]", int64(443)))
+		Expect(flat).To(HaveKeyWithValue("a.b/c%[0]", int64(1)))
+	})
+
+	It("stores scalars under the empty path", func() {
+		Expect(Flatten("x", FlattenOptions{})).To(Equal(map[string]interface{}{"": "x"}))
+	})
+
+	It("unflattens what it flattened", func() {
+		for _, opts := range []FlattenOptions{{}, {Separator: "::", Escape: '~'}} {
+			v, err := Unflatten(Flatten(doc, opts), opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(doc))
+		}
+	})
+
+	It("builds nested values from overrides", func() {
+		v, err := Unflatten(map[string]interface{}{
+			"a.b":     "c",
+			"a.d[1]":  true,
+			"e\\.f.g": 1,
+		}, FlattenOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(Equal(map[interface{}]interface{}{
+			"a":   map[interface{}]interface{}{"b": "c", "d": []interface{}{nil, true}},
+			"e.f": map[interface{}]interface{}{"g": 1},
+		}))
+	})
+
+	It("rejects conflicting and invalid paths", func() {
+		_, err := Unflatten(map[string]interface{}{"a": 1, "a.b": 2}, FlattenOptions{})
+		Expect(err).To(MatchError(`Path "a.b" uses a key where there is no mapping`))
+
+		_, err = Unflatten(map[string]interface{}{"a[x]": 1}, FlattenOptions{})
+		Expect(err).To(MatchError(`Path "a[x]" has an invalid index at 1`))
+
+		_, err = Unflatten(map[string]interface{}{"a[0]b": 1}, FlattenOptions{})
+		Expect(err).To(MatchError(`Path "a[0]b" has a key after an index at 4`))
+	})
+})
@@ -0,0 +1,146 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+)
+
+// FormatOptions controls the layout of the documents written by Format.
+type FormatOptions struct {
+	// Indent is the number of spaces per nesting level, from 2 to 9.
+	// Zero selects 2.
+	Indent int
+
+	// Width is the preferred line width. Zero selects 80, and a negative
+	// width disables line folding.
+	Width int
+}
+
+// Format rewrites the YAML documents in src with uniform indentation,
+// spacing and line width, like gofmt does for Go source.
+//
+// The documents are decoded as Nodes and encoded again, so comments,
+// anchors and aliases, tags, scalar styles, flow collections and the
+// order of mapping keys are kept, as are the line breaks of the source and
+// the blank lines between the entries of block collections, a run of them
+// being written as one.
+// Comments are placed relative to the nodes they precede, follow or end
+// the line of; comments at the end of a block collection are indented as
+// its items. Directives and explicit document markers are not kept.
+func Format(src []byte, opts FormatOptions) ([]byte, error) {
+	return reformat(src, true, func(e *Encoder) {
+		yaml_emitter_set_indent(&e.emitter, opts.Indent)
+		yaml_emitter_set_width(&e.emitter, opts.Width)
+	}, nil)
+}
+
+// Minify rewrites the YAML documents in src in their most compact form:
+// every collection in flow style, scalars plain unless they would resolve
+// differently, no comments and no line breaks within a document. Like
+// Format it keeps anchors and aliases, tags and key order.
+func Minify(src []byte) ([]byte, error) {
+	return reformat(src, false, func(e *Encoder) {
+		e.emitter.compact = true
+		yaml_emitter_set_width(&e.emitter, -1)
+	}, minifyNode)
+}
+
+// minifyNode switches n and its content to the flow style and drops
+// scalar styles that are not needed.
+func minifyNode(n *Node) {
+	switch n.Kind {
+	case ScalarNode:
+		switch {
+		case n.Tag == "" && n.Value == "" && (n.Style == AnyStyle || n.Style == PlainStyle):
+			// the emitter quotes empty scalars in flow collections
+			n.Value = "~"
+		case strings.ContainsAny(n.Value, "\r\n\u0085\u2028\u2029"):
+			n.Style = DoubleQuotedStyle
+		default:
+			n.Style = normalStyle(n)
+		}
+	case SequenceNode, MappingNode:
+		n.Flow = true
+		for _, c := range n.Content {
+			minifyNode(c)
+		}
+	}
+}
+
+// reformat decodes each document of src as a Node, passes it to edit
+// when that is not nil, and encodes it again with an Encoder prepared by
+// setup.
+func reformat(src []byte, comments bool, setup func(e *Encoder), edit func(n *Node)) (out []byte, err error) {
+	defer recovery(&err)
+
+	d := NewDecoder(bytes.NewReader(src))
+	d.parser.keep_comments = comments
+	d.start()
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	setup(e)
+
+	for d.event.event_type != yaml_STREAM_END_EVENT {
+		var n Node
+		d.document(reflect.ValueOf(&n))
+		if edit != nil {
+			edit(&n)
+		}
+
+		if !e.started {
+			e.SetLineBreak(d.LineBreak())
+			e.start()
+		}
+		yaml_document_start_event_initialize(&e.event, nil, nil, true)
+		e.emit()
+		e.emitNode(&n)
+		yaml_document_end_event_initialize(&e.event, true)
+		e.emit()
+	}
+
+	if e.started {
+		e.emitter.open_ended = false
+		yaml_stream_end_event_initialize(&e.event)
+		e.emit()
+	}
+
+	// comments after the last document marker, or in a stream without
+	// documents
+	brk := "\n"
+	switch d.LineBreak() {
+	case CRBreak:
+		brk = "\r"
+	case CRLFBreak:
+		brk = "\r\n"
+	}
+	var trailing string
+	for _, c := range d.parser.comments {
+		addComment(&trailing, c, "\n")
+	}
+	trailing = strings.Trim(trailing, "\n")
+	for strings.Contains(trailing, "\n\n\n") {
+		trailing = strings.Replace(trailing, "\n\n\n", "\n\n", -1)
+	}
+	if trailing != "" {
+		buf.WriteString(strings.Replace(trailing, "\n", brk, -1))
+		buf.WriteString(brk)
+	}
+
+	return buf.Bytes(), nil
+}
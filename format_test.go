@@ -0,0 +1,147 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Format", func() {
+	format := func(src string, opts FormatOptions) string {
+		out, err := Format([]byte(src), opts)
+		Expect(err).NotTo(HaveOccurred())
+		return string(out)
+	}
+
+	It("normalizes indentation and spacing", func() {
+		Expect(format("a:\n      b:   1\n      c: [ 1,2 ]\n", FormatOptions{})).To(Equal("a:\n  b: 1\n  c: [1, 2]\n"))
+		Expect(format("a:\n  b: 1\n", FormatOptions{Indent: 4})).To(Equal("a:\n    b: 1\n"))
+	})
+
+	It("keeps comments", func() {
+		src := `# head of doc
+a: 1   # line a
+# head b
+b:
+    # head c
+    c: x
+    d: [1, 2] # flow
+    # foot of b
+e: # after e key
+- 1
+- | # literal
+  text
+# tail
+`
+		Expect(format(src, FormatOptions{})).To(Equal(`# head of doc
+a: 1 # line a
+# head b
+b:
+  # head c
+  c: x
+  d: [1, 2] # flow
+  # foot of b
+e: # after e key
+- 1
+- | # literal
+  text
+# tail
+`))
+	})
+
+	It("keeps nested foot comments at their level", func() {
+		src := "a:\n  b:\n    c: 1\n    # foot c\n  # foot b\n# foot a\n"
+		Expect(format(src, FormatOptions{})).To(Equal(src))
+	})
+
+	It("keeps blank lines between entries", func() {
+		src := "\n# header\n\na: 1\n\n\nb:\n  c: 1\n\n  d: |\n    text\n\n  e: [x,\n\n    y]\n\n  # foot of b\n\nf:\n- 1\n\n- 2\n\n"
+		Expect(format(src, FormatOptions{})).To(Equal(`# header
+
+a: 1
+
+b:
+  c: 1
+
+  d: |
+    text
+
+  e: [x, y]
+
+  # foot of b
+
+f:
+- 1
+
+- 2
+`))
+	})
+
+	It("keeps anchors, aliases and every document", func() {
+		src := "a: 1\n---\n# second\nb: &x 2 # anchored\nc: *x\n"
+		Expect(format(src, FormatOptions{})).To(Equal(src))
+	})
+
+	It("folds long plain scalars at the given width", func() {
+		src := "a: one two three four five\n"
+		Expect(format(src, FormatOptions{Width: 10})).To(Equal("a: one two three\n  four five\n"))
+		Expect(format(src, FormatOptions{Width: -1})).To(Equal(src))
+	})
+
+	It("is idempotent", func() {
+		src := "# c\nx:   {a: 1,  b: [2,3]} # f\ny:\n   - 1\n   # d\n   - 2\n"
+		once := format(src, FormatOptions{})
+		Expect(format(once, FormatOptions{})).To(Equal(once))
+	})
+
+	It("reports syntax errors", func() {
+		_, err := Format([]byte("a: [1\n"), FormatOptions{})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Minify", func() {
+	minify := func(src string) string {
+		out, err := Minify([]byte(src))
+		Expect(err).NotTo(HaveOccurred())
+		return string(out)
+	}
+
+	It("writes every document on one line", func() {
+		src := "# c\na: 1 # x\nb:\n  - x\n  - [1, 2]\n---\nc: d\n"
+		Expect(minify(src)).To(Equal("{a: 1,b: [x,[1,2]]}\n--- {c: d}\n"))
+	})
+
+	It("quotes scalars only when needed", func() {
+		src := "- 'plain'\n- 'true'\n- |\n  two\n  lines\n- !!str 1\n"
+		Expect(minify(src)).To(Equal("[plain,\"true\",\"two\\nlines\\n\",!!str 1]\n"))
+	})
+
+	It("keeps empty values null", func() {
+		Expect(minify("a:\nb: ''\n")).To(Equal("{a: ~,b: \"\"}\n"))
+	})
+
+	It("keeps anchors, aliases and complex keys", func() {
+		src := "a: &x {k: v}\nb: *x\n? [1, 2]\n: c\n"
+		out := minify(src)
+		Expect(out).To(Equal("{a: &x {k: v},b: *x,? [1,2] : c}\n"))
+
+		var before, after interface{}
+		Expect(Unmarshal([]byte(src), &before)).To(Succeed())
+		Expect(Unmarshal([]byte(out), &after)).To(Succeed())
+		Expect(after).To(Equal(before))
+	})
+})
@@ -0,0 +1,95 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// The entry points in this file never panic, whatever their input, which
+// makes them suitable targets for fuzzing: an internal error is a bug, and
+// so is RoundTrip finding that the text it wrote holds different data.
+// FuzzRoundTrip runs them with "go test -fuzz FuzzRoundTrip".
+
+// fuzzRecovery is recovery for the entry points that never panic: a
+// runtime error, which is a bug in the package, is returned as an error
+// instead of being propagated.
+func fuzzRecovery(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(runtime.Error); ok {
+			*err = fmt.Errorf("yaml: internal error: %v", e)
+			return
+		}
+		*err = panicError(r)
+	}
+}
+
+// ParseAllEvents parses src and returns its events, up to the first error
+// if there is one.
+func ParseAllEvents(src []byte) (events []Event, err error) {
+	defer fuzzRecovery(&err)
+
+	var parser yaml_parser_t
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_string(&parser, src)
+
+	var event yaml_event_t
+	for {
+		if !yaml_parser_parse(&parser, &event) {
+			return events, newParserError(&parser)
+		}
+		events = append(events, eventOf(&event))
+		if event.event_type == yaml_STREAM_END_EVENT {
+			return events, nil
+		}
+	}
+}
+
+// RoundTrip decodes the documents in doc as Nodes and encodes them again,
+// returning the text written. It fails when doc cannot be decoded, and
+// when the text written is not the same data as doc, as EqualDocuments
+// compares them, naming the first path that differs.
+func RoundTrip(doc []byte) (out []byte, err error) {
+	defer fuzzRecovery(&err)
+
+	d := NewDecoder(bytes.NewReader(doc))
+	d.EmptyDocuments(EmptyIsEOF)
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	for {
+		var n Node
+		if err := d.Decode(&n); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if err := e.Encode(&n); err != nil {
+			return nil, fmt.Errorf("yaml: round trip: %v", err)
+		}
+	}
+
+	equal, path, err := EqualDocuments(doc, buf.Bytes(), DefaultSchema)
+	if err != nil {
+		return nil, fmt.Errorf("yaml: round trip: reading the output: %v", err)
+	}
+	if !equal {
+		return nil, fmt.Errorf("yaml: round trip changed the document at %q", path)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// FuzzRoundTrip checks that ParseAllEvents and RoundTrip only fail on
+// input that is not valid YAML, starting from the examples of the
+// specification.
+func FuzzRoundTrip(f *testing.F) {
+	for _, pattern := range []string{"fixtures/specification/*.yaml", "fixtures/specification/types/*.yaml"} {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, file := range files {
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(src)
+		}
+	}
+	f.Add([]byte("---"))
+
+	// errors in the input are expected, internal errors and round trips
+	// that change the data are bugs
+	bug := func(err error) bool {
+		return err != nil && (strings.HasPrefix(err.Error(), "yaml: internal error") ||
+			strings.HasPrefix(err.Error(), "yaml: round trip"))
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if _, err := ParseAllEvents(src); bug(err) {
+			t.Fatal(err)
+		}
+		if _, err := RoundTrip(src); bug(err) {
+			t.Fatal(err)
+		}
+	})
+}
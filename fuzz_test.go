@@ -0,0 +1,78 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fuzzing entry points", func() {
+	It("parses all the events of a stream", func() {
+		events, err := ParseAllEvents([]byte("a: [1]\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		kinds := make([]EventKind, len(events))
+		for i, ev := range events {
+			kinds[i] = ev.Kind
+		}
+		Expect(kinds).To(Equal([]EventKind{StreamStartEvent, DocumentStartEvent, MappingStartEvent,
+			ScalarEvent, SequenceStartEvent, ScalarEvent, SequenceEndEvent, MappingEndEvent,
+			DocumentEndEvent, StreamEndEvent}))
+	})
+
+	It("returns the events before an error", func() {
+		events, err := ParseAllEvents([]byte("a: [1\n"))
+		Expect(err).To(BeAssignableToTypeOf(&ParserError{}))
+		Expect(events).To(HaveLen(6))
+	})
+
+	It("round trips documents", func() {
+		out, err := RoundTrip([]byte("a: &x [1, 'b']\nc: *x\n---\n? \n: {d: }\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("a: &x [1, 'b']\nc: *x\n---\n~: {d: ~}\n"))
+
+		out, err = RoundTrip(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(BeEmpty())
+	})
+
+	It("keeps empty documents", func() {
+		for input, output := range map[string]string{
+			"---":         "---\n",
+			"--- \n...\n": "---\n",
+			"---\n---":    "---\n---\n",
+			"a: 1\n---\n": "a: 1\n---\n",
+		} {
+			out, err := RoundTrip([]byte(input))
+			Expect(err).NotTo(HaveOccurred(), input)
+			Expect(string(out)).To(Equal(output), input)
+		}
+	})
+
+	It("returns decoding errors", func() {
+		_, err := RoundTrip([]byte("a: *x\n"))
+		Expect(err).To(MatchError("missing anchor: 'x' at line 0, column 3"))
+	})
+
+	It("does not panic", func() {
+		for _, input := range []string{"", "\xff", "{", "- [a, *", "? - ? - ?", "!<", "&a *a", "%TAG ! !\n--- !x"} {
+			Expect(func() {
+				ParseAllEvents([]byte(input))
+				RoundTrip([]byte(input))
+			}).NotTo(Panic(), input)
+		}
+	})
+})
@@ -0,0 +1,305 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gen generates reflection-free YAML codecs for Go structs.
+//
+// The structs whose doc comments contain a line reading
+// "candiedyaml:generate" get MarshalYAMLEvents and UnmarshalYAMLEvents
+// methods, which candiedyaml calls instead of encoding and decoding them
+// through reflection. Fields are named and omitted following their yaml
+// tags, as candiedyaml would; fields of types other than strings, booleans
+// and numbers are still encoded and decoded through reflection.
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Directive marks the structs to generate codecs for.
+const Directive = "candiedyaml:generate"
+
+type field struct {
+	goName string
+	name   string
+	kind   string // "string", "bool", "int", "uint", "float" or "" for others
+	typ    string
+	opts   string
+}
+
+type structType struct {
+	name   string
+	fields []field
+}
+
+// Generate returns the source of a file in the package of src holding the
+// codecs of the structs of src marked by Directive, or nil if there are
+// none. filename is used for positions in errors.
+func Generate(filename string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var types []structType
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			doc := ts.Doc
+			if doc == nil && len(gd.Specs) == 1 {
+				doc = gd.Doc
+			}
+			if !marked(doc) {
+				continue
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s is not a struct type", fset.Position(ts.Pos()), ts.Name.Name)
+			}
+			t, err := structFields(fset, ts.Name.Name, st)
+			if err != nil {
+				return nil, err
+			}
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by candiedyaml-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", file.Name.Name)
+	fmt.Fprintf(&buf, "import \"github.com/cloudfoundry-incubator/candiedyaml\"\n")
+	for _, t := range types {
+		writeMarshal(&buf, t)
+		writeUnmarshal(&buf, t)
+	}
+	return format.Source(buf.Bytes())
+}
+
+func marked(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		text := strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), "/*")
+		if strings.TrimSpace(text) == Directive {
+			return true
+		}
+	}
+	return false
+}
+
+// structFields lists the fields of a struct that candiedyaml would encode.
+func structFields(fset *token.FileSet, name string, st *ast.StructType) (structType, error) {
+	t := structType{name: name}
+	seen := make(map[string]bool)
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return t, fmt.Errorf("%s: embedded fields are not supported", fset.Position(f.Pos()))
+		}
+
+		tag := ""
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return t, fmt.Errorf("%s: %s", fset.Position(f.Tag.Pos()), err)
+			}
+			tag = reflect.StructTag(s).Get("yaml")
+		}
+		if tag == "-" {
+			continue
+		}
+		tagName, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			tagName, opts = tag[:i], tag[i+1:]
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "", "omitempty", "omitnil":
+			default:
+				return t, fmt.Errorf("%s: option %q is not supported", fset.Position(f.Tag.Pos()), opt)
+			}
+		}
+		if !isValidTag(tagName) {
+			tagName = ""
+		}
+
+		var typ bytes.Buffer
+		if err := format.Node(&typ, fset, f.Type); err != nil {
+			return t, err
+		}
+		for _, n := range f.Names {
+			if !n.IsExported() {
+				continue
+			}
+			fd := field{goName: n.Name, name: tagName, typ: typ.String(), opts: opts}
+			if fd.name == "" {
+				fd.name = n.Name
+			}
+			if seen[fd.name] {
+				return t, fmt.Errorf("%s: duplicate field name %q", fset.Position(n.Pos()), fd.name)
+			}
+			seen[fd.name] = true
+			if id, ok := f.Type.(*ast.Ident); ok {
+				fd.kind = basicKind(id.Name)
+			}
+			t.fields = append(t.fields, fd)
+		}
+	}
+	return t, nil
+}
+
+func basicKind(name string) string {
+	switch name {
+	case "string", "bool":
+		return name
+	case "int", "int8", "int16", "int32", "int64":
+		return "int"
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		return "uint"
+	case "float32", "float64":
+		return "float"
+	}
+	return ""
+}
+
+// isValidTag follows the rules of candiedyaml for names in yaml tags.
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("!#$%&()*+-./:<=>?@[]^_{|}~ ", c) &&
+			!unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasOption(opts, name string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writeMarshal(buf *bytes.Buffer, t structType) {
+	fmt.Fprintf(buf, "\n// MarshalYAMLEvents writes v as a YAML mapping.\n")
+	fmt.Fprintf(buf, "func (v %s) MarshalYAMLEvents(w *candiedyaml.EventWriter) error {\n", t.name)
+	fmt.Fprintf(buf, "if err := w.Write(candiedyaml.Event{Kind: candiedyaml.MappingStartEvent}); err != nil {\nreturn err\n}\n")
+	for _, f := range t.fields {
+		if f.kind == "" {
+			fmt.Fprintf(buf, "if err := w.EncodeField(%q, v.%s, %q); err != nil {\nreturn err\n}\n", f.name, f.goName, f.opts)
+			continue
+		}
+
+		value := "v." + f.goName
+		var write string
+		switch f.kind {
+		case "string":
+			write = fmt.Sprintf("w.WriteString(%s)", value)
+		case "bool":
+			write = fmt.Sprintf("w.WriteBool(%s)", value)
+		case "int":
+			write = fmt.Sprintf("w.WriteInt(%s)", convert(value, f.typ, "int64"))
+		case "uint":
+			write = fmt.Sprintf("w.WriteUint(%s)", convert(value, f.typ, "uint64"))
+		case "float":
+			write = fmt.Sprintf("w.WriteFloat(%s, %s)", convert(value, f.typ, "float64"), strings.TrimPrefix(f.typ, "float"))
+		}
+
+		omit := hasOption(f.opts, "omitempty")
+		if omit {
+			switch f.kind {
+			case "string":
+				fmt.Fprintf(buf, "if v.%s != \"\" {\n", f.goName)
+			case "bool":
+				fmt.Fprintf(buf, "if v.%s {\n", f.goName)
+			default:
+				fmt.Fprintf(buf, "if v.%s != 0 {\n", f.goName)
+			}
+		}
+		fmt.Fprintf(buf, "if err := w.WriteKey(%q); err != nil {\nreturn err\n}\n", f.name)
+		fmt.Fprintf(buf, "if err := %s; err != nil {\nreturn err\n}\n", write)
+		if omit {
+			fmt.Fprintf(buf, "}\n")
+		}
+	}
+	fmt.Fprintf(buf, "return w.Write(candiedyaml.Event{Kind: candiedyaml.MappingEndEvent})\n}\n")
+}
+
+// convert converts value from the type from to the type to, if needed.
+func convert(value, from, to string) string {
+	if from == to {
+		return value
+	}
+	return to + "(" + value + ")"
+}
+
+func writeUnmarshal(buf *bytes.Buffer, t structType) {
+	names := make([]string, len(t.fields))
+	for i, f := range t.fields {
+		names[i] = strconv.Quote(f.name)
+	}
+	fieldsVar := "yamlFieldsOf" + t.name
+	fmt.Fprintf(buf, "\nvar %s = []string{%s}\n", fieldsVar, strings.Join(names, ", "))
+
+	fmt.Fprintf(buf, "\n// UnmarshalYAMLEvents reads a YAML mapping into v.\n")
+	fmt.Fprintf(buf, "func (v *%s) UnmarshalYAMLEvents(r *candiedyaml.EventReader) error {\n", t.name)
+	fmt.Fprintf(buf, "if _, err := r.Expect(candiedyaml.MappingStartEvent); err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(buf, "for {\n")
+	fmt.Fprintf(buf, "kind, err := r.NextKind()\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(buf, "if kind == candiedyaml.MappingEndEvent {\n_, err := r.Next()\nreturn err\n}\n")
+	fmt.Fprintf(buf, "key, err := r.ReadString()\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(buf, "switch candiedyaml.MatchField(key, %s) {\n", fieldsVar)
+	for _, f := range t.fields {
+		fmt.Fprintf(buf, "case %q:\n", f.name)
+		switch {
+		case f.typ == "string":
+			fmt.Fprintf(buf, "v.%s, err = r.ReadString()\n", f.goName)
+		case f.typ == "bool":
+			fmt.Fprintf(buf, "v.%s, err = r.ReadBool()\n", f.goName)
+		case f.typ == "int64":
+			fmt.Fprintf(buf, "v.%s, err = r.ReadInt()\n", f.goName)
+		case f.typ == "uint64":
+			fmt.Fprintf(buf, "v.%s, err = r.ReadUint()\n", f.goName)
+		case f.typ == "float64":
+			fmt.Fprintf(buf, "v.%s, err = r.ReadFloat()\n", f.goName)
+		default:
+			// narrower and named types are range checked by Decode
+			fmt.Fprintf(buf, "err = r.Decode(&v.%s)\n", f.goName)
+		}
+	}
+	fmt.Fprintf(buf, "default:\nerr = r.Skip()\n}\n")
+	fmt.Fprintf(buf, "if err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(buf, "}\n}\n")
+}
@@ -0,0 +1,95 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+import (
+	"go/parser"
+	"go/token"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Generate", func() {
+	generate := func(src string) string {
+		out, err := Generate("t.go", []byte(src))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = parser.ParseFile(token.NewFileSet(), "t_yaml.go", out, 0)
+		Expect(err).NotTo(HaveOccurred())
+		return string(out)
+	}
+
+	It("generates codecs for the marked structs only", func() {
+		out := generate(`package p
+
+// T is marked.
+//
+// candiedyaml:generate
+type T struct {
+	Name string
+}
+
+type U struct {
+	Name string
+}
+`)
+		Expect(out).To(HavePrefix("// Code generated by candiedyaml-gen. DO NOT EDIT.\n\npackage p\n"))
+		Expect(out).To(ContainSubstring("func (v T) MarshalYAMLEvents(w *candiedyaml.EventWriter) error {"))
+		Expect(out).To(ContainSubstring("func (v *T) UnmarshalYAMLEvents(r *candiedyaml.EventReader) error {"))
+		Expect(out).NotTo(ContainSubstring("(v U)"))
+	})
+
+	It("returns nothing without marked structs", func() {
+		out, err := Generate("t.go", []byte("package p\n\ntype T struct{}\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(BeNil())
+	})
+
+	It("names and omits fields following their yaml tags", func() {
+		out := generate(`package p
+
+// candiedyaml:generate
+type T struct {
+	Name    string ` + "`yaml:\"name\"`" + `
+	Age     int    ` + "`yaml:\"age,omitempty\"`" + `
+	Ratio   float32
+	Tags    []string ` + "`yaml:\",omitnil\"`" + `
+	Ignored string   ` + "`yaml:\"-\"`" + `
+	private string
+}
+`)
+		Expect(out).To(ContainSubstring(`w.WriteKey("name")`))
+		Expect(out).To(ContainSubstring("if v.Age != 0 {"))
+		Expect(out).To(ContainSubstring("w.WriteInt(int64(v.Age))"))
+		Expect(out).To(ContainSubstring("w.WriteFloat(float64(v.Ratio), 32)"))
+		Expect(out).To(ContainSubstring(`w.EncodeField("Tags", v.Tags, "omitnil")`))
+		Expect(out).To(ContainSubstring(`[]string{"name", "age", "Ratio", "Tags"}`))
+		Expect(out).To(ContainSubstring("v.Name, err = r.ReadString()"))
+		Expect(out).To(ContainSubstring("err = r.Decode(&v.Age)"))
+		Expect(out).NotTo(ContainSubstring("Ignored"))
+		Expect(out).NotTo(ContainSubstring("private"))
+	})
+
+	It("rejects what it cannot generate", func() {
+		_, err := Generate("t.go", []byte("package p\n\n// candiedyaml:generate\ntype T struct {\n\tU\n}\n"))
+		Expect(err).To(MatchError("t.go:5:2: embedded fields are not supported"))
+
+		_, err = Generate("t.go", []byte("package p\n\n// candiedyaml:generate\ntype T struct {\n\tA string `yaml:\",flow\"`\n}\n"))
+		Expect(err).To(MatchError(`t.go:5:11: option "flow" is not supported`))
+
+		_, err = Generate("t.go", []byte("package p\n\n// candiedyaml:generate\ntype T int\n"))
+		Expect(err).To(MatchError("t.go:4:6: T is not a struct type"))
+	})
+})
@@ -0,0 +1,338 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+)
+
+// A shape is the type inferred for the values found at one place in the
+// samples.
+type shape struct {
+	kind     string // "bool", "int", "float", "string", "time", "list", "object", "map", "any" or "" before any value
+	nullable bool   // a null was found
+	elem     *shape // of lists
+	fields   []*inferredField
+	count    int // the objects merged
+}
+
+type inferredField struct {
+	key   string
+	shape *shape
+	count int // the objects holding the key
+}
+
+// Infer returns the source of a file of package pkg declaring a type named
+// name, with the struct types it needs, inferred from the YAML documents
+// in samples: mappings become structs with a field for each key found in
+// any of them, tagged with the key. Fields missing from some of the
+// mappings are tagged omitempty, and those that are missing or null are
+// pointers. Values of different types in different samples are typed
+// interface{}, except for integers and floats, which make a float64.
+func Infer(pkg, name string, samples ...io.Reader) ([]byte, error) {
+	root := &shape{}
+	for i, r := range samples {
+		d := candiedyaml.NewDecoder(r)
+		d.EmptyDocuments(candiedyaml.EmptyIsEOF)
+		for {
+			var n candiedyaml.Node
+			err := d.Decode(&n)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("sample %d: %s", i, err)
+			}
+			root.add(&n)
+		}
+	}
+
+	r := renderer{names: make(map[string]bool)}
+	r.declare(name, root)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	if r.time {
+		fmt.Fprintf(&buf, "import \"time\"\n\n")
+	}
+	buf.Write(r.out.Bytes())
+	return format.Source(buf.Bytes())
+}
+
+// add merges the node n into s.
+func (s *shape) add(n *candiedyaml.Node) {
+	for n.Kind == candiedyaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+
+	switch n.Kind {
+	case candiedyaml.ScalarNode:
+		if kind := scalarKind(n); kind == "" {
+			s.nullable = true
+		} else {
+			s.merge(kind)
+		}
+	case candiedyaml.SequenceNode:
+		if !s.merge("list") {
+			return
+		}
+		if s.elem == nil {
+			s.elem = &shape{}
+		}
+		for _, c := range n.Content {
+			s.elem.add(c)
+		}
+	case candiedyaml.MappingNode:
+		kind := "object"
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if k := n.Content[i]; k.Kind != candiedyaml.ScalarNode || scalarKind(k) != "string" ||
+				!isValidTag(k.Value) || goName(k.Value) == "" {
+				kind = "map"
+			}
+		}
+		if !s.merge(kind) || kind == "map" {
+			return
+		}
+		s.count++
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+			f := s.field(key)
+			f.count++
+			f.shape.add(n.Content[i+1])
+		}
+	}
+}
+
+// scalarKind returns the kind of the value of a scalar node, or "" for a
+// null.
+func scalarKind(n *candiedyaml.Node) string {
+	var v interface{}
+	if err := candiedyaml.Unmarshal([]byte(scalarText(n)), &v); err != nil {
+		return "string"
+	}
+	switch v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return "bool"
+	case int64, uint64:
+		return "int"
+	case float64:
+		return "float"
+	case time.Time:
+		return "time"
+	}
+	return "string"
+}
+
+// scalarText returns YAML text for a scalar node, to decode it as Decode
+// would.
+func scalarText(n *candiedyaml.Node) string {
+	if n.Style == candiedyaml.PlainStyle || n.Style == candiedyaml.AnyStyle {
+		if n.Tag == "" || n.Tag == "!" {
+			return n.Value
+		}
+	}
+	var buf bytes.Buffer
+	e := candiedyaml.NewEncoder(&buf)
+	if err := e.Encode(n); err != nil {
+		return "''"
+	}
+	return buf.String()
+}
+
+// merge adds a value of kind to s, reporting whether s still has that kind.
+func (s *shape) merge(kind string) bool {
+	switch {
+	case s.kind == "" || s.kind == kind:
+		s.kind = kind
+		return true
+	case s.kind == "int" && kind == "float" || s.kind == "float" && kind == "int":
+		s.kind = "float"
+	case s.kind == "object" && kind == "map":
+		s.kind, s.fields = "map", nil
+	case s.kind == "map" && kind == "object":
+	default:
+		s.kind, s.elem, s.fields = "any", nil, nil
+	}
+	return false
+}
+
+func (s *shape) field(key string) *inferredField {
+	for _, f := range s.fields {
+		if f.key == key {
+			return f
+		}
+	}
+	f := &inferredField{key: key, shape: &shape{}}
+	s.fields = append(s.fields, f)
+	return f
+}
+
+// A renderer writes the declarations of types.
+type renderer struct {
+	out   bytes.Buffer
+	names map[string]bool
+	time  bool
+}
+
+// declare writes the declaration of a type named name for s.
+func (r *renderer) declare(name string, s *shape) {
+	r.names[name] = true
+	if s.kind != "object" {
+		fmt.Fprintf(&r.out, "type %s %s\n\n", name, r.typeOf(name, s))
+		return
+	}
+
+	var fields bytes.Buffer
+	goNames := make(map[string]bool)
+	var nested []func()
+	for _, f := range s.fields {
+		fname := unique(goName(f.key), goNames)
+		optional := f.count < s.count
+		typ := r.fieldType(name+fname, f.shape, optional || f.shape.nullable, &nested)
+		tag := f.key
+		if optional {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&fields, "\t%s %s `yaml:%q`\n", fname, typ, tag)
+	}
+	fmt.Fprintf(&r.out, "type %s struct {\n%s}\n\n", name, fields.String())
+	for _, declare := range nested {
+		declare()
+	}
+}
+
+// fieldType returns the type of a field of shape s, adding the
+// declarations of the struct types it needs to nested.
+func (r *renderer) fieldType(name string, s *shape, pointer bool, nested *[]func()) string {
+	if s.kind == "object" || s.kind == "list" && s.elem != nil && s.elem.kind == "object" {
+		if s.kind == "list" {
+			name = singular(name)
+		}
+		name = r.name(name)
+		*nested = append(*nested, func() {
+			if s.kind == "object" {
+				r.declare(name, s)
+			} else {
+				r.declare(name, s.elem)
+			}
+		})
+		if s.kind == "list" {
+			return "[]" + name
+		}
+		if pointer {
+			return "*" + name
+		}
+		return name
+	}
+
+	typ := r.typeOf(name, s)
+	switch s.kind {
+	case "bool", "int", "float", "string", "time":
+		if pointer {
+			return "*" + typ
+		}
+	}
+	return typ
+}
+
+// typeOf returns the type of values of shape s that are not objects.
+func (r *renderer) typeOf(name string, s *shape) string {
+	switch s.kind {
+	case "bool":
+		return "bool"
+	case "int":
+		return "int"
+	case "float":
+		return "float64"
+	case "string":
+		return "string"
+	case "time":
+		r.time = true
+		return "time.Time"
+	case "map":
+		return "map[string]interface{}"
+	case "list":
+		if s.elem == nil {
+			return "[]interface{}"
+		}
+		var nested []func()
+		elem := r.fieldType(singular(name), s.elem, s.elem.nullable, &nested)
+		for _, declare := range nested {
+			declare()
+		}
+		return "[]" + elem
+	}
+	return "interface{}"
+}
+
+// name returns name, or name followed by a number when it is taken.
+func (r *renderer) name(name string) string {
+	return unique(name, r.names)
+}
+
+func unique(name string, taken map[string]bool) string {
+	n := name
+	for i := 2; taken[n]; i++ {
+		n = fmt.Sprintf("%s%d", name, i)
+	}
+	taken[n] = true
+	return n
+}
+
+var initialisms = map[string]bool{
+	"api": true, "cpu": true, "dns": true, "http": true, "https": true,
+	"id": true, "ip": true, "json": true, "tls": true, "ttl": true,
+	"uri": true, "url": true, "uuid": true, "yaml": true,
+}
+
+// goName returns an exported Go name for a mapping key, such as
+// "ServerURL" for "server_url".
+func goName(key string) string {
+	var name strings.Builder
+	for _, word := range strings.FieldsFunc(key, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}) {
+		if initialisms[strings.ToLower(word)] {
+			name.WriteString(strings.ToUpper(word))
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		name.WriteString(string(runes))
+	}
+	s := name.String()
+	if s != "" && !unicode.IsLetter([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// singular returns the name of the elements of a list named name.
+func singular(name string) string {
+	if strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 3 {
+		return name[:len(name)-1]
+	}
+	return name + "Item"
+}
@@ -0,0 +1,89 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Infer", func() {
+	It("infers structs from samples", func() {
+		out, err := Infer("config", "Config", strings.NewReader(`name: web
+port: 8080
+ratio: 1
+started: 2015-02-24
+server_url: http://example.com
+servers:
+- host: a
+  tags: [web, api]
+- host: b
+  weight: 2
+limits:
+  cpu: 2
+---
+name: db
+port: 5432
+ratio: 0.5
+debug: true
+started: 2015-02-25
+server_url: ~
+servers: []
+labels: {1: a}
+extra: one
+`), strings.NewReader("name: cache\nport: '6379'\nratio: 2\nstarted: 2015-02-26\nextra: [1]\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`package config
+
+import "time"
+
+type Config struct {
+	Name      string                 ` + "`yaml:\"name\"`" + `
+	Port      interface{}            ` + "`yaml:\"port\"`" + `
+	Ratio     float64                ` + "`yaml:\"ratio\"`" + `
+	Started   time.Time              ` + "`yaml:\"started\"`" + `
+	ServerURL *string                ` + "`yaml:\"server_url,omitempty\"`" + `
+	Servers   []ConfigServer         ` + "`yaml:\"servers,omitempty\"`" + `
+	Limits    *ConfigLimits          ` + "`yaml:\"limits,omitempty\"`" + `
+	Debug     *bool                  ` + "`yaml:\"debug,omitempty\"`" + `
+	Labels    map[string]interface{} ` + "`yaml:\"labels,omitempty\"`" + `
+	Extra     interface{}            ` + "`yaml:\"extra,omitempty\"`" + `
+}
+
+type ConfigServer struct {
+	Host   string   ` + "`yaml:\"host\"`" + `
+	Tags   []string ` + "`yaml:\"tags,omitempty\"`" + `
+	Weight *int     ` + "`yaml:\"weight,omitempty\"`" + `
+}
+
+type ConfigLimits struct {
+	CPU int ` + "`yaml:\"cpu\"`" + `
+}
+`))
+	})
+
+	It("declares named types for other documents", func() {
+		out, err := Infer("p", "Hosts", strings.NewReader("- a\n- b\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("package p\n\ntype Hosts []string\n"))
+	})
+
+	It("fails on invalid samples", func() {
+		_, err := Infer("p", "T", strings.NewReader("a: 1\n"), strings.NewReader("a: [\n"))
+		Expect(err).To(MatchError(HavePrefix("sample 1: ")))
+	})
+})
@@ -0,0 +1,173 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ErrPathNotFound is returned by Get when no value is at the path.
+var ErrPathNotFound = errors.New("No value at the path")
+
+// Get decodes the value at path in the first document read from r into v,
+// as Decoder.Get does, and stops reading as soon as the value is decoded.
+// It is a cheap lookup in a large file, as nothing but the value is
+// decoded, and what follows it is not even read.
+func Get(r io.Reader, path string, v interface{}, opts ...Option) error {
+	return NewDecoder(r, opts...).get(path, v, true)
+}
+
+// Get decodes the value at path in the next document into v, as Decode
+// would, skipping the other values without decoding them. A path is made
+// of the keys of mappings separated by dots and of the indices of
+// sequences in brackets, such as "spec.containers[0].image"; the empty
+// path is the whole document. Keys merged in with "<<" are not looked up,
+// but aliases are followed.
+//
+// In a path, "*" stands for any key and "[*]" for any index. v then has
+// to point to a slice, and each value matched is appended to it, in the
+// order of the document.
+//
+// It returns ErrPathNotFound when no value is at the path, and io.EOF at
+// the end of the stream.
+func (d *Decoder) Get(path string, v interface{}) error {
+	return d.get(path, v, false)
+}
+
+// a lookup of Get: where the values found go, and whether it ends with
+// the first one
+type getter struct {
+	rv       reflect.Value
+	wildcard bool
+	found    bool
+	early    bool
+}
+
+func (d *Decoder) get(path string, v interface{}, early bool) (err error) {
+	defer recovery(&err)
+
+	g := &getter{rv: reflect.ValueOf(v), early: early}
+	if g.rv.Kind() != reflect.Ptr || g.rv.IsNil() {
+		return fmt.Errorf("Expected a pointer but was a %s", g.rv.String())
+	}
+	segments := parsePath(path)
+	for _, s := range segments {
+		g.wildcard = g.wildcard || s.key == "*" || s.key == "" && s.index < 0
+	}
+	if g.wildcard && g.rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Expected a pointer to a slice for the path '%s' but was a %s", path, g.rv.String())
+	}
+
+	d.start()
+	if d.event.event_type == yaml_STREAM_END_EVENT {
+		return io.EOF
+	}
+	if d.event.event_type != yaml_DOCUMENT_START_EVENT {
+		return composingError(d.event.start_mark, "Expected document start")
+	}
+	d.nextEvent()
+	if d.event.event_type != yaml_DOCUMENT_END_EVENT && d.find(segments, g) {
+		return nil
+	}
+	d.dropSkipped()
+	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		return composingError(d.event.start_mark, "Expected document end")
+	}
+	d.nextEvent()
+
+	if !g.found {
+		return ErrPathNotFound
+	}
+	return nil
+}
+
+// find decodes the values at the path made of segments within the current
+// value, skipping the others. It reports whether the lookup is over, with
+// the rest of the document left unread.
+func (d *Decoder) find(segments []pathSegment, g *getter) bool {
+	if len(segments) == 0 {
+		g.bind(d)
+		return g.early && !g.wildcard
+	}
+
+	s := segments[0]
+	anchor := string(d.event.anchor)
+	switch d.event.event_type {
+	case yaml_ALIAS_EVENT:
+		d.replayAlias()
+		return d.find(segments, g)
+	case yaml_MAPPING_START_EVENT:
+		if s.key == "" {
+			break
+		}
+		d.begin_anchor(anchor)
+		d.nextEvent()
+		for d.event.event_type != yaml_MAPPING_END_EVENT {
+			d.checkKey()
+			if d.event.event_type != yaml_SCALAR_EVENT && d.event.event_type != yaml_ALIAS_EVENT {
+				d.skip()
+				d.skip()
+				continue
+			}
+
+			key := ""
+			d.parse(reflect.ValueOf(&key))
+			if s.key != "*" && key != s.key {
+				d.skip()
+			} else if d.find(segments[1:], g) {
+				return true
+			}
+		}
+		d.nextEvent()
+		d.end_anchor(anchor)
+		return false
+	case yaml_SEQUENCE_START_EVENT:
+		if s.key != "" {
+			break
+		}
+		d.begin_anchor(anchor)
+		d.nextEvent()
+		for i := 0; d.event.event_type != yaml_SEQUENCE_END_EVENT; i++ {
+			if s.index >= 0 && i != s.index {
+				d.skip()
+			} else if d.find(segments[1:], g) {
+				return true
+			}
+		}
+		d.nextEvent()
+		d.end_anchor(anchor)
+		return false
+	}
+	d.skip()
+	return false
+}
+
+// bind decodes the current value, the one at the path, into the value of
+// g, or appends it to the slice that points to when the path has
+// wildcards.
+func (g *getter) bind(d *Decoder) {
+	g.found = true
+	if !g.wildcard {
+		d.parse(g.rv)
+		return
+	}
+	slice := g.rv.Elem()
+	item := reflect.New(slice.Type().Elem())
+	d.parse(item)
+	slice.Set(reflect.Append(slice, item.Elem()))
+}
@@ -0,0 +1,115 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Get", func() {
+	const doc = `
+kind: Deployment
+spec:
+  replicas: 3
+  template: &t
+    containers:
+    - name: web
+      image: nginx
+    - name: log
+      image: fluentd
+  other: *t
+---
+second: true
+`
+
+	It("decodes the value at a path", func() {
+		var n int
+		Expect(Get(strings.NewReader(doc), "spec.replicas", &n)).To(Succeed())
+		Expect(n).To(Equal(3))
+
+		var image string
+		Expect(Get(strings.NewReader(doc), "spec.template.containers[1].image", &image)).To(Succeed())
+		Expect(image).To(Equal("fluentd"))
+
+		var node Node
+		Expect(Get(strings.NewReader(doc), "spec.template.containers[0]", &node)).To(Succeed())
+		Expect(node.Kind).To(Equal(MappingNode))
+		Expect(node.Line).To(Equal(7))
+	})
+
+	It("follows aliases", func() {
+		var name string
+		Expect(Get(strings.NewReader(doc), "spec.other.containers[0].name", &name)).To(Succeed())
+		Expect(name).To(Equal("web"))
+	})
+
+	It("appends the values matched by wildcards", func() {
+		var names []string
+		Expect(Get(strings.NewReader(doc), "spec.*.containers[*].name", &names)).To(Succeed())
+		Expect(names).To(Equal([]string{"web", "log", "web", "log"}))
+
+		var n int
+		Expect(Get(strings.NewReader(doc), "spec.*", &n)).To(MatchError(
+			"Expected a pointer to a slice for the path 'spec.*' but was a <*int Value>"))
+	})
+
+	It("reports paths without a value", func() {
+		var s string
+		Expect(Get(strings.NewReader(doc), "spec.replicas.count", &s)).To(Equal(ErrPathNotFound))
+		Expect(Get(strings.NewReader(doc), "spec.template.containers[2]", &s)).To(Equal(ErrPathNotFound))
+		Expect(Get(strings.NewReader(doc), "second", &s)).To(Equal(ErrPathNotFound))
+
+		var names []string
+		Expect(Get(strings.NewReader(doc), "missing[*]", &names)).To(Equal(ErrPathNotFound))
+	})
+
+	It("stops reading once the value is found", func() {
+		src := "a: 1\nb: [" + strings.Repeat("x, ", 100000) + "x]\n"
+		r := &countingReader{r: strings.NewReader(src)}
+		var a int
+		Expect(Get(r, "a", &a)).To(Succeed())
+		Expect(a).To(Equal(1))
+		Expect(r.n).To(BeNumerically("<", len(src)/10))
+	})
+
+	It("reads whole documents from a Decoder", func() {
+		d := NewDecoder(bytes.NewBufferString(doc))
+		var image string
+		Expect(d.Get("spec.template.containers[0].image", &image)).To(Succeed())
+		Expect(image).To(Equal("nginx"))
+
+		var second bool
+		Expect(d.Get("second", &second)).To(Succeed())
+		Expect(second).To(BeTrue())
+		Expect(d.Get("", &second)).To(Equal(io.EOF))
+	})
+})
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
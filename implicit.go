@@ -0,0 +1,172 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"regexp"
+	"strings"
+)
+
+// An ImplicitRule gives the plain scalars without an explicit tag that
+// match Pattern the tag Tag, in full or with the "!!" shorthand.
+//
+// Rules come before the built-in resolution of plain scalars, which they
+// can extend or override: a rule for a tag registered with RegisterType
+// decodes the scalars it matches into the registered type, one for StrTag
+// keeps them strings, as in
+//
+//	ImplicitRule{Tag: StrTag, Pattern: TimestampPattern}
+//
+// which turns off the resolution of timestamps. Scalars given an unknown
+// tag are handled by the UnknownTags policy.
+type ImplicitRule struct {
+	Tag     string
+	Pattern *regexp.Regexp
+}
+
+// ImplicitRules sets the rules the Decoder resolves plain scalars with
+// before the built-in ones. The first rule matching a scalar applies.
+// Nodes keep the tags of the scalars implied.
+func (d *Decoder) ImplicitRules(rules ...ImplicitRule) {
+	d.implicitRules = rules
+}
+
+// ImplicitRules sets the rules that the documents written are read with,
+// which the Encoder quotes the strings that would resolve to another type
+// by.
+func (e *Encoder) ImplicitRules(rules ...ImplicitRule) {
+	e.implicitRules = rules
+}
+
+// DigitSeparators sets whether the Decoder reads plain scalars with '_'
+// between their digits, such as 1_000_000, as numbers, which YAML 1.1
+// allows and is the default. When it is off they are strings, whatever
+// the schema, as with an ImplicitRule giving them StrTag.
+func (d *Decoder) DigitSeparators(allow bool) {
+	d.noSeparators = !allow
+}
+
+// DigitSeparators sets whether the documents written are read with '_'
+// separating digits. When it is off the Encoder writes strings such as
+// 1_000_000 without quotes.
+func (e *Encoder) DigitSeparators(allow bool) {
+	e.noSeparators = !allow
+}
+
+// Failsafe makes the Decoder resolve plain scalars by the failsafe schema,
+// as strings: scalars without an explicit tag that would otherwise be
+// nulls, booleans, numbers or timestamps decode into an interface{} as
+// they are written. Values decoded into typed fields are converted to
+// their types as usual, and explicit tags still apply.
+func (d *Decoder) Failsafe(on bool) {
+	d.failsafe = on
+}
+
+// Schema sets the schema the Decoder resolves plain scalars without an
+// explicit tag by, after its ImplicitRules. Under CoreSchema the scalars
+// that are not nulls, booleans or numbers of the core schema are strings,
+// and under JSONSchema they are an error, as plain mapping keys are too.
+// DefaultSchema, the default,
+// resolves them as the built-in rules do, and FailsafeSchema as Failsafe
+// does.
+func (d *Decoder) Schema(schema Schema) {
+	d.schema = schema
+}
+
+// Schema sets the schema that the documents written are read with, which
+// the Encoder quotes the strings that would resolve to another type by.
+// Under JSONSchema every string is quoted.
+func (e *Encoder) Schema(schema Schema) {
+	e.schema = schema
+}
+
+// separatedNumber reports whether a plain scalar resolves to a number
+// written with '_' between its digits.
+func separatedNumber(value string) bool {
+	if !strings.Contains(value, "_") {
+		return false
+	}
+	tag, _ := resolveInterface(yaml_event_t{implicit: true, value: []byte(value)}, false)
+	return tag == yaml_INT_TAG || tag == yaml_FLOAT_TAG
+}
+
+// implicitTag returns the tag the documents written resolve the plain
+// scalar value to by the rules of the Encoder, if any.
+func (e *Encoder) implicitTag(value string) (string, bool) {
+	if tag, ok := implicitTag(e.implicitRules, value); ok {
+		return tag, true
+	}
+	if e.noSeparators && separatedNumber(value) {
+		return yaml_STR_TAG, true
+	}
+	if e.nulls != nil {
+		if e.isNull(value) {
+			return yaml_NULL_TAG, true
+		}
+		if value == "" || null_values[value] {
+			return yaml_STR_TAG, true
+		}
+	}
+	if e.schema != DefaultSchema {
+		return plainTag(value, e.schema), true
+	}
+	return "", false
+}
+
+// implicitTag returns the tag of the first rule matching value, if any.
+func implicitTag(rules []ImplicitRule, value string) (string, bool) {
+	for _, r := range rules {
+		if r.Pattern.MatchString(value) {
+			return longTag(r.Tag), true
+		}
+	}
+	return "", false
+}
+
+// applyImplicitRules gives the current event, a plain scalar without an
+// explicit tag, the tag of the rule it matches, or else StrTag under
+// Failsafe, for a number with digit separators when they are off, the tag
+// of the null set of the Decoder, or StrTag for a string of the schema. The event stays implicit, which tells it from
+// one tagged in the source.
+func (d *Decoder) applyImplicitRules() {
+	e := &d.event
+	if e.event_type != yaml_SCALAR_EVENT || !e.implicit || len(e.tag) != 0 ||
+		yaml_scalar_style_t(e.style) != yaml_PLAIN_SCALAR_STYLE {
+		return
+	}
+	if tag, ok := implicitTag(d.implicitRules, string(e.value)); ok {
+		e.tag = []byte(tag)
+	} else if d.failsafe || d.noSeparators && separatedNumber(string(e.value)) {
+		e.tag = []byte(yaml_STR_TAG)
+	} else if tag, ok := d.nullTag(string(e.value)); ok && d.nulls != nil {
+		e.tag = []byte(tag)
+	} else if d.schema != DefaultSchema {
+		switch plainTag(string(e.value), d.schema) {
+		case yaml_STR_TAG:
+			e.tag = []byte(yaml_STR_TAG)
+		case "":
+			d.error(decodingError(e.start_mark, "Invalid plain scalar '%s' under the JSON schema", e.value))
+		}
+	}
+}
+
+// explicitTag returns the tag of the event as written in the source,
+// leaving out the tag of an implicit rule.
+func explicitTag(e *yaml_event_t) string {
+	if e.event_type == yaml_SCALAR_EVENT && e.implicit {
+		return ""
+	}
+	return string(e.tag)
+}
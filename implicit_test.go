@@ -0,0 +1,165 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type semver string
+
+var _ = Describe("Implicit rules", func() {
+	semverRule := ImplicitRule{Tag: "!semver", Pattern: regexp.MustCompile(`^\d+\.\d+\.\d+$`)}
+
+	BeforeEach(func() {
+		RegisterType("!semver", reflect.TypeOf(semver("")))
+	})
+
+	AfterEach(func() {
+		typeRegistry.Lock()
+		delete(typeRegistry.types, "!semver")
+		delete(typeRegistry.tags, reflect.TypeOf(semver("")))
+		typeRegistry.Unlock()
+	})
+
+	decode := func(input string, rules ...ImplicitRule) interface{} {
+		d := NewDecoder(strings.NewReader(input))
+		d.ImplicitRules(rules...)
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		return v
+	}
+
+	It("resolves the scalars a rule matches to its tag", func() {
+		v := decode("[1.2.3, '1.2.3', 1.2, !!str 2.0.0]", semverRule)
+		Expect(v).To(Equal([]interface{}{semver("1.2.3"), "1.2.3", 1.2, "2.0.0"}))
+	})
+
+	It("overrides the built-in resolution", func() {
+		v := decode("[2001-01-01, 12, on]",
+			ImplicitRule{Tag: StrTag, Pattern: TimestampPattern},
+			ImplicitRule{Tag: "!!float", Pattern: regexp.MustCompile(`^\d+$`)},
+			ImplicitRule{Tag: "!!str", Pattern: regexp.MustCompile(`^(?i:on|off)$`)})
+		Expect(v).To(Equal([]interface{}{"2001-01-01", 12.0, "on"}))
+
+		Expect(decode("2001-01-01")).To(Equal(time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("leaves the tags of Nodes implied", func() {
+		d := NewDecoder(strings.NewReader("v: 1.2.3\n"))
+		d.ImplicitRules(semverRule)
+		var n Node
+		Expect(d.Decode(&n)).To(Succeed())
+		Expect(n.Content[1].Tag).To(BeEmpty())
+	})
+
+	It("quotes the strings a rule would resolve to another type", func() {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.ImplicitRules(semverRule, ImplicitRule{Tag: StrTag, Pattern: TimestampPattern})
+		Expect(e.Encode([]string{"1.2.3", "2001-01-01", "1.2"})).To(Succeed())
+		Expect(buf.String()).To(Equal("- \"1.2.3\"\n- 2001-01-01\n- \"1.2\"\n"))
+	})
+
+	Context("digit separators", func() {
+		It("reads numbers with separators as strings when they are off", func() {
+			d := NewDecoder(strings.NewReader("[1_000_000, 1_000.5, 1000, '1_0', a_b]"))
+			d.DigitSeparators(false)
+			var v interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(v).To(Equal([]interface{}{"1_000_000", "1_000.5", int64(1000), "1_0", "a_b"}))
+
+			Expect(decode("1_000_000")).To(Equal(int64(1000000)))
+		})
+
+		It("writes them without quotes when they are off", func() {
+			var buf bytes.Buffer
+			e := NewEncoder(&buf)
+			e.DigitSeparators(false)
+			Expect(e.Encode([]string{"1_000_000", "1000"})).To(Succeed())
+			Expect(buf.String()).To(Equal("- 1_000_000\n- \"1000\"\n"))
+		})
+	})
+
+	Context("failsafe", func() {
+		It("reads plain scalars without explicit tags as strings", func() {
+			d := NewDecoder(strings.NewReader("[null, ~, true, no, 12, 1.5, 2001-01-01, !!int 3, '4', {a: }]"))
+			d.Failsafe(true)
+			var v interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(v).To(Equal([]interface{}{"null", "~", "true", "no", "12", "1.5", "2001-01-01",
+				int64(3), "4", map[interface{}]interface{}{"a": ""}}))
+		})
+
+		It("still converts values decoded into typed fields", func() {
+			d := NewDecoder(strings.NewReader("{n: 12, b: true, s: null}"))
+			d.Failsafe(true)
+			var v struct {
+				N int
+				B bool
+				S string
+			}
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(v.N).To(Equal(12))
+			Expect(v.B).To(BeTrue())
+			Expect(v.S).To(Equal("null"))
+		})
+	})
+
+	Context("schemas", func() {
+		It("reads the scalars that are not of the core schema as strings", func() {
+			d := NewDecoder(strings.NewReader("[null, True, no, 0x1F, 1.5, .inf, 2001-01-01, on, !!bool yes]"))
+			d.Schema(CoreSchema)
+			var v interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(v).To(Equal([]interface{}{nil, true, "no", int64(31), 1.5, math.Inf(1), "2001-01-01",
+				"on", true}))
+		})
+
+		It("rejects plain scalars that are not of the JSON schema", func() {
+			d := NewDecoder(strings.NewReader(`{"a": [null, true, -12, 1.5e3, "x"]}`))
+			d.Schema(JSONSchema)
+			var v interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(v).To(Equal(map[interface{}]interface{}{"a": []interface{}{nil, true, int64(-12), 1500.0, "x"}}))
+
+			d = NewDecoder(strings.NewReader("a: [True]"))
+			d.Schema(JSONSchema)
+			Expect(d.Decode(&v)).To(MatchError("Invalid plain scalar 'a' under the JSON schema at line 0, column 0"))
+		})
+
+		It("quotes the strings the schema would resolve to another type", func() {
+			buf := &bytes.Buffer{}
+			e := NewEncoder(buf)
+			e.Schema(CoreSchema)
+			Expect(e.Encode([]string{"yes", "True", "0o17", "2001-01-01", "x"})).To(Succeed())
+			Expect(buf.String()).To(Equal("- yes\n- \"True\"\n- \"0o17\"\n- 2001-01-01\n- x\n"))
+
+			buf.Reset()
+			e = NewEncoder(buf)
+			e.Schema(JSONSchema)
+			Expect(e.Encode(map[string]interface{}{"a": []interface{}{"x", 1, true, nil}})).To(Succeed())
+			Expect(buf.String()).To(Equal("\"a\":\n- \"x\"\n- 1\n- true\n- null\n"))
+		})
+	})
+})
@@ -0,0 +1,94 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"math/big"
+	"strings"
+	"time"
+)
+
+// A scalar decoded into an interface{}, including the elements of the
+// []interface{} and map[interface{}]interface{} values built for
+// collections, becomes a value of the Go type of the tag it resolves to:
+//
+//	!!null       nil
+//	!!bool       bool
+//	!!int        int64, or Number with UseNumber
+//	!!float      float64, or Number with UseNumber
+//	!!timestamp  time.Time, or string with TimestampStrings
+//	!!binary     []byte
+//	!!str        string
+//
+// Integers beyond the range of an int64 are decoded as set by BigInts.
+// Scalars with other tags decode to their string value, or to the type
+// registered for the tag with RegisterType.
+
+// A BigIntPolicy selects what the Decoder does with an integer too large
+// for an int64 when decoding into an interface{}.
+type BigIntPolicy int
+
+const (
+	// BigIntsAsFloats decodes them to the nearest float64, as a float
+	// with the same digits would be.
+	BigIntsAsFloats BigIntPolicy = iota
+	// BigIntsAsBigInts decodes them to a *big.Int.
+	BigIntsAsBigInts
+	// ErrorOnBigInts fails the decoding, so that every integer is an int64.
+	ErrorOnBigInts
+)
+
+// BigInts sets what the Decoder does with integers too large for an int64
+// when decoding into an interface{}. The default is BigIntsAsFloats.
+func (d *Decoder) BigInts(policy BigIntPolicy) {
+	d.bigInts = policy
+}
+
+// TimestampStrings sets whether timestamps decoded into an interface{} are
+// kept as their string value rather than parsed into a time.Time.
+func (d *Decoder) TimestampStrings(on bool) {
+	d.timestampStrings = on
+}
+
+// interfaceValue applies the policies of the Decoder to the value v that
+// the current scalar, resolving to tag, decodes to in an interface{}.
+func (d *Decoder) interfaceValue(tag string, v interface{}) interface{} {
+	switch v.(type) {
+	case time.Time:
+		if d.timestampStrings {
+			return string(d.event.value)
+		}
+	case float64, Number, string:
+		// big integers in other bases than ten resolve to strings
+		plain := tag == yaml_FLOAT_TAG || tag == yaml_STR_TAG && len(d.event.tag) == 0 && d.event.implicit
+		if d.bigInts == BigIntsAsFloats || !plain {
+			break
+		}
+		n, ok := bigInt(string(d.event.value))
+		if !ok {
+			break
+		}
+		if d.bigInts == ErrorOnBigInts {
+			d.error(decodingError(d.event.start_mark, "Integer '%s' overflows an int64", d.event.value))
+		}
+		return n
+	}
+	return v
+}
+
+// bigInt parses s as an integer of any size.
+func bigInt(s string) (*big.Int, bool) {
+	return new(big.Int).SetString(strings.Replace(s, "_", "", -1), 0)
+}
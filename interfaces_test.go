@@ -0,0 +1,82 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"math/big"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decoding into interface{}", func() {
+	decode := func(input string, configure func(*Decoder)) (interface{}, error) {
+		d := NewDecoder(strings.NewReader(input))
+		if configure != nil {
+			configure(d)
+		}
+		var v interface{}
+		err := d.Decode(&v)
+		return v, err
+	}
+
+	It("gives each resolved tag its Go type", func() {
+		v, err := decode(`[1, -0x10, 1.5, .inf, true, ~, 2020-01-01, !!binary aGk=, "1", text]`, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(Equal([]interface{}{
+			int64(1), int64(-16), 1.5, v.([]interface{})[3], true, nil,
+			time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), []byte("hi"), "1", "text",
+		}))
+		Expect(v.([]interface{})[3]).To(BeAssignableToTypeOf(float64(0)))
+	})
+
+	It("decodes big integers to floats by default", func() {
+		v, err := decode("18446744073709551615", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(Equal(float64(18446744073709551615)))
+	})
+
+	It("decodes big integers to big.Ints", func() {
+		v, err := decode("[-18_446_744_073_709_551_616, 0x10000000000000000, 9223372036854775807, 1e30, '18446744073709551616']", func(d *Decoder) {
+			d.BigInts(BigIntsAsBigInts)
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		n, _ := new(big.Int).SetString("-18446744073709551616", 10)
+		Expect(v.([]interface{})[0]).To(Equal(n))
+		n, _ = new(big.Int).SetString("18446744073709551616", 10)
+		Expect(v.([]interface{})[1]).To(Equal(n))
+		Expect(v.([]interface{})[2]).To(Equal(int64(9223372036854775807)))
+		Expect(v.([]interface{})[3]).To(Equal(1e30))
+		Expect(v.([]interface{})[4]).To(Equal("18446744073709551616"))
+	})
+
+	It("rejects big integers", func() {
+		_, err := decode("a: [1, 18446744073709551615]", func(d *Decoder) {
+			d.BigInts(ErrorOnBigInts)
+		})
+		Expect(err).To(MatchError(ContainSubstring("Integer '18446744073709551615' overflows an int64 at line 0, column 7")))
+	})
+
+	It("keeps timestamps as strings", func() {
+		v, err := decode("- 2020-01-01\n- !!timestamp 2020-01-01T10:00:00Z\n- 1\n", func(d *Decoder) {
+			d.TimestampStrings(true)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(Equal([]interface{}{"2020-01-01", "2020-01-01T10:00:00Z", int64(1)}))
+	})
+})
@@ -0,0 +1,133 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// KeyOrder sets the order of the keys of the mappings the encoder writes
+// at path, in the form of the paths of Positions, such as "" for the root
+// or "spec.containers[0]". In path, "[*]" stands for any index, as in
+// "spec.containers[*]", and the order of a path with an index takes
+// precedence over it. The keys listed are written first, in the order
+// given, and the others after them in their usual order: structs in the
+// order of their fields and maps sorted. KeyOrder with no keys removes
+// the order of path.
+func (e *Encoder) KeyOrder(path string, keys ...string) {
+	if len(keys) == 0 {
+		delete(e.pathKeyOrders, path)
+		return
+	}
+	if e.pathKeyOrders == nil {
+		e.pathKeyOrders = make(map[string]map[string]int)
+	}
+	e.pathKeyOrders[path] = keyRanks(keys)
+}
+
+// TypeKeyOrder is like KeyOrder for the maps and structs of type t,
+// wherever they are. The order of a path takes precedence over that of a
+// type.
+func (e *Encoder) TypeKeyOrder(t reflect.Type, keys ...string) {
+	if len(keys) == 0 {
+		delete(e.typeKeyOrders, t)
+		return
+	}
+	if e.typeKeyOrders == nil {
+		e.typeKeyOrders = make(map[reflect.Type]map[string]int)
+	}
+	e.typeKeyOrders[t] = keyRanks(keys)
+}
+
+// keyRanks returns the position of each of keys.
+func keyRanks(keys []string) map[string]int {
+	ranks := make(map[string]int, len(keys))
+	for i, k := range keys {
+		if _, ok := ranks[k]; !ok {
+			ranks[k] = i
+		}
+	}
+	return ranks
+}
+
+// keyOrder returns the ranks of the keys of the mapping of type t written
+// at the current path, or nil if no order was set for it.
+func (e *Encoder) keyOrder(t reflect.Type) map[string]int {
+	if len(e.pathKeyOrders) > 0 {
+		if ranks, ok := e.pathKeyOrders[string(e.path)]; ok {
+			return ranks
+		}
+		if ranks, ok := e.pathKeyOrders[wildcardPath(e.path)]; ok {
+			return ranks
+		}
+	}
+	return e.typeKeyOrders[t]
+}
+
+// wildcardPath returns path with the indexes of sequences replaced by "*".
+func wildcardPath(path []byte) string {
+	b := make([]byte, 0, len(path))
+	for i := 0; i < len(path); i++ {
+		b = append(b, path[i])
+		if path[i] != '[' {
+			continue
+		}
+		j := i + 1
+		for j < len(path) && is_digit(path[j]) {
+			j++
+		}
+		if j > i+1 && j < len(path) && path[j] == ']' {
+			b = append(b, '*')
+			i = j - 1
+		}
+	}
+	return string(b)
+}
+
+// rank returns the rank of key, with the keys not ranked after the others.
+func rank(ranks map[string]int, key string) int {
+	if r, ok := ranks[key]; ok {
+		return r
+	}
+	return len(ranks)
+}
+
+// orderMapKeys sorts the keys of a map by their ranks, keeping the order
+// of the keys of the same rank.
+func orderMapKeys(keys []mapKey, ranks map[string]int) {
+	sort.SliceStable(keys, func(i, j int) bool {
+		return rank(ranks, keyText(keys[i].value)) < rank(ranks, keyText(keys[j].value))
+	})
+}
+
+// orderFields returns the fields of a struct sorted by the ranks of their
+// names, keeping the order of the fields of the same rank.
+func orderFields(fields []field, ranks map[string]int) []field {
+	fields = append([]field(nil), fields...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return rank(ranks, fields[i].name) < rank(ranks, fields[j].name)
+	})
+	return fields
+}
+
+// keyText returns the text a map key is ranked by.
+func keyText(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	return fmt.Sprint(k.Interface())
+}
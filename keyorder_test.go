@@ -0,0 +1,100 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Key order", func() {
+	var (
+		buf *bytes.Buffer
+		enc *Encoder
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		enc = NewEncoder(buf)
+	})
+
+	manifest := map[string]interface{}{
+		"spec":       map[string]interface{}{"replicas": 2},
+		"metadata":   map[string]interface{}{"name": "web", "labels": map[string]string{}},
+		"kind":       "Deployment",
+		"apiVersion": "apps/v1",
+		"items": []interface{}{
+			map[string]interface{}{"value": 1, "name": "a"},
+			map[string]interface{}{"value": 2, "name": "b"},
+		},
+	}
+
+	It("writes the fields of structs in the order of their declaration", func() {
+		type config struct {
+			Zone string
+			App  string
+		}
+		Expect(enc.Encode(config{"eu", "web"})).To(Succeed())
+		Expect(buf.String()).To(Equal("Zone: eu\nApp: web\n"))
+	})
+
+	It("writes the keys listed for a path first", func() {
+		enc.KeyOrder("", "apiVersion", "kind", "metadata", "spec")
+		enc.KeyOrder("metadata", "name")
+		enc.KeyOrder("items[*]", "name")
+		Expect(enc.Encode(manifest)).To(Succeed())
+		Expect(buf.String()).To(Equal(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels: {}
+spec:
+  replicas: 2
+items:
+- name: a
+  value: 1
+- name: b
+  value: 2
+`))
+	})
+
+	It("orders the keys of a type wherever it is", func() {
+		type pair struct {
+			Value int
+			Name  string
+		}
+		enc.TypeKeyOrder(reflect.TypeOf(pair{}), "Name")
+		enc.TypeKeyOrder(reflect.TypeOf(map[string]int{}), "z")
+		enc.KeyOrder("[1]", "Value")
+		Expect(enc.Encode([]interface{}{pair{1, "a"}, pair{2, "b"}, map[string]int{"a": 1, "z": 2}})).To(Succeed())
+		Expect(buf.String()).To(Equal(`- Name: a
+  Value: 1
+- Value: 2
+  Name: b
+- z: 2
+  a: 1
+`))
+	})
+
+	It("removes an order set with no keys", func() {
+		enc.KeyOrder("", "b")
+		enc.KeyOrder("")
+		Expect(enc.Encode(map[string]int{"a": 1, "b": 2})).To(Succeed())
+		Expect(buf.String()).To(Equal("a: 1\nb: 2\n"))
+	})
+})
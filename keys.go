@@ -0,0 +1,217 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A ComplexKey stands in for a sequence or mapping used as a mapping key
+// when decoding into interface{}, since such values cannot be Go map keys.
+// It holds the key in canonical flow form, e.g. "[a, b]" or "{x: 1, y: 2}",
+// so equal keys compare equal. Unmarshal the ComplexKey to get the key's
+// value back.
+//
+// Keys can also be decoded into comparable Go types: sequences into arrays
+// and mappings into structs.
+type ComplexKey string
+
+var complexKeyType = reflect.TypeOf(ComplexKey(""))
+
+// hashableKey returns k, or its ComplexKey when k cannot be a map key.
+func hashableKey(k interface{}) interface{} {
+	switch reflect.ValueOf(k).Kind() {
+	case reflect.Slice, reflect.Map:
+		return complexKey(k)
+	}
+	return k
+}
+
+// complexKey renders k in canonical flow form.
+func complexKey(k interface{}) ComplexKey {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.flow = true
+	if err := e.Encode(k); err != nil {
+		panic(err)
+	}
+	return ComplexKey(strings.TrimSuffix(buf.String(), "\n"))
+}
+
+// emitComplexKey writes the value held by a ComplexKey. Keys that are not
+// simple keys are written in the explicit "? key" form by the emitter.
+func (e *Encoder) emitComplexKey(k string) {
+	var n Node
+	if err := Unmarshal([]byte(k), &n); err != nil {
+		panic(err)
+	}
+	e.emitNode(&n)
+}
+
+var (
+	textMarshalerType = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
+	stringerType      = reflect.TypeOf(new(fmt.Stringer)).Elem()
+)
+
+// A mapKey is a key of a Go map being encoded: the key, the value written
+// in its place and, unless that is a string, boolean or number, the text
+// it is sorted by.
+type mapKey struct {
+	key, value reflect.Value
+	text       string
+}
+
+// mapKeys returns the keys of the map v in the order they are written,
+// sorted by kind, then by value for strings, booleans and numbers and by
+// their text for others. A key is written
+//
+//   - as it writes itself when it is a Marshaler or a Node, or its type is
+//     registered,
+//   - as the string of its text when it is an encoding.TextMarshaler,
+//   - as a scalar when it is a string, boolean or number, and as a complex
+//     key when it is an array or struct,
+//   - as the string it returns when it is any other fmt.Stringer,
+//   - as the key it refers to when it is a pointer or interface.
+//
+// Any other key, and keys written the same way, fail with an error naming
+// the path of the map. Keys of unsupported values left out under
+// SkipUnsupported are left out.
+func (e *Encoder) mapKeys(v reflect.Value) []mapKey {
+	keys := make([]mapKey, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		if !e.skipped(k) {
+			keys = append(keys, e.mapKey(k))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+
+	for i := 1; i < len(keys); i++ {
+		if a, b := keys[i-1], keys[i]; a.same(b) {
+			panic(fmt.Errorf("Cannot encode the map of %s, whose keys %s and %s are both written as %s",
+				e.where(), keyName(a.key), keyName(b.key), keyName(b.value)))
+		}
+	}
+	return keys
+}
+
+// mapKey returns the mapKey of the key k.
+func (e *Encoder) mapKey(k reflect.Value) mapKey {
+	mk := mapKey{key: k, value: k}
+	for {
+		kt := mk.value.Type()
+		switch {
+		case kt == nodeType || kt.Implements(marshalerType) || kt.Implements(eventMarshalerType) ||
+			kt != complexKeyType && registeredTag(kt) != "":
+			mk.text = string(complexKey(mk.value.Interface()))
+			return mk
+		case kt.Implements(textMarshalerType):
+			text, err := mk.value.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				panic(fmt.Errorf("Cannot encode the key %s of %s: %s", keyName(k), e.where(), err))
+			}
+			mk.value = reflect.ValueOf(string(text))
+			return mk
+		}
+
+		switch mk.value.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64:
+			return mk
+		case reflect.Array, reflect.Struct:
+			mk.text = string(complexKey(mk.value.Interface()))
+			return mk
+		}
+
+		switch {
+		case kt.Implements(stringerType):
+			mk.value = reflect.ValueOf(mk.value.Interface().(fmt.Stringer).String())
+			return mk
+		case (mk.value.Kind() == reflect.Ptr || mk.value.Kind() == reflect.Interface) && mk.value.IsNil():
+			mk.text = "null"
+			return mk
+		case mk.value.Kind() == reflect.Ptr || mk.value.Kind() == reflect.Interface:
+			mk.value = mk.value.Elem()
+		default:
+			panic(fmt.Errorf("Cannot encode the key %s of %s: a %s is not a mapping key", keyName(k), e.where(), kt))
+		}
+	}
+}
+
+// less orders the keys of a map as mapKeys does.
+func (a mapKey) less(b mapKey) bool {
+	av, bv := a.value, b.value
+	if av.Kind() != bv.Kind() {
+		return av.Kind() < bv.Kind()
+	}
+
+	switch av.Kind() {
+	case reflect.String:
+		if av.String() != bv.String() {
+			return av.String() < bv.String()
+		}
+		return keyType(a.key).String() < keyType(b.key).String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return av.Int() < bv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return av.Uint() < bv.Uint()
+	case reflect.Float32, reflect.Float64:
+		af, bf := av.Float(), bv.Float()
+		return af < bf || math.IsNaN(af) && !math.IsNaN(bf)
+	case reflect.Bool:
+		return !av.Bool() && bv.Bool()
+	}
+	return a.text < b.text
+}
+
+// same reports whether the keys a and b, which are distinct map keys, are
+// written the same way.
+func (a mapKey) same(b mapKey) bool {
+	if a.value.Kind() != b.value.Kind() {
+		return false
+	}
+	if a.value.Kind() == reflect.String {
+		return a.value.String() == b.value.String()
+	}
+	return a.text != "" && a.text == b.text
+}
+
+// keyType returns the type of the value of a map key.
+func keyType(k reflect.Value) reflect.Type {
+	if k.Kind() == reflect.Interface && !k.IsNil() {
+		return k.Elem().Type()
+	}
+	return k.Type()
+}
+
+// keyName returns a map key as it is named in errors.
+func keyName(k reflect.Value) string {
+	if k.Kind() == reflect.Interface && !k.IsNil() {
+		k = k.Elem()
+	}
+	if k.Kind() == reflect.String {
+		return strconv.Quote(k.String())
+	}
+	return fmt.Sprint(k.Interface())
+}
@@ -0,0 +1,193 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"io"
+)
+
+// Limits bound the input a Decoder reads, so that hostile documents cannot
+// make it buffer without end. A zero field sets no limit.
+type Limits struct {
+	// MaxScalarLength is the number of characters of the source a scalar,
+	// with its quotes, escapes and line breaks, may take.
+	MaxScalarLength int
+
+	// MaxFlowNesting is the depth flow collections may be nested to.
+	MaxFlowNesting int
+
+	// MaxSimpleKeys is the number of potential simple keys, which keep
+	// the tokens after them buffered until they are resolved, that may be
+	// outstanding at once. There is at most one for each level of flow
+	// collections, and one for the block context.
+	MaxSimpleKeys int
+
+	// MaxValueBytes is the number of bytes the values of a document may
+	// take once its aliases are expanded: each value counts nodeBytes,
+	// about the memory its decoded value and its event take, and a scalar
+	// its length on top of that. The value of an anchor counts again
+	// wherever an alias expands it. Exceeding it is an error of type
+	// *ResourceLimitError.
+	MaxValueBytes int
+
+	// MaxAliasExpansion is the number of events the aliases of a document
+	// may expand to for each event parsed in it, once they expand to more
+	// than minAliasExpansion events. It stops a small document of nested
+	// aliases early, before the Decoder goes through the values counted by
+	// MaxValueBytes. Exceeding it is an error of type *ResourceLimitError.
+	MaxAliasExpansion int
+}
+
+const (
+	// nodeBytes is the number of bytes MaxValueBytes counts for each
+	// value besides the text of scalars.
+	nodeBytes = 256
+
+	// minAliasExpansion is the number of events aliases may expand to
+	// whatever MaxAliasExpansion is, so that small documents use their
+	// anchors freely.
+	minAliasExpansion = 10000
+)
+
+// SafeLimits are the limits of the Decoders returned by NewSafeDecoder.
+var SafeLimits = Limits{
+	MaxScalarLength:   1 << 20,
+	MaxFlowNesting:    100,
+	MaxSimpleKeys:     100,
+	MaxValueBytes:     64 << 20,
+	MaxAliasExpansion: 10,
+}
+
+// NewSafeDecoder returns a Decoder reading from r with SafeLimits, for
+// input that is not trusted.
+func NewSafeDecoder(r io.Reader) *Decoder {
+	d := NewDecoder(r)
+	d.Limits(SafeLimits)
+	return d
+}
+
+// Limits sets the limits on the input the Decoder reads. Input exceeding
+// one is an error of type *LimitError, except for MaxValueBytes.
+func (d *Decoder) Limits(limits Limits) {
+	d.parser.max_scalar_length = limits.MaxScalarLength
+	d.parser.max_flow_level = limits.MaxFlowNesting
+	d.parser.max_simple_keys = limits.MaxSimpleKeys
+	d.maxValueBytes = limits.MaxValueBytes
+	d.maxAliasExpansion = limits.MaxAliasExpansion
+}
+
+// A LimitError reports input exceeding one of the Limits of a Decoder.
+type LimitError struct {
+	// Limit is the name of the field of Limits that was exceeded, and Max
+	// its value.
+	Limit string
+	Max   int
+
+	// At is the start of the value that exceeds the limit.
+	At Mark
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("yaml: input exceeds %s of %d at line %d, column %d", e.Limit, e.Max, e.At.Line, e.At.Column)
+}
+
+// Kind returns LimitExceededError.
+func (e *LimitError) Kind() ErrorKind {
+	return LimitExceededError
+}
+
+func (e *LimitError) Location() Mark {
+	return e.At
+}
+
+func (e *LimitError) Description() string {
+	return fmt.Sprintf("input exceeds %s of %d", e.Limit, e.Max)
+}
+
+// A ResourceLimitError reports a document whose values, with its aliases
+// expanded, exceed the MaxValueBytes or the MaxAliasExpansion of a Decoder.
+type ResourceLimitError struct {
+	// Limit is the name of the field of Limits that was exceeded, and Max
+	// its value.
+	Limit string
+	Max   int
+
+	// At is the start of the value that exceeds the limit, which may be
+	// inside the value of an anchor.
+	At Mark
+}
+
+func (e *ResourceLimitError) Error() string {
+	return fmt.Sprintf("yaml: document exceeds %s of %d at line %d, column %d", e.Limit, e.Max, e.At.Line, e.At.Column)
+}
+
+// Kind returns LimitExceededError.
+func (e *ResourceLimitError) Kind() ErrorKind {
+	return LimitExceededError
+}
+
+func (e *ResourceLimitError) Location() Mark {
+	return e.At
+}
+
+func (e *ResourceLimitError) Description() string {
+	return fmt.Sprintf("document exceeds %s of %d", e.Limit, e.Max)
+}
+
+// countValueBytes adds the current event to the bytes of the values of the
+// document, which a document start resets.
+func (d *Decoder) countValueBytes() {
+	switch d.event.event_type {
+	case yaml_DOCUMENT_START_EVENT:
+		d.valueBytes = 0
+		return
+	case yaml_SCALAR_EVENT:
+		d.valueBytes += nodeBytes + len(d.event.value)
+	case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+		d.valueBytes += nodeBytes
+	default:
+		return
+	}
+	if d.valueBytes > d.maxValueBytes {
+		d.error(&ResourceLimitError{
+			Limit: "MaxValueBytes",
+			Max:   d.maxValueBytes,
+			At:    markOf(d.event.start_mark),
+		})
+	}
+}
+
+// countParsedEvent counts the current event, which was parsed, toward the
+// events of the document, which a document start resets.
+func (d *Decoder) countParsedEvent() {
+	if d.event.event_type == yaml_DOCUMENT_START_EVENT {
+		d.parsedEvents, d.expandedEvents = 0, 0
+		return
+	}
+	d.parsedEvents++
+}
+
+// countExpansion counts the events the current alias expands to.
+func (d *Decoder) countExpansion(events []yaml_event_t) {
+	d.expandedEvents += len(events)
+	if d.expandedEvents > minAliasExpansion && d.expandedEvents > d.maxAliasExpansion*d.parsedEvents {
+		d.error(&ResourceLimitError{
+			Limit: "MaxAliasExpansion",
+			Max:   d.maxAliasExpansion,
+			At:    markOf(d.event.start_mark),
+		})
+	}
+}
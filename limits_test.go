@@ -0,0 +1,148 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limits", func() {
+	decode := func(input string, limits Limits) error {
+		d := NewDecoder(strings.NewReader(input))
+		d.Limits(limits)
+		var v interface{}
+		return d.Decode(&v)
+	}
+
+	It("limits the length of scalars", func() {
+		for _, input := range []string{
+			"k: " + strings.Repeat("a", 20),
+			"k: '" + strings.Repeat("a", 20) + "'",
+			"k: |\n  " + strings.Repeat("a", 20) + "\n",
+			"k: a" + strings.Repeat(" ", 20) + "b",
+			"k: \"a" + strings.Repeat("\n", 20) + "b\"",
+		} {
+			err := decode(input, Limits{MaxScalarLength: 10})
+			Expect(err).To(HaveOccurred(), input)
+			Expect(err).To(BeAssignableToTypeOf(&LimitError{}))
+			Expect(err.(*LimitError).Limit).To(Equal("MaxScalarLength"))
+		}
+
+		Expect(decode("k: "+strings.Repeat("a", 10), Limits{MaxScalarLength: 10})).To(Succeed())
+	})
+
+	It("limits the nesting of flow collections", func() {
+		err := decode("a: [[{b: [1]}]]", Limits{MaxFlowNesting: 3})
+		Expect(err).To(MatchError("yaml: input exceeds MaxFlowNesting of 3 at line 1, column 10"))
+
+		Expect(decode("a: [[{b: 1}]]", Limits{MaxFlowNesting: 3})).To(Succeed())
+	})
+
+	It("limits the simple keys outstanding", func() {
+		err := decode("[[[[a", Limits{MaxSimpleKeys: 3})
+		Expect(err).To(BeAssignableToTypeOf(&LimitError{}))
+		Expect(err.(*LimitError).Limit).To(Equal("MaxSimpleKeys"))
+	})
+
+	Context("on the bytes of values", func() {
+		bomb := `a: &a [lol, lol, lol, lol, lol, lol, lol, lol, lol]
+b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a]
+c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b]
+d: &d [*c, *c, *c, *c, *c, *c, *c, *c, *c]
+e: &e [*d, *d, *d, *d, *d, *d, *d, *d, *d]
+`
+
+		It("counts the values of aliases each time they are expanded", func() {
+			err := decode(bomb, Limits{MaxValueBytes: 10 << 20})
+			Expect(err).To(BeAssignableToTypeOf(&ResourceLimitError{}))
+			Expect(err.(*ResourceLimitError).Limit).To(Equal("MaxValueBytes"))
+			Expect(err.(*ResourceLimitError).Max).To(Equal(10 << 20))
+
+			Expect(decode(bomb, Limits{MaxValueBytes: 20 << 20})).To(Succeed())
+		})
+
+		It("counts the scalars of the document", func() {
+			// 7 values and 24 bytes of text
+			err := decode("a: "+strings.Repeat("x", 20)+"\nb: [1, 2]\n", Limits{MaxValueBytes: 7*nodeBytes + 23})
+			Expect(err).To(MatchError(ContainSubstring("document exceeds MaxValueBytes of %d at line 2, column 8", 7*nodeBytes+23)))
+
+			Expect(decode("a: "+strings.Repeat("x", 20)+"\nb: [1, 2]\n", Limits{MaxValueBytes: 7*nodeBytes + 24})).To(Succeed())
+		})
+
+		It("counts each document on its own", func() {
+			d := NewDecoder(strings.NewReader("a: &a [xxxx]\nb: *a\n---\nb: xxxxxxxxx\n---\nc: xxxxxxxxx\n"))
+			d.Limits(Limits{MaxValueBytes: 7*nodeBytes + 10})
+			for i := 0; i < 3; i++ {
+				var v interface{}
+				Expect(d.Decode(&v)).To(Succeed())
+			}
+		})
+	})
+
+	Context("on the expansion of aliases", func() {
+		nested := func(levels int) string {
+			src := "l0: &l0 [lol, lol, lol, lol, lol, lol, lol, lol, lol]\n"
+			for i := 1; i < levels; i++ {
+				alias := fmt.Sprintf("*l%d", i-1)
+				src += fmt.Sprintf("l%d: &l%d [%s]\n", i, i, strings.Repeat(alias+", ", 8)+alias)
+			}
+			return src
+		}
+
+		It("stops nested aliases in safe decoders", func() {
+			for _, levels := range []int{6, 7, 9} {
+				d := NewSafeDecoder(strings.NewReader(nested(levels)))
+				var v interface{}
+				err := d.Decode(&v)
+				Expect(err).To(BeAssignableToTypeOf(&ResourceLimitError{}), "%d levels", levels)
+				Expect(err.(*ResourceLimitError).Limit).To(Equal("MaxAliasExpansion"))
+			}
+		})
+
+		It("stops nested aliases with MaxValueBytes alone", func() {
+			err := decode(nested(7), Limits{MaxValueBytes: SafeLimits.MaxValueBytes})
+			Expect(err).To(BeAssignableToTypeOf(&ResourceLimitError{}))
+			Expect(err.(*ResourceLimitError).Limit).To(Equal("MaxValueBytes"))
+		})
+
+		It("lets small documents use their anchors", func() {
+			src := "base: &base {" + strings.Repeat("k: v, ", 20) + "last: v}\nitems:\n" + strings.Repeat("- *base\n", 200)
+			Expect(decode(src, SafeLimits)).To(Succeed())
+			Expect(decode(nested(4), SafeLimits)).To(Succeed())
+		})
+	})
+
+	It("are kept by Reset", func() {
+		d := NewSafeDecoder(strings.NewReader("a"))
+		d.Reset(strings.NewReader(strings.Repeat("a", SafeLimits.MaxScalarLength+1)))
+		var v interface{}
+		Expect(d.Decode(&v)).To(MatchError(ContainSubstring("MaxScalarLength")))
+	})
+
+	It("apply to the documents decoded in parallel", func() {
+		d := NewDecoder(strings.NewReader("a\n---\n[[b]]\n"))
+		d.Limits(Limits{MaxFlowNesting: 1})
+		var errs []error
+		for r := range d.DecodeParallel(2, true, func() interface{} { return new(interface{}) }) {
+			errs = append(errs, r.Err)
+		}
+		Expect(errs).To(HaveLen(2))
+		Expect(errs[1]).To(BeAssignableToTypeOf(&LimitError{}))
+	})
+})
@@ -0,0 +1,385 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A MergePolicy tells how a Loader merges the documents it loads.
+type MergePolicy int
+
+const (
+	// MergeDeep merges mappings key by key, at every level, and replaces
+	// other values with those of the later documents. This is the
+	// default.
+	MergeDeep MergePolicy = iota
+
+	// MergeAppend is MergeDeep, with the items of sequences appended to
+	// those of the earlier documents rather than replacing them.
+	MergeAppend
+
+	// MergeShallow merges only the keys of the root mappings, replacing
+	// the value of each key with that of the later documents.
+	MergeShallow
+)
+
+// SourceAnnotation is the annotation holding the Source of each Node a
+// Loader loads.
+const SourceAnnotation = "source"
+
+// A Source is where a value a Loader loaded was read from.
+type Source struct {
+	File string
+	Position
+}
+
+func (s Source) String() string {
+	return fmt.Sprintf("%s:%d:%d", s.File, s.Line, s.Column)
+}
+
+// A LoadError is an error of a value read from File by a Loader.
+type LoadError struct {
+	File string
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// A Loader loads a configuration spread over YAML files, such as those
+// of a conf.d directory, into one tree, keeping the Source of each value.
+// Each file is decoded on its own, so the aliases of a file can only refer
+// to the anchors of the same file.
+type Loader struct {
+	opts  []Option
+	merge MergePolicy
+}
+
+// NewLoader returns a Loader decoding the files with a Decoder configured
+// by opts.
+func NewLoader(opts ...Option) *Loader {
+	return &Loader{opts: opts}
+}
+
+// MergePolicy sets how the documents loaded are merged, MergeDeep by
+// default.
+func (l *Loader) MergePolicy(policy MergePolicy) {
+	l.merge = policy
+}
+
+// A Project is the configuration a Loader loaded.
+type Project struct {
+	// Files are the files loaded, in order.
+	Files []string
+
+	// Documents are the documents of the files, in order, each Node of
+	// which has its Source as the annotation SourceAnnotation.
+	Documents []*Node
+
+	// Root is the tree of the Documents merged, nil when there are none.
+	// The nodes of the Documents are shared with it, and copied only when
+	// they are merged. An alias whose anchored value was merged or
+	// replaced is inlined, keeping the value it had in its file.
+	Root *Node
+
+	opts []Option
+}
+
+// Load reads the files named by paths, in order, and merges their
+// documents. A path is a file, a directory, whose .yml and .yaml files are
+// loaded in the order of their names, or a glob, whose files are loaded
+// in the order of their names. Empty documents are left out.
+func (l *Loader) Load(paths ...string) (*Project, error) {
+	p := &Project{opts: l.opts}
+	for _, path := range paths {
+		files, err := loaderFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		p.Files = append(p.Files, files...)
+	}
+
+	for _, file := range p.Files {
+		docs, err := l.loadFile(file)
+		if err != nil {
+			return nil, &LoadError{File: file, Err: err}
+		}
+		for _, doc := range docs {
+			p.Documents = append(p.Documents, doc)
+			if p.Root == nil {
+				p.Root = doc
+			} else {
+				p.Root = l.mergeNodes(p.Root, doc, 0)
+			}
+		}
+	}
+	if len(p.Documents) > 1 {
+		p.Root = inlineMissingAliases(p.Root, make(map[*Node]*Node))
+	}
+	return p, nil
+}
+
+// loaderFiles returns the files named by the path of a file, a directory
+// or a glob.
+func loaderFiles(path string) ([]string, error) {
+	if info, err := os.Stat(path); err == nil {
+		if !info.IsDir() {
+			return []string{path}, nil
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, e := range entries {
+			if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".yml" || ext == ".yaml") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+		return files, nil
+	} else if !strings.ContainsAny(path, "*?[") {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	var files []string
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && !info.IsDir() {
+			files = append(files, m)
+		}
+	}
+	return files, nil
+}
+
+// loadFile returns the documents of file, annotated with their sources.
+func (l *Loader) loadFile(file string) ([]*Node, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var docs []*Node
+	d := NewDecoder(f, l.opts...)
+	d.EmptyDocuments(EmptyIsEOF)
+	for {
+		n := &Node{}
+		if err := d.Decode(n); err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if n.Kind == 0 || n.Kind == ScalarNode && n.Tag == "" && emptyNull(n.Style, n.Value) {
+			continue
+		}
+		annotateSources(n, file)
+		docs = append(docs, n)
+	}
+}
+
+func annotateSources(n *Node, file string) {
+	n.Annotate(SourceAnnotation, Source{File: file, Position: Position{n.Line, n.Column}})
+	for _, c := range n.Content {
+		annotateSources(c, file)
+	}
+}
+
+// mergeNodes returns the node of the value over merged into base, at the
+// given depth of the document, following the MergePolicy.
+func (l *Loader) mergeNodes(base, over *Node, depth int) *Node {
+	b, o := target(base), target(over)
+	switch {
+	case b.Kind == MappingNode && o.Kind == MappingNode && (l.merge != MergeShallow || depth == 0):
+	case b.Kind == SequenceNode && o.Kind == SequenceNode && l.merge == MergeAppend:
+		merged := *b
+		merged.Anchor = ""
+		merged.Content = append(append([]*Node(nil), b.Content...), o.Content...)
+		return &merged
+	default:
+		return over
+	}
+
+	merged := *b
+	merged.Anchor = ""
+	merged.Content = append([]*Node(nil), b.Content...)
+	keys := make(map[string]int)
+	for i := 0; i+1 < len(merged.Content); i += 2 {
+		if k := target(merged.Content[i]); k.Kind == ScalarNode {
+			keys[k.Value] = i
+		}
+	}
+	for i := 0; i+1 < len(o.Content); i += 2 {
+		k := target(o.Content[i])
+		if j, ok := keys[k.Value]; ok && k.Kind == ScalarNode {
+			merged.Content[j+1] = l.mergeNodes(merged.Content[j+1], o.Content[i+1], depth+1)
+			continue
+		}
+		if k.Kind == ScalarNode {
+			keys[k.Value] = len(merged.Content)
+		}
+		merged.Content = append(merged.Content, o.Content[i], o.Content[i+1])
+	}
+	return &merged
+}
+
+// inlineMissingAliases returns n with the aliases whose anchored nodes are
+// no longer in the tree before them, as merging copied or replaced them,
+// inlined, so that each alias keeps the value it had in its file and the
+// tree encodes as valid YAML. defined maps the anchored nodes found so far
+// to those in the tree. The nodes on the way to such an alias are copied,
+// as they may be shared with the Documents.
+func inlineMissingAliases(n *Node, defined map[*Node]*Node) *Node {
+	if n.Kind == AliasNode {
+		if n.Alias == nil {
+			return n
+		}
+		t, ok := defined[n.Alias]
+		switch {
+		case ok && t == n.Alias:
+			return n
+		case ok:
+			relinked := *n
+			relinked.Alias = t
+			return &relinked
+		}
+		inlined := *n
+		inlined.Inline()
+		return inlineMissingAliases(&inlined, defined)
+	}
+
+	if n.Anchor != "" {
+		defined[n] = n
+	}
+	var content []*Node
+	for i, c := range n.Content {
+		if r := inlineMissingAliases(c, defined); r != c {
+			if content == nil {
+				content = append([]*Node(nil), n.Content...)
+			}
+			content[i] = r
+		}
+	}
+	if content == nil {
+		return n
+	}
+	copied := *n
+	copied.Content = content
+	if n.Anchor != "" {
+		defined[n] = &copied
+	}
+	return &copied
+}
+
+// Decode decodes the Root of p into v, as the Decoder of the Loader would
+// decode its text. The errors of values name the file they were read
+// from, as LoadErrors. Decode leaves v unchanged when there are no
+// documents.
+func (p *Project) Decode(v interface{}) error {
+	if p.Root == nil {
+		return nil
+	}
+	rec := &Recording{events: nodeStream(p.Root)}
+	err := rec.Replay(p.opts...).Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	n, path := p.Root, ""
+	var field *FieldError
+	if errors.As(err, &field) {
+		path = field.Path
+	}
+	for _, s := range parsePath(path) {
+		c := childNode(n, s)
+		if c == nil {
+			break
+		}
+		n = c
+	}
+	if s, ok := n.Annotation(SourceAnnotation).(Source); ok {
+		return &LoadError{File: s.File, Err: err}
+	}
+	return err
+}
+
+// childNode returns the node at the key or index of s within n, or nil.
+func childNode(n *Node, s pathSegment) *Node {
+	n = target(n)
+	switch {
+	case n.Kind == SequenceNode && s.key == "" && s.index >= 0 && s.index < len(n.Content):
+		return n.Content[s.index]
+	case n.Kind == MappingNode && s.key != "":
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if target(n.Content[i]).Value == s.key {
+				return n.Content[i+1]
+			}
+		}
+	}
+	return nil
+}
+
+// Sources returns the Source of each value of the Root of p, keyed by its
+// path as those of Positions, with the keys of mappings as they are
+// written. A value that is an alias has the Source of the alias, and the
+// values within it those of the anchored value.
+func (p *Project) Sources() map[string]Source {
+	sources := make(map[string]Source)
+	if p.Root != nil {
+		addSources(sources, "", p.Root)
+	}
+	return sources
+}
+
+func addSources(sources map[string]Source, path string, n *Node) {
+	if s, ok := n.Annotation(SourceAnnotation).(Source); ok {
+		sources[path] = s
+	}
+	n = target(n)
+	switch n.Kind {
+	case SequenceNode:
+		for i, c := range n.Content {
+			addSources(sources, fmt.Sprintf("%s[%d]", path, i), c)
+		}
+	case MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := target(n.Content[i])
+			if k.Kind != ScalarNode {
+				continue
+			}
+			if path == "" {
+				addSources(sources, k.Value, n.Content[i+1])
+			} else {
+				addSources(sources, path+"."+k.Value, n.Content[i+1])
+			}
+		}
+	}
+}
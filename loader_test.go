@@ -0,0 +1,179 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Loader", func() {
+	var dir string
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	encode := func(n *Node) string {
+		out, err := Marshal(n)
+		Expect(err).NotTo(HaveOccurred())
+		return string(out)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "loader")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Mkdir(filepath.Join(dir, "conf.d"), 0755)).To(Succeed())
+
+		write("conf.d/10-base.yml", "server:\n  host: localhost\n  port: 80\nplugins: [auth]\n")
+		write("conf.d/20-prod.yaml", "server:\n  port: 443\nplugins: [metrics]\n")
+		write("conf.d/README", "not yaml: [")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("merges the files of a directory in order, keeping their sources", func() {
+		p, err := NewLoader().Load(filepath.Join(dir, "conf.d"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Files).To(Equal([]string{
+			filepath.Join(dir, "conf.d/10-base.yml"),
+			filepath.Join(dir, "conf.d/20-prod.yaml"),
+		}))
+		Expect(p.Documents).To(HaveLen(2))
+		Expect(encode(p.Root)).To(Equal("server:\n  host: localhost\n  port: 443\nplugins: [metrics]\n"))
+
+		sources := p.Sources()
+		Expect(sources["server.host"]).To(Equal(Source{p.Files[0], Position{2, 9}}))
+		Expect(sources["server.port"]).To(Equal(Source{p.Files[1], Position{2, 9}}))
+		Expect(sources["plugins[0]"].String()).To(Equal(p.Files[1] + ":3:11"))
+
+		var config struct {
+			Server struct {
+				Host string
+				Port int
+			}
+			Plugins []string
+		}
+		Expect(p.Decode(&config)).To(Succeed())
+		Expect(config.Server.Host).To(Equal("localhost"))
+		Expect(config.Server.Port).To(Equal(443))
+		Expect(config.Plugins).To(Equal([]string{"metrics"}))
+
+		Expect(encode(p.Documents[0])).To(Equal("server:\n  host: localhost\n  port: 80\nplugins: [auth]\n"))
+	})
+
+	It("follows the merge policy", func() {
+		l := NewLoader()
+		l.MergePolicy(MergeAppend)
+		p, err := l.Load(filepath.Join(dir, "conf.d/*.y*ml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(encode(p.Root)).To(Equal("server:\n  host: localhost\n  port: 443\nplugins: [auth, metrics]\n"))
+		Expect(p.Sources()["plugins[0]"].File).To(Equal(p.Files[0]))
+
+		l.MergePolicy(MergeShallow)
+		p, err = l.Load(filepath.Join(dir, "conf.d"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(encode(p.Root)).To(Equal("server:\n  port: 443\nplugins: [metrics]\n"))
+	})
+
+	It("keeps aliases within their files", func() {
+		base := write("base.yml", "defaults: &d {retries: 3}\njob: *d\n")
+		other := write("other.yml", "defaults: {retries: 5}\nextra: *d\n")
+
+		_, err := NewLoader().Load(base, other)
+		var loadErr *LoadError
+		Expect(errors.As(err, &loadErr)).To(BeTrue())
+		Expect(loadErr.File).To(Equal(other))
+
+		override := write("override.yml", "defaults: 1\n")
+		p, err := NewLoader().Load(base, override)
+		Expect(err).NotTo(HaveOccurred())
+		var config map[string]interface{}
+		Expect(p.Decode(&config)).To(Succeed())
+		Expect(config).To(Equal(map[string]interface{}{
+			"defaults": int64(1),
+			"job":      map[interface{}]interface{}{"retries": int64(3)},
+		}))
+	})
+
+	It("inlines the aliases to the anchored values it merges", func() {
+		base := write("base.yml", "base: &b {x: 1}\nuse: *b\nlist: &l [a]\nmore: *l\n")
+		over := write("over.yml", "base: {y: 2}\nlist: [b]\n")
+
+		p, err := NewLoader().Load(base, over)
+		Expect(err).NotTo(HaveOccurred())
+		out := encode(p.Root)
+		Expect(out).To(Equal("base: {x: 1, y: 2}\nuse: {x: 1}\nlist: [b]\nmore: [a]\n"))
+
+		var config, reread map[string]interface{}
+		Expect(p.Decode(&config)).To(Succeed())
+		Expect(Unmarshal([]byte(out), &reread)).To(Succeed())
+		Expect(reread).To(Equal(config))
+
+		Expect(encode(p.Documents[0])).To(Equal("base: &b {x: 1}\nuse: *b\nlist: &l [a]\nmore: *l\n"))
+	})
+
+	It("keeps the aliases to the anchored values it leaves", func() {
+		base := write("base.yml", "base: &b {x: 1}\nuse: *b\n")
+		over := write("over.yml", "other: 2\n")
+
+		p, err := NewLoader().Load(base, over)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(encode(p.Root)).To(Equal("base: &b {x: 1}\nuse: *b\nother: 2\n"))
+	})
+
+	It("names the file of a value that fails to decode", func() {
+		p, err := NewLoader().Load(filepath.Join(dir, "conf.d"), write("bad.yml", "server: {port: high}\n"))
+		Expect(err).NotTo(HaveOccurred())
+		var config struct{ Server struct{ Port int } }
+		direct := Unmarshal([]byte("server: {port: high}\n"), &config)
+		Expect(direct).To(HaveOccurred())
+		Expect(p.Decode(&config)).To(MatchError(filepath.Join(dir, "bad.yml") + ": " + direct.Error()))
+	})
+
+	It("names the file of a value decoded into a renamed field", func() {
+		first := write("a.yaml", "server:\n  host: localhost\n  listen_port: 80\n")
+		second := write("b.yaml", "server:\n  listen_port: notanumber\n")
+		p, err := NewLoader().Load(first, second)
+		Expect(err).NotTo(HaveOccurred())
+
+		var config struct {
+			Server struct {
+				Host string
+				Port int `yaml:"listen_port"`
+			}
+		}
+		err = p.Decode(&config)
+		var loadErr *LoadError
+		Expect(errors.As(err, &loadErr)).To(BeTrue())
+		Expect(loadErr.File).To(Equal(second))
+		Expect(err).To(MatchError(second + ": server.listen_port: Invalid integer: 'notanumber' at line 1, column 15"))
+	})
+
+	It("fails on missing files", func() {
+		_, err := NewLoader().Load(filepath.Join(dir, "missing.yml"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,41 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "fmt"
+
+// A Mark is a location in the input of a parser. Index is the 0-based
+// number of characters before it and Offset the 0-based number of bytes,
+// which editors can use to find it in their buffer, and Line and Column
+// are 1-based, as those of a Position.
+type Mark struct {
+	Index  int
+	Offset int
+	Line   int
+	Column int
+}
+
+func (m Mark) String() string {
+	return fmt.Sprintf("line %d, column %d", m.Line, m.Column)
+}
+
+// Position returns the line and column of m.
+func (m Mark) Position() Position {
+	return Position{m.Line, m.Column}
+}
+
+func markOf(m YAML_mark_t) Mark {
+	return Mark{Index: m.index, Offset: m.offset, Line: m.line + 1, Column: m.column + 1}
+}
@@ -0,0 +1,72 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"unicode/utf16"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Marks", func() {
+	text := func(input []byte, n *Node) string {
+		return string(input[n.Offset:n.EndOffset])
+	}
+
+	It("gives the byte offsets of nodes", func() {
+		input := []byte("a: ü\nb: [x, \"y\"]\n")
+		var n Node
+		Expect(Unmarshal(input, &n)).To(Succeed())
+
+		Expect(n.Content[1].Offset).To(Equal(3))
+		Expect(text(input, n.Content[1])).To(Equal("ü"))
+		Expect(n.Content[2].Offset).To(Equal(6))
+		Expect(text(input, n.Content[3])).To(Equal("[x, \"y\"]"))
+		Expect(text(input, n.Content[3].Content[1])).To(Equal("\"y\""))
+	})
+
+	It("counts the bytes of UTF-16 input and its byte order mark", func() {
+		input := []byte{0xff, 0xfe}
+		for _, c := range utf16.Encode([]rune("a: ü\nb: 𝄞\n")) {
+			input = append(input, byte(c), byte(c>>8))
+		}
+		var n Node
+		Expect(Unmarshal(input, &n)).To(Succeed())
+
+		Expect(n.Content[0].Offset).To(Equal(2))
+		Expect(n.Content[1].Offset).To(Equal(8))
+		Expect(n.Content[2].Offset).To(Equal(12))
+		Expect(n.Content[3].Offset).To(Equal(18))
+		Expect(n.Content[3].EndOffset).To(Equal(22))
+	})
+
+	It("gives the marks of parser errors", func() {
+		err := Unmarshal([]byte("é: [1\nb: 2\n"), new(interface{}))
+		Expect(err).To(BeAssignableToTypeOf(&ParserError{}))
+		pe := err.(*ParserError)
+		Expect(pe.ContextMark).To(Equal(Mark{Index: 3, Offset: 4, Line: 1, Column: 4}))
+		Expect(pe.ProblemMark.Position()).To(Equal(Position{2, 2}))
+		Expect(pe.ProblemMark.Offset).To(Equal(pe.ProblemMark.Index + 1))
+	})
+
+	It("moves the offsets of the documents after an edit of a stream", func() {
+		s := ParseStream([]byte("a: 1\n---\nb: 2\n"))
+		Expect(s.Edit(0, 0, []byte("x: 0\n"))).To(Succeed())
+
+		n := s.Documents[len(s.Documents)-1].Node
+		Expect(text(s.Text(), n.Content[1])).To(Equal("2"))
+	})
+})
@@ -0,0 +1,79 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "regexp"
+
+// markerLine matches text with a line starting with a document marker or
+// a directive, once its leading spaces are removed.
+var markerLine = regexp.MustCompile("(?:^|[\n\r\u0085\u2028\u2029])[ \t]*(?:---|\\.\\.\\.|%)")
+
+// QuoteMarkers makes the encoder write every string with a line that
+// starts with "---", "..." or "%" as a double-quoted scalar, its line
+// breaks escaped, so that no line of the output can be taken for a
+// document marker or a directive, even by a tool splitting a stream on
+// lines rather than parsing it. The encoder already quotes or indents
+// such strings wherever YAML would read them as markers.
+func (e *Encoder) QuoteMarkers(on bool) {
+	e.quoteMarkers = on
+}
+
+// quotedMarkers returns the style of a scalar with the given value, which
+// is double-quoted when it has to be for QuoteMarkers.
+func (e *Encoder) quotedMarkers(value string, style yaml_scalar_style_t) yaml_scalar_style_t {
+	if e.quoteMarkers && markerLine.MatchString(value) {
+		return yaml_DOUBLE_QUOTED_SCALAR_STYLE
+	}
+	return style
+}
+
+// StrictMarkers makes a document marker, "---" or "...", that ends a
+// literal or folded block scalar an error rather than the end of the
+// document, as it is most likely a line of the scalar that lost its
+// indentation. A document still ends that way once a comment line
+// separates the marker from the scalar.
+func (d *Decoder) StrictMarkers(on bool) {
+	d.strictMarkers = on
+}
+
+// checkMarker fails on a document marker following a block scalar, for
+// StrictMarkers. Only the ends of collections and documents may come
+// between them, as they are implicit.
+func (d *Decoder) checkMarker() {
+	switch d.event.event_type {
+	case yaml_SCALAR_EVENT:
+		style := yaml_scalar_style_t(d.event.style)
+		d.blockScalar = style == yaml_LITERAL_SCALAR_STYLE || style == yaml_FOLDED_SCALAR_STYLE
+		d.blockScalarMark = d.event.start_mark
+		d.blockScalarEnd = d.event.end_mark.line
+		return
+	case yaml_SEQUENCE_END_EVENT, yaml_MAPPING_END_EVENT:
+		return
+	case yaml_DOCUMENT_START_EVENT, yaml_DOCUMENT_END_EVENT:
+		if d.event.implicit {
+			return
+		}
+		if d.blockScalar && d.event.start_mark.line == d.blockScalarEnd {
+			marker := "---"
+			if d.event.event_type == yaml_DOCUMENT_END_EVENT {
+				marker = "..."
+			}
+			d.error(composingError(d.event.start_mark,
+				"Found the document marker '%s' ending the block scalar at %s; indent it if it is a line of the scalar",
+				marker, d.blockScalarMark))
+		}
+	}
+	d.blockScalar = false
+}
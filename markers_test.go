@@ -0,0 +1,104 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Document markers", func() {
+	Context("QuoteMarkers", func() {
+		encode := func(v interface{}, quote bool) string {
+			var buf bytes.Buffer
+			e := NewEncoder(&buf)
+			e.QuoteMarkers(quote)
+			Expect(e.Encode(v)).To(Succeed())
+			return buf.String()
+		}
+
+		It("double-quotes strings with a line starting with a marker", func() {
+			v := map[string]string{
+				"a": "text\n--- more",
+				"b": "one\n  ...\ntwo",
+				"c": "%done",
+				"d": "plain -- text",
+			}
+			Expect(encode(v, true)).To(Equal(`a: "text\n--- more"
+b: "one\n  ...\ntwo"
+c: "%done"
+d: plain -- text
+`))
+
+			var back map[string]string
+			Expect(Unmarshal([]byte(encode(v, true)), &back)).To(Succeed())
+			Expect(back).To(Equal(v))
+		})
+
+		It("keeps block scalars by default", func() {
+			Expect(encode("text\n--- more", false)).To(Equal("|-\n  text\n  --- more\n"))
+		})
+
+		It("overrides the style of Nodes", func() {
+			n := &Node{Kind: ScalarNode, Style: LiteralStyle, Value: "---\nnext\n"}
+			Expect(encode(n, true)).To(Equal("\"---\\nnext\\n\"\n"))
+		})
+	})
+
+	Context("StrictMarkers", func() {
+		decode := func(src string, strict bool) (interface{}, error) {
+			d := NewDecoder(bytes.NewBufferString(src))
+			d.StrictMarkers(strict)
+			var v interface{}
+			err := d.Decode(&v)
+			return v, err
+		}
+
+		It("ends the document at a marker by default", func() {
+			v, err := decode("key: |\n  a\n---\n  b\n", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[interface{}]interface{}{"key": "a\n"}))
+		})
+
+		It("fails on a marker ending a block scalar", func() {
+			_, err := decode("key: |\n  a\n---\n  b\n", true)
+			Expect(err).To(MatchError("Found the document marker '---' ending the block scalar at line 0, column 5; indent it if it is a line of the scalar at line 2, column 0"))
+
+			_, err = decode("- >\n  a\n\n...\n", true)
+			Expect(err).To(MatchError(ContainSubstring("the document marker '...' ending the block scalar")))
+		})
+
+		It("accepts markers after other scalars or a comment", func() {
+			v, err := decode("key: |\n  a\n# end\n---\nb\n", true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[interface{}]interface{}{"key": "a\n"}))
+
+			v, err = decode("key: a\n---\nb\n", true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(map[interface{}]interface{}{"key": "a"}))
+		})
+	})
+
+	It("explains content read as directives", func() {
+		var v interface{}
+		err := Unmarshal([]byte("%done: true\n"), &v)
+		Expect(err).To(MatchError(ContainSubstring("quote a scalar starting with '%'")))
+
+		err = Unmarshal([]byte("%YAML 1.1\ndone: true\n"), &v)
+		Expect(err).To(MatchError("yaml: [while parsing the directives] did not find expected <document start>, which has to follow them at line 2, column 1"))
+	})
+})
@@ -0,0 +1,301 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MovePaths returns a Filter moving the mapping entries at the paths that
+// are the keys of rules to the paths they map to, such as "server.host"
+// to "network.host", for migrating documents from one schema to another.
+// The mappings on the way to a new path are created when missing, and an
+// entry already at the new path is replaced. An entry moved within its
+// mapping, which renames its key, keeps its place.
+//
+// In the paths of the entries to move, "*" stands for any key and "[*]"
+// for any index; in the new paths, they stand, in order, for the keys and
+// indices they matched. Unlike the other Filters, MovePaths holds a whole
+// document at a time, as the place an entry moves to can come before it.
+// The rules are applied one after the other, in the order of their paths.
+func MovePaths(rules map[string]string) Filter {
+	type rule struct {
+		from, to []pathSegment
+	}
+	froms := make([]string, 0, len(rules))
+	for from := range rules {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	var moves []rule
+	for _, from := range froms {
+		moves = append(moves, rule{parsePath(from), parsePath(rules[from])})
+	}
+
+	// the nodes of the document being read, and the collections open
+	var nodes []*eventTree
+	var open []*eventTree
+	return FilterFunc(func(ev Event, at EventPath, next func(Event) error) error {
+		switch ev.Kind {
+		case StreamStartEvent, StreamEndEvent, DocumentStartEvent:
+			return next(ev)
+		case DocumentEndEvent:
+			for _, n := range nodes {
+				for _, m := range moves {
+					if err := n.move(m.from, m.to); err != nil {
+						return err
+					}
+				}
+				if err := n.write(next); err != nil {
+					return err
+				}
+			}
+			nodes = nodes[:0]
+			return next(ev)
+		case SequenceEndEvent, MappingEndEvent:
+			if len(open) == 0 {
+				return next(ev)
+			}
+			open[len(open)-1].end = ev
+			open = open[:len(open)-1]
+			return nil
+		}
+
+		n := &eventTree{ev: ev}
+		if len(open) > 0 {
+			parent := open[len(open)-1]
+			parent.children = append(parent.children, n)
+		} else {
+			nodes = append(nodes, n)
+		}
+		if ev.Kind == SequenceStartEvent || ev.Kind == MappingStartEvent {
+			open = append(open, n)
+		}
+		return nil
+	})
+}
+
+// An eventTree is the events of a node: the event of a scalar or an alias,
+// or the start and end of a collection with the nodes within it, keys and
+// values alternating in mappings.
+type eventTree struct {
+	ev       Event
+	children []*eventTree
+	end      Event
+}
+
+func (t *eventTree) write(next func(Event) error) error {
+	if err := next(t.ev); err != nil {
+		return err
+	}
+	if t.ev.Kind != SequenceStartEvent && t.ev.Kind != MappingStartEvent {
+		return nil
+	}
+	for _, c := range t.children {
+		if err := c.write(next); err != nil {
+			return err
+		}
+	}
+	return next(t.end)
+}
+
+// entry returns the index of the value of key in the mapping t, or -1.
+func (t *eventTree) entry(key string) int {
+	for i := 0; i+1 < len(t.children); i += 2 {
+		if k := t.children[i]; k.ev.Kind == ScalarEvent && k.ev.Value == key {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// A pathSegment is a key, or an index when key is empty; "*" and an index
+// of -1 are wildcards.
+type pathSegment struct {
+	key   string
+	index int
+}
+
+func (s pathSegment) String() string {
+	switch {
+	case s.key != "":
+		return s.key
+	case s.index < 0:
+		return "[*]"
+	}
+	return "[" + strconv.Itoa(s.index) + "]"
+}
+
+// parsePath splits a path such as "servers[0].host" into its segments.
+func parsePath(path string) []pathSegment {
+	var segments []pathSegment
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key = part[:i]
+			part = part[i:]
+		} else {
+			part = ""
+		}
+		if key != "" {
+			segments = append(segments, pathSegment{key: key})
+		}
+		for strings.HasPrefix(part, "[") {
+			end := strings.IndexByte(part, ']')
+			if end < 0 {
+				break
+			}
+			index, err := strconv.Atoi(part[1:end])
+			if err != nil {
+				index = -1
+			}
+			segments = append(segments, pathSegment{index: index})
+			part = part[end+1:]
+		}
+	}
+	return segments
+}
+
+// A pathMatch is an entry found at a path: the mapping holding it, its key
+// and value, and the keys and indices matched by wildcards.
+type pathMatch struct {
+	mapping    *eventTree
+	key, value *eventTree
+	captured   []pathSegment
+}
+
+// find returns the entries at the path made of segments within t.
+func (t *eventTree) find(segments []pathSegment, captured []pathSegment, found []pathMatch) []pathMatch {
+	if len(segments) == 0 {
+		return found
+	}
+	s := segments[0]
+	switch {
+	case s.key != "" && t.ev.Kind == MappingStartEvent:
+		for i := 0; i+1 < len(t.children); i += 2 {
+			k := t.children[i]
+			if k.ev.Kind != ScalarEvent || s.key != "*" && k.ev.Value != s.key {
+				continue
+			}
+			c := captured
+			if s.key == "*" {
+				c = append(c[:len(c):len(c)], pathSegment{key: k.ev.Value})
+			}
+			if len(segments) == 1 {
+				found = append(found, pathMatch{t, k, t.children[i+1], c})
+			} else {
+				found = t.children[i+1].find(segments[1:], c, found)
+			}
+		}
+	case s.key == "" && t.ev.Kind == SequenceStartEvent:
+		for i, e := range t.children {
+			if s.index >= 0 && i != s.index {
+				continue
+			}
+			c := captured
+			if s.index < 0 {
+				c = append(c[:len(c):len(c)], pathSegment{index: i})
+			}
+			if len(segments) > 1 {
+				found = e.find(segments[1:], c, found)
+			}
+		}
+	}
+	return found
+}
+
+// move moves the entries at the path from to the path to.
+func (t *eventTree) move(from, to []pathSegment) error {
+	for _, m := range t.find(from, nil, nil) {
+		key, value := m.key, m.value
+		at := -1
+		for i := 0; i < len(m.mapping.children); i += 2 {
+			if m.mapping.children[i] == key {
+				at = i
+			}
+		}
+		if at < 0 {
+			// moved already, within an entry moved before
+			continue
+		}
+
+		path := make([]pathSegment, len(to))
+		captured := m.captured
+		for i, s := range to {
+			if (s.key == "*" || s.key == "" && s.index < 0) && len(captured) > 0 {
+				s, captured = captured[0], captured[1:]
+			}
+			path[i] = s
+		}
+
+		parent, err := t.makePath(path[:len(path)-1])
+		if err != nil {
+			return err
+		}
+		last := path[len(path)-1]
+		if last.key == "" || parent.ev.Kind != MappingStartEvent {
+			return fmt.Errorf("Cannot move %s to %s: it is not a mapping entry", key.ev.Value, joinSegments(path))
+		}
+
+		m.mapping.children = append(m.mapping.children[:at], m.mapping.children[at+2:]...)
+		key.ev.Value = last.key
+		if i := parent.entry(last.key); i >= 0 {
+			parent.children[i] = value
+		} else if parent == m.mapping {
+			parent.children = append(parent.children[:at], append([]*eventTree{key, value}, parent.children[at:]...)...)
+		} else {
+			parent.children = append(parent.children, key, value)
+		}
+	}
+	return nil
+}
+
+// makePath returns the node at the path made of segments within t,
+// creating the mappings missing on the way.
+func (t *eventTree) makePath(segments []pathSegment) (*eventTree, error) {
+	n := t
+	for i, s := range segments {
+		switch {
+		case s.key != "" && n.ev.Kind == MappingStartEvent:
+			if v := n.entry(s.key); v >= 0 {
+				n = n.children[v]
+				continue
+			}
+			m := &eventTree{ev: Event{Kind: MappingStartEvent}, end: Event{Kind: MappingEndEvent}}
+			n.children = append(n.children, &eventTree{ev: Event{Kind: ScalarEvent, Value: s.key}}, m)
+			n = m
+		case s.key == "" && n.ev.Kind == SequenceStartEvent && s.index >= 0 && s.index < len(n.children):
+			n = n.children[s.index]
+		default:
+			return nil, fmt.Errorf("Cannot move an entry to %s: there is no %s", joinSegments(segments), joinSegments(segments[:i+1]))
+		}
+	}
+	return n, nil
+}
+
+func joinSegments(segments []pathSegment) string {
+	path := ""
+	for _, s := range segments {
+		if s.key == "" {
+			path += s.String()
+		} else {
+			path = joinPath(path, s.key)
+		}
+	}
+	return path
+}
@@ -0,0 +1,670 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// A NodeKind identifies the type of a Node.
+type NodeKind int
+
+const (
+	ScalarNode NodeKind = iota + 1
+	SequenceNode
+	MappingNode
+	AliasNode
+
+	// ErrorNode stands for text that failed to parse, which a Decoder
+	// tolerating errors skipped. Its Value describes the error.
+	ErrorNode
+)
+
+var nodeKindNames = []string{
+	ScalarNode:   "scalar",
+	SequenceNode: "sequence",
+	MappingNode:  "mapping",
+	AliasNode:    "alias",
+	ErrorNode:    "error",
+}
+
+func (k NodeKind) String() string {
+	if k > 0 && int(k) < len(nodeKindNames) {
+		return nodeKindNames[k]
+	}
+	return fmt.Sprintf("node kind %d", int(k))
+}
+
+// A Node is the representation of a YAML value as it appears in a document.
+//
+// Decoding into a Node (or a struct field of type Node or *Node) keeps the
+// presentation details of the source, such as scalar styles and explicit
+// tags, and encoding a Node writes them back. Aliases are kept as AliasNodes
+// rather than being expanded. This allows documents to be rewritten without
+// reformatting the parts that were not changed.
+type Node struct {
+	Kind NodeKind
+
+	// Style is the style of a scalar node.
+	Style ScalarStyle
+
+	// Flow is true when a sequence or mapping node uses the flow style.
+	Flow bool
+
+	// Tag is the explicit tag of the node, empty when the tag was implied.
+	Tag string
+
+	// Value is the content of a scalar node, or the name of the anchor an
+	// alias node refers to.
+	Value string
+
+	// Verbatim is the text of a single-line scalar node in the source,
+	// quotes and escapes included, when the Decoder keeps it. Encode
+	// writes it as it is while it still reads as the Value and Style of
+	// the node.
+	Verbatim string
+
+	// Anchor is the anchor defined on the node, if any.
+	Anchor string
+
+	// Content holds the items of a sequence node, or the keys and values
+	// of a mapping node in alternating order.
+	Content []*Node
+
+	// Alias is the anchored node an alias node refers to.
+	Alias *Node
+
+	// Line and Column are the 1-based position of the node in the source,
+	// and EndLine and EndColumn that of the end of the node. Offset and
+	// EndOffset are the byte offsets of the start and end of the node.
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Offset    int
+	EndOffset int
+
+	// HeadComment, LineComment and FootComment are the comments on the
+	// lines before the node, at the end of its line and after it, which
+	// are written when the node is encoded. Each line of a comment starts
+	// with '#', which is added when it is missing, and an empty line
+	// stands for a blank line. Comments are decoded only by Format, and
+	// the emitter leaves out those inside flow collections.
+	HeadComment string
+	LineComment string
+	FootComment string
+
+	// TagDirectives are the %TAG directives in scope where the node was
+	// decoded, those of its document, which give the shorthand forms of
+	// the tags in it. Encode writes those of the root node of a document
+	// as its directives, so that its tags are written in shorthand again.
+	TagDirectives []TagDirective
+
+	// Text is the TextFormat of the source of a root node decoded by a
+	// Decoder with NormalizeText on. Encode writes the first document of
+	// a stream in it.
+	Text TextFormat
+
+	// Annotations hold the metadata tools attach to the node, such as the
+	// file or overlay it came from, which is never encoded. Copies of the
+	// node made by Inline and Substitute keep them.
+	Annotations map[string]interface{}
+}
+
+var nodeType = reflect.TypeOf(Node{})
+
+// A NodeUnmarshaler decodes itself from the Node of a value, which holds
+// its tag, style and position in the source, unlike the value given to an
+// Unmarshaler. Nulls are decoded as usual, without calling it.
+type NodeUnmarshaler interface {
+	UnmarshalYAMLNode(node *Node) error
+}
+
+var nodeUnmarshalerType = reflect.TypeOf(new(NodeUnmarshaler)).Elem()
+
+// nodeUnmarshaler returns the NodeUnmarshaler that v is or points to,
+// allocating a nil pointer to it, or nil if there is none or the current
+// event is a null.
+func (d *Decoder) nodeUnmarshaler(v reflect.Value) NodeUnmarshaler {
+	if u := d.implementation(v, nodeUnmarshalerType); u != nil {
+		return u.(NodeUnmarshaler)
+	}
+	return nil
+}
+
+// setEnd sets the end of n to that of the current event.
+func (d *Decoder) setEnd(n *Node) {
+	n.EndLine = d.event.end_mark.line + 1
+	n.EndColumn = d.event.end_mark.column + 1
+	n.EndOffset = d.event.end_mark.offset
+}
+
+// A Position is a 1-based location in the source of a document.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// An Anchor describes an anchor defined in the decoded input.
+type Anchor struct {
+	Name string
+
+	// Node is the anchored value. Its Line and Column give the position
+	// of the definition.
+	Node *Node
+
+	// References holds the positions of the aliases to the anchor.
+	References []Position
+}
+
+// Anchors returns the anchors defined in the last document decoded, keyed
+// by name, with the aliases of that document referring to them. When an
+// anchor is redefined, the latest definition is reported.
+func (d *Decoder) Anchors() map[string]Anchor {
+	anchors := make(map[string]Anchor, len(d.documentAnchors))
+	// the events of an anchor hold the values of its aliases in full, so
+	// that they decode without looking up other anchors
+	r := &Decoder{anchors: make(map[string][]yaml_event_t)}
+	for _, name := range d.documentAnchors {
+		if _, ok := anchors[name]; ok {
+			continue
+		}
+
+		// the document end stands in for the event following the value
+		events := d.anchors[name]
+		r.replay_events = append(events[:len(events):len(events)],
+			yaml_event_t{event_type: yaml_DOCUMENT_END_EVENT})
+		r.nextEvent()
+
+		n := r.node()
+		n.Anchor = name
+		anchors[name] = Anchor{
+			Name:       name,
+			Node:       n,
+			References: d.aliases[name],
+		}
+	}
+	return anchors
+}
+
+// An AnchorUsage describes where an anchor is defined and referenced.
+type AnchorUsage struct {
+	Name string
+
+	// Definition is the position of the anchored value.
+	Definition Position
+
+	// References holds the positions of the aliases to the anchor, in the
+	// order they appear.
+	References []Position
+}
+
+// Unused reports whether no alias refers to the anchor.
+func (u AnchorUsage) Unused() bool {
+	return len(u.References) == 0
+}
+
+// AnchorUsages scans the documents in src and returns every anchor they
+// define, in the order of the definitions, with the aliases referring to
+// it. An anchor that is defined again is reported once per definition,
+// each with the aliases that follow it up to the next one in its
+// document, as aliases never refer to the anchors of other documents.
+// Nothing is decoded, so the report is cheap enough for linting large
+// files.
+func AnchorUsages(src []byte) (usages []AnchorUsage, err error) {
+	defer recovery(&err)
+
+	d := NewDecoder(bytes.NewReader(src))
+	d.start()
+
+	// the index of the latest definition of each anchor in the document
+	defined := make(map[string]int)
+	for ; d.event.event_type != yaml_STREAM_END_EVENT; d.nextEvent() {
+		if d.event.event_type == yaml_DOCUMENT_START_EVENT {
+			// anchors are scoped to their document
+			defined = make(map[string]int)
+		}
+		if len(d.event.anchor) == 0 {
+			continue
+		}
+
+		name := string(d.event.anchor)
+		pos := Position{
+			Line:   d.event.start_mark.line + 1,
+			Column: d.event.start_mark.column + 1,
+		}
+		if d.event.event_type != yaml_ALIAS_EVENT {
+			defined[name] = len(usages)
+			usages = append(usages, AnchorUsage{Name: name, Definition: pos})
+			continue
+		}
+
+		i, ok := defined[name]
+		if !ok {
+			d.error(composingError(d.event.start_mark, "missing anchor: '%s'", name))
+		}
+		usages[i].References = append(usages[i].References, pos)
+	}
+	return usages, nil
+}
+
+// ParseFragment decodes src, a fragment of a larger document such as an
+// indented block selected in an editor, into a Node. at is where src
+// starts in the larger document: the first line of src is read as if it
+// started at the column of at, so that a fragment starting in the middle
+// of a line is indented as the lines after it, and the positions of the
+// nodes and of any error are those in the larger document. A zero Line
+// or Column of at is taken as 1.
+func ParseFragment(src []byte, at Mark) (*Node, error) {
+	d := NewDecoder(bytes.NewReader(src))
+	d.parser.mark = YAML_mark_t{index: at.Index, offset: at.Offset}
+	if at.Line > 0 {
+		d.parser.mark.line = at.Line - 1
+	}
+	if at.Column > 0 {
+		d.parser.mark.column = at.Column - 1
+	}
+
+	n := &Node{}
+	if err := d.Decode(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// nodeTarget returns the Node that v refers to, allocating any nil
+// pointers on the way, or nil when v cannot hold a Node.
+func nodeTarget(v reflect.Value) *Node {
+	for {
+		if v.Type() == nodeType {
+			if !v.CanAddr() {
+				return nil
+			}
+			return v.Addr().Interface().(*Node)
+		}
+
+		if v.Kind() != reflect.Ptr {
+			return nil
+		}
+
+		if v.Type().Elem() != nodeType && v.Type().Elem().Kind() != reflect.Ptr {
+			return nil
+		}
+
+		if v.IsNil() {
+			if !v.CanSet() {
+				return nil
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+}
+
+// node builds a Node from the events of the current value.
+func (d *Decoder) node() *Node {
+	if d.event.event_type == yaml_ALIAS_EVENT {
+		return d.aliasNode()
+	}
+	if d.nodeDepth == 0 && d.parser.keep_source {
+		d.trimSource()
+	}
+
+	n := d.newNode()
+	*n = Node{
+		Tag:           explicitTag(&d.event),
+		Anchor:        string(d.event.anchor),
+		Line:          d.event.start_mark.line + 1,
+		Column:        d.event.start_mark.column + 1,
+		Offset:        d.event.start_mark.offset,
+		TagDirectives: d.nextDocumentInfo.TagDirectives,
+	}
+
+	d.nodeComments(n)
+	d.nodeDepth++
+
+	anchor := n.Anchor
+	switch d.event.event_type {
+	case yaml_SCALAR_EVENT:
+		d.begin_anchor(anchor)
+		n.Kind = ScalarNode
+		n.Value = d.scalarValue()
+		n.Style = ScalarStyle(d.event.style)
+		if d.parser.keep_source {
+			n.Verbatim = d.verbatim()
+		}
+		d.setEnd(n)
+		d.nextEvent()
+	case yaml_SEQUENCE_START_EVENT:
+		d.begin_anchor(anchor)
+		n.Kind = SequenceNode
+		n.Flow = yaml_sequence_style_t(d.event.style) == yaml_FLOW_SEQUENCE_STYLE
+		d.enterFlow(n)
+		d.nextEvent()
+		start := len(d.nodeStack)
+		for d.errorNodes(n); d.event.event_type != yaml_SEQUENCE_END_EVENT; d.errorNodes(n) {
+			item := d.node()
+			d.nodeStack = append(d.nodeStack, item)
+		}
+		n.Content = d.takeContent(start)
+		d.endComments(n)
+		d.setEnd(n)
+		d.nextEvent()
+	case yaml_MAPPING_START_EVENT:
+		d.begin_anchor(anchor)
+		n.Kind = MappingNode
+		n.Flow = yaml_mapping_style_t(d.event.style) == yaml_FLOW_MAPPING_STYLE
+		d.enterFlow(n)
+		d.nextEvent()
+		start := len(d.nodeStack)
+		for d.errorNodes(n); d.event.event_type != yaml_MAPPING_END_EVENT; d.errorNodes(n) {
+			d.checkKey()
+			key := d.node()
+			value := d.node()
+			d.nodeStack = append(d.nodeStack, key, value)
+		}
+		n.Content = d.takeContent(start)
+		d.endComments(n)
+		d.setEnd(n)
+		d.nextEvent()
+	default:
+		d.error(&UnexpectedEventError{
+			Value:     string(d.event.value),
+			EventType: EventKind(d.event.event_type),
+			At:        markOf(d.event.start_mark),
+		})
+	}
+	d.end_anchor(anchor)
+	d.nodeDepth--
+	if d.nodeDepth == 0 {
+		d.documentComments(n)
+		if d.normalizeText {
+			n.Text = d.textFormat()
+		}
+	}
+
+	if anchor != "" {
+		if d.nodeAnchors == nil {
+			d.nodeAnchors = make(map[string]*Node)
+		}
+		d.nodeAnchors[anchor] = n
+	}
+
+	return n
+}
+
+// aliasNode returns an AliasNode for the current alias event. Aliases to
+// anchors that were not decoded as Nodes are expanded instead.
+func (d *Decoder) aliasNode() *Node {
+	name := string(d.event.anchor)
+	target, ok := d.nodeAnchors[name]
+	if !ok {
+		if _, defined := d.anchors[name]; !defined && d.tolerant {
+			return d.missingAnchor()
+		}
+		d.replayAlias()
+		return d.node()
+	}
+
+	n := d.newNode()
+	*n = Node{
+		Kind:   AliasNode,
+		Value:  name,
+		Alias:  target,
+		Line:   d.event.start_mark.line + 1,
+		Column: d.event.start_mark.column + 1,
+		Offset: d.event.start_mark.offset,
+	}
+	d.nodeComments(n)
+	d.setEnd(n)
+	d.recordAlias()
+
+	// enclosing anchors still record the aliased value in full
+	for i, e := range d.tracking_anchors {
+		d.tracking_anchors[i] = append(e, d.anchors[name]...)
+	}
+
+	d.nextEvent()
+	return n
+}
+
+// nodeDirectives returns the tag directives of v when it is a Node or a
+// pointer to one.
+func nodeDirectives(v interface{}) []yaml_tag_directive_t {
+	var n *Node
+	switch v := v.(type) {
+	case Node:
+		n = &v
+	case *Node:
+		n = v
+	}
+	if n == nil {
+		return nil
+	}
+	_, tags := documentDirectives(DocumentInfo{TagDirectives: n.TagDirectives})
+	return tags
+}
+
+// emitNode writes the events describing n.
+func (e *Encoder) emitNode(n *Node) {
+	tag := []byte(n.Tag)
+	implicit := n.Tag == ""
+	style, flow := n.Style, n.Flow
+	if e.deterministic {
+		style, flow = normalStyle(n), false
+	}
+
+	switch n.Kind {
+	case ScalarNode:
+		value := e.nodeValue(n)
+		if n.Verbatim != "" && !e.deterministic && readsAs(n.Verbatim, value, n.Style) {
+			value, style = n.Verbatim, ScalarStyle(yaml_RAW_SCALAR_STYLE)
+		} else {
+			style = ScalarStyle(e.quotedMarkers(value, yaml_scalar_style_t(style)))
+		}
+		yaml_scalar_event_initialize(&e.event, []byte(n.Anchor), tag, []byte(value),
+			implicit, implicit && !emptyNull(style, value), yaml_scalar_style_t(style))
+		e.emitComments(n, true, true, true)
+	case SequenceNode:
+		style := yaml_BLOCK_SEQUENCE_STYLE
+		if flow {
+			style = yaml_FLOW_SEQUENCE_STYLE
+		}
+		yaml_sequence_start_event_initialize(&e.event, []byte(n.Anchor), tag, implicit, style)
+		e.emitComments(n, true, false, !flow)
+		for _, c := range n.Content {
+			e.emitNode(c)
+		}
+		yaml_sequence_end_event_initialize(&e.event)
+		e.emitComments(n, false, true, flow)
+	case MappingNode:
+		style := yaml_BLOCK_MAPPING_STYLE
+		if flow {
+			style = yaml_FLOW_MAPPING_STYLE
+		}
+		yaml_mapping_start_event_initialize(&e.event, []byte(n.Anchor), tag, implicit, style)
+		e.emitComments(n, true, false, !flow)
+		for _, c := range n.Content {
+			e.emitNode(c)
+		}
+		yaml_mapping_end_event_initialize(&e.event)
+		e.emitComments(n, false, true, flow)
+	case AliasNode:
+		name := n.Value
+		if name == "" && n.Alias != nil {
+			name = n.Alias.Anchor
+		}
+		yaml_alias_event_initialize(&e.event, []byte(name))
+		e.emitComments(n, true, true, true)
+	default:
+		e.emitNil()
+	}
+}
+
+// emptyNull reports whether a scalar without a tag is an empty plain one,
+// which is a null that cannot be quoted.
+func emptyNull(style ScalarStyle, value string) bool {
+	return value == "" && (style == AnyStyle || style == PlainStyle)
+}
+
+// nodeValue returns the value of a scalar node, with timestamps formatted
+// following TimeFormat when it is set.
+func (e *Encoder) nodeValue(n *Node) string {
+	if e.timeLayout == "" && !e.timeUTC {
+		return n.Value
+	}
+	if n.Style != AnyStyle && n.Style != PlainStyle || n.Tag != "" && longTag(n.Tag) != yaml_TIMESTAMP_TAG {
+		return n.Value
+	}
+	if _, v, err := Resolve(n.Tag, n.Value); err == nil {
+		if t, ok := v.(time.Time); ok {
+			return e.formatTime(t)
+		}
+	}
+	return n.Value
+}
+
+// normalStyle returns the style a deterministic encoder writes a scalar
+// node in: quoted only when the value would not resolve to a string
+// otherwise.
+func normalStyle(n *Node) ScalarStyle {
+	if n.Tag != "" || n.Style == AnyStyle || n.Style == PlainStyle {
+		return AnyStyle
+	}
+
+	event := yaml_event_t{implicit: true, value: []byte(n.Value)}
+	if tag, _ := resolveInterface(event, false); tag != yaml_STR_TAG {
+		return DoubleQuotedStyle
+	}
+	return AnyStyle
+}
+
+// nodeStream returns the events of a stream holding the document n, as the
+// parser would read them, with the positions of the nodes, so that n can
+// be decoded. The anchors of the stream are named after the nodes aliases
+// refer to, whatever their anchors are named, and a node an alias refers
+// to is written in place of the alias when it is not in the tree before.
+func nodeStream(n *Node) []yaml_event_t {
+	s := &nodeEvents{names: make(map[*Node]string)}
+	s.events = append(s.events,
+		yaml_event_t{event_type: yaml_STREAM_START_EVENT, encoding: yaml_UTF8_ENCODING},
+		yaml_event_t{event_type: yaml_DOCUMENT_START_EVENT, implicit: true})
+	s.targets(n, make(map[*Node]bool))
+	s.node(n)
+	s.events = append(s.events,
+		yaml_event_t{event_type: yaml_DOCUMENT_END_EVENT, implicit: true},
+		yaml_event_t{event_type: yaml_STREAM_END_EVENT})
+	return s.events
+}
+
+// nodeEvents builds the events of a tree of Nodes. names holds the anchors
+// of the nodes aliases refer to, which are empty until they are written.
+type nodeEvents struct {
+	events []yaml_event_t
+	names  map[*Node]string
+}
+
+func (s *nodeEvents) targets(n *Node, seen map[*Node]bool) {
+	if n == nil || seen[n] {
+		return
+	}
+	seen[n] = true
+	if n.Kind == AliasNode && n.Alias != nil {
+		if _, ok := s.names[n.Alias]; !ok {
+			s.names[n.Alias] = ""
+		}
+		s.targets(n.Alias, seen)
+	}
+	for _, c := range n.Content {
+		s.targets(c, seen)
+	}
+}
+
+func (s *nodeEvents) node(n *Node) {
+	event := yaml_event_t{start_mark: nodeMark(n.Line, n.Column, n.Offset),
+		end_mark: nodeMark(n.EndLine, n.EndColumn, n.EndOffset)}
+
+	if n.Kind == AliasNode {
+		name, ok := s.names[n.Alias]
+		switch {
+		case n.Alias == nil:
+			name = n.Value
+		case !ok || name == "":
+			s.node(n.Alias)
+			return
+		}
+		event.event_type = yaml_ALIAS_EVENT
+		event.anchor = []byte(name)
+		s.events = append(s.events, event)
+		return
+	}
+
+	if name, ok := s.names[n]; ok {
+		if name == "" {
+			name = strconv.Itoa(len(s.events))
+			s.names[n] = name
+		}
+		event.anchor = []byte(name)
+	}
+	if n.Tag != "" {
+		event.tag = []byte(longTag(n.Tag))
+	}
+
+	switch n.Kind {
+	case SequenceNode, MappingNode:
+		event.event_type = yaml_SEQUENCE_START_EVENT
+		end := yaml_SEQUENCE_END_EVENT
+		event.style = yaml_style_t(yaml_BLOCK_SEQUENCE_STYLE)
+		if n.Flow {
+			event.style = yaml_style_t(yaml_FLOW_SEQUENCE_STYLE)
+		}
+		if n.Kind == MappingNode {
+			event.event_type, end = yaml_MAPPING_START_EVENT, yaml_MAPPING_END_EVENT
+			event.style = yaml_style_t(yaml_BLOCK_MAPPING_STYLE)
+			if n.Flow {
+				event.style = yaml_style_t(yaml_FLOW_MAPPING_STYLE)
+			}
+		}
+		event.implicit = n.Tag == ""
+		s.events = append(s.events, event)
+		for _, c := range n.Content {
+			s.node(c)
+		}
+		s.events = append(s.events, yaml_event_t{event_type: end, start_mark: event.end_mark, end_mark: event.end_mark})
+	default:
+		plain := n.Style == AnyStyle || n.Style == PlainStyle
+		event.event_type = yaml_SCALAR_EVENT
+		event.value = []byte(n.Value)
+		event.implicit = n.Tag == "" && plain
+		event.quoted_implicit = n.Tag == "" && !plain
+		event.style = yaml_style_t(n.Style)
+		if plain {
+			event.style = yaml_style_t(yaml_PLAIN_SCALAR_STYLE)
+		}
+		s.events = append(s.events, event)
+	}
+}
+
+// nodeMark returns the mark of the 1-based line and column of a Node.
+func nodeMark(line, column, offset int) YAML_mark_t {
+	if line == 0 {
+		return YAML_mark_t{}
+	}
+	return YAML_mark_t{index: offset, offset: offset, line: line - 1, column: column - 1}
+}
@@ -0,0 +1,297 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// portRange decodes "low-high" scalars, reporting where an invalid one is.
+type portRange struct {
+	Low, High int
+}
+
+func (r *portRange) UnmarshalYAMLNode(n *Node) error {
+	if _, err := fmt.Sscanf(n.Value, "%d-%d", &r.Low, &r.High); err != nil || n.Kind != ScalarNode {
+		return fmt.Errorf("invalid port range %q from line %d, column %d to line %d, column %d",
+			n.Value, n.Line, n.Column, n.EndLine, n.EndColumn)
+	}
+	return nil
+}
+
+// ports marshals as a flow sequence with a line comment.
+type ports []int
+
+func (p ports) MarshalYAML() (string, interface{}, error) {
+	n := &Node{Kind: SequenceNode, Flow: true, LineComment: "# open"}
+	for _, port := range p {
+		n.Content = append(n.Content, &Node{Kind: ScalarNode, Value: fmt.Sprint(port), Style: DoubleQuotedStyle})
+	}
+	return "!ports", n, nil
+}
+
+var _ = Describe("Node", func() {
+	roundTrip := func(doc string) string {
+		var n Node
+		err := NewDecoder(strings.NewReader(doc)).Decode(&n)
+		Expect(err).NotTo(HaveOccurred())
+
+		buf := &bytes.Buffer{}
+		err = NewEncoder(buf).Encode(&n)
+		Expect(err).NotTo(HaveOccurred())
+		return buf.String()
+	}
+
+	It("decodes the structure of a document", func() {
+		var n Node
+		err := NewDecoder(strings.NewReader(`a: [1, 'two']
+`)).Decode(&n)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(n.Kind).To(Equal(MappingNode))
+		Expect(n.Content).To(HaveLen(2))
+		Expect(n.Content[0].Value).To(Equal("a"))
+		Expect(n.Content[0].Line).To(Equal(1))
+		Expect(n.Content[0].Column).To(Equal(1))
+
+		seq := n.Content[1]
+		Expect(seq.Kind).To(Equal(SequenceNode))
+		Expect(seq.Flow).To(BeTrue())
+		Expect(seq.Content[0].Style).To(Equal(PlainStyle))
+		Expect(seq.Content[1].Value).To(Equal("two"))
+		Expect(seq.Content[1].Style).To(Equal(SingleQuotedStyle))
+		Expect(seq.Content[1].Column).To(Equal(8))
+	})
+
+	It("preserves scalar styles", func() {
+		doc := `plain: value
+single: 'quoted'
+double: "quoted"
+literal: |
+  line one
+  line two
+folded: >
+  folded text
+list:
+- a
+- 'b'
+flow: [x, "y"]
+`
+		Expect(roundTrip(doc)).To(Equal(doc))
+	})
+
+	It("preserves the base of integers", func() {
+		doc := `mode: 0o755
+mask: 0xFF
+legacy: 0644
+`
+		Expect(roundTrip(doc)).To(Equal(doc))
+	})
+
+	It("preserves CRLF line breaks", func() {
+		doc := "a: 1\r\nb: |\r\n  text\r\n"
+		d := NewDecoder(strings.NewReader(doc))
+		var n Node
+		Expect(d.Decode(&n)).To(Succeed())
+		Expect(d.LineBreak()).To(Equal(CRLFBreak))
+		Expect(n.Content[3].Value).To(Equal("text\n"))
+
+		buf := &bytes.Buffer{}
+		enc := NewEncoder(buf)
+		enc.SetLineBreak(d.LineBreak())
+		Expect(enc.Encode(&n)).To(Succeed())
+		Expect(buf.String()).To(Equal(doc))
+	})
+
+	It("keeps aliases unexpanded", func() {
+		doc := `base: &base
+  a: 1
+  b: [x, y]
+derived:
+  <<: *base
+  c: 2
+list:
+- *base
+`
+		var n Node
+		Expect(Unmarshal([]byte(doc), &n)).To(Succeed())
+
+		base := n.Content[1]
+		alias := n.Content[3].Content[1]
+		Expect(alias.Kind).To(Equal(AliasNode))
+		Expect(alias.Value).To(Equal("base"))
+		Expect(alias.Alias).To(BeIdenticalTo(base))
+		Expect(alias.Line).To(Equal(5))
+		Expect(alias.Column).To(Equal(7))
+		Expect(n.Content[5].Content[0].Alias).To(BeIdenticalTo(base))
+
+		Expect(roundTrip(doc)).To(Equal(doc))
+	})
+
+	It("emits an alias to its target's anchor", func() {
+		target := &Node{Kind: ScalarNode, Value: "v", Anchor: "a"}
+		n := Node{Kind: SequenceNode, Content: []*Node{
+			target,
+			{Kind: AliasNode, Alias: target},
+		}}
+
+		out, err := Marshal(&n)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("- &a v\n- *a\n"))
+	})
+
+	It("preserves explicit tags and anchors", func() {
+		doc := `a: &x !!str 123
+b: !custom
+  c: d
+`
+		Expect(roundTrip(doc)).To(Equal(doc))
+	})
+
+	It("decodes into struct fields", func() {
+		var v struct {
+			Name  string
+			Extra *Node
+		}
+		err := NewDecoder(strings.NewReader(`name: x
+extra: {a: b}
+`)).Decode(&v)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v.Name).To(Equal("x"))
+		Expect(v.Extra.Kind).To(Equal(MappingNode))
+		Expect(v.Extra.Content[1].Value).To(Equal("b"))
+	})
+
+	It("writes the comments of built nodes", func() {
+		n := Node{Kind: MappingNode, HeadComment: "Generated", Content: []*Node{
+			{Kind: ScalarNode, Value: "port", HeadComment: "The port to listen on\n\n# TCP only"},
+			{Kind: ScalarNode, Value: "8080", LineComment: "# default"},
+			{Kind: ScalarNode, Value: "hosts"},
+			{Kind: SequenceNode, FootComment: "more to come", Content: []*Node{
+				{Kind: ScalarNode, Value: "a"},
+			}},
+		}}
+
+		out, err := Marshal(&n)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`# Generated
+# The port to listen on
+
+# TCP only
+port: 8080 # default
+hosts:
+- a
+# more to come
+`))
+	})
+
+	It("writes the comments of tagged struct fields", func() {
+		type config struct {
+			Port  int `yaml:"port" comment:"The port to listen on"`
+			Inner struct {
+				Host string `yaml:"host" comment:"Host name\nor address"`
+			} `yaml:"inner"`
+		}
+
+		out, err := Marshal(config{Port: 80})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`# The port to listen on
+port: 80
+inner:
+  # Host name
+  # or address
+  host: ""
+`))
+	})
+
+	Context("NodeUnmarshaler", func() {
+		It("decodes values from their nodes", func() {
+			var v struct {
+				Ports []portRange
+				Spare *portRange
+			}
+			Expect(Unmarshal([]byte("ports: [80-81, 8000-8080]\nspare: ~\n"), &v)).To(Succeed())
+			Expect(v.Ports).To(Equal([]portRange{{80, 81}, {8000, 8080}}))
+			Expect(v.Spare).To(BeNil())
+		})
+
+		It("gives the position of the node", func() {
+			var v map[string]portRange
+			err := Unmarshal([]byte("web: 80-81\nbad: \"8000\"\n"), &v)
+			Expect(err).To(MatchError(`bad: invalid port range "8000" from line 2, column 6 to line 2, column 12`))
+		})
+	})
+
+	It("records the end of nodes", func() {
+		var n Node
+		Expect(Unmarshal([]byte("a: [1, 22]\nb: |\n  x\n"), &n)).To(Succeed())
+		Expect([]int{n.Content[1].EndLine, n.Content[1].EndColumn}).To(Equal([]int{1, 11}))
+		Expect([]int{n.Content[3].EndLine, n.Content[3].EndColumn}).To(Equal([]int{4, 1}))
+	})
+
+	It("encodes the nodes returned by a Marshaler", func() {
+		out, err := Marshal(map[string]interface{}{"web": ports{80, 443}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("web: !ports [\"80\", \"443\"] # open\n"))
+	})
+
+	It("records the tag directives in scope", func() {
+		input := "%TAG !e! tag:example.com,2000:\n--- !e!config\nport: !e!port 80\n"
+		d := NewDecoder(strings.NewReader(input + "--- !e!config\n"))
+		var n, next Node
+		Expect(d.Decode(&n)).To(Succeed())
+		Expect(n.Tag).To(Equal("tag:example.com,2000:config"))
+		Expect(n.Content[1].TagDirectives).To(Equal([]TagDirective{{"!e!", "tag:example.com,2000:"}}))
+
+		out, err := Marshal(n)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(input))
+
+		// the directives end with their document
+		err = d.Decode(&next)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("ParseFragment", func() {
+		It("reads a fragment starting in the middle of a line", func() {
+			n, err := ParseFragment([]byte("a: 1\n    b: [2, 3]\n"), Mark{Index: 40, Offset: 40, Line: 3, Column: 5})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.Content).To(HaveLen(4))
+			Expect([]int{n.Line, n.Column, n.Offset}).To(Equal([]int{3, 5, 40}))
+			Expect([]int{n.Content[3].Line, n.Content[3].Column, n.Content[3].Offset}).To(Equal([]int{4, 8, 52}))
+		})
+
+		It("reads an indented block", func() {
+			n, err := ParseFragment([]byte("    - x\n    - y\n"), Mark{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.Kind).To(Equal(SequenceNode))
+			Expect(n.Column).To(Equal(5))
+		})
+
+		It("reports errors at their positions in the larger document", func() {
+			_, err := ParseFragment([]byte("a: 1\n    b: [2\n"), Mark{Line: 3, Column: 5})
+			Expect(err).To(BeAssignableToTypeOf(&ParserError{}))
+			Expect(err.(*ParserError).ProblemMark.Line).To(Equal(5))
+
+			_, err = ParseFragment([]byte("a: 1\n  b: 2\n"), Mark{Line: 3, Column: 5})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,93 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "fmt"
+
+// Anchors returns the nodes of the tree n roots that define anchors, by
+// name. When an anchor is defined more than once, the last definition is
+// returned, which is the one the aliases after it refer to.
+func (n *Node) Anchors() map[string]*Node {
+	anchors := make(map[string]*Node)
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		if n == nil {
+			return
+		}
+		if n.Anchor != "" && n.Kind != AliasNode {
+			anchors[n.Anchor] = n
+		}
+		for _, c := range n.Content {
+			visit(c)
+		}
+	}
+	visit(n)
+	return anchors
+}
+
+// Retarget makes the alias node n refer to target, which has to define an
+// anchor. An alias has to come after the node it refers to in its
+// document, and after no other definition of the same anchor, for the
+// document encoded to read back as the tree.
+func (n *Node) Retarget(target *Node) error {
+	if n.Kind != AliasNode {
+		return fmt.Errorf("Cannot retarget a node that is not an alias at line %d, column %d", n.Line, n.Column)
+	}
+	if target == nil || target.Anchor == "" || target.Kind == AliasNode {
+		return fmt.Errorf("Cannot retarget the alias at line %d, column %d to a node without an anchor", n.Line, n.Column)
+	}
+	n.Value = target.Anchor
+	n.Alias = target
+	return nil
+}
+
+// Inline replaces the alias node n with a copy of the node it refers to,
+// keeping the position, comments and annotations of the alias, which are
+// set on top of those of the node copied. The copy defines no
+// anchors, so that the aliases after it still refer to the nodes they did,
+// and the aliases in it refer to the same nodes as those it copies.
+func (n *Node) Inline() error {
+	if n.Kind != AliasNode {
+		return fmt.Errorf("Cannot inline a node that is not an alias at line %d, column %d", n.Line, n.Column)
+	}
+	if n.Alias == nil {
+		return fmt.Errorf("Cannot inline the alias '%s' at line %d, column %d, whose anchor is unknown", n.Value, n.Line, n.Column)
+	}
+
+	alias := *n
+	*n = *copyNode(n.Alias)
+	n.Line, n.Column = alias.Line, alias.Column
+	n.EndLine, n.EndColumn = alias.EndLine, alias.EndColumn
+	n.Offset, n.EndOffset = alias.Offset, alias.EndOffset
+	n.HeadComment, n.LineComment, n.FootComment = alias.HeadComment, alias.LineComment, alias.FootComment
+	n.Annotations = mergeAnnotations(n.Annotations, alias.Annotations)
+	return nil
+}
+
+// copyNode returns a deep copy of n without its anchors.
+func copyNode(n *Node) *Node {
+	c := *n
+	if c.Kind != AliasNode {
+		c.Anchor = ""
+	}
+	c.Annotations = mergeAnnotations(n.Annotations, nil)
+	if n.Content != nil {
+		c.Content = make([]*Node, len(n.Content))
+		for i, item := range n.Content {
+			c.Content[i] = copyNode(item)
+		}
+	}
+	return &c
+}
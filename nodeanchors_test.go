@@ -0,0 +1,83 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Node anchors", func() {
+	var root Node
+
+	BeforeEach(func() {
+		root = Node{}
+		src := "a: &a {x: &x 1}\nb: &b [2]\nc: *a\nd: [*x, *b]\n"
+		Expect(Unmarshal([]byte(src), &root)).To(Succeed())
+	})
+
+	encode := func() string {
+		out, err := Marshal(&root)
+		Expect(err).NotTo(HaveOccurred())
+		return string(out)
+	}
+
+	It("lists the anchors of a document", func() {
+		anchors := root.Anchors()
+		Expect(anchors).To(HaveLen(3))
+		Expect(anchors["a"]).To(BeIdenticalTo(root.Content[1]))
+		Expect(anchors["x"]).To(BeIdenticalTo(root.Content[1].Content[1]))
+		Expect(anchors["b"]).To(BeIdenticalTo(root.Content[3]))
+	})
+
+	It("returns the last definition of an anchor", func() {
+		var n Node
+		Expect(Unmarshal([]byte("[&a 1, &a 2, *a]"), &n)).To(Succeed())
+		Expect(n.Anchors()["a"]).To(BeIdenticalTo(n.Content[1]))
+	})
+
+	It("retargets aliases", func() {
+		c := root.Content[5]
+		Expect(c.Retarget(root.Anchors()["b"])).To(Succeed())
+		Expect(c.Alias).To(BeIdenticalTo(root.Content[3]))
+		Expect(encode()).To(Equal("a: &a {x: &x 1}\nb: &b [2]\nc: *b\nd: [*x, *b]\n"))
+	})
+
+	It("retargets only aliases, to anchored nodes", func() {
+		Expect(root.Content[1].Retarget(root.Content[3])).To(MatchError(
+			"Cannot retarget a node that is not an alias at line 1, column 4"))
+		Expect(root.Content[5].Retarget(root.Content[4])).To(MatchError(
+			"Cannot retarget the alias at line 3, column 4 to a node without an anchor"))
+	})
+
+	It("inlines aliases", func() {
+		d := root.Content[7]
+		Expect(root.Content[5].Inline()).To(Succeed())
+		Expect(d.Content[1].Inline()).To(Succeed())
+		Expect(encode()).To(Equal("a: &a {x: &x 1}\nb: &b [2]\nc: {x: 1}\nd: [*x, [2]]\n"))
+
+		root.Content[5].Content[1].Value = "3"
+		Expect(root.Content[1].Content[1].Value).To(Equal("1"))
+		Expect(root.Content[5].Line).To(Equal(3))
+	})
+
+	It("keeps the aliases inside the node inlined", func() {
+		var n Node
+		Expect(Unmarshal([]byte("a: &a 1\nb: &b [*a]\nc: *b\n"), &n)).To(Succeed())
+		Expect(n.Content[5].Inline()).To(Succeed())
+		Expect(n.Content[5].Content[0].Alias).To(BeIdenticalTo(n.Content[1]))
+		Expect(n.Content[1].Inline()).To(MatchError("Cannot inline a node that is not an alias at line 1, column 4"))
+	})
+})
@@ -0,0 +1,78 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+// DefaultNulls are the plain scalars that are nulls by default, "" standing
+// for an empty value.
+var DefaultNulls = []string{"~", "null", "Null", "NULL", ""}
+
+// Nulls sets the plain scalars without an explicit tag that the Decoder
+// reads as nulls, in place of DefaultNulls, "" standing for an empty value.
+// The scalars of DefaultNulls left out are strings, so that
+//
+//	d.Nulls("null", "Null", "NULL", "", "nil")
+//
+// reads ~ as a string and nil as a null. The set applies after the
+// ImplicitRules of the Decoder and before its Schema; under Failsafe there
+// are no nulls.
+func (d *Decoder) Nulls(values ...string) {
+	d.nulls = make(map[string]bool, len(values))
+	for _, v := range values {
+		d.nulls[v] = true
+	}
+}
+
+// Nulls sets the plain scalars that the documents written are read as
+// nulls, which the Encoder quotes the strings equal to. Nil values are
+// written as the null selected by NullValue when it is in the set, or else
+// as the first of them, or as an empty value tagged !!null when there are
+// none.
+func (e *Encoder) Nulls(values ...string) {
+	e.nulls = append([]string{}, values...)
+	if !e.isNull(e.nullValue) {
+		e.nullValue = ""
+		if len(values) > 0 {
+			e.nullValue = values[0]
+		}
+	}
+}
+
+// isNull reports whether the documents written read the plain scalar s as
+// a null, by the set given to Nulls or else by DefaultNulls.
+func (e *Encoder) isNull(s string) bool {
+	nulls := e.nulls
+	if nulls == nil {
+		nulls = DefaultNulls
+	}
+	for _, v := range nulls {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// nullTag returns the tag a plain scalar resolves to by the null set of
+// the Decoder: NullTag for the scalars in the set, and StrTag for the other
+// nulls of DefaultNulls.
+func (d *Decoder) nullTag(value string) (string, bool) {
+	if d.nulls[value] {
+		return yaml_NULL_TAG, true
+	}
+	if value == "" || null_values[value] {
+		return yaml_STR_TAG, true
+	}
+	return "", false
+}
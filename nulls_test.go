@@ -0,0 +1,151 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Null sets", func() {
+	type config struct {
+		Name  *string `yaml:"name"`
+		Owner *string `yaml:"owner"`
+		Port  int     `yaml:"port"`
+	}
+
+	It("reads the nulls of the set", func() {
+		d := NewDecoder(strings.NewReader("[~, null, nil, NIL, '', \"nil\"]"))
+		d.Nulls("null", "nil")
+
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(v).To(Equal([]interface{}{"~", nil, nil, "NIL", "", "nil"}))
+	})
+
+	It("binds them to typed values", func() {
+		d := NewDecoder(strings.NewReader("name: ~\nowner: nil\nport: nil\n"))
+		d.Nulls("nil")
+
+		var c config
+		Expect(d.Decode(&c)).To(Succeed())
+		Expect(*c.Name).To(Equal("~"))
+		Expect(c.Owner).To(BeNil())
+		Expect(c.Port).To(Equal(0))
+	})
+
+	It("can leave out empty values", func() {
+		d := NewDecoder(strings.NewReader("a:\nb: null\n"))
+		d.Nulls("null")
+
+		var v map[string]interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(v).To(Equal(map[string]interface{}{"a": "", "b": nil}))
+	})
+
+	It("keeps the default nulls by default", func() {
+		var v []interface{}
+		Expect(Unmarshal([]byte("[~, null, Null, NULL, nil]"), &v)).To(Succeed())
+		Expect(v).To(Equal([]interface{}{nil, nil, nil, nil, "nil"}))
+	})
+
+	Context("when encoding", func() {
+		var buf *bytes.Buffer
+		var enc *Encoder
+
+		BeforeEach(func() {
+			buf = &bytes.Buffer{}
+			enc = NewEncoder(buf)
+		})
+
+		It("quotes the strings of the set only", func() {
+			enc.Nulls("null", "nil")
+			Expect(enc.Encode([]interface{}{"~", "Null", "nil", nil})).To(Succeed())
+			Expect(buf.String()).To(Equal(`- ~
+- Null
+- "nil"
+- null
+`))
+		})
+
+		It("writes nils as the first of the set", func() {
+			enc.Nulls("nil", "~")
+			Expect(enc.Encode([]interface{}{nil})).To(Succeed())
+			Expect(buf.String()).To(Equal("- nil\n"))
+		})
+
+		It("selects another null of the set", func() {
+			enc.Nulls("nil", "null")
+			enc.NullValue("null")
+			Expect(enc.Encode([]interface{}{nil})).To(Succeed())
+			Expect(buf.String()).To(Equal("- null\n"))
+		})
+
+		It("tags nils when there are no nulls", func() {
+			enc.Nulls()
+			Expect(enc.Encode(map[string]interface{}{"a": nil, "b": "null"})).To(Succeed())
+			Expect(buf.String()).To(Equal("a: !!null\nb: null\n"))
+
+			d := NewDecoder(strings.NewReader(buf.String()))
+			d.Nulls()
+			var v map[string]interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(v).To(Equal(map[string]interface{}{"a": nil, "b": "null"}))
+		})
+
+		It("quotes and writes every default null", func() {
+			enc.NullValue("NULL")
+			in := []interface{}{"NULL", "Null", "null", "~", "", nil}
+			Expect(enc.Encode(in)).To(Succeed())
+			Expect(buf.String()).To(Equal("- \"NULL\"\n- \"Null\"\n- \"null\"\n- \"~\"\n- \"\"\n- NULL\n"))
+
+			for _, null := range DefaultNulls {
+				var v interface{} = "not null"
+				Expect(Unmarshal([]byte("a: "+null), &v)).To(Succeed())
+				Expect(v).To(Equal(map[interface{}]interface{}{"a": nil}))
+			}
+		})
+
+		It("writes the nulls of field tags by the set", func() {
+			type config struct {
+				A *int `yaml:"a,null=NULL"`
+				B *int `yaml:"b,null=nil"`
+			}
+			Expect(enc.Encode(config{})).To(Succeed())
+			Expect(buf.String()).To(Equal("a: NULL\nb: null\n"))
+
+			buf.Reset()
+			enc = NewEncoder(buf)
+			enc.Nulls("nil")
+			Expect(enc.Encode(config{})).To(Succeed())
+			Expect(buf.String()).To(Equal("a: nil\nb: nil\n"))
+		})
+
+		It("round-trips with the same set", func() {
+			enc.Nulls("nil")
+			in := map[string]interface{}{"a": nil, "b": "nil", "c": "~"}
+			Expect(enc.Encode(in)).To(Succeed())
+
+			d := NewDecoder(strings.NewReader(buf.String()))
+			d.Nulls("nil")
+			var v map[string]interface{}
+			Expect(d.Decode(&v)).To(Succeed())
+			Expect(v).To(Equal(in))
+		})
+	})
+})
@@ -0,0 +1,132 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+// An Option configures the Decoder or the Encoder made by NewDecoder or
+// NewEncoder. Options can be shared by both: one that only applies to
+// decoding or to encoding is ignored by the other. Each option does what
+// the setter method of the same name does, and options apply in order,
+// so a later one overrides an earlier one.
+type Option struct {
+	decoder func(d *Decoder)
+	encoder func(e *Encoder)
+}
+
+// DecoderOption returns an Option calling f on the Decoder, for the
+// settings that have no option of their own.
+func DecoderOption(f func(d *Decoder)) Option {
+	return Option{decoder: f}
+}
+
+// EncoderOption returns an Option calling f on the Encoder, for the
+// settings that have no option of their own.
+func EncoderOption(f func(e *Encoder)) Option {
+	return Option{encoder: f}
+}
+
+// Options combines opts into one Option, so that a configuration can be
+// passed around as a single value.
+func Options(opts ...Option) Option {
+	return Option{
+		decoder: func(d *Decoder) {
+			for _, opt := range opts {
+				if opt.decoder != nil {
+					opt.decoder(d)
+				}
+			}
+		},
+		encoder: func(e *Encoder) {
+			for _, opt := range opts {
+				if opt.encoder != nil {
+					opt.encoder(e)
+				}
+			}
+		},
+	}
+}
+
+// WithStrict sets the strict mode of a Decoder, as StrictMode does.
+func WithStrict(strict bool) Option {
+	return DecoderOption(func(d *Decoder) { d.StrictMode(strict) })
+}
+
+// WithLimits sets the limits on the input of a Decoder, as Limits does.
+func WithLimits(limits Limits) Option {
+	return DecoderOption(func(d *Decoder) { d.Limits(limits) })
+}
+
+// WithUnknownTags sets how a Decoder handles unknown tags, as UnknownTags
+// does.
+func WithUnknownTags(policy UnknownTagPolicy) Option {
+	return DecoderOption(func(d *Decoder) { d.UnknownTags(policy) })
+}
+
+// WithSchema sets the schema that plain scalars are resolved by when
+// decoding, and that strings are quoted by when encoding, as Schema does.
+func WithSchema(schema Schema) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.Schema(schema) },
+		encoder: func(e *Encoder) { e.Schema(schema) },
+	}
+}
+
+// WithImplicitRules sets the rules that plain scalars are resolved by when
+// decoding, and that strings are quoted by when encoding, as
+// ImplicitRules does.
+func WithImplicitRules(rules ...ImplicitRule) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.ImplicitRules(rules...) },
+		encoder: func(e *Encoder) { e.ImplicitRules(rules...) },
+	}
+}
+
+// WithDigitSeparators sets whether numbers can have '_' between their
+// digits, as DigitSeparators does.
+func WithDigitSeparators(allow bool) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.DigitSeparators(allow) },
+		encoder: func(e *Encoder) { e.DigitSeparators(allow) },
+	}
+}
+
+// WithNulls sets the plain scalars that are nulls, as Nulls does.
+func WithNulls(values ...string) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.Nulls(values...) },
+		encoder: func(e *Encoder) { e.Nulls(values...) },
+	}
+}
+
+// WithIndent sets the indentation of an Encoder, as Indent does.
+func WithIndent(spaces int) Option {
+	return EncoderOption(func(e *Encoder) { e.Indent(spaces) })
+}
+
+// WithLineWidth sets the preferred width of the lines an Encoder writes,
+// as LineWidth does.
+func WithLineWidth(width int) Option {
+	return EncoderOption(func(e *Encoder) { e.LineWidth(width) })
+}
+
+// WithEncoding sets the encoding an Encoder writes, as SetEncoding does.
+func WithEncoding(enc Encoding) Option {
+	return EncoderOption(func(e *Encoder) { e.SetEncoding(enc) })
+}
+
+// WithLineBreak sets the line breaks an Encoder writes, as SetLineBreak
+// does.
+func WithLineBreak(brk LineBreak) Option {
+	return EncoderOption(func(e *Encoder) { e.SetLineBreak(brk) })
+}
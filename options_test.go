@@ -0,0 +1,67 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Options", func() {
+	It("configures decoders", func() {
+		var v struct{ A int }
+		d := NewDecoder(strings.NewReader("a: 1\nb: 2\n"), WithStrict(true))
+		Expect(d.Decode(&v)).To(MatchError(ContainSubstring(`unable to map key "b"`)))
+
+		d = NewDecoder(strings.NewReader("a: 1\nb: 2\n"), WithStrict(true), WithStrict(false))
+		Expect(d.Decode(&v)).To(Succeed())
+	})
+
+	It("configures encoders", func() {
+		buf := &bytes.Buffer{}
+		e := NewEncoder(buf, WithIndent(4), WithLineBreak(CRLFBreak))
+		Expect(e.Encode(map[string]interface{}{"a": map[string]int{"b": 1}})).To(Succeed())
+		Expect(buf.String()).To(Equal("a:\r\n    b: 1\r\n"))
+	})
+
+	It("is shared by decoders and encoders", func() {
+		opts := Options(WithSchema(CoreSchema), WithIndent(3), WithStrict(true))
+
+		buf := &bytes.Buffer{}
+		e := NewEncoder(buf, opts)
+		Expect(e.Encode(map[string][]string{"a": {"on", "true"}})).To(Succeed())
+		Expect(buf.String()).To(Equal("a:\n- on\n- \"true\"\n"))
+
+		var v map[string]interface{}
+		d := NewDecoder(buf, opts)
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(v).To(Equal(map[string]interface{}{"a": []interface{}{"on", "true"}}))
+	})
+
+	It("passes other settings to the setters", func() {
+		buf := &bytes.Buffer{}
+		e := NewEncoder(buf, EncoderOption(func(e *Encoder) { e.QuoteStrings(true) }))
+		Expect(e.Encode([]string{"a"})).To(Succeed())
+		Expect(buf.String()).To(Equal("- \"a\"\n"))
+
+		var v interface{}
+		d := NewDecoder(strings.NewReader("1_000"), DecoderOption(func(d *Decoder) { d.DigitSeparators(false) }))
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(v).To(Equal("1_000"))
+	})
+})
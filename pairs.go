@@ -0,0 +1,195 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "reflect"
+
+// A Pair is a key/value pair of an ordered mapping or a pairs sequence.
+type Pair struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// An OrderedMap is a !!omap: a sequence of key/value pairs with unique keys.
+// !!omap values decoded into an interface{} are OrderedMaps.
+type OrderedMap []Pair
+
+// Pairs is a !!pairs: a sequence of key/value pairs in which keys may
+// repeat. !!pairs values decoded into an interface{} are Pairs.
+type Pairs []Pair
+
+var (
+	orderedMapType = reflect.TypeOf(OrderedMap{})
+	pairsType      = reflect.TypeOf(Pairs{})
+)
+
+// Besides OrderedMap and Pairs, !!omap and !!pairs sequences decode into
+// maps and into slices of structs with Key and Value fields, like plain
+// mappings do. !!set mappings decode into an interface{} as a
+// map[interface{}]struct{}, and Go maps with struct{} values encode as
+// !!set.
+
+// pairFields returns the Key and Value fields of a struct type, or nil when
+// it does not have both.
+func pairFields(t reflect.Type) (key, value *field) {
+	if t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	for _, f := range cachedTypeFields(t) {
+		switch f.name {
+		case "Key":
+			tempf := f
+			key = &tempf
+		case "Value":
+			tempf := f
+			value = &tempf
+		}
+	}
+
+	if key == nil || value == nil {
+		return nil, nil
+	}
+	return key, value
+}
+
+// pairs decodes a !!omap or !!pairs sequence into v. It returns false,
+// without consuming any event, when v cannot hold pairs.
+func (d *Decoder) pairs(v reflect.Value) bool {
+	tag := string(d.event.tag)
+
+	var add func(k, val reflect.Value)
+	var key, value func() reflect.Value
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return false
+		}
+		var p []Pair
+		key = func() reflect.Value {
+			var i interface{}
+			return reflect.ValueOf(&i).Elem()
+		}
+		value = key
+		add = func(k, val reflect.Value) {
+			p = append(p, Pair{k.Interface(), val.Interface()})
+		}
+		defer func() {
+			if tag == yaml_OMAP_TAG {
+				v.Set(reflect.ValueOf(OrderedMap(p)))
+			} else {
+				v.Set(reflect.ValueOf(Pairs(p)))
+			}
+		}()
+	case reflect.Slice:
+		kf, vf := pairFields(v.Type().Elem())
+		if kf == nil {
+			return false
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		elem := reflect.New(v.Type().Elem()).Elem()
+		key = func() reflect.Value {
+			elem.Set(reflect.Zero(elem.Type()))
+			return elem.FieldByIndex(kf.index)
+		}
+		value = func() reflect.Value { return elem.FieldByIndex(vf.index) }
+		add = func(k, val reflect.Value) { v.Set(reflect.Append(v, elem)) }
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key = func() reflect.Value { return reflect.New(v.Type().Key()).Elem() }
+		value = func() reflect.Value { return reflect.New(v.Type().Elem()).Elem() }
+		add = func(k, val reflect.Value) { v.SetMapIndex(k, val) }
+	default:
+		return false
+	}
+
+	d.nextEvent()
+	for d.event.event_type != yaml_SEQUENCE_END_EVENT {
+		if d.event.event_type != yaml_MAPPING_START_EVENT {
+			d.error(decodingError(d.event.start_mark, "Expected a mapping with a single pair in %s", shortTags[tag]))
+		}
+
+		anchor := string(d.event.anchor)
+		d.begin_anchor(anchor)
+		d.nextEvent()
+		if d.event.event_type == yaml_MAPPING_END_EVENT {
+			d.error(decodingError(d.event.start_mark, "Expected a mapping with a single pair in %s", shortTags[tag]))
+		}
+		d.checkKey()
+		k := key()
+		d.parse(k)
+		val := value()
+		d.parse(val)
+		if d.event.event_type != yaml_MAPPING_END_EVENT {
+			d.error(decodingError(d.event.start_mark, "Expected a mapping with a single pair in %s", shortTags[tag]))
+		}
+		d.nextEvent()
+		d.end_anchor(anchor)
+
+		add(k, val)
+	}
+	d.nextEvent()
+
+	return true
+}
+
+// setInterface is like mappingInterface for !!set mappings.
+func (d *Decoder) setInterface() map[interface{}]struct{} {
+	m := make(map[interface{}]struct{})
+
+	d.nextEvent()
+	for d.event.event_type != yaml_MAPPING_END_EVENT {
+		d.checkKey()
+		key := d.valueInterface()
+		d.valueInterface()
+		m[key] = struct{}{}
+	}
+	d.nextEvent()
+
+	return m
+}
+
+// isSetType reports whether maps of type t encode as a !!set.
+func isSetType(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Struct && t.Elem().NumField() == 0
+}
+
+// emitPairs writes an OrderedMap or Pairs as a sequence of single pair
+// mappings.
+func (e *Encoder) emitPairs(tag string, v reflect.Value) {
+	if tag == "" {
+		tag = shortTags[yaml_PAIRS_TAG]
+		if v.Type() == orderedMapType {
+			tag = shortTags[yaml_OMAP_TAG]
+		}
+	}
+
+	yaml_sequence_start_event_initialize(&e.event, e.takeAnchor(), []byte(tag), false, yaml_BLOCK_SEQUENCE_STYLE)
+	e.emit()
+
+	for i := 0; i < v.Len(); i++ {
+		p := v.Index(i).Interface().(Pair)
+		e.mapping("", func() {
+			e.marshalKey(reflect.ValueOf(&p.Key).Elem())
+			e.marshal("", reflect.ValueOf(&p.Value).Elem(), true)
+		})
+	}
+
+	yaml_sequence_end_event_initialize(&e.event)
+	e.emit()
+}
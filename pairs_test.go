@@ -0,0 +1,110 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Standard collection tags", func() {
+	Context("!!omap and !!pairs", func() {
+		omap := `!!omap
+- b: 2
+- a: 1
+`
+		pairs := `!!pairs
+- a: 1
+- a: 2
+`
+
+		It("decodes into an interface{}", func() {
+			var v interface{}
+			Expect(Unmarshal([]byte(omap), &v)).To(Succeed())
+			Expect(v).To(Equal(OrderedMap{{"b", int64(2)}, {"a", int64(1)}}))
+
+			Expect(Unmarshal([]byte(pairs), &v)).To(Succeed())
+			Expect(v).To(Equal(Pairs{{"a", int64(1)}, {"a", int64(2)}}))
+		})
+
+		It("decodes into a slice of Key/Value structs", func() {
+			type pair struct {
+				Key   string
+				Value int
+			}
+			var v []pair
+			Expect(Unmarshal([]byte(omap), &v)).To(Succeed())
+			Expect(v).To(Equal([]pair{{"b", 2}, {"a", 1}}))
+		})
+
+		It("decodes into a map", func() {
+			var v map[string]int
+			Expect(Unmarshal([]byte(omap), &v)).To(Succeed())
+			Expect(v).To(Equal(map[string]int{"a": 1, "b": 2}))
+		})
+
+		It("decodes nested in an interface{}", func() {
+			var v map[string]interface{}
+			Expect(Unmarshal([]byte("m: "+omap), &v)).To(Succeed())
+			Expect(v["m"]).To(Equal(OrderedMap{{"b", int64(2)}, {"a", int64(1)}}))
+		})
+
+		It("fails on items that are not single pairs", func() {
+			var v interface{}
+			err := Unmarshal([]byte("!!omap\n- a: 1\n  b: 2\n"), &v)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected a mapping with a single pair in !!omap"))
+		})
+
+		It("encodes OrderedMap and Pairs", func() {
+			out, err := Marshal(OrderedMap{{"b", 2}, {"a", 1}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal(omap))
+
+			out, err = Marshal(Pairs{{"a", 1}, {"a", 2}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal(pairs))
+		})
+	})
+
+	Context("!!set", func() {
+		set := `!!set
+a: null
+b: null
+`
+
+		It("decodes into an interface{}", func() {
+			var v interface{}
+			Expect(Unmarshal([]byte("!!set {a, b}\n"), &v)).To(Succeed())
+			Expect(v).To(Equal(map[interface{}]struct{}{"a": {}, "b": {}}))
+		})
+
+		It("decodes into maps of struct{} and bool", func() {
+			var s map[string]struct{}
+			Expect(Unmarshal([]byte(set), &s)).To(Succeed())
+			Expect(s).To(Equal(map[string]struct{}{"a": {}, "b": {}}))
+
+			var b map[string]bool
+			Expect(Unmarshal([]byte("!!set\n? a\n? b\n"), &b)).To(Succeed())
+			Expect(b).To(Equal(map[string]bool{"a": true, "b": true}))
+		})
+
+		It("encodes maps of struct{}", func() {
+			out, err := Marshal(map[string]struct{}{"b": {}, "a": {}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal(set))
+		})
+	})
+})
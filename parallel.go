@@ -0,0 +1,266 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// A DocumentResult is a document decoded by DecodeParallel.
+type DocumentResult struct {
+	// Index is the position of the document in the stream, from 0.
+	Index int
+
+	// Value is the pointer returned by newValue that the document was
+	// decoded into.
+	Value interface{}
+
+	// Err is the error decoding the document, if any.
+	Err error
+}
+
+// a document split out of a stream, at a byte offset from the start of
+// the input scanned, which ends at the end mark
+type documentText struct {
+	index     int
+	text      []byte
+	offset    int
+	mark, end YAML_mark_t
+	err       error
+
+	// receives the result when the results are ordered
+	result chan DocumentResult
+}
+
+// DecodeParallel decodes the remaining documents of the stream on several
+// goroutines, sending a result for each of them to the returned channel,
+// which is closed after the last one. It must be called before any other
+// reading method, and the Decoder cannot be read afterwards.
+//
+// The stream is scanned for the boundaries of its documents, and the text
+// of each document is decoded on one of workers goroutines, by default
+// one per CPU, into a value returned by newValue, which must be a pointer.
+// If ordered is true, the results are sent in the order of the documents;
+// otherwise, as soon as they are decoded. The options of the Decoder apply
+// to every document, and its OnWarning and OnProgress functions are called
+// one at a time, in the order the documents happen to be decoded. As the
+// documents are decoded independently, aliases can only refer to anchors
+// of their own document.
+//
+// A document that fails to decode is reported in its result, and the
+// documents that follow it are still decoded. An error scanning the stream
+// is reported in the result of the document being scanned, which is the
+// last. The channel must be drained, or the goroutines reading the stream
+// and decoding the documents never stop.
+func (d *Decoder) DecodeParallel(workers int, ordered bool, newValue func() interface{}) <-chan DocumentResult {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	results := make(chan DocumentResult, workers)
+
+	if d.event.event_type != yaml_NO_EVENT {
+		results <- DocumentResult{Err: errors.New("DecodeParallel must be called before reading the stream")}
+		close(results)
+		return results
+	}
+
+	jobs := make(chan *documentText, workers)
+	var queue chan *documentText
+	if ordered {
+		// bounds the documents held in memory while an earlier one is
+		// being decoded
+		queue = make(chan *documentText, 2*workers)
+	}
+	go func() {
+		d.splitDocuments(func(doc *documentText) bool {
+			if ordered {
+				doc.result = make(chan DocumentResult, 1)
+				queue <- doc
+			}
+			jobs <- doc
+			return true
+		})
+		close(jobs)
+		if ordered {
+			close(queue)
+		}
+	}()
+
+	// the functions of OnWarning and OnProgress are called one at a time
+	var callbacks sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			dec := NewDecoder(nil)
+			dec.copyOptions(d)
+			if dec.onWarning != nil {
+				dec.onWarning = func(w Warning) {
+					callbacks.Lock()
+					defer callbacks.Unlock()
+					d.onWarning(w)
+				}
+			}
+			if dec.onProgress != nil {
+				dec.onProgress = func(m Mark) {
+					callbacks.Lock()
+					defer callbacks.Unlock()
+					d.onProgress(m)
+				}
+			}
+			for doc := range jobs {
+				result := dec.decodeDocument(doc, newValue)
+				if ordered {
+					doc.result <- result
+				} else {
+					results <- result
+				}
+			}
+		}()
+	}
+
+	go func() {
+		if ordered {
+			for doc := range queue {
+				results <- <-doc.result
+			}
+		}
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// copyOptions sets the options of d to those of o.
+func (d *Decoder) copyOptions(o *Decoder) {
+	d.decoderOptions = o.decoderOptions
+	d.parser.yaml_parser_options_t = o.parser.yaml_parser_options_t
+}
+
+// decodeDocument decodes the text of a document into a new value.
+func (d *Decoder) decodeDocument(doc *documentText, newValue func() interface{}) DocumentResult {
+	result := DocumentResult{Index: doc.index, Err: doc.err}
+	if result.Err != nil {
+		return result
+	}
+
+	d.Reset(bytes.NewReader(doc.text))
+	// report positions in the whole stream
+	d.parser.mark = doc.mark
+	d.nextProgress = doc.mark.offset + d.progressEvery
+
+	result.Value = newValue()
+	result.Err = d.Decode(result.Value)
+	return result
+}
+
+// splitDocuments scans the stream, passing the text of each document to
+// send. It stops at the end of the stream, when send returns false, or at
+// the first scanner error, which is sent as the last document.
+func (d *Decoder) splitDocuments(send func(*documentText) bool) {
+	parser := &d.parser
+	parser.keep_text = true
+
+	// the start of the current document, and whether it has any content
+	// or directives
+	start := parser.mark
+	content, directives := false, false
+	index, offset := 0, 0
+	stop := false
+
+	cut := func(end YAML_mark_t) {
+		text := takeText(parser, end.index-start.index)
+		if content || directives {
+			stop = !send(&documentText{index: index, text: text, offset: offset, mark: start, end: end})
+			index++
+		}
+		offset += len(text)
+		start = end
+		content, directives = false, false
+	}
+
+	// after a "..." that ends its line, the next document starts with the
+	// first token on a later line, where the scanner is as if it started
+	// afresh
+	ended := false
+	var endMark YAML_mark_t
+
+	var token yaml_token_t
+	for !stop {
+		if !yaml_parser_scan(parser, &token) {
+			send(&documentText{index: index, offset: offset, mark: start, err: newParserError(parser)})
+			return
+		}
+
+		if ended {
+			ended = false
+			if token.start_mark.line > endMark.line {
+				if cut(token.start_mark); stop {
+					return
+				}
+			}
+		}
+
+		switch token.token_type {
+		case yaml_STREAM_START_TOKEN:
+		case yaml_VERSION_DIRECTIVE_TOKEN, yaml_TAG_DIRECTIVE_TOKEN:
+			if content {
+				cut(token.start_mark)
+			}
+			directives = true
+		case yaml_DOCUMENT_START_TOKEN:
+			if content {
+				cut(token.start_mark)
+			}
+			content = true
+		case yaml_DOCUMENT_END_TOKEN:
+			ended, endMark = true, token.end_mark
+		case yaml_STREAM_END_TOKEN:
+			cut(token.start_mark)
+			return
+		default:
+			content = true
+		}
+	}
+}
+
+// takeText removes the next n characters from the text kept by the parser
+// and returns a copy of them.
+func takeText(parser *yaml_parser_t, n int) []byte {
+	i := 0
+	for ; n > 0 && i < len(parser.text); n-- {
+		i += width(parser.text[i])
+	}
+	j := parser.text_pos
+	for ; n > 0; n-- {
+		j += width(parser.buffer[j])
+	}
+
+	text := make([]byte, 0, i+j-parser.text_pos)
+	text = append(text, parser.text[:i]...)
+	text = append(text, parser.buffer[parser.text_pos:j]...)
+
+	if j > parser.text_pos {
+		parser.text = parser.text[:0]
+		parser.text_pos = j
+	} else {
+		parser.text = parser.text[:copy(parser.text, parser.text[i:])]
+	}
+	return text
+}
@@ -0,0 +1,188 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parallel decoding", func() {
+	newMap := func() interface{} { return new(map[string]interface{}) }
+
+	collect := func(results <-chan DocumentResult) []DocumentResult {
+		var all []DocumentResult
+		for r := range results {
+			all = append(all, r)
+		}
+		return all
+	}
+
+	It("decodes every document of the stream in order", func() {
+		var buf bytes.Buffer
+		for i := 0; i < 200; i++ {
+			fmt.Fprintf(&buf, "---\nn: %d\nlist: [a, b]\ntext: |\n  line %d\n", i, i)
+		}
+
+		results := collect(NewDecoder(&buf).DecodeParallel(4, true, newMap))
+		Expect(results).To(HaveLen(200))
+		for i, r := range results {
+			Expect(r.Err).NotTo(HaveOccurred())
+			Expect(r.Index).To(Equal(i))
+			Expect(*r.Value.(*map[string]interface{})).To(Equal(map[string]interface{}{
+				"n": int64(i), "list": []interface{}{"a", "b"}, "text": fmt.Sprintf("line %d\n", i),
+			}))
+		}
+	})
+
+	It("sends every result when unordered", func() {
+		input := strings.Repeat("a: 1\n---\n", 50) + "a: 1\n"
+		results := collect(NewDecoder(strings.NewReader(input)).DecodeParallel(0, false, newMap))
+		Expect(results).To(HaveLen(51))
+
+		indexes := make([]int, len(results))
+		for i, r := range results {
+			Expect(r.Err).NotTo(HaveOccurred())
+			indexes[i] = r.Index
+		}
+		sort.Ints(indexes)
+		for i := range indexes {
+			Expect(indexes[i]).To(Equal(i))
+		}
+	})
+
+	It("splits documents at their markers only", func() {
+		input := `# leading comment
+%TAG !e! tag:yaml.org,2002:
+--- !e!map
+a: "--- not a marker"
+...
+# between documents
+---
+b: |
+  text
+...
+...
+c: &x [1, *x2]
+`
+		var values []interface{}
+		var errs []error
+		for r := range NewDecoder(strings.NewReader(input)).DecodeParallel(2, true, func() interface{} { return new(interface{}) }) {
+			values = append(values, *r.Value.(*interface{}))
+			errs = append(errs, r.Err)
+		}
+		Expect(values).To(HaveLen(3))
+		Expect(errs[0]).NotTo(HaveOccurred())
+		Expect(errs[1]).NotTo(HaveOccurred())
+		Expect(values[1]).To(Equal(map[interface{}]interface{}{"b": "text\n"}))
+		Expect(errs[2]).To(HaveOccurred())
+	})
+
+	It("reports errors at their position in the stream", func() {
+		input := "a: 1\n---\nb: [1\n---\nc: 3\n"
+		results := collect(NewDecoder(strings.NewReader(input)).DecodeParallel(2, true, newMap))
+		Expect(results).To(HaveLen(3))
+		Expect(results[0].Err).NotTo(HaveOccurred())
+		Expect(results[1].Index).To(Equal(1))
+		Expect(results[1].Err).To(MatchError(ContainSubstring("line 4")))
+		Expect(results[2].Err).NotTo(HaveOccurred())
+		Expect(*results[2].Value.(*map[string]interface{})).To(Equal(map[string]interface{}{"c": int64(3)}))
+	})
+
+	It("stops at scanner errors", func() {
+		input := "a: 1\n---\nb: 2\nb\n---\nc: 3\n"
+		results := collect(NewDecoder(strings.NewReader(input)).DecodeParallel(2, true, newMap))
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Err).NotTo(HaveOccurred())
+		Expect(results[1].Index).To(Equal(1))
+		Expect(results[1].Err).To(MatchError(ContainSubstring("line 5")))
+	})
+
+	It("applies the options of the Decoder", func() {
+		d := NewDecoder(strings.NewReader("a: 1\n---\nb: 2.5\n"))
+		d.UseNumber()
+		results := collect(d.DecodeParallel(2, true, newMap))
+		Expect(results).To(HaveLen(2))
+		Expect(*results[1].Value.(*map[string]interface{})).To(Equal(map[string]interface{}{"b": Number("2.5")}))
+	})
+
+	It("decodes as serial decoding does with the same options", func() {
+		var buf bytes.Buffer
+		for i := 0; i < 20; i++ {
+			fmt.Fprintf(&buf, "%%FOO bar\n---\ncount: %d.50\nt: 2001-12-14\nnothing: none\nlist:\n    - x\nb:\n  c: yes\n", i)
+		}
+		input := buf.String()
+
+		configure := func(d *Decoder, warnings *[]string) {
+			d.EmptyDocuments(EmptyIsEOF)
+			d.UseNumber()
+			d.TimestampStrings(true)
+			d.Nulls("none")
+			d.UnknownDirectives(WarnOnUnknownDirectives)
+			d.IndentationWarnings(true)
+			d.OnWarning(func(w Warning) { *warnings = append(*warnings, w.String()) })
+		}
+
+		var serial []interface{}
+		var serialWarnings []string
+		d := NewDecoder(strings.NewReader(input))
+		configure(d, &serialWarnings)
+		for {
+			var v interface{}
+			err := d.Decode(&v)
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			serial = append(serial, v)
+		}
+
+		var parallel []interface{}
+		var parallelWarnings []string
+		d = NewDecoder(strings.NewReader(input))
+		configure(d, &parallelWarnings)
+		newValue := func() interface{} { return new(interface{}) }
+		for _, r := range collect(d.DecodeParallel(4, true, newValue)) {
+			Expect(r.Err).NotTo(HaveOccurred())
+			parallel = append(parallel, *r.Value.(*interface{}))
+		}
+
+		Expect(serial).To(HaveLen(20))
+		Expect(serial[3]).To(HaveKeyWithValue("count", Number("3.5")))
+		Expect(serial[3]).To(HaveKeyWithValue("t", "2001-12-14"))
+		Expect(serial[3]).To(HaveKeyWithValue("nothing", BeNil()))
+		Expect(parallel).To(Equal(serial))
+
+		Expect(serialWarnings).NotTo(BeEmpty())
+		sort.Strings(serialWarnings)
+		sort.Strings(parallelWarnings)
+		Expect(parallelWarnings).To(Equal(serialWarnings))
+	})
+
+	It("fails once the stream has been read", func() {
+		d := NewDecoder(strings.NewReader("a: 1\n---\nb: 2\n"))
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		results := collect(d.DecodeParallel(2, true, newMap))
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Err).To(HaveOccurred())
+	})
+})
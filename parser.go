@@ -261,6 +261,7 @@ func yaml_parser_parse_document_start(parser *yaml_parser_t, event *yaml_event_t
 
 	/* Parse an implicit document. */
 
+	implicit = implicit || parser.implicit_documents
 	if implicit && token.token_type != yaml_VERSION_DIRECTIVE_TOKEN &&
 		token.token_type != yaml_TAG_DIRECTIVE_TOKEN &&
 		token.token_type != yaml_DOCUMENT_START_TOKEN &&
@@ -293,6 +294,13 @@ func yaml_parser_parse_document_start(parser *yaml_parser_t, event *yaml_event_t
 			return false
 		}
 		if token.token_type != yaml_DOCUMENT_START_TOKEN {
+			if version_directive != nil || len(tag_directives) > 0 {
+				yaml_parser_set_parser_error_context(parser,
+					"while parsing the directives", start_mark,
+					"did not find expected <document start>, which has to follow them",
+					token.start_mark)
+				return false
+			}
 			yaml_parser_set_parser_error(parser,
 				"did not find expected <document start>", token.start_mark)
 			return false
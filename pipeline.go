@@ -0,0 +1,408 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Filter transforms the events of a stream on their way from a parser to
+// an emitter, in a Pipeline. Filter is called with each event in turn and
+// where it is, and passes the events to write on to next: none to drop the
+// event, the event itself or a changed one, or several to add events.
+type Filter interface {
+	Filter(ev Event, at EventPath, next func(Event) error) error
+}
+
+// FilterFunc is a function that is a Filter.
+type FilterFunc func(ev Event, at EventPath, next func(Event) error) error
+
+// Filter calls f.
+func (f FilterFunc) Filter(ev Event, at EventPath, next func(Event) error) error {
+	return f(ev, at, next)
+}
+
+// An EventPath locates an event in its document.
+type EventPath struct {
+	// Path is the path of the node the event belongs to, from the root of
+	// the document, which is "": mapping values are named by their keys,
+	// joined by dots, and elements of sequences are indexed, as in
+	// "servers[0].port". The end of a collection has the path of its
+	// start. A mapping key has the path of its value, and a key that is
+	// not a scalar is named "?".
+	Path string
+
+	// Key is true for the events of a mapping key.
+	Key bool
+}
+
+// A Pipeline passes the events of a YAML stream through a chain of
+// Filters, holding only the nodes being written, so that streams of any
+// size can be transformed in constant memory. Each Filter locates the
+// events it receives, after the changes of the Filters before it.
+// Comments and directives are not kept.
+type Pipeline struct {
+	filters []Filter
+}
+
+// NewPipeline returns a Pipeline passing events through filters, in order.
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+// Run reads the stream from r and writes the events out of the last Filter
+// to e, as EncodeEvents does. It stops at the first error of the parser,
+// of a Filter or of e.
+func (p *Pipeline) Run(r io.Reader, e *Encoder) (err error) {
+	if e.err != nil {
+		return e.err
+	}
+	defer func() { e.err = err }()
+	defer recovery(&err)
+
+	if !e.started {
+		e.start()
+	}
+
+	sink := eventSink{e: e}
+	next := func(ev Event) error {
+		sink.write(ev)
+		return nil
+	}
+	for i := len(p.filters) - 1; i >= 0; i-- {
+		next = filterStage(p.filters[i], next)
+	}
+
+	var parser yaml_parser_t
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_reader(&parser, r)
+
+	var event yaml_event_t
+	for {
+		if !yaml_parser_parse(&parser, &event) {
+			return newParserError(&parser)
+		}
+		if err := next(eventOf(&event)); err != nil {
+			return err
+		}
+		if event.event_type == yaml_STREAM_END_EVENT {
+			return sink.close()
+		}
+	}
+}
+
+// filterStage returns the function passing events through f to next,
+// locating them in the stream f receives.
+func filterStage(f Filter, next func(Event) error) func(Event) error {
+	var t pathTracker
+	return func(ev Event) error {
+		return f.Filter(ev, t.locate(ev), next)
+	}
+}
+
+// A pathTracker follows the path of the events of a stream.
+type pathTracker struct {
+	frames []pathFrame
+}
+
+// A pathFrame is an open collection.
+type pathFrame struct {
+	path    string
+	mapping bool
+	index   int    // the index of the next element of a sequence
+	onKey   bool   // the next node of a mapping is a key
+	key     string // the last key of a mapping
+	inKey   bool   // the collection is within a mapping key
+}
+
+// locate returns where ev is, and moves past it.
+func (t *pathTracker) locate(ev Event) EventPath {
+	switch ev.Kind {
+	case ScalarEvent, AliasEvent:
+		at := t.node(ev)
+		t.done()
+		return at
+	case SequenceStartEvent, MappingStartEvent:
+		at := t.node(ev)
+		mapping := ev.Kind == MappingStartEvent
+		t.frames = append(t.frames, pathFrame{path: at.Path, mapping: mapping, onKey: mapping, inKey: at.Key})
+		return at
+	case SequenceEndEvent, MappingEndEvent:
+		if len(t.frames) == 0 {
+			return EventPath{}
+		}
+		f := t.frames[len(t.frames)-1]
+		t.frames = t.frames[:len(t.frames)-1]
+		t.done()
+		return EventPath{Path: f.path, Key: f.inKey}
+	}
+	t.frames = t.frames[:0]
+	return EventPath{}
+}
+
+// node returns where the node starting at ev is.
+func (t *pathTracker) node(ev Event) EventPath {
+	if len(t.frames) == 0 {
+		return EventPath{}
+	}
+	f := &t.frames[len(t.frames)-1]
+	switch {
+	case f.inKey:
+		return EventPath{Path: f.path, Key: true}
+	case !f.mapping:
+		return EventPath{Path: f.path + "[" + strconv.Itoa(f.index) + "]"}
+	case f.onKey:
+		f.key = "?"
+		if ev.Kind == ScalarEvent {
+			f.key = ev.Value
+		}
+		return EventPath{Path: joinPath(f.path, f.key), Key: true}
+	}
+	return EventPath{Path: joinPath(f.path, f.key)}
+}
+
+// done moves past a node of the innermost collection.
+func (t *pathTracker) done() {
+	if len(t.frames) == 0 {
+		return
+	}
+	f := &t.frames[len(t.frames)-1]
+	if f.mapping {
+		f.onKey = !f.onKey
+	} else {
+		f.index++
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// pathPattern compiles a pattern of the paths of EventPath, in which "*"
+// stands for any key, "[*]" for any index and "**" for any number of keys
+// and indices, none included, as in "**.password".
+func pathPattern(pattern string) *regexp.Regexp {
+	p := regexp.QuoteMeta(pattern)
+	p = strings.Replace(p, `\[\*\]`, `\[[0-9]+\]`, -1)
+	p = strings.Replace(p, `\*\*\.`, `(?:.*\.)?`, -1)
+	p = strings.Replace(p, `\*\*`, `.*`, -1)
+	p = strings.Replace(p, `\*`, `[^.\[]*`, -1)
+	return regexp.MustCompile("^" + p + "$")
+}
+
+// matchPaths compiles patterns of paths into a function matching them.
+func matchPaths(patterns []string) func(string) bool {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		res[i] = pathPattern(p)
+	}
+	return func(path string) bool {
+		for _, re := range res {
+			if re.MatchString(path) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// isNodeStart reports whether ev is the first event of a node.
+func isNodeStart(ev Event) bool {
+	switch ev.Kind {
+	case ScalarEvent, AliasEvent, SequenceStartEvent, MappingStartEvent:
+		return true
+	}
+	return false
+}
+
+// RenameKeys returns a Filter renaming the mapping keys at the paths
+// given, to the names they map to. In paths, "*" stands for any key and
+// "[*]" for any index, as in "servers[*].host".
+func RenameKeys(names map[string]string) Filter {
+	type rename struct {
+		re   *regexp.Regexp
+		name string
+	}
+	var renames []rename
+	for path, name := range names {
+		renames = append(renames, rename{pathPattern(path), name})
+	}
+	return FilterFunc(func(ev Event, at EventPath, next func(Event) error) error {
+		if at.Key && ev.Kind == ScalarEvent {
+			for _, r := range renames {
+				if r.re.MatchString(at.Path) {
+					ev.Value = r.name
+					break
+				}
+			}
+		}
+		return next(ev)
+	})
+}
+
+// DropPaths returns a Filter dropping the mapping entries and sequence
+// elements at paths matching patterns, in which "*" stands for any key
+// and "[*]" for any index.
+func DropPaths(patterns ...string) Filter {
+	match := matchPaths(patterns)
+
+	// depth counts the open collections of the node being dropped, and
+	// value is true when the value of a dropped key comes next
+	depth := 0
+	value := false
+	return FilterFunc(func(ev Event, at EventPath, next func(Event) error) error {
+		switch {
+		case depth > 0:
+		case value && isNodeStart(ev):
+			value = false
+		case isNodeStart(ev) && at.Path != "" && match(at.Path):
+			if at.Key {
+				if ev.Kind != ScalarEvent {
+					return next(ev)
+				}
+				value = true
+				return nil
+			}
+		default:
+			return next(ev)
+		}
+
+		switch ev.Kind {
+		case SequenceStartEvent, MappingStartEvent:
+			depth++
+		case SequenceEndEvent, MappingEndEvent:
+			depth--
+		}
+		return nil
+	})
+}
+
+// RewriteTags returns a Filter replacing the tags of nodes by the tags
+// they map to. Tags can be given in their short forms, such as "!!str".
+func RewriteTags(tags map[string]string) Filter {
+	long := make(map[string]string, len(tags))
+	for from, to := range tags {
+		long[longTag(from)] = longTag(to)
+	}
+	return FilterFunc(func(ev Event, at EventPath, next func(Event) error) error {
+		if to, ok := long[ev.Tag]; ok && ev.Tag != "" {
+			ev.Tag = to
+		}
+		return next(ev)
+	})
+}
+
+// InjectDefaults returns a Filter adding the keys of defaults, with their
+// values, to the mappings at the paths matching pattern that lack them.
+// The values are written as plain scalars, so that they are read as they
+// would be in the input. In pattern, "*" stands for any key and "[*]" for
+// any index.
+func InjectDefaults(pattern string, defaults map[string]string) Filter {
+	re := pathPattern(pattern)
+	keys := make([]string, 0, len(defaults))
+	for k := range defaults {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// the open collections, with the keys seen in those that are
+	// mappings matching pattern
+	type frame struct {
+		path string
+		keys map[string]bool
+	}
+	var frames []frame
+	return FilterFunc(func(ev Event, at EventPath, next func(Event) error) error {
+		switch ev.Kind {
+		case SequenceStartEvent, MappingStartEvent:
+			f := frame{path: at.Path}
+			if ev.Kind == MappingStartEvent && !at.Key && re.MatchString(at.Path) {
+				f.keys = make(map[string]bool)
+			}
+			frames = append(frames, f)
+		case ScalarEvent:
+			if n := len(frames); n > 0 && frames[n-1].keys != nil &&
+				at.Key && at.Path == joinPath(frames[n-1].path, ev.Value) {
+				frames[n-1].keys[ev.Value] = true
+			}
+		case MappingEndEvent:
+			if n := len(frames); n > 0 && frames[n-1].keys != nil {
+				for _, k := range keys {
+					if frames[n-1].keys[k] {
+						continue
+					}
+					if err := next(Event{Kind: ScalarEvent, Value: k}); err != nil {
+						return err
+					}
+					if err := next(Event{Kind: ScalarEvent, Value: defaults[k]}); err != nil {
+						return err
+					}
+				}
+			}
+			fallthrough
+		case SequenceEndEvent:
+			if n := len(frames); n > 0 {
+				frames = frames[:n-1]
+			}
+		}
+		return next(ev)
+	})
+}
+
+// Redact returns a Filter replacing the values at the paths matching
+// patterns by placeholder, keeping the rest of the stream as it is, such as
+// "**.password" for every password. A collection at a matching path is
+// replaced as a whole. In patterns, "*" stands for any key, "[*]" for any
+// index and "**" for any number of keys and indices.
+func Redact(placeholder string, patterns ...string) Filter {
+	match := matchPaths(patterns)
+
+	// depth counts the open collections of the value being replaced
+	depth := 0
+	return FilterFunc(func(ev Event, at EventPath, next func(Event) error) error {
+		if depth > 0 {
+			switch ev.Kind {
+			case SequenceStartEvent, MappingStartEvent:
+				depth++
+			case SequenceEndEvent, MappingEndEvent:
+				depth--
+			}
+			return nil
+		}
+		if at.Key || !isNodeStart(ev) || !match(at.Path) {
+			return next(ev)
+		}
+
+		style := ev.Style
+		if ev.Kind != ScalarEvent {
+			style = AnyStyle
+		}
+		if ev.Kind == SequenceStartEvent || ev.Kind == MappingStartEvent {
+			depth = 1
+		}
+		anchor := ev.Anchor
+		if ev.Kind == AliasEvent {
+			anchor = ""
+		}
+		return next(Event{Kind: ScalarEvent, Anchor: anchor, Value: placeholder, Style: style})
+	})
+}
@@ -0,0 +1,204 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pipeline", func() {
+	input := `servers:
+- host: a.example.com
+  port: 80
+  debug: true
+- host: b.example.com
+  secret: !vault abc
+---
+servers: []
+`
+
+	run := func(filters ...Filter) (string, error) {
+		var buf bytes.Buffer
+		err := NewPipeline(filters...).Run(strings.NewReader(input), NewEncoder(&buf))
+		return buf.String(), err
+	}
+
+	It("copies the stream without filters", func() {
+		out, err := run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(input))
+	})
+
+	It("locates the events", func() {
+		var paths []string
+		_, err := run(FilterFunc(func(ev Event, at EventPath, next func(Event) error) error {
+			if ev.Kind == ScalarEvent && at.Key {
+				paths = append(paths, at.Path)
+			}
+			return next(ev)
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(Equal([]string{
+			"servers",
+			"servers[0].host", "servers[0].port", "servers[0].debug",
+			"servers[1].host", "servers[1].secret",
+			"servers",
+		}))
+	})
+
+	It("chains the filters", func() {
+		out, err := run(
+			DropPaths("servers[*].debug", "servers[1]"),
+			RenameKeys(map[string]string{"servers[*].host": "hostname"}),
+			InjectDefaults("servers[*]", map[string]string{"port": "8080", "weight": "1"}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(`servers:
+- hostname: a.example.com
+  port: 80
+  weight: 1
+---
+servers: []
+`))
+	})
+
+	It("rewrites tags", func() {
+		out, err := run(DropPaths("servers[0]"), RewriteTags(map[string]string{"!vault": "!!str"}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(HavePrefix(`servers:
+- host: b.example.com
+  secret: !!str abc
+`))
+	})
+
+	It("stops at the first error", func() {
+		failure := errors.New("no servers")
+		_, err := run(FilterFunc(func(ev Event, at EventPath, next func(Event) error) error {
+			if at.Path == "servers" && !at.Key {
+				return failure
+			}
+			return next(ev)
+		}))
+		Expect(err).To(Equal(failure))
+
+		var buf bytes.Buffer
+		err = NewPipeline().Run(strings.NewReader("a: [\n"), NewEncoder(&buf))
+		Expect(err).To(BeAssignableToTypeOf(&ParserError{}))
+	})
+})
+
+var _ = Describe("MovePaths", func() {
+	move := func(input string, rules map[string]string) (string, error) {
+		var buf bytes.Buffer
+		err := NewPipeline(MovePaths(rules)).Run(strings.NewReader(input), NewEncoder(&buf))
+		return buf.String(), err
+	}
+
+	It("renames keys in place", func() {
+		out, err := move("a: 1\nb: 2\nc: 3\n", map[string]string{"b": "bee"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("a: 1\nbee: 2\nc: 3\n"))
+	})
+
+	It("moves entries to other mappings, creating them", func() {
+		out, err := move(`server:
+  host: example.com
+  port: 80
+db:
+  url: postgres://db
+`, map[string]string{
+			"server.host": "network.host",
+			"db":          "database",
+			"server.port": "network.ports.http",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(`server: {}
+database:
+  url: postgres://db
+network:
+  host: example.com
+  ports:
+    http: 80
+`))
+	})
+
+	It("moves the entries matching wildcards", func() {
+		out, err := move(`servers:
+- name: a
+  addr: 1.2.3.4
+- name: b
+  addr: 5.6.7.8
+  old:
+    x: 1
+    y: 2
+`, map[string]string{
+			"servers[*].addr":  "servers[*].address",
+			"servers[*].old.*": "servers[*].*",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(`servers:
+- name: a
+  address: 1.2.3.4
+- name: b
+  address: 5.6.7.8
+  old: {}
+  x: 1
+  y: 2
+`))
+	})
+
+	It("fails when there is nowhere to move an entry", func() {
+		_, err := move("a: 1\nlist: []\n", map[string]string{"a": "list[0].a"})
+		Expect(err).To(MatchError("Cannot move an entry to list[0]: there is no list[0]"))
+	})
+})
+
+var _ = Describe("Redact", func() {
+	It("replaces the values at the paths given", func() {
+		input := `# settings
+db:
+  user: admin
+  password: "s3cret" # rotated monthly
+  replicas:
+  - host: a
+    password: 'x'
+credentials: &creds
+  token: abc
+backup: *creds
+password: |
+  hunter2
+`
+		var buf bytes.Buffer
+		err := NewPipeline(Redact("[REDACTED]", "**.password", "credentials", "backup")).
+			Run(strings.NewReader(input), NewEncoder(&buf))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(buf.String()).To(Equal(`db:
+  user: admin
+  password: "[REDACTED]"
+  replicas:
+  - host: a
+    password: '[REDACTED]'
+credentials: &creds '[REDACTED]'
+backup: '[REDACTED]'
+password: |-
+  [REDACTED]
+`))
+	})
+})
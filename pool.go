@@ -0,0 +1,90 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"io"
+	"sync"
+)
+
+// Marshal and Unmarshal take their Encoders and Decoders from pools, so
+// that the buffers, token queues and state stacks of one call are reused
+// by the next. Buffers that grew past these limits while reading or
+// writing a large document are left to the garbage collector instead.
+const (
+	maxPooledBuffer = 64 << 10
+	maxPooledQueue  = 1024
+)
+
+var (
+	decoderPool sync.Pool
+	encoderPool sync.Pool
+)
+
+// getDecoder returns a Decoder with default options reading from r.
+func getDecoder(r io.Reader) *Decoder {
+	if d, ok := decoderPool.Get().(*Decoder); ok {
+		d.Reset(r)
+		return d
+	}
+	return NewDecoder(r)
+}
+
+// putDecoder returns d to the pool unless its buffers are too large.
+func putDecoder(d *Decoder) {
+	p := &d.parser
+	if cap(p.buffer) > maxPooledBuffer || cap(p.raw_buffer) > maxPooledBuffer ||
+		cap(p.tokens) > maxPooledQueue || cap(d.tracking_anchors) > maxPooledQueue {
+		return
+	}
+
+	// drop the references to the input before pooling
+	p.tokens = p.tokens[:cap(p.tokens)]
+	for i := range p.tokens {
+		p.tokens[i] = yaml_token_t{}
+	}
+	d.Reset(nil)
+	decoderPool.Put(d)
+}
+
+// getEncoder returns an Encoder with default options writing to w.
+func getEncoder(w io.Writer) *Encoder {
+	if e, ok := encoderPool.Get().(*Encoder); ok {
+		e.Reset(w)
+		return e
+	}
+	return NewEncoder(w)
+}
+
+// putEncoder returns e to the pool unless its buffers are too large.
+func putEncoder(e *Encoder) {
+	em := &e.emitter
+	if cap(em.raw_buffer) > maxPooledBuffer || cap(em.events) > maxPooledQueue ||
+		cap(e.events) > maxPooledQueue {
+		return
+	}
+
+	// drop the references to the output and the encoded values
+	em.events = em.events[:cap(em.events)]
+	for i := range em.events {
+		em.events[i] = yaml_event_t{}
+	}
+	e.events = e.events[:cap(e.events)]
+	for i := range e.events {
+		e.events[i] = yaml_event_t{}
+	}
+	e.Reset(nil)
+	encoderPool.Put(e)
+}
@@ -0,0 +1,48 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pooling", func() {
+	It("leaves no state behind between calls", func() {
+		var v interface{}
+		Expect(Unmarshal([]byte("a: &x [1\n"), &v)).NotTo(Succeed())
+		Expect(Unmarshal([]byte("b: *x\n"), &v)).To(MatchError(ContainSubstring("missing anchor")))
+		Expect(Unmarshal([]byte("c: 1\n"), &v)).To(Succeed())
+		Expect(v).To(Equal(map[interface{}]interface{}{"c": int64(1)}))
+
+		_, err := Marshal(make(chan int))
+		Expect(err).To(HaveOccurred())
+		out, err := Marshal(map[string]int{"d": 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("d: 2\n"))
+	})
+
+	It("does not keep large queues", func() {
+		d := NewDecoder(strings.NewReader("a"))
+		d.parser.tokens = make([]yaml_token_t, 0, 2*maxPooledQueue)
+
+		putDecoder(d)
+		for i := 0; i < 10; i++ {
+			Expect(decoderPool.Get()).NotTo(BeIdenticalTo(d))
+		}
+	})
+})
@@ -0,0 +1,124 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// RecordPositions sets whether the Decoder records the positions of the
+// values it decodes, which Positions returns.
+func (d *Decoder) RecordPositions(record bool) {
+	d.recordPositions = record
+}
+
+// Positions returns the positions in the source of the values of the last
+// document decoded, when they are recorded. They are keyed by the path of
+// each value from the root of the document, which is "": the values of
+// mappings are named by their keys as written, whether they decode into
+// struct fields or maps, joined by dots, and elements of sequences are
+// indexed, as in "servers[0].port". A value
+// that is an alias has the position of the alias, and the values within
+// it those of the anchored value.
+func (d *Decoder) Positions() map[string]Position {
+	return d.positions
+}
+
+// startPositions starts recording the positions of a document at the
+// current event, its root value.
+func (d *Decoder) startPositions() {
+	d.positions = nil
+	d.path = d.path[:0]
+	if !d.recordPositions {
+		return
+	}
+	d.positions = make(map[string]Position)
+	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		d.recordPosition()
+	}
+}
+
+// a step of the path of the value being decoded: the key of a mapping
+// value, or the index of a sequence element. Keys that are not strings are
+// formatted only when the path is.
+type decodeStep struct {
+	key   string
+	value reflect.Value
+	index int
+}
+
+// pathString formats the path of the value being decoded.
+func (d *Decoder) pathString() string {
+	var path []byte
+	for _, s := range d.path {
+		switch {
+		case s.index >= 0:
+			path = append(path, '[')
+			path = strconv.AppendInt(path, int64(s.index), 10)
+			path = append(path, ']')
+			continue
+		case len(path) > 0:
+			path = append(path, '.')
+		}
+		if s.value.IsValid() {
+			path = append(path, fmt.Sprint(s.value.Interface())...)
+		} else {
+			path = append(path, s.key...)
+		}
+	}
+	return string(path)
+}
+
+func (d *Decoder) recordPosition() {
+	d.positions[d.pathString()] = Position{
+		Line:   d.event.start_mark.line + 1,
+		Column: d.event.start_mark.column + 1,
+	}
+}
+
+// pushKey extends the path with the key of the value at the current event
+// and records its position. It returns the length of the path to restore
+// with popPath.
+func (d *Decoder) pushKey(key string) int {
+	return d.pushStep(decodeStep{key: key, index: -1})
+}
+
+// pushValue is like pushKey for a key that is not a string.
+func (d *Decoder) pushValue(key reflect.Value) int {
+	if key.Kind() == reflect.String {
+		return d.pushKey(key.String())
+	}
+	return d.pushStep(decodeStep{value: key, index: -1})
+}
+
+// pushIndex is like pushKey for the index of an element of a sequence.
+func (d *Decoder) pushIndex(i int) int {
+	return d.pushStep(decodeStep{index: i})
+}
+
+func (d *Decoder) pushStep(s decodeStep) int {
+	n := len(d.path)
+	d.path = append(d.path, s)
+	if d.positions != nil {
+		d.recordPosition()
+	}
+	return n
+}
+
+func (d *Decoder) popPath(n int) {
+	d.path = d.path[:n]
+}
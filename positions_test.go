@@ -0,0 +1,98 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Positions", func() {
+	type Server struct {
+		Host string
+		Port int `yaml:"port"`
+	}
+	type Base struct {
+		Name string
+	}
+	type Config struct {
+		Base    `yaml:",inline"`
+		Server  Server
+		Mirrors []*Server
+		Labels  map[string]string
+		Extra   interface{}
+	}
+
+	input := `name: demo
+server: &s
+  host: example.com
+  port: 8080
+mirrors:
+  - *s
+  - host: other
+labels: {env: prod}
+extra:
+  - a
+  - {b: 1}
+unknown: 1
+`
+
+	It("records the position of every value by path", func() {
+		d := NewDecoder(strings.NewReader(input))
+		d.RecordPositions(true)
+		var c Config
+		Expect(d.Decode(&c)).To(Succeed())
+
+		Expect(d.Positions()).To(Equal(map[string]Position{
+			"":                {1, 1},
+			"name":            {1, 7},
+			"server":          {2, 9},
+			"server.host":     {3, 9},
+			"server.port":     {4, 9},
+			"mirrors":         {6, 3},
+			"mirrors[0]":      {6, 5},
+			"mirrors[0].host": {3, 9},
+			"mirrors[0].port": {4, 9},
+			"mirrors[1]":      {7, 5},
+			"mirrors[1].host": {7, 11},
+			"labels":          {8, 9},
+			"labels.env":      {8, 15},
+			"extra":           {10, 3},
+			"extra[0]":        {10, 5},
+			"extra[1]":        {11, 5},
+			"extra[1].b":      {11, 9},
+		}))
+	})
+
+	It("records the positions of the last document only", func() {
+		d := NewDecoder(strings.NewReader("a: 1\n---\n[x]\n"))
+		d.RecordPositions(true)
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(d.Positions()).To(HaveKey("a"))
+
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(d.Positions()).To(Equal(map[string]Position{"": {3, 1}, "[0]": {3, 2}}))
+	})
+
+	It("records nothing by default", func() {
+		d := NewDecoder(strings.NewReader(input))
+		var c Config
+		Expect(d.Decode(&c)).To(Succeed())
+		Expect(d.Positions()).To(BeNil())
+	})
+})
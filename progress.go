@@ -0,0 +1,46 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+// Progress returns how far the Decoder has read into its input: the mark
+// just past the last token it scanned, whose Offset is the number of bytes
+// of the input consumed and Line the line being read. A progress bar over
+// a file compares Offset to its size.
+func (d *Decoder) Progress() Mark {
+	return markOf(d.parser.mark)
+}
+
+// OnProgress sets a function that the Decoder calls with its Progress each
+// time it has read at least every more bytes of its input, or nil for
+// none, which is the default. The calls are made while decoding, from the
+// goroutine that decodes.
+func (d *Decoder) OnProgress(every int, f func(Mark)) {
+	if every < 1 {
+		every = 1
+	}
+	d.onProgress = f
+	d.progressEvery = every
+	d.nextProgress = d.parser.mark.offset + every
+}
+
+// reportProgress calls the progress function when the scanner has gone
+// past the next offset to report.
+func (d *Decoder) reportProgress() {
+	if d.parser.mark.offset < d.nextProgress {
+		return
+	}
+	d.nextProgress = d.parser.mark.offset + d.progressEvery
+	d.onProgress(markOf(d.parser.mark))
+}
@@ -0,0 +1,64 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Progress", func() {
+	input := strings.Repeat("- name: item\n  size: 12\n", 1000)
+
+	It("tells how far the input was read", func() {
+		d := NewDecoder(strings.NewReader(input))
+		Expect(d.Progress()).To(Equal(Mark{Line: 1, Column: 1}))
+
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(d.Progress().Offset).To(Equal(len(input)))
+		Expect(d.Progress().Line).To(Equal(2001))
+	})
+
+	It("reports it as the input is read", func() {
+		var marks []Mark
+		d := NewDecoder(strings.NewReader(input))
+		d.OnProgress(5000, func(m Mark) { marks = append(marks, m) })
+
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(marks).To(HaveLen(len(input) / 5000))
+		for i, m := range marks {
+			Expect(m.Offset).To(BeNumerically(">=", (i+1)*5000))
+			Expect(m.Offset).To(BeNumerically("<", (i+1)*5000+30))
+			Expect(m.Line).To(Equal(strings.Count(input[:m.Offset], "\n") + 1))
+		}
+	})
+
+	It("starts over after a reset", func() {
+		var marks []Mark
+		d := NewDecoder(strings.NewReader(input))
+		d.OnProgress(len(input)/2, func(m Mark) { marks = append(marks, m) })
+
+		var v interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		d.Reset(strings.NewReader(input))
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(marks).To(HaveLen(4))
+		Expect(marks[2].Offset).To(BeNumerically("<", marks[1].Offset))
+	})
+})
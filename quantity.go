@@ -0,0 +1,192 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ByteSize is a number of bytes, decoded from a plain number or from a
+// number with a unit, such as 512, 10Mi, 1.5GB, 4 KiB or -1Ki. The units
+// are the decimal k, M, G, T, P and E, with K for k, and the binary Ki,
+// Mi, Gi, Ti, Pi and Ei, each optionally followed by B, and B alone. A
+// size with a fraction has to come to whole bytes, and sizes that do not
+// fit in an int64 are errors.
+//
+// A ByteSize is encoded with the largest unit it is a whole multiple of,
+// such as 10Mi or 1500M, and as a plain number when it is a multiple of
+// none.
+type ByteSize int64
+
+var byteSizePattern = regexp.MustCompile(`^(-?[0-9]+(?:\.[0-9]+)?) ?([A-Za-z]*)$`)
+
+var byteUnits = map[string]int64{"": 1, "B": 1}
+
+// the suffixes of the units, from the largest
+var decimalUnits = []string{"E", "P", "T", "G", "M", "k"}
+var binaryUnits = []string{"Ei", "Pi", "Ti", "Gi", "Mi", "Ki"}
+
+func init() {
+	for i := range decimalUnits {
+		decimal := int64(math.Pow(1000, float64(len(decimalUnits)-i)))
+		binary := int64(1) << (10 * uint(len(binaryUnits)-i))
+		for _, suffix := range []string{"", "B"} {
+			byteUnits[decimalUnits[i]+suffix] = decimal
+			byteUnits[binaryUnits[i]+suffix] = binary
+		}
+	}
+	byteUnits["K"], byteUnits["KB"] = 1000, 1000
+}
+
+// ParseByteSize parses a size such as 10Mi or 1.5GB, as ByteSize values
+// are decoded.
+func ParseByteSize(s string) (ByteSize, error) {
+	size, err := parseBytes(s, "byte size")
+	if err != nil {
+		return 0, err
+	}
+	n, err := wholeBytes(s, "byte size", size)
+	return ByteSize(n), err
+}
+
+// parseBytes parses a number of bytes with a unit, the kind of quantity s
+// is naming it in errors.
+func parseBytes(s, kind string) (*big.Rat, error) {
+	m := byteSizePattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("Invalid %s '%s'", kind, s)
+	}
+	unit, ok := byteUnits[m[2]]
+	if !ok {
+		return nil, fmt.Errorf("Invalid %s '%s': unknown unit '%s'", kind, s, m[2])
+	}
+
+	size, _ := new(big.Rat).SetString(m[1])
+	return size.Mul(size, new(big.Rat).SetInt64(unit)), nil
+}
+
+// wholeBytes returns the quantity parsed from s as an int64.
+func wholeBytes(s, kind string, size *big.Rat) (int64, error) {
+	if !size.IsInt() {
+		return 0, fmt.Errorf("Invalid %s '%s': not a whole number of bytes", kind, s)
+	}
+	if n := size.Num(); n.IsInt64() {
+		return n.Int64(), nil
+	}
+	return 0, fmt.Errorf("Invalid %s '%s': overflows an int64", kind, s)
+}
+
+func (s ByteSize) String() string {
+	best, unit := "", int64(1)
+	for _, suffix := range append(binaryUnits, decimalUnits...) {
+		if u := byteUnits[suffix]; u > unit && s != 0 && int64(s)%u == 0 {
+			best, unit = suffix, u
+		}
+	}
+	return strconv.FormatInt(int64(s)/unit, 10) + best
+}
+
+func (s *ByteSize) UnmarshalYAMLNode(n *Node) error {
+	if n.Kind != ScalarNode {
+		return fmt.Errorf("Invalid byte size, a %s at line %d, column %d", n.Kind, n.Line, n.Column)
+	}
+	size, err := ParseByteSize(n.Value)
+	if err != nil {
+		return fmt.Errorf("%s at line %d, column %d", err, n.Line, n.Column)
+	}
+	*s = size
+	return nil
+}
+
+func (s ByteSize) MarshalYAML() (string, interface{}, error) {
+	if str := s.String(); str != strconv.FormatInt(int64(s), 10) {
+		return "", str, nil
+	}
+	return "", int64(s), nil
+}
+
+// A ByteRate is a number of bytes per second, decoded from a size as
+// ByteSize reads them followed by /s, /m or /h, such as 10Mi/s, 1.5GB/m or
+// 4 KiB/s, or from a plain number of bytes per second. A rate has to come
+// to whole bytes per second. It is encoded per second, with the unit a
+// ByteSize of the same number of bytes is encoded with, such as 10Mi/s.
+type ByteRate int64
+
+var rateUnits = map[string]int64{"s": 1, "m": 60, "h": 3600}
+
+// ParseByteRate parses a rate such as 10Mi/s or 1.5GB/m, as ByteRate
+// values are decoded.
+func ParseByteRate(s string) (ByteRate, error) {
+	size, per := s, "s"
+	if i := strings.LastIndexByte(s, '/'); i >= 0 {
+		size, per = s[:i], s[i+1:]
+	}
+	seconds, ok := rateUnits[per]
+	if !ok {
+		return 0, fmt.Errorf("Invalid byte rate '%s': unknown time unit '%s'", s, per)
+	}
+
+	rate, err := parseBytes(size, "byte rate")
+	if err != nil {
+		return 0, fmt.Errorf("Invalid byte rate '%s'", s)
+	}
+	rate.Quo(rate, new(big.Rat).SetInt64(seconds))
+	n, err := wholeBytes(s, "byte rate", rate)
+	return ByteRate(n), err
+}
+
+func (r ByteRate) String() string {
+	return ByteSize(r).String() + "/s"
+}
+
+func (r *ByteRate) UnmarshalYAMLNode(n *Node) error {
+	if n.Kind != ScalarNode {
+		return fmt.Errorf("Invalid byte rate, a %s at line %d, column %d", n.Kind, n.Line, n.Column)
+	}
+	rate, err := ParseByteRate(n.Value)
+	if err != nil {
+		return fmt.Errorf("%s at line %d, column %d", err, n.Line, n.Column)
+	}
+	*r = rate
+	return nil
+}
+
+func (r ByteRate) MarshalYAML() (string, interface{}, error) {
+	return "", r.String(), nil
+}
+
+// A Duration is a time.Duration decoded from and encoded to the form of
+// time.ParseDuration, such as 250ms or 1h30m, rather than a number of
+// nanoseconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAMLNode(n *Node) error {
+	duration, err := time.ParseDuration(n.Value)
+	if n.Kind != ScalarNode || err != nil {
+		return fmt.Errorf("Invalid duration '%s' at line %d, column %d", n.Value, n.Line, n.Column)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (string, interface{}, error) {
+	return "", time.Duration(d).String(), nil
+}
@@ -0,0 +1,137 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Quantities", func() {
+	type limits struct {
+		Memory  ByteSize
+		Disk    *ByteSize
+		Timeout Duration
+	}
+
+	It("parses sizes with units", func() {
+		for s, size := range map[string]ByteSize{
+			"512":     512,
+			"0":       0,
+			"10Mi":    10 << 20,
+			"4 KiB":   4096,
+			"1.5GB":   1500000000,
+			"2k":      2000,
+			"3K":      3000,
+			"0.5Ki":   512,
+			"8Ei":     0,
+			"7Ei":     7 << 60,
+			"100B":    100,
+			"1.25MiB": 1310720,
+			"-1Ki":    -1024,
+			"-8Ei":    math.MinInt64,
+		} {
+			parsed, err := ParseByteSize(s)
+			if s == "8Ei" {
+				Expect(err).To(MatchError("Invalid byte size '8Ei': overflows an int64"))
+				continue
+			}
+			Expect(err).NotTo(HaveOccurred(), s)
+			Expect(parsed).To(Equal(size), s)
+		}
+
+		_, err := ParseByteSize("1.5B")
+		Expect(err).To(MatchError("Invalid byte size '1.5B': not a whole number of bytes"))
+		_, err = ParseByteSize("10Mb/s")
+		Expect(err).To(MatchError("Invalid byte size '10Mb/s'"))
+		_, err = ParseByteSize("10Xi")
+		Expect(err).To(MatchError("Invalid byte size '10Xi': unknown unit 'Xi'"))
+	})
+
+	It("decodes and encodes sizes and durations", func() {
+		var v limits
+		Expect(Unmarshal([]byte("memory: 1.5Gi\ndisk: 20GB\ntimeout: 1m30s\n"), &v)).To(Succeed())
+		Expect(v.Memory).To(Equal(ByteSize(3 << 29)))
+		Expect(*v.Disk).To(Equal(ByteSize(20000000000)))
+		Expect(time.Duration(v.Timeout)).To(Equal(90 * time.Second))
+
+		out, err := Marshal(v)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("Memory: 1536Mi\nDisk: 20G\nTimeout: 1m30s\n"))
+
+		disk := ByteSize(1000001)
+		v.Disk = &disk
+		out, err = Marshal(v)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(ContainSubstring("Disk: 1000001\n"))
+	})
+
+	It("reads back negative sizes", func() {
+		for _, size := range []ByteSize{-1024, -1500, -7, math.MinInt64} {
+			out, err := Marshal(size)
+			Expect(err).NotTo(HaveOccurred())
+			var back ByteSize
+			Expect(Unmarshal(out, &back)).To(Succeed(), string(out))
+			Expect(back).To(Equal(size))
+		}
+		Expect(ByteSize(-1024).String()).To(Equal("-1Ki"))
+	})
+
+	It("parses rates with units", func() {
+		for s, rate := range map[string]ByteRate{
+			"10Mi/s":  10 << 20,
+			"1.5GB/m": 25000000,
+			"4 KiB/s": 4096,
+			"3600/h":  1,
+			"512":     512,
+		} {
+			parsed, err := ParseByteRate(s)
+			Expect(err).NotTo(HaveOccurred(), s)
+			Expect(parsed).To(Equal(rate), s)
+		}
+
+		_, err := ParseByteRate("1Ki/m")
+		Expect(err).To(MatchError("Invalid byte rate '1Ki/m': not a whole number of bytes"))
+		_, err = ParseByteRate("1Mi/d")
+		Expect(err).To(MatchError("Invalid byte rate '1Mi/d': unknown time unit 'd'"))
+		_, err = ParseByteRate("fast/s")
+		Expect(err).To(MatchError("Invalid byte rate 'fast/s'"))
+	})
+
+	It("decodes and encodes rates", func() {
+		var v struct{ Ingress, Egress ByteRate }
+		Expect(Unmarshal([]byte("ingress: 10Mi/s\negress: 60k/m\n"), &v)).To(Succeed())
+		Expect(v.Ingress).To(Equal(ByteRate(10 << 20)))
+		Expect(v.Egress).To(Equal(ByteRate(1000)))
+
+		out, err := Marshal(v)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("Ingress: 10Mi/s\nEgress: 1k/s\n"))
+
+		Expect(Unmarshal([]byte("ingress: {}\n"), &v)).To(MatchError(
+			"ingress: Invalid byte rate, a mapping at line 1, column 10"))
+	})
+
+	It("reports where invalid quantities are", func() {
+		var v limits
+		Expect(Unmarshal([]byte("memory: 1\ntimeout: soon\n"), &v)).To(MatchError(
+			"timeout: Invalid duration 'soon' at line 2, column 10"))
+		Expect(Unmarshal([]byte("memory: [1]\n"), &v)).To(MatchError(
+			"memory: Invalid byte size, a sequence at line 1, column 9"))
+	})
+})
@@ -0,0 +1,101 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "regexp"
+
+// A Schema is a way of resolving plain scalars, under which some strings
+// cannot be written plain without being read back as another type.
+type Schema int
+
+const (
+	// DefaultSchema resolves scalars as the Decoder does: besides the
+	// types of the core schema, it reads the booleans of YAML 1.1, such as
+	// yes and off, and timestamps.
+	DefaultSchema Schema = iota
+
+	// CoreSchema is the core schema of YAML 1.2.
+	CoreSchema
+
+	// JSONSchema is the JSON schema of YAML 1.2, in which every plain
+	// scalar that is not null, a boolean or a number is invalid.
+	JSONSchema
+
+	// FailsafeSchema reads every scalar as a string.
+	FailsafeSchema
+)
+
+var (
+	coreNull  = regexp.MustCompile(`^(?:~|null|Null|NULL|)$`)
+	coreBool  = regexp.MustCompile(`^(?:true|True|TRUE|false|False|FALSE)$`)
+	coreInt   = regexp.MustCompile(`^(?:[-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+	coreFloat = regexp.MustCompile(`^(?:[-+]?(?:\.[0-9]+|[0-9]+(?:\.[0-9]*)?)(?:[eE][-+]?[0-9]+)?|[-+]?\.(?:inf|Inf|INF)|\.(?:nan|NaN|NAN))$`)
+
+	jsonInt   = regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)$`)
+	jsonFloat = regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)(?:\.[0-9]*)?(?:[eE][-+]?[0-9]+)?$`)
+)
+
+// NeedsQuoting reports whether s has to be quoted to be read as the same
+// string under schema wherever it is written: when it would resolve to
+// another type, or when it is not a valid plain scalar, which includes the
+// empty string, strings starting with an indicator such as "-" or "&",
+// holding ": ", " #" or flow indicators, with leading or trailing spaces,
+// line breaks or characters that are not printable.
+func NeedsQuoting(s string, schema Schema) bool {
+	var emitter yaml_emitter_t
+	emitter.unicode = true
+	yaml_emitter_analyze_scalar(&emitter, []byte(s))
+	if !emitter.scalar_data.flow_plain_allowed {
+		return true
+	}
+
+	return plainTag(s, schema) != yaml_STR_TAG
+}
+
+// plainTag returns the tag the plain scalar s resolves to under schema,
+// which is empty when it is invalid under the JSON schema.
+func plainTag(s string, schema Schema) string {
+	switch schema {
+	case CoreSchema:
+		switch {
+		case coreNull.MatchString(s):
+			return yaml_NULL_TAG
+		case coreBool.MatchString(s):
+			return yaml_BOOL_TAG
+		case coreInt.MatchString(s):
+			return yaml_INT_TAG
+		case coreFloat.MatchString(s):
+			return yaml_FLOAT_TAG
+		}
+		return yaml_STR_TAG
+	case JSONSchema:
+		switch {
+		case s == "null":
+			return yaml_NULL_TAG
+		case s == "true" || s == "false":
+			return yaml_BOOL_TAG
+		case jsonInt.MatchString(s):
+			return yaml_INT_TAG
+		case jsonFloat.MatchString(s):
+			return yaml_FLOAT_TAG
+		}
+		return ""
+	case FailsafeSchema:
+		return yaml_STR_TAG
+	}
+
+	tag, _ := resolveInterface(yaml_event_t{implicit: true, value: []byte(s)}, false)
+	return tag
+}
@@ -0,0 +1,54 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NeedsQuoting", func() {
+	It("quotes strings that are not valid plain scalars", func() {
+		for _, s := range []string{"", " x", "x ", "a: b", "a #b", "- x", "&a", "*a", "!t", "[x]", "a,b", "{", "x\ny", "---", "\x01", "'q'"} {
+			for _, schema := range []Schema{DefaultSchema, CoreSchema, FailsafeSchema} {
+				Expect(NeedsQuoting(s, schema)).To(BeTrue(), "%q under schema %d", s, schema)
+			}
+		}
+	})
+
+	It("quotes strings that resolve to other types", func() {
+		for _, s := range []string{"12", "0x1F", "1.5e3", ".inf", "~", "null", "true", "FALSE"} {
+			Expect(NeedsQuoting(s, DefaultSchema)).To(BeTrue(), s)
+			Expect(NeedsQuoting(s, CoreSchema)).To(BeTrue(), s)
+			Expect(NeedsQuoting(s, FailsafeSchema)).To(BeFalse(), s)
+		}
+
+		for _, s := range []string{"yes", "Off", "2001-01-01"} {
+			Expect(NeedsQuoting(s, DefaultSchema)).To(BeTrue(), s)
+			Expect(NeedsQuoting(s, CoreSchema)).To(BeFalse(), s)
+		}
+	})
+
+	It("leaves other strings plain", func() {
+		for _, s := range []string{"text", "two words", "a/b", "x-y", "1.2.3", "ünïcode", "a#b"} {
+			Expect(NeedsQuoting(s, DefaultSchema)).To(BeFalse(), s)
+			Expect(NeedsQuoting(s, CoreSchema)).To(BeFalse(), s)
+		}
+	})
+
+	It("quotes every string under the JSON schema", func() {
+		Expect(NeedsQuoting("text", JSONSchema)).To(BeTrue())
+	})
+})
@@ -0,0 +1,177 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+var rawYAMLType = reflect.TypeOf(RawYAML(nil))
+
+// RawYAML is YAML text that the encoder writes as it is in place of a value,
+// re-indented to the position of the value. The text must be a single
+// value: a scalar, or a flow or block collection, without directives or
+// document markers. Comments and styles in it pass through unchanged.
+//
+// Decoding into a RawYAML captures the text of a value instead, to be
+// decoded later with Unmarshal once its type is known, as for the values
+// of a map[string]RawYAML of plugin settings. Aliases to anchors outside
+// the value are replaced by the values they refer to.
+type RawYAML []byte
+
+// RawMessage is RawYAML under the name encoding/json gives it.
+type RawMessage = RawYAML
+
+// checkRawYAML reports whether raw holds a single YAML value, and whether
+// that value is a block collection, which starts on a line of its own.
+func checkRawYAML(raw []byte) (block bool, err error) {
+	var parser yaml_parser_t
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_string(&parser, raw)
+
+	notSingle := fmt.Errorf("RawYAML is not a single YAML value: %q", raw)
+
+	var event yaml_event_t
+	depth, nodes := 0, 0
+	for {
+		if !yaml_parser_parse(&parser, &event) {
+			return false, newParserError(&parser)
+		}
+
+		switch event.event_type {
+		case yaml_STREAM_END_EVENT:
+			if nodes != 1 {
+				return false, notSingle
+			}
+			return block, nil
+		case yaml_DOCUMENT_START_EVENT:
+			if !event.implicit || event.version_directive != nil || len(event.tag_directives) > 0 {
+				return false, notSingle
+			}
+		case yaml_DOCUMENT_END_EVENT:
+			if !event.implicit {
+				return false, notSingle
+			}
+		case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+			if depth == 0 {
+				nodes++
+				block = event.style != yaml_style_t(yaml_FLOW_SEQUENCE_STYLE) &&
+					event.style != yaml_style_t(yaml_FLOW_MAPPING_STYLE)
+			}
+			depth++
+		case yaml_SEQUENCE_END_EVENT, yaml_MAPPING_END_EVENT:
+			depth--
+		case yaml_SCALAR_EVENT, yaml_ALIAS_EVENT:
+			if depth == 0 {
+				nodes++
+			}
+		}
+	}
+}
+
+// emitRaw writes the text of a RawYAML value.
+func (e *Encoder) emitRaw(raw []byte) {
+	if e.key {
+		panic(fmt.Errorf("Cannot encode RawYAML as a key of %s", e.where()))
+	}
+
+	block, err := checkRawYAML(raw)
+	if err != nil {
+		panic(err)
+	}
+
+	value := bytes.TrimRight(raw, "\r\n")
+	if block {
+		value = append([]byte{'\n'}, value...)
+	}
+
+	yaml_scalar_event_initialize(&e.event, e.takeAnchor(), nil, value, true, true, yaml_RAW_SCALAR_STYLE)
+	e.emit()
+}
+
+// rawTarget returns the RawYAML that v refers to, allocating any nil
+// pointers on the way, or nil when v cannot hold one.
+func rawTarget(v reflect.Value) *RawYAML {
+	for {
+		if v.Type() == rawYAMLType {
+			if !v.CanAddr() {
+				return nil
+			}
+			return v.Addr().Interface().(*RawYAML)
+		}
+
+		if v.Kind() != reflect.Ptr {
+			return nil
+		}
+
+		if v.Type().Elem() != rawYAMLType && v.Type().Elem().Kind() != reflect.Ptr {
+			return nil
+		}
+
+		if v.IsNil() {
+			if !v.CanSet() {
+				return nil
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+}
+
+// raw returns the text of the value at the current event.
+func (d *Decoder) raw() RawYAML {
+	n := *selfContained(d.node(), map[string]bool{})
+	if n.Kind == ScalarNode && n.Tag == "" && n.Value == "" && n.Style == PlainStyle {
+		// an empty value would leave nothing to splice back
+		n = Node{Kind: ScalarNode, Value: "null"}
+	}
+	// tags are written in full, as the directives are not part of the value
+	n.TagDirectives = nil
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	if err := e.Encode(n); err != nil {
+		d.error(err)
+	}
+	return RawYAML(buf.Bytes())
+}
+
+// selfContained returns n with the aliases to anchors it does not define
+// replaced by the nodes they refer to, so that its text stands alone.
+// defined holds the anchors defined so far, in document order.
+func selfContained(n *Node, defined map[string]bool) *Node {
+	if n.Kind == AliasNode {
+		if defined[n.Value] || n.Alias == nil {
+			return n
+		}
+		n = n.Alias
+	}
+
+	if n.Anchor != "" {
+		defined[n.Anchor] = true
+	}
+	if len(n.Content) == 0 {
+		return n
+	}
+
+	c := *n
+	c.Content = make([]*Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = selfContained(child, defined)
+	}
+	return &c
+}
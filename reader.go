@@ -39,17 +39,21 @@ const (
 	BOM_UTF8    = "\xef\xbb\xbf"
 	BOM_UTF16LE = "\xff\xfe"
 	BOM_UTF16BE = "\xfe\xff"
+	BOM_UTF32LE = "\xff\xfe\x00\x00"
+	BOM_UTF32BE = "\x00\x00\xfe\xff"
 )
 
 /*
  * Determine the input stream encoding by checking the BOM symbol. If no BOM is
- * found, the UTF-8 encoding is assumed. Return 1 on success, 0 on failure.
+ * found, the encoding is guessed from the pattern of NUL bytes in the first
+ * two characters (see section 5.2 of the YAML 1.2 spec), UTF-8 being assumed
+ * otherwise. UTF-32 streams are rejected. Return 1 on success, 0 on failure.
  */
 
 func yaml_parser_determine_encoding(parser *yaml_parser_t) bool {
 	/* Ensure that we had enough bytes in the raw buffer. */
 	for !parser.eof &&
-		len(parser.raw_buffer)-parser.raw_buffer_pos < 3 {
+		len(parser.raw_buffer)-parser.raw_buffer_pos < 4 {
 		if !yaml_parser_update_raw_buffer(parser) {
 			return false
 		}
@@ -59,21 +63,40 @@ func yaml_parser_determine_encoding(parser *yaml_parser_t) bool {
 	raw := parser.raw_buffer
 	pos := parser.raw_buffer_pos
 	remaining := len(raw) - pos
-	if remaining >= 2 &&
+	if remaining >= 4 &&
+		((raw[pos] == BOM_UTF32LE[0] && raw[pos+1] == BOM_UTF32LE[1] &&
+			raw[pos+2] == BOM_UTF32LE[2] && raw[pos+3] == BOM_UTF32LE[3]) ||
+			(raw[pos] == BOM_UTF32BE[0] && raw[pos+1] == BOM_UTF32BE[1] &&
+				raw[pos+2] == BOM_UTF32BE[2] && raw[pos+3] == BOM_UTF32BE[3]) ||
+			(raw[pos] == 0 && raw[pos+1] == 0 && raw[pos+2] == 0 && raw[pos+3] != 0) ||
+			(raw[pos] != 0 && raw[pos+1] == 0 && raw[pos+2] == 0 && raw[pos+3] == 0)) {
+		return yaml_parser_set_reader_error(parser,
+			"UTF-32 input is not supported", parser.offset, -1)
+	} else if remaining >= 2 &&
 		raw[pos] == BOM_UTF16LE[0] && raw[pos+1] == BOM_UTF16LE[1] {
 		parser.encoding = yaml_UTF16LE_ENCODING
 		parser.raw_buffer_pos += 2
 		parser.offset += 2
+		parser.mark.offset += 2
 	} else if remaining >= 2 &&
 		raw[pos] == BOM_UTF16BE[0] && raw[pos+1] == BOM_UTF16BE[1] {
 		parser.encoding = yaml_UTF16BE_ENCODING
 		parser.raw_buffer_pos += 2
 		parser.offset += 2
+		parser.mark.offset += 2
 	} else if remaining >= 3 &&
 		raw[pos] == BOM_UTF8[0] && raw[pos+1] == BOM_UTF8[1] && raw[pos+2] == BOM_UTF8[2] {
 		parser.encoding = yaml_UTF8_ENCODING
+		parser.has_bom = true
 		parser.raw_buffer_pos += 3
 		parser.offset += 3
+		parser.mark.offset += 3
+	} else if remaining >= 4 && raw[pos] == 0 && raw[pos+1] != 0 &&
+		raw[pos+2] == 0 && raw[pos+3] != 0 {
+		parser.encoding = yaml_UTF16BE_ENCODING
+	} else if remaining >= 4 && raw[pos] != 0 && raw[pos+1] == 0 &&
+		raw[pos+2] != 0 && raw[pos+3] == 0 {
+		parser.encoding = yaml_UTF16LE_ENCODING
 	} else {
 		parser.encoding = yaml_UTF8_ENCODING
 	}
@@ -110,6 +133,9 @@ func yaml_parser_update_raw_buffer(parser *yaml_parser_t) bool {
 	size_read, err := parser.read_handler(parser,
 		parser.raw_buffer[len(parser.raw_buffer):cap(parser.raw_buffer)])
 	parser.raw_buffer = parser.raw_buffer[:len(parser.raw_buffer)+size_read]
+	if parser.keep_source {
+		parser.source = append(parser.source, parser.raw_buffer[len(parser.raw_buffer)-size_read:]...)
+	}
 
 	if err == io.EOF {
 		parser.eof = true
@@ -128,6 +154,32 @@ func yaml_parser_update_raw_buffer(parser *yaml_parser_t) bool {
  * The length is supposed to be significantly less that the buffer size.
  */
 
+/*
+ * The ASCII characters allowed in a stream, which UTF-8 encodes as they
+ * are.
+ */
+var ascii_allowed [256]bool
+
+func init() {
+	ascii_allowed['\t'] = true
+	ascii_allowed['\n'] = true
+	ascii_allowed['\r'] = true
+	for b := 0x20; b <= 0x7E; b++ {
+		ascii_allowed[b] = true
+	}
+}
+
+/*
+ * Count the allowed ASCII characters at the start of raw.
+ */
+func ascii_run(raw []byte) int {
+	n := 0
+	for n < len(raw) && ascii_allowed[raw[n]] {
+		n++
+	}
+	return n
+}
+
 func yaml_parser_update_buffer(parser *yaml_parser_t, length int) bool {
 	/* Read handler must be set. */
 	if parser.read_handler == nil {
@@ -154,6 +206,12 @@ func yaml_parser_update_buffer(parser *yaml_parser_t, length int) bool {
 		}
 	}
 
+	/* Keep the characters read past before they are overwritten. */
+	if parser.keep_text {
+		parser.text = append(parser.text, parser.buffer[parser.text_pos:parser.buffer_pos]...)
+		parser.text_pos = 0
+	}
+
 	/* Move the unread characters to the beginning of the buffer. */
 	buffer_end := len(parser.buffer)
 	if 0 < parser.buffer_pos &&
@@ -183,6 +241,19 @@ func yaml_parser_update_buffer(parser *yaml_parser_t, length int) bool {
 
 		/* Decode the raw buffer. */
 		for parser.raw_buffer_pos != len(parser.raw_buffer) {
+			/* Copy a run of allowed ASCII characters at once. */
+			if parser.encoding == yaml_UTF8_ENCODING {
+				run := ascii_run(parser.raw_buffer[parser.raw_buffer_pos:])
+				if run > 0 {
+					copy(parser.buffer[buffer_end:], parser.raw_buffer[parser.raw_buffer_pos:parser.raw_buffer_pos+run])
+					buffer_end += run
+					parser.raw_buffer_pos += run
+					parser.offset += run
+					parser.unread += run
+					continue
+				}
+			}
+
 			var value rune
 			var w int
 
@@ -297,7 +368,7 @@ func yaml_parser_update_buffer(parser *yaml_parser_t, length int) bool {
 				if parser.encoding == yaml_UTF16LE_ENCODING {
 					low, high = 0, 1
 				} else {
-					high, low = 1, 0
+					low, high = 1, 0
 				}
 
 				/*
@@ -427,24 +498,27 @@ func yaml_parser_update_buffer(parser *yaml_parser_t, length int) bool {
 			/* 0000 0000-0000 007F . 0xxxxxxx */
 			if value <= 0x7F {
 				parser.buffer[buffer_end] = byte(value)
+				buffer_end++
 			} else if value <= 0x7FF {
 				/* 0000 0080-0000 07FF . 110xxxxx 10xxxxxx */
 				parser.buffer[buffer_end] = byte(0xC0 + (value >> 6))
 				parser.buffer[buffer_end+1] = byte(0x80 + (value & 0x3F))
+				buffer_end += 2
 			} else if value <= 0xFFFF {
 				/* 0000 0800-0000 FFFF . 1110xxxx 10xxxxxx 10xxxxxx */
 				parser.buffer[buffer_end] = byte(0xE0 + (value >> 12))
 				parser.buffer[buffer_end+1] = byte(0x80 + ((value >> 6) & 0x3F))
 				parser.buffer[buffer_end+2] = byte(0x80 + (value & 0x3F))
+				buffer_end += 3
 			} else {
 				/* 0001 0000-0010 FFFF . 11110xxx 10xxxxxx 10xxxxxx 10xxxxxx */
 				parser.buffer[buffer_end] = byte(0xF0 + (value >> 18))
 				parser.buffer[buffer_end+1] = byte(0x80 + ((value >> 12) & 0x3F))
 				parser.buffer[buffer_end+2] = byte(0x80 + ((value >> 6) & 0x3F))
 				parser.buffer[buffer_end+3] = byte(0x80 + (value & 0x3F))
+				buffer_end += 4
 			}
 
-			buffer_end += w
 			parser.unread++
 		}
 
@@ -168,6 +168,11 @@ var _ = Describe("Reader", func() {
 			{"bom (utf-8)", "\xef\xbb\xbfHi is \xd0\x9f\xd1\x80\xd0\xb8\xd0\xb2\xd0\xb5\xd1\x82!", true},
 			{"bom (utf-16-le)", "\xff\xfeH\x00i\x00 \x00i\x00s\x00 \x00\x1f\x04@\x04" + "8\x04" + "2\x04" + "5\x04" + "B\x04!", true},
 			{"bom (utf-16-be)", "\xfe\xff\x00H\x00i\x00 \x00i\x00s\x00 \x04\x1f\x04@\x04" + "8\x04" + "2\x04" + "5\x04" + "B!", true},
+			{"no bom (utf-16-le)", "H\x00i\x00 \x00\x1f\x04!", true},
+			{"no bom (utf-16-be)", "\x00H\x00i\x00 \x04\x1f!", true},
+			{"bom (utf-32-le)", "\xff\xfe\x00\x00H\x00\x00\x00!", false},
+			{"bom (utf-32-be)", "\x00\x00\xfe\xff\x00\x00\x00H!", false},
+			{"no bom (utf-32-le)", "H\x00\x00\x00i\x00\x00\x00!", false},
 		}
 
 		check_bom := func(tc test_case) {
@@ -195,6 +200,18 @@ var _ = Describe("Reader", func() {
 
 	})
 
+	Context("UTF16 characters", func() {
+		It("converts them to UTF-8", func() {
+			input := []byte("\xff\xfea\x00\xe9\x00\xe5\x65\x3d\xd8\x00\xde")
+			parser := yaml_parser_t{}
+			yaml_parser_initialize(&parser)
+			yaml_parser_set_input_string(&parser, input)
+			Expect(yaml_parser_update_buffer(&parser, 5)).To(BeTrue())
+			Expect(string(parser.buffer)).To(Equal("aé日😀\x00"))
+			yaml_parser_delete(&parser)
+		})
+	})
+
 	Context("Long UTF8", func() {
 		It("parses properly", func() {
 			buffer := make([]byte, 0, 3+LONG*2)
@@ -0,0 +1,279 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// A Recording holds the events of a stream parsed once, so that it can be
+// decoded any number of times without parsing it again, such as a base
+// document instantiated for each of many requests. It is safe to replay a
+// Recording from many goroutines at once.
+type Recording struct {
+	events []yaml_event_t
+}
+
+// Record parses the stream read from r into a Recording. The options that
+// apply to parsing, such as WithLimits, are those of the Decoder configured
+// by opts; the others apply when the Recording is replayed.
+func Record(r io.Reader, opts ...Option) (rec *Recording, err error) {
+	d := NewDecoder(r, opts...)
+	rec = &Recording{}
+	for {
+		var event yaml_event_t
+		if !yaml_parser_parse(&d.parser, &event) {
+			return nil, newParserError(&d.parser)
+		}
+		rec.events = append(rec.events, event)
+		if event.event_type == yaml_STREAM_END_EVENT {
+			return rec, nil
+		}
+	}
+}
+
+// Replay returns a Decoder reading the recorded stream, configured by
+// opts, as one reading the source of the Recording would.
+func (rec *Recording) Replay(opts ...Option) *Decoder {
+	d := NewDecoder(bytes.NewReader(nil), opts...)
+	d.recorded = rec.events
+	return d
+}
+
+// recordingMagic starts the binary form of a Recording, with the version
+// of its format.
+const recordingMagic = "cyr\x01"
+
+var errBadRecording = errors.New("yaml: invalid recording")
+
+// The flags of an event in the binary form of a Recording, telling its
+// booleans and which of its optional parts follow.
+const (
+	recordImplicit = 1 << iota
+	recordQuotedImplicit
+	recordVersion
+	recordTagDirectives
+	recordAnchor
+	recordTag
+	recordValue
+	recordComments
+)
+
+// MarshalBinary returns the Recording in a compact binary form, which
+// UnmarshalBinary reads back, so that it can be cached outside of the
+// process. Each event takes a few bytes besides its strings.
+func (rec *Recording) MarshalBinary() ([]byte, error) {
+	w := recordingWriter{b: []byte(recordingMagic)}
+	for i := range rec.events {
+		e := &rec.events[i]
+		flags := 0
+		set := func(flag int, on bool) {
+			if on {
+				flags |= flag
+			}
+		}
+		set(recordImplicit, e.implicit)
+		set(recordQuotedImplicit, e.quoted_implicit)
+		set(recordVersion, e.version_directive != nil)
+		set(recordTagDirectives, len(e.tag_directives) > 0)
+		set(recordAnchor, len(e.anchor) > 0)
+		set(recordTag, len(e.tag) > 0)
+		set(recordValue, len(e.value) > 0)
+		set(recordComments, len(e.head_comment)+len(e.line_comment)+len(e.foot_comment) > 0)
+
+		w.b = append(w.b, byte(e.event_type), byte(flags), byte(e.style)|byte(e.encoding)<<4)
+		w.mark(e.start_mark)
+		w.mark(e.end_mark)
+		if flags&recordVersion != 0 {
+			w.uint(e.version_directive.major)
+			w.uint(e.version_directive.minor)
+		}
+		if flags&recordTagDirectives != 0 {
+			w.uint(len(e.tag_directives))
+			for _, t := range e.tag_directives {
+				w.bytes(t.handle)
+				w.bytes(t.prefix)
+			}
+		}
+		if flags&recordAnchor != 0 {
+			w.bytes(e.anchor)
+		}
+		if flags&recordTag != 0 {
+			w.bytes(e.tag)
+		}
+		if flags&recordValue != 0 {
+			w.bytes(e.value)
+		}
+		if flags&recordComments != 0 {
+			w.bytes(e.head_comment)
+			w.bytes(e.line_comment)
+			w.bytes(e.foot_comment)
+		}
+	}
+	return w.b, nil
+}
+
+// UnmarshalBinary sets the Recording to the one of data, the binary form
+// returned by MarshalBinary.
+func (rec *Recording) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(recordingMagic)) {
+		return errBadRecording
+	}
+	r := recordingReader{data: data[len(recordingMagic):]}
+
+	var events []yaml_event_t
+	for len(r.data) > 0 && !r.bad {
+		if len(r.data) < 3 {
+			return errBadRecording
+		}
+		e := yaml_event_t{
+			event_type: yaml_event_type_t(r.data[0]),
+			style:      yaml_style_t(r.data[2] & 0xF),
+			encoding:   yaml_encoding_t(r.data[2] >> 4),
+		}
+		flags := int(r.data[1])
+		r.data = r.data[3:]
+
+		e.implicit = flags&recordImplicit != 0
+		e.quoted_implicit = flags&recordQuotedImplicit != 0
+		e.start_mark = r.mark()
+		e.end_mark = r.mark()
+		if flags&recordVersion != 0 {
+			e.version_directive = &yaml_version_directive_t{major: r.uint(), minor: r.uint()}
+		}
+		if flags&recordTagDirectives != 0 {
+			for n := r.uint(); n > 0 && !r.bad; n-- {
+				e.tag_directives = append(e.tag_directives, yaml_tag_directive_t{handle: r.bytes(), prefix: r.bytes()})
+			}
+		}
+		if flags&recordAnchor != 0 {
+			e.anchor = r.bytes()
+		}
+		if flags&recordTag != 0 {
+			e.tag = r.bytes()
+		}
+		if flags&recordValue != 0 {
+			e.value = r.bytes()
+		}
+		if flags&recordComments != 0 {
+			e.head_comment = r.bytes()
+			e.line_comment = r.bytes()
+			e.foot_comment = r.bytes()
+		}
+		events = append(events, e)
+	}
+
+	// a stream is whole, and a decoder reads nothing past its end
+	if r.bad || len(events) < 2 || events[0].event_type != yaml_STREAM_START_EVENT ||
+		events[len(events)-1].event_type != yaml_STREAM_END_EVENT {
+		return errBadRecording
+	}
+	for _, e := range events {
+		if e.event_type < yaml_STREAM_START_EVENT || e.event_type > yaml_MAPPING_END_EVENT {
+			return errBadRecording
+		}
+	}
+	rec.events = events
+	return nil
+}
+
+// A recordingWriter appends the parts of events to the binary form of a
+// Recording. Marks are written relative to the previous one, which they
+// are close to.
+type recordingWriter struct {
+	b    []byte
+	last YAML_mark_t
+	buf  [binary.MaxVarintLen64]byte
+}
+
+func (w *recordingWriter) uint(n int) {
+	w.b = append(w.b, w.buf[:binary.PutUvarint(w.buf[:], uint64(n))]...)
+}
+
+func (w *recordingWriter) int(n int) {
+	w.b = append(w.b, w.buf[:binary.PutVarint(w.buf[:], int64(n))]...)
+}
+
+func (w *recordingWriter) bytes(s []byte) {
+	w.uint(len(s))
+	w.b = append(w.b, s...)
+}
+
+func (w *recordingWriter) mark(m YAML_mark_t) {
+	w.int(m.index - w.last.index)
+	w.int(m.line - w.last.line)
+	w.uint(m.column)
+	w.int(m.offset - m.index)
+	w.last = m
+}
+
+// A recordingReader reads the parts of events written by a
+// recordingWriter. Once the data runs out or is invalid, bad is set and
+// the parts read are zero.
+type recordingReader struct {
+	data []byte
+	last YAML_mark_t
+	bad  bool
+}
+
+func (r *recordingReader) check(n int64, w int) int {
+	if w <= 0 || n < math.MinInt32 || n > math.MaxInt32 {
+		r.bad = true
+		return 0
+	}
+	r.data = r.data[w:]
+	return int(n)
+}
+
+func (r *recordingReader) uint() int {
+	n, w := binary.Uvarint(r.data)
+	if n > math.MaxInt32 {
+		r.bad = true
+		return 0
+	}
+	return r.check(int64(n), w)
+}
+
+func (r *recordingReader) int() int {
+	return r.check(binary.Varint(r.data))
+}
+
+func (r *recordingReader) bytes() []byte {
+	n := r.uint()
+	if n > len(r.data) {
+		r.bad = true
+	}
+	if r.bad || n == 0 {
+		return nil
+	}
+	s := r.data[:n:n]
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *recordingReader) mark() YAML_mark_t {
+	m := YAML_mark_t{
+		index:  r.last.index + r.int(),
+		line:   r.last.line + r.int(),
+		column: r.uint(),
+	}
+	m.offset = m.index + r.int()
+	r.last = m
+	return m
+}
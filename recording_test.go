@@ -0,0 +1,138 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Recordings", func() {
+	type service struct {
+		Name     string            `yaml:"name"`
+		Replicas int               `yaml:"replicas"`
+		Labels   map[string]string `yaml:"labels"`
+		Ports    []int             `yaml:"ports"`
+	}
+
+	input := `%YAML 1.1
+---
+name: web
+replicas: 3
+labels: &labels {tier: front, env: prod}
+ports: [80, 443]
+...
+%TAG !e! tag:yaml.org,2002:
+--- !e!map
+name: !e!str worker
+labels: *labels
+`
+
+	record := func() *Recording {
+		rec, err := Record(strings.NewReader(input))
+		Expect(err).NotTo(HaveOccurred())
+		return rec
+	}
+
+	decodeAll := func(d *Decoder) []service {
+		d.EmptyDocuments(EmptyIsEOF)
+		var all []service
+		for {
+			var s service
+			err := d.Decode(&s)
+			if err != nil {
+				Expect(err.Error()).To(Equal("EOF"))
+				return all
+			}
+			all = append(all, s)
+		}
+	}
+
+	It("decodes as the source does", func() {
+		want := decodeAll(NewDecoder(strings.NewReader(input)))
+		Expect(want).To(HaveLen(2))
+
+		rec := record()
+		Expect(decodeAll(rec.Replay())).To(Equal(want))
+		Expect(decodeAll(rec.Replay())).To(Equal(want))
+	})
+
+	It("applies the options of the replay", func() {
+		var v map[string]interface{}
+		rec, err := Record(strings.NewReader("a: ~\nb: 010\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rec.Replay(WithNulls("null")).Decode(&v)).To(Succeed())
+		Expect(v).To(Equal(map[string]interface{}{"a": "~", "b": int64(8)}))
+	})
+
+	It("applies the limits of the recording", func() {
+		_, err := Record(strings.NewReader("a: [[[1]]]"), WithLimits(Limits{MaxFlowNesting: 2}))
+		Expect(err).To(BeAssignableToTypeOf(&LimitError{}))
+	})
+
+	It("reports errors at their place in the source", func() {
+		rec, err := Record(strings.NewReader("a: 1\nb: x\n"))
+		Expect(err).NotTo(HaveOccurred())
+		var v struct{ A, B int }
+		Expect(rec.Replay().Decode(&v)).To(MatchError(ContainSubstring("line 1, column 3")))
+	})
+
+	It("can be replayed concurrently", func() {
+		rec := record()
+		want := decodeAll(rec.Replay())
+
+		var wg sync.WaitGroup
+		results := make([][]service, 8)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				results[i] = decodeAll(rec.Replay())
+			}(i)
+		}
+		wg.Wait()
+		for _, r := range results {
+			Expect(r).To(Equal(want))
+		}
+	})
+
+	It("round-trips through its binary form", func() {
+		rec := record()
+		data, err := rec.MarshalBinary()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(data)).To(BeNumerically("<", 4*len(input)))
+
+		var back Recording
+		Expect(back.UnmarshalBinary(data)).To(Succeed())
+		Expect(back.events).To(Equal(rec.events))
+		Expect(decodeAll(back.Replay())).To(Equal(decodeAll(rec.Replay())))
+	})
+
+	It("rejects invalid binary forms", func() {
+		data, err := record().MarshalBinary()
+		Expect(err).NotTo(HaveOccurred())
+
+		var back Recording
+		Expect(back.UnmarshalBinary(nil)).To(MatchError("yaml: invalid recording"))
+		Expect(back.UnmarshalBinary([]byte("abcd"))).To(MatchError("yaml: invalid recording"))
+		for n := 5; n < len(data); n += 7 {
+			Expect(back.UnmarshalBinary(data[:n])).To(MatchError("yaml: invalid recording"), "%d bytes", n)
+		}
+	})
+})
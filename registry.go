@@ -0,0 +1,100 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var typeRegistry struct {
+	sync.RWMutex
+	types map[string]reflect.Type
+	tags  map[reflect.Type]string
+}
+
+// RegisterType associates a tag such as "!Widget" with the type t.
+//
+// Values carrying the tag are decoded into a new t even when the
+// destination is an interface that t (or *t) implements, and values of type
+// t are encoded with the tag. Registering a pointer type registers its
+// element type. A later registration of the same tag or type replaces the
+// earlier one.
+func RegisterType(tag string, t reflect.Type) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	typeRegistry.Lock()
+	defer typeRegistry.Unlock()
+
+	if typeRegistry.types == nil {
+		typeRegistry.types = make(map[string]reflect.Type)
+		typeRegistry.tags = make(map[reflect.Type]string)
+	}
+	if old, ok := typeRegistry.types[longTag(tag)]; ok {
+		delete(typeRegistry.tags, old)
+	}
+	if old, ok := typeRegistry.tags[t]; ok {
+		delete(typeRegistry.types, longTag(old))
+	}
+	typeRegistry.types[longTag(tag)] = t
+	typeRegistry.tags[t] = tag
+}
+
+// registeredType returns the type registered for the tag of a parsed event.
+func registeredType(tag string) (reflect.Type, bool) {
+	typeRegistry.RLock()
+	t, ok := typeRegistry.types[tag]
+	typeRegistry.RUnlock()
+	return t, ok
+}
+
+// registeredTag returns the tag registered for t, if any.
+func registeredTag(t reflect.Type) string {
+	typeRegistry.RLock()
+	tag := typeRegistry.tags[t]
+	typeRegistry.RUnlock()
+	return tag
+}
+
+// longTag expands the !! shorthand into the form reported by the parser.
+func longTag(tag string) string {
+	if strings.HasPrefix(tag, "!!") {
+		return "tag:yaml.org,2002:" + tag[2:]
+	}
+	return tag
+}
+
+// registeredValue returns a pointer to a new value of the type registered
+// for the tag of the current event when it can be stored in the interface
+// v, or an invalid value otherwise.
+func (d *Decoder) registeredValue(v reflect.Value) reflect.Value {
+	if len(d.event.tag) == 0 || d.event.event_type == yaml_ALIAS_EVENT {
+		return reflect.Value{}
+	}
+
+	t, ok := registeredType(string(d.event.tag))
+	if !ok || v.Kind() != reflect.Interface {
+		return reflect.Value{}
+	}
+
+	if !t.Implements(v.Type()) && !reflect.PtrTo(t).Implements(v.Type()) {
+		return reflect.Value{}
+	}
+
+	return reflect.New(t)
+}
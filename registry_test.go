@@ -0,0 +1,93 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type registryPlugin interface {
+	Kind() string
+}
+
+type registryWidget struct {
+	Size int `yaml:"size"`
+}
+
+func (w registryWidget) Kind() string { return "widget" }
+
+type registryGadget struct {
+	Name string `yaml:"name"`
+}
+
+func (g *registryGadget) Kind() string { return "gadget" }
+
+type registryCelsius float64
+
+var _ = Describe("RegisterType", func() {
+	BeforeEach(func() {
+		RegisterType("!Widget", reflect.TypeOf(registryWidget{}))
+		RegisterType("!Gadget", reflect.TypeOf(&registryGadget{}))
+		RegisterType("!!celsius", reflect.TypeOf(registryCelsius(0)))
+	})
+
+	type config struct {
+		Plugins []registryPlugin `yaml:"plugins"`
+		Main    registryPlugin   `yaml:"main"`
+	}
+
+	doc := `plugins:
+- !Widget
+  size: 3
+- !Gadget
+  name: g
+main: !Widget
+  size: 1
+`
+
+	It("decodes tagged values into interface fields", func() {
+		var c config
+		Expect(Unmarshal([]byte(doc), &c)).To(Succeed())
+		Expect(c.Plugins).To(Equal([]registryPlugin{registryWidget{Size: 3}, &registryGadget{Name: "g"}}))
+		Expect(c.Main).To(Equal(registryWidget{Size: 1}))
+	})
+
+	It("decodes tagged values into interface{}", func() {
+		var v map[string]interface{}
+		Expect(Unmarshal([]byte("t: !!celsius 21.5\nw: !Widget {size: 2}\n"), &v)).To(Succeed())
+		Expect(v["t"]).To(Equal(registryCelsius(21.5)))
+		Expect(v["w"]).To(Equal(registryWidget{Size: 2}))
+	})
+
+	It("encodes registered types with their tag", func() {
+		out, err := Marshal(config{
+			Plugins: []registryPlugin{registryWidget{Size: 3}, &registryGadget{Name: "g"}},
+			Main:    registryWidget{Size: 1},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(doc))
+	})
+
+	It("ignores tags that the destination cannot hold", func() {
+		var v struct {
+			Main error `yaml:"main"`
+		}
+		err := Unmarshal([]byte("main: !Widget\n  size: 1\n"), &v)
+		Expect(err).To(HaveOccurred())
+	})
+})
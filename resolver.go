@@ -17,7 +17,6 @@ package candiedyaml
 import (
 	"bytes"
 	"encoding/base64"
-	"fmt"
 	"math"
 	"reflect"
 	"regexp"
@@ -36,6 +35,9 @@ var signs = []byte{'-', '+'}
 var nulls = []byte{'~', 'n', 'N'}
 var bools = []byte{'t', 'T', 'f', 'F', 'y', 'Y', 'n', 'N', 'o', 'O'}
 
+// TimestampPattern matches the plain scalars that resolve to timestamps.
+var TimestampPattern = regexp.MustCompile("^([0-9][0-9][0-9][0-9])-([0-9][0-9]?)-([0-9][0-9]?)(?:(?:[Tt]|[ \t]+)([0-9][0-9]?):([0-9][0-9]):([0-9][0-9])(?:\\.([0-9]*))?(?:[ \t]*(?:Z|([-+][0-9][0-9]?)(?::([0-9][0-9])?)?))?)?$")
+
 var timestamp_regexp *regexp.Regexp
 var ymd_regexp *regexp.Regexp
 
@@ -56,14 +58,30 @@ func init() {
 	null_values["Null"] = true
 	null_values["NULL"] = true
 
-	timestamp_regexp = regexp.MustCompile("^([0-9][0-9][0-9][0-9])-([0-9][0-9]?)-([0-9][0-9]?)(?:(?:[Tt]|[ \t]+)([0-9][0-9]?):([0-9][0-9]):([0-9][0-9])(?:\\.([0-9]*))?(?:[ \t]*(?:Z|([-+][0-9][0-9]?)(?::([0-9][0-9])?)?))?)?$")
+	timestamp_regexp = TimestampPattern
 	ymd_regexp = regexp.MustCompile("^([0-9][0-9][0-9][0-9])-([0-9][0-9]?)-([0-9][0-9]?)$")
 }
 
+// isNull reports whether a scalar event is a null: one of the null values,
+// or an empty plain scalar, without a !!str tag, or a plain scalar given
+// NullTag by the Decoder.
+func isNull(event yaml_event_t) bool {
+	if event.implicit && string(event.tag) == yaml_NULL_TAG {
+		return true
+	}
+	return (null_values[string(event.value)] || len(event.value) == 0 && event.implicit) &&
+		string(event.tag) != yaml_STR_TAG
+}
+
 func resolve(event yaml_event_t, v reflect.Value, useNumber bool) (string, error) {
-	val := string(event.value)
+	return resolveText(event, string(event.value), v, useNumber)
+}
 
-	if null_values[val] && string(event.tag) != yaml_STR_TAG {
+// resolveText is resolve for a value already converted to a string, which
+// may share the memory of the event.
+func resolveText(event yaml_event_t, val string, v reflect.Value, useNumber bool) (string, error) {
+	// an empty scalar is a null, which still decodes to an empty string
+	if isNull(event) && (val != "" || v.Kind() != reflect.String) {
 		v.Set(reflect.Zero(v.Type()))
 		return yaml_NULL_TAG, nil
 	}
@@ -71,12 +89,16 @@ func resolve(event yaml_event_t, v reflect.Value, useNumber bool) (string, error
 	switch v.Kind() {
 	case reflect.String:
 		if useNumber && v.Type() == numberType {
-			tag, i := resolveInterface(event, useNumber)
+			tag, i := resolveValue(event, val, useNumber)
 			if n, ok := i.(Number); ok {
 				v.Set(reflect.ValueOf(n))
 				return tag, nil
 			}
-			return "", fmt.Errorf("Not a number: '%s' at %s", event.value, event.start_mark)
+			return "", decodingError(event.start_mark, "Not a number: '%s'", event.value)
+		}
+
+		if v.Type() == intStringType {
+			return resolve_int_string(val, v, event)
 		}
 
 		return resolve_string(val, v, event)
@@ -89,7 +111,7 @@ func resolve(event yaml_event_t, v reflect.Value, useNumber bool) (string, error
 	case reflect.Float32, reflect.Float64:
 		return resolve_float(val, v, useNumber, event)
 	case reflect.Interface:
-		_, i := resolveInterface(event, useNumber)
+		_, i := resolveValue(event, val, useNumber)
 		if i != nil {
 			v.Set(reflect.ValueOf(i))
 		} else {
@@ -100,7 +122,7 @@ func resolve(event yaml_event_t, v reflect.Value, useNumber bool) (string, error
 		return resolve_time(val, v, event)
 	case reflect.Slice:
 		if v.Type() != byteSliceType {
-			return "", fmt.Errorf("Cannot resolve %s into %s at %s", val, v.String(), event.start_mark)
+			return "", decodingError(event.start_mark, "Cannot resolve %s into %s", val, v.String())
 		}
 		b, err := decode_binary(event.value, event)
 		if err != nil {
@@ -109,7 +131,7 @@ func resolve(event yaml_event_t, v reflect.Value, useNumber bool) (string, error
 
 		v.Set(reflect.ValueOf(b))
 	default:
-		return "", fmt.Errorf("Unknown resolution for '%s' using %s at %s", val, v.String(), event.start_mark)
+		return "", decodingError(event.start_mark, "Unknown resolution for '%s' using %s", val, v.String())
 	}
 
 	return yaml_STR_TAG, nil
@@ -128,7 +150,7 @@ func decode_binary(value []byte, event yaml_event_t) ([]byte, error) {
 	b := make([]byte, base64.StdEncoding.DecodedLen(len(value)))
 	n, err := base64.StdEncoding.Decode(b, value)
 	if err != nil {
-		return nil, fmt.Errorf("Invalid base64 text: '%s' at %s", string(b), event.start_mark)
+		return nil, decodingError(event.start_mark, "Invalid base64 text: '%s'", string(b))
 	}
 	return b[:n], nil
 }
@@ -147,10 +169,23 @@ func resolve_string(val string, v reflect.Value, event yaml_event_t) (string, er
 	return yaml_STR_TAG, nil
 }
 
+func resolve_int_string(val string, v reflect.Value, event yaml_event_t) (string, error) {
+	var i int64
+	var u uint64
+	if _, err := resolve_int(val, reflect.ValueOf(&i).Elem(), false, event); err != nil {
+		if _, err := resolve_uint(val, reflect.ValueOf(&u).Elem(), false, event); err != nil {
+			return "", decodingError(event.start_mark, "Invalid integer: '%s'", val)
+		}
+	}
+
+	v.SetString(val)
+	return yaml_INT_TAG, nil
+}
+
 func resolve_bool(val string, v reflect.Value, event yaml_event_t) (string, error) {
 	b, found := bool_values[strings.ToLower(val)]
 	if !found {
-		return "", fmt.Errorf("Invalid boolean: '%s' at %s", val, event.start_mark)
+		return "", decodingError(event.start_mark, "Invalid boolean: '%s'", val)
 	}
 
 	v.SetBool(b)
@@ -164,6 +199,10 @@ func resolve_int(val string, v reflect.Value, useNumber bool, event yaml_event_t
 
 	isNumberValue := v.Type() == numberType
 
+	if val == "" {
+		return "", decodingError(event.start_mark, "Invalid integer: '%s'", original)
+	}
+
 	sign := int64(1)
 	if val[0] == '-' {
 		sign = -1
@@ -190,7 +229,7 @@ func resolve_int(val string, v reflect.Value, useNumber bool, event yaml_event_t
 
 	value, err := strconv.ParseUint(val, base, 64)
 	if err != nil {
-		return "", fmt.Errorf("Invalid integer: '%s' at %s", original, event.start_mark)
+		return "", decodingError(event.start_mark, "Invalid integer: '%s'", original)
 	}
 
 	var val64 int64
@@ -202,14 +241,14 @@ func resolve_int(val string, v reflect.Value, useNumber bool, event yaml_event_t
 	} else if sign == -1 && value == uint64(math.MaxInt64)+1 {
 		val64 = math.MinInt64
 	} else {
-		return "", fmt.Errorf("Invalid integer: '%s' at %s", original, event.start_mark)
+		return "", decodingError(event.start_mark, "Invalid integer: '%s'", original)
 	}
 
 	if isNumberValue {
 		v.SetString(strconv.FormatInt(val64, 10))
 	} else {
 		if v.OverflowInt(val64) {
-			return "", fmt.Errorf("Invalid integer: '%s' at %s", original, event.start_mark)
+			return "", decodingError(event.start_mark, "Invalid integer: '%s'", original)
 		}
 		v.SetInt(val64)
 	}
@@ -224,8 +263,12 @@ func resolve_uint(val string, v reflect.Value, useNumber bool, event yaml_event_
 
 	isNumberValue := v.Type() == numberType
 
+	if val == "" {
+		return "", decodingError(event.start_mark, "Invalid unsigned integer: '%s'", original)
+	}
+
 	if val[0] == '-' {
-		return "", fmt.Errorf("Unsigned int with negative value: '%s' at %s", original, event.start_mark)
+		return "", decodingError(event.start_mark, "Unsigned int with negative value: '%s'", original)
 	}
 
 	if val[0] == '+' {
@@ -250,14 +293,14 @@ func resolve_uint(val string, v reflect.Value, useNumber bool, event yaml_event_
 
 	value, err := strconv.ParseUint(val, base, 64)
 	if err != nil {
-		return "", fmt.Errorf("Invalid unsigned integer: '%s' at %s", val, event.start_mark)
+		return "", decodingError(event.start_mark, "Invalid unsigned integer: '%s'", val)
 	}
 
 	if isNumberValue {
 		v.SetString(strconv.FormatUint(value, 10))
 	} else {
 		if v.OverflowUint(value) {
-			return "", fmt.Errorf("Invalid unsigned integer: '%s' at %s", val, event.start_mark)
+			return "", decodingError(event.start_mark, "Invalid unsigned integer: '%s'", val)
 		}
 
 		v.SetUint(value)
@@ -276,6 +319,10 @@ func resolve_float(val string, v reflect.Value, useNumber bool, event yaml_event
 		typeBits = v.Type().Bits()
 	}
 
+	if val == "" {
+		return "", decodingError(event.start_mark, "Invalid float: '%s'", val)
+	}
+
 	sign := 1
 	if val[0] == '-' {
 		sign = -1
@@ -295,7 +342,7 @@ func resolve_float(val string, v reflect.Value, useNumber bool, event yaml_event
 		value *= float64(sign)
 
 		if err != nil {
-			return "", fmt.Errorf("Invalid float: '%s' at %s", val, event.start_mark)
+			return "", decodingError(event.start_mark, "Invalid float: '%s'", val)
 		}
 	}
 
@@ -303,7 +350,7 @@ func resolve_float(val string, v reflect.Value, useNumber bool, event yaml_event
 		v.SetString(strconv.FormatFloat(value, 'g', -1, typeBits))
 	} else {
 		if v.OverflowFloat(value) {
-			return "", fmt.Errorf("Invalid float: '%s' at %s", val, event.start_mark)
+			return "", decodingError(event.start_mark, "Invalid float: '%s'", val)
 		}
 
 		v.SetFloat(value)
@@ -323,7 +370,7 @@ func resolve_time(val string, v reflect.Value, event yaml_event_t) (string, erro
 	} else {
 		matches = timestamp_regexp.FindStringSubmatch(val)
 		if len(matches) == 0 {
-			return "", fmt.Errorf("Invalid timestamp: '%s' at %s", val, event.start_mark)
+			return "", decodingError(event.start_mark, "Invalid timestamp: '%s'", val)
 		}
 
 		year, _ := strconv.Atoi(matches[1])
@@ -362,16 +409,54 @@ func resolve_time(val string, v reflect.Value, event yaml_event_t) (string, erro
 	return "", nil
 }
 
+// resolveNumber resolves val into a new value of the type of zero, or a
+// Number when useNumber is set.
+func resolveNumber(val string, zero interface{}, useNumber bool, event yaml_event_t,
+	resolve func(string, reflect.Value, bool, yaml_event_t) (string, error)) (interface{}, error) {
+	v := reflect.New(reflect.TypeOf(zero)).Elem()
+	if useNumber {
+		v = reflect.New(numberType).Elem()
+	}
+	if _, err := resolve(val, v, useNumber, event); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
 func resolveInterface(event yaml_event_t, useNumber bool) (string, interface{}) {
-	val := string(event.value)
+	return resolveValue(event, string(event.value), useNumber)
+}
+
+// resolveValue is resolveInterface for a value already converted to a
+// string, so that each scalar is copied only once.
+func resolveValue(event yaml_event_t, val string, useNumber bool) (string, interface{}) {
 	if len(event.tag) == 0 && !event.implicit {
 		return "", val
 	}
 
-	if len(val) == 0 {
+	if len(val) == 0 && string(event.tag) != yaml_STR_TAG || event.implicit && string(event.tag) == yaml_NULL_TAG {
 		return yaml_NULL_TAG, nil
 	}
 
+	// an explicit tag of the core schema holds when the value matches it
+	switch string(event.tag) {
+	case yaml_STR_TAG:
+		return yaml_STR_TAG, val
+	case yaml_BOOL_TAG:
+		b := false
+		if _, err := resolve_bool(val, reflect.ValueOf(&b).Elem(), event); err == nil {
+			return yaml_BOOL_TAG, b
+		}
+	case yaml_INT_TAG:
+		if i, err := resolveNumber(val, int64(0), useNumber, event, resolve_int); err == nil {
+			return yaml_INT_TAG, i
+		}
+	case yaml_FLOAT_TAG:
+		if f, err := resolveNumber(val, float64(0), useNumber, event, resolve_float); err == nil {
+			return yaml_FLOAT_TAG, f
+		}
+	}
+
 	var result interface{}
 
 	sign := false
@@ -447,3 +532,76 @@ func resolveInterface(event yaml_event_t, useNumber bool) (string, interface{})
 
 	return yaml_STR_TAG, val
 }
+
+// The tags that scalars resolve to.
+const (
+	NullTag      = yaml_NULL_TAG
+	BoolTag      = yaml_BOOL_TAG
+	StrTag       = yaml_STR_TAG
+	IntTag       = yaml_INT_TAG
+	FloatTag     = yaml_FLOAT_TAG
+	TimestampTag = yaml_TIMESTAMP_TAG
+	BinaryTag    = yaml_BINARY_TAG
+)
+
+// The tags of collections, and that of the merge key.
+const (
+	SeqTag   = yaml_SEQ_TAG
+	MapTag   = yaml_MAP_TAG
+	SetTag   = yaml_SET_TAG
+	OmapTag  = yaml_OMAP_TAG
+	PairsTag = yaml_PAIRS_TAG
+	MergeTag = yaml_MERGE_TAG
+)
+
+// Resolve returns the tag a scalar resolves to and the value it decodes to
+// in an interface{}, as the decoder does. The tag of the scalar is its
+// explicit tag, in full or with the "!!" shorthand, "" for a plain scalar
+// without one, or "!" for a quoted one. A tag registered with RegisterType
+// resolves to itself and a value of the registered type; other unknown
+// tags are an error, as with ErrorOnUnknownTags.
+func Resolve(tag string, value string) (resolvedTag string, goValue interface{}, err error) {
+	defer recovery(&err)
+
+	event := yaml_event_t{
+		event_type: yaml_SCALAR_EVENT,
+		value:      []byte(value),
+		implicit:   tag == "",
+	}
+	if tag != "" && tag != "!" {
+		event.tag = []byte(longTag(tag))
+	}
+
+	// the document end stands in for the event following the scalar
+	d := &Decoder{anchors: make(map[string][]yaml_event_t)}
+	d.replay_events = []yaml_event_t{event, {event_type: yaml_DOCUMENT_END_EVENT}}
+	d.nextEvent()
+	goValue = d.valueInterface()
+
+	switch goValue.(type) {
+	case nil:
+		resolvedTag = yaml_NULL_TAG
+	case bool:
+		resolvedTag = yaml_BOOL_TAG
+	case int64:
+		resolvedTag = yaml_INT_TAG
+	case float64:
+		resolvedTag = yaml_FLOAT_TAG
+	case string:
+		resolvedTag = yaml_STR_TAG
+	case []byte:
+		resolvedTag = yaml_BINARY_TAG
+	case time.Time:
+		resolvedTag = yaml_TIMESTAMP_TAG
+	default:
+		resolvedTag = string(event.tag)
+	}
+	return resolvedTag, goValue, nil
+}
+
+// DetectScalarType returns the tag a plain scalar without an explicit tag
+// resolves to: NullTag, BoolTag, IntTag, FloatTag, TimestampTag or StrTag.
+func DetectScalarType(value string) string {
+	tag, _, _ := Resolve("", value)
+	return tag
+}
@@ -677,3 +677,58 @@ var _ = Describe("Resolver", func() {
 		})
 	})
 })
+
+var _ = Describe("Resolve", func() {
+	It("resolves plain scalars by their value", func() {
+		for value, expected := range map[string]interface{}{
+			"12":   int64(12),
+			"1.5":  1.5,
+			"yes":  true,
+			"text": "text",
+		} {
+			_, v, err := Resolve("", value)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(expected))
+		}
+
+		tag, v, err := Resolve("", "2001-01-01")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tag).To(Equal(TimestampTag))
+		Expect(v).To(Equal(time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("resolves quoted scalars as strings", func() {
+		tag, v, err := Resolve("!", "12")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tag).To(Equal(StrTag))
+		Expect(v).To(Equal("12"))
+	})
+
+	It("resolves explicit tags", func() {
+		tag, v, err := Resolve("!!binary", "aGk=")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tag).To(Equal(BinaryTag))
+		Expect(v).To(Equal([]byte("hi")))
+
+		tag, _, err = Resolve("tag:yaml.org,2002:null", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tag).To(Equal(NullTag))
+
+		_, v, err = Resolve("", "~")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(BeNil())
+	})
+
+	It("fails on unknown tags", func() {
+		_, _, err := Resolve("!unknown", "x")
+		Expect(err).To(MatchError(ContainSubstring("Unknown tag '!unknown'")))
+	})
+
+	It("detects the type of plain scalars", func() {
+		Expect(DetectScalarType("0o17")).To(Equal(IntTag))
+		Expect(DetectScalarType(".inf")).To(Equal(FloatTag))
+		Expect(DetectScalarType("Off")).To(Equal(BoolTag))
+		Expect(DetectScalarType("")).To(Equal(NullTag))
+		Expect(DetectScalarType("1.2.3")).To(Equal(StrTag))
+	})
+})
@@ -16,6 +16,7 @@ package candiedyaml
 
 import (
 	"bytes"
+	"strconv"
 )
 
 /*
@@ -510,25 +511,65 @@ func cache(parser *yaml_parser_t, length int) bool {
  * Advance the buffer pointer.
  */
 func skip(parser *yaml_parser_t) {
+	w := width(parser.buffer[parser.buffer_pos])
 	parser.mark.index++
 	parser.mark.column++
+	parser.mark.offset += input_width(parser, w)
 	parser.unread--
-	parser.buffer_pos += width(parser.buffer[parser.buffer_pos])
+	parser.buffer_pos += w
+}
+
+/*
+ * The number of bytes of the input taken by a character of w bytes in the
+ * buffer, which differ when the input is UTF-16.
+ */
+func input_width(parser *yaml_parser_t, w int) int {
+	if parser.encoding != yaml_UTF16LE_ENCODING && parser.encoding != yaml_UTF16BE_ENCODING {
+		return w
+	}
+	if w == 4 {
+		return 4
+	}
+	return 2
+}
+
+/*
+ * Count the style of a line break of the input, and record that of the first.
+ */
+
+func note_break(parser *yaml_parser_t) {
+	brk := yaml_ANY_BREAK
+	switch {
+	case is_crlf_at(parser.buffer, parser.buffer_pos):
+		brk = yaml_CRLN_BREAK
+	case parser.buffer[parser.buffer_pos] == '\r':
+		brk = yaml_CR_BREAK
+	case parser.buffer[parser.buffer_pos] == '\n':
+		brk = yaml_LN_BREAK
+	}
+	parser.break_counts[brk]++
+	if parser.line_break == yaml_ANY_BREAK {
+		parser.line_break = brk
+	}
 }
 
 func skip_line(parser *yaml_parser_t) {
+	note_break(parser)
 	if is_crlf_at(parser.buffer, parser.buffer_pos) {
 		parser.mark.index += 2
 		parser.mark.column = 0
 		parser.mark.line++
+		parser.mark.offset += 2 * input_width(parser, 1)
 		parser.unread -= 2
 		parser.buffer_pos += 2
 	} else if is_break_at(parser.buffer, parser.buffer_pos) {
+		w := width(parser.buffer[parser.buffer_pos])
 		parser.mark.index++
 		parser.mark.column = 0
 		parser.mark.line++
+		parser.mark.offset += input_width(parser, w)
 		parser.unread--
-		parser.buffer_pos += width(parser.buffer[parser.buffer_pos])
+		parser.buffer_pos += w
 	}
 }
 
@@ -541,7 +582,7 @@ func read(parser *yaml_parser_t, s []byte) []byte {
 	if w == 0 {
 		panic("invalid character sequence")
 	}
-	if len(s) == 0 {
+	if cap(s) == 0 {
 		s = make([]byte, 0, 32)
 	}
 	if w == 1 && len(s)+w <= cap(s) {
@@ -554,14 +595,81 @@ func read(parser *yaml_parser_t, s []byte) []byte {
 	}
 	parser.mark.index++
 	parser.mark.column++
+	parser.mark.offset += input_width(parser, w)
 	parser.unread--
 	return s
 }
 
+/*
+ * The ASCII characters that cannot end a plain scalar or start a comment
+ * when they follow a non-blank character of it, in the block and in the
+ * flow context. A plain scalar leaves the last unread character alone, as
+ * the checks of the next character look ahead of it.
+ */
+var plain_block, plain_flow [256]bool
+
+func init() {
+	for b := 0x21; b < 0x7F; b++ {
+		plain_block[b] = b != ':'
+		plain_flow[b] = plain_block[b] && bytes.IndexByte([]byte(",?[]{}"), byte(b)) < 0
+	}
+}
+
+/*
+ * The ASCII characters of the text of a comment.
+ */
+var comment_text [256]bool
+
+func init() {
+	comment_text['\t'] = true
+	for b := 0x20; b < 0x7F; b++ {
+		comment_text[b] = true
+	}
+}
+
+/*
+ * Count the characters from the current one on that are in table, up to
+ * max of them, so that runs of ASCII characters are handled at once. The
+ * characters of a run are single bytes.
+ */
+func ascii_span(parser *yaml_parser_t, table *[256]bool, max int) int {
+	buf := parser.buffer[parser.buffer_pos:]
+	if max < len(buf) {
+		buf = buf[:max]
+	}
+	n := 0
+	for n < len(buf) && table[buf[n]] {
+		n++
+	}
+	return n
+}
+
+/*
+ * Copy a run of n ASCII characters to a string buffer and advance
+ * pointers.
+ */
+func read_span(parser *yaml_parser_t, s []byte, n int) []byte {
+	s = append(s, parser.buffer[parser.buffer_pos:parser.buffer_pos+n]...)
+	skip_span(parser, n)
+	return s
+}
+
+/*
+ * Skip a run of n ASCII characters.
+ */
+func skip_span(parser *yaml_parser_t, n int) {
+	parser.buffer_pos += n
+	parser.mark.index += n
+	parser.mark.column += n
+	parser.mark.offset += n * input_width(parser, 1)
+	parser.unread -= n
+}
+
 /*
  * Copy a line break character to a string buffer and advance pointers.
  */
 func read_line(parser *yaml_parser_t, s []byte) []byte {
+	note_break(parser)
 	buf := parser.buffer
 	pos := parser.buffer_pos
 	if buf[pos] == '\r' && buf[pos+1] == '\n' {
@@ -569,6 +677,7 @@ func read_line(parser *yaml_parser_t, s []byte) []byte {
 		s = append(s, '\n')
 		parser.buffer_pos += 2
 		parser.mark.index++
+		parser.mark.offset += input_width(parser, 1)
 		parser.unread--
 	} else if buf[pos] == '\r' || buf[pos] == '\n' {
 		/* CR|LF . LF */
@@ -590,6 +699,7 @@ func read_line(parser *yaml_parser_t, s []byte) []byte {
 	parser.mark.index++
 	parser.mark.column = 0
 	parser.mark.line++
+	parser.mark.offset += input_width(parser, width(buf[pos]))
 	parser.unread--
 	return s
 }
@@ -653,6 +763,28 @@ func yaml_parser_set_scanner_tag_error(parser *yaml_parser_t, directive bool, co
 	return yaml_parser_set_scanner_error(parser, context, context_mark, "did not find URI escaped octet")
 }
 
+// yaml_parser_set_limit_error fails with the limit of the parser exceeded
+// by the input starting at mark.
+func yaml_parser_set_limit_error(parser *yaml_parser_t, limit string, max int, mark YAML_mark_t) bool {
+	parser.error = yaml_LIMIT_ERROR
+	parser.context = limit
+	parser.context_mark = mark
+	parser.problem = "exceeded the limit of " + strconv.Itoa(max)
+	parser.problem_mark = parser.mark
+	parser.problem_value = max
+
+	return false
+}
+
+// yaml_parser_check_scalar_length fails when the scalar starting at
+// start_mark is longer than the parser allows.
+func yaml_parser_check_scalar_length(parser *yaml_parser_t, start_mark YAML_mark_t) bool {
+	if parser.max_scalar_length > 0 && parser.mark.index-start_mark.index > parser.max_scalar_length {
+		return yaml_parser_set_limit_error(parser, "MaxScalarLength", parser.max_scalar_length, start_mark)
+	}
+	return true
+}
+
 /*
  * Ensure that the tokens queue contains at least one token which can be
  * returned to the Parser.
@@ -976,10 +1108,12 @@ func yaml_parser_save_simple_key(parser *yaml_parser_t) bool {
 
 	/*
 	 * A simple key is required only when it is the first token in the current
-	 * line.  Therefore it is always allowed.  But we add a check anyway.
+	 * line, where it is always allowed, except after a flow collection that
+	 * ends back at the indentation of the block, which is not valid YAML.
 	 */
 	if required && !parser.simple_key_allowed {
-		panic("impossible") /* Impossible. */
+		return yaml_parser_set_scanner_error(parser, "while scanning a simple key",
+			parser.mark, "found a simple key where none is allowed")
 	}
 
 	/*
@@ -998,6 +1132,18 @@ func yaml_parser_save_simple_key(parser *yaml_parser_t) bool {
 			return false
 		}
 
+		if parser.max_simple_keys > 0 {
+			possible := 0
+			for i := range parser.simple_keys {
+				if parser.simple_keys[i].possible {
+					possible++
+				}
+			}
+			if possible >= parser.max_simple_keys {
+				return yaml_parser_set_limit_error(parser, "MaxSimpleKeys", parser.max_simple_keys, parser.mark)
+			}
+		}
+
 		parser.simple_keys[len(parser.simple_keys)-1] = simple_key
 	}
 
@@ -1033,6 +1179,10 @@ func yaml_parser_remove_simple_key(parser *yaml_parser_t) bool {
  */
 
 func yaml_parser_increase_flow_level(parser *yaml_parser_t) bool {
+	if parser.max_flow_level > 0 && parser.flow_level >= parser.max_flow_level {
+		return yaml_parser_set_limit_error(parser, "MaxFlowNesting", parser.max_flow_level, parser.mark)
+	}
+
 	/* Reset the simple key on the next level. */
 
 	parser.simple_keys = append(parser.simple_keys, yaml_simple_key_t{})
@@ -1057,6 +1207,17 @@ func yaml_parser_decrease_flow_level(parser *yaml_parser_t) bool {
 	return true
 }
 
+/*
+ * Close the flow collections left open at a document boundary, which no
+ * flow collection can span, so that the scanner starts every document
+ * afresh.
+ */
+
+func yaml_parser_reset_flow_level(parser *yaml_parser_t) {
+	parser.flow_level = 0
+	parser.simple_keys = parser.simple_keys[:1]
+}
+
 /*
  * Push the current indentation level to the stack and set the new level
  * the current column is greater than the indentation level.  In this case,
@@ -1073,6 +1234,10 @@ func yaml_parser_roll_indent(parser *yaml_parser_t, column int,
 	}
 
 	if parser.indent == -1 || parser.indent < column {
+		if parser.keep_indentation && parser.indent >= 0 {
+			yaml_parser_check_indentation_step(parser, column-parser.indent, mark)
+		}
+
 		/*
 		 * Push the current indentation level to the stack and set the new
 		 * indentation level.
@@ -1249,6 +1414,10 @@ func yaml_parser_fetch_stream_end(parser *yaml_parser_t) bool {
  */
 
 func yaml_parser_fetch_directive(parser *yaml_parser_t) bool {
+	/* Close the flow collections left open. */
+
+	yaml_parser_reset_flow_level(parser)
+
 	/* Reset the indentation level. */
 
 	if !yaml_parser_reset_indent(parser) {
@@ -1269,8 +1438,10 @@ func yaml_parser_fetch_directive(parser *yaml_parser_t) bool {
 		return false
 	}
 
-	/* Append the token to the queue. */
-	insert_token(parser, -1, &token)
+	/* Append the token to the queue, unless the directive was skipped. */
+	if token.token_type != yaml_NO_TOKEN {
+		insert_token(parser, -1, &token)
+	}
 
 	return true
 }
@@ -1282,11 +1453,16 @@ func yaml_parser_fetch_directive(parser *yaml_parser_t) bool {
 func yaml_parser_fetch_document_indicator(parser *yaml_parser_t,
 	token_type yaml_token_type_t) bool {
 
+	/* Close the flow collections left open. */
+
+	yaml_parser_reset_flow_level(parser)
+
 	/* Reset the indentation level. */
 
 	if !yaml_parser_reset_indent(parser) {
 		return false
 	}
+	parser.indentation_step = 0
 
 	/* Reset simple keys. */
 
@@ -1787,6 +1963,11 @@ func yaml_parser_scan_to_next_token(parser *yaml_parser_t) bool {
 			skip(parser)
 		}
 
+		/* A line holding nothing but whitespaces is a blank line. */
+
+		line_start := parser.mark
+		blank := parser.mark.column == 0
+
 		/*
 		 * Eat whitespaces.
 		 *
@@ -1804,6 +1985,9 @@ func yaml_parser_scan_to_next_token(parser *yaml_parser_t) bool {
 		for parser.buffer[parser.buffer_pos] == ' ' ||
 			((parser.flow_level > 0 || !parser.simple_key_allowed) &&
 				parser.buffer[parser.buffer_pos] == '\t') {
+			if blank && parser.keep_indentation && is_tab(parser.buffer[parser.buffer_pos]) {
+				yaml_parser_save_tab(parser)
+			}
 			skip(parser)
 			if !cache(parser, 1) {
 				return false
@@ -1813,12 +1997,13 @@ func yaml_parser_scan_to_next_token(parser *yaml_parser_t) bool {
 		/* Eat a comment until a line break. */
 
 		if parser.buffer[parser.buffer_pos] == '#' {
-			for !is_breakz_at(parser.buffer, parser.buffer_pos) {
-				skip(parser)
-				if !cache(parser, 1) {
-					return false
-				}
+			/* Block scalars end at the start of the following line. */
+			trailing := parser.mark.line == parser.last_token_end.line &&
+				parser.last_token_end.column > 0
+			if !yaml_parser_scan_comment(parser, trailing) {
+				return false
 			}
+			blank = false
 		}
 
 		/* If it is a line break, eat it. */
@@ -1827,6 +2012,9 @@ func yaml_parser_scan_to_next_token(parser *yaml_parser_t) bool {
 			if !cache(parser, 2) {
 				return false
 			}
+			if blank {
+				yaml_parser_save_blank_line(parser, line_start)
+			}
 			skip_line(parser)
 
 			/* In the block context, a new line may start a simple key. */
@@ -1844,6 +2032,47 @@ func yaml_parser_scan_to_next_token(parser *yaml_parser_t) bool {
 	return true
 }
 
+/*
+ * Eat a comment until a line break, recording it if comments are kept.
+ */
+
+func yaml_parser_scan_comment(parser *yaml_parser_t, trailing bool) bool {
+	comment := yaml_comment_t{start_mark: parser.mark, trailing: trailing}
+
+	for !is_breakz_at(parser.buffer, parser.buffer_pos) {
+		n := ascii_span(parser, &comment_text, parser.unread)
+		switch {
+		case n > 1 && parser.keep_comments:
+			comment.value = read_span(parser, comment.value, n)
+		case n > 1:
+			skip_span(parser, n)
+		case parser.keep_comments:
+			comment.value = read(parser, comment.value)
+		default:
+			skip(parser)
+		}
+		if !cache(parser, 1) {
+			return false
+		}
+	}
+
+	if parser.keep_comments {
+		parser.comments = append(parser.comments, comment)
+	}
+	return true
+}
+
+/*
+ * Record a blank line at mark, as a comment without text, if comments are
+ * kept.
+ */
+
+func yaml_parser_save_blank_line(parser *yaml_parser_t, mark YAML_mark_t) {
+	if parser.keep_comments {
+		parser.comments = append(parser.comments, yaml_comment_t{start_mark: mark})
+	}
+}
+
 /*
  * Scan a YAML-DIRECTIVE or TAG-DIRECTIVE token.
  *
@@ -1908,11 +2137,21 @@ func yaml_parser_scan_directive(parser *yaml_parser_t, token *yaml_token_t) bool
 			value:      handle,
 			prefix:     prefix,
 		}
-	} else {
+	} else if parser.reject_unknown_directives {
 		/* Unknown directive. */
 		yaml_parser_set_scanner_error(parser, "while scanning a directive",
-			start_mark, "found uknown directive name")
+			start_mark, "found unknown directive name")
 		return false
+	} else {
+		/* Skip the parameters of an unknown directive, leaving no token. */
+		var value []byte
+		if !yaml_parser_scan_directive_parameters(parser, &value) {
+			return false
+		}
+		if parser.keep_unknown_directives {
+			parser.unknown_directives = append(parser.unknown_directives,
+				yaml_unknown_directive_t{name: name, value: value, start_mark: start_mark})
+		}
 	}
 
 	/* Eat the rest of the line including any comments. */
@@ -1988,7 +2227,7 @@ func yaml_parser_scan_directive_name(parser *yaml_parser_t,
 
 	if len(s) == 0 {
 		yaml_parser_set_scanner_error(parser, "while scanning a directive",
-			start_mark, "could not find expected directive name")
+			start_mark, "could not find expected directive name; quote a scalar starting with '%'")
 		return false
 	}
 
@@ -1996,7 +2235,7 @@ func yaml_parser_scan_directive_name(parser *yaml_parser_t,
 
 	if !is_blankz_at(parser.buffer, parser.buffer_pos) {
 		yaml_parser_set_scanner_error(parser, "while scanning a directive",
-			start_mark, "found unexpected non-alphabetical character")
+			start_mark, "found unexpected non-alphabetical character in the directive name; quote a scalar starting with '%'")
 		return false
 	}
 
@@ -2005,6 +2244,42 @@ func yaml_parser_scan_directive_name(parser *yaml_parser_t,
 	return true
 }
 
+/*
+ * Scan the parameters of an unknown directive, up to a comment or the end
+ * of the line.
+ *
+ * Scope:
+ *      %FOO    bar baz     # comment     \n
+ *              ^^^^^^^
+ */
+
+func yaml_parser_scan_directive_parameters(parser *yaml_parser_t, value *[]byte) bool {
+	if !cache(parser, 1) {
+		return false
+	}
+
+	for is_blank(parser.buffer[parser.buffer_pos]) {
+		skip(parser)
+		if !cache(parser, 1) {
+			return false
+		}
+	}
+
+	var s []byte
+	for !is_breakz_at(parser.buffer, parser.buffer_pos) {
+		if parser.buffer[parser.buffer_pos] == '#' && len(s) > 0 && is_blank(s[len(s)-1]) {
+			break
+		}
+		s = read(parser, s)
+		if !cache(parser, 1) {
+			return false
+		}
+	}
+
+	*value = bytes.TrimRight(s, " \t")
+	return true
+}
+
 /*
  * Scan the value of VERSION-DIRECTIVE.
  *
@@ -2630,11 +2905,8 @@ func yaml_parser_scan_block_scalar(parser *yaml_parser_t, token *yaml_token_t,
 	}
 
 	if parser.buffer[parser.buffer_pos] == '#' {
-		for !is_breakz_at(parser.buffer, parser.buffer_pos) {
-			skip(parser)
-			if !cache(parser, 1) {
-				return false
-			}
+		if !yaml_parser_scan_comment(parser, true) {
+			return false
 		}
 	}
 
@@ -2721,7 +2993,7 @@ func yaml_parser_scan_block_scalar(parser *yaml_parser_t, token *yaml_token_t,
 
 		for !is_breakz_at(parser.buffer, parser.buffer_pos) {
 			s = read(parser, s)
-			if !cache(parser, 1) {
+			if !yaml_parser_check_scalar_length(parser, start_mark) || !cache(parser, 1) {
 				return false
 			}
 		}
@@ -2749,6 +3021,11 @@ func yaml_parser_scan_block_scalar(parser *yaml_parser_t, token *yaml_token_t,
 	}
 	if chomping == 1 {
 		s = append(s, trailing_breaks...)
+	} else if len(trailing_breaks) > 0 {
+		/* The blank lines chomped precede the next token. */
+		mark := end_mark
+		mark.index--
+		yaml_parser_save_blank_line(parser, mark)
 	}
 
 	/* Create a token. */
@@ -2790,7 +3067,7 @@ func yaml_parser_scan_block_scalar_breaks(parser *yaml_parser_t,
 		for (*indent == 0 || parser.mark.column < *indent) &&
 			is_space(parser.buffer[parser.buffer_pos]) {
 			skip(parser)
-			if !cache(parser, 1) {
+			if !yaml_parser_check_scalar_length(parser, start_mark) || !cache(parser, 1) {
 				return false
 			}
 		}
@@ -2814,7 +3091,7 @@ func yaml_parser_scan_block_scalar_breaks(parser *yaml_parser_t,
 
 		/* Consume the line break. */
 
-		if !cache(parser, 2) {
+		if !yaml_parser_check_scalar_length(parser, start_mark) || !cache(parser, 2) {
 			return false
 		}
 
@@ -2834,9 +3111,43 @@ func yaml_parser_scan_block_scalar_breaks(parser *yaml_parser_t,
 		}
 	}
 
+	/* A tab after the indentation is content, which may not be meant. */
+
+	if parser.keep_indentation && parser.mark.column == *indent &&
+		is_tab(parser.buffer[parser.buffer_pos]) {
+		yaml_parser_save_tab(parser)
+	}
+
 	return true
 }
 
+/*
+ * Record a tab in the indentation of the current line, once a line.
+ */
+
+func yaml_parser_save_tab(parser *yaml_parser_t) {
+	if parser.tab_line == parser.mark.line+1 {
+		return
+	}
+	parser.tab_line = parser.mark.line + 1
+	parser.indentation_issues = append(parser.indentation_issues,
+		yaml_indentation_issue_t{tab: true, mark: parser.mark})
+}
+
+/*
+ * Record a block collection indented by another step than the first one
+ * nested in the document.
+ */
+
+func yaml_parser_check_indentation_step(parser *yaml_parser_t, step int, mark YAML_mark_t) {
+	if parser.indentation_step == 0 {
+		parser.indentation_step = step
+	} else if step != parser.indentation_step {
+		parser.indentation_issues = append(parser.indentation_issues,
+			yaml_indentation_issue_t{mark: mark, step: step, document_step: parser.indentation_step})
+	}
+}
+
 /*
  * Scan a quoted scalar.
  */
@@ -3038,7 +3349,7 @@ func yaml_parser_scan_flow_scalar(parser *yaml_parser_t, token *yaml_token_t,
 				s = read(parser, s)
 			}
 
-			if !cache(parser, 2) {
+			if !yaml_parser_check_scalar_length(parser, start_mark) || !cache(parser, 2) {
 				return false
 			}
 		}
@@ -3061,6 +3372,10 @@ func yaml_parser_scan_flow_scalar(parser *yaml_parser_t, token *yaml_token_t,
 
 		for is_blank(parser.buffer[parser.buffer_pos]) || is_break_at(parser.buffer, parser.buffer_pos) {
 			if is_blank(parser.buffer[parser.buffer_pos]) {
+				if leading_blanks && parser.keep_indentation && is_tab(parser.buffer[parser.buffer_pos]) {
+					yaml_parser_save_tab(parser)
+				}
+
 				/* Consume a space or a tab character. */
 				if !leading_blanks {
 					whitespaces = read(parser, whitespaces)
@@ -3082,7 +3397,7 @@ func yaml_parser_scan_flow_scalar(parser *yaml_parser_t, token *yaml_token_t,
 				}
 			}
 
-			if !cache(parser, 1) {
+			if !yaml_parser_check_scalar_length(parser, start_mark) || !cache(parser, 1) {
 				return false
 			}
 		}
@@ -3228,12 +3543,20 @@ func yaml_parser_scan_plain_scalar(parser *yaml_parser_t, token *yaml_token_t) b
 				}
 			}
 
-			/* Copy the character. */
+			/* Copy the character, or the run of plain characters it starts. */
 
-			s = read(parser, s)
+			plain := &plain_block
+			if parser.flow_level > 0 {
+				plain = &plain_flow
+			}
+			if n := ascii_span(parser, plain, parser.unread-1); n > 1 {
+				s = read_span(parser, s, n)
+			} else {
+				s = read(parser, s)
+			}
 			end_mark = parser.mark
 
-			if !cache(parser, 2) {
+			if !yaml_parser_check_scalar_length(parser, start_mark) || !cache(parser, 2) {
 				return false
 			}
 		}
@@ -3263,6 +3586,9 @@ func yaml_parser_scan_plain_scalar(parser *yaml_parser_t, token *yaml_token_t) b
 						start_mark, "found a tab character that violate indentation")
 					return false
 				}
+				if leading_blanks && parser.keep_indentation && is_tab(parser.buffer[parser.buffer_pos]) {
+					yaml_parser_save_tab(parser)
+				}
 
 				/* Consume a space or a tab character. */
 
@@ -3286,7 +3612,7 @@ func yaml_parser_scan_plain_scalar(parser *yaml_parser_t, token *yaml_token_t) b
 					trailing_breaks = read_line(parser, trailing_breaks)
 				}
 			}
-			if !cache(parser, 1) {
+			if !yaml_parser_check_scalar_length(parser, start_mark) || !cache(parser, 1) {
 				return false
 			}
 		}
@@ -3298,6 +3624,12 @@ func yaml_parser_scan_plain_scalar(parser *yaml_parser_t, token *yaml_token_t) b
 		}
 	}
 
+	/* The blank lines after the scalar precede the next token. */
+
+	if leading_blanks && len(trailing_breaks) > 0 {
+		yaml_parser_save_blank_line(parser, end_mark)
+	}
+
 	/* Create a token. */
 
 	*token = yaml_token_t{
@@ -18,6 +18,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -77,4 +78,97 @@ var scanYamls = func(dirname string) {
 var _ = Describe("Scanner", func() {
 	scanYamls("fixtures/specification")
 	scanYamls("fixtures/specification/types")
+
+	It("keeps the token queue proportional to the lookahead", func() {
+		input := "[" + strings.Repeat("abc, ", 1000) + "x]\n" + strings.Repeat("- abc\n", 1000)
+
+		parser := yaml_parser_t{}
+		yaml_parser_initialize(&parser)
+		yaml_parser_set_input_string(&parser, []byte(input))
+
+		token := yaml_token_t{}
+		for token.token_type != yaml_STREAM_END_TOKEN {
+			Expect(yaml_parser_scan(&parser, &token)).To(BeTrue())
+			Expect(cap(parser.tokens)).To(BeNumerically("<", 1024))
+
+			// tokens moved down in the queue are not kept twice
+			for _, t := range parser.tokens[len(parser.tokens):cap(parser.tokens)] {
+				Expect(t.value).To(BeNil())
+			}
+		}
+	})
+
+	It("reports a flow collection that ends at the indentation of a key", func() {
+		parser := yaml_parser_t{}
+		yaml_parser_initialize(&parser)
+		yaml_parser_set_input_string(&parser, []byte("  a: [x,\n ]b\n"))
+
+		token := yaml_token_t{}
+		for yaml_parser_scan(&parser, &token) {
+			Expect(token.token_type).NotTo(Equal(yaml_STREAM_END_TOKEN))
+		}
+		Expect(parser.problem).To(Equal("found a simple key where none is allowed"))
+		Expect(parser.problem_mark.line).To(Equal(1))
+	})
+
+	It("fails instead of panicking on a flow collection that ends at the indentation of a key", func() {
+		var v interface{}
+		err := Unmarshal([]byte("  a: [x,\n ]b\n"), &v)
+		Expect(err).To(MatchError(ContainSubstring("found a simple key where none is allowed")))
+	})
+
+	It("closes the flow collections left open at a document boundary", func() {
+		for _, input := range []string{"[a, {b: c\n---\nd: e\n", "[a\n...\n%YAML 1.1\n---\nd: e\n"} {
+			parser := yaml_parser_t{}
+			yaml_parser_initialize(&parser)
+			yaml_parser_set_input_string(&parser, []byte(input))
+
+			var types []yaml_token_type_t
+			token := yaml_token_t{}
+			for yaml_parser_scan(&parser, &token) && token.token_type != yaml_STREAM_END_TOKEN {
+				types = append(types, token.token_type)
+			}
+			Expect(parser.error).To(Equal(yaml_NO_ERROR), input)
+			Expect(types[len(types)-7:]).To(Equal([]yaml_token_type_t{
+				yaml_DOCUMENT_START_TOKEN,
+				yaml_BLOCK_MAPPING_START_TOKEN,
+				yaml_KEY_TOKEN, yaml_SCALAR_TOKEN,
+				yaml_VALUE_TOKEN, yaml_SCALAR_TOKEN,
+				yaml_BLOCK_END_TOKEN,
+			}), input)
+		}
+	})
+
+	Context("runs of ASCII characters", func() {
+		scalars := func(input string) []string {
+			parser := yaml_parser_t{}
+			yaml_parser_initialize(&parser)
+			yaml_parser_set_input_reader(&parser, strings.NewReader(input))
+
+			var values []string
+			token := yaml_token_t{}
+			for token.token_type != yaml_STREAM_END_TOKEN {
+				Expect(yaml_parser_scan(&parser, &token)).To(BeTrue(), parser.problem)
+				if token.token_type == yaml_SCALAR_TOKEN {
+					values = append(values, string(token.value))
+				}
+			}
+			return values
+		}
+
+		It("reads long plain scalars mixing ASCII and other characters", func() {
+			long := strings.Repeat("abcdefghij#:x ", 2000) + "é" + strings.Repeat("z", 5000) + "ü"
+			Expect(scalars("key: " + long + "\n")).To(Equal([]string{"key", long}))
+		})
+
+		It("ends plain scalars at the indicators of their context", func() {
+			Expect(scalars("a:b: c:d # note\n")).To(Equal([]string{"a:b", "c:d"}))
+			Expect(scalars("[ab,cd, {e#f: g}]\n")).To(Equal([]string{"ab", "cd", "e#f", "g"}))
+		})
+
+		It("skips long comments", func() {
+			comment := "# " + strings.Repeat("ñ comment text ", 1000) + "\n"
+			Expect(scalars(comment + "a: b " + comment)).To(Equal([]string{"a", "b"}))
+		})
+	})
 })
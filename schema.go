@@ -0,0 +1,280 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+var unmarshalerType = reflect.TypeOf(new(Unmarshaler)).Elem()
+
+// JSONSchemaOf returns a JSON Schema describing the documents that decode
+// into the type of v, for editors and validators to check documents
+// against without reading the Go source.
+//
+// Struct fields become properties, described by their `comment` tags.
+// Fields with the `required` option are listed as required, and the
+// `default=` option, which comes last as it takes the rest of the tag,
+// gives the value of a field when it is missing, as in
+//
+//	Port int `yaml:"port,required,default=8080" comment:"port to listen on"`
+//
+// Named struct types are described once, under $defs, so that recursive
+// types can refer to themselves. Types that decode or encode themselves,
+// and interface values, accept any value. The options are only recorded
+// in the schema: Decode neither enforces required fields nor fills in
+// defaults.
+func JSONSchemaOf(v interface{}) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("Cannot describe a nil value")
+	}
+
+	b := &schemaBuilder{
+		defs: make(map[string]interface{}),
+		refs: make(map[reflect.Type]string),
+		root: indirectType(t),
+	}
+	s, err := b.schema(t)
+	if err != nil {
+		return nil, err
+	}
+
+	s["$schema"] = jsonSchemaDraft
+	if name := b.root.Name(); name != "" {
+		s["title"] = name
+	}
+	if len(b.defs) > 0 {
+		s["$defs"] = b.defs
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// schemaBuilder collects the definitions of the named struct types met
+// while describing a type.
+type schemaBuilder struct {
+	defs map[string]interface{}
+	refs map[reflect.Type]string
+	root reflect.Type
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// selfDescribed reports whether values of t choose their own
+// representation, so that nothing is known about their shape.
+func selfDescribed(t reflect.Type) bool {
+	for _, i := range []reflect.Type{marshalerType, eventMarshalerType, unmarshalerType, eventUnmarshalerType} {
+		if t.Implements(i) || reflect.PtrTo(t).Implements(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) (map[string]interface{}, error) {
+	t = indirectType(t)
+
+	switch t {
+	case nodeType, rawYAMLType:
+		return map[string]interface{}{}, nil
+	case timeTimeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case numberType:
+		return map[string]interface{}{"type": "number"}, nil
+	case intStringType:
+		return map[string]interface{}{"type": "integer"}, nil
+	case byteSliceType:
+		return map[string]interface{}{"type": "string", "contentEncoding": "base64"}, nil
+	case orderedMapType:
+		return map[string]interface{}{"type": "object"}, nil
+	case pairsType:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "object", "minProperties": 1, "maxProperties": 1},
+		}, nil
+	}
+
+	if enum := registeredEnum(t); enum != nil {
+		names := make([]string, 0, len(enum.values))
+		for name := range enum.values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return map[string]interface{}{"type": "string", "enum": names}, nil
+	}
+
+	if selfDescribed(t) {
+		return map[string]interface{}{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := b.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s := map[string]interface{}{"type": "array", "items": items}
+		if t.Kind() == reflect.Array {
+			s["maxItems"] = t.Len()
+		}
+		return s, nil
+	case reflect.Map:
+		if isSetType(t) {
+			items, err := b.schema(t.Key())
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"type": "array", "items": items, "uniqueItems": true}, nil
+		}
+		values, err := b.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return b.structSchema(t)
+	}
+	return nil, fmt.Errorf("Cannot describe the %s", t)
+}
+
+// structSchema describes a struct type, in place when it is unnamed and
+// by a reference to its definition otherwise.
+func (b *schemaBuilder) structSchema(t reflect.Type) (map[string]interface{}, error) {
+	if t.Name() == "" {
+		return b.object(t)
+	}
+
+	if ref, ok := b.refs[t]; ok {
+		return map[string]interface{}{"$ref": ref}, nil
+	}
+	if t == b.root {
+		b.refs[t] = "#"
+		return b.object(t)
+	}
+
+	name := t.Name()
+	for i := 2; b.defs[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", t.Name(), i)
+	}
+	ref := "#/$defs/" + name
+	b.refs[t] = ref
+	b.defs[name] = true // reserve the name while describing the fields
+
+	s, err := b.object(t)
+	if err != nil {
+		return nil, err
+	}
+	b.defs[name] = s
+	return map[string]interface{}{"$ref": ref}, nil
+}
+
+// object describes the fields of a struct type.
+func (b *schemaBuilder) object(t reflect.Type) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for _, f := range cachedTypeFields(t) {
+		p, err := b.schema(f.typ)
+		if err != nil {
+			return nil, fmt.Errorf("%s of %s.%s", err, t, f.name)
+		}
+		if _, ok := p["$ref"]; ok && (f.comment != "" || f.def != nil) {
+			// siblings of a $ref are ignored by older validators
+			p = map[string]interface{}{"allOf": []interface{}{p}}
+		}
+		if f.comment != "" {
+			p["description"] = f.comment
+		}
+		if f.def != nil {
+			def, err := defaultValue(*f.def, f.typ)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid default %q of %s.%s: %s", *f.def, t, f.name, err)
+			}
+			p["default"] = def
+		}
+		if f.required {
+			required = append(required, f.name)
+		}
+		properties[f.name] = p
+	}
+
+	s := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s, nil
+}
+
+// defaultValue decodes the text of a `default=` option into the type of
+// its field and returns it in the form encoding/json writes.
+func defaultValue(text string, t reflect.Type) (interface{}, error) {
+	v := reflect.New(t)
+	if err := Unmarshal([]byte(text), v.Interface()); err != nil {
+		return nil, err
+	}
+
+	// round trip through a document so that the value takes the shape
+	// documents give it rather than the shape of the Go type
+	data, err := Marshal(v.Elem().Interface())
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return jsonValue(value), nil
+}
+
+// jsonValue replaces the mappings in a decoded value with maps that
+// encoding/json accepts.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,116 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON Schema", func() {
+	type listener struct {
+		Host string `yaml:"host" comment:"address to bind"`
+		Port uint16 `yaml:"port,required,default=8080"`
+	}
+
+	type config struct {
+		Name      string            `yaml:"name,required"`
+		Listeners []listener        `yaml:"listeners"`
+		Primary   *listener         `yaml:"primary" comment:"the main listener"`
+		Labels    map[string]string `yaml:"labels,omitempty"`
+		Started   time.Time         `yaml:"started"`
+		Extra     interface{}       `yaml:"extra"`
+		Tags      [2]string         `yaml:"tags,default=[a, b]"`
+		Ignored   string            `yaml:"-"`
+	}
+
+	It("describes the fields of a struct and the types they refer to", func() {
+		schema, err := JSONSchemaOf(config{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schema).To(MatchJSON(`{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"title": "config",
+			"type": "object",
+			"properties": {
+				"name": {"type": "string"},
+				"listeners": {"type": "array", "items": {"$ref": "#/$defs/listener"}},
+				"primary": {"allOf": [{"$ref": "#/$defs/listener"}], "description": "the main listener"},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"started": {"type": "string", "format": "date-time"},
+				"extra": {},
+				"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "default": ["a", "b"]}
+			},
+			"required": ["name"],
+			"$defs": {
+				"listener": {
+					"type": "object",
+					"properties": {
+						"host": {"type": "string", "description": "address to bind"},
+						"port": {"type": "integer", "minimum": 0, "default": 8080}
+					},
+					"required": ["port"]
+				}
+			}
+		}`))
+	})
+
+	It("refers to the root of recursive types", func() {
+		type tree struct {
+			Value    string  `yaml:"value"`
+			Children []*tree `yaml:"children"`
+		}
+
+		schema, err := JSONSchemaOf(&tree{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schema).To(MatchJSON(`{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"title": "tree",
+			"type": "object",
+			"properties": {
+				"value": {"type": "string"},
+				"children": {"type": "array", "items": {"$ref": "#"}}
+			}
+		}`))
+	})
+
+	It("keeps defaults that look like other types as strings", func() {
+		type flags struct {
+			Answer string `yaml:"answer,default=yes"`
+		}
+
+		schema, err := JSONSchemaOf(flags{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schema).To(ContainSubstring(`"default": "yes"`))
+	})
+
+	It("rejects defaults that do not decode into their fields", func() {
+		type bad struct {
+			Port int `yaml:"port,default=http"`
+		}
+
+		_, err := JSONSchemaOf(bad{})
+		Expect(err).To(MatchError(ContainSubstring(`Invalid default "http" of candiedyaml.bad.port`)))
+	})
+
+	It("rejects types that cannot be decoded", func() {
+		_, err := JSONSchemaOf(struct{ C chan int }{})
+		Expect(err).To(MatchError(ContainSubstring("Cannot describe the chan int")))
+
+		_, err = JSONSchemaOf(nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
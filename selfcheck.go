@@ -0,0 +1,174 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SelfCheck makes the encoder parse each document it writes again before
+// writing it to its writer, and fail with an error when the output does
+// not parse or does not read back as the events that were emitted: the
+// same nodes, anchors, aliases, tags and scalar values. It costs a parse
+// of the output, and is meant for tests and debugging, to catch output
+// that other parsers would reject where it is produced.
+func (e *Encoder) SelfCheck(on bool) {
+	e.selfCheck = on
+}
+
+// a checked event: what of an event is kept by writing and reading it,
+// and the line it was read from
+type checkedEvent struct {
+	kind   yaml_event_type_t
+	anchor string
+	tag    string
+	value  string
+	line   int
+}
+
+// checkedEventOf returns the checked event of ev. An implicit tag of an
+// emitted event is left out, as the emitter may or may not write it.
+func checkedEventOf(ev *yaml_event_t, emitted bool) checkedEvent {
+	c := checkedEvent{kind: ev.event_type, anchor: string(ev.anchor), line: ev.start_mark.line + 1}
+	switch ev.event_type {
+	case yaml_SCALAR_EVENT:
+		c.value = string(ev.value)
+		if !emitted || !ev.implicit && !ev.quoted_implicit {
+			c.tag = longTag(string(ev.tag))
+		}
+	case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+		if !emitted || !ev.implicit {
+			c.tag = longTag(string(ev.tag))
+		}
+	}
+	return c
+}
+
+func (c checkedEvent) String() string {
+	s := EventKind(c.kind).String()
+	if c.anchor != "" {
+		s += " &" + c.anchor
+	}
+	if c.tag != "" {
+		s += " <" + c.tag + ">"
+	}
+	if c.kind == yaml_SCALAR_EVENT {
+		s += fmt.Sprintf(" %q", c.value)
+	}
+	return s
+}
+
+// parseChecked returns the checked events of the nodes of the documents
+// in input, and the document starts and ends when documents is true.
+func parseChecked(input []byte, encoding yaml_encoding_t, documents bool) ([]checkedEvent, error) {
+	parser := yaml_parser_t{}
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_string(&parser, input)
+	yaml_parser_set_encoding(&parser, encoding)
+
+	var events []checkedEvent
+	for {
+		ev := yaml_event_t{}
+		if !yaml_parser_parse(&parser, &ev) {
+			return nil, newParserError(&parser)
+		}
+		switch ev.event_type {
+		case yaml_STREAM_END_EVENT:
+			return events, nil
+		case yaml_STREAM_START_EVENT:
+			continue
+		case yaml_DOCUMENT_START_EVENT, yaml_DOCUMENT_END_EVENT:
+			if !documents {
+				continue
+			}
+		}
+		events = append(events, checkedEventOf(&ev, false))
+	}
+}
+
+// checkEvent records the event being emitted for the self-check, which
+// starts with each document.
+func (e *Encoder) checkEvent() {
+	switch e.event.event_type {
+	case yaml_STREAM_START_EVENT, yaml_STREAM_END_EVENT:
+		return
+	case yaml_DOCUMENT_START_EVENT:
+		// the output before the document is written as it is, and that of
+		// the document kept until it is checked
+		if !yaml_emitter_flush(&e.emitter) {
+			panic(errors.New(e.emitter.problem))
+		}
+		e.checkOutput = e.emitter.output_writer
+		e.emitter.output_writer = &e.checkBuffer
+		e.checkBuffer.Reset()
+		e.checkEvents = e.checkEvents[:0]
+	case yaml_SCALAR_EVENT:
+		if yaml_scalar_style_t(e.event.style) == yaml_RAW_SCALAR_STYLE {
+			// raw text reads back as the nodes it holds
+			raw, err := parseChecked(e.event.value, yaml_UTF8_ENCODING, false)
+			if err != nil {
+				panic(err)
+			}
+			if len(raw) > 0 && len(e.event.anchor) > 0 {
+				raw[0].anchor = string(e.event.anchor)
+			}
+			e.checkEvents = append(e.checkEvents, raw...)
+			return
+		}
+	}
+	e.checkEvents = append(e.checkEvents, checkedEventOf(&e.event, true))
+}
+
+// endCheck checks the output of the document that ended, and writes it.
+func (e *Encoder) endCheck() {
+	flushed := yaml_emitter_flush(&e.emitter)
+	e.emitter.output_writer = e.checkOutput
+	if !flushed {
+		panic(errors.New(e.emitter.problem))
+	}
+
+	if err := checkOutput(e.checkBuffer.Bytes(), e.emitter.encoding, e.checkEvents); err != nil {
+		panic(err)
+	}
+	if _, err := e.checkOutput.Write(e.checkBuffer.Bytes()); err != nil {
+		panic(err)
+	}
+}
+
+// checkOutput parses output and compares its events to those emitted.
+func checkOutput(output []byte, encoding yaml_encoding_t, emitted []checkedEvent) error {
+	parsed, err := parseChecked(output, encoding, true)
+	if err != nil {
+		return fmt.Errorf("yaml: self-check of the output failed: %s", err)
+	}
+
+	for i, got := range parsed {
+		if i == len(emitted) {
+			return fmt.Errorf("yaml: self-check of the output failed: %s read at line %d after the end of the document",
+				got, got.line)
+		}
+		want := emitted[i]
+		if got.kind != want.kind || got.anchor != want.anchor || got.value != want.value ||
+			want.tag != "" && got.tag != want.tag {
+			return fmt.Errorf("yaml: self-check of the output failed: %s reads back at line %d as %s",
+				want, got.line, got)
+		}
+	}
+	if len(parsed) < len(emitted) {
+		return fmt.Errorf("yaml: self-check of the output failed: %s is missing", emitted[len(parsed)])
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// An ElementDecoder decodes an element of the sequence read by DecodeSeq.
+type ElementDecoder struct {
+	d    *Decoder
+	done bool
+	err  error
+}
+
+// Decode decodes the element into the value pointed to by v. It can be
+// called once per element.
+func (e *ElementDecoder) Decode(v interface{}) (err error) {
+	if e.done {
+		return errors.New("The element was already decoded")
+	}
+	e.done = true
+	defer func() { e.err = err }()
+	defer recovery(&err)
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Expected a pointer or nil but was a %s at %s", rv.String(), e.d.event.start_mark)
+	}
+	e.d.parse(rv)
+	return nil
+}
+
+// DecodeSeq reads the next document, whose root is a sequence, one element
+// at a time, calling fn with the index of each element and a decoder for
+// it, so that huge sequences can be processed without building a slice.
+// Elements that fn does not decode are skipped.
+//
+// When the sequence is not the root, path gives the keys of the mappings
+// leading to it from the root. The other entries of those mappings are
+// skipped.
+//
+// An error returned by fn, or by decoding an element, stops the reading
+// and is returned. The Decoder cannot read the rest of the document then.
+func (d *Decoder) DecodeSeq(fn func(i int, dec *ElementDecoder) error, path ...string) (err error) {
+	defer recovery(&err)
+
+	d.start()
+	if d.event.event_type != yaml_DOCUMENT_START_EVENT {
+		return composingError(d.event.start_mark, "Expected document start")
+	}
+	d.nextEvent()
+	// positions are not recorded, as they would pile up
+	d.positions, d.path = nil, d.path[:0]
+	d.depth = 0
+
+	for _, key := range path {
+		d.seekKey(key)
+	}
+
+	if d.event.event_type != yaml_SEQUENCE_START_EVENT {
+		return decodingError(d.event.start_mark, "Expected a sequence")
+	}
+	d.nextEvent()
+	for i := 0; d.event.event_type != yaml_SEQUENCE_END_EVENT; i++ {
+		dec := &ElementDecoder{d: d}
+		if err := fn(i, dec); err != nil {
+			return err
+		}
+		if dec.err != nil {
+			return dec.err
+		}
+		if !dec.done {
+			d.skip()
+		}
+	}
+	d.nextEvent()
+
+	// the entries after the sequence in the mappings leading to it
+	for range path {
+		for d.event.event_type != yaml_MAPPING_END_EVENT {
+			d.skip()
+		}
+		d.nextEvent()
+	}
+	d.dropSkipped()
+
+	if d.event.event_type != yaml_DOCUMENT_END_EVENT {
+		return composingError(d.event.start_mark, "Expected document end")
+	}
+	d.nextEvent()
+	return nil
+}
+
+// seekKey moves from the start of the mapping at the current event to the
+// value of key, skipping the entries before it.
+func (d *Decoder) seekKey(key string) {
+	if d.event.event_type != yaml_MAPPING_START_EVENT {
+		d.error(decodingError(d.event.start_mark, "Expected a mapping holding '%s'", key))
+	}
+	d.nextEvent()
+
+	for d.event.event_type != yaml_MAPPING_END_EVENT {
+		found := d.event.event_type == yaml_SCALAR_EVENT && string(d.event.value) == key
+		d.skip()
+		if found {
+			return
+		}
+		d.skip()
+	}
+	d.error(decodingError(d.event.start_mark, "Key '%s' not found", key))
+}
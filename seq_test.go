@@ -0,0 +1,106 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DecodeSeq", func() {
+	type record struct {
+		ID   int
+		Name string
+	}
+
+	It("decodes the elements of a root sequence one at a time", func() {
+		d := NewDecoder(strings.NewReader("- {id: 1, name: a}\n- {id: 2, name: b}\n- {id: 3, name: c}\n---\nnext\n"))
+		var records []record
+		err := d.DecodeSeq(func(i int, dec *ElementDecoder) error {
+			Expect(i).To(Equal(len(records)))
+			var r record
+			if err := dec.Decode(&r); err != nil {
+				return err
+			}
+			records = append(records, r)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(Equal([]record{{1, "a"}, {2, "b"}, {3, "c"}}))
+
+		var next string
+		Expect(d.Decode(&next)).To(Succeed())
+		Expect(next).To(Equal("next"))
+	})
+
+	It("finds the sequence under keys and skips the rest", func() {
+		d := NewDecoder(strings.NewReader(`version: 1
+export:
+  meta: {count: 3}
+  records:
+  - {id: 1}
+  - {id: 2}
+  - {id: 3}
+  footer: done
+`))
+		var ids []int
+		err := d.DecodeSeq(func(i int, dec *ElementDecoder) error {
+			if i == 1 {
+				return nil
+			}
+			var r record
+			err := dec.Decode(&r)
+			ids = append(ids, r.ID)
+			return err
+		}, "export", "records")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids).To(Equal([]int{1, 3}))
+	})
+
+	It("stops at the first error", func() {
+		d := NewDecoder(strings.NewReader("[1, 2, 3]\n"))
+		stop := errors.New("stop")
+		calls := 0
+		err := d.DecodeSeq(func(i int, dec *ElementDecoder) error {
+			calls++
+			return stop
+		})
+		Expect(err).To(Equal(stop))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("reports decoding errors the callback ignores", func() {
+		d := NewDecoder(strings.NewReader("[a]\n"))
+		err := d.DecodeSeq(func(i int, dec *ElementDecoder) error {
+			var n int
+			dec.Decode(&n)
+			return nil
+		})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("requires a sequence at the path", func() {
+		d := NewDecoder(strings.NewReader("a: {b: 1}\n"))
+		err := d.DecodeSeq(func(int, *ElementDecoder) error { return nil }, "a", "b")
+		Expect(err).To(MatchError(ContainSubstring("Expected a sequence")))
+
+		d = NewDecoder(strings.NewReader("a: {b: 1}\n"))
+		err = d.DecodeSeq(func(int, *ElementDecoder) error { return nil }, "a", "c")
+		Expect(err).To(MatchError(ContainSubstring("Key 'c' not found")))
+	})
+})
@@ -0,0 +1,110 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "io"
+
+// A SplitKey chooses where a document goes when splitting a stream: it is
+// called with the events of the document in turn, until it returns a key.
+type SplitKey func(ev Event, at EventPath) (key string, ok bool)
+
+// KeyAt returns a SplitKey keying documents by the value of the scalar at
+// path, such as "kind" for Kubernetes manifests.
+func KeyAt(path string) SplitKey {
+	return func(ev Event, at EventPath) (string, bool) {
+		if ev.Kind == ScalarEvent && !at.Key && at.Path == path {
+			return ev.Value, true
+		}
+		return "", false
+	}
+}
+
+// Split reads the documents of the stream from r and writes each to the
+// Encoder open returns for its key, calling open once for each key. The
+// documents for which key returns none have the key "". The events of a
+// document are only held until its key is known, after which they are
+// written as they are read. Comments and directives are not kept.
+func Split(r io.Reader, key SplitKey, open func(key string) (*Encoder, error)) (err error) {
+	defer recovery(&err)
+
+	sinks := make(map[string]*eventSink)
+	var sink *eventSink
+	choose := func(k string) {
+		s, ok := sinks[k]
+		if !ok {
+			e, err := open(k)
+			if err != nil {
+				panic(err)
+			}
+			if e.err != nil {
+				panic(e.err)
+			}
+			if !e.started {
+				e.start()
+			}
+			s = &eventSink{e: e}
+			sinks[k] = s
+		}
+		sink = s
+	}
+
+	var parser yaml_parser_t
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_reader(&parser, r)
+
+	var t pathTracker
+	var pending []Event
+	var event yaml_event_t
+	for {
+		if !yaml_parser_parse(&parser, &event) {
+			return newParserError(&parser)
+		}
+		ev := eventOf(&event)
+		at := t.locate(ev)
+
+		switch ev.Kind {
+		case StreamStartEvent:
+			continue
+		case StreamEndEvent:
+			for _, s := range sinks {
+				if err := s.close(); err != nil {
+					return err
+				}
+			}
+			return nil
+		case DocumentStartEvent:
+			sink = nil
+		case DocumentEndEvent:
+			if sink == nil {
+				choose("")
+			}
+		}
+
+		if sink == nil {
+			if k, ok := key(ev, at); ok {
+				choose(k)
+			}
+		}
+		if sink == nil {
+			pending = append(pending, ev)
+			continue
+		}
+		for _, p := range pending {
+			sink.write(p)
+		}
+		pending = pending[:0]
+		sink.write(ev)
+	}
+}
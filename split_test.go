@@ -0,0 +1,78 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Split", func() {
+	input := `kind: Service
+metadata:
+  name: web
+---
+metadata:
+  name: db
+kind: Deployment
+---
+kind: Service
+metadata:
+  name: db
+---
+metadata:
+  name: orphan
+`
+
+	It("writes the documents to the Encoder of their key", func() {
+		outputs := make(map[string]*bytes.Buffer)
+		err := Split(strings.NewReader(input), KeyAt("kind"), func(key string) (*Encoder, error) {
+			Expect(outputs).NotTo(HaveKey(key))
+			outputs[key] = new(bytes.Buffer)
+			return NewEncoder(outputs[key]), nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outputs).To(HaveLen(3))
+		Expect(outputs["Service"].String()).To(Equal(`kind: Service
+metadata:
+  name: web
+---
+kind: Service
+metadata:
+  name: db
+`))
+		Expect(outputs["Deployment"].String()).To(Equal(`---
+metadata:
+  name: db
+kind: Deployment
+`))
+		Expect(outputs[""].String()).To(Equal(`---
+metadata:
+  name: orphan
+`))
+	})
+
+	It("fails when an Encoder cannot be opened", func() {
+		failure := errors.New("too many files")
+		err := Split(strings.NewReader(input), KeyAt("kind"), func(key string) (*Encoder, error) {
+			return nil, failure
+		})
+		Expect(err).To(Equal(failure))
+	})
+})
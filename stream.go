@@ -0,0 +1,412 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// A Stream holds the documents of a YAML text parsed as Nodes, and parses
+// again only the part that an edit of the text touches, which keeps
+// editors from parsing a whole file after every keystroke: the smallest
+// block mapping or sequence around the edit that starts its own line, or
+// else the documents around it.
+//
+// Documents are parsed independently, so an error in one document leaves
+// the others parsed, and aliases only refer to anchors of their own
+// document. The text must be UTF-8.
+type Stream struct {
+	text      []byte
+	Documents []*Document
+}
+
+// A Document is a document of a Stream.
+type Document struct {
+	// Node is the root node of the document, or nil if the document is
+	// empty or failed to parse.
+	Node *Node
+
+	// Err is the error parsing the document, if any.
+	Err error
+
+	// Start and End are the byte offsets of the text of the document,
+	// which includes the comments and blank lines around it.
+	Start, End int
+
+	// the marks of the start and end of the text, and whether a scanner
+	// error left the text after the start unsplit
+	start, end YAML_mark_t
+	unsplit    bool
+}
+
+// boundaryLookahead is how far after the end of a document an edit can
+// change whether it ends there: the end is where a "---", a directive or
+// the first token after a "..." starts, which is decided by up to three
+// characters and a blank of up to three bytes.
+const boundaryLookahead = 6
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// ParseStream parses the documents of src. The Stream keeps src, which
+// must not be modified afterwards.
+func ParseStream(src []byte) *Stream {
+	s := &Stream{text: src}
+	start := 0
+	if bytes.HasPrefix(src, utf8BOM) {
+		start = len(utf8BOM)
+	}
+	s.Documents = s.parse(start, YAML_mark_t{offset: start}, nil)
+	return s
+}
+
+// Text returns the current text of the stream.
+func (s *Stream) Text() []byte {
+	return s.text
+}
+
+// Edit replaces the removed bytes of the text at offset with inserted, and
+// parses what changed again. When the edit is within a block mapping or
+// sequence that starts its own line, holds no anchors and is not the root
+// of its document, the innermost one is parsed again and replaced in the
+// Node tree of the document, whose later Nodes are moved. Otherwise the
+// documents that changed are parsed again. The documents before them are
+// kept, and those after them are kept with their positions moved.
+func (s *Stream) Edit(offset, removed int, inserted []byte) error {
+	if offset < 0 || removed < 0 || offset+removed > len(s.text) {
+		return fmt.Errorf("Edit of %d bytes at %d is out of the text of %d bytes", removed, offset, len(s.text))
+	}
+
+	before := s.text
+	text := make([]byte, 0, len(s.text)-removed+len(inserted))
+	text = append(text, s.text[:offset]...)
+	text = append(text, inserted...)
+	text = append(text, s.text[offset+removed:]...)
+	s.text = text
+
+	if s.editNode(before, offset, removed, inserted) {
+		return nil
+	}
+
+	// the parse stops at the end of a document that ends where one of the
+	// old documents after the edit did, at the same column, after which
+	// nothing changed
+	docs := s.Documents
+	delta := len(inserted) - removed
+	editEnd := offset + len(inserted)
+	rest := len(docs)
+	resync := func(doc *Document) bool {
+		i := sort.Search(len(docs), func(i int) bool { return docs[i].End+delta >= doc.End })
+		if doc.End < editEnd || i == len(docs) || docs[i].End+delta != doc.End ||
+			docs[i].End < offset+removed || docs[i].end.column != doc.end.column {
+			return false
+		}
+		rest = i + 1
+		return true
+	}
+
+	// parse again from the end of the last document the edit leaves
+	// alone, which is a document boundary where the scanner starts afresh;
+	// a scanner error before the first boundary found joins the document
+	// before it, as the boundary would not have been found either
+	first := sort.Search(len(docs), func(i int) bool { return docs[i].End+boundaryLookahead >= offset })
+	var parsed []*Document
+	for {
+		start, mark := 0, YAML_mark_t{}
+		if first > 0 {
+			start, mark = docs[first-1].End, docs[first-1].end
+		} else if bytes.HasPrefix(text, utf8BOM) {
+			start = len(utf8BOM)
+			mark.offset = start
+		}
+		parsed = s.parse(start, mark, resync)
+		if first == 0 || len(parsed) == 0 || !parsed[0].unsplit {
+			break
+		}
+		first--
+	}
+
+	kept := docs[rest:]
+	s.Documents = append(append(append([]*Document{}, docs[:first]...), parsed...), kept...)
+	if len(kept) == 0 {
+		return nil
+	}
+
+	last, old := parsed[len(parsed)-1].end, docs[rest-1].end
+	s.moveDocuments(len(s.Documents)-len(kept), delta, last.line-old.line, last.index-old.index)
+	return nil
+}
+
+// moveDocuments moves the documents from the one at index by a number of
+// bytes, lines and characters.
+func (s *Stream) moveDocuments(index, n, lines, chars int) {
+	shiftDocuments(s.Documents[index:], n, lines, chars)
+
+	// errors are parsed again to report their new positions
+	if lines != 0 {
+		for i := index; i < len(s.Documents); i++ {
+			if doc := s.Documents[i]; doc.Err != nil {
+				s.Documents[i] = s.parse(doc.Start, doc.start, func(*Document) bool { return true })[0]
+			}
+		}
+	}
+}
+
+// editNode parses again the innermost block collection that an edit of old
+// leaves in its place, and replaces it in its document. It reports whether
+// it did, or whether the documents are to be parsed again.
+func (s *Stream) editNode(old []byte, offset, removed int, inserted []byte) bool {
+	i := sort.Search(len(s.Documents), func(i int) bool { return s.Documents[i].End >= offset+removed })
+	if i == len(s.Documents) {
+		return false
+	}
+	doc := s.Documents[i]
+	if doc.Node == nil || doc.Err != nil || doc.Start > offset || len(doc.Node.TagDirectives) > 0 {
+		return false
+	}
+
+	// the block collections holding the edit, from the root, with the
+	// parents and indexes of all but the root
+	type nested struct {
+		node, parent *Node
+		index        int
+	}
+	path := []nested{{node: doc.Node}}
+	for {
+		n := path[len(path)-1].node
+		var next *nested
+		for j, c := range n.Content {
+			if n.Kind == MappingNode && j%2 == 0 {
+				continue
+			}
+			if !c.Flow && (c.Kind == MappingNode || c.Kind == SequenceNode) &&
+				lineStart(old, c.Offset) <= offset && offset+removed <= c.EndOffset {
+				next = &nested{node: c, parent: n, index: j}
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		path = append(path, *next)
+	}
+
+	delta := len(inserted) - removed
+	for j := len(path) - 1; j > 0; j-- {
+		n := replacedNode(old, s.text, doc, path[j].node, path[j].parent, offset, removed, delta)
+		if n == nil {
+			continue
+		}
+		oldEnd, lines := path[j].node.EndOffset, n.EndLine-path[j].node.EndLine
+		path[j].parent.Content[path[j].index] = n
+		shiftNodesAfter(doc.Node, n, oldEnd, delta, lines)
+
+		chars := utf8.RuneCount(inserted) - utf8.RuneCount(old[offset:offset+removed])
+		edited := *doc
+		edited.End += delta
+		edited.end.line += lines
+		edited.end.index += chars
+		edited.end.offset += delta
+		s.Documents[i] = &edited
+		s.moveDocuments(i+1, delta, lines, chars)
+		return true
+	}
+	return false
+}
+
+// replacedNode parses again the block collection n of doc after an edit
+// of old into text, and returns the Node that replaces it, or nil when
+// the edit may change more than n.
+//
+// The collection starts its line, after only spaces, and ends where a
+// token less indented than it starts, which the edit must leave alone
+// along with the line break before it. Parsed from the start of its line,
+// the collection then gives the events it would within its document, as
+// long as it is still indented more than parent and still ends where it
+// did; it cannot hold anchors, which later aliases may refer to.
+func replacedNode(old, text []byte, doc *Document, n, parent *Node, offset, removed, delta int) *Node {
+	start := lineStart(old, n.Offset)
+	end := lineStart(old, n.EndOffset)
+	if offset < start || offset+removed > end-lineBreakBefore(old, end) ||
+		len(bytes.Trim(old[start:n.Offset], " ")) > 0 || hasAnchors(n) {
+		return nil
+	}
+
+	var parser yaml_parser_t
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_string(&parser, text[start:])
+	parser.mark = YAML_mark_t{
+		index:  doc.start.index + utf8.RuneCount(text[doc.Start:start]),
+		line:   n.Line - 1,
+		offset: start,
+	}
+
+	events := make([]yaml_event_t, 0, 16)
+	depth := 0
+	for depth > 0 || len(events) < 3 {
+		var event yaml_event_t
+		if !yaml_parser_parse(&parser, &event) {
+			return nil
+		}
+		switch event.event_type {
+		case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+			depth++
+		case yaml_SEQUENCE_END_EVENT, yaml_MAPPING_END_EVENT:
+			depth--
+		case yaml_ALIAS_EVENT:
+			return nil
+		}
+		if len(event.anchor) > 0 {
+			return nil
+		}
+		events = append(events, event)
+	}
+
+	first, last := events[2], events[len(events)-1]
+	if first.event_type != yaml_MAPPING_START_EVENT && first.event_type != yaml_SEQUENCE_START_EVENT ||
+		first.event_type == yaml_MAPPING_START_EVENT && yaml_mapping_style_t(first.style) == yaml_FLOW_MAPPING_STYLE ||
+		first.event_type == yaml_SEQUENCE_START_EVENT && yaml_sequence_style_t(first.style) == yaml_FLOW_SEQUENCE_STYLE ||
+		len(first.tag) > 0 || first.start_mark.line != n.Line-1 || first.start_mark.column < parent.Column ||
+		len(bytes.Trim(text[start:first.start_mark.offset], " ")) > 0 ||
+		last.end_mark.offset != n.EndOffset+delta {
+		return nil
+	}
+
+	events = append(events,
+		yaml_event_t{event_type: yaml_DOCUMENT_END_EVENT, implicit: true},
+		yaml_event_t{event_type: yaml_STREAM_END_EVENT})
+	var replaced *Node
+	if err := (&Recording{events: events}).Replay().Decode(&replaced); err != nil {
+		return nil
+	}
+	return replaced
+}
+
+// hasAnchors reports whether n or a Node within it defines an anchor.
+func hasAnchors(n *Node) bool {
+	if n.Anchor != "" {
+		return true
+	}
+	for _, c := range n.Content {
+		if hasAnchors(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// shiftNodesAfter moves the starts and ends of the Nodes of the tree at n,
+// except those of replaced, that are at or after end, by a number of
+// bytes and lines.
+func shiftNodesAfter(n, replaced *Node, end, offset, lines int) {
+	if n == replaced {
+		return
+	}
+	if n.Offset >= end {
+		n.Line += lines
+		n.Offset += offset
+	}
+	if n.EndOffset >= end {
+		n.EndLine += lines
+		n.EndOffset += offset
+	}
+	for _, c := range n.Content {
+		shiftNodesAfter(c, replaced, end, offset, lines)
+	}
+}
+
+// lineStart returns the offset of the start of the line of text holding
+// offset.
+func lineStart(text []byte, offset int) int {
+	for i := offset; i > 0; i-- {
+		if lineBreakBefore(text, i) > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// lineBreakBefore returns the length of the line break that ends right
+// before offset in text, or 0.
+func lineBreakBefore(text []byte, offset int) int {
+	before := text[:offset]
+	switch {
+	case bytes.HasSuffix(before, []byte("\r\n")):
+		return 2
+	case bytes.HasSuffix(before, []byte("\n")), bytes.HasSuffix(before, []byte("\r")):
+		return 1
+	case bytes.HasSuffix(before, nextLine):
+		return len(nextLine)
+	case bytes.HasSuffix(before, lineSeparator), bytes.HasSuffix(before, paragraphSeparator):
+		return len(lineSeparator)
+	}
+	return 0
+}
+
+// parse parses the documents of the text from start, where the scanner is
+// at mark, until its end or until stop returns true for one of them.
+func (s *Stream) parse(start int, mark YAML_mark_t, stop func(*Document) bool) []*Document {
+	var docs []*Document
+	d := NewDecoder(bytes.NewReader(s.text[start:]))
+	d.parser.mark = mark
+	dec := NewDecoder(nil)
+
+	d.splitDocuments(func(t *documentText) bool {
+		doc := &Document{Start: start + t.offset, start: t.mark, Err: t.err}
+		docs = append(docs, doc)
+		if t.err != nil {
+			doc.End, doc.unsplit = len(s.text), true
+			return false
+		}
+		doc.End, doc.end = doc.Start+len(t.text), t.end
+
+		dec.Reset(bytes.NewReader(t.text))
+		dec.parser.mark = t.mark
+		if doc.Err = dec.Decode(&doc.Node); doc.Err != nil {
+			doc.Node = nil
+		}
+		return stop == nil || !stop(doc)
+	})
+	return docs
+}
+
+// shiftDocuments moves documents by a number of bytes, lines and
+// characters.
+func shiftDocuments(docs []*Document, n, lines, chars int) {
+	for _, doc := range docs {
+		doc.Start += n
+		doc.End += n
+		for _, m := range []*YAML_mark_t{&doc.start, &doc.end} {
+			m.line += lines
+			m.index += chars
+			m.offset += n
+		}
+		if doc.Node != nil {
+			shiftNode(doc.Node, n, lines)
+		}
+	}
+}
+
+func shiftNode(n *Node, offset, lines int) {
+	n.Line += lines
+	n.EndLine += lines
+	n.Offset += offset
+	n.EndOffset += offset
+	for _, c := range n.Content {
+		shiftNode(c, offset, lines)
+	}
+}
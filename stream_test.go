@@ -0,0 +1,217 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Streams", func() {
+	src := `# config
+a: 1
+b: [x, y]
+---
+c: &anchor
+  d: "text"
+e: *anchor
+...
+# between
+--- |
+  block
+---
+- last
+`
+
+	// expectFresh checks that s holds what parsing its text anew gives
+	expectFresh := func(s *Stream) {
+		fresh := ParseStream(append([]byte{}, s.Text()...))
+		Expect(s.Documents).To(HaveLen(len(fresh.Documents)))
+		for i, doc := range fresh.Documents {
+			got := s.Documents[i]
+			Expect(got.Start).To(Equal(doc.Start), "start of document %d", i)
+			Expect(got.End).To(Equal(doc.End), "end of document %d", i)
+			Expect(got.Node).To(Equal(doc.Node), "node of document %d", i)
+			if doc.Err == nil {
+				Expect(got.Err).NotTo(HaveOccurred())
+			} else {
+				Expect(got.Err).To(MatchError(doc.Err.Error()))
+			}
+		}
+	}
+
+	It("parses every document", func() {
+		s := ParseStream([]byte(src))
+		Expect(s.Documents).To(HaveLen(4))
+		Expect(s.Documents[0].Start).To(Equal(0))
+		Expect(s.Documents[0].Node.Content[0].Value).To(Equal("a"))
+		Expect(s.Documents[1].Node.Content[3].Kind).To(Equal(AliasNode))
+		Expect(s.Documents[2].Node.Value).To(Equal("block\n"))
+		Expect(s.Documents[2].Node.Line).To(Equal(10))
+		Expect(s.Documents[3].End).To(Equal(len(src)))
+	})
+
+	It("parses again only the documents an edit touches", func() {
+		s := ParseStream([]byte(src))
+		before := append([]*Document{}, s.Documents...)
+
+		at := strings.Index(src, "text")
+		Expect(s.Edit(at, 4, []byte("changed\n  more: text"))).To(Succeed())
+
+		Expect(s.Documents[0]).To(BeIdenticalTo(before[0]))
+		Expect(s.Documents[1]).NotTo(BeIdenticalTo(before[1]))
+		Expect(s.Documents[2]).To(BeIdenticalTo(before[2]))
+		Expect(s.Documents[3]).To(BeIdenticalTo(before[3]))
+		Expect(s.Documents[2].Node.Line).To(Equal(11))
+		expectFresh(s)
+	})
+
+	It("splits and joins documents", func() {
+		s := ParseStream([]byte(src))
+		at := strings.Index(src, "e: ")
+		Expect(s.Edit(at, 0, []byte("---\n"))).To(Succeed())
+		Expect(s.Documents).To(HaveLen(5))
+		expectFresh(s)
+
+		Expect(s.Edit(at, 4, nil)).To(Succeed())
+		Expect(s.Documents).To(HaveLen(4))
+		expectFresh(s)
+
+		Expect(s.Edit(0, len(s.Text()), []byte("only: one\n"))).To(Succeed())
+		Expect(s.Documents).To(HaveLen(1))
+		expectFresh(s)
+	})
+
+	It("keeps the documents around errors parsed", func() {
+		s := ParseStream([]byte(src))
+		at := strings.Index(src, "[x, y]")
+		Expect(s.Edit(at+1, 0, []byte("["))).To(Succeed())
+		Expect(s.Documents[0].Err).To(HaveOccurred())
+		Expect(s.Documents[1].Node).NotTo(BeNil())
+		expectFresh(s)
+
+		Expect(s.Edit(0, 0, []byte("\n\n"))).To(Succeed())
+		expectFresh(s)
+	})
+
+	It("parses a document again when an edit moves its start", func() {
+		s := ParseStream([]byte("a\n...\n---\nb\n"))
+		Expect(s.Documents).To(HaveLen(2))
+
+		Expect(s.Edit(6, 0, []byte("  "))).To(Succeed())
+		Expect(s.Documents[1].Node.Value).To(Equal("--- b"))
+		expectFresh(s)
+	})
+
+	It("keeps a document after a \"...\" followed by more on its line", func() {
+		s := ParseStream([]byte("a\n... [x\n---\nb\n"))
+		Expect(s.Documents).To(HaveLen(2))
+		Expect(s.Documents[0].Err).To(HaveOccurred())
+		Expect(s.Documents[1].Node.Value).To(Equal("b"))
+	})
+
+	It("matches a fresh parse after any sequence of edits", func() {
+		r := rand.New(rand.NewSource(1))
+		pieces := []string{"", "a", ": ", "\n", "---\n", "...\n", "- ", "[", "]", "{k: v}", "&x ", "*x", "'q'", "  ", "# c\n", "---", "...", "%YAML 1.1\n", "\"", "|\n", "? "}
+		s := ParseStream([]byte(src))
+		for i := 0; i < 300; i++ {
+			text := s.Text()
+			offset := r.Intn(len(text) + 1)
+			removed := 0
+			if offset < len(text) {
+				removed = r.Intn(len(text)-offset) % 6
+			}
+			inserted := []byte(pieces[r.Intn(len(pieces))])
+			expected := append(append(append([]byte{}, text[:offset]...), inserted...), text[offset+removed:]...)
+
+			Expect(s.Edit(offset, removed, inserted)).To(Succeed())
+			Expect(bytes.Equal(s.Text(), expected)).To(BeTrue())
+			expectFresh(s)
+		}
+	})
+
+	It("parses again only the block collection an edit touches", func() {
+		s := ParseStream([]byte("server:\n  host: example.com\n  ports:\n    - 80\n    - 443\nclient:\n  retries: 3\n---\nnext: doc\n"))
+		root, client, next := s.Documents[0].Node, s.Documents[0].Node.Content[3], s.Documents[1].Node
+		server := root.Content[1]
+
+		at := strings.Index(string(s.Text()), "443")
+		Expect(s.Edit(at, 3, []byte("8443\n    - 9443"))).To(Succeed())
+		Expect(s.Documents[0].Node).To(BeIdenticalTo(root))
+		Expect(root.Content[1]).To(BeIdenticalTo(server))
+		Expect(root.Content[3]).To(BeIdenticalTo(client))
+		Expect(s.Documents[1].Node).To(BeIdenticalTo(next))
+		Expect(server.Content[3].Content).To(HaveLen(3))
+		Expect(client.Line).To(Equal(8))
+		Expect(next.Line).To(Equal(10))
+		expectFresh(s)
+
+		at = strings.Index(string(s.Text()), "host")
+		Expect(s.Edit(at, 0, []byte("name: web\n  "))).To(Succeed())
+		Expect(root.Content[1]).NotTo(BeIdenticalTo(server))
+		Expect(root.Content[3]).To(BeIdenticalTo(client))
+		expectFresh(s)
+	})
+
+	It("parses the document again when an edit changes more than a block collection", func() {
+		src := "a:\n  b: &x 1\n  c: 2\nd:\n  e: 3\n"
+		for _, edit := range []struct {
+			at      string
+			removed int
+			text    string
+		}{
+			{"  c", 0, "x"},         // at the start of a line of the root
+			{"  e", 0, "---\n"},     // a line starting a document
+			{"3", 1, "*x"},          // an alias
+			{"c: 2", 0, "f: 0\n  "}, // within a collection with an anchor
+			{"d:", 0, "  "},         // the line ending a collection
+		} {
+			s := ParseStream([]byte(src))
+			root := s.Documents[0].Node
+			Expect(s.Edit(strings.Index(src, edit.at), edit.removed, []byte(edit.text))).To(Succeed())
+			Expect(s.Documents[0].Node).NotTo(BeIdenticalTo(root), edit.text)
+			expectFresh(s)
+		}
+	})
+
+	It("matches a fresh parse after edits within block collections", func() {
+		src := "top:\r\n  a: 1\r\n  b:\n    - x\n    - y: [1, 2]\n      z: |\n        lit\n\n  c: \"q\"\n# comment\nnext:\n  - d: e\n  -\n    f: g\n---\nlast: 1\n"
+		pieces := []string{"", "a", ": ", "\n", "\n  ", "\n    k: v", "- ", "[", "]", "&x ", "*x", "  ", "# c\n", "---", "\"", "|\n", "? ", "\r\n", "\u2028", "é"}
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 100; i++ {
+			s := ParseStream([]byte(src))
+			for j := 0; j < 3; j++ {
+				text := s.Text()
+				offset := r.Intn(len(text) + 1)
+				removed := 0
+				if offset < len(text) {
+					removed = r.Intn(len(text)-offset) % 4
+				}
+				Expect(s.Edit(offset, removed, []byte(pieces[r.Intn(len(pieces))]))).To(Succeed())
+				expectFresh(s)
+			}
+		}
+	})
+
+	It("rejects edits outside of the text", func() {
+		s := ParseStream([]byte("a: 1\n"))
+		Expect(s.Edit(3, 5, nil)).To(HaveOccurred())
+		Expect(s.Edit(-1, 0, nil)).To(HaveOccurred())
+	})
+})
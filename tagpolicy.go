@@ -0,0 +1,105 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "strings"
+
+// A TagPolicy restricts the explicit tags that the documents read by a
+// Decoder may carry, so that untrusted input can neither select the types
+// it is decoded into, such as those registered with RegisterType, nor use
+// tags that the application does not expect. Tags are given in full or in
+// the !! shorthand, and a tag ending in "*" stands for every tag starting
+// with what precedes it, such as "!*" for all the local tags.
+type TagPolicy struct {
+	// Allow are the tags the documents may carry. When it is nil, they are
+	// the CoreTags; an empty Allow allows no tag.
+	Allow []string
+
+	// Deny are tags the documents may not carry, even when Allow has
+	// them.
+	Deny []string
+}
+
+// CoreTags are the tags of the core schema, which a TagPolicy allows by
+// default.
+var CoreTags = []string{"!!null", "!!bool", "!!int", "!!float", "!!str", "!!seq", "!!map"}
+
+// RestrictTags makes the Decoder reject the documents carrying an explicit
+// tag that policy does not allow, wherever it is in the document, even in
+// a value that is skipped or decoded into a Node. The non-specific tag
+// "!", which only makes a scalar a string, is always allowed. By default,
+// documents may carry any tag, and UnknownTags selects how the values
+// with a tag that the Decoder does not know are decoded.
+func (d *Decoder) RestrictTags(policy TagPolicy) {
+	allow := policy.Allow
+	if allow == nil {
+		allow = CoreTags
+	}
+	d.tagPolicy = &tagPolicy{allow: newTagSet(allow), deny: newTagSet(policy.Deny)}
+}
+
+// WithTagPolicy restricts the tags the documents read by a Decoder may
+// carry, as RestrictTags does.
+func WithTagPolicy(policy TagPolicy) Option {
+	return DecoderOption(func(d *Decoder) { d.RestrictTags(policy) })
+}
+
+// the tags of a TagPolicy, in full
+type tagPolicy struct {
+	allow, deny tagSet
+}
+
+// a set of tags and of the prefixes of tags
+type tagSet struct {
+	tags     map[string]bool
+	prefixes []string
+}
+
+func newTagSet(tags []string) tagSet {
+	s := tagSet{tags: make(map[string]bool)}
+	for _, tag := range tags {
+		tag = longTag(tag)
+		if strings.HasSuffix(tag, "*") {
+			s.prefixes = append(s.prefixes, strings.TrimSuffix(tag, "*"))
+		} else {
+			s.tags[tag] = true
+		}
+	}
+	return s
+}
+
+func (s tagSet) contains(tag string) bool {
+	if s.tags[tag] {
+		return true
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(tag, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTag fails on the current event, as read from the input, when it
+// carries a tag the TagPolicy does not allow.
+func (d *Decoder) checkTag() {
+	tag := string(d.event.tag)
+	if tag == "" || tag == "!" {
+		return
+	}
+	if !d.tagPolicy.allow.contains(tag) || d.tagPolicy.deny.contains(tag) {
+		d.error(composingError(d.event.start_mark, "Tag '%s' is not allowed", shortTag(tag)))
+	}
+}
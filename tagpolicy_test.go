@@ -0,0 +1,75 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tag policies", func() {
+	decode := func(src string, v interface{}, opts ...Option) error {
+		return NewDecoder(bytes.NewBufferString(src), opts...).Decode(v)
+	}
+
+	It("allow any tag by default", func() {
+		var n Node
+		Expect(decode("a: !vault secret\n", &n)).To(Succeed())
+		Expect(n.Content[1].Tag).To(Equal("!vault"))
+	})
+
+	It("allow the core schema tags unless told otherwise", func() {
+		core := WithTagPolicy(TagPolicy{})
+		var v interface{}
+		Expect(decode("a: !!int 1\nb: !!str 2\nc: ! 3\nd: !!map {}\n", &v, core)).To(Succeed())
+		Expect(v).To(HaveKeyWithValue("b", "2"))
+
+		var n Node
+		err := decode("a: [1, !vault secret]\n", &n, core)
+		Expect(err).To(MatchError("Tag '!vault' is not allowed at line 0, column 7"))
+		Expect(err.(Error).Kind()).To(Equal(ComposingError))
+
+		Expect(decode("a: !!binary aGk=\n", &v, core)).To(MatchError(
+			"Tag '!!binary' is not allowed at line 0, column 3"))
+	})
+
+	It("reject tags in values that are skipped", func() {
+		var v struct{ A int }
+		d := NewDecoder(bytes.NewBufferString("a: 1\nb: !!python/object:os.system {}\n"))
+		d.RestrictTags(TagPolicy{})
+		Expect(d.Decode(&v)).To(MatchError("Tag '!!python/object:os.system' is not allowed at line 1, column 3"))
+	})
+
+	It("allow tags by prefix and deny some of them", func() {
+		policy := WithTagPolicy(TagPolicy{
+			Allow: append([]string{"!app/*", "tag:example.com,2024:*"}, CoreTags...),
+			Deny:  []string{"!app/exec", "!!float"},
+		})
+		var n Node
+		Expect(decode("%TAG !e! tag:example.com,2024:\n---\n- !app/user x\n- !e!id 1\n", &n, policy)).To(Succeed())
+		Expect(decode("- !app/exec rm\n", &n, policy)).To(MatchError(ContainSubstring("'!app/exec' is not allowed")))
+		Expect(decode("- !!float 1\n", &n, policy)).To(MatchError(ContainSubstring("'!!float' is not allowed")))
+		Expect(decode("- !other x\n", &n, policy)).To(MatchError(ContainSubstring("'!other' is not allowed")))
+	})
+
+	It("allow no tag when Allow is empty", func() {
+		var v interface{}
+		policy := WithTagPolicy(TagPolicy{Allow: []string{}})
+		Expect(decode("a: !!str x\n", &v, policy)).To(HaveOccurred())
+		Expect(decode("a: x\n", &v, policy)).To(Succeed())
+	})
+})
@@ -29,7 +29,18 @@ type field struct {
 	index     []int
 	typ       reflect.Type
 	omitEmpty bool
+	omitZero  bool
+	omitNil   bool
 	flow      bool
+	style     yaml_scalar_style_t
+	null      *string
+	secret    bool
+	anchor    string
+	alias     string
+	comment   string
+	required  bool
+	def       *string
+	explicit  bool
 }
 
 // byName sorts field by name, breaking ties with depth,
@@ -132,7 +143,10 @@ func typeFields(t reflect.Type) []field {
 						name = sf.Name
 					}
 					fields = append(fields, field{name, tagged, index, ft,
-						opts.Contains("omitempty"), opts.Contains("flow")})
+						opts.Contains("omitempty"), opts.Contains("omitzero"), opts.Contains("omitnil"), opts.Contains("flow"),
+						opts.scalarStyle(), opts.nullValue(), opts.Contains("secret"),
+						opts.value("anchor"), opts.value("alias"), sf.Tag.Get("comment"),
+						opts.Contains("required"), opts.defaultValue(), opts.Contains("tagged")})
 					if count[f.typ] > 1 {
 						// If there were multiple instances, add a second,
 						// so that the annihilation code will see a duplicate.
@@ -226,34 +240,71 @@ func dominantField(fields []field) (field, bool) {
 	return fields[0], true
 }
 
+// A structType is what encoding and decoding need to know about a struct
+// type, computed once per type: its fields, and indexes to look them up
+// by the keys of a mapping.
+type structType struct {
+	fields []field
+
+	// byName maps field names to fields.
+	byName map[string]*field
+}
+
+// fieldByName returns the field that a mapping key names: the field with
+// exactly that name, or else the first one with that name in another case.
+// Folding the case allocates nothing, as keys often differ in case from
+// the names of untagged fields.
+func (st *structType) fieldByName(name string) *field {
+	if f, ok := st.byName[name]; ok {
+		return f
+	}
+	for i := range st.fields {
+		if strings.EqualFold(st.fields[i].name, name) {
+			return &st.fields[i]
+		}
+	}
+	return nil
+}
+
 var fieldCache struct {
 	sync.RWMutex
-	m map[reflect.Type][]field
+	m map[reflect.Type]*structType
 }
 
-// cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
-func cachedTypeFields(t reflect.Type) []field {
+// cachedStructType returns the structType of t, using a cache to avoid
+// repeated work.
+func cachedStructType(t reflect.Type) *structType {
 	fieldCache.RLock()
-	f := fieldCache.m[t]
+	st := fieldCache.m[t]
 	fieldCache.RUnlock()
-	if f != nil {
-		return f
+	if st != nil {
+		return st
 	}
 
 	// Compute fields without lock.
 	// Might duplicate effort but won't hold other computations back.
-	f = typeFields(t)
-	if f == nil {
-		f = []field{}
+	fields := typeFields(t)
+	st = &structType{
+		fields: fields,
+		byName: make(map[string]*field, len(fields)),
+	}
+	for i := range fields {
+		f := &fields[i]
+		st.byName[f.name] = f
 	}
 
 	fieldCache.Lock()
 	if fieldCache.m == nil {
-		fieldCache.m = map[reflect.Type][]field{}
+		fieldCache.m = map[reflect.Type]*structType{}
 	}
-	fieldCache.m[t] = f
+	fieldCache.m[t] = st
 	fieldCache.Unlock()
-	return f
+	return st
+}
+
+// cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
+func cachedTypeFields(t reflect.Type) []field {
+	return cachedStructType(t).fields
 }
 
 // tagOptions is the string following a comma in a struct field's "json"
@@ -302,22 +353,6 @@ func typeByIndex(t reflect.Type, index []int) reflect.Type {
 	return t
 }
 
-// stringValues is a slice of reflect.Value holding *reflect.StringValue.
-// It implements the methods to sort by string.
-type stringValues []reflect.Value
-
-func (sv stringValues) Len() int      { return len(sv) }
-func (sv stringValues) Swap(i, j int) { sv[i], sv[j] = sv[j], sv[i] }
-func (sv stringValues) Less(i, j int) bool {
-	av, ak := getElem(sv[i])
-	bv, bk := getElem(sv[j])
-	if ak == reflect.String && bk == reflect.String {
-		return av.String() < bv.String()
-	}
-
-	return ak < bk
-}
-
 func getElem(v reflect.Value) (reflect.Value, reflect.Kind) {
 	k := v.Kind()
 	for k == reflect.Interface || k == reflect.Ptr && !v.IsNil() {
@@ -337,6 +372,56 @@ func parseTag(tag string) (string, tagOptions) {
 	return tag, tagOptions("")
 }
 
+// scalarStyle returns the scalar style requested by the options, if any.
+func (o tagOptions) scalarStyle() yaml_scalar_style_t {
+	switch {
+	case o.Contains("literal"):
+		return yaml_LITERAL_SCALAR_STYLE
+	case o.Contains("folded"):
+		return yaml_FOLDED_SCALAR_STYLE
+	case o.Contains("singlequoted"):
+		return yaml_SINGLE_QUOTED_SCALAR_STYLE
+	case o.Contains("doublequoted"):
+		return yaml_DOUBLE_QUOTED_SCALAR_STYLE
+	}
+	return yaml_ANY_SCALAR_STYLE
+}
+
+// nullValue returns the representation requested by a `null=` option, or
+// nil when the field has none. The Encoder ignores it unless it is one of
+// its nulls.
+func (o tagOptions) nullValue() *string {
+	for _, opt := range strings.Split(string(o), ",") {
+		if strings.HasPrefix(opt, "null=") {
+			v := opt[len("null="):]
+			return &v
+		}
+	}
+	return nil
+}
+
+// defaultValue returns the text of a `default=` option, or nil when the
+// field has none. The option takes the rest of the tag, so that the
+// value may hold commas.
+func (o tagOptions) defaultValue() *string {
+	s := "," + string(o)
+	if i := strings.Index(s, ",default="); i >= 0 {
+		v := s[i+len(",default="):]
+		return &v
+	}
+	return nil
+}
+
+// value returns the value of a `name=` option, or "" when there is none.
+func (o tagOptions) value(name string) string {
+	for _, opt := range strings.Split(string(o), ",") {
+		if strings.HasPrefix(opt, name+"=") {
+			return opt[len(name)+1:]
+		}
+	}
+	return ""
+}
+
 // Contains reports whether a comma-separated list of options
 // contains a particular substr flag. substr must be surrounded by a
 // string boundary or commas.
@@ -0,0 +1,80 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "io"
+
+// Tee makes e write its output to each of writers as well as to its own
+// writer, so that a stream is encoded once for several sinks, such as a
+// file, a hash and a network connection. Each chunk of output is written
+// to every writer in turn, even when one of them fails, and the first
+// error fails e.
+//
+// Flush and Close then also flush each writer that has a Flush method,
+// such as a bufio.Writer, and they flush them all even when one fails.
+// The sinks are flushed only then, each on its own, so that a buffered
+// one is not flushed for every chunk that the others are written. Reset
+// drops the writers added by Tee.
+func (e *Encoder) Tee(writers ...io.Writer) {
+	t, ok := e.w.(*teeWriter)
+	if !ok {
+		t = &teeWriter{writers: []io.Writer{e.w}}
+		e.w = t
+		e.emitter.output_writer = t
+	}
+	t.writers = append(t.writers, writers...)
+}
+
+// A teeWriter writes to all of its writers.
+type teeWriter struct {
+	writers []io.Writer
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	var first error
+	for _, w := range t.writers {
+		n, err := w.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return len(p), first
+}
+
+// flush flushes the writers that can be, returning the first error.
+func (t *teeWriter) flush() error {
+	var first error
+	for _, w := range t.writers {
+		if f, ok := w.(interface {
+			Flush() error
+		}); ok {
+			if err := f.Flush(); first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// flushTee flushes the writers added by Tee, if any.
+func (e *Encoder) flushTee() error {
+	if t, ok := e.w.(*teeWriter); ok {
+		return t.flush()
+	}
+	return nil
+}
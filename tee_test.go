@@ -0,0 +1,69 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tee", func() {
+	It("writes the output to every writer in one pass", func() {
+		out, file := &bytes.Buffer{}, &bytes.Buffer{}
+		buffered := bufio.NewWriter(file)
+		hash := sha256.New()
+
+		e := NewEncoder(out)
+		e.Tee(buffered, hash)
+		Expect(e.Encode(map[string]int{"a": 1})).To(Succeed())
+		Expect(e.Encode([]string{"b"})).To(Succeed())
+		Expect(out.String()).To(Equal("a: 1\n---\n- b\n"))
+		Expect(file.Len()).To(BeZero())
+
+		Expect(e.Flush()).To(Succeed())
+		Expect(file.String()).To(Equal(out.String()))
+		sum := sha256.Sum256(out.Bytes())
+		Expect(hash.Sum(nil)).To(Equal(sum[:]))
+	})
+
+	It("keeps writing to the other writers when one fails", func() {
+		out, other := &bytes.Buffer{}, &bytes.Buffer{}
+		e := NewEncoder(out)
+		e.Tee(errorWriter{}, other)
+
+		Expect(e.Encode("a")).To(MatchError("write error: closed"))
+		Expect(other.String()).To(Equal(out.String()))
+		Expect(other.String()).To(Equal("a\n"))
+	})
+
+	It("flushes the writers when the stream is closed, and drops them on Reset", func() {
+		file := &bytes.Buffer{}
+		buffered := bufio.NewWriter(file)
+		e := NewEncoder(&bytes.Buffer{})
+		e.Tee(buffered)
+		Expect(e.Encode("a")).To(Succeed())
+		Expect(e.Close()).To(Succeed())
+		Expect(file.String()).To(Equal("a\n...\n"))
+
+		e.Reset(&bytes.Buffer{})
+		Expect(e.Encode("b")).To(Succeed())
+		Expect(e.Flush()).To(Succeed())
+		Expect(file.String()).To(Equal("a\n...\n"))
+	})
+})
@@ -0,0 +1,150 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// VarTag is the tag of a scalar that is a placeholder for the variable it
+// names, which Substitute replaces.
+const VarTag = "!var"
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// Substitute replaces the placeholders in the tree n roots with the values
+// of vars, marshaled as Encode would. A placeholder is either a scalar
+// tagged VarTag whose value is the name of a variable, or a {{name}} in
+// the value of a scalar. A scalar starting with a {{name}} has to be
+// quoted, such as "{{port}}", as a plain one would be a flow mapping.
+//
+// A scalar that is a single placeholder becomes the value, of its own
+// type, such as a number or a mapping, keeping the anchor, position,
+// comments and annotations of the placeholder. A placeholder inside a longer scalar, or in
+// one with another explicit tag, is replaced by the text of the value,
+// which has to be a scalar. Substituted strings keep the quoting of the
+// placeholder, and are quoted when they would otherwise read back as
+// another type, so that the document holds the values whatever they are.
+// A *Node or Node value is substituted as it is.
+//
+// It is an error for a placeholder to name a variable missing from vars.
+func (n *Node) Substitute(vars map[string]interface{}) (err error) {
+	defer recovery(&err)
+	s := substitution{vars: vars, values: make(map[string]*Node)}
+	s.walk(n)
+	return nil
+}
+
+// the variables of Substitute, with the nodes of those marshaled so far
+type substitution struct {
+	vars   map[string]interface{}
+	values map[string]*Node
+}
+
+func (s *substitution) walk(n *Node) {
+	if n == nil {
+		return
+	}
+	if n.Kind == ScalarNode {
+		s.scalar(n)
+		return
+	}
+	for _, c := range n.Content {
+		s.walk(c)
+	}
+}
+
+func (s *substitution) scalar(n *Node) {
+	if n.Tag == VarTag {
+		s.replace(n, s.value(strings.TrimSpace(n.Value), n))
+		return
+	}
+	m := placeholderPattern.FindStringSubmatchIndex(n.Value)
+	if m == nil {
+		return
+	}
+	if n.Tag == "" && m[0] == 0 && m[1] == len(n.Value) {
+		s.replace(n, s.value(n.Value[m[2]:m[3]], n))
+		return
+	}
+
+	n.Value = placeholderPattern.ReplaceAllStringFunc(n.Value, func(p string) string {
+		name := placeholderPattern.FindStringSubmatch(p)[1]
+		v := s.value(name, n)
+		if v.Kind != ScalarNode {
+			panic(fmt.Errorf("Cannot substitute the %s '%s' inside the scalar at line %d, column %d", v.Kind, name, n.Line, n.Column))
+		}
+		return v.Value
+	})
+	if n.Tag == "" && (n.Style == AnyStyle || n.Style == PlainStyle) && !plainString(n.Value) {
+		n.Style = DoubleQuotedStyle
+	}
+}
+
+// replace replaces the placeholder n with a copy of the node of its value.
+func (s *substitution) replace(n, v *Node) {
+	c := copyNode(v)
+	if c.Kind == ScalarNode && c.Tag == "" && n.Tag != VarTag &&
+		(n.Style == SingleQuotedStyle || n.Style == DoubleQuotedStyle) &&
+		(c.Style != AnyStyle && c.Style != PlainStyle || plainString(c.Value)) {
+		c.Style = n.Style
+	}
+	c.Anchor = n.Anchor
+	c.Line, c.Column = n.Line, n.Column
+	c.EndLine, c.EndColumn = n.EndLine, n.EndColumn
+	c.Offset, c.EndOffset = n.Offset, n.EndOffset
+	c.HeadComment, c.LineComment, c.FootComment = n.HeadComment, n.LineComment, n.FootComment
+	c.TagDirectives = n.TagDirectives
+	c.Annotations = mergeAnnotations(c.Annotations, n.Annotations)
+	*n = *c
+}
+
+// value returns the node of the variable name, which the placeholder at
+// refers to.
+func (s *substitution) value(name string, at *Node) *Node {
+	if v, ok := s.values[name]; ok {
+		return v
+	}
+	x, ok := s.vars[name]
+	if !ok {
+		panic(fmt.Errorf("Unknown placeholder '%s' at line %d, column %d", name, at.Line, at.Column))
+	}
+
+	var v *Node
+	switch x := x.(type) {
+	case *Node:
+		v = x
+	case Node:
+		v = &x
+	}
+	if v == nil {
+		src, err := Marshal(x)
+		if err != nil {
+			panic(err)
+		}
+		v = composeNodes(src)[0]
+	}
+	s.values[name] = v
+	return v
+}
+
+// plainString reports whether value reads back as a string when it is
+// written as a plain scalar.
+func plainString(value string) bool {
+	tag, _ := resolveInterface(yaml_event_t{implicit: true, value: []byte(value)}, false)
+	return tag == yaml_STR_TAG
+}
@@ -0,0 +1,100 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Substitute", func() {
+	substitute := func(src string, vars map[string]interface{}) (string, error) {
+		var n Node
+		Expect(Unmarshal([]byte(src), &n)).To(Succeed())
+		if err := n.Substitute(vars); err != nil {
+			return "", err
+		}
+		out, err := Marshal(&n)
+		Expect(err).NotTo(HaveOccurred())
+		return string(out), nil
+	}
+
+	It("substitutes typed values for whole placeholders", func() {
+		out, err := substitute("port: \"{{port}}\"\ndebug: !var debug\nhosts: '{{ hosts }}'\nname: \"{{name}}\"\n",
+			map[string]interface{}{
+				"port":  8080,
+				"debug": true,
+				"hosts": []string{"a", "b"},
+				"name":  "web",
+			})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("port: 8080\ndebug: true\nhosts:\n- a\n- b\nname: \"web\"\n"))
+
+		var v struct {
+			Port  int
+			Debug bool
+			Hosts []string
+			Name  string
+		}
+		Expect(Unmarshal([]byte(out), &v)).To(Succeed())
+		Expect(v.Port).To(Equal(8080))
+		Expect(v.Debug).To(BeTrue())
+		Expect(v.Hosts).To(Equal([]string{"a", "b"}))
+	})
+
+	It("quotes strings that would read back as another type", func() {
+		out, err := substitute("a: !var version\nb: \"{{version}}\"\nc: !var empty\n",
+			map[string]interface{}{"version": "1.10", "empty": ""})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("a: \"1.10\"\nb: \"1.10\"\nc: \"\"\n"))
+	})
+
+	It("replaces placeholders inside scalars with the text of the values", func() {
+		out, err := substitute("url: http://{{host}}:{{port}}/\nflag: '{{on}}'\nplain: x{{on}}\n",
+			map[string]interface{}{"host": "example.com", "port": 80, "on": "yes"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("url: http://example.com:80/\nflag: 'yes'\nplain: xyes\n"))
+
+		out, err = substitute("v: 1.{{minor}}\n", map[string]interface{}{"minor": 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("v: \"1.2\"\n"))
+	})
+
+	It("keeps the anchors and comments of placeholders", func() {
+		var n Node
+		Expect(Unmarshal([]byte("a: &p \"{{p}}\"\nb: *p\n"), &n)).To(Succeed())
+		n.Content[1].LineComment = "# port"
+		Expect(n.Substitute(map[string]interface{}{"p": 22})).To(Succeed())
+		out, err := Marshal(&n)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("a: &p 22 # port\nb: *p\n"))
+	})
+
+	It("substitutes nodes as they are", func() {
+		var value Node
+		Expect(Unmarshal([]byte("{x: 'y'}"), &value)).To(Succeed())
+		out, err := substitute("v: !var v\n", map[string]interface{}{"v": &value})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("v: {x: 'y'}\n"))
+	})
+
+	It("fails on unknown placeholders and collections inside scalars", func() {
+		_, err := substitute("a: 1\nb: \"{{missing}}\"\n", nil)
+		Expect(err).To(MatchError("Unknown placeholder 'missing' at line 2, column 4"))
+
+		_, err = substitute("a: x{{list}}\n", map[string]interface{}{"list": []int{1}})
+		Expect(err).To(MatchError("Cannot substitute the sequence 'list' inside the scalar at line 1, column 4"))
+	})
+})
@@ -0,0 +1,90 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "errors"
+
+// A TextFormat is what a Node leaves out of the text of its source: the
+// values of a Node hold line breaks as "\n" and no byte order mark,
+// whatever the source used.
+type TextFormat struct {
+	// BOM is true when the source starts with a UTF-8 byte order mark.
+	BOM bool
+
+	// LineBreak is the line break the source uses the most, the first
+	// of them on a tie, or AnyBreak when it has none. MixedBreaks is true
+	// when the source uses others as well.
+	LineBreak   LineBreak
+	MixedBreaks bool
+}
+
+// NormalizeText sets whether the root Node of each document decoded
+// records the TextFormat of the source in its Text, so that encoding the
+// Node writes the byte order mark and the line breaks of the source back.
+// The line breaks of a source mixing them are all written as the one it
+// uses the most, so that a file is written back consistently.
+func (d *Decoder) NormalizeText(on bool) {
+	d.normalizeText = on
+}
+
+// textFormat returns the TextFormat of the input read so far.
+func (d *Decoder) textFormat() TextFormat {
+	f := TextFormat{BOM: d.parser.has_bom}
+	counts := d.parser.break_counts
+	first := d.parser.line_break
+	if first == yaml_ANY_BREAK {
+		return f
+	}
+
+	brk, kinds := first, 0
+	for b := yaml_CR_BREAK; b <= yaml_CRLN_BREAK; b++ {
+		if counts[b] > 0 {
+			kinds++
+		}
+		if counts[b] > counts[brk] {
+			brk = b
+		}
+	}
+	f.LineBreak = LineBreak(brk)
+	f.MixedBreaks = kinds > 1
+	return f
+}
+
+// nodeText returns the Text of v when it is a Node or a pointer to one.
+func nodeText(v interface{}) TextFormat {
+	switch v := v.(type) {
+	case Node:
+		return v.Text
+	case *Node:
+		if v != nil {
+			return v.Text
+		}
+	}
+	return TextFormat{}
+}
+
+// startText starts the stream written by e, in the TextFormat of v, the
+// first document: a Node with a Text gives the line breaks, unless
+// SetLineBreak chose them, and the byte order mark of a UTF-8 stream.
+func (e *Encoder) startText(v interface{}) {
+	text := nodeText(v)
+	if text.LineBreak != AnyBreak && e.lineBreak == yaml_ANY_BREAK {
+		yaml_emitter_set_break(&e.emitter, yaml_break_t(text.LineBreak))
+	}
+	e.start()
+	if text.BOM && e.emitter.encoding == yaml_UTF8_ENCODING && !yaml_emitter_write_bom(&e.emitter) {
+		panic(errors.New(e.emitter.problem))
+	}
+}
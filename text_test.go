@@ -0,0 +1,78 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NormalizeText", func() {
+	decode := func(src string, normalize bool) *Node {
+		d := NewDecoder(bytes.NewBufferString(src))
+		d.NormalizeText(normalize)
+		var n Node
+		Expect(d.Decode(&n)).To(Succeed())
+		return &n
+	}
+
+	encode := func(n *Node) string {
+		out, err := Marshal(n)
+		Expect(err).NotTo(HaveOccurred())
+		return string(out)
+	}
+
+	It("records the byte order mark and line breaks of the source", func() {
+		n := decode("\xef\xbb\xbfa: |\r\n  x\r\n  y\r\nb: 2\r\n", true)
+		Expect(n.Text).To(Equal(TextFormat{BOM: true, LineBreak: CRLFBreak}))
+		Expect(n.Content[1].Value).To(Equal("x\ny\n"))
+		Expect(n.Content[1].Text).To(Equal(TextFormat{}))
+
+		Expect(encode(n)).To(Equal("\xef\xbb\xbfa: |\r\n  x\r\n  y\r\nb: 2\r\n"))
+	})
+
+	It("writes the line break a mixed source uses the most", func() {
+		n := decode("a: 1\nb: 2\r\nc: 3\r\n", true)
+		Expect(n.Text).To(Equal(TextFormat{LineBreak: CRLFBreak, MixedBreaks: true}))
+		Expect(encode(n)).To(Equal("a: 1\r\nb: 2\r\nc: 3\r\n"))
+
+		n = decode("a: 1\rb: 2\n", true)
+		Expect(n.Text).To(Equal(TextFormat{LineBreak: CRBreak, MixedBreaks: true}))
+	})
+
+	It("is off by default", func() {
+		n := decode("\xef\xbb\xbfa: 1\r\n", false)
+		Expect(n.Text).To(Equal(TextFormat{}))
+		Expect(encode(n)).To(Equal("a: 1\n"))
+	})
+
+	It("leaves the line breaks set on the Encoder", func() {
+		n := decode("a: 1\r\n", true)
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SetLineBreak(LFBreak)
+		Expect(e.Encode(n)).To(Succeed())
+		Expect(buf.String()).To(Equal("a: 1\n"))
+
+		buf.Reset()
+		e = NewEncoder(&buf)
+		Expect(e.Encode(n)).To(Succeed())
+		e.Reset(&buf)
+		Expect(e.Encode(map[string]int{"b": 2})).To(Succeed())
+		Expect(buf.String()).To(Equal("a: 1\r\nb: 2\n"))
+	})
+})
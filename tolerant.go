@@ -0,0 +1,274 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// A Diagnostic describes an error in the input that a Decoder tolerating
+// errors skipped.
+type Diagnostic struct {
+	// Context and Message describe the error, as the Context and Problem
+	// of a ParserError do.
+	Context string
+	Message string
+
+	// Position is where the error was found.
+	Position Position
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("[%s] %s at line %d, column %d", d.Context, d.Message, d.Position.Line, d.Position.Column)
+}
+
+// a line of the input blanked to skip an error
+type skippedLine struct {
+	line, column int
+	message      string
+}
+
+// TolerateErrors sets whether the Decoder skips the parts of the input that
+// fail to parse instead of stopping at the first syntax error, which gives
+// editors a best-effort Node tree of a document being typed.
+//
+// The whole input is read before the first document is decoded, and must
+// be UTF-8. The line of each syntax error, or of the flow collection or
+// scalar it was found in, is blanked until the rest of the input parses.
+// After each blanked line, parsing resumes at the end of the last document
+// before it, so the cost grows with the number of errors times the size of
+// the documents holding them rather than of the whole input. The
+// errors are recorded in Diagnostics, and a Node decoded holds an
+// ErrorNode where each line skipped within it was; in a mapping, the
+// ErrorNode is a key with an empty value. Aliases to anchors that are not
+// defined are recorded as errors and decoded as ErrorNodes as well.
+func (d *Decoder) TolerateErrors(tolerate bool) {
+	d.tolerant = tolerate
+}
+
+// Diagnostics returns the errors skipped so far when tolerating them, in
+// the order they were found.
+func (d *Decoder) Diagnostics() []Diagnostic {
+	return d.diagnostics
+}
+
+// skipErrors reads the whole input and blanks the lines that fail to parse
+// until the rest of it parses, resuming each time at the last document
+// boundary before the line blanked.
+func (d *Decoder) skipErrors() {
+	parser := &d.parser
+	text := parser.input
+	if parser.input_reader != nil {
+		var err error
+		if text, err = ioutil.ReadAll(parser.input_reader); err != nil {
+			d.error(err)
+		}
+	}
+
+	// blanking lines keeps their spans
+	spans := lineSpans(text)
+
+	// the lines starting the documents parsed so far: blanking a line after
+	// one leaves the documents before it as they parsed
+	var boundaries []int
+	from := 0
+
+	copied := false
+	for {
+		diagnostic, line, found := syntaxError(text, spans, from)
+		boundaries = append(boundaries, found...)
+		if diagnostic == nil {
+			break
+		}
+		d.diagnostics = append(d.diagnostics, *diagnostic)
+
+		if !copied {
+			text, copied = append([]byte(nil), text...), true
+		}
+
+		// an error found on an empty line, as at the end of the input, is
+		// caused by the last line before it that holds anything
+		var skipped *skippedLine
+		for ; skipped == nil && line >= 0; line-- {
+			skipped = blankLine(text, spans, line)
+		}
+		if skipped == nil {
+			break
+		}
+		skipped.message = diagnostic.Message
+		d.skipped = append(d.skipped, *skipped)
+
+		for len(boundaries) > 0 && boundaries[len(boundaries)-1] >= skipped.line {
+			boundaries = boundaries[:len(boundaries)-1]
+		}
+		from = 0
+		if len(boundaries) > 0 {
+			from = boundaries[len(boundaries)-1]
+		}
+	}
+	sort.SliceStable(d.skipped, func(i, j int) bool { return d.skipped[i].line < d.skipped[j].line })
+
+	parser.read_handler = yaml_string_read_handler
+	parser.input, parser.input_pos, parser.input_reader = text, 0, nil
+}
+
+// syntaxError parses text from the start of the line from, which starts a
+// document, and returns the first error found, the line to skip for it and
+// the lines starting the documents after the first one parsed.
+func syntaxError(text []byte, spans [][2]int, from int) (*Diagnostic, int, []int) {
+	var parser yaml_parser_t
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_string(&parser, text[spans[from][0]:])
+
+	var boundaries []int
+	var event yaml_event_t
+	for yaml_parser_parse(&parser, &event) {
+		switch event.event_type {
+		case yaml_STREAM_END_EVENT:
+			return nil, 0, boundaries
+		case yaml_DOCUMENT_END_EVENT:
+			// the next document starts at the line of its document start
+			// marker, or after the document end marker, where its
+			// directives do
+			line := from + event.start_mark.line
+			if !event.implicit {
+				line++
+			}
+			if event.start_mark.column == 0 && line > from && line < len(spans) {
+				boundaries = append(boundaries, line)
+			}
+		}
+	}
+
+	if parser.error == yaml_READER_ERROR {
+		// reader errors only know their byte offset
+		offset := spans[from][0] + parser.problem_offset
+		line := sort.Search(len(spans), func(i int) bool { return spans[i][1] >= offset })
+		return &Diagnostic{
+			Message:  parser.problem,
+			Position: Position{Line: line + 1, Column: offset - spans[line][0] + 1},
+		}, line, boundaries
+	}
+
+	// the error is on the line of the problem, unless it is in a flow
+	// collection or a scalar started on an earlier line
+	line := parser.problem_mark.line
+	if parser.context != "" && parser.context_mark.line < line &&
+		!strings.HasPrefix(parser.context, "while parsing a block") {
+		line = parser.context_mark.line
+	}
+	return &Diagnostic{
+		Context:  parser.context,
+		Message:  parser.problem,
+		Position: Position{Line: from + parser.problem_mark.line + 1, Column: parser.problem_mark.column + 1},
+	}, from + line, boundaries
+}
+
+var (
+	nextLine           = []byte("\u0085")
+	lineSeparator      = []byte("\u2028")
+	paragraphSeparator = []byte("\u2029")
+)
+
+// lineSpans returns the start and end offsets of the lines of text,
+// without their breaks.
+func lineSpans(text []byte) [][2]int {
+	var spans [][2]int
+	start := 0
+	for i := 0; i < len(text); {
+		n := 0
+		switch {
+		case text[i] == '\r' && i+1 < len(text) && text[i+1] == '\n':
+			n = 2
+		case text[i] == '\r' || text[i] == '\n':
+			n = 1
+		case bytes.HasPrefix(text[i:], nextLine):
+			n = len(nextLine)
+		case bytes.HasPrefix(text[i:], lineSeparator), bytes.HasPrefix(text[i:], paragraphSeparator):
+			n = len(lineSeparator)
+		}
+		if n == 0 {
+			i++
+			continue
+		}
+		spans = append(spans, [2]int{start, i})
+		i += n
+		start = i
+	}
+	return append(spans, [2]int{start, len(text)})
+}
+
+// blankLine replaces a line of text with spaces, and returns it if it held
+// anything but spaces, or nil.
+func blankLine(text []byte, spans [][2]int, line int) *skippedLine {
+	if line >= len(spans) {
+		return nil
+	}
+	var skipped *skippedLine
+	start, end := spans[line][0], spans[line][1]
+	for i := start; i < end; i++ {
+		if text[i] != ' ' {
+			if skipped == nil {
+				skipped = &skippedLine{line: line, column: i - start}
+			}
+			text[i] = ' '
+		}
+	}
+	return skipped
+}
+
+// errorNodes pushes on the node stack, as content of n, an ErrorNode for
+// each line skipped before the current event that belongs in it: lines
+// indented less than a block collection belong to an enclosing one.
+func (d *Decoder) errorNodes(n *Node) {
+	for len(d.skipped) > 0 {
+		s := d.skipped[0]
+		if s.line >= d.event.start_mark.line || !n.Flow && s.column < n.Column-1 {
+			return
+		}
+		d.skipped = d.skipped[1:]
+
+		e := &Node{Kind: ErrorNode, Value: s.message, Line: s.line + 1, Column: s.column + 1}
+		d.nodeStack = append(d.nodeStack, e)
+		if n.Kind == MappingNode {
+			d.nodeStack = append(d.nodeStack, &Node{Kind: ScalarNode, Line: e.Line, Column: e.Column})
+		}
+	}
+}
+
+// dropSkipped drops the lines skipped before the current event that no
+// Node took.
+func (d *Decoder) dropSkipped() {
+	for len(d.skipped) > 0 && d.skipped[0].line < d.event.start_mark.line {
+		d.skipped = d.skipped[1:]
+	}
+}
+
+// missingAnchor records the current alias, whose anchor is not defined, as
+// an error, and returns an ErrorNode in its place.
+func (d *Decoder) missingAnchor() *Node {
+	message := fmt.Sprintf("missing anchor: '%s'", d.event.anchor)
+	position := Position{
+		Line:   d.event.start_mark.line + 1,
+		Column: d.event.start_mark.column + 1,
+	}
+	d.diagnostics = append(d.diagnostics, Diagnostic{Message: message, Position: position})
+	d.nextEvent()
+	return &Node{Kind: ErrorNode, Value: message, Line: position.Line, Column: position.Column, Offset: d.event.start_mark.offset}
+}
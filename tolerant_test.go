@@ -0,0 +1,137 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tolerating errors", func() {
+	decode := func(input string) (*Node, *Decoder) {
+		d := NewDecoder(strings.NewReader(input))
+		d.TolerateErrors(true)
+		var n Node
+		Expect(d.Decode(&n)).To(Succeed())
+		return &n, d
+	}
+
+	It("skips the line of an error and parses the rest", func() {
+		n, d := decode("a:\n  b: 1\n  c d: : x\n  e: 2\nf: 3\n")
+
+		Expect(d.Diagnostics()).To(Equal([]Diagnostic{{
+			Message:  "mapping values are not allowed in this context",
+			Position: Position{Line: 3, Column: 8},
+		}}))
+
+		Expect(n.Content).To(HaveLen(4))
+		inner := n.Content[1]
+		Expect(inner.Content).To(HaveLen(6))
+		Expect(inner.Content[0].Value).To(Equal("b"))
+		Expect(*inner.Content[2]).To(Equal(Node{
+			Kind:   ErrorNode,
+			Value:  "mapping values are not allowed in this context",
+			Line:   3,
+			Column: 3,
+		}))
+		Expect(inner.Content[3].Kind).To(Equal(ScalarNode))
+		Expect(inner.Content[4].Value).To(Equal("e"))
+		Expect(n.Content[2].Value).To(Equal("f"))
+	})
+
+	It("skips the line a flow collection or a scalar starts on", func() {
+		n, d := decode("a: 1\nb: [x,\nc: 3\nd: \"open\n")
+
+		Expect(d.Diagnostics()).To(HaveLen(2))
+		Expect(d.Diagnostics()[0].Context).To(Equal("while parsing a flow sequence"))
+		Expect(d.Diagnostics()[1].Context).To(Equal("while scanning a quoted scalar"))
+
+		var keys []string
+		for i := 0; i < len(n.Content); i += 2 {
+			keys = append(keys, n.Content[i].Value)
+		}
+		Expect(keys).To(Equal([]string{"a", "did not find expected ',' or ']'", "c", "found unexpected end of stream"}))
+		Expect(n.Content[2].Line).To(Equal(2))
+		Expect(n.Content[6].Line).To(Equal(4))
+	})
+
+	It("adds the lines less indented than a collection to the one enclosing it", func() {
+		n, _ := decode("- - a\n  - b\n ]\n- d\n")
+
+		Expect(n.Content).To(HaveLen(3))
+		Expect(n.Content[0].Content).To(HaveLen(2))
+		Expect(n.Content[1].Kind).To(Equal(ErrorNode))
+		Expect(n.Content[2].Value).To(Equal("d"))
+
+		n, _ = decode("- - a\n  - b\n  ]\n- d\n")
+
+		Expect(n.Content).To(HaveLen(2))
+		Expect(n.Content[0].Content).To(HaveLen(3))
+		Expect(n.Content[0].Content[2].Kind).To(Equal(ErrorNode))
+	})
+
+	It("decodes aliases to undefined anchors as errors", func() {
+		n, d := decode("- *nope\n- b\n")
+
+		Expect(n.Content[0].Kind).To(Equal(ErrorNode))
+		Expect(n.Content[1].Value).To(Equal("b"))
+		Expect(d.Diagnostics()).To(Equal([]Diagnostic{{
+			Message:  "missing anchor: 'nope'",
+			Position: Position{Line: 1, Column: 3},
+		}}))
+	})
+
+	It("keeps the errors of each document to its own Nodes", func() {
+		d := NewDecoder(strings.NewReader("a: 1\nb c: d: e\n---\n- x\n"))
+		d.TolerateErrors(true)
+
+		var v map[string]interface{}
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(v).To(Equal(map[string]interface{}{"a": int64(1)}))
+
+		var n Node
+		Expect(d.Decode(&n)).To(Succeed())
+		Expect(n.Content).To(HaveLen(1))
+		Expect(d.Diagnostics()).To(HaveLen(1))
+	})
+
+	It("skips the errors of later documents without changing the earlier ones", func() {
+		d := NewDecoder(strings.NewReader("a: 1\n---\nb c: d: e\n...\n%TAG !e! tag:example.com,2000:\n--- !e!m\nf: [x,\ng: 2\n"))
+		d.TolerateErrors(true)
+
+		var first, second, third Node
+		Expect(d.Decode(&first)).To(Succeed())
+		Expect(d.Decode(&second)).To(Succeed())
+		Expect(d.Decode(&third)).To(Succeed())
+
+		Expect(first.Content).To(HaveLen(2))
+		Expect(second.Kind).To(Equal(ScalarNode))
+		Expect(third.Tag).To(Equal("tag:example.com,2000:m"))
+		Expect(third.Content).To(HaveLen(2))
+		Expect(third.Content[0].Value).To(Equal("g"))
+
+		Expect(d.Diagnostics()).To(HaveLen(2))
+		Expect(d.Diagnostics()[0].Position).To(Equal(Position{Line: 3, Column: 7}))
+		Expect(d.Diagnostics()[1].Context).To(Equal("while parsing a flow sequence"))
+		Expect(d.Diagnostics()[1].Position).To(Equal(Position{Line: 9, Column: 1}))
+	})
+
+	It("stops at the first error otherwise", func() {
+		var n Node
+		Expect(Unmarshal([]byte("a: 1\nb c: d: e\n"), &n)).To(HaveOccurred())
+	})
+})
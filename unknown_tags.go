@@ -0,0 +1,131 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"reflect"
+	"strings"
+)
+
+// An UnknownTagPolicy selects how the decoder handles values carrying a
+// tag it does not know, such as `!vault` or `!!python/unicode`.
+type UnknownTagPolicy int
+
+const (
+	// ErrorOnUnknownTags fails the decoding.
+	ErrorOnUnknownTags UnknownTagPolicy = iota
+	// IgnoreUnknownTags drops the tag and resolves the value by its kind.
+	IgnoreUnknownTags
+	// PreserveUnknownTags decodes values destined for an interface{} as
+	// TaggedValues and ignores the tag for other destinations.
+	PreserveUnknownTags
+)
+
+// A TaggedValue is a value decoded together with its explicit tag. Encoding
+// a TaggedValue writes the value with the tag. Decoding into a TaggedValue
+// always records the tag, whatever the policy.
+type TaggedValue struct {
+	Tag   string
+	Value interface{}
+}
+
+var taggedValueType = reflect.TypeOf(TaggedValue{})
+
+var known_tags = map[string]bool{
+	"":                 true,
+	"!":                true,
+	"!binary":          true,
+	yaml_NULL_TAG:      true,
+	yaml_BOOL_TAG:      true,
+	yaml_STR_TAG:       true,
+	yaml_INT_TAG:       true,
+	yaml_FLOAT_TAG:     true,
+	yaml_TIMESTAMP_TAG: true,
+	yaml_SEQ_TAG:       true,
+	yaml_MAP_TAG:       true,
+	yaml_BINARY_TAG:    true,
+	yaml_SET_TAG:       true,
+	yaml_OMAP_TAG:      true,
+	yaml_PAIRS_TAG:     true,
+	yaml_MERGE_TAG:     true,
+	yaml_VALUE_TAG:     true,
+}
+
+// UnknownTags sets the policy for values with unknown tags. The default is
+// ErrorOnUnknownTags. Tags registered with RegisterType are known.
+func (d *Decoder) UnknownTags(policy UnknownTagPolicy) {
+	d.unknownTags = policy
+}
+
+// shortTag returns the !! shorthand of tags in the YAML namespace.
+func shortTag(tag string) string {
+	if strings.HasPrefix(tag, "tag:yaml.org,2002:") {
+		return "!!" + tag[len("tag:yaml.org,2002:"):]
+	}
+	return tag
+}
+
+// hasUnknownTag reports whether the current event carries an unknown tag
+// that the policy has to deal with.
+func (d *Decoder) hasUnknownTag() bool {
+	if d.tagChecked {
+		d.tagChecked = false
+		return false
+	}
+
+	switch d.event.event_type {
+	case yaml_SCALAR_EVENT, yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+	default:
+		return false
+	}
+
+	tag := string(d.event.tag)
+	if known_tags[tag] {
+		return false
+	}
+	if _, ok := registeredType(tag); ok {
+		return false
+	}
+	return true
+}
+
+// taggedValue decodes the current value into v, which is a TaggedValue or
+// an interface{} that receives one, and returns true. It returns false when
+// the value is not to be decoded as a TaggedValue.
+func (d *Decoder) taggedValue(v reflect.Value) bool {
+	isTagged := v.Type() == taggedValueType
+	if !isTagged && (d.event.event_type == yaml_ALIAS_EVENT || !d.hasUnknownTag()) {
+		return false
+	}
+
+	if !isTagged {
+		switch d.unknownTags {
+		case ErrorOnUnknownTags:
+			d.error(decodingError(d.event.start_mark, "Unknown tag '%s'", shortTag(string(d.event.tag))))
+		case IgnoreUnknownTags:
+			return false
+		}
+		if v.Kind() != reflect.Interface || v.NumMethod() != 0 {
+			return false
+		}
+	}
+
+	tv := TaggedValue{Tag: shortTag(string(d.event.tag))}
+	d.tagChecked = true
+	d.parse(reflect.ValueOf(&tv.Value))
+	d.tagChecked = false
+	v.Set(reflect.ValueOf(tv))
+	return true
+}
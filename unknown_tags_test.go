@@ -0,0 +1,111 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unknown tags", func() {
+	doc := `password: !vault abc
+name: !!python/unicode bob
+port: !custom 80
+db: !custom
+  host: h
+`
+
+	decode := func(policy UnknownTagPolicy, v interface{}) error {
+		d := NewDecoder(strings.NewReader(doc))
+		d.UnknownTags(policy)
+		return d.Decode(v)
+	}
+
+	It("fails by default", func() {
+		var v interface{}
+		err := NewDecoder(strings.NewReader(doc)).Decode(&v)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("password: Unknown tag '!vault' at line 0, column 10"))
+	})
+
+	It("reports tags in the YAML namespace in their short form", func() {
+		var v string
+		err := Unmarshal([]byte("!!python/unicode bob\n"), &v)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unknown tag '!!python/unicode'"))
+	})
+
+	It("accepts standard and non-specific tags", func() {
+		var v interface{}
+		err := Unmarshal([]byte("a: !!str 1\nb: ! 2\nc: !!set {x}\n"), &v)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("ignores unknown tags", func() {
+		var v map[string]interface{}
+		Expect(decode(IgnoreUnknownTags, &v)).To(Succeed())
+		Expect(v).To(Equal(map[string]interface{}{
+			"password": "abc",
+			"name":     "bob",
+			"port":     int64(80),
+			"db":       map[interface{}]interface{}{"host": "h"},
+		}))
+	})
+
+	It("preserves unknown tags in interface{} values", func() {
+		var v map[string]interface{}
+		Expect(decode(PreserveUnknownTags, &v)).To(Succeed())
+		Expect(v).To(Equal(map[string]interface{}{
+			"password": TaggedValue{"!vault", "abc"},
+			"name":     TaggedValue{"!!python/unicode", "bob"},
+			"port":     TaggedValue{"!custom", int64(80)},
+			"db":       TaggedValue{"!custom", map[interface{}]interface{}{"host": "h"}},
+		}))
+	})
+
+	It("ignores preserved tags for typed destinations", func() {
+		var v struct {
+			Password string `yaml:"password"`
+			Port     int    `yaml:"port"`
+		}
+		Expect(decode(PreserveUnknownTags, &v)).To(Succeed())
+		Expect(v.Password).To(Equal("abc"))
+		Expect(v.Port).To(Equal(80))
+	})
+
+	It("decodes into TaggedValue fields", func() {
+		var v struct {
+			Password TaggedValue `yaml:"password"`
+			Plain    TaggedValue `yaml:"plain"`
+		}
+		Expect(Unmarshal([]byte("password: !vault abc\nplain: x\n"), &v)).To(Succeed())
+		Expect(v.Password).To(Equal(TaggedValue{"!vault", "abc"}))
+		Expect(v.Plain).To(Equal(TaggedValue{"", "x"}))
+	})
+
+	It("encodes TaggedValues with their tag", func() {
+		out, err := Marshal(map[string]interface{}{
+			"db":       TaggedValue{"!custom", map[string]string{"host": "h"}},
+			"password": TaggedValue{"!vault", "abc"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`db: !custom
+  host: h
+password: !vault abc
+`))
+	})
+})
@@ -0,0 +1,93 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "bytes"
+
+// KeepVerbatim sets whether the scalar Nodes decoded keep their text in
+// the source as their Verbatim, so that encoding them writes the scalars
+// that were not changed byte for byte as they were, such as "caf\u00e9"
+// or 0x1F, and a rewritten document differs from its source only in the
+// values changed. The text of the input read is kept while a Node is
+// decoded, and only a UTF-8 input keeps it.
+func (d *Decoder) KeepVerbatim(keep bool) {
+	d.parser.keep_source = keep
+}
+
+// trimSource drops the input kept before the current event, which starts
+// a Node that no other Node decoded later comes before.
+func (d *Decoder) trimSource() {
+	p := &d.parser
+	if n := d.event.start_mark.offset - p.source_offset; n > 0 && n <= len(p.source) {
+		p.source = append(p.source[:0], p.source[n:]...)
+		p.source_offset = d.event.start_mark.offset
+	}
+}
+
+// verbatim returns the text of the scalar at the current event, without
+// its anchor and tag, or "" when it spans several lines or is not in the
+// input kept.
+func (d *Decoder) verbatim() string {
+	p := &d.parser
+	start, end := d.event.start_mark.offset-p.source_offset, d.event.end_mark.offset-p.source_offset
+	if p.encoding != yaml_UTF8_ENCODING || start < 0 || end > len(p.source) || start >= end {
+		return ""
+	}
+
+	text := p.source[start:end]
+	for len(text) > 0 && (text[0] == '&' || text[0] == '!') {
+		i := bytes.IndexAny(text, " \t\r\n")
+		if i < 0 {
+			return ""
+		}
+		text = bytes.TrimLeft(text[i:], " \t")
+	}
+	if len(text) == 0 || bytes.ContainsAny(text, "\r\n") {
+		return ""
+	}
+	return string(text)
+}
+
+// readsAs reports whether the text of a scalar reads alone as the value
+// in the style, or in any style when style is AnyStyle.
+func readsAs(text, value string, style ScalarStyle) bool {
+	var parser yaml_parser_t
+	yaml_parser_initialize(&parser)
+	yaml_parser_set_input_string(&parser, []byte(text))
+
+	var scalar yaml_event_t
+	for {
+		var event yaml_event_t
+		if !yaml_parser_parse(&parser, &event) {
+			return false
+		}
+		switch event.event_type {
+		case yaml_STREAM_START_EVENT, yaml_DOCUMENT_START_EVENT, yaml_DOCUMENT_END_EVENT:
+			if event.event_type == yaml_DOCUMENT_START_EVENT && !event.implicit {
+				return false
+			}
+		case yaml_SCALAR_EVENT:
+			if scalar.event_type != yaml_NO_EVENT || len(event.tag) > 0 || len(event.anchor) > 0 {
+				return false
+			}
+			scalar = event
+		case yaml_STREAM_END_EVENT:
+			return scalar.event_type == yaml_SCALAR_EVENT && string(scalar.value) == value &&
+				(style == AnyStyle || ScalarStyle(scalar.style) == style)
+		default:
+			return false
+		}
+	}
+}
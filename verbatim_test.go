@@ -0,0 +1,70 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verbatim scalars", func() {
+	const src = "name: \"caf\\u00e9\"\nmask: 0x1F\n\"k\\x41\": &a !!str 012\nlist: [ 'it''s' , 1_000 ]\nalias: *a\nmulti: \"a\n  b\"\n"
+
+	decode := func(keep bool) *Node {
+		d := NewDecoder(bytes.NewReader([]byte(src)))
+		d.KeepVerbatim(keep)
+		n := &Node{}
+		Expect(d.Decode(n)).To(Succeed())
+		return n
+	}
+
+	encode := func(n *Node) string {
+		out, err := Marshal(n)
+		Expect(err).NotTo(HaveOccurred())
+		return string(out)
+	}
+
+	It("keeps the text of single-line scalars", func() {
+		n := decode(true)
+		Expect(n.Content[1].Value).To(Equal("café"))
+		Expect(n.Content[1].Verbatim).To(Equal(`"caf\u00e9"`))
+		Expect(n.Content[4].Verbatim).To(Equal(`"k\x41"`))
+		Expect(n.Content[5].Verbatim).To(Equal("012"))
+		Expect(n.Content[7].Content[0].Verbatim).To(Equal("'it''s'"))
+		Expect(n.Content[11].Value).To(Equal("a b"))
+		Expect(n.Content[11].Verbatim).To(BeEmpty())
+
+		Expect(decode(false).Content[1].Verbatim).To(BeEmpty())
+	})
+
+	It("writes the scalars that were not changed as they were", func() {
+		n := decode(true)
+		n.Content[3].Value = "0x20"
+		n.Content[7].Content[1].Style = DoubleQuotedStyle
+		Expect(encode(n)).To(Equal("name: \"caf\\u00e9\"\nmask: 0x20\n\"k\\x41\": &a !!str 012\nlist: ['it''s', \"1_000\"]\nalias: *a\nmulti: \"a b\"\n"))
+
+		Expect(encode(decode(false))).To(Equal("name: \"caf\\xE9\"\nmask: 0x1F\n\"kA\": &a !!str 012\nlist: ['it''s', 1_000]\nalias: *a\nmulti: \"a b\"\n"))
+	})
+
+	It("is ignored by deterministic encoders", func() {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.Deterministic(true)
+		Expect(e.Encode(decode(true).Content[1])).To(Succeed())
+		Expect(buf.String()).To(Equal("\"caf\\xE9\"\n"))
+	})
+})
@@ -0,0 +1,117 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "strconv"
+
+// A Path locates a Node from the Node a walk starts at, which is "", in
+// the form of the paths of Positions, such as "servers[0].host". Mapping
+// keys that are not scalars are written in the form EqualDocuments uses
+// for them.
+type Path string
+
+// A VisitAction tells Walk how to go on after visiting a Node.
+type VisitAction int
+
+const (
+	// Continue goes on with the children of the Node, then its siblings.
+	Continue VisitAction = iota
+	// SkipChildren goes on with the siblings of the Node, leaving out its
+	// children.
+	SkipChildren
+	// Stop ends the walk.
+	Stop
+)
+
+// Walk visits n and the Nodes within it depth-first, each before its
+// children, which are the items of sequences and the values of mappings.
+// The keys of mappings are not visited, but name the paths of their
+// values. An alias is visited, and not the Node it refers to, which is
+// visited where it is anchored. Walk returns false when visit stopped it.
+func (n *Node) Walk(visit func(path Path, n *Node) VisitAction) bool {
+	w := walker{visit: visit}
+	return w.walk(n)
+}
+
+// WalkPostOrder visits n and the Nodes within it as Walk does, but each
+// after its children, so that a Node is visited once those within it have
+// been, e.g. changed. SkipChildren is the same as Continue.
+func (n *Node) WalkPostOrder(visit func(path Path, n *Node) VisitAction) bool {
+	w := walker{visit: visit, postOrder: true}
+	return w.walk(n)
+}
+
+// a walk of the Nodes of Walk and WalkPostOrder, and the path of the Node
+// being visited
+type walker struct {
+	visit     func(Path, *Node) VisitAction
+	postOrder bool
+	path      []byte
+}
+
+func (w *walker) walk(n *Node) bool {
+	if n == nil {
+		return true
+	}
+	if !w.postOrder {
+		switch w.visit(Path(w.path), n) {
+		case Stop:
+			return false
+		case SkipChildren:
+			return true
+		}
+	}
+
+	length := len(w.path)
+	switch n.Kind {
+	case SequenceNode:
+		for i, c := range n.Content {
+			w.path = append(w.path, '[')
+			w.path = strconv.AppendInt(w.path, int64(i), 10)
+			w.path = append(w.path, ']')
+			ok := w.walk(c)
+			w.path = w.path[:length]
+			if !ok {
+				return false
+			}
+		}
+	case MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			w.pushKey(n.Content[i])
+			ok := w.walk(n.Content[i+1])
+			w.path = w.path[:length]
+			if !ok {
+				return false
+			}
+		}
+	}
+
+	if w.postOrder {
+		return w.visit(Path(w.path), n) != Stop
+	}
+	return true
+}
+
+// pushKey extends the path with a mapping key.
+func (w *walker) pushKey(key *Node) {
+	if len(w.path) > 0 {
+		w.path = append(w.path, '.')
+	}
+	if key = target(key); key.Kind == ScalarNode {
+		w.path = append(w.path, key.Value...)
+	} else {
+		w.path = append(w.path, (&comparison{}).canonical(key)...)
+	}
+}
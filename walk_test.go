@@ -0,0 +1,116 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Walk", func() {
+	var root *Node
+
+	BeforeEach(func() {
+		root = &Node{}
+		Expect(Unmarshal([]byte(`name: app
+servers:
+- host: a
+  port: 80
+- &b
+  host: b
+extra: *b
+`), root)).To(Succeed())
+	})
+
+	visits := func(walk func(func(Path, *Node) VisitAction) bool, action func(Path, *Node) VisitAction) ([]string, bool) {
+		var paths []string
+		done := walk(func(path Path, n *Node) VisitAction {
+			paths = append(paths, string(path)+" "+n.Kind.String())
+			return action(path, n)
+		})
+		return paths, done
+	}
+
+	continueAll := func(Path, *Node) VisitAction { return Continue }
+
+	It("visits nodes before their children with their paths", func() {
+		paths, done := visits(root.Walk, continueAll)
+		Expect(done).To(BeTrue())
+		Expect(paths).To(Equal([]string{
+			" mapping",
+			"name scalar",
+			"servers sequence",
+			"servers[0] mapping",
+			"servers[0].host scalar",
+			"servers[0].port scalar",
+			"servers[1] mapping",
+			"servers[1].host scalar",
+			"extra alias",
+		}))
+	})
+
+	It("visits nodes after their children in post-order", func() {
+		paths, done := visits(root.WalkPostOrder, continueAll)
+		Expect(done).To(BeTrue())
+		Expect(paths).To(Equal([]string{
+			"name scalar",
+			"servers[0].host scalar",
+			"servers[0].port scalar",
+			"servers[0] mapping",
+			"servers[1].host scalar",
+			"servers[1] mapping",
+			"servers sequence",
+			"extra alias",
+			" mapping",
+		}))
+	})
+
+	It("skips children", func() {
+		paths, _ := visits(root.Walk, func(path Path, n *Node) VisitAction {
+			if n.Kind == SequenceNode {
+				return SkipChildren
+			}
+			return Continue
+		})
+		Expect(paths).To(Equal([]string{" mapping", "name scalar", "servers sequence", "extra alias"}))
+	})
+
+	It("stops", func() {
+		paths, done := visits(root.Walk, func(path Path, n *Node) VisitAction {
+			if path == "servers[0].host" {
+				return Stop
+			}
+			return Continue
+		})
+		Expect(done).To(BeFalse())
+		Expect(paths).To(HaveLen(5))
+
+		paths, done = visits(root.WalkPostOrder, func(path Path, n *Node) VisitAction {
+			if path == "servers[0]" {
+				return Stop
+			}
+			return Continue
+		})
+		Expect(done).To(BeFalse())
+		Expect(paths).To(HaveLen(4))
+	})
+
+	It("names keys that are not scalars", func() {
+		n := &Node{}
+		Expect(Unmarshal([]byte("? [a, b]\n: c\n"), n)).To(Succeed())
+		paths, _ := visits(n.Walk, continueAll)
+		Expect(paths).To(Equal([]string{" mapping", "[tag:yaml.org,2002:str a, tag:yaml.org,2002:str b] scalar"}))
+	})
+})
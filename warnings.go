@@ -0,0 +1,216 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A WarningKind identifies the issue a Warning reports.
+type WarningKind int
+
+const (
+	// ImplicitBool is a scalar such as yes, no, on or off, which only
+	// YAML 1.1 reads as a boolean, decoded as one.
+	ImplicitBool WarningKind = iota + 1
+
+	// PrecisionLoss is a number decoded into a float that does not hold
+	// it exactly.
+	PrecisionLoss
+
+	// DuplicateKey is a key repeated in a mapping, of which the last value
+	// was kept.
+	DuplicateKey
+
+	// UnknownField is a key that matches no field of the struct decoded
+	// into, whose value was skipped. StrictMode fails the decoding instead.
+	UnknownField
+
+	// TabIndentation is a tab in the indentation of a line, where YAML
+	// accepts it: in a flow collection, on the continuation line of a
+	// scalar, or after the indentation of a block scalar, whose content it
+	// becomes. It is only reported with IndentationWarnings.
+	TabIndentation
+
+	// MixedIndentation is a block collection indented by another number of
+	// spaces than the first collection nested in its document. It is only
+	// reported with IndentationWarnings.
+	MixedIndentation
+
+	// UnknownDirective is a directive other than %YAML and %TAG that was
+	// skipped. It is only reported with WarnOnUnknownDirectives.
+	UnknownDirective
+)
+
+var warningKindNames = []string{
+	ImplicitBool:     "implicit bool",
+	PrecisionLoss:    "precision loss",
+	DuplicateKey:     "duplicate key",
+	UnknownField:     "unknown field",
+	TabIndentation:   "tab indentation",
+	MixedIndentation: "mixed indentation",
+	UnknownDirective: "unknown directive",
+}
+
+func (k WarningKind) String() string {
+	if k > 0 && int(k) < len(warningKindNames) {
+		return warningKindNames[k]
+	}
+	return fmt.Sprintf("warning kind %d", int(k))
+}
+
+// A Warning is an issue found while decoding that does not stop it, but
+// that may not give the values the author of the document intended.
+type Warning struct {
+	Kind     WarningKind
+	Message  string
+	Position Position
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s at line %d, column %d", w.Message, w.Position.Line, w.Position.Column)
+}
+
+// OnWarning sets a function that the Decoder calls with each Warning it
+// finds, or nil to ignore them, which is the default.
+func (d *Decoder) OnWarning(f func(Warning)) {
+	d.onWarning = f
+}
+
+// IndentationWarnings sets whether the Decoder reports the tabs in the
+// indentation of lines and the inconsistent indentation of block
+// collections as warnings, which explain why a document parses otherwise
+// than its layout suggests. It is off by default, and has no effect
+// without OnWarning.
+func (d *Decoder) IndentationWarnings(on bool) {
+	d.parser.keep_indentation = on
+}
+
+// warnIndentation reports the indentation issues the scanner recorded.
+func (d *Decoder) warnIndentation() {
+	for _, issue := range d.parser.indentation_issues {
+		if d.onWarning == nil {
+			break
+		}
+		if issue.tab {
+			d.warn(TabIndentation, issue.mark, "a tab indents the line")
+		} else {
+			d.warn(MixedIndentation, issue.mark, "a collection is indented by %d spaces where the document indents by %d",
+				issue.step, issue.document_step)
+		}
+	}
+	d.parser.indentation_issues = d.parser.indentation_issues[:0]
+}
+
+// warnDirectives reports the unknown directives the scanner skipped.
+func (d *Decoder) warnDirectives() {
+	for _, directive := range d.parser.unknown_directives {
+		if d.onWarning == nil {
+			break
+		}
+		text := "%" + string(directive.name)
+		if len(directive.value) > 0 {
+			text += " " + string(directive.value)
+		}
+		d.warn(UnknownDirective, directive.start_mark, "unknown directive '%s' was ignored", text)
+	}
+	d.parser.unknown_directives = d.parser.unknown_directives[:0]
+}
+
+func (d *Decoder) warn(kind WarningKind, at YAML_mark_t, format string, args ...interface{}) {
+	d.onWarning(Warning{
+		Kind:     kind,
+		Message:  fmt.Sprintf(format, args...),
+		Position: Position{Line: at.line + 1, Column: at.column + 1},
+	})
+}
+
+// checkScalar warns about the current scalar event having been resolved
+// with tag into v.
+func (d *Decoder) checkScalar(tag string, v reflect.Value) {
+	if d.onWarning == nil {
+		return
+	}
+
+	if v.Kind() == reflect.Interface {
+		// resolve reports values decoded into interfaces as strings
+		if v.IsNil() {
+			return
+		}
+		tag, _ = resolveInterface(d.event, d.useNumber)
+		v = v.Elem()
+	}
+
+	val := string(d.event.value)
+	switch tag {
+	case yaml_BOOL_TAG:
+		if b := strings.ToLower(val); b != "true" && b != "false" {
+			d.warn(ImplicitBool, d.event.start_mark, "'%s' was decoded as the boolean %t", val, v.Interface())
+		}
+	case yaml_FLOAT_TAG:
+		if k := v.Kind(); k != reflect.Float32 && k != reflect.Float64 {
+			return
+		}
+		bits := v.Type().Bits()
+		f := strconv.FormatFloat(v.Float(), 'g', -1, bits)
+		want, ok := decimal(val)
+		if got, _ := decimal(f); ok && got != want {
+			d.warn(PrecisionLoss, d.event.start_mark, "'%s' was decoded as the float%d %s", val, bits, f)
+		}
+	}
+}
+
+// decimal returns the decimal number s in a form that is the same for
+// every way to write it, or false if s is not one.
+func decimal(s string) (string, bool) {
+	s = strings.Replace(s, "_", "", -1)
+	sign := ""
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		if s[0] == '-' {
+			sign = "-"
+		}
+		s = s[1:]
+	}
+
+	exp := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return "", false
+		}
+		s, exp = s[:i], e
+	}
+	digits := s
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits = s[:i] + s[i+1:]
+		exp -= len(s) - i - 1
+	}
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return "", false
+	}
+
+	digits = strings.TrimLeft(digits, "0")
+	for strings.HasSuffix(digits, "0") {
+		digits = digits[:len(digits)-1]
+		exp++
+	}
+	if digits == "" {
+		return "0", true
+	}
+	return sign + digits + "e" + strconv.Itoa(exp), true
+}
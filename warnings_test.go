@@ -0,0 +1,140 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Warnings", func() {
+	decode := func(input string, v interface{}) []Warning {
+		var warnings []Warning
+		d := NewDecoder(strings.NewReader(input))
+		d.OnWarning(func(w Warning) { warnings = append(warnings, w) })
+		Expect(d.Decode(v)).To(Succeed())
+		return warnings
+	}
+
+	kinds := func(warnings []Warning) []WarningKind {
+		var k []WarningKind
+		for _, w := range warnings {
+			k = append(k, w.Kind)
+		}
+		return k
+	}
+
+	It("reports YAML 1.1 booleans", func() {
+		var v struct {
+			A, B bool
+			C    interface{}
+		}
+		warnings := decode("a: yes\nb: true\nc: Off\n", &v)
+
+		Expect(v.A).To(BeTrue())
+		Expect(warnings).To(Equal([]Warning{
+			{ImplicitBool, "'yes' was decoded as the boolean true", Position{1, 4}},
+			{ImplicitBool, "'Off' was decoded as the boolean false", Position{3, 4}},
+		}))
+	})
+
+	It("reports floats that do not hold the number written", func() {
+		var v struct {
+			Small  float32
+			Exact  float32
+			Big    float64
+			Plenty float64
+		}
+		warnings := decode("small: 16777217\nexact: 0.1\nbig: 9007199254740993\nplenty: 1.5e300\n", &v)
+
+		Expect(warnings).To(Equal([]Warning{
+			{PrecisionLoss, "'16777217' was decoded as the float32 1.6777216e+07", Position{1, 8}},
+			{PrecisionLoss, "'9007199254740993' was decoded as the float64 9.007199254740992e+15", Position{3, 6}},
+		}))
+
+		var i interface{}
+		Expect(kinds(decode("- 3.14159265358979323846\n- 2.5\n", &i))).To(Equal([]WarningKind{PrecisionLoss}))
+	})
+
+	It("reports duplicate keys", func() {
+		var s struct{ A int }
+		Expect(decode("a: 1\na: 3\n", &s)).To(Equal([]Warning{
+			{DuplicateKey, "duplicate key 'a', of which the last value is kept", Position{2, 1}},
+		}))
+		Expect(s.A).To(Equal(3))
+
+		var m map[string]int
+		Expect(kinds(decode("a: 1\na: 2\n", &m))).To(Equal([]WarningKind{DuplicateKey}))
+
+		var i interface{}
+		Expect(kinds(decode("{a: 1, a: 2}", &i))).To(Equal([]WarningKind{DuplicateKey}))
+	})
+
+	It("reports unknown fields outside of strict mode", func() {
+		var s struct{ A int }
+		Expect(decode("a: 1\nb: 2\n", &s)).To(Equal([]Warning{
+			{UnknownField, "key 'b' matches no field of struct { A int }", Position{2, 1}},
+		}))
+	})
+
+	It("finds nothing to report in plain documents", func() {
+		var i interface{}
+		Expect(decode("a: [1, 2.5, true, x]\nb: {c: null}\n", &i)).To(BeEmpty())
+	})
+
+	Context("indentation", func() {
+		decodeIndented := func(input string) []Warning {
+			var warnings []Warning
+			d := NewDecoder(strings.NewReader(input))
+			d.IndentationWarnings(true)
+			d.EmptyDocuments(EmptyIsEOF)
+			d.OnWarning(func(w Warning) { warnings = append(warnings, w) })
+			for {
+				var v interface{}
+				err := d.Decode(&v)
+				if err == io.EOF {
+					return warnings
+				}
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+
+		It("reports tabs in the indentation of lines", func() {
+			warnings := decodeIndented("a: {b: 1,\n\tc: 2}\nd: |\n  x\n  \ty\ne: f\n  \tg\nh: \"i\n\t\t j\"\nk:\t1\n")
+			Expect(warnings).To(Equal([]Warning{
+				{TabIndentation, "a tab indents the line", Position{2, 1}},
+				{TabIndentation, "a tab indents the line", Position{5, 3}},
+				{TabIndentation, "a tab indents the line", Position{7, 3}},
+				{TabIndentation, "a tab indents the line", Position{9, 1}},
+			}))
+		})
+
+		It("reports collections indented unlike the others of their document", func() {
+			warnings := decodeIndented("a:\n  b:\n    c: 1\n  d:\n      e: 1\n  f:\n  -   g: 1\n---\nh:\n    i: 1\n")
+			Expect(warnings).To(Equal([]Warning{
+				{MixedIndentation, "a collection is indented by 4 spaces where the document indents by 2", Position{5, 7}},
+				{MixedIndentation, "a collection is indented by 4 spaces where the document indents by 2", Position{7, 7}},
+			}))
+		})
+
+		It("reports nothing unless asked to", func() {
+			var i interface{}
+			Expect(decode("a: {b: 1,\n\tc: 2}\nd:\n    e: 1\nf:\n  g: 1\n", &i)).To(BeEmpty())
+		})
+	})
+})
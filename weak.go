@@ -0,0 +1,100 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// WeaklyTyped makes the decoder convert scalars whose type does not match
+// the value they are decoded into, for input written by loosely typed
+// producers. Quoted numbers such as "8080" always decode into numbers,
+// and numbers into strings; in addition, with WeaklyTyped:
+//
+//   - an empty string decodes into a number or boolean as its zero value
+//   - true and false decode into a number as 1 and 0
+//   - a number decodes into a boolean as whether it is not zero
+//   - a float without a fractional part, such as 8080.0 or 1e3, decodes
+//     into an integer
+func (d *Decoder) WeaklyTyped(on bool) {
+	d.weaklyTyped = on
+}
+
+// weakScalar decodes the scalar at the current event into v with the
+// conversions of WeaklyTyped, reporting whether it did and with which tag.
+func (d *Decoder) weakScalar(v reflect.Value) (string, bool) {
+	if isNull(d.event) || v.Type() == numberType || v.Type() == intStringType {
+		return "", false
+	}
+
+	val := strings.TrimSpace(string(d.event.value))
+	switch v.Kind() {
+	case reflect.Bool:
+		if val == "" {
+			v.SetBool(false)
+			return yaml_BOOL_TAG, true
+		}
+		if f, ok := weakFloat(val); ok {
+			v.SetBool(f != 0)
+			return yaml_BOOL_TAG, true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if val == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return yaml_INT_TAG, true
+		}
+		switch strings.ToLower(val) {
+		case "true":
+			v.Set(reflect.ValueOf(1).Convert(v.Type()))
+			return yaml_INT_TAG, true
+		case "false":
+			v.Set(reflect.Zero(v.Type()))
+			return yaml_INT_TAG, true
+		}
+		f, ok := weakFloat(val)
+		if !ok || f != math.Trunc(f) {
+			return "", false
+		}
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if f < math.MinInt64 || f >= math.MaxInt64 || v.OverflowInt(int64(f)) {
+				return "", false
+			}
+			v.SetInt(int64(f))
+			return yaml_INT_TAG, true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if f < 0 || f >= math.MaxUint64 || v.OverflowUint(uint64(f)) {
+				return "", false
+			}
+			v.SetUint(uint64(f))
+			return yaml_INT_TAG, true
+		}
+	}
+	return "", false
+}
+
+// weakFloat parses val as a finite number.
+func weakFloat(val string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.Replace(val, "_", "", -1), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
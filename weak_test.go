@@ -0,0 +1,78 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Weakly typed decoding", func() {
+	type settings struct {
+		Port    int
+		Name    string
+		Debug   bool
+		Retries uint8
+		Ratio   float64
+	}
+
+	decode := func(input string, weak bool) (settings, error) {
+		d := NewDecoder(strings.NewReader(input))
+		d.WeaklyTyped(weak)
+		var s settings
+		err := d.Decode(&s)
+		return s, err
+	}
+
+	It("converts quoted numbers and numbers into strings by default", func() {
+		s, err := decode("port: '8080'\nname: 8080\nratio: '0.5'\n", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s).To(Equal(settings{Port: 8080, Name: "8080", Ratio: 0.5}))
+	})
+
+	It("rejects the other mismatches by default", func() {
+		_, err := decode("port: 8080.0\n", false)
+		Expect(err).To(MatchError("port: Invalid integer: '8080.0' at line 0, column 6"))
+
+		_, err = decode("debug: 1\n", false)
+		Expect(err).To(MatchError("debug: Invalid boolean: '1' at line 0, column 7"))
+
+		_, err = decode("port: ''\n", false)
+		Expect(err).To(MatchError("port: Invalid integer: '' at line 0, column 6"))
+	})
+
+	It("converts them when weakly typed", func() {
+		s, err := decode("port: 8080.0\nretries: true\ndebug: '1'\nratio: ''\n", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s).To(Equal(settings{Port: 8080, Retries: 1, Debug: true}))
+
+		s, err = decode("port: 1e3\ndebug: 0\nretries: ''\n", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s).To(Equal(settings{Port: 1000}))
+	})
+
+	It("still rejects values that cannot be converted", func() {
+		_, err := decode("port: 1.5\n", true)
+		Expect(err).To(MatchError("port: Invalid integer: '1.5' at line 0, column 6"))
+
+		_, err = decode("retries: 300\n", true)
+		Expect(err).To(MatchError("retries: Invalid unsigned integer: '300' at line 0, column 9"))
+
+		_, err = decode("debug: maybe\n", true)
+		Expect(err).To(MatchError("debug: Invalid boolean: 'maybe' at line 0, column 7"))
+	})
+})
@@ -37,9 +37,13 @@ func yaml_emitter_flush(emitter *yaml_emitter_t) bool {
 		panic("Encoding must be set") /* Output encoding must be set. */
 	}
 
-	/* Check if the buffer is empty. */
+	/* Check if the buffer is empty, but for a line break held back. */
 
-	if emitter.buffer_pos == 0 {
+	end := emitter.buffer_pos
+	if emitter.hold_final_break {
+		end -= yaml_emitter_final_break(emitter)
+	}
+	if end == 0 {
 		return true
 	}
 
@@ -47,10 +51,10 @@ func yaml_emitter_flush(emitter *yaml_emitter_t) bool {
 
 	if emitter.encoding == yaml_UTF8_ENCODING {
 		if err := emitter.write_handler(emitter,
-			emitter.buffer[:emitter.buffer_pos]); err != nil {
+			emitter.buffer[:end]); err != nil {
 			return yaml_emitter_set_writer_error(emitter, "write error: "+err.Error())
 		}
-		emitter.buffer_pos = 0
+		emitter.buffer_pos = copy(emitter.buffer, emitter.buffer[end:emitter.buffer_pos])
 		return true
 	}
 
@@ -60,11 +64,11 @@ func yaml_emitter_flush(emitter *yaml_emitter_t) bool {
 	if emitter.encoding == yaml_UTF16LE_ENCODING {
 		low, high = 0, 1
 	} else {
-		high, low = 1, 0
+		low, high = 1, 0
 	}
 
 	pos := 0
-	for pos < emitter.buffer_pos {
+	for pos < end {
 
 		/*
 		 * See the "reader.c" code for more details on UTF-8 encoding.  Note
@@ -109,7 +113,7 @@ func yaml_emitter_flush(emitter *yaml_emitter_t) bool {
 			value -= 0x10000
 			b[high] = byte(0xD8 + (value >> 18))
 			b[low] = byte((value >> 10) & 0xFF)
-			b[high+2] = byte(0xDC + ((value >> 8) & 0xFF))
+			b[high+2] = byte(0xDC + ((value >> 8) & 0x03))
 			b[low+2] = byte(value & 0xFF)
 			emitter.raw_buffer = append(emitter.raw_buffer, b[0], b[1], b[2], b[3])
 		}
@@ -122,7 +126,22 @@ func yaml_emitter_flush(emitter *yaml_emitter_t) bool {
 		return yaml_emitter_set_writer_error(emitter, "write error: "+err.Error())
 	}
 
-	emitter.buffer_pos = 0
+	emitter.buffer_pos = copy(emitter.buffer, emitter.buffer[end:emitter.buffer_pos])
 	emitter.raw_buffer = emitter.raw_buffer[:0]
 	return true
 }
+
+/*
+ * Return the length of the line break ending the buffer, if any.
+ */
+
+func yaml_emitter_final_break(emitter *yaml_emitter_t) int {
+	buffer := emitter.buffer[:emitter.buffer_pos]
+	switch {
+	case len(buffer) >= 2 && buffer[len(buffer)-2] == '\r' && buffer[len(buffer)-1] == '\n':
+		return 2
+	case len(buffer) >= 1 && (buffer[len(buffer)-1] == '\r' || buffer[len(buffer)-1] == '\n'):
+		return 1
+	}
+	return 0
+}
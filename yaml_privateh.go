@@ -14,6 +14,11 @@ limitations under the License.
 
 package candiedyaml
 
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
 const (
 	INPUT_RAW_BUFFER_SIZE = 1024
 
@@ -38,6 +43,12 @@ const (
 
 	OUTPUT_RAW_BUFFER_SIZE = (OUTPUT_BUFFER_SIZE*2 + 2)
 
+	/*
+	 * The size the output buffer grows to before it is written out.
+	 */
+
+	OUTPUT_FLUSH_SIZE = 65536
+
 	INITIAL_STACK_SIZE = 16
 	INITIAL_QUEUE_SIZE = 16
 )
@@ -218,29 +229,80 @@ func is_printable_at(b []byte, i int) bool {
 		(b[i] == 0xEE) ||
 		(b[i] == 0xEF && /* && . != #xFEFF */
 			!(b[i+1] == 0xBB && b[i+2] == 0xBF) &&
-			!(b[i+1] == 0xBF && (b[i+2] == 0xBE || b[i+2] == 0xBF))))
+			!(b[i+1] == 0xBF && (b[i+2] == 0xBE || b[i+2] == 0xBF))) ||
+		(b[i] >= 0xF0 && b[i] <= 0xF4)) /* #x10000 <= . <= #x10FFFF */
 }
 
 func insert_token(parser *yaml_parser_t, pos int, token *yaml_token_t) {
-	// collapse the slice
-	if parser.tokens_head > 0 && len(parser.tokens) == cap(parser.tokens) {
-		if parser.tokens_head != len(parser.tokens) {
-			// move the tokens down
-			copy(parser.tokens, parser.tokens[parser.tokens_head:])
+	// collapse the slice when it is empty or full, so that it only grows
+	// with the lookahead, and drop the consumed tokens so that their
+	// values can be collected
+	if parser.tokens_head > 0 && (parser.tokens_head == len(parser.tokens) ||
+		len(parser.tokens) == cap(parser.tokens)) {
+		// move the tokens down
+		n := copy(parser.tokens, parser.tokens[parser.tokens_head:])
+		for i := n; i < len(parser.tokens); i++ {
+			parser.tokens[i] = yaml_token_t{}
 		}
 		// readjust the length
-		parser.tokens = parser.tokens[:len(parser.tokens)-parser.tokens_head]
+		parser.tokens = parser.tokens[:n]
 		parser.tokens_head = 0
 	}
 
 	parser.tokens = append(parser.tokens, *token)
 	if pos < 0 {
+		if token.token_type != yaml_STREAM_START_TOKEN {
+			parser.last_token_end = token.end_mark
+		}
 		return
 	}
 	copy(parser.tokens[parser.tokens_head+pos+1:], parser.tokens[parser.tokens_head+pos:])
 	parser.tokens[parser.tokens_head+pos] = *token
 }
 
+// the blocks of characters that terminals display two columns wide: East
+// Asian wide and fullwidth characters, and emoji
+var wide_ranges = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x2E80, 0x303E}, {0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF}, {0xA960, 0xA97F}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF},
+	{0xFE10, 0xFE19}, {0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6},
+	{0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF}, {0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A},
+	{0x1F200, 0x1F251}, {0x1F300, 0x1F64F}, {0x1F680, 0x1F6FF}, {0x1F900, 0x1F9FF},
+	{0x1FA70, 0x1FAFF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+// /*
+//  * Get the number of columns a terminal displays the character in: two
+//  * for wide characters, none for combining marks and format characters.
+//  */
+func display_width(r rune) int {
+	if r < 0x300 {
+		return 1
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, w := range wide_ranges {
+		if r < w[0] {
+			break
+		}
+		if r <= w[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// /*
+//  * Get the number of columns the character at the specified position
+//  * takes.
+//  */
+func display_width_at(b []byte, i int) int {
+	r, _ := utf8.DecodeRune(b[i:])
+	return display_width(r)
+}
+
 // /*
 //  * Check if the character at the specified position is BOM.
 //  */
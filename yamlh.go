@@ -78,6 +78,9 @@ const (
 	yaml_WRITER_ERROR
 	/** Cannot emit a YAML stream. */
 	yaml_EMITTER_ERROR
+
+	/** The input exceeds a limit of the parser. */
+	yaml_LIMIT_ERROR
 )
 
 /** The pointer position. */
@@ -90,6 +93,9 @@ type YAML_mark_t struct {
 
 	/** The position column. */
 	column int
+
+	/** The byte offset of the position in the input. */
+	offset int
 }
 
 func (m YAML_mark_t) String() string {
@@ -124,6 +130,10 @@ const (
 	yaml_LITERAL_SCALAR_STYLE
 	/** The folded scalar style. */
 	yaml_FOLDED_SCALAR_STYLE
+
+	/** Text written as it is, re-indented, for RawYAML. A value starting
+	 * with a line break starts on a line of its own. */
+	yaml_RAW_SCALAR_STYLE
 )
 
 /** Sequence styles. */
@@ -291,6 +301,35 @@ const (
 	yaml_MAPPING_END_EVENT
 )
 
+/** The unknown directive structure. */
+type yaml_unknown_directive_t struct {
+	/** The name and the parameters of the directive. */
+	name, value []byte
+	/** The position of the '%' indicator. */
+	start_mark YAML_mark_t
+}
+
+/** The indentation issue structure. */
+type yaml_indentation_issue_t struct {
+	/** Is it a tab in the indentation of a line, or a block collection
+	 * indented by a step other than that of the document? */
+	tab bool
+	/** The position of the tab or of the collection. */
+	mark YAML_mark_t
+	/** The step of the collection, and that of the document. */
+	step, document_step int
+}
+
+/** The comment structure. */
+type yaml_comment_t struct {
+	/** The position of the '#' indicator. */
+	start_mark YAML_mark_t
+	/** The comment text, starting with '#', or nothing for a blank line. */
+	value []byte
+	/** Does the comment follow a token on the same line? */
+	trailing bool
+}
+
 /** The event structure. */
 type yaml_event_t struct {
 
@@ -332,6 +371,11 @@ type yaml_event_t struct {
 
 	/** The beginning of the event. */
 	start_mark, end_mark YAML_mark_t
+
+	/** The comments on the lines before, at the end of and after the node. */
+	head_comment []byte
+	line_comment []byte
+	foot_comment []byte
 }
 
 /**
@@ -366,6 +410,17 @@ const (
 	yaml_DEFAULT_MAPPING_TAG = yaml_MAP_TAG
 
 	yaml_BINARY_TAG = "tag:yaml.org,2002:binary"
+
+	/** The tag @c !!set for mappings with null values. */
+	yaml_SET_TAG = "tag:yaml.org,2002:set"
+	/** The tag @c !!omap for sequences of unique key/value pairs. */
+	yaml_OMAP_TAG = "tag:yaml.org,2002:omap"
+	/** The tag @c !!pairs for sequences of key/value pairs. */
+	yaml_PAIRS_TAG = "tag:yaml.org,2002:pairs"
+	/** The tag @c !!merge for merge keys. */
+	yaml_MERGE_TAG = "tag:yaml.org,2002:merge"
+	/** The tag @c !!value for default value keys. */
+	yaml_VALUE_TAG = "tag:yaml.org,2002:value"
 )
 
 /** Node types. */
@@ -563,6 +618,34 @@ type yaml_alias_data_t struct {
 	mark YAML_mark_t
 }
 
+/**
+ * The options of a parser, which it keeps when it is reset.
+ */
+
+type yaml_parser_options_t struct {
+	/** Keep the input read in source? */
+	keep_source bool
+
+	/** Are comments recorded? */
+	keep_comments bool
+
+	/** Are unknown directives an error, or recorded when skipped? */
+	reject_unknown_directives bool
+	keep_unknown_directives   bool
+
+	/** Are indentation issues recorded? */
+	keep_indentation bool
+
+	/** The limits on the length of scalars, the nesting of flow
+	 * collections and the simple keys outstanding, 0 for none. */
+	max_scalar_length int
+	max_flow_level    int
+	max_simple_keys   int
+
+	/** May documents after the first omit the document start marker? */
+	implicit_documents bool
+}
+
 /**
  * The parser structure.
  *
@@ -571,6 +654,7 @@ type yaml_alias_data_t struct {
  */
 
 type yaml_parser_t struct {
+	yaml_parser_options_t
 
 	/**
 	 * @name Error handling
@@ -626,6 +710,42 @@ type yaml_parser_t struct {
 	/** The input encoding. */
 	encoding yaml_encoding_t
 
+	/** The style of the first line break found in the input. */
+	line_break yaml_break_t
+
+	/** The number of line breaks of each style found in the input. */
+	break_counts [4]int
+
+	/** Does the input start with a UTF-8 BOM? */
+	has_bom bool
+
+	/** The input read, which starts at source_offset, if kept. */
+	source        []byte
+	source_offset int
+
+	/** The end of the last token. */
+	last_token_end YAML_mark_t
+
+	/** The comments recorded and not yet consumed. */
+	comments []yaml_comment_t
+
+	/** The unknown directives skipped and not yet consumed. */
+	unknown_directives []yaml_unknown_directive_t
+
+	/** The indentation issues recorded and not yet consumed. */
+	indentation_issues []yaml_indentation_issue_t
+	/** The step of the first block collection nested in the document. */
+	indentation_step int
+	/** The line after the last one a tab was recorded on. */
+	tab_line int
+
+	/** Is the text read past kept? */
+	keep_text bool
+	/** The text read past and not yet taken, which continues in the
+	 * buffer from text_pos. */
+	text     []byte
+	text_pos int
+
 	/** The offset of the current position (in bytes). */
 	offset int
 
@@ -813,6 +933,13 @@ type yaml_emitter_t struct {
 	buffer     []byte
 	buffer_pos int
 
+	/** Keep finished documents in the buffer until it fills or is flushed. */
+	defer_flush bool
+	/** The size the buffer is flushed at. */
+	flush_size int
+	/** Keep a final line break in the buffer, so that it can be dropped. */
+	hold_final_break bool
+
 	/** The raw buffer. */
 	raw_buffer     []byte
 	raw_buffer_pos int
@@ -831,12 +958,24 @@ type yaml_emitter_t struct {
 
 	/** If the output is in the canonical style? */
 	canonical bool
+	/** Omit the space after flow entry separators? */
+	compact bool
 	/** The number of indentation spaces. */
 	best_indent int
 	/** The preferred width of the output lines. */
 	best_width int
 	/** Allow unescaped non-ASCII characters? */
 	unicode bool
+	/** Count the columns characters are displayed in, rather than characters? */
+	display_width bool
+	/** The length of the longest simple key, or zero for 128. */
+	max_simple_key_length int
+	/** Never break lines within plain scalars? */
+	no_plain_breaks bool
+	/** Indent block sequences that are the values of block mappings? */
+	indent_sequences bool
+	/** Start the mappings that are items of block sequences on a line of their own? */
+	no_compact_mappings bool
 	/** The preferred line break. */
 	line_break yaml_break_t
 
@@ -859,6 +998,9 @@ type yaml_emitter_t struct {
 	/** The current indentation level. */
 	indent int
 
+	/** The indentation added to every line, for a fragment. */
+	base_indent int
+
 	/** The current flow level. */
 	flow_level int
 
@@ -882,6 +1024,17 @@ type yaml_emitter_t struct {
 	/** If an explicit document end is required? */
 	open_ended bool
 
+	/** The head comment waiting for the first item of a block collection. */
+	head_comment []byte
+	/** The line comment of the current block scalar. */
+	line_comment []byte
+	/** The line comment of the current simple key. */
+	key_line_comment []byte
+	/** The line following the last blank line written. */
+	blank_line int
+	/** Is the outermost flow collection a simple key? */
+	flow_simple_key bool
+
 	/** Anchor analysis. */
 	anchor_data struct {
 		/** The anchor value. */
@@ -0,0 +1,265 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package yamltest checks YAML parsers against the YAML test suite
+// (https://github.com/yaml/yaml-test-suite), by writing the events of a
+// document in the notation of its test.event files and comparing them.
+//
+// The events are those of a Parser, which is candiedyaml.ParseAllEvents by
+// default: a project with its own resolvers or event filters passes a
+// Parser that applies them to check that they keep the events conformant.
+package yamltest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+)
+
+// A Parser returns the events of the documents in src, up to the first
+// error if there is one.
+type Parser func(src []byte) ([]candiedyaml.Event, error)
+
+// Parse is the Parser of candiedyaml.
+var Parse Parser = candiedyaml.ParseAllEvents
+
+// FormatEvent returns ev in the notation of the test suite, as in
+// "+MAP {} &a <tag:yaml.org,2002:map>" or "=VAL 'some\ttext".
+func FormatEvent(ev candiedyaml.Event) string {
+	var b strings.Builder
+	switch ev.Kind {
+	case candiedyaml.StreamStartEvent:
+		return "+STR"
+	case candiedyaml.StreamEndEvent:
+		return "-STR"
+	case candiedyaml.DocumentStartEvent:
+		if ev.Implicit {
+			return "+DOC"
+		}
+		return "+DOC ---"
+	case candiedyaml.DocumentEndEvent:
+		if ev.Implicit {
+			return "-DOC"
+		}
+		return "-DOC ..."
+	case candiedyaml.AliasEvent:
+		return "=ALI *" + ev.Anchor
+	case candiedyaml.SequenceEndEvent:
+		return "-SEQ"
+	case candiedyaml.MappingEndEvent:
+		return "-MAP"
+	case candiedyaml.SequenceStartEvent:
+		b.WriteString("+SEQ")
+		if ev.Flow {
+			b.WriteString(" []")
+		}
+	case candiedyaml.MappingStartEvent:
+		b.WriteString("+MAP")
+		if ev.Flow {
+			b.WriteString(" {}")
+		}
+	case candiedyaml.ScalarEvent:
+		b.WriteString("=VAL")
+	default:
+		return fmt.Sprintf("?%d", int(ev.Kind))
+	}
+
+	if ev.Anchor != "" {
+		b.WriteString(" &" + ev.Anchor)
+	}
+	if ev.Tag != "" {
+		b.WriteString(" <" + ev.Tag + ">")
+	}
+	if ev.Kind == candiedyaml.ScalarEvent {
+		b.WriteString(" " + styleIndicators[ev.Style] + escaper.Replace(ev.Value))
+	}
+	return b.String()
+}
+
+var styleIndicators = map[candiedyaml.ScalarStyle]string{
+	candiedyaml.AnyStyle:          ":",
+	candiedyaml.PlainStyle:        ":",
+	candiedyaml.SingleQuotedStyle: "'",
+	candiedyaml.DoubleQuotedStyle: `"`,
+	candiedyaml.LiteralStyle:      "|",
+	candiedyaml.FoldedStyle:       ">",
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\x00", `\0`,
+	"\b", `\b`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
+
+// FormatEvents returns events in the notation of the test suite, one event
+// per line.
+func FormatEvents(events []candiedyaml.Event) string {
+	var b strings.Builder
+	for _, ev := range events {
+		b.WriteString(FormatEvent(ev))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// A MismatchError reports the first line where the events of a document
+// differ from those expected. An empty Got or Want is a missing line.
+type MismatchError struct {
+	Line      int
+	Got, Want string
+}
+
+func (e *MismatchError) Error() string {
+	switch {
+	case e.Got == "":
+		return fmt.Sprintf("event %d: missing %q", e.Line, e.Want)
+	case e.Want == "":
+		return fmt.Sprintf("event %d: unexpected %q", e.Line, e.Got)
+	}
+	return fmt.Sprintf("event %d: got %q, want %q", e.Line, e.Got, e.Want)
+}
+
+// CompareEvents compares events to want, in the notation of the test
+// suite, and returns a *MismatchError for the first line that differs.
+// Blank lines and the indentation of lines in want are ignored.
+func CompareEvents(events []candiedyaml.Event, want string) error {
+	var lines []string
+	for _, line := range strings.Split(want, "\n") {
+		if line = strings.TrimLeft(line, " \t"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	for i, ev := range events {
+		got := FormatEvent(ev)
+		if i == len(lines) {
+			return &MismatchError{Line: i + 1, Got: got}
+		}
+		if got != lines[i] {
+			return &MismatchError{Line: i + 1, Got: got, Want: lines[i]}
+		}
+	}
+	if len(events) < len(lines) {
+		return &MismatchError{Line: len(events) + 1, Want: lines[len(events)]}
+	}
+	return nil
+}
+
+// A Case is a test of the test suite: a document, and either the events
+// it parses to or the fact that it is invalid.
+type Case struct {
+	// Name is the path of the directory of the case, relative to the
+	// directory of the suite, as in "229Q" or "2JQS/00".
+	Name string
+
+	// Title is the description of the case, which may be empty.
+	Title string
+
+	// Input is the document, read from in.yaml.
+	Input []byte
+
+	// Events are the events of the document, read from test.event.
+	Events string
+
+	// Error is true when the document is invalid, and parsing it must
+	// fail.
+	Error bool
+}
+
+// Run parses the input of the case with parse and returns an error when
+// its events are not those of the case, or when it parses without error
+// an invalid document.
+func (c *Case) Run(parse Parser) error {
+	events, err := parse(c.Input)
+	switch {
+	case c.Error && err == nil:
+		return fmt.Errorf("%s: parsed an invalid document", c.Name)
+	case c.Error:
+		return nil
+	case err != nil:
+		return fmt.Errorf("%s: %v", c.Name, err)
+	}
+	if err := CompareEvents(events, c.Events); err != nil {
+		return fmt.Errorf("%s: %v", c.Name, err)
+	}
+	return nil
+}
+
+// ReadCases reads the cases of the test suite in dir, a checkout of its
+// data branch: every directory below dir holding an in.yaml file is a
+// case. The cases are sorted by name.
+func ReadCases(dir string) ([]*Case, error) {
+	var cases []*Case
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		input, err := ioutil.ReadFile(filepath.Join(path, "in.yaml"))
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		c := &Case{Input: input}
+		if c.Name, err = filepath.Rel(dir, path); err != nil {
+			return err
+		}
+		c.Name = filepath.ToSlash(c.Name)
+		if title, err := ioutil.ReadFile(filepath.Join(path, "===")); err == nil {
+			c.Title = strings.TrimSpace(string(title))
+		}
+		if _, err := os.Stat(filepath.Join(path, "error")); err == nil {
+			c.Error = true
+		}
+		events, err := ioutil.ReadFile(filepath.Join(path, "test.event"))
+		if err != nil && !(c.Error && os.IsNotExist(err)) {
+			return err
+		}
+		c.Events = string(events)
+		cases = append(cases, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+// Run runs the cases of the test suite in dir with parse, and returns the
+// errors of those that fail.
+func Run(dir string, parse Parser) ([]error, error) {
+	cases, err := ReadCases(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []error
+	for _, c := range cases {
+		if err := c.Run(parse); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	return failures, nil
+}
@@ -0,0 +1,136 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yamltest
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Yamltest", func() {
+	It("writes events in the notation of the test suite", func() {
+		events, err := Parse([]byte("--- &a !!map\nx: [*a, 'y\\z']\nt: |\n  a\tb\n...\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(FormatEvents(events)).To(Equal(`+STR
++DOC ---
++MAP &a <tag:yaml.org,2002:map>
+=VAL :x
++SEQ []
+=ALI *a
+=VAL 'y\\z
+-SEQ
+=VAL :t
+=VAL |a\tb\n
+-MAP
+-DOC ...
+-STR
+`))
+	})
+
+	It("compares events to those expected", func() {
+		events, err := Parse([]byte("- \"a\"\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(CompareEvents(events, "+STR\n +DOC\n  +SEQ\n   =VAL \"a\n  -SEQ\n -DOC\n-STR\n\n")).To(Succeed())
+		Expect(CompareEvents(events, "+STR\n+DOC\n+SEQ\n=VAL :a\n-SEQ\n-DOC\n-STR\n")).To(MatchError(
+			&MismatchError{Line: 4, Got: `=VAL "a`, Want: "=VAL :a"}))
+		Expect(CompareEvents(events, "+STR\n+DOC\n+SEQ\n")).To(MatchError(`event 4: unexpected "=VAL \"a"`))
+		Expect(CompareEvents(events[:2], "+STR\n+DOC\n+SEQ\n")).To(MatchError(`event 3: missing "+SEQ"`))
+	})
+
+	Context("with a suite", func() {
+		var dir string
+
+		writeCase := func(name string, files map[string]string) {
+			path := filepath.Join(dir, name)
+			Expect(os.MkdirAll(path, 0755)).To(Succeed())
+			for file, content := range files {
+				Expect(ioutil.WriteFile(filepath.Join(path, file), []byte(content), 0644)).To(Succeed())
+			}
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "yamltest")
+			Expect(err).NotTo(HaveOccurred())
+
+			writeCase("AAAA", map[string]string{
+				"===":        "A scalar\n",
+				"in.yaml":    "a\n",
+				"test.event": "+STR\n+DOC\n=VAL :a\n-DOC\n-STR\n",
+			})
+			writeCase("BBBB/00", map[string]string{
+				"in.yaml":    "[a\n",
+				"test.event": "+STR\n+DOC\n+SEQ []\n",
+				"error":      "",
+			})
+			writeCase("BBBB/01", map[string]string{
+				"in.yaml":    "{a: b}\n",
+				"test.event": "+STR\n+DOC\n+MAP {}\n=VAL :a\n=VAL :b\n-MAP\n-DOC\n-STR\n",
+			})
+			writeCase("name", map[string]string{})
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("reads the cases", func() {
+			cases, err := ReadCases(dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cases).To(HaveLen(3))
+			Expect(*cases[0]).To(Equal(Case{
+				Name:   "AAAA",
+				Title:  "A scalar",
+				Input:  []byte("a\n"),
+				Events: "+STR\n+DOC\n=VAL :a\n-DOC\n-STR\n",
+			}))
+			Expect(cases[1].Name).To(Equal("BBBB/00"))
+			Expect(cases[1].Error).To(BeTrue())
+			Expect(cases[2].Name).To(Equal("BBBB/01"))
+		})
+
+		It("runs the cases", func() {
+			failures, err := Run(dir, Parse)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(failures).To(BeEmpty())
+		})
+
+		It("reports the cases a parser fails", func() {
+			failures, err := Run(dir, func(src []byte) ([]candiedyaml.Event, error) {
+				events, err := Parse(src)
+				if err != nil {
+					return events, nil
+				}
+				for i := range events {
+					events[i].Flow = false
+				}
+				return events, errors.New("too late")
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(failures).To(HaveLen(3))
+			Expect(failures[0]).To(MatchError("AAAA: too late"))
+			Expect(failures[1]).To(MatchError("BBBB/00: parsed an invalid document"))
+			Expect(failures[2]).To(MatchError("BBBB/01: too late"))
+		})
+	})
+})
@@ -0,0 +1,41 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import "unsafe"
+
+// ZeroCopy sets whether the strings the Decoder decodes share the memory
+// the scanner read each scalar into, rather than being copied from it,
+// which saves an allocation and a copy for each string of a document
+// heavy with scalars. It is off by default, as a string shared this way
+// keeps the whole buffer of its scalar alive, which is at least 32 bytes,
+// for as long as the string is; decoding many short strings that are kept
+// long after the document can then take more memory than copying them.
+//
+// The buffers of scalars are never reused or changed once read, so the
+// strings stay valid whatever the Decoder reads next.
+func (d *Decoder) ZeroCopy(on bool) {
+	d.zeroCopy = on
+}
+
+// scalarValue returns the value of the current scalar event as a string,
+// shared with the event when ZeroCopy is on.
+func (d *Decoder) scalarValue() string {
+	if d.zeroCopy && len(d.event.value) > 0 {
+		b := d.event.value
+		return *(*string)(unsafe.Pointer(&b))
+	}
+	return string(d.event.value)
+}
@@ -0,0 +1,79 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package candiedyaml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Zero-copy decoding", func() {
+	decode := func(input []byte, zeroCopy bool, v interface{}) {
+		d := NewDecoder(bytes.NewReader(input))
+		d.ZeroCopy(zeroCopy)
+		Expect(d.Decode(v)).To(Succeed())
+	}
+
+	It("decodes the values copying does", func() {
+		input := []byte("name: &n caf\u00e9\nquoted: 'it''s'\nfolded: >\n  a\n  b\nn: *n\ncount: 12\nempty: ''\nnothing:\n")
+
+		var copied, shared interface{}
+		decode(input, false, &copied)
+		decode(input, true, &shared)
+		Expect(shared).To(Equal(copied))
+
+		type doc struct {
+			Name, Quoted, Folded, N string
+			Count                   Number
+		}
+		var copiedDoc, sharedDoc doc
+		decode(input, false, &copiedDoc)
+		decode(input, true, &sharedDoc)
+		Expect(sharedDoc).To(Equal(copiedDoc))
+		Expect(sharedDoc.Folded).To(Equal("a b\n"))
+
+		var copiedNode, sharedNode Node
+		decode(input, false, &copiedNode)
+		decode(input, true, &sharedNode)
+		Expect(sharedNode).To(Equal(copiedNode))
+	})
+
+	It("keeps the strings decoded while the Decoder reads on", func() {
+		d := NewDecoder(strings.NewReader("[a, bb, ccc]\n---\n[x, yy, zzz]\n---\n[1, 22, 333]\n"))
+		d.ZeroCopy(true)
+
+		var first, v []string
+		Expect(d.Decode(&first)).To(Succeed())
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(d.Decode(&v)).To(Succeed())
+		Expect(first).To(Equal([]string{"a", "bb", "ccc"}))
+		Expect(v).To(Equal([]string{"1", "22", "333"}))
+	})
+
+	It("allocates less than copying", func() {
+		input := benchmarkInput(100)
+		allocs := func(zeroCopy bool) float64 {
+			return testing.AllocsPerRun(5, func() {
+				var v interface{}
+				decode(input, zeroCopy, &v)
+			})
+		}
+		Expect(allocs(true)).To(BeNumerically("<", allocs(false)-500))
+	})
+})